@@ -0,0 +1,63 @@
+package ble
+
+import "time"
+
+// AdapterCounters are the counters of an adapter, as the kernel keeps them
+// since it was registered. They are 32 bits, and wrap.
+type AdapterCounters struct {
+	ErrRx  uint32 // Packets received in error, such as malformed, or of unknown type.
+	ErrTx  uint32 // Packets failed to send.
+	CmdTx  uint32 // Commands sent.
+	EvtRx  uint32 // Events received.
+	ACLTx  uint32 // ACL packets sent.
+	ACLRx  uint32 // ACL packets received.
+	SCOTx  uint32 // SCO packets sent.
+	SCORx  uint32 // SCO packets received.
+	ByteTx uint32 // Bytes sent.
+	ByteRx uint32 // Bytes received.
+}
+
+// AdapterRates are the rates of the AdapterCounters, per second.
+type AdapterRates struct {
+	ErrRx  float64
+	ErrTx  float64
+	CmdTx  float64
+	EvtRx  float64
+	ACLTx  float64
+	ACLRx  float64
+	SCOTx  float64
+	SCORx  float64
+	ByteTx float64
+	ByteRx float64
+}
+
+// AdapterStats is a sample of the counters of an adapter, with their rates
+// since the previous sample, so that a controller babbling events, or a storm
+// of receive errors, shows.
+type AdapterStats struct {
+	Time     time.Time
+	Counters AdapterCounters
+	Interval time.Duration // Since the previous sample, or 0 of the first, which has no rates.
+	Rates    AdapterRates
+}
+
+// Rates returns the rates of the counters, from prev, d before. The counters
+// are taken to have wrapped, if less than prev, but not reset.
+func (c AdapterCounters) Rates(prev AdapterCounters, d time.Duration) AdapterRates {
+	if d <= 0 {
+		return AdapterRates{}
+	}
+	rate := func(n, p uint32) float64 { return float64(n-p) / d.Seconds() }
+	return AdapterRates{
+		ErrRx:  rate(c.ErrRx, prev.ErrRx),
+		ErrTx:  rate(c.ErrTx, prev.ErrTx),
+		CmdTx:  rate(c.CmdTx, prev.CmdTx),
+		EvtRx:  rate(c.EvtRx, prev.EvtRx),
+		ACLTx:  rate(c.ACLTx, prev.ACLTx),
+		ACLRx:  rate(c.ACLRx, prev.ACLRx),
+		SCOTx:  rate(c.SCOTx, prev.SCOTx),
+		SCORx:  rate(c.SCORx, prev.SCORx),
+		ByteTx: rate(c.ByteTx, prev.ByteTx),
+		ByteRx: rate(c.ByteRx, prev.ByteRx),
+	}
+}
@@ -18,3 +18,65 @@ type addr string
 func (a addr) String() string {
 	return string(a)
 }
+
+// AddrType is the type of a device address, which tells a public address
+// from a random one. A peer is dialed, accept listed, and resolved by the
+// address along with its type. [Vol 6, Part B, 1.3]
+type AddrType uint8
+
+// AddrTypes.
+const (
+	AddrTypeUnknown AddrType = iota // Not told, as of NewAddr, or of the UUIDs of OS X.
+	AddrTypePublic                  // A public device address.
+	AddrTypeRandom                  // A random device address, static or private.
+)
+
+func (t AddrType) String() string {
+	switch t {
+	case AddrTypePublic:
+		return "public"
+	case AddrTypeRandom:
+		return "random"
+	}
+	return "unknown"
+}
+
+// A TypedAddr is an Addr, which tells the type of the address as well. Its
+// String is the address alone, as of an Addr.
+type TypedAddr interface {
+	Addr
+	Type() AddrType
+}
+
+// NewTypedAddr creates an Addr of type t from string.
+func NewTypedAddr(s string, t AddrType) TypedAddr {
+	return typedAddr{addr(strings.ToLower(s)), t}
+}
+
+type typedAddr struct {
+	addr
+	t AddrType
+}
+
+func (a typedAddr) Type() AddrType { return a.t }
+
+// AddrTypeOf returns the type of a, or AddrTypeUnknown, if a doesn't tell.
+func AddrTypeOf(a Addr) AddrType {
+	if t, ok := a.(TypedAddr); ok {
+		return t.Type()
+	}
+	return AddrTypeUnknown
+}
+
+// SameAddr reports whether a and b are the same address: the same, ignoring
+// the case, and of the same type, unless either doesn't tell it.
+func SameAddr(a, b Addr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if !strings.EqualFold(a.String(), b.String()) {
+		return false
+	}
+	ta, tb := AddrTypeOf(a), AddrTypeOf(b)
+	return ta == AddrTypeUnknown || tb == AddrTypeUnknown || ta == tb
+}
@@ -9,3 +9,40 @@ func TestNewAddr(t *testing.T) {
 		t.Error("address should be \"test\" but is ", a.String())
 	}
 }
+
+func TestSameAddr(t *testing.T) {
+	pub := NewTypedAddr("11:22:33:44:55:66", AddrTypePublic)
+	rnd := NewTypedAddr("11:22:33:44:55:66", AddrTypeRandom)
+	for _, tc := range []struct {
+		a, b Addr
+		want bool
+	}{
+		{pub, NewAddr("11:22:33:44:55:66"), true},
+		{pub, NewTypedAddr("11:22:33:44:55:66", AddrTypePublic), true},
+		{pub, rnd, false},
+		{rnd, NewAddr("11:22:33:44:55:67"), false},
+	} {
+		if got := SameAddr(tc.a, tc.b); got != tc.want {
+			t.Errorf("SameAddr(%s %s, %s %s) = %v, want %v", AddrTypeOf(tc.a), tc.a, AddrTypeOf(tc.b), tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestBondStorePeerType(t *testing.T) {
+	s := NewMemoryBondStore()
+	s.Save(NewTypedAddr("C1:22:33:44:55:66", AddrTypeRandom), BondPeerCSRK, []byte{1})
+	s.Save(NewAddr("11:22:33:44:55:66"), BondPeerCSRK, []byte{1})
+	peers, err := s.(BondLister).Peers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range peers {
+		want := AddrTypeUnknown
+		if p.String() == "c1:22:33:44:55:66" {
+			want = AddrTypeRandom
+		}
+		if typ := AddrTypeOf(p); typ != want {
+			t.Errorf("peer %s of type %s, want %s", p, typ, want)
+		}
+	}
+}
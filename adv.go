@@ -1,5 +1,7 @@
 package ble
 
+import "time"
+
 // AdvHandler handles advertisement.
 type AdvHandler func(a Advertisement)
 
@@ -16,13 +18,112 @@ type Advertisement interface {
 	TxPowerLevel() int
 	Connectable() bool
 	SolicitedService() []UUID
+	URI() string
+	Appearance() uint16
+	AdvInterval() time.Duration
+	LERole() LERole
+	PublicTargetAddrs() []Addr
+
+	// Records returns the AD structures of the advertising data, followed by
+	// those of the scan response, as received, for the fields which have no
+	// accessors.
+	Records() []ADStructure
 
 	RSSI() int
 	Addr() Addr
 }
 
+// An ADStructure is a field of the advertising data or the scan response.
+// [Vol 3, Part C, 11]
+type ADStructure struct {
+	Type byte
+	Data []byte
+}
+
+// LERole is the support of the roles of an advertiser, as told by the LE Role
+// field. [CSS, Part A, 1.17]
+type LERole int
+
+// LERoles.
+const (
+	LERoleUnknown             LERole = -1   // The advertisement has no LE Role field.
+	LERolePeripheral          LERole = 0x00 // Only the peripheral role is supported.
+	LERoleCentral             LERole = 0x01 // Only the central role is supported.
+	LERolePeripheralPreferred LERole = 0x02 // Both roles are supported, the peripheral role is preferred.
+	LERoleCentralPreferred    LERole = 0x03 // Both roles are supported, the central role is preferred.
+)
+
+// ServiceDataMap returns the service data of a, by the String of their
+// UUIDs. The first data of a UUID advertised several times are kept.
+func ServiceDataMap(a Advertisement) map[string][]byte {
+	m := make(map[string][]byte)
+	for _, sd := range a.ServiceData() {
+		if _, ok := m[sd.UUID.String()]; !ok {
+			m[sd.UUID.String()] = sd.Data
+		}
+	}
+	return m
+}
+
+// The Flags field, and its BR/EDR Not Supported bit. [CSS, Part A, 1.3]
+const (
+	adFlags               = 0x01
+	flagBREDRNotSupported = 0x04
+)
+
+// BREDRSupported reports whether the advertiser of a supports BR/EDR, as well
+// as LE, i.e. its Flags field lacks the BR/EDR Not Supported bit. The
+// advertisements without the Flags field, such as those of beacons, are
+// taken as of LE only devices.
+func BREDRSupported(a Advertisement) bool {
+	for _, r := range a.Records() {
+		if r.Type == adFlags && len(r.Data) > 0 {
+			return r.Data[0]&flagBREDRNotSupported == 0
+		}
+	}
+	return false
+}
+
 // ServiceData ...
 type ServiceData struct {
 	UUID UUID
 	Data []byte
 }
+
+// A ScanFilter selects advertisements by the address of the advertiser, and
+// by a service UUID it advertises. A nil field matches any advertisement.
+type ScanFilter struct {
+	Addr    Addr
+	Service UUID
+}
+
+// Match reports whether a matches the filter.
+func (f ScanFilter) Match(a Advertisement) bool {
+	if f.Addr != nil && !SameAddr(f.Addr, a.Addr()) {
+		return false
+	}
+	if f.Service == nil {
+		return true
+	}
+	// Not Contains, which matches a nil slice.
+	for _, u := range a.Services() {
+		if u.Equal(f.Service) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchScanFilters reports whether a matches any of the filters, or there
+// are none.
+func MatchScanFilters(fs []ScanFilter, a Advertisement) bool {
+	if len(fs) == 0 {
+		return true
+	}
+	for _, f := range fs {
+		if f.Match(a) {
+			return true
+		}
+	}
+	return false
+}
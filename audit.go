@@ -0,0 +1,15 @@
+package ble
+
+// An ATTOp is an attribute operation of a client of the GATT server, as told
+// to an AuditHook.
+type ATTOp struct {
+	Addr   Addr     // Address of the client.
+	Opcode uint8    // ATT opcode of the request or command.
+	Handle uint16   // Handle of the attribute, or the first of the range; 0 if none.
+	Err    ATTError // ErrSuccess, or the error responded with, or the command dropped for.
+}
+
+// An AuditHook is called for each attribute operation of the clients of the
+// GATT server, once it's handled, so that what they do can be audited. It's
+// called from the loop of the connection, and shouldn't block.
+type AuditHook func(op ATTOp)
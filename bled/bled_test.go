@@ -0,0 +1,144 @@
+package bled
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/mock"
+)
+
+var (
+	testSvcUUID    = ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+	testReadUUID   = ble.MustParse("5e0a0002-0000-1000-8000-00805f9b34fb")
+	testNotifyUUID = ble.MustParse("5e0a0003-0000-1000-8000-00805f9b34fb")
+	testDenyUUID   = ble.MustParse("5e0a0004-0000-1000-8000-00805f9b34fb")
+)
+
+func TestSharedDevice(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	svc := ble.NewService(testSvcUUID)
+	svc.NewCharacteristic(testReadUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write([]byte("hello"))
+	}))
+	svc.NewCharacteristic(testNotifyUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		n.Write([]byte("tick"))
+		<-n.Context().Done()
+	}))
+	svc.NewCharacteristic(testDenyUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.SetStatus(ble.ErrReadNotPerm)
+	}))
+	if err := p.AddService(svc); err != nil {
+		t.Fatal(err)
+	}
+	advCtx, stopAdv := context.WithCancel(ctx)
+	defer stopAdv()
+	go p.AdvertiseNameAndServices(advCtx, "Peripheral", testSvcUUID)
+
+	// Two clients share the device of the daemon.
+	s := NewServer(c)
+	var ds []*Device
+	for i := 0; i < 2; i++ {
+		sc, cc := net.Pipe()
+		go s.ServeConn(sc)
+		d := NewDevice(cc)
+		defer d.Stop()
+		ds = append(ds, d)
+	}
+
+	found := make(chan ble.Advertisement, 2)
+	scanCtx, stopScan := context.WithCancel(ctx)
+	scanned := make(chan error, 2)
+	for _, d := range ds {
+		d := d
+		go func() {
+			scanned <- d.Scan(scanCtx, false, func(a ble.Advertisement) {
+				if a.LocalName() == "Peripheral" {
+					found <- a
+				}
+			})
+		}()
+	}
+	var a ble.Advertisement
+	for i := 0; i < 2; i++ {
+		select {
+		case a = <-found:
+		case <-ctx.Done():
+			t.Fatal("advertisement not received by both clients")
+		}
+	}
+	stopScan()
+	for i := 0; i < 2; i++ {
+		if err := <-scanned; err != context.Canceled {
+			t.Fatalf("scan returned %v, want context.Canceled", err)
+		}
+	}
+	if a.RSSI() != mock.DefaultRSSI || !a.Connectable() || len(a.Services()) != 1 || !a.Services()[0].Equal(testSvcUUID) {
+		t.Fatalf("unexpected advertisement: rssi %d, connectable %v, services %v", a.RSSI(), a.Connectable(), a.Services())
+	}
+
+	cln, err := ds[0].Dial(ctx, a.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cln.DiscoverProfile(true); err != nil {
+		t.Fatal(err)
+	}
+	rc := cln.Profile().FindCharacteristic(ble.NewCharacteristic(testReadUUID))
+	if rc == nil {
+		t.Fatal("readable characteristic not found")
+	}
+	if v, err := cln.ReadCharacteristic(rc); err != nil || !bytes.Equal(v, []byte("hello")) {
+		t.Fatalf("read %q, %v, want %q", v, err, "hello")
+	}
+	dc := cln.Profile().FindCharacteristic(ble.NewCharacteristic(testDenyUUID))
+	if _, err := cln.ReadCharacteristic(dc); !errors.Is(err, ble.ErrReadNotPerm) {
+		t.Fatalf("read returned %v, want ErrReadNotPerm", err)
+	}
+
+	nc := cln.Profile().FindCharacteristic(ble.NewCharacteristic(testNotifyUUID))
+	got := make(chan []byte, 1)
+	if err := cln.Subscribe(nc, false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-got:
+		if !bytes.Equal(b, []byte("tick")) {
+			t.Fatalf("notified %q, want %q", b, "tick")
+		}
+	case <-ctx.Done():
+		t.Fatal("notification not received")
+	}
+
+	// The connections of a client end with it.
+	ds[0].Stop()
+	select {
+	case <-cln.Disconnected():
+	case <-ctx.Done():
+		t.Fatal("client not disconnected")
+	}
+	if _, err := cln.ReadCharacteristic(rc); !errors.Is(err, ble.ErrDisconnected) {
+		t.Fatalf("read returned %v, want ErrDisconnected", err)
+	}
+	if err := ds[1].AddService(svc); err != ble.ErrNotImplemented {
+		t.Fatalf("AddService returned %v, want ErrNotImplemented", err)
+	}
+}
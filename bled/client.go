@@ -0,0 +1,530 @@
+package bled
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// Device is a ble.Device served by the daemon.
+type Device struct {
+	rc  *rpc.Client
+	ids uint64
+}
+
+// Dial connects to the daemon listening on the socket path, such as
+// DefaultSocket.
+func Dial(path string) (*Device, error) {
+	rc, err := rpc.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return &Device{rc: rc}, nil
+}
+
+// NewDevice returns the Device served by the daemon on c.
+func NewDevice(c io.ReadWriteCloser) *Device {
+	return &Device{rc: rpc.NewClient(c)}
+}
+
+// replyErr is a reply, which carries the error of the device.
+type replyErr interface {
+	remoteErr() error
+}
+
+func (r *Reply) remoteErr() error     { return r.Err.err() }
+func (r *ConnReply) remoteErr() error { return r.Err.err() }
+func (r *Info) remoteErr() error      { return r.Err.err() }
+func (r *GATTReply) remoteErr() error { return r.Err.err() }
+
+// nextID returns the ID of a call, which Cancel cancels.
+func (d *Device) nextID() uint64 {
+	return atomic.AddUint64(&d.ids, 1)
+}
+
+// call calls the method, and cancels the call id, once ctx is done. It
+// returns the error of the device in the reply, if any.
+func (d *Device) call(ctx context.Context, method string, id uint64, args, reply interface{}) error {
+	c := d.rc.Go(serviceName+"."+method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-c.Done:
+	case <-ctx.Done():
+		d.cancel(id)
+		<-c.Done
+	}
+	if c.Error != nil {
+		return fmt.Errorf("bled: %s: %w", c.Error, ble.ErrDisconnected)
+	}
+	if r, ok := reply.(replyErr); ok {
+		return r.remoteErr()
+	}
+	return nil
+}
+
+func (d *Device) cancel(id uint64) {
+	d.rc.Call(serviceName+".Cancel", id, &Reply{})
+}
+
+// AddService isn't supported, as the GATT server is left to the daemon.
+func (d *Device) AddService(svc *ble.Service) error {
+	return ble.ErrNotImplemented
+}
+
+// RemoveAllServices isn't supported, as the GATT server is left to the daemon.
+func (d *Device) RemoveAllServices() error {
+	return ble.ErrNotImplemented
+}
+
+// SetServices isn't supported, as the GATT server is left to the daemon.
+func (d *Device) SetServices(svcs []*ble.Service) error {
+	return ble.ErrNotImplemented
+}
+
+// GATTDump isn't supported, as the GATT server is left to the daemon.
+func (d *Device) GATTDump() ([]ble.Attribute, error) {
+	return nil, ble.ErrNotImplemented
+}
+
+// Stop disconnects from the daemon, which ends the scans, the advertising,
+// and the connections of the device.
+func (d *Device) Stop() error {
+	return d.rc.Close()
+}
+
+// Advertise isn't supported, as an Advertisement can't be passed to the
+// daemon.
+func (d *Device) Advertise(ctx context.Context, adv ble.Advertisement) error {
+	return ble.ErrNotImplemented
+}
+
+// AdvertiseNameAndServices advertises device name, and specified service UUIDs.
+func (d *Device) AdvertiseNameAndServices(ctx context.Context, name string, uuids ...ble.UUID) error {
+	return d.advertise(ctx, AdvArgs{Kind: advNameAndServices, Name: name, UUIDs: uuids})
+}
+
+// AdvertiseMfgData avertises the given manufacturer data.
+func (d *Device) AdvertiseMfgData(ctx context.Context, id uint16, b []byte) error {
+	return d.advertise(ctx, AdvArgs{Kind: advMfgData, Num: id, Data: b})
+}
+
+// AdvertiseServiceData16 advertises data associated with a 16bit service uuid
+func (d *Device) AdvertiseServiceData16(ctx context.Context, id uint16, b []byte) error {
+	return d.advertise(ctx, AdvArgs{Kind: advServiceData16, Num: id, Data: b})
+}
+
+// AdvertiseIBeaconData advertise iBeacon with given manufacturer data.
+func (d *Device) AdvertiseIBeaconData(ctx context.Context, b []byte) error {
+	return d.advertise(ctx, AdvArgs{Kind: advIBeaconData, Data: b})
+}
+
+// AdvertiseIBeacon advertises iBeacon with specified parameters.
+func (d *Device) AdvertiseIBeacon(ctx context.Context, u ble.UUID, major, minor uint16, pwr int8) error {
+	return d.advertise(ctx, AdvArgs{Kind: advIBeacon, UUIDs: []ble.UUID{u}, Major: major, Minor: minor, Power: pwr})
+}
+
+func (d *Device) advertise(ctx context.Context, a AdvArgs) error {
+	a.ID = d.nextID()
+	err := d.call(ctx, "Advertise", a.ID, a, &Reply{})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// Scan starts scanning. Duplicated advertisements will be filtered out if allowDup is set to false.
+func (d *Device) Scan(ctx context.Context, allowDup bool, h ble.AdvHandler) error {
+	id := d.nextID()
+	if err := d.call(ctx, "Scan", id, ScanArgs{ID: id, AllowDup: allowDup}, &Reply{}); err != nil {
+		return err
+	}
+	defer d.cancel(id)
+	for {
+		var r AdvsReply
+		if err := d.call(ctx, "NextAdvs", id, id, &r); err != nil {
+			return err
+		}
+		for _, a := range r.Advs {
+			h(&advertisement{a})
+		}
+		if r.Done {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return r.Err.err()
+		}
+	}
+}
+
+// Dial connects to the peer a, through the daemon, which passes it the
+// client timeout of ctx, if any.
+func (d *Device) Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
+	args := DialArgs{ID: d.nextID(), Addr: a.String(), AddrType: ble.AddrTypeOf(a)}
+	if t, ok := ble.ClientTimeoutFromContext(ctx); ok {
+		args.ClientTimeout = t
+	}
+	var r ConnReply
+	if err := d.call(ctx, "Dial", args.ID, args, &r); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	c := &client{
+		d:        d,
+		id:       r.Conn,
+		info:     r.Info,
+		handlers: make(map[uint16]ble.NotificationHandler),
+		done:     make(chan struct{}),
+	}
+	c.conn = &conn{c: c, ctx: context.Background()}
+	go c.loop()
+	return c, nil
+}
+
+// DisconnectAll terminates the connections of the device, and waits until
+// they are disconnected, or ctx is done. Those of the other clients of the
+// daemon are left alone.
+func (d *Device) DisconnectAll(ctx context.Context) error {
+	id := d.nextID()
+	return d.call(ctx, "DisconnectAll", id, id, &Reply{})
+}
+
+// Reset terminates the connections of the device, and stops its
+// advertising and scanning. The adapter is shared with the other clients of
+// the daemon, so it isn't reset.
+func (d *Device) Reset(ctx context.Context) error {
+	id := d.nextID()
+	return d.call(ctx, "Reset", id, id, &Reply{})
+}
+
+// client is a ble.Client of a connection of the daemon.
+type client struct {
+	d    *Device
+	id   uint64
+	info Info
+	conn *conn
+
+	mu       sync.Mutex
+	profile  *ble.Profile
+	handlers map[uint16]ble.NotificationHandler
+	done     chan struct{}
+}
+
+// loop passes the notifications to their handlers, until the connection is
+// gone.
+func (c *client) loop() {
+	defer close(c.done)
+	for {
+		var r EventsReply
+		if err := c.d.rc.Call(serviceName+".Events", c.id, &r); err != nil {
+			return
+		}
+		for _, n := range r.Notifications {
+			c.mu.Lock()
+			h := c.handlers[n.ValueHandle]
+			c.mu.Unlock()
+			if h != nil {
+				h(n.Value)
+			}
+		}
+		if r.Disconnected {
+			return
+		}
+	}
+}
+
+// gatt calls the GATT method of the connection with a.
+func (c *client) gatt(method string, a GATTArgs) (*GATTReply, error) {
+	a.Conn = c.id
+	var r GATTReply
+	if err := c.d.call(context.Background(), method, 0, a, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// state returns the current state of the connection, or the last one known,
+// if the daemon is gone.
+func (c *client) state() Info {
+	var r Info
+	if err := c.d.call(context.Background(), "Info", 0, c.id, &r); err != nil {
+		return c.info
+	}
+	return r
+}
+
+func (c *client) Addr() ble.Addr { return toAddr(c.info.RemoteAddr, c.info.RemoteAddrType) }
+func (c *client) Name() string   { return c.state().Name }
+
+func (c *client) Profile() *ble.Profile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.profile
+}
+
+func (c *client) DiscoverProfile(force bool) (*ble.Profile, error) {
+	r, err := c.gatt("DiscoverProfile", GATTArgs{Force: force})
+	if err != nil {
+		return nil, err
+	}
+	p := &ble.Profile{Services: services(r.Services)}
+	c.mu.Lock()
+	c.profile = p
+	c.mu.Unlock()
+	return p, nil
+}
+
+func (c *client) DiscoverServices(filter []ble.UUID) ([]*ble.Service, error) {
+	r, err := c.gatt("DiscoverServices", GATTArgs{Filter: filter})
+	if err != nil {
+		return nil, err
+	}
+	svcs := services(r.Services)
+	c.mu.Lock()
+	c.profile = &ble.Profile{Services: svcs}
+	c.mu.Unlock()
+	return svcs, nil
+}
+
+func (c *client) DiscoverIncludedServices(filter []ble.UUID, s *ble.Service) ([]*ble.Service, error) {
+	r, err := c.gatt("DiscoverIncludedServices", GATTArgs{Filter: filter, Service: serviceRange(s)})
+	if err != nil {
+		return nil, err
+	}
+	return services(r.Services), nil
+}
+
+func (c *client) DiscoverCharacteristics(filter []ble.UUID, s *ble.Service) ([]*ble.Characteristic, error) {
+	r, err := c.gatt("DiscoverCharacteristics", GATTArgs{Filter: filter, Service: serviceRange(s)})
+	if err != nil {
+		return nil, err
+	}
+	var cs []*ble.Characteristic
+	for _, w := range r.Characteristics {
+		cs = append(cs, w.characteristic())
+	}
+	s.Characteristics = cs
+	return cs, nil
+}
+
+func (c *client) DiscoverDescriptors(filter []ble.UUID, ch *ble.Characteristic) ([]*ble.Descriptor, error) {
+	r, err := c.gatt("DiscoverDescriptors", GATTArgs{Filter: filter, Char: toCharacteristic(ch)})
+	if err != nil {
+		return nil, err
+	}
+	var ds []*ble.Descriptor
+	for _, w := range r.Descriptors {
+		d := w.descriptor()
+		ds = append(ds, d)
+		if d.UUID.Equal(ble.ClientCharacteristicConfigUUID) {
+			ch.CCCD = d
+		}
+	}
+	ch.Descriptors = ds
+	return ds, nil
+}
+
+func (c *client) Characteristics(u ble.UUID) []*ble.Characteristic {
+	if p := c.Profile(); p != nil {
+		return p.FindCharacteristics(u)
+	}
+	return nil
+}
+
+func (c *client) ReadCharacteristic(ch *ble.Characteristic) ([]byte, error) {
+	r, err := c.gatt("ReadCharacteristic", GATTArgs{Char: toCharacteristic(ch)})
+	if err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (c *client) ReadCharacteristicByUUID(svc, chr ble.UUID) ([]byte, error) {
+	r, err := c.gatt("ReadCharacteristicByUUID", GATTArgs{Service: Service{UUID: svc}, Char: Characteristic{UUID: chr}})
+	if err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (c *client) ReadLongCharacteristic(ch *ble.Characteristic) ([]byte, error) {
+	r, err := c.gatt("ReadCharacteristic", GATTArgs{Char: toCharacteristic(ch), Long: true})
+	if err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (c *client) WriteCharacteristic(ch *ble.Characteristic, value []byte, noRsp bool) error {
+	_, err := c.gatt("WriteCharacteristic", GATTArgs{Char: toCharacteristic(ch), Value: value, NoRsp: noRsp})
+	return err
+}
+
+func (c *client) WriteLongCharacteristic(ch *ble.Characteristic, value []byte, reliable bool) error {
+	_, err := c.gatt("WriteCharacteristic", GATTArgs{Char: toCharacteristic(ch), Value: value, Long: true, Reliable: reliable})
+	return err
+}
+
+func (c *client) RawATT(ctx context.Context, pdu []byte) ([]byte, error) {
+	a := GATTArgs{ID: c.d.nextID(), Conn: c.id, Value: pdu}
+	var r GATTReply
+	if err := c.d.call(ctx, "RawATT", a.ID, a, &r); err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (c *client) Flush(ctx context.Context) error {
+	a := GATTArgs{ID: c.d.nextID(), Conn: c.id}
+	var r GATTReply
+	return c.d.call(ctx, "Flush", a.ID, a, &r)
+}
+
+func (c *client) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
+	r, err := c.gatt("ReadDescriptor", GATTArgs{Desc: toDescriptor(d)})
+	if err != nil {
+		return nil, err
+	}
+	return r.Value, nil
+}
+
+func (c *client) WriteDescriptor(d *ble.Descriptor, v []byte) error {
+	_, err := c.gatt("WriteDescriptor", GATTArgs{Desc: toDescriptor(d), Value: v})
+	return err
+}
+
+func (c *client) ReadRSSI() int                    { return c.state().RSSI }
+func (c *client) Security() ble.Security           { return c.state().Security }
+func (c *client) Encrypted() bool                  { return c.state().Security.Encrypted() }
+func (c *client) SecurityLevel() ble.SecurityLevel { return c.state().Security.Level }
+func (c *client) RemoteInfo() ble.RemoteInfo       { return c.state().RemoteInfo }
+func (c *client) Disconnected() <-chan struct{}    { return c.done }
+func (c *client) Conn() ble.Conn                   { return c.conn }
+
+func (c *client) ExchangeMTU(rxMTU int) (int, error) {
+	r, err := c.gatt("ExchangeMTU", GATTArgs{MTU: rxMTU})
+	if err != nil {
+		return 0, err
+	}
+	return r.MTU, nil
+}
+
+func (c *client) Subscribe(ch *ble.Characteristic, ind bool, h ble.NotificationHandler) error {
+	// The handler is set first, for the notifications sent right away.
+	c.mu.Lock()
+	c.handlers[ch.ValueHandle] = h
+	c.mu.Unlock()
+	if _, err := c.gatt("Subscribe", GATTArgs{Char: toCharacteristic(ch), Ind: ind}); err != nil {
+		c.mu.Lock()
+		delete(c.handlers, ch.ValueHandle)
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (c *client) Unsubscribe(ch *ble.Characteristic, ind bool) error {
+	if _, err := c.gatt("Unsubscribe", GATTArgs{Char: toCharacteristic(ch), Ind: ind}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.handlers, ch.ValueHandle)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *client) ClearSubscriptions() error {
+	if _, err := c.gatt("ClearSubscriptions", GATTArgs{}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.handlers = make(map[uint16]ble.NotificationHandler)
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *client) CancelConnection() error {
+	_, err := c.gatt("CancelConnection", GATTArgs{})
+	return err
+}
+
+// services returns the ble.Services of ws.
+func services(ws []Service) []*ble.Service {
+	var ss []*ble.Service
+	for _, w := range ws {
+		ss = append(ss, w.service())
+	}
+	return ss
+}
+
+// serviceRange returns the wire form of s, without its characteristics.
+func serviceRange(s *ble.Service) Service {
+	return Service{UUID: s.UUID, Handle: s.Handle, EndHandle: s.EndHandle}
+}
+
+// conn is the ble.Conn of a client. The L2CAP channel is kept by the
+// daemon, so it can't be read or written, and the MTUs are set on the
+// daemon's side, by ExchangeMTU.
+type conn struct {
+	c *client
+
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+func (c *conn) Read(b []byte) (int, error)  { return 0, ble.ErrNotImplemented }
+func (c *conn) Write(b []byte) (int, error) { return 0, ble.ErrNotImplemented }
+func (c *conn) Close() error                { return c.c.CancelConnection() }
+
+func (c *conn) Context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+func (c *conn) SetContext(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ctx = ctx
+}
+
+func (c *conn) LocalAddr() ble.Addr           { return ble.NewAddr(c.c.info.LocalAddr) }
+func (c *conn) RemoteAddr() ble.Addr          { return toAddr(c.c.info.RemoteAddr, c.c.info.RemoteAddrType) }
+func (c *conn) RxMTU() int                    { return c.c.state().RxMTU }
+func (c *conn) SetRxMTU(mtu int)              {}
+func (c *conn) TxMTU() int                    { return c.c.state().TxMTU }
+func (c *conn) SetTxMTU(mtu int)              {}
+func (c *conn) Disconnected() <-chan struct{} { return c.c.done }
+
+// advertisement is an Advertisement received by the daemon.
+type advertisement struct{ w Advertisement }
+
+func (a *advertisement) LocalName() string              { return a.w.LocalName }
+func (a *advertisement) ManufacturerData() []byte       { return a.w.ManufacturerData }
+func (a *advertisement) ServiceData() []ble.ServiceData { return a.w.ServiceData }
+func (a *advertisement) Services() []ble.UUID           { return a.w.Services }
+func (a *advertisement) OverflowService() []ble.UUID    { return a.w.OverflowService }
+func (a *advertisement) TxPowerLevel() int              { return a.w.TxPowerLevel }
+func (a *advertisement) Connectable() bool              { return a.w.Connectable }
+func (a *advertisement) SolicitedService() []ble.UUID   { return a.w.SolicitedService }
+func (a *advertisement) URI() string                    { return a.w.URI }
+func (a *advertisement) Appearance() uint16             { return a.w.Appearance }
+func (a *advertisement) AdvInterval() time.Duration     { return a.w.AdvInterval }
+func (a *advertisement) LERole() ble.LERole             { return a.w.LERole }
+func (a *advertisement) Records() []ble.ADStructure     { return a.w.Records }
+func (a *advertisement) RSSI() int                      { return a.w.RSSI }
+func (a *advertisement) Addr() ble.Addr                 { return toAddr(a.w.Addr, a.w.AddrType) }
+
+func (a *advertisement) PublicTargetAddrs() []ble.Addr {
+	var addrs []ble.Addr
+	for _, s := range a.w.PublicTargetAddrs {
+		addrs = append(addrs, ble.NewAddr(s))
+	}
+	return addrs
+}
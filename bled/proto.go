@@ -0,0 +1,334 @@
+package bled
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// The types below are the arguments and the replies of the methods the
+// daemon serves, as encoded by net/rpc. The errors of the device are passed
+// in the replies, rather than as the errors of the calls, which net/rpc
+// passes as strings only.
+
+// Error is an error of the device, as passed to the client.
+type Error struct {
+	Msg  string
+	ATT  *ble.ErrATT // The error is an Error Response of the peer.
+	HCI  *ble.ErrHCI // The error is the status of an HCI command.
+	Sent []int       // Indexes of the sentinels the error tells as.
+}
+
+// sentinels are the errors, which the errors of the device keep telling as
+// errors.Is, across the socket.
+var sentinels = []error{
+	ble.ErrDisconnected,
+	ble.ErrAdapterRemoved,
+	ble.ErrPermissionDenied,
+	ble.ErrNotImplemented,
+	context.Canceled,
+	context.DeadlineExceeded,
+}
+
+// newError returns the Error of err, or nil if err is nil.
+func newError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{Msg: err.Error()}
+	var att *ble.ErrATT
+	if errors.As(err, &att) {
+		e.ATT = att
+	} else if code, ok := err.(ble.ATTError); ok {
+		e.ATT = &ble.ErrATT{ErrCode: code}
+	}
+	var hci *ble.ErrHCI
+	if errors.As(err, &hci) {
+		e.HCI = hci
+	}
+	for i, s := range sentinels {
+		if errors.Is(err, s) {
+			e.Sent = append(e.Sent, i)
+		}
+	}
+	return e
+}
+
+// err returns the error of e, or nil if e is nil.
+func (e *Error) err() error {
+	switch {
+	case e == nil:
+		return nil
+	case e.ATT != nil:
+		return e.ATT
+	case e.HCI != nil:
+		return e.HCI
+	case len(e.Sent) == 1 && e.Msg == sentinels[e.Sent[0]].Error():
+		return sentinels[e.Sent[0]]
+	}
+	return &remoteError{e}
+}
+
+// remoteError is an Error of the device, which tells as its sentinels.
+type remoteError struct{ e *Error }
+
+func (e *remoteError) Error() string { return e.e.Msg }
+
+func (e *remoteError) Is(target error) bool {
+	for _, i := range e.e.Sent {
+		if i < len(sentinels) && sentinels[i] == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Reply is the reply of the methods, which return no value.
+type Reply struct {
+	Err *Error
+}
+
+// ScanArgs starts the scan ID of a client.
+type ScanArgs struct {
+	ID       uint64
+	AllowDup bool
+}
+
+// AdvsReply is the advertisements received by a scan since the last call.
+// Done is set once the scan is canceled, or fails with Err.
+type AdvsReply struct {
+	Advs []Advertisement
+	Done bool
+	Err  *Error
+}
+
+// Advertisement is a received ble.Advertisement.
+type Advertisement struct {
+	Addr              string
+	AddrType          ble.AddrType
+	RSSI              int
+	Connectable       bool
+	LocalName         string
+	ManufacturerData  []byte
+	ServiceData       []ble.ServiceData
+	Services          []ble.UUID
+	OverflowService   []ble.UUID
+	SolicitedService  []ble.UUID
+	TxPowerLevel      int
+	URI               string
+	Appearance        uint16
+	AdvInterval       time.Duration
+	LERole            ble.LERole
+	PublicTargetAddrs []string
+	Records           []ble.ADStructure
+}
+
+// Kinds of the advertising of AdvArgs, as the methods of ble.Device.
+const (
+	advNameAndServices = iota
+	advMfgData
+	advServiceData16
+	advIBeaconData
+	advIBeacon
+)
+
+// AdvArgs advertises, as the ID of a client, until canceled.
+type AdvArgs struct {
+	ID    uint64
+	Kind  int
+	Name  string
+	UUIDs []ble.UUID
+	Num   uint16 // Company ID, or 16-bit service UUID.
+	Data  []byte
+	Major uint16
+	Minor uint16
+	Power int8
+}
+
+// DialArgs connects, as the ID of a client, to the peer Addr.
+type DialArgs struct {
+	ID            uint64
+	Addr          string
+	AddrType      ble.AddrType
+	ClientTimeout time.Duration // As set by ble.WithClientTimeout, if non-zero.
+}
+
+// ConnReply is the connection a Dial returns.
+type ConnReply struct {
+	Conn uint64
+	Info Info
+	Err  *Error
+}
+
+// Info is the state of a connection.
+type Info struct {
+	LocalAddr      string
+	RemoteAddr     string
+	RemoteAddrType ble.AddrType
+	Name           string
+	RSSI           int
+	RxMTU          int
+	TxMTU          int
+	Security       ble.Security
+	RemoteInfo     ble.RemoteInfo
+	Err            *Error
+}
+
+// EventsReply is the notifications received on a connection since the last
+// call. Disconnected is set once the connection is gone.
+type EventsReply struct {
+	Notifications []Notification
+	Disconnected  bool
+}
+
+// Notification is a notification, or an indication, of a characteristic
+// value.
+type Notification struct {
+	ValueHandle uint16
+	Value       []byte
+}
+
+// Service is the wire form of a discovered ble.Service.
+type Service struct {
+	UUID            ble.UUID
+	Handle          uint16
+	EndHandle       uint16
+	Characteristics []Characteristic
+}
+
+// Characteristic is the wire form of a discovered ble.Characteristic.
+type Characteristic struct {
+	UUID        ble.UUID
+	Property    ble.Property
+	Handle      uint16
+	ValueHandle uint16
+	EndHandle   uint16
+	CCCD        uint16 // Handle of the CCCD, or 0 if none.
+	Descriptors []Descriptor
+}
+
+// Descriptor is the wire form of a discovered ble.Descriptor.
+type Descriptor struct {
+	UUID   ble.UUID
+	Handle uint16
+}
+
+// GATTArgs is the request of a GATT operation on the connection Conn. The
+// attributes are those the client discovered.
+type GATTArgs struct {
+	ID       uint64 // Of RawATT, which is canceled as the call.
+	Conn     uint64
+	Force    bool
+	Filter   []ble.UUID
+	Service  Service
+	Char     Characteristic
+	Desc     Descriptor
+	Value    []byte
+	NoRsp    bool
+	Long     bool
+	Reliable bool
+	Ind      bool
+	MTU      int
+}
+
+// GATTReply is the reply of a GATT operation.
+type GATTReply struct {
+	Services        []Service
+	Characteristics []Characteristic
+	Descriptors     []Descriptor
+	Value           []byte
+	MTU             int
+	Err             *Error
+}
+
+func toService(s *ble.Service) Service {
+	w := Service{UUID: s.UUID, Handle: s.Handle, EndHandle: s.EndHandle}
+	for _, c := range s.Characteristics {
+		w.Characteristics = append(w.Characteristics, toCharacteristic(c))
+	}
+	return w
+}
+
+func toCharacteristic(c *ble.Characteristic) Characteristic {
+	w := Characteristic{UUID: c.UUID, Property: c.Property, Handle: c.Handle, ValueHandle: c.ValueHandle, EndHandle: c.EndHandle}
+	if c.CCCD != nil {
+		w.CCCD = c.CCCD.Handle
+	}
+	for _, d := range c.Descriptors {
+		w.Descriptors = append(w.Descriptors, toDescriptor(d))
+	}
+	return w
+}
+
+func toDescriptor(d *ble.Descriptor) Descriptor {
+	return Descriptor{UUID: d.UUID, Handle: d.Handle}
+}
+
+func (w Service) service() *ble.Service {
+	s := &ble.Service{UUID: w.UUID, Handle: w.Handle, EndHandle: w.EndHandle}
+	for _, c := range w.Characteristics {
+		s.Characteristics = append(s.Characteristics, c.characteristic())
+	}
+	return s
+}
+
+func (w Characteristic) characteristic() *ble.Characteristic {
+	c := &ble.Characteristic{UUID: w.UUID, Property: w.Property, Handle: w.Handle, ValueHandle: w.ValueHandle, EndHandle: w.EndHandle}
+	for _, d := range w.Descriptors {
+		c.Descriptors = append(c.Descriptors, d.descriptor())
+		if d.Handle == w.CCCD {
+			c.CCCD = c.Descriptors[len(c.Descriptors)-1]
+		}
+	}
+	if c.CCCD == nil && w.CCCD != 0 {
+		c.CCCD = &ble.Descriptor{UUID: ble.ClientCharacteristicConfigUUID, Handle: w.CCCD}
+	}
+	return c
+}
+
+func (w Descriptor) descriptor() *ble.Descriptor {
+	return &ble.Descriptor{UUID: w.UUID, Handle: w.Handle}
+}
+
+func toServices(ss []*ble.Service) []Service {
+	var ws []Service
+	for _, s := range ss {
+		ws = append(ws, toService(s))
+	}
+	return ws
+}
+
+// toAddr returns the address s of type t, if told.
+func toAddr(s string, t ble.AddrType) ble.Addr {
+	if t == ble.AddrTypeUnknown {
+		return ble.NewAddr(s)
+	}
+	return ble.NewTypedAddr(s, t)
+}
+
+func toAdvertisement(a ble.Advertisement) Advertisement {
+	w := Advertisement{
+		Addr:             a.Addr().String(),
+		AddrType:         ble.AddrTypeOf(a.Addr()),
+		RSSI:             a.RSSI(),
+		Connectable:      a.Connectable(),
+		LocalName:        a.LocalName(),
+		ManufacturerData: a.ManufacturerData(),
+		ServiceData:      a.ServiceData(),
+		Services:         a.Services(),
+		OverflowService:  a.OverflowService(),
+		SolicitedService: a.SolicitedService(),
+		TxPowerLevel:     a.TxPowerLevel(),
+		URI:              a.URI(),
+		Appearance:       a.Appearance(),
+		AdvInterval:      a.AdvInterval(),
+		LERole:           a.LERole(),
+		Records:          a.Records(),
+	}
+	for _, t := range a.PublicTargetAddrs() {
+		w.PublicTargetAddrs = append(w.PublicTargetAddrs, t.String())
+	}
+	return w
+}
@@ -0,0 +1,715 @@
+// Package bled shares a ble.Device between the processes of a machine. The
+// daemon, cmd/bled, owns the adapter, and serves it over a local UNIX
+// socket, with net/rpc, and Dial returns a Device, which implements
+// ble.Device as a client of the daemon.
+//
+// The clients scan, advertise, and connect as centrals, with the GATT
+// clients of their connections. The scans of the clients share a single
+// scan of the adapter. Serving GATT services is left to the daemon, as the
+// requests of the peers can't wait on another process, so AddService and
+// the like return ble.ErrNotImplemented.
+package bled
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/rpc"
+	"strings"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// DefaultSocket is the path of the socket the daemon listens on, unless
+// told otherwise.
+const DefaultSocket = "/run/bled.sock"
+
+// serviceName is the name the methods of the daemon are served as.
+const serviceName = "Device"
+
+// Bounds of the queues of a client, which drop the oldest entries once full,
+// if the client doesn't keep up.
+const (
+	maxQueuedAdvs   = 256
+	maxQueuedNotifs = 1024
+	maxSeenAdvs     = 1024
+)
+
+// A Server serves a ble.Device to the clients of the daemon.
+type Server struct {
+	d ble.Device
+
+	mu       sync.Mutex
+	scanners map[*scanner]bool
+	stopScan context.CancelFunc
+	scanDone chan struct{}
+
+	// seen is the last advertisement of each address scanned, for Dial to
+	// tell the type of the address, which the clients don't pass.
+	seen map[string]ble.Advertisement
+}
+
+// NewServer returns a Server of the device d.
+func NewServer(d ble.Device) *Server {
+	return &Server{
+		d:        d,
+		scanners: make(map[*scanner]bool),
+		seen:     make(map[string]ble.Advertisement),
+	}
+}
+
+// Serve serves the clients, which connect to l, until l is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(c)
+	}
+}
+
+// ServeConn serves the client of c, until it disconnects. The scans, the
+// advertising, and the connections of the client end with it.
+func (s *Server) ServeConn(c io.ReadWriteCloser) {
+	ss := newSession(s)
+	rs := rpc.NewServer()
+	if err := rs.RegisterName(serviceName, ss); err != nil {
+		c.Close()
+		return
+	}
+	rs.ServeConn(&sessionConn{ReadWriteCloser: c, ss: ss})
+}
+
+// addScanner passes the advertisements scanned to sc, and starts scanning,
+// unless another client is already.
+func (s *Server) addScanner(sc *scanner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanners[sc] = true
+	if s.stopScan != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	prev, done := s.scanDone, make(chan struct{})
+	s.stopScan, s.scanDone = cancel, done
+	go func() {
+		defer close(done)
+		if prev != nil {
+			<-prev
+		}
+		// The duplicates are filtered by each scanner, as the clients
+		// ask.
+		err := s.d.Scan(ctx, true, s.handleAdv)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+		cancel()
+		s.stopScan = nil
+		for sc := range s.scanners {
+			sc.finish(err)
+			delete(s.scanners, sc)
+		}
+	}()
+}
+
+// removeScanner stops passing the advertisements to sc, and stops scanning,
+// once no client is.
+func (s *Server) removeScanner(sc *scanner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.scanners, sc)
+	if len(s.scanners) == 0 && s.stopScan != nil {
+		s.stopScan()
+		s.stopScan = nil
+	}
+}
+
+func (s *Server) handleAdv(a ble.Advertisement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.seen) >= maxSeenAdvs {
+		s.seen = make(map[string]ble.Advertisement)
+	}
+	s.seen[key(a.Addr())] = a
+	for sc := range s.scanners {
+		sc.push(a)
+	}
+}
+
+// advertisement returns the last advertisement scanned of a, if any.
+func (s *Server) advertisement(a ble.Addr) (ble.Advertisement, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	adv, ok := s.seen[key(a)]
+	return adv, ok
+}
+
+func key(a ble.Addr) string {
+	return strings.ToLower(a.String())
+}
+
+// sessionConn ends its session, once the client disconnects.
+type sessionConn struct {
+	io.ReadWriteCloser
+	ss *session
+}
+
+func (c *sessionConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	if err != nil {
+		c.ss.close()
+	}
+	return n, err
+}
+
+// scanner is a scan of a client.
+type scanner struct {
+	allowDup bool
+
+	mu    sync.Mutex
+	advs  []ble.Advertisement
+	seen  map[string]bool
+	done  bool
+	err   error
+	ready chan struct{}
+}
+
+func newScanner(allowDup bool) *scanner {
+	return &scanner{allowDup: allowDup, seen: make(map[string]bool), ready: make(chan struct{}, 1)}
+}
+
+func (sc *scanner) push(a ble.Advertisement) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	k := key(a.Addr())
+	if sc.done || !sc.allowDup && sc.seen[k] {
+		return
+	}
+	sc.seen[k] = true
+	if len(sc.advs) == maxQueuedAdvs {
+		sc.advs = sc.advs[1:]
+	}
+	sc.advs = append(sc.advs, a)
+	sc.signal()
+}
+
+// finish ends the scan with err, or nil if canceled.
+func (sc *scanner) finish(err error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if !sc.done {
+		sc.done, sc.err = true, err
+		sc.signal()
+	}
+}
+
+func (sc *scanner) signal() {
+	select {
+	case sc.ready <- struct{}{}:
+	default:
+	}
+}
+
+// sconn is a connection of a client.
+type sconn struct {
+	cln ble.Client
+
+	mu     sync.Mutex
+	notifs []Notification
+	ready  chan struct{}
+}
+
+func (c *sconn) handler(h uint16) ble.NotificationHandler {
+	return func(b []byte) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.notifs) == maxQueuedNotifs {
+			c.notifs = c.notifs[1:]
+		}
+		c.notifs = append(c.notifs, Notification{ValueHandle: h, Value: append([]byte(nil), b...)})
+		select {
+		case c.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// session is the state of a client, whose methods are served over its
+// connection to the daemon.
+type session struct {
+	s      *Server
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+
+	mu       sync.Mutex
+	cancels  map[uint64]context.CancelFunc
+	canceled map[uint64]bool // Canceled before they started.
+	scans    map[uint64]*scanner
+	conns    map[uint64]*sconn
+	nextConn uint64
+}
+
+func newSession(s *Server) *session {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &session{
+		s:        s,
+		ctx:      ctx,
+		cancel:   cancel,
+		cancels:  make(map[uint64]context.CancelFunc),
+		canceled: make(map[uint64]bool),
+		scans:    make(map[uint64]*scanner),
+		conns:    make(map[uint64]*sconn),
+	}
+}
+
+// close ends everything the client started.
+func (ss *session) close() {
+	ss.once.Do(func() {
+		ss.cancel()
+		ss.mu.Lock()
+		scans, conns := ss.scans, ss.conns
+		ss.scans, ss.conns = make(map[uint64]*scanner), make(map[uint64]*sconn)
+		ss.mu.Unlock()
+		for _, sc := range scans {
+			ss.s.removeScanner(sc)
+		}
+		for _, c := range conns {
+			c.cln.CancelConnection()
+		}
+	})
+}
+
+// newCtx returns the context of the call id, which Cancel cancels, and the
+// func to call once it's done.
+func (ss *session) newCtx(id uint64) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ss.ctx)
+	ss.mu.Lock()
+	ss.cancels[id] = cancel
+	if ss.canceled[id] {
+		delete(ss.canceled, id)
+		cancel()
+	}
+	ss.mu.Unlock()
+	return ctx, func() {
+		ss.mu.Lock()
+		delete(ss.cancels, id)
+		ss.mu.Unlock()
+		cancel()
+	}
+}
+
+func (ss *session) conn(id uint64) (*sconn, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	c, ok := ss.conns[id]
+	if !ok {
+		return nil, ble.ErrDisconnected
+	}
+	return c, nil
+}
+
+// Cancel cancels the call id, or ends the scan id.
+func (ss *session) Cancel(id uint64, r *Reply) error {
+	ss.mu.Lock()
+	cancel := ss.cancels[id]
+	sc := ss.scans[id]
+	delete(ss.scans, id)
+	if cancel == nil && sc == nil {
+		// The calls are served concurrently, so the call may be yet to
+		// start.
+		ss.canceled[id] = true
+	}
+	ss.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	if sc != nil {
+		ss.s.removeScanner(sc)
+		sc.finish(nil)
+	}
+	return nil
+}
+
+// Scan starts the scan a.ID, whose advertisements NextAdvs returns.
+func (ss *session) Scan(a ScanArgs, r *Reply) error {
+	sc := newScanner(a.AllowDup)
+	ss.mu.Lock()
+	if ss.ctx.Err() != nil {
+		ss.mu.Unlock()
+		return nil
+	}
+	ss.scans[a.ID] = sc
+	ss.mu.Unlock()
+	ss.s.addScanner(sc)
+	return nil
+}
+
+// NextAdvs waits for the advertisements of the scan id.
+func (ss *session) NextAdvs(id uint64, r *AdvsReply) error {
+	ss.mu.Lock()
+	sc := ss.scans[id]
+	ss.mu.Unlock()
+	if sc == nil {
+		r.Done = true
+		return nil
+	}
+	for {
+		sc.mu.Lock()
+		advs, done, err := sc.advs, sc.done, sc.err
+		sc.advs = nil
+		sc.mu.Unlock()
+		for _, a := range advs {
+			r.Advs = append(r.Advs, toAdvertisement(a))
+		}
+		if len(advs) != 0 || done {
+			r.Done, r.Err = done, newError(err)
+			return nil
+		}
+		select {
+		case <-sc.ready:
+		case <-ss.ctx.Done():
+			r.Done = true
+			return nil
+		}
+	}
+}
+
+// Advertise advertises until the call is canceled.
+func (ss *session) Advertise(a AdvArgs, r *Reply) error {
+	ctx, done := ss.newCtx(a.ID)
+	defer done()
+	var err error
+	switch a.Kind {
+	case advNameAndServices:
+		err = ss.s.d.AdvertiseNameAndServices(ctx, a.Name, a.UUIDs...)
+	case advMfgData:
+		err = ss.s.d.AdvertiseMfgData(ctx, a.Num, a.Data)
+	case advServiceData16:
+		err = ss.s.d.AdvertiseServiceData16(ctx, a.Num, a.Data)
+	case advIBeaconData:
+		err = ss.s.d.AdvertiseIBeaconData(ctx, a.Data)
+	case advIBeacon:
+		var u ble.UUID
+		if len(a.UUIDs) != 0 {
+			u = a.UUIDs[0]
+		}
+		err = ss.s.d.AdvertiseIBeacon(ctx, u, a.Major, a.Minor, a.Power)
+	default:
+		err = ble.ErrNotImplemented
+	}
+	r.Err = newError(err)
+	return nil
+}
+
+// Dial connects to the peer a.Addr.
+func (ss *session) Dial(a DialArgs, r *ConnReply) error {
+	ctx, done := ss.newCtx(a.ID)
+	defer done()
+	addr := toAddr(a.Addr, a.AddrType)
+	if adv, ok := ss.s.advertisement(addr); ok {
+		ctx = ble.WithAdvertisement(ctx, adv)
+	}
+	if a.ClientTimeout > 0 {
+		ctx = ble.WithClientTimeout(ctx, a.ClientTimeout)
+	}
+	cln, err := ss.s.d.Dial(ctx, addr)
+	if err != nil {
+		r.Err = newError(err)
+		return nil
+	}
+	c := &sconn{cln: cln, ready: make(chan struct{}, 1)}
+	ss.mu.Lock()
+	if ss.ctx.Err() != nil {
+		ss.mu.Unlock()
+		cln.CancelConnection()
+		r.Err = newError(ble.ErrDisconnected)
+		return nil
+	}
+	ss.nextConn++
+	r.Conn = ss.nextConn
+	ss.conns[r.Conn] = c
+	ss.mu.Unlock()
+	ss.info(c, &r.Info)
+	return nil
+}
+
+// DisconnectAll terminates the connections of the client, and waits until
+// they are disconnected, or the call id is canceled.
+func (ss *session) DisconnectAll(id uint64, r *Reply) error {
+	ctx, done := ss.newCtx(id)
+	defer done()
+	ss.mu.Lock()
+	var conns []*sconn
+	for _, c := range ss.conns {
+		conns = append(conns, c)
+	}
+	ss.mu.Unlock()
+	for _, c := range conns {
+		c.cln.CancelConnection()
+	}
+	for _, c := range conns {
+		select {
+		case <-c.cln.Disconnected():
+		case <-ctx.Done():
+			r.Err = newError(ctx.Err())
+			return nil
+		}
+	}
+	return nil
+}
+
+// Reset ends the scans and the advertising of the client, other than the
+// call id, and terminates its connections. The adapter is shared, so it
+// isn't reset.
+func (ss *session) Reset(id uint64, r *Reply) error {
+	ss.mu.Lock()
+	for i, cancel := range ss.cancels {
+		if i != id {
+			cancel()
+		}
+	}
+	var scans []uint64
+	for i := range ss.scans {
+		scans = append(scans, i)
+	}
+	ss.mu.Unlock()
+	for _, i := range scans {
+		ss.Cancel(i, &Reply{})
+	}
+	return ss.DisconnectAll(id, r)
+}
+
+// Events waits for the notifications received on the connection id, or its
+// disconnection.
+func (ss *session) Events(id uint64, r *EventsReply) error {
+	c, err := ss.conn(id)
+	if err != nil {
+		r.Disconnected = true
+		return nil
+	}
+	for {
+		c.mu.Lock()
+		r.Notifications, c.notifs = c.notifs, nil
+		c.mu.Unlock()
+		select {
+		case <-c.cln.Disconnected():
+			r.Disconnected = true
+			ss.mu.Lock()
+			delete(ss.conns, id)
+			ss.mu.Unlock()
+		default:
+		}
+		if len(r.Notifications) != 0 || r.Disconnected {
+			return nil
+		}
+		select {
+		case <-c.ready:
+		case <-c.cln.Disconnected():
+		case <-ss.ctx.Done():
+			r.Disconnected = true
+			return nil
+		}
+	}
+}
+
+// Info returns the state of the connection id.
+func (ss *session) Info(id uint64, r *Info) error {
+	c, err := ss.conn(id)
+	if err != nil {
+		r.Err = newError(err)
+		return nil
+	}
+	ss.info(c, r)
+	return nil
+}
+
+func (ss *session) info(c *sconn, r *Info) {
+	*r = Info{
+		RemoteAddr:     c.cln.Addr().String(),
+		RemoteAddrType: ble.AddrTypeOf(c.cln.Addr()),
+		Name:           c.cln.Name(),
+		RSSI:           c.cln.ReadRSSI(),
+		Security:       c.cln.Security(),
+		RemoteInfo:     c.cln.RemoteInfo(),
+	}
+	if cn := c.cln.Conn(); cn != nil {
+		r.LocalAddr = cn.LocalAddr().String()
+		r.RxMTU, r.TxMTU = cn.RxMTU(), cn.TxMTU()
+	}
+}
+
+// gatt runs f with the client of the connection a.Conn, and sets the error
+// of r.
+func (ss *session) gatt(a GATTArgs, r *GATTReply, f func(ble.Client) error) error {
+	c, err := ss.conn(a.Conn)
+	if err == nil {
+		err = f(c.cln)
+	}
+	r.Err = newError(err)
+	return nil
+}
+
+// DiscoverProfile discovers the whole hierarchy of the server.
+func (ss *session) DiscoverProfile(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		p, err := cln.DiscoverProfile(a.Force)
+		if err == nil {
+			r.Services = toServices(p.Services)
+		}
+		return err
+	})
+}
+
+// DiscoverServices finds the primary services of the server.
+func (ss *session) DiscoverServices(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		svcs, err := cln.DiscoverServices(a.Filter)
+		r.Services = toServices(svcs)
+		return err
+	})
+}
+
+// DiscoverIncludedServices finds the included services of a.Service.
+func (ss *session) DiscoverIncludedServices(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		svcs, err := cln.DiscoverIncludedServices(a.Filter, a.Service.service())
+		r.Services = toServices(svcs)
+		return err
+	})
+}
+
+// DiscoverCharacteristics finds the characteristics of a.Service.
+func (ss *session) DiscoverCharacteristics(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		cs, err := cln.DiscoverCharacteristics(a.Filter, a.Service.service())
+		for _, c := range cs {
+			r.Characteristics = append(r.Characteristics, toCharacteristic(c))
+		}
+		return err
+	})
+}
+
+// DiscoverDescriptors finds the descriptors of a.Char.
+func (ss *session) DiscoverDescriptors(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		ds, err := cln.DiscoverDescriptors(a.Filter, a.Char.characteristic())
+		for _, d := range ds {
+			r.Descriptors = append(r.Descriptors, toDescriptor(d))
+		}
+		return err
+	})
+}
+
+// ReadCharacteristic reads the value of a.Char, as a long value if a.Long.
+func (ss *session) ReadCharacteristic(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) (err error) {
+		if a.Long {
+			r.Value, err = cln.ReadLongCharacteristic(a.Char.characteristic())
+		} else {
+			r.Value, err = cln.ReadCharacteristic(a.Char.characteristic())
+		}
+		return err
+	})
+}
+
+// ReadCharacteristicByUUID reads the value of the characteristic a.Char.UUID,
+// within the service a.Service.UUID, if any.
+func (ss *session) ReadCharacteristicByUUID(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) (err error) {
+		r.Value, err = cln.ReadCharacteristicByUUID(a.Service.UUID, a.Char.UUID)
+		return err
+	})
+}
+
+// WriteCharacteristic writes a.Value to a.Char, as a long value if a.Long.
+func (ss *session) WriteCharacteristic(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		if a.Long {
+			return cln.WriteLongCharacteristic(a.Char.characteristic(), a.Value, a.Reliable)
+		}
+		return cln.WriteCharacteristic(a.Char.characteristic(), a.Value, a.NoRsp)
+	})
+}
+
+// ReadDescriptor reads the value of a.Desc.
+func (ss *session) ReadDescriptor(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) (err error) {
+		r.Value, err = cln.ReadDescriptor(a.Desc.descriptor())
+		return err
+	})
+}
+
+// WriteDescriptor writes a.Value to a.Desc.
+func (ss *session) WriteDescriptor(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		return cln.WriteDescriptor(a.Desc.descriptor(), a.Value)
+	})
+}
+
+// RawATT sends the PDU a.Value, until the call a.ID is canceled.
+func (ss *session) RawATT(a GATTArgs, r *GATTReply) error {
+	ctx, done := ss.newCtx(a.ID)
+	defer done()
+	return ss.gatt(a, r, func(cln ble.Client) (err error) {
+		r.Value, err = cln.RawATT(ctx, a.Value)
+		return err
+	})
+}
+
+// Flush waits until the writes without response queued have been sent, or
+// the call a.ID is canceled.
+func (ss *session) Flush(a GATTArgs, r *GATTReply) error {
+	ctx, done := ss.newCtx(a.ID)
+	defer done()
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		return cln.Flush(ctx)
+	})
+}
+
+// ExchangeMTU exchanges the ATT_MTU, offering a.MTU.
+func (ss *session) ExchangeMTU(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) (err error) {
+		r.MTU, err = cln.ExchangeMTU(a.MTU)
+		return err
+	})
+}
+
+// Subscribe subscribes to the indications, if a.Ind, or the notifications
+// of a.Char, which Events returns.
+func (ss *session) Subscribe(a GATTArgs, r *GATTReply) error {
+	c, err := ss.conn(a.Conn)
+	if err != nil {
+		r.Err = newError(err)
+		return nil
+	}
+	r.Err = newError(c.cln.Subscribe(a.Char.characteristic(), a.Ind, c.handler(a.Char.ValueHandle)))
+	return nil
+}
+
+// Unsubscribe unsubscribes to the indications, if a.Ind, or the
+// notifications of a.Char.
+func (ss *session) Unsubscribe(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		return cln.Unsubscribe(a.Char.characteristic(), a.Ind)
+	})
+}
+
+// ClearSubscriptions clears the subscriptions of the connection.
+func (ss *session) ClearSubscriptions(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		return cln.ClearSubscriptions()
+	})
+}
+
+// CancelConnection disconnects the connection.
+func (ss *session) CancelConnection(a GATTArgs, r *GATTReply) error {
+	return ss.gatt(a, r, func(cln ble.Client) error {
+		return cln.CancelConnection()
+	})
+}
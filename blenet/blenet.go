@@ -0,0 +1,143 @@
+// Package blenet serves a ble.Device to the applications of other machines,
+// so that the scans and the connections run on a machine near the
+// peripherals, such as a Raspberry Pi, while the application runs elsewhere.
+//
+// An Agent serves the device over TCP, or over WebSocket for the networks
+// which pass HTTP only. Dial and DialWebSocket return a Device, which
+// implements ble.Device as a client of the agent. The protocol is that of
+// bled: the advertisements scanned, and the notifications of the
+// connections, are streamed to the clients, and the GATT servers are left
+// to the agent.
+//
+// The agents, and the clients, share a token, which the clients pass on
+// connecting. The connections aren't encrypted, unless they run over TLS, as
+// with the wss URLs.
+package blenet
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/bled"
+)
+
+// DefaultPort is the TCP port the agents listen on, unless told otherwise.
+const DefaultPort = 7435
+
+// ErrDenied is returned by Dial and DialWebSocket, if the agent refuses the
+// token.
+var ErrDenied = errors.New("blenet: token denied")
+
+// A Device is a ble.Device served by an agent.
+type Device = bled.Device
+
+// handshakeTimeout bounds the time a client takes to pass its token.
+const handshakeTimeout = 10 * time.Second
+
+// Lines of the handshake of the TCP connections, which precedes the RPCs.
+const (
+	helloPrefix = "blenet/1 "
+	helloOK     = "ok"
+	helloDenied = "denied"
+)
+
+// maxLine bounds the lines of the handshake.
+const maxLine = 512
+
+// An Agent serves a ble.Device to the clients, which pass its token.
+type Agent struct {
+	s     *bled.Server
+	token string
+}
+
+// NewAgent returns an Agent of the device d, for the clients passing token.
+func NewAgent(d ble.Device, token string) *Agent {
+	return &Agent{s: bled.NewServer(d), token: token}
+}
+
+// Serve serves the clients, which connect to l over TCP, until l is closed.
+func (a *Agent) Serve(l net.Listener) error {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go a.serveTCP(c)
+	}
+}
+
+func (a *Agent) serveTCP(c net.Conn) {
+	c.SetDeadline(time.Now().Add(handshakeTimeout))
+	l, err := readLine(c)
+	if err != nil || len(l) < len(helloPrefix) || l[:len(helloPrefix)] != helloPrefix {
+		c.Close()
+		return
+	}
+	if !a.authorized(l[len(helloPrefix):]) {
+		io.WriteString(c, helloDenied+"\n")
+		c.Close()
+		return
+	}
+	if _, err := io.WriteString(c, helloOK+"\n"); err != nil {
+		c.Close()
+		return
+	}
+	c.SetDeadline(time.Time{})
+	a.s.ServeConn(c)
+}
+
+func (a *Agent) authorized(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+// Dial connects to the agent listening on the TCP address addr, such as
+// "raspberrypi.local:7435", with token.
+func Dial(addr, token string) (*Device, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c.SetDeadline(time.Now().Add(handshakeTimeout))
+	if _, err := io.WriteString(c, helloPrefix+token+"\n"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	l, err := readLine(c)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	switch l {
+	case helloOK:
+	case helloDenied:
+		c.Close()
+		return nil, ErrDenied
+	default:
+		c.Close()
+		return nil, fmt.Errorf("blenet: unexpected handshake %q", l)
+	}
+	c.SetDeadline(time.Time{})
+	return bled.NewDevice(c), nil
+}
+
+// readLine reads a line of the handshake, a byte at a time, so that none of
+// the RPCs following it is read.
+func readLine(r io.Reader) (string, error) {
+	var b []byte
+	var c [1]byte
+	for len(b) < maxLine {
+		if _, err := io.ReadFull(r, c[:]); err != nil {
+			return "", err
+		}
+		if c[0] == '\n' {
+			return string(b), nil
+		}
+		b = append(b, c[0])
+	}
+	return "", errors.New("blenet: handshake line too long")
+}
@@ -0,0 +1,136 @@
+package blenet
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/mock"
+)
+
+var (
+	testSvcUUID    = ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+	testReadUUID   = ble.MustParse("5e0a0002-0000-1000-8000-00805f9b34fb")
+	testNotifyUUID = ble.MustParse("5e0a0003-0000-1000-8000-00805f9b34fb")
+)
+
+func TestAgent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Agent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	svc := ble.NewService(testSvcUUID)
+	svc.NewCharacteristic(testReadUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write([]byte("hello"))
+	}))
+	svc.NewCharacteristic(testNotifyUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		n.Write([]byte("tick"))
+		<-n.Context().Done()
+	}))
+	if err := p.AddService(svc); err != nil {
+		t.Fatal(err)
+	}
+	advCtx, stopAdv := context.WithCancel(ctx)
+	defer stopAdv()
+	go p.AdvertiseNameAndServices(advCtx, "Peripheral", testSvcUUID)
+
+	a := NewAgent(c, "secret")
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go a.Serve(l)
+	hs := httptest.NewServer(a)
+	defer hs.Close()
+	wsURL := "ws" + strings.TrimPrefix(hs.URL, "http") + "/ble"
+
+	if _, err := Dial(l.Addr().String(), "wrong"); err != ErrDenied {
+		t.Fatalf("Dial with a wrong token returned %v, want ErrDenied", err)
+	}
+	if _, err := DialWebSocket(wsURL, "wrong"); err != ErrDenied {
+		t.Fatalf("DialWebSocket with a wrong token returned %v, want ErrDenied", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		dial func() (*Device, error)
+	}{
+		{"tcp", func() (*Device, error) { return Dial(l.Addr().String(), "secret") }},
+		{"websocket", func() (*Device, error) { return DialWebSocket(wsURL, "secret") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := tc.dial()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer d.Stop()
+
+			found := make(chan ble.Advertisement, 1)
+			scanCtx, stopScan := context.WithCancel(ctx)
+			scanned := make(chan error, 1)
+			go func() {
+				scanned <- d.Scan(scanCtx, false, func(a ble.Advertisement) {
+					if a.LocalName() == "Peripheral" {
+						select {
+						case found <- a:
+						default:
+						}
+					}
+				})
+			}()
+			var adv ble.Advertisement
+			select {
+			case adv = <-found:
+			case <-ctx.Done():
+				t.Fatal("advertisement not received")
+			}
+			stopScan()
+			if err := <-scanned; err != context.Canceled {
+				t.Fatalf("scan returned %v, want context.Canceled", err)
+			}
+
+			cln, err := d.Dial(ctx, adv.Addr())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cln.CancelConnection()
+			if _, err := cln.DiscoverProfile(true); err != nil {
+				t.Fatal(err)
+			}
+			rc := cln.Profile().FindCharacteristic(ble.NewCharacteristic(testReadUUID))
+			if v, err := cln.ReadCharacteristic(rc); err != nil || !bytes.Equal(v, []byte("hello")) {
+				t.Fatalf("read %q, %v", v, err)
+			}
+			nc := cln.Profile().FindCharacteristic(ble.NewCharacteristic(testNotifyUUID))
+			got := make(chan []byte, 1)
+			if err := cln.Subscribe(nc, false, func(b []byte) { got <- b }); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case b := <-got:
+				if !bytes.Equal(b, []byte("tick")) {
+					t.Fatalf("notified %q, want %q", b, "tick")
+				}
+			case <-ctx.Done():
+				t.Fatal("notification not received")
+			}
+		})
+	}
+}
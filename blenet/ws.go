@@ -0,0 +1,41 @@
+package blenet
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/kirbo/ble/bled"
+	"github.com/kirbo/ble/internal/ws"
+)
+
+// The WebSocket connections carry the RPCs of bled as binary messages, the
+// boundaries of which are ignored.
+
+// ServeHTTP serves the client of the WebSocket request r, until it
+// disconnects. The client passes the token as a bearer token.
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.authorized(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")) {
+		http.Error(w, "token denied", http.StatusUnauthorized)
+		return
+	}
+	c, err := ws.Accept(w, r)
+	if err != nil {
+		return
+	}
+	a.s.ServeConn(c)
+}
+
+// DialWebSocket connects to the agent served at the ws, or wss, URL rawurl,
+// with token.
+func DialWebSocket(rawurl, token string) (*Device, error) {
+	c, err := ws.Dial(rawurl, http.Header{"Authorization": {"Bearer " + token}})
+	var he *ws.HandshakeError
+	if errors.As(err, &he) && he.StatusCode == http.StatusUnauthorized {
+		return nil, ErrDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bled.NewDevice(c), nil
+}
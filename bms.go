@@ -0,0 +1,130 @@
+package ble
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+)
+
+// BondManagementOp is an operation of the Bond Management Control Point, on
+// the LE bonds of the server. [BMS, 3.1.2]
+type BondManagementOp uint8
+
+// Bond management operations.
+const (
+	BondDeleteRequester BondManagementOp = 0x03 // Delete the bond of the client.
+	BondDeleteAll       BondManagementOp = 0x06 // Delete all the bonds.
+	BondDeleteOthers    BondManagementOp = 0x09 // Delete all the bonds, but the one of the client.
+)
+
+// Application errors of the Bond Management Service. [BMS, 1.6]
+const (
+	ErrBondOpNotSupported ATTError = 0x80 // The server doesn't support the operation.
+	ErrBondOpFailed       ATTError = 0x81 // The server failed to delete the bonds.
+)
+
+// ErrNoBondManagement is returned by DeleteBonds, if the server has no Bond
+// Management Service.
+var ErrNoBondManagement = errors.New("no bond management service")
+
+// featureBit returns the bit of the Bond Management Feature, which tells the
+// server supports op, with an authorization code if code is set. [BMS, 3.2]
+func (op BondManagementOp) featureBit(code bool) uint32 {
+	bit := map[BondManagementOp]uint{BondDeleteRequester: 4, BondDeleteAll: 10, BondDeleteOthers: 16}[op]
+	if code {
+		bit++
+	}
+	return 1 << bit
+}
+
+type bondManager struct {
+	s    BondStore
+	code string
+	ops  []BondManagementOp
+}
+
+// NewBondManagementService returns the Bond Management Service, through which
+// the clients delete the bonds of s, such as for the tools of a fleet to have
+// a device forget its peers. The writes of the control point require perm,
+// e.g. PermEncrypt|PermAuthorize to leave them to the Authorizer of the
+// server. If code isn't empty, the clients authorize each operation with it.
+// The deletion of all the bonds requires s to be a BondLister.
+func NewBondManagementService(s BondStore, code string, perm Permission) *Service {
+	m := &bondManager{s: s, code: code, ops: []BondManagementOp{BondDeleteRequester}}
+	if _, ok := s.(BondLister); ok {
+		m.ops = append(m.ops, BondDeleteAll, BondDeleteOthers)
+	}
+	var f uint32
+	for _, op := range m.ops {
+		f |= op.featureBit(code != "")
+	}
+
+	svc := NewService(BondManagementUUID)
+	svc.BuildCharacteristic(BondManagementControlPointUUID).
+		Properties(CharWrite).
+		Permissions(0, perm).
+		OnWrite(WriteHandlerFunc(func(req Request, rsp ResponseWriter) {
+			if err := m.serve(req); err != nil {
+				rsp.SetError(err)
+			}
+		}))
+	svc.BuildCharacteristic(BondManagementFeatureUUID).
+		Properties(CharRead).
+		Permissions(perm&^PermAuthorize, 0).
+		Value([]byte{byte(f), byte(f >> 8), byte(f >> 16)})
+	return svc
+}
+
+// serve performs the operation written to the control point.
+func (m *bondManager) serve(req Request) error {
+	b := req.Data()
+	if len(b) == 0 || !m.supports(BondManagementOp(b[0])) {
+		return ErrBondOpNotSupported
+	}
+	if m.code != "" && subtle.ConstantTimeCompare(b[1:], []byte(m.code)) != 1 {
+		return ErrAuthorization
+	}
+	op, peer := BondManagementOp(b[0]), req.Conn().RemoteAddr()
+	if op == BondDeleteRequester {
+		if m.s.Delete(peer) != nil {
+			return ErrBondOpFailed
+		}
+		return nil
+	}
+	peers, err := m.s.(BondLister).Peers()
+	if err != nil {
+		return ErrBondOpFailed
+	}
+	for _, p := range peers {
+		if op == BondDeleteOthers && strings.EqualFold(p.String(), peer.String()) {
+			continue
+		}
+		if m.s.Delete(p) != nil {
+			return ErrBondOpFailed
+		}
+	}
+	return nil
+}
+
+func (m *bondManager) supports(op BondManagementOp) bool {
+	for _, o := range m.ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteBonds has the Bond Management Service of the server perform op,
+// authorized by code, if the server requires one.
+func DeleteBonds(cln Client, op BondManagementOp, code string) error {
+	p, err := DiscoverPartialProfile(cln, []UUID{BondManagementUUID})
+	if err != nil {
+		return err
+	}
+	c := p.FindCharacteristic(NewCharacteristic(BondManagementControlPointUUID))
+	if c == nil {
+		return ErrNoBondManagement
+	}
+	return cln.WriteCharacteristic(c, append([]byte{byte(op)}, code...), false)
+}
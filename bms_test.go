@@ -0,0 +1,38 @@
+package ble_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestBondManagement(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s := ble.NewMemoryBondStore()
+	client, other := ble.NewAddr(gatttest.CentralAddr), ble.NewAddr("aa:bb:cc:dd:ee:ff")
+	for _, a := range []ble.Addr{client, other} {
+		s.Save(a, ble.BondPeerCSRK, []byte{0x01})
+	}
+	f := gatttest.New(ctx, t, 10*time.Millisecond, ble.NewBondManagementService(s, "fleet", 0))
+	defer f.Stop()
+
+	cln := f.Dial(ctx)
+	if err := ble.DeleteBonds(cln, ble.BondDeleteOthers, "guess"); !errors.Is(err, ble.ErrAuthorization) {
+		t.Errorf("DeleteBonds() with a wrong code = %v, want ErrAuthorization", err)
+	}
+	if err := ble.DeleteBonds(cln, ble.BondDeleteOthers, "fleet"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load(other, ble.BondPeerCSRK); err != ble.ErrNotFound {
+		t.Errorf("bond of another peer kept: %v", err)
+	}
+	if _, err := s.Load(client, ble.BondPeerCSRK); err != nil {
+		t.Errorf("bond of the client deleted: %v", err)
+	}
+}
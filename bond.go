@@ -0,0 +1,206 @@
+package ble
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound means the requested entry doesn't exist in the BondStore.
+var ErrNotFound = errors.New("not found")
+
+// A BondStore persists per-peer security data, such as keys and counters,
+// across sessions. Entries are opaque byte strings identified by the peer
+// address and a name. The stores of NewMemoryBondStore and NewFileBondStore
+// key the peers by the address alone, and keep its type, if the address of
+// a Save tells it, see ble.TypedAddr.
+type BondStore interface {
+	// Load returns the named entry of a peer, or ErrNotFound.
+	Load(peer Addr, name string) ([]byte, error)
+
+	// Save stores the named entry of a peer, replacing any previous value.
+	Save(peer Addr, name string, v []byte) error
+
+	// Delete removes all the entries of a peer.
+	Delete(peer Addr) error
+}
+
+// A BondLister is a BondStore, which lists the peers it has entries of, such
+// as to delete all the bonds. The stores of NewMemoryBondStore and
+// NewFileBondStore are BondListers.
+type BondLister interface {
+	Peers() ([]Addr, error)
+}
+
+// Names of the BondStore entries used for data signing. [Vol 3, Part H, 2.4.5]
+const (
+	BondLocalCSRK        = "lcsrk"    // CSRK distributed to the peer, used to sign outgoing writes.
+	BondLocalSignCounter = "lsigncnt" // Next counter for outgoing signed writes.
+	BondPeerCSRK         = "rcsrk"    // CSRK received from the peer, used to verify incoming writes.
+	BondPeerSignCounter  = "rsigncnt" // Last counter of verified incoming signed writes.
+)
+
+// BondLTK is the name of the BondStore entry of the LTK distributed by the
+// peer, which a central encrypts the link with: the LTK, EDIV, and Rand, in
+// little endian, and optionally the SecurityLevel of the pairing it was
+// distributed by, as a byte, or else SecurityEncrypted. [Vol 3, Part H, 3.6]
+const BondLTK = "ltk"
+
+// BondAddrType is the name of the BondStore entry of the type of the address
+// of the peer, as its AddrType.String, which the stores of NewMemoryBondStore
+// and NewFileBondStore save along the entries of a TypedAddr, so their
+// Peers are typed.
+const BondAddrType = "addrtype"
+
+// BondGATTCache is the name of the BondStore entry which caches the GATT
+// profile of the peer. An empty entry means the cache is invalid.
+const BondGATTCache = "gattcache"
+
+// NewMemoryBondStore returns a BondStore which keeps the entries in memory.
+func NewMemoryBondStore() BondStore {
+	return &memBondStore{m: make(map[string]map[string][]byte)}
+}
+
+type memBondStore struct {
+	sync.Mutex
+	m map[string]map[string][]byte
+}
+
+func (s *memBondStore) Load(peer Addr, name string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	v, ok := s.m[strings.ToLower(peer.String())][name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), v...), nil
+}
+
+func (s *memBondStore) Save(peer Addr, name string, v []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	p := strings.ToLower(peer.String())
+	if s.m[p] == nil {
+		s.m[p] = make(map[string][]byte)
+	}
+	s.m[p][name] = append([]byte(nil), v...)
+	if t := AddrTypeOf(peer); t != AddrTypeUnknown {
+		s.m[p][BondAddrType] = []byte(t.String())
+	}
+	return nil
+}
+
+func (s *memBondStore) Delete(peer Addr) error {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.m, strings.ToLower(peer.String()))
+	return nil
+}
+
+func (s *memBondStore) Peers() ([]Addr, error) {
+	s.Lock()
+	defer s.Unlock()
+	var peers []Addr
+	for p, m := range s.m {
+		peers = append(peers, peerAddr(p, m[BondAddrType]))
+	}
+	return peers, nil
+}
+
+// NewFileBondStore returns a BondStore which keeps each entry in a file,
+// under a subdirectory of dir per peer.
+func NewFileBondStore(dir string) (BondStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileBondStore{dir: dir}, nil
+}
+
+type fileBondStore struct {
+	sync.Mutex
+	dir string
+}
+
+func (s *fileBondStore) peerDir(peer Addr) string {
+	return filepath.Join(s.dir, strings.Replace(strings.ToLower(peer.String()), ":", "", -1))
+}
+
+func (s *fileBondStore) Load(peer Addr, name string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	v, err := ioutil.ReadFile(filepath.Join(s.peerDir(peer), name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+func (s *fileBondStore) Save(peer Addr, name string, v []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	dir := s.peerDir(peer)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := writeEntry(dir, name, v); err != nil {
+		return err
+	}
+	if t := AddrTypeOf(peer); t != AddrTypeUnknown {
+		return writeEntry(dir, BondAddrType, []byte(t.String()))
+	}
+	return nil
+}
+
+// writeEntry writes the entry name of the peer directory dir. It's written to
+// a temporary file first, so a crash can't leave a partial entry.
+func writeEntry(dir, name string, v []byte) error {
+	tmp := filepath.Join(dir, "."+name)
+	if err := ioutil.WriteFile(tmp, v, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, name))
+}
+
+func (s *fileBondStore) Delete(peer Addr) error {
+	s.Lock()
+	defer s.Unlock()
+	return os.RemoveAll(s.peerDir(peer))
+}
+
+func (s *fileBondStore) Peers() ([]Addr, error) {
+	s.Lock()
+	defer s.Unlock()
+	fis, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var peers []Addr
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		p := fi.Name()
+		if len(p) == 12 {
+			// A MAC address, stripped of its colons by peerDir.
+			p = strings.Join([]string{p[0:2], p[2:4], p[4:6], p[6:8], p[8:10], p[10:12]}, ":")
+		}
+		t, _ := ioutil.ReadFile(filepath.Join(s.dir, fi.Name(), BondAddrType))
+		peers = append(peers, peerAddr(p, t))
+	}
+	return peers, nil
+}
+
+// peerAddr returns the address p of a peer, typed as the BondAddrType entry
+// t, if any.
+func peerAddr(p string, t []byte) Addr {
+	switch string(t) {
+	case AddrTypePublic.String():
+		return NewTypedAddr(p, AddrTypePublic)
+	case AddrTypeRandom.String():
+		return NewTypedAddr(p, AddrTypeRandom)
+	}
+	return NewAddr(p)
+}
@@ -0,0 +1,138 @@
+package ble
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidAttribute is returned by Service.Validate, and by the AddService
+// of the servers, for characteristics whose properties, permissions, values
+// and handlers are inconsistent.
+var ErrInvalidAttribute = errors.New("invalid attribute")
+
+// A CharacteristicBuilder declares the properties, permissions, value,
+// descriptors and handlers of a characteristic in one place. Unlike
+// HandleRead and the like, it implies no properties, so that the properties
+// missing are reported by the AddService of the server.
+type CharacteristicBuilder struct {
+	c *Characteristic
+}
+
+// BuildCharacteristic adds a characteristic to a service, and returns its
+// builder. BuildCharacteristic panics if the service already contains another
+// characteristic with the same UUID.
+func (s *Service) BuildCharacteristic(u UUID) *CharacteristicBuilder {
+	return &CharacteristicBuilder{c: s.NewCharacteristic(u)}
+}
+
+// Properties declares the properties p.
+func (b *CharacteristicBuilder) Properties(p Property) *CharacteristicBuilder {
+	b.c.Property |= p
+	return b
+}
+
+// Permissions sets the security, which the reads and the writes require.
+func (b *CharacteristicBuilder) Permissions(read, write Permission) *CharacteristicBuilder {
+	b.c.ReadPermission, b.c.WritePermission = read, write
+	return b
+}
+
+// Value sets the static value, which the reads return.
+func (b *CharacteristicBuilder) Value(v []byte) *CharacteristicBuilder {
+	b.c.Value = append([]byte{}, v...)
+	return b
+}
+
+// Descriptor adds a descriptor of the static value v. Descriptor panics if
+// the characteristic already contains another descriptor with the same UUID.
+func (b *CharacteristicBuilder) Descriptor(u UUID, v []byte) *CharacteristicBuilder {
+	b.c.NewDescriptor(u).SetValue(v)
+	return b
+}
+
+// AddDescriptor adds d, e.g. one which has handlers. AddDescriptor panics if
+// the characteristic already contains another descriptor with the same UUID.
+func (b *CharacteristicBuilder) AddDescriptor(d *Descriptor) *CharacteristicBuilder {
+	b.c.AddDescriptor(d)
+	return b
+}
+
+// OnRead routes the read requests to h.
+func (b *CharacteristicBuilder) OnRead(h ReadHandler) *CharacteristicBuilder {
+	b.c.ReadHandler = h
+	return b
+}
+
+// OnWrite routes the write requests and commands to h.
+func (b *CharacteristicBuilder) OnWrite(h WriteHandler) *CharacteristicBuilder {
+	b.c.WriteHandler = h
+	return b
+}
+
+// OnNotify routes the subscriptions to notifications to h.
+func (b *CharacteristicBuilder) OnNotify(h NotifyHandler) *CharacteristicBuilder {
+	b.c.NotifyHandler = h
+	return b
+}
+
+// OnIndicate routes the subscriptions to indications to h.
+func (b *CharacteristicBuilder) OnIndicate(h NotifyHandler) *CharacteristicBuilder {
+	b.c.IndicateHandler = h
+	return b
+}
+
+// Characteristic returns the characteristic built.
+func (b *CharacteristicBuilder) Characteristic() *Characteristic {
+	return b.c
+}
+
+// Validate returns an error wrapping ErrInvalidAttribute, if the properties,
+// permissions, values and handlers of any characteristic of s, or its
+// descriptors, are inconsistent, such as a characteristic declared writable
+// without a WriteHandler.
+func (s *Service) Validate() error {
+	for _, c := range s.Characteristics {
+		if msg := c.inconsistency(); msg != "" {
+			return wrap(ErrInvalidAttribute, fmt.Sprintf("characteristic %s %s", c.UUID, msg))
+		}
+		for _, d := range c.Descriptors {
+			if d.Value != nil && d.ReadHandler != nil {
+				return wrap(ErrInvalidAttribute, fmt.Sprintf("descriptor %s of characteristic %s has both a value and a read handler", d.UUID, c.UUID))
+			}
+		}
+	}
+	return nil
+}
+
+// inconsistency describes the first inconsistency of c, or returns "".
+func (c *Characteristic) inconsistency() string {
+	const writes = CharWrite | CharWriteNR | CharSignedWrite
+	read := c.Value != nil || c.ReadHandler != nil
+	switch {
+	case c.Value != nil && c.ReadHandler != nil:
+		return "has both a value and a read handler"
+	case read && c.Property&CharRead == 0:
+		return "has a value or a read handler, but isn't readable"
+	case !read && c.Property&CharRead != 0:
+		return "is readable, but has neither a value nor a read handler"
+	case c.WriteHandler != nil && c.Property&writes == 0:
+		return "has a write handler, but isn't writable"
+	case c.WriteHandler == nil && c.Property&writes != 0:
+		return "is writable, but has no write handler"
+	case c.NotifyHandler != nil && c.Property&CharNotify == 0:
+		return "has a notify handler, but doesn't notify"
+	case c.NotifyHandler == nil && c.Property&CharNotify != 0:
+		return "notifies, but has no notify handler"
+	case c.IndicateHandler != nil && c.Property&CharIndicate == 0:
+		return "has an indicate handler, but doesn't indicate"
+	case c.IndicateHandler == nil && c.Property&CharIndicate != 0:
+		return "indicates, but has no indicate handler"
+	case c.ReadPermission != 0 && c.Property&CharRead == 0:
+		return "has a read permission, but isn't readable"
+	case c.WritePermission != 0 && c.Property&writes == 0:
+		return "has a write permission, but isn't writable"
+	case c.WritePermission&(PermEncrypt|PermAuthenticate) != 0 && c.Property&(CharWrite|CharWriteNR) == 0:
+		return "requires encryption of the writes, but is only written to by signed writes, which are unencrypted"
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package ble
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestServiceValidate(t *testing.T) {
+	read := ReadHandlerFunc(func(req Request, rsp ResponseWriter) {})
+	write := WriteHandlerFunc(func(req Request, rsp ResponseWriter) {})
+	for _, tt := range []struct {
+		name  string
+		build func(b *CharacteristicBuilder)
+		ok    bool
+	}{
+		{"value", func(b *CharacteristicBuilder) {
+			b.Properties(CharRead).Permissions(PermEncrypt, 0).Value([]byte("v")).Descriptor(UserDescriptionUUID, []byte("d"))
+		}, true},
+		{"handlers", func(b *CharacteristicBuilder) {
+			b.Properties(CharRead|CharWrite).Permissions(PermAuthorize, PermAuthenticate).OnRead(read).OnWrite(write)
+		}, true},
+		{"value and handler", func(b *CharacteristicBuilder) {
+			b.Properties(CharRead).Value([]byte("v")).OnRead(read)
+		}, false},
+		{"unreadable value", func(b *CharacteristicBuilder) {
+			b.Value([]byte("v"))
+		}, false},
+		{"writable without handler", func(b *CharacteristicBuilder) {
+			b.Properties(CharWrite)
+		}, false},
+		{"notifies without handler", func(b *CharacteristicBuilder) {
+			b.Properties(CharNotify)
+		}, false},
+		{"read permission of unreadable", func(b *CharacteristicBuilder) {
+			b.Properties(CharWrite).Permissions(PermEncrypt, 0).OnWrite(write)
+		}, false},
+		{"encrypted signed writes", func(b *CharacteristicBuilder) {
+			b.Properties(CharSignedWrite).Permissions(0, PermEncrypt).OnWrite(write)
+		}, false},
+	} {
+		s := NewService(UUID16(0x180F))
+		tt.build(s.BuildCharacteristic(UUID16(0x2A19)))
+		err := s.Validate()
+		if tt.ok && err != nil {
+			t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidAttribute) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidAttribute", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package ble
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// A ChannelMap is a mask of the data channels 0 to 36, as the bits of its
+// bytes, the channel 0 being the lowest bit of the first. [Vol 6, Part B,
+// 1.4.1]
+type ChannelMap [5]byte
+
+// AllDataChannels is the map of every data channel.
+var AllDataChannels = ChannelMap{0xFF, 0xFF, 0xFF, 0xFF, 0x1F}
+
+// MinChannels is the least number of data channels a map of the host may
+// leave. [Vol 2, Part E, 7.8.19]
+const MinChannels = 2
+
+// ErrChannelMap is the error of the channel maps, which leave fewer than
+// MinChannels data channels.
+var ErrChannelMap = errors.New("channel map leaves fewer than 2 data channels")
+
+// Has reports whether the map has the data channel ch.
+func (m ChannelMap) Has(ch int) bool {
+	return ch >= 0 && ch < 37 && m[ch/8]&(1<<uint(ch%8)) != 0
+}
+
+// Count returns the number of data channels of the map.
+func (m ChannelMap) Count() int {
+	m[4] &= 0x1F // Channels 37 to 39 are the advertising channels.
+	n := 0
+	for _, b := range m {
+		n += bits.OnesCount8(b)
+	}
+	return n
+}
+
+// Channels returns the data channels of the map, in order.
+func (m ChannelMap) Channels() []int {
+	var chs []int
+	for ch := 0; ch < 37; ch++ {
+		if m.Has(ch) {
+			chs = append(chs, ch)
+		}
+	}
+	return chs
+}
+
+// Without returns the map without the data channels chs.
+func (m ChannelMap) Without(chs ...int) ChannelMap {
+	for _, ch := range chs {
+		if ch >= 0 && ch < 37 {
+			m[ch/8] &^= 1 << uint(ch%8)
+		}
+	}
+	return m
+}
+
+// WithoutWiFi returns the map without the data channels, which overlap the
+// 20 MHz wide 2.4 GHz Wi-Fi channels chs, 1 to 14, so that a device
+// co-located with an access point avoids them.
+func (m ChannelMap) WithoutWiFi(chs ...int) ChannelMap {
+	for _, w := range chs {
+		if w < 1 || w > 14 {
+			continue
+		}
+		c := 2407 + 5*w
+		if w == 14 {
+			c = 2484
+		}
+		for ch := 0; ch < 37; ch++ {
+			if d := DataChannelFreq(ch) - c; d > -11 && d < 11 {
+				m = m.Without(ch)
+			}
+		}
+	}
+	return m
+}
+
+// DataChannelFreq returns the center frequency of the data channel ch, in
+// MHz. [Vol 6, Part B, 1.4.1]
+func DataChannelFreq(ch int) int {
+	if ch <= 10 {
+		return 2404 + 2*ch
+	}
+	return 2428 + 2*(ch-11)
+}
+
+func (m ChannelMap) String() string {
+	chs := m.Channels()
+	ss := make([]string, len(chs))
+	for i, ch := range chs {
+		ss[i] = fmt.Sprint(ch)
+	}
+	return "[" + strings.Join(ss, " ") + "]"
+}
+
+// Validate returns ErrChannelMap, if the map leaves fewer than MinChannels
+// data channels.
+func (m ChannelMap) Validate() error {
+	if m.Count() < MinChannels {
+		return ErrChannelMap
+	}
+	return nil
+}
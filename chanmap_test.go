@@ -0,0 +1,36 @@
+package ble
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChannelMap(t *testing.T) {
+	m := AllDataChannels
+	if n := m.Count(); n != 37 {
+		t.Fatalf("Count() = %d, want 37", n)
+	}
+	// Wi-Fi channel 1 spans 2401 to 2423 MHz, over the data channels 0 to 9.
+	m = m.WithoutWiFi(1)
+	if n := m.Count(); n != 27 {
+		t.Errorf("without Wi-Fi 1: Count() = %d, want 27", n)
+	}
+	if m.Has(9) || !m.Has(10) {
+		t.Errorf("without Wi-Fi 1: %v", m)
+	}
+	// And 6 and 11, 2426 to 2448, and 2451 to 2473 MHz, so only the data
+	// channels between them, and above, are left.
+	m = m.WithoutWiFi(6, 11)
+	if chs := m.Channels(); !reflect.DeepEqual(chs, []int{10, 21, 22, 34, 35, 36}) {
+		t.Errorf("without Wi-Fi 1, 6 and 11: %v", chs)
+	}
+	if err := m.Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := m.Without(10, 21, 22, 34, 35).Validate(); err != ErrChannelMap {
+		t.Errorf("single channel: %v, want %v", err, ErrChannelMap)
+	}
+	if s := (ChannelMap{0x05}).String(); s != "[0 2]" {
+		t.Errorf("String() = %q", s)
+	}
+}
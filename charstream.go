@@ -0,0 +1,242 @@
+package ble
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// Framing is the framing of the data of a CharStream.
+type Framing int
+
+// Framings.
+const (
+	// FramingNone streams the bytes as they are, cut into values of the MTU.
+	// The peer sees no boundaries of the writes.
+	FramingNone Framing = iota
+
+	// FramingLength prefixes each write with its length, in 2 bytes little
+	// endian, so the peer can reassemble it from the values of the MTU, and
+	// each read returns the data of a single frame.
+	FramingLength
+)
+
+// ErrReadOnly is returned by the writes of the streams, which have no tx
+// characteristic.
+var ErrReadOnly = errors.New("stream is read only")
+
+// MaxFrameLen is the length limit of the frames of FramingLength.
+const MaxFrameLen = 0xFFFF
+
+// ErrFrameTooLong is returned by the writes of frames longer than MaxFrameLen.
+var ErrFrameTooLong = errors.New("frame too long")
+
+// CharStreamConfig configures a CharStream. The zero value streams the bytes
+// unframed, with writes without response where tx supports them, and
+// notifications where rx supports them.
+type CharStreamConfig struct {
+	Framing  Framing
+	Acked    bool // Writes with response, even if tx supports writes without response.
+	Indicate bool // Subscribes to indications, even if rx supports notifications.
+	MaxChunk int  // Limit of the bytes of each write; the MTU allows up to ATT_MTU-3 if 0.
+
+	// Framer frames the data, rather than Framing, if set, such as by
+	// DelimiterFramer, SLIPFramer or COBSFramer. It's of the stream only.
+	Framer Framer
+}
+
+// A CharStream is an io.ReadWriteCloser, which writes to one characteristic
+// and reads the notifications of another, as the serial ports over GATT of
+// many devices do, such as the Nordic UART Service.
+type CharStream struct {
+	cln Client
+	tx  *Characteristic
+	rx  *Characteristic
+	cfg CharStreamConfig
+	ind bool
+	f   Framer // Nil if unframed.
+
+	wmu sync.Mutex // Serializes the writes, so that their chunks don't interleave.
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte   // Received bytes, not read yet.
+	frames [][]byte // Received frames, not read yet, if framed.
+	closed bool
+	err    error
+	done   chan struct{}
+}
+
+// NewCharStream returns a CharStream, which writes to tx and reads the
+// notifications of rx, with the zero CharStreamConfig.
+func NewCharStream(cln Client, tx, rx *Characteristic) (*CharStream, error) {
+	return NewCharStreamWithConfig(cln, tx, rx, CharStreamConfig{})
+}
+
+// NewCharStreamWithConfig returns a CharStream, which writes to tx and reads
+// the notifications of rx, as configured by cfg. rx is subscribed to until
+// the stream is closed, or the client disconnects. Of a nil tx, the stream
+// is read only, and its writes fail with ErrReadOnly.
+func NewCharStreamWithConfig(cln Client, tx, rx *Characteristic, cfg CharStreamConfig) (*CharStream, error) {
+	if tx != nil && tx.Property&(CharWrite|CharWriteNR) == 0 {
+		return nil, errors.New("tx characteristic isn't writable")
+	}
+	if rx.Property&(CharNotify|CharIndicate) == 0 {
+		return nil, errors.New("rx characteristic doesn't notify")
+	}
+	s := &CharStream{cln: cln, tx: tx, rx: rx, cfg: cfg, f: cfg.Framer, done: make(chan struct{})}
+	if s.f == nil && cfg.Framing == FramingLength {
+		s.f = LengthFramer(2)
+	}
+	s.cond = sync.NewCond(&s.mu)
+	s.ind = rx.Property&CharNotify == 0 || (cfg.Indicate && rx.Property&CharIndicate != 0)
+	if err := cln.Subscribe(rx, s.ind, s.receive); err != nil {
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-cln.Disconnected():
+			s.shut(io.EOF)
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// NewCharReader returns a read only CharStream of the notifications of rx,
+// as configured by cfg, such as of a sensor streaming its samples.
+func NewCharReader(cln Client, rx *Characteristic, cfg CharStreamConfig) (*CharStream, error) {
+	return NewCharStreamWithConfig(cln, nil, rx, cfg)
+}
+
+// receive queues the value of a notification.
+func (s *CharStream) receive(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.f == nil {
+		s.buf = append(s.buf, b...)
+		s.cond.Broadcast()
+		return
+	}
+	if fs := s.f.Decode(b); len(fs) > 0 {
+		s.frames = append(s.frames, fs...)
+		s.cond.Broadcast()
+	}
+}
+
+// Read reads the received bytes, or, if framed, the data of the next frame, which fails with io.ErrShortBuffer beyond len(p). It blocks until
+// there are any, and returns io.EOF once the stream is closed, or the client
+// disconnects. The notifications are queued until read, however many.
+func (s *CharStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.buf) == 0 && len(s.frames) == 0 && s.err == nil {
+		s.cond.Wait()
+	}
+	if s.f == nil && len(s.buf) > 0 {
+		n := copy(p, s.buf)
+		s.buf = s.buf[n:]
+		return n, nil
+	}
+	if len(s.frames) > 0 {
+		f := s.frames[0]
+		s.frames = s.frames[1:]
+		n := copy(p, f)
+		if n < len(f) {
+			return n, io.ErrShortBuffer
+		}
+		return n, nil
+	}
+	return 0, s.err
+}
+
+// Write writes p, framed, if framed, and cut into the values of the MTU.
+// The frames are written as a whole, or not at all.
+func (s *CharStream) Write(p []byte) (int, error) {
+	if s.tx == nil {
+		return 0, ErrReadOnly
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return 0, io.ErrClosedPipe
+	}
+	b := p
+	if s.f != nil {
+		var err error
+		if b, err = s.f.Encode(p); err != nil {
+			return 0, err
+		}
+	}
+	noRsp := !s.cfg.Acked && s.tx.Property&CharWriteNR != 0
+	if s.tx.Property&CharWrite == 0 {
+		noRsp = true
+	}
+
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	n := s.chunk()
+	for off := 0; off < len(b); off += n {
+		end := off + n
+		if end > len(b) {
+			end = len(b)
+		}
+		if err := s.cln.WriteCharacteristic(s.tx, b[off:end], noRsp); err != nil {
+			if s.f != nil {
+				return 0, err
+			}
+			return off, err
+		}
+	}
+	return len(p), nil
+}
+
+// chunk returns the number of bytes of each write.
+func (s *CharStream) chunk() int {
+	n := DefaultMTU - 3
+	if c := s.cln.Conn(); c != nil {
+		n = c.TxMTU() - 3
+	}
+	if s.cfg.MaxChunk > 0 && s.cfg.MaxChunk < n {
+		n = s.cfg.MaxChunk
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// Close unsubscribes from rx, and ends the reads with io.EOF. The client
+// stays connected.
+func (s *CharStream) Close() error {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return nil
+	}
+	s.shut(io.EOF)
+	select {
+	case <-s.cln.Disconnected():
+		return nil
+	default:
+	}
+	return s.cln.Unsubscribe(s.rx, s.ind)
+}
+
+// shut ends the stream with err, once the received data has been read.
+func (s *CharStream) shut(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.err = err
+	close(s.done)
+	s.cond.Broadcast()
+}
@@ -0,0 +1,68 @@
+package ble_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestCharStream(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The peripheral echoes the writes of tx as the notifications of rx.
+	echo := make(chan []byte, 64)
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		echo <- append([]byte(nil), req.Data()...)
+	}))
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		for {
+			select {
+			case b := <-echo:
+				n.Write(b)
+			case <-n.Context().Done():
+				return
+			}
+		}
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	tx := f.Characteristic(cln, gatttest.ReadCharUUID)
+	rx := f.Characteristic(cln, gatttest.NotifyCharUUID)
+	s, err := ble.NewCharStreamWithConfig(cln, tx, rx, ble.CharStreamConfig{Framing: ble.FramingLength})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Frames longer than the MTU are cut, and reassembled.
+	want := bytes.Repeat([]byte("0123456789"), 10)
+	for i := 0; i < 2; i++ {
+		if _, err := s.Write(want); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		b := make([]byte, 200)
+		n, err := s.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b[:n], want) {
+			t.Errorf("read %q, want %q", b[:n], want)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() after Close = %v, want io.EOF", err)
+	}
+}
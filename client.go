@@ -1,5 +1,7 @@
 package ble
 
+import "context"
+
 // A Client is a GATT client.
 type Client interface {
 	// Addr returns platform specific unique ID of the remote peripheral, e.g. MAC on Linux, Client UUID on OS X.
@@ -16,7 +18,8 @@ type Client interface {
 	DiscoverProfile(force bool) (*Profile, error)
 
 	// DiscoverServices finds all the primary services on a server. [Vol 3, Part G, 4.4.1]
-	// If filter is specified, only filtered services are returned.
+	// If filter is specified, only filtered services are returned, which are found by their
+	// UUIDs, rather than by walking all the services, where the platform allows. [Vol 3, Part G, 4.4.2]
 	DiscoverServices(filter []UUID) ([]*Service, error)
 
 	// DiscoverIncludedServices finds the included services of a service. [Vol 3, Part G, 4.5.1]
@@ -31,15 +34,36 @@ type Client interface {
 	// If filter is specified, only filtered descriptors are returned.
 	DiscoverDescriptors(filter []UUID, c *Characteristic) ([]*Descriptor, error)
 
+	// Characteristics returns all the discovered characteristics with the UUID, in handle order.
+	Characteristics(u UUID) []*Characteristic
+
 	// ReadCharacteristic reads a characteristic value from a server. [Vol 3, Part G, 4.8.1]
 	ReadCharacteristic(c *Characteristic) ([]byte, error)
 
+	// ReadCharacteristicByUUID reads the value of the first characteristic with UUID chr, within the
+	// first service with UUID svc, or anywhere if svc is nil, without discovering them. [Vol 3, Part G, 4.8.2]
+	ReadCharacteristicByUUID(svc, chr UUID) ([]byte, error)
+
 	// ReadLongCharacteristic reads a characteristic value which is longer than the MTU. [Vol 3, Part G, 4.8.3]
 	ReadLongCharacteristic(c *Characteristic) ([]byte, error)
 
 	// WriteCharacteristic writes a characteristic value to a server. [Vol 3, Part G, 4.9.3]
+	// The writes without response return once queued to the controller, so that they
+	// are pipelined up to its buffers. [Vol 3, Part G, 4.9.1]
 	WriteCharacteristic(c *Characteristic, value []byte, noRsp bool) error
 
+	// Flush waits until the writes without response queued have been sent to the peer,
+	// as far as the platform tells, or ctx is done.
+	Flush(ctx context.Context) error
+
+	// WriteLongCharacteristic writes a characteristic value which is longer than the MTU.
+	// If reliable is set, the server's echo of each part is verified. [Vol 3, Part G, 4.9.4 & 4.9.5]
+	WriteLongCharacteristic(c *Characteristic, value []byte, reliable bool) error
+
+	// RawATT sends an ATT PDU the library doesn't otherwise model, and returns the
+	// response if the opcode is a request. It is serialized with the other requests.
+	RawATT(ctx context.Context, pdu []byte) ([]byte, error)
+
 	// ReadDescriptor reads a characteristic descriptor from a server. [Vol 3, Part G, 4.12.1]
 	ReadDescriptor(d *Descriptor) ([]byte, error)
 
@@ -49,10 +73,26 @@ type Client interface {
 	// ReadRSSI retrieves the current RSSI value of remote peripheral. [Vol 2, Part E, 7.5.4]
 	ReadRSSI() int
 
+	// Security returns the security of the link, e.g. to enforce a minimum encryption key size.
+	Security() Security
+
+	// Encrypted reports whether the link is encrypted.
+	Encrypted() bool
+
+	// SecurityLevel returns the security level of the link.
+	SecurityLevel() SecurityLevel
+
+	// RemoteInfo returns the version and the features of the remote controller, as far as they
+	// have been read on connect.
+	RemoteInfo() RemoteInfo
+
 	// ExchangeMTU set the ATT_MTU to the maximum possible value that can be supported by both devices [Vol 3, Part G, 4.3.1]
 	ExchangeMTU(rxMTU int) (txMTU int, err error)
 
 	// Subscribe subscribes to indication (if ind is set true), or notification of a characteristic value. [Vol 3, Part G, 4.10 & 4.11]
+	// The values are passed to h in the order they were received. The ones received before the response
+	// to a request, such as a read of the same characteristic, are queued for h before the request returns,
+	// though h may be called after; on linux, gatt.Client.SubscribeSeq numbers them to tell.
 	Subscribe(c *Characteristic, ind bool, h NotificationHandler) error
 
 	// Unsubscribe unsubscribes to indication (if ind is set true), or notification of a specified characteristic value. [Vol 3, Part G, 4.10 & 4.11]
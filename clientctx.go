@@ -0,0 +1,157 @@
+package ble
+
+import "context"
+
+// A ContextClient is a Client, which bounds its operations by a context of
+// their own, as the linux backend does, so that a read, a write, or a
+// discovery gives up once the context is done, rather than wait up to the
+// transaction timeout of the connection.
+type ContextClient interface {
+	Client
+
+	// WithContext returns a copy of the Client, sharing its connection,
+	// whose operations give up once ctx is done, returning its error.
+	WithContext(ctx context.Context) Client
+}
+
+// ClientWithContext returns cln, whose operations give up once ctx is done,
+// returning its error. The operations of a ContextClient are abandoned by
+// the backend, down to its requests; those of the other Clients are left to
+// complete in the background, only their callers return. Subscribe is
+// bounded as it subscribes, but the subscription outlives ctx.
+//
+//	ctx, cancel := context.WithTimeout(ctx, time.Second)
+//	defer cancel()
+//	b, err := ble.ClientWithContext(ctx, cln).ReadCharacteristic(c)
+func ClientWithContext(ctx context.Context, cln Client) Client {
+	if cc, ok := cln.(ContextClient); ok {
+		return cc.WithContext(ctx)
+	}
+	return &ctxClient{Client: cln, ctx: ctx}
+}
+
+// ctxClient bounds the waits of the operations of a Client by ctx.
+type ctxClient struct {
+	Client
+	ctx context.Context
+}
+
+// do calls f, unless ctx is done already, and returns its error, or that of
+// ctx, if done first.
+func (c *ctxClient) do(f func() error) error {
+	if err := c.ctx.Err(); err != nil {
+		return err
+	}
+	ch := make(chan error, 1)
+	go func() { ch <- f() }()
+	select {
+	case err := <-ch:
+		return err
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+func (c *ctxClient) DiscoverProfile(force bool) (*Profile, error) {
+	var p *Profile
+	if err := c.do(func() (err error) { p, err = c.Client.DiscoverProfile(force); return }); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *ctxClient) DiscoverServices(filter []UUID) ([]*Service, error) {
+	var ss []*Service
+	if err := c.do(func() (err error) { ss, err = c.Client.DiscoverServices(filter); return }); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (c *ctxClient) DiscoverIncludedServices(filter []UUID, s *Service) ([]*Service, error) {
+	var ss []*Service
+	if err := c.do(func() (err error) { ss, err = c.Client.DiscoverIncludedServices(filter, s); return }); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (c *ctxClient) DiscoverCharacteristics(filter []UUID, s *Service) ([]*Characteristic, error) {
+	var cs []*Characteristic
+	if err := c.do(func() (err error) { cs, err = c.Client.DiscoverCharacteristics(filter, s); return }); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+func (c *ctxClient) DiscoverDescriptors(filter []UUID, ch *Characteristic) ([]*Descriptor, error) {
+	var ds []*Descriptor
+	if err := c.do(func() (err error) { ds, err = c.Client.DiscoverDescriptors(filter, ch); return }); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+func (c *ctxClient) ReadCharacteristic(ch *Characteristic) ([]byte, error) {
+	var b []byte
+	if err := c.do(func() (err error) { b, err = c.Client.ReadCharacteristic(ch); return }); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *ctxClient) ReadCharacteristicByUUID(svc, chr UUID) ([]byte, error) {
+	var b []byte
+	if err := c.do(func() (err error) { b, err = c.Client.ReadCharacteristicByUUID(svc, chr); return }); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *ctxClient) ReadLongCharacteristic(ch *Characteristic) ([]byte, error) {
+	var b []byte
+	if err := c.do(func() (err error) { b, err = c.Client.ReadLongCharacteristic(ch); return }); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *ctxClient) WriteCharacteristic(ch *Characteristic, v []byte, noRsp bool) error {
+	return c.do(func() error { return c.Client.WriteCharacteristic(ch, v, noRsp) })
+}
+
+func (c *ctxClient) WriteLongCharacteristic(ch *Characteristic, v []byte, reliable bool) error {
+	return c.do(func() error { return c.Client.WriteLongCharacteristic(ch, v, reliable) })
+}
+
+func (c *ctxClient) ReadDescriptor(d *Descriptor) ([]byte, error) {
+	var b []byte
+	if err := c.do(func() (err error) { b, err = c.Client.ReadDescriptor(d); return }); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *ctxClient) WriteDescriptor(d *Descriptor, v []byte) error {
+	return c.do(func() error { return c.Client.WriteDescriptor(d, v) })
+}
+
+func (c *ctxClient) ExchangeMTU(rxMTU int) (int, error) {
+	var txMTU int
+	if err := c.do(func() (err error) { txMTU, err = c.Client.ExchangeMTU(rxMTU); return }); err != nil {
+		return 0, err
+	}
+	return txMTU, nil
+}
+
+func (c *ctxClient) Subscribe(ch *Characteristic, ind bool, h NotificationHandler) error {
+	return c.do(func() error { return c.Client.Subscribe(ch, ind, h) })
+}
+
+func (c *ctxClient) Unsubscribe(ch *Characteristic, ind bool) error {
+	return c.do(func() error { return c.Client.Unsubscribe(ch, ind) })
+}
+
+func (c *ctxClient) ClearSubscriptions() error {
+	return c.do(c.Client.ClearSubscriptions)
+}
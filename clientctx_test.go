@@ -0,0 +1,76 @@
+package ble_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+	"github.com/kirbo/ble/linux/gatt"
+)
+
+// stallingClient reads once release is closed.
+type stallingClient struct {
+	ble.Client
+	release chan struct{}
+}
+
+func (c *stallingClient) ReadCharacteristic(ch *ble.Characteristic) ([]byte, error) {
+	<-c.release
+	return []byte{0x01}, nil
+}
+
+func TestClientWithContext(t *testing.T) {
+	cln := &stallingClient{release: make(chan struct{})}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	bound := ble.ClientWithContext(ctx, cln)
+	if _, err := bound.ReadCharacteristic(nil); err != context.DeadlineExceeded {
+		t.Fatalf("ReadCharacteristic() = %v, want context.DeadlineExceeded", err)
+	}
+	close(cln.release)
+	if _, err := bound.ReadCharacteristic(nil); err != context.DeadlineExceeded {
+		t.Errorf("ReadCharacteristic() once done = %v, want context.DeadlineExceeded", err)
+	}
+	b, err := ble.ClientWithContext(context.Background(), cln).ReadCharacteristic(nil)
+	if err != nil || len(b) != 1 {
+		t.Errorf("ReadCharacteristic() = [% X], %v", b, err)
+	}
+}
+
+func TestOperationContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stall := make(chan struct{})
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		<-stall
+		rsp.Write([]byte("v"))
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	opCtx, opCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer opCancel()
+	bound := ble.ClientWithContext(opCtx, cln)
+	if _, ok := bound.(*gatt.Client); !ok {
+		t.Fatalf("ClientWithContext() = %T, want *gatt.Client", bound)
+	}
+	if _, err := bound.ReadCharacteristic(rc); err != context.DeadlineExceeded {
+		t.Fatalf("read of a stalled characteristic = %v, want context.DeadlineExceeded", err)
+	}
+
+	// The response to the read abandoned isn't taken for that of the next.
+	close(stall)
+	v, err := cln.ReadCharacteristic(rc)
+	if err != nil || string(v) != "v" {
+		t.Errorf("read %q, %v, want \"v\"", v, err)
+	}
+	if _, err := bound.ReadCharacteristic(rc); err != context.DeadlineExceeded {
+		t.Errorf("read once done = %v, want context.DeadlineExceeded", err)
+	}
+}
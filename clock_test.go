@@ -0,0 +1,43 @@
+package ble
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	c := NewFakeClock(time.Unix(0, 0))
+	tk := c.NewTicker(time.Second)
+	tm := c.NewTimer(1500 * time.Millisecond)
+	after := c.After(3 * time.Second)
+
+	c.Advance(2 * time.Second)
+	if at := <-tk.C(); !at.Equal(time.Unix(1, 0)) {
+		t.Errorf("first tick at %v, want 1s", at)
+	}
+	// The tick of 2s is dropped, as the first wasn't received in time.
+	select {
+	case at := <-tk.C():
+		t.Errorf("tick at %v, want none", at)
+	default:
+	}
+	if at := <-tm.C(); !at.Equal(time.Unix(1, 5e8)) {
+		t.Errorf("timer fired at %v, want 1.5s", at)
+	}
+	select {
+	case <-after:
+		t.Error("After(3s) fired at 2s")
+	default:
+	}
+	tk.Stop()
+	if n := c.Waiters(); n != 1 {
+		t.Errorf("%d waiters, want After(3s)", n)
+	}
+	c.Advance(time.Second)
+	if at := <-after; !at.Equal(time.Unix(3, 0)) {
+		t.Errorf("After(3s) fired at %v", at)
+	}
+	if !c.Now().Equal(time.Unix(3, 0)) {
+		t.Errorf("Now() = %v, want 3s", c.Now())
+	}
+}
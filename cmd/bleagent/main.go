@@ -0,0 +1,67 @@
+// Bleagent owns the adapter of a machine near the peripherals, and serves it
+// to the applications of other machines, over TCP and WebSocket. The
+// applications connect with blenet.Dial, or blenet.DialWebSocket, which
+// return a ble.Device.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/kirbo/ble/blenet"
+	"github.com/kirbo/ble/examples/lib/dev"
+)
+
+var (
+	device = flag.String("device", "default", "implementation of ble")
+	addr   = flag.String("addr", ":"+strconv.Itoa(blenet.DefaultPort), "TCP address to listen on, or empty")
+	ws     = flag.String("ws", "", "HTTP address to serve WebSocket on, at /ble, or empty")
+	token  = flag.String("token", os.Getenv("BLENET_TOKEN"), "token of the clients, by default $BLENET_TOKEN")
+)
+
+func main() {
+	flag.Parse()
+
+	if *token == "" {
+		log.Fatal("a token is required")
+	}
+	if *addr == "" && *ws == "" {
+		log.Fatal("nothing to listen on")
+	}
+	d, err := dev.NewDevice(*device)
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	defer d.Stop()
+	a := blenet.NewAgent(d, *token)
+
+	errs := make(chan error, 2)
+	if *addr != "" {
+		l, err := net.Listen("tcp", *addr)
+		if err != nil {
+			log.Fatalf("can't listen: %s", err)
+		}
+		log.Printf("serving on %s", l.Addr())
+		go func() { errs <- a.Serve(l) }()
+	}
+	if *ws != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/ble", a)
+		log.Printf("serving WebSocket on %s/ble", *ws)
+		go func() { errs <- http.ListenAndServe(*ws, mux) }()
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-sigs:
+	case err := <-errs:
+		log.Printf("can't serve: %s", err)
+	}
+}
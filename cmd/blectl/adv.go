@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kirbo/ble"
+	"github.com/urfave/cli"
+)
+
+func cmdAdv(c *cli.Context) error {
+	ctx, cancel := cmdContext(c)
+	defer cancel()
+	switch {
+	case c.String("ibeacon") != "":
+		u, major, minor, pwr, err := parseIBeacon(c.String("ibeacon"))
+		if err != nil {
+			return err
+		}
+		return done(ble.AdvertiseIBeacon(ctx, u, major, minor, pwr))
+	case c.String("mfg") != "":
+		id, b, err := parseMfg(c.String("mfg"))
+		if err != nil {
+			return err
+		}
+		return done(device.AdvertiseMfgData(ctx, id, b))
+	}
+	svcs, err := parseUUIDs(c.String("svc"))
+	if err != nil {
+		return err
+	}
+	return done(ble.AdvertiseNameAndServices(ctx, c.String("name"), svcs...))
+}
+
+// parseIBeacon parses uuid,major,minor[,power]. The power, measured at 1m,
+// defaults to -59 dBm.
+func parseIBeacon(s string) (u ble.UUID, major, minor uint16, pwr int8, err error) {
+	f := strings.Split(s, ",")
+	if len(f) != 3 && len(f) != 4 {
+		return nil, 0, 0, 0, fmt.Errorf("invalid iBeacon %q: want uuid,major,minor[,power]", s)
+	}
+	if u, err = ble.Parse(f[0]); err != nil {
+		return nil, 0, 0, 0, fmt.Errorf("invalid iBeacon UUID %q", f[0])
+	}
+	n, err := strconv.ParseUint(f[1], 0, 16)
+	if err != nil {
+		return nil, 0, 0, 0, fmt.Errorf("invalid iBeacon major %q", f[1])
+	}
+	m, err := strconv.ParseUint(f[2], 0, 16)
+	if err != nil {
+		return nil, 0, 0, 0, fmt.Errorf("invalid iBeacon minor %q", f[2])
+	}
+	p := int64(-59)
+	if len(f) == 4 {
+		if p, err = strconv.ParseInt(f[3], 0, 8); err != nil {
+			return nil, 0, 0, 0, fmt.Errorf("invalid iBeacon power %q", f[3])
+		}
+	}
+	return u, uint16(n), uint16(m), int8(p), nil
+}
+
+// parseMfg parses id:hex, the company ID, in hex, and the data.
+func parseMfg(s string) (uint16, []byte, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return 0, nil, fmt.Errorf("invalid manufacturer data %q: want id:hex", s)
+	}
+	id, err := strconv.ParseUint(s[:i], 16, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid company ID %q", s[:i])
+	}
+	b, err := hex.DecodeString(s[i+1:])
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid manufacturer data %q", s[i+1:])
+	}
+	return uint16(id), b, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kirbo/ble"
+	"github.com/urfave/cli"
+)
+
+// connect connects to the peripheral of the address, or else the name,
+// flag.
+func connect(ctx context.Context, c *cli.Context) (ble.Client, error) {
+	addr, name := c.String("addr"), c.String("name")
+	switch {
+	case addr != "":
+		return ble.Dial(ctx, ble.NewAddr(addr))
+	case name != "":
+		return ble.Connect(ctx, func(a ble.Advertisement) bool {
+			return a.LocalName() == name
+		})
+	}
+	return nil, fmt.Errorf("no remote device: set --addr or --name")
+}
+
+// profileJSON is the GATT profile, as printed with --json.
+type profileJSON struct {
+	UUID            string         `json:"uuid"`
+	Name            string         `json:"name,omitempty"`
+	Handle          uint16         `json:"handle"`
+	Property        string         `json:"property,omitempty"`
+	ValueHandle     uint16         `json:"valueHandle,omitempty"`
+	Value           string         `json:"value,omitempty"`
+	Error           string         `json:"error,omitempty"`
+	Characteristics []*profileJSON `json:"characteristics,omitempty"`
+	Descriptors     []*profileJSON `json:"descriptors,omitempty"`
+}
+
+func cmdExplore(c *cli.Context) error {
+	ctx, cancel := cmdContext(c)
+	defer cancel()
+	cln, err := connect(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer cln.CancelConnection()
+	p, err := cln.DiscoverProfile(true)
+	if err != nil {
+		return fmt.Errorf("can't discover profile: %v", err)
+	}
+
+	read := func(j *profileJSON, f func() ([]byte, error)) {
+		if !c.Bool("read") {
+			return
+		}
+		if b, err := f(); err != nil {
+			j.Error = err.Error()
+		} else {
+			j.Value = hex.EncodeToString(b)
+		}
+	}
+	var svcs []*profileJSON
+	for _, s := range p.Services {
+		sj := &profileJSON{UUID: s.UUID.String(), Name: ble.Name(s.UUID), Handle: s.Handle}
+		for _, ch := range s.Characteristics {
+			ch := ch
+			cj := &profileJSON{
+				UUID:        ch.UUID.String(),
+				Name:        ble.Name(ch.UUID),
+				Handle:      ch.Handle,
+				Property:    propString(ch.Property),
+				ValueHandle: ch.ValueHandle,
+			}
+			if ch.Property&ble.CharRead != 0 {
+				read(cj, func() ([]byte, error) { return cln.ReadLongCharacteristic(ch) })
+			}
+			for _, d := range ch.Descriptors {
+				d := d
+				dj := &profileJSON{UUID: d.UUID.String(), Name: ble.Name(d.UUID), Handle: d.Handle}
+				read(dj, func() ([]byte, error) { return cln.ReadDescriptor(d) })
+				cj.Descriptors = append(cj.Descriptors, dj)
+			}
+			sj.Characteristics = append(sj.Characteristics, cj)
+		}
+		svcs = append(svcs, sj)
+	}
+
+	if c.Bool("json") {
+		return json.NewEncoder(os.Stdout).Encode(svcs)
+	}
+	for _, s := range svcs {
+		fmt.Printf("Service: %s %s, Handle(0x%02X)\n", s.UUID, s.Name, s.Handle)
+		for _, ch := range s.Characteristics {
+			fmt.Printf("  Characteristic: %s %s, Property(%s), Handle(0x%02X), VHandle(0x%02X)\n",
+				ch.UUID, ch.Name, ch.Property, ch.Handle, ch.ValueHandle)
+			printValue("    ", ch)
+			for _, d := range ch.Descriptors {
+				fmt.Printf("    Descriptor: %s %s, Handle(0x%02X)\n", d.UUID, d.Name, d.Handle)
+				printValue("      ", d)
+			}
+		}
+	}
+	return nil
+}
+
+func printValue(indent string, j *profileJSON) {
+	switch {
+	case j.Error != "":
+		fmt.Printf("%sError: %s\n", indent, j.Error)
+	case j.Value != "":
+		b, _ := hex.DecodeString(j.Value)
+		fmt.Printf("%sValue: %x | %q\n", indent, b, b)
+	}
+}
+
+func propString(p ble.Property) string {
+	var s string
+	for _, pr := range []struct {
+		p ble.Property
+		s string
+	}{
+		{ble.CharBroadcast, "B"},
+		{ble.CharRead, "R"},
+		{ble.CharWriteNR, "w"},
+		{ble.CharWrite, "W"},
+		{ble.CharNotify, "N"},
+		{ble.CharIndicate, "I"},
+		{ble.CharSignedWrite, "S"},
+		{ble.CharExtended, "E"},
+	} {
+		if p&pr.p != 0 {
+			s += pr.s
+		}
+	}
+	return s
+}
+
+// findChar connects, and finds the characteristic of the UUID flag, in the
+// services of the service flag, if set.
+func findChar(ctx context.Context, c *cli.Context) (ble.Client, *ble.Characteristic, error) {
+	u, err := ble.Parse(c.String("uuid"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid characteristic UUID %q", c.String("uuid"))
+	}
+	svcs, err := parseUUIDs(c.String("svc"))
+	if err != nil {
+		return nil, nil, err
+	}
+	cln, err := connect(ctx, c)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := cln.DiscoverProfile(true)
+	if err != nil {
+		cln.CancelConnection()
+		return nil, nil, fmt.Errorf("can't discover profile: %v", err)
+	}
+	for _, s := range p.Services {
+		if len(svcs) > 0 && !ble.Contains(svcs, s.UUID) {
+			continue
+		}
+		for _, ch := range s.Characteristics {
+			if ch.UUID.Equal(u) {
+				return cln, ch, nil
+			}
+		}
+	}
+	cln.CancelConnection()
+	return nil, nil, fmt.Errorf("characteristic %s not found", u)
+}
+
+// format formats the value b, as a string with the string flag, or else as
+// hex.
+func format(c *cli.Context, b []byte) string {
+	if c.Bool("str") {
+		return string(b)
+	}
+	return hex.EncodeToString(b)
+}
+
+func cmdRead(c *cli.Context) error {
+	ctx, cancel := cmdContext(c)
+	defer cancel()
+	cln, ch, err := findChar(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer cln.CancelConnection()
+	b, err := cln.ReadLongCharacteristic(ch)
+	if err != nil {
+		return fmt.Errorf("can't read characteristic: %v", err)
+	}
+	fmt.Println(format(c, b))
+	return nil
+}
+
+func cmdWrite(c *cli.Context) error {
+	arg := c.Args().First()
+	v := []byte(arg)
+	if !c.Bool("str") {
+		var err error
+		if v, err = hex.DecodeString(strings.Replace(arg, ":", "", -1)); err != nil {
+			return fmt.Errorf("invalid hex value %q", arg)
+		}
+	}
+	ctx, cancel := cmdContext(c)
+	defer cancel()
+	cln, ch, err := findChar(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer cln.CancelConnection()
+	if err := cln.WriteCharacteristic(ch, v, c.Bool("nr")); err != nil {
+		return fmt.Errorf("can't write characteristic: %v", err)
+	}
+	return nil
+}
+
+func cmdSub(c *cli.Context) error {
+	ctx, cancel := cmdContext(c)
+	defer cancel()
+	cln, ch, err := findChar(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer cln.CancelConnection()
+	enc := json.NewEncoder(os.Stdout)
+	h := func(b []byte) {
+		if c.Bool("json") {
+			enc.Encode(map[string]string{"uuid": ch.UUID.String(), "value": format(c, b)})
+			return
+		}
+		fmt.Println(format(c, b))
+	}
+	ind := c.Bool("ind")
+	if err := cln.Subscribe(ch, ind, h); err != nil {
+		return fmt.Errorf("can't subscribe: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-cln.Disconnected():
+		return fmt.Errorf("disconnected")
+	}
+	cln.Unsubscribe(ch, ind)
+	return nil
+}
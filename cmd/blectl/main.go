@@ -0,0 +1,171 @@
+// Blectl is a command-line utility of the library: it scans, explores the
+// GATT profiles of the peripherals, reads, writes, and subscribes to their
+// characteristics, and advertises, one command per run.
+//
+//	blectl scan -t 10s --svc 180d --json
+//	blectl explore -a 11:22:33:44:55:66 --read
+//	blectl read -a 11:22:33:44:55:66 -u 2a19
+//	blectl write -n Sensor -u 6e400002b5a3f393e0a9e50e24dcca9e --str hello
+//	blectl sub -a 11:22:33:44:55:66 -u 2a37
+//	blectl adv --ibeacon 5e0a0001-0000-1000-8000-00805f9b34fb,1,2,-59
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/examples/lib/dev"
+	"github.com/urfave/cli"
+)
+
+var (
+	flgDevice   = cli.StringFlag{Name: "device", Value: "default", Usage: "Implementation of ble"}
+	flgTimeout  = cli.DurationFlag{Name: "tmo, t", Value: 5 * time.Second, Usage: "Timeout for the command, or 0 for none"}
+	flgForever  = cli.DurationFlag{Name: "tmo, t", Usage: "Timeout for the command, or 0 for none"}
+	flgName     = cli.StringFlag{Name: "name, n", Usage: "Name of remote device"}
+	flgAddr     = cli.StringFlag{Name: "addr, a", Usage: "Address of remote device"}
+	flgSvc      = cli.StringFlag{Name: "svc, s", Usage: "Comma-separated UUIDs of services"}
+	flgAllowDup = cli.BoolFlag{Name: "dup", Usage: "Allow duplicate in scanning result"}
+	flgRSSI     = cli.IntFlag{Name: "rssi", Value: -127, Usage: "Minimum RSSI of the advertisements"}
+	flgJSON     = cli.BoolFlag{Name: "json", Usage: "Print JSON, one object per line"}
+	flgUUID     = cli.StringFlag{Name: "uuid, u", Usage: "UUID of the characteristic"}
+	flgInd      = cli.BoolFlag{Name: "ind", Usage: "Indication"}
+	flgRead     = cli.BoolFlag{Name: "read", Usage: "Read the values of the characteristics and descriptors"}
+	flgStr      = cli.BoolFlag{Name: "str", Usage: "Print the values, or take the value, as a string rather than hex"}
+	flgNoRsp    = cli.BoolFlag{Name: "nr", Usage: "Write without response"}
+	flgIBeacon  = cli.StringFlag{Name: "ibeacon", Usage: "Advertise an iBeacon of uuid,major,minor[,power]"}
+	flgMfg      = cli.StringFlag{Name: "mfg", Usage: "Advertise the manufacturer data of id:hex, such as 004c:0215"}
+)
+
+func main() {
+	app := cli.NewApp()
+
+	app.Name = "blectl"
+	app.Usage = "Scan, explore, read, write, subscribe and advertise"
+	app.Flags = []cli.Flag{flgDevice}
+	app.Action = cli.ShowAppHelp
+
+	app.Commands = []cli.Command{
+		{
+			Name:   "scan",
+			Usage:  "Scan for advertisements matching the filters",
+			Before: setup,
+			Action: cmdScan,
+			Flags:  []cli.Flag{flgTimeout, flgName, flgAddr, flgSvc, flgRSSI, flgAllowDup, flgJSON},
+		},
+		{
+			Name:   "explore",
+			Usage:  "Connect, and dump the GATT profile",
+			Before: setup,
+			Action: cmdExplore,
+			Flags:  []cli.Flag{flgTimeout, flgName, flgAddr, flgRead, flgJSON},
+		},
+		{
+			Name:      "read",
+			Usage:     "Read a characteristic",
+			Before:    setup,
+			Action:    cmdRead,
+			Flags:     []cli.Flag{flgTimeout, flgName, flgAddr, flgSvc, flgUUID, flgStr},
+			ArgsUsage: " ",
+		},
+		{
+			Name:      "write",
+			Usage:     "Write a characteristic",
+			Before:    setup,
+			Action:    cmdWrite,
+			Flags:     []cli.Flag{flgTimeout, flgName, flgAddr, flgSvc, flgUUID, flgStr, flgNoRsp},
+			ArgsUsage: "value",
+		},
+		{
+			Name:   "sub",
+			Usage:  "Subscribe to notification (or indication), and print the values",
+			Before: setup,
+			Action: cmdSub,
+			Flags:  []cli.Flag{flgForever, flgName, flgAddr, flgSvc, flgUUID, flgInd, flgStr, flgJSON},
+		},
+		{
+			Name:   "adv",
+			Usage:  "Advertise name and services, an iBeacon, or manufacturer data",
+			Before: setup,
+			Action: cmdAdv,
+			Flags:  []cli.Flag{flgForever, flgName, flgSvc, flgIBeacon, flgMfg},
+		},
+	}
+	app.After = func(c *cli.Context) error {
+		if d := device; d != nil {
+			d.Stop()
+		}
+		return nil
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var device ble.Device
+
+func setup(c *cli.Context) error {
+	d, err := dev.NewDevice(c.GlobalString("device"))
+	if err != nil {
+		return fmt.Errorf("can't new device: %v", err)
+	}
+	ble.SetDefaultDevice(d)
+	device = d
+	return nil
+}
+
+// cmdContext returns the context of the command, done once its timeout
+// passes, or it's interrupted.
+func cmdContext(c *cli.Context) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if d := c.Duration("tmo"); d > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), d)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, cancel
+}
+
+// done tells that err is the end of a command, which runs until its
+// context is done, rather than a failure.
+func done(err error) error {
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return nil
+	}
+	return err
+}
+
+// parseUUIDs parses a comma-separated list of UUIDs.
+func parseUUIDs(s string) ([]ble.UUID, error) {
+	var us []ble.UUID
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		u, err := ble.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UUID %q", f)
+		}
+		us = append(us, u)
+	}
+	return us, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kirbo/ble"
+	"github.com/urfave/cli"
+)
+
+// advJSON is an advertisement, as printed with --json.
+type advJSON struct {
+	Addr             string            `json:"addr"`
+	RSSI             int               `json:"rssi"`
+	Name             string            `json:"name,omitempty"`
+	Connectable      bool              `json:"connectable"`
+	TxPower          int               `json:"txPower,omitempty"`
+	Services         []string          `json:"services,omitempty"`
+	ManufacturerData string            `json:"manufacturerData,omitempty"`
+	ServiceData      map[string]string `json:"serviceData,omitempty"`
+}
+
+func cmdScan(c *cli.Context) error {
+	f, err := filter(c)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := cmdContext(c)
+	defer cancel()
+	enc := json.NewEncoder(os.Stdout)
+	h := func(a ble.Advertisement) {
+		if c.Bool("json") {
+			enc.Encode(toJSON(a))
+			return
+		}
+		fmt.Printf("%s %4d %-3s %q", a.Addr(), a.RSSI(), map[bool]string{true: "C", false: "N"}[a.Connectable()], a.LocalName())
+		if len(a.Services()) > 0 {
+			fmt.Printf(" svcs %v", a.Services())
+		}
+		if len(a.ManufacturerData()) > 0 {
+			fmt.Printf(" mfg %X", a.ManufacturerData())
+		}
+		for _, sd := range a.ServiceData() {
+			fmt.Printf(" sd %s:%X", sd.UUID, sd.Data)
+		}
+		fmt.Println()
+	}
+	return done(ble.Scan(ctx, c.Bool("dup"), h, f))
+}
+
+func toJSON(a ble.Advertisement) advJSON {
+	j := advJSON{
+		Addr:             a.Addr().String(),
+		RSSI:             a.RSSI(),
+		Name:             a.LocalName(),
+		Connectable:      a.Connectable(),
+		TxPower:          a.TxPowerLevel(),
+		ManufacturerData: hex.EncodeToString(a.ManufacturerData()),
+	}
+	for _, u := range a.Services() {
+		j.Services = append(j.Services, u.String())
+	}
+	for _, sd := range a.ServiceData() {
+		if j.ServiceData == nil {
+			j.ServiceData = map[string]string{}
+		}
+		j.ServiceData[sd.UUID.String()] = hex.EncodeToString(sd.Data)
+	}
+	return j
+}
+
+// filter returns the filter of the name, the address, the services and the
+// RSSI flags, all of which the advertisements match.
+func filter(c *cli.Context) (ble.AdvFilter, error) {
+	name := strings.ToLower(c.String("name"))
+	addr := strings.ToLower(c.String("addr"))
+	svcs, err := parseUUIDs(c.String("svc"))
+	if err != nil {
+		return nil, err
+	}
+	rssi := c.Int("rssi")
+	return func(a ble.Advertisement) bool {
+		if name != "" && !strings.Contains(strings.ToLower(a.LocalName()), name) {
+			return false
+		}
+		if addr != "" && strings.ToLower(a.Addr().String()) != addr {
+			return false
+		}
+		if len(svcs) > 0 {
+			found := false
+			for _, u := range a.Services() {
+				found = found || ble.Contains(svcs, u)
+			}
+			if !found {
+				return false
+			}
+		}
+		return a.RSSI() >= rssi
+	}, nil
+}
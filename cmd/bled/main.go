@@ -0,0 +1,67 @@
+// Bled owns the adapter, and serves it to the other processes of the
+// machine over a UNIX socket, so that they share it. The processes connect
+// with bled.Dial, which returns a ble.Device.
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/kirbo/ble/bled"
+	"github.com/kirbo/ble/examples/lib/dev"
+)
+
+var (
+	device = flag.String("device", "default", "implementation of ble")
+	socket = flag.String("socket", bled.DefaultSocket, "path of the socket to listen on")
+	mode   = flag.String("mode", "0660", "permissions of the socket, in octal")
+)
+
+func main() {
+	flag.Parse()
+
+	perm, err := strconv.ParseUint(*mode, 8, 32)
+	if err != nil {
+		log.Fatalf("invalid mode %q: %s", *mode, err)
+	}
+	d, err := dev.NewDevice(*device)
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	defer d.Stop()
+
+	// A socket left by a daemon, which didn't shut down, is replaced.
+	if err := os.Remove(*socket); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("can't remove %s: %s", *socket, err)
+	}
+	l, err := net.Listen("unix", *socket)
+	if err != nil {
+		log.Fatalf("can't listen: %s", err)
+	}
+	if err := os.Chmod(*socket, os.FileMode(perm)); err != nil {
+		log.Fatalf("can't set the mode of %s: %s", *socket, err)
+	}
+
+	// The socket is removed on shutdown, as the device is stopped.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	stopped := make(chan struct{})
+	go func() {
+		<-sigs
+		close(stopped)
+		l.Close()
+	}()
+	log.Printf("serving on %s", *socket)
+	if err := bled.NewServer(d).Serve(l); err != nil {
+		select {
+		case <-stopped:
+		default:
+			log.Printf("can't serve: %s", err)
+		}
+	}
+}
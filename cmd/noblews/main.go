@@ -0,0 +1,34 @@
+// Noblews serves the adapter to the WebSocket bindings of noble, which
+// connect to ws://localhost:2846 once NOBLE_WEBSOCKET is set, so that the
+// JavaScript tooling built on noble drives it.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/kirbo/ble/examples/lib/dev"
+	"github.com/kirbo/ble/noblews"
+)
+
+var (
+	device = flag.String("device", "default", "implementation of ble")
+	addr   = flag.String("addr", "localhost:"+strconv.Itoa(noblews.DefaultPort), "address to listen on")
+)
+
+func main() {
+	flag.Parse()
+
+	d, err := dev.NewDevice(*device)
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	defer d.Stop()
+
+	log.Printf("serving on %s", *addr)
+	if err := http.ListenAndServe(*addr, noblews.NewBridge(d)); err != nil {
+		log.Printf("can't serve: %s", err)
+	}
+}
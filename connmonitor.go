@@ -0,0 +1,41 @@
+package ble
+
+import "time"
+
+// LinkQuality is the quality of a link, as it is polled.
+type LinkQuality struct {
+	RSSI       int        // Received signal strength, in dBm.
+	ChannelMap ChannelMap // Data channels in use.
+	Channels   int        // Number of data channels in use.
+}
+
+// A ConnMonitor receives the telemetry of the health of the connections, set
+// with OptConnMonitor, to detect degrading links before they drop. It is
+// called on goroutines of its own, and must be safe for concurrent use.
+type ConnMonitor interface {
+	// ConnParamsUpdated is called as the parameters of c are updated. [Vol 6, Part B, 5.1.1]
+	ConnParamsUpdated(c Conn, p ConnParams)
+
+	// ChannelSelection is called with the channel selection algorithm of c,
+	// 1 or 2, as it's established. [Vol 6, Part B, 4.5.8]
+	ChannelSelection(c Conn, algorithm int)
+
+	// LinkQuality is called with the quality of c, at every poll.
+	LinkQuality(c Conn, q LinkQuality)
+
+	// SupervisionWarning is called when a packet sent on c hasn't been
+	// acknowledged by the peer for pending, over half the supervision
+	// timeout, after which the link is lost.
+	SupervisionWarning(c Conn, pending time.Duration)
+}
+
+// A ChannelMapMonitor is a ConnMonitor, which is called too as the data
+// channels in use of a connection change, as the link quality is polled,
+// such as once the controller applied the host channel classification. The
+// first poll of a connection tells its initial map.
+type ChannelMapMonitor interface {
+	ConnMonitor
+
+	// ChannelMapChanged is called with the data channels in use of c.
+	ChannelMapChanged(c Conn, m ChannelMap)
+}
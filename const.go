@@ -13,16 +13,22 @@ var (
 	GATTUUID        = UUID16(0x1801) // Generic Attribute
 	CurrentTimeUUID = UUID16(0x1805) // Current Time Service
 	DeviceInfoUUID  = UUID16(0x180A) // Device Information
+	HeartRateUUID   = UUID16(0x180D) // Heart Rate Service
 	BatteryUUID     = UUID16(0x180F) // Battery Service
 	HIDUUID         = UUID16(0x1812) // Human Interface Device
 
+	BondManagementUUID = UUID16(0x181E) // Bond Management Service
+
 	PrimaryServiceUUID   = UUID16(0x2800)
 	SecondaryServiceUUID = UUID16(0x2801)
 	IncludeUUID          = UUID16(0x2802)
 	CharacteristicUUID   = UUID16(0x2803)
 
+	UserDescriptionUUID            = UUID16(0x2901)
 	ClientCharacteristicConfigUUID = UUID16(0x2902)
 	ServerCharacteristicConfigUUID = UUID16(0x2903)
+	PresentationFormatUUID         = UUID16(0x2904)
+	AggregateFormatUUID            = UUID16(0x2905)
 
 	DeviceNameUUID        = UUID16(0x2A00)
 	AppearanceUUID        = UUID16(0x2A01)
@@ -30,4 +36,10 @@ var (
 	ReconnectionAddrUUID  = UUID16(0x2A03)
 	PeferredParamsUUID    = UUID16(0x2A04)
 	ServiceChangedUUID    = UUID16(0x2A05)
+
+	BondManagementControlPointUUID = UUID16(0x2AA4)
+	BondManagementFeatureUUID      = UUID16(0x2AA5)
+
+	DatabaseHashUUID            = UUID16(0x2B2A)
+	ServerSupportedFeaturesUUID = UUID16(0x2B3A)
 )
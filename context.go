@@ -1,5 +1,10 @@
 package ble
 
+import (
+	"context"
+	"time"
+)
+
 // ContextKey is a type used for keys of a context
 type ContextKey string
 
@@ -8,4 +13,153 @@ var (
 	ContextKeySig = ContextKey("sig")
 	// ContextKeyCCC for per connection contexts
 	ContextKeyCCC = ContextKey("ccc")
+	// ContextKeyBondStore for the BondStore of a connection, if any
+	ContextKeyBondStore = ContextKey("bondstore")
+	// ContextKeyGATTCache for the BondStore caching the GATT profile of a connection, if enabled
+	ContextKeyGATTCache = ContextKey("gattcache")
+	// ContextKeyAdvertisement for the Advertisement a peer was discovered with
+	ContextKeyAdvertisement = ContextKey("advertisement")
+	// ContextKeyLogger for the Logger of a connection
+	ContextKeyLogger = ContextKey("logger")
+	// ContextKeyMetrics for the MetricsCollector of a connection
+	ContextKeyMetrics = ContextKey("metrics")
+	// ContextKeyClientTimeout for the timeout of the GATT operations of a connection
+	ContextKeyClientTimeout = ContextKey("clienttimeout")
+	// ContextKeyClock for the Clock of a connection
+	ContextKeyClock = ContextKey("clock")
+	// ContextKeyRetryPolicy for the RetryPolicy of the GATT operations of a connection
+	ContextKeyRetryPolicy = ContextKey("retrypolicy")
+	// ContextKeyAutoSecurity for the SecurityLevel the GATT operations of a connection elevate to
+	ContextKeyAutoSecurity = ContextKey("autosecurity")
+	// ContextKeyConnectTimeout for the timeout of the connection attempt of a Dial
+	ContextKeyConnectTimeout = ContextKey("connecttimeout")
 )
+
+// WithAdvertisement returns a copy of ctx carrying the advertisement a peer
+// was discovered with. Dial uses it to connect to the peer with the address
+// type of the advertisement, so an address obtained elsewhere, such as from
+// NewAddr, can be dialed after scanning for it.
+func WithAdvertisement(ctx context.Context, a Advertisement) context.Context {
+	return context.WithValue(ctx, ContextKeyAdvertisement, a)
+}
+
+// WithClientTimeout returns a copy of ctx, which makes Dial return a Client
+// whose GATT operations time out after d, in place of the 30s ATT transaction
+// timeout. It applies to every operation of the Client, which has no deadline
+// of its own. It is honored by the linux backend.
+func WithClientTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, ContextKeyClientTimeout, d)
+}
+
+// WithRequestTimeout is WithClientTimeout: each ATT request of the Client
+// times out after d.
+func WithRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return WithClientTimeout(ctx, d)
+}
+
+// WithConnectTimeout returns a copy of ctx, which makes Dial cancel its
+// connection attempt, unless connected after d. Unlike the deadline of ctx,
+// d starts once the Dial has taken its turn, as the Dials of a device are
+// queued, and only one connection is initiated at a time. It is honored by
+// the linux backend.
+func WithConnectTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, ContextKeyConnectTimeout, d)
+}
+
+// ConnectTimeoutFromContext returns the timeout attached to ctx by
+// WithConnectTimeout, if any.
+func ConnectTimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(ContextKeyConnectTimeout).(time.Duration)
+	return d, ok && d > 0
+}
+
+// WithRetry returns a copy of ctx, which makes Dial return a Client whose ATT
+// requests are retried as told by p, when they fail. It is honored by the
+// linux backend.
+func WithRetry(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, ContextKeyRetryPolicy, p)
+}
+
+// RetryPolicyFromContext returns the RetryPolicy attached to ctx by
+// WithRetry, if any.
+func RetryPolicyFromContext(ctx context.Context) (RetryPolicy, bool) {
+	p, ok := ctx.Value(ContextKeyRetryPolicy).(RetryPolicy)
+	return p, ok
+}
+
+// ClientTimeoutFromContext returns the timeout attached to ctx by
+// WithClientTimeout, if any.
+func ClientTimeoutFromContext(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(ContextKeyClientTimeout).(time.Duration)
+	return d, ok && d > 0
+}
+
+// AdvertisementFromContext returns the advertisement attached to ctx by
+// WithAdvertisement, if any.
+func AdvertisementFromContext(ctx context.Context) (Advertisement, bool) {
+	a, ok := ctx.Value(ContextKeyAdvertisement).(Advertisement)
+	return a, ok && a != nil
+}
+
+// SigHandlerFromContext returns the cancel function attached to ctx by
+// WithSigHandler, if any.
+func SigHandlerFromContext(ctx context.Context) (func(), bool) {
+	cancel, ok := ctx.Value(ContextKeySig).(func())
+	return cancel, ok && cancel != nil
+}
+
+// BondStoreFromContext returns the BondStore of the connection whose context
+// is ctx, if any.
+func BondStoreFromContext(ctx context.Context) (BondStore, bool) {
+	s, ok := ctx.Value(ContextKeyBondStore).(BondStore)
+	return s, ok && s != nil
+}
+
+// GATTCacheFromContext returns the BondStore caching the GATT profile of the
+// connection whose context is ctx, if enabled.
+func GATTCacheFromContext(ctx context.Context) (BondStore, bool) {
+	s, ok := ctx.Value(ContextKeyGATTCache).(BondStore)
+	return s, ok && s != nil
+}
+
+// AutoSecurityFromContext returns the SecurityLevel, which the GATT
+// operations of the connection whose context is ctx elevate the link to,
+// once refused for its security, if set.
+func AutoSecurityFromContext(ctx context.Context) (SecurityLevel, bool) {
+	l, ok := ctx.Value(ContextKeyAutoSecurity).(SecurityLevel)
+	return l, ok && l > SecurityNone
+}
+
+// LoggerFromContext returns the Logger of the connection whose context is
+// ctx, or DefaultLogger.
+func LoggerFromContext(ctx context.Context) Logger {
+	if l, ok := ctx.Value(ContextKeyLogger).(Logger); ok && l != nil {
+		return l
+	}
+	return DefaultLogger
+}
+
+// MetricsFromContext returns the MetricsCollector of the connection whose
+// context is ctx, or NopMetrics.
+func MetricsFromContext(ctx context.Context) MetricsCollector {
+	if m, ok := ctx.Value(ContextKeyMetrics).(MetricsCollector); ok && m != nil {
+		return m
+	}
+	return NopMetrics
+}
+
+// WithClock returns a copy of ctx carrying the Clock, which times the
+// operations given ctx, such as the backoffs of Connector.Run, and the
+// dialing and the scanning of the mock devices.
+func WithClock(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, ContextKeyClock, c)
+}
+
+// ClockFromContext returns the Clock attached to ctx by WithClock, or of the
+// connection whose context is ctx, or SystemClock.
+func ClockFromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(ContextKeyClock).(Clock); ok && c != nil {
+		return c
+	}
+	return SystemClock
+}
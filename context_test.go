@@ -0,0 +1,62 @@
+package ble_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestClientTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stall := make(chan struct{})
+	defer close(stall)
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		<-stall
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ble.WithClientTimeout(ctx, 100*time.Millisecond))
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	start := time.Now()
+	if _, err := cln.ReadCharacteristic(rc); err == nil {
+		t.Fatal("read of a stalled characteristic succeeded")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("read timed out after %s, want 100ms", d)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The server runs out of resources for the first two reads of each three.
+	var reads int32
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		if atomic.AddInt32(&reads, 1)%3 != 0 {
+			rsp.SetStatus(ble.ErrInsuffResources)
+			return
+		}
+		rsp.Write([]byte("hello"))
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ble.WithRetry(ctx, ble.RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}))
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	if v, err := cln.ReadCharacteristic(rc); err != nil || string(v) != "hello" {
+		t.Errorf("ReadCharacteristic() = %q, %v, want hello after 2 retries", v, err)
+	}
+	if n := atomic.LoadInt32(&reads); n != 3 {
+		t.Errorf("%d reads, want 3", n)
+	}
+}
@@ -0,0 +1,113 @@
+package ble
+
+import "strings"
+
+// LEFeatures are the LE Supported Features of a controller, a bit each, as
+// the Features of a RemoteInfo are of a link. [Vol 6, Part B, 4.6]
+type LEFeatures uint64
+
+// LE features.
+const (
+	LEFeatureEncryption             LEFeatures = 1 << 0
+	LEFeatureConnParamsRequest      LEFeatures = 1 << 1
+	LEFeatureExtRejectIndication    LEFeatures = 1 << 2
+	LEFeaturePeripheralFeatures     LEFeatures = 1 << 3
+	LEFeaturePing                   LEFeatures = 1 << 4
+	LEFeatureDataLengthExtension    LEFeatures = 1 << 5
+	LEFeaturePrivacy                LEFeatures = 1 << 6
+	LEFeatureExtScanFilterPolicies  LEFeatures = 1 << 7
+	LEFeature2MPHY                  LEFeatures = 1 << 8
+	LEFeatureStableModulationTx     LEFeatures = 1 << 9
+	LEFeatureStableModulationRx     LEFeatures = 1 << 10
+	LEFeatureCodedPHY               LEFeatures = 1 << 11
+	LEFeatureExtAdvertising         LEFeatures = 1 << 12
+	LEFeaturePeriodicAdvertising    LEFeatures = 1 << 13
+	LEFeatureChannelSelection2      LEFeatures = 1 << 14
+	LEFeaturePowerClass1            LEFeatures = 1 << 15
+	LEFeatureMinUsedChannels        LEFeatures = 1 << 16
+	LEFeatureCISCentral             LEFeatures = 1 << 28
+	LEFeatureCISPeripheral          LEFeatures = 1 << 29
+	LEFeatureISOBroadcaster         LEFeatures = 1 << 30
+	LEFeatureSyncReceiver           LEFeatures = 1 << 31
+	LEFeaturePowerControlRequest    LEFeatures = 1 << 33
+	LEFeaturePathLossMonitoring     LEFeatures = 1 << 35
+	LEFeaturePeriodicAdvADI         LEFeatures = 1 << 36
+	LEFeatureConnSubrating          LEFeatures = 1 << 37
+	LEFeatureChannelClassification  LEFeatures = 1 << 39
+	LEFeatureAdvCodingSelection     LEFeatures = 1 << 40
+	LEFeaturePeriodicAdvResponses   LEFeatures = 1 << 43
+	LEFeatureUnsegmentedFramedMode  LEFeatures = 1 << 44
+	LEFeatureChannelSounding        LEFeatures = 1 << 46
+	LEFeatureDecisionBasedFiltering LEFeatures = 1 << 47
+)
+
+var leFeatureNames = []struct {
+	f    LEFeatures
+	name string
+}{
+	{LEFeatureEncryption, "Encryption"},
+	{LEFeatureConnParamsRequest, "ConnParamsRequest"},
+	{LEFeatureExtRejectIndication, "ExtRejectIndication"},
+	{LEFeaturePeripheralFeatures, "PeripheralFeatures"},
+	{LEFeaturePing, "Ping"},
+	{LEFeatureDataLengthExtension, "DataLengthExtension"},
+	{LEFeaturePrivacy, "Privacy"},
+	{LEFeatureExtScanFilterPolicies, "ExtScanFilterPolicies"},
+	{LEFeature2MPHY, "2MPHY"},
+	{LEFeatureStableModulationTx, "StableModulationTx"},
+	{LEFeatureStableModulationRx, "StableModulationRx"},
+	{LEFeatureCodedPHY, "CodedPHY"},
+	{LEFeatureExtAdvertising, "ExtAdvertising"},
+	{LEFeaturePeriodicAdvertising, "PeriodicAdvertising"},
+	{LEFeatureChannelSelection2, "ChannelSelection2"},
+	{LEFeaturePowerClass1, "PowerClass1"},
+	{LEFeatureMinUsedChannels, "MinUsedChannels"},
+	{LEFeatureCISCentral, "CISCentral"},
+	{LEFeatureCISPeripheral, "CISPeripheral"},
+	{LEFeatureISOBroadcaster, "ISOBroadcaster"},
+	{LEFeatureSyncReceiver, "SyncReceiver"},
+	{LEFeaturePowerControlRequest, "PowerControlRequest"},
+	{LEFeaturePathLossMonitoring, "PathLossMonitoring"},
+	{LEFeaturePeriodicAdvADI, "PeriodicAdvADI"},
+	{LEFeatureConnSubrating, "ConnSubrating"},
+	{LEFeatureChannelClassification, "ChannelClassification"},
+	{LEFeatureAdvCodingSelection, "AdvCodingSelection"},
+	{LEFeaturePeriodicAdvResponses, "PeriodicAdvResponses"},
+	{LEFeatureUnsegmentedFramedMode, "UnsegmentedFramedMode"},
+	{LEFeatureChannelSounding, "ChannelSounding"},
+	{LEFeatureDecisionBasedFiltering, "DecisionBasedFiltering"},
+}
+
+// Has reports whether all the features of f are supported.
+func (fs LEFeatures) Has(f LEFeatures) bool { return fs&f == f }
+
+// Names returns the names of the known features.
+func (fs LEFeatures) Names() []string {
+	var ns []string
+	for _, n := range leFeatureNames {
+		if fs&n.f != 0 {
+			ns = append(ns, n.name)
+		}
+	}
+	return ns
+}
+
+func (fs LEFeatures) String() string { return strings.Join(fs.Names(), "|") }
+
+// ControllerInfo is what a controller supports, as read from it, so the
+// applications can tell what they may do, rather than fail doing it.
+type ControllerInfo struct {
+	HCIVersion   uint8  // HCI version; 0x06 for 4.0, up to 0x0D for 5.4.
+	LMPVersion   uint8  // Link Layer version, as HCIVersion.
+	Manufacturer uint16 // Company identifier of the manufacturer.
+
+	LEFeatures LEFeatures // LE features, as used, short of those disabled by quirks.
+
+	MaxAdvDataLength int // Longest advertising data, or scan response; 31 without extended advertising.
+	AdvSets          int // Advertising sets supported, or 0 without extended advertising.
+
+	ACLBufferSize  int // Size of the ACL data packets the controller buffers.
+	ACLBufferCount int // Number of ACL data packets the controller buffers.
+
+	AcceptListSize int // Entries of the Filter Accept List, formerly the White List.
+}
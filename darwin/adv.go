@@ -1,6 +1,8 @@
 package darwin
 
 import (
+	"time"
+
 	"github.com/kirbo/ble"
 	"github.com/raff/goble/xpc"
 )
@@ -24,12 +26,14 @@ func (a *adv) ServiceData() []ble.ServiceData {
 		return nil
 	}
 
+	// Pairs of a UUID, in big endian as the others CoreBluetooth passes, and
+	// the data.
 	xSD := xSDs.(xpc.Array)
 	var sd []ble.ServiceData
-	for i := 0; i < len(xSD); i += 2 {
+	for i := 0; i+1 < len(xSD); i += 2 {
 		sd = append(
 			sd, ble.ServiceData{
-				UUID: ble.UUID(xSD[i].([]byte)),
+				UUID: ble.UUID(ble.Reverse(xSD[i].([]byte))),
 				Data: xSD[i+1].([]byte),
 			})
 	}
@@ -37,7 +41,27 @@ func (a *adv) ServiceData() []ble.ServiceData {
 }
 
 func (a *adv) Services() []ble.UUID {
-	xUUIDs, ok := a.ad["kCBAdvDataServiceUUIDs"]
+	return a.uuids("kCBAdvDataServiceUUIDs")
+}
+
+// OverflowService returns the UUIDs of the overflow area of the
+// advertisements of iOS apps in the background.
+func (a *adv) OverflowService() []ble.UUID {
+	return a.uuids("kCBAdvDataOverflowServiceUUIDs")
+}
+
+func (a *adv) TxPowerLevel() int {
+	return a.ad.GetInt("kCBAdvDataTxPowerLevel", 0)
+}
+
+func (a *adv) SolicitedService() []ble.UUID {
+	return a.uuids("kCBAdvDataSolicitedServiceUUIDs")
+}
+
+// uuids returns the UUIDs of the array k of the advertisement data, which
+// CoreBluetooth passes in big endian.
+func (a *adv) uuids(k string) []ble.UUID {
+	xUUIDs, ok := a.ad[k]
 	if !ok {
 		return nil
 	}
@@ -48,16 +72,35 @@ func (a *adv) Services() []ble.UUID {
 	return uuids
 }
 
-func (a *adv) OverflowService() []ble.UUID {
-	return nil // TODO
+// URI is not supported; CoreBluetooth doesn't pass the field along.
+func (a *adv) URI() string {
+	return ""
 }
 
-func (a *adv) TxPowerLevel() int {
-	return a.ad.GetInt("kCBAdvDataTxPowerLevel", 0)
+// Appearance is not supported; CoreBluetooth doesn't pass the field along.
+func (a *adv) Appearance() uint16 {
+	return 0
 }
 
-func (a *adv) SolicitedService() []ble.UUID {
-	return nil // TODO
+// AdvInterval is not supported; CoreBluetooth doesn't pass the field along.
+func (a *adv) AdvInterval() time.Duration {
+	return 0
+}
+
+// LERole is not supported; CoreBluetooth doesn't pass the field along.
+func (a *adv) LERole() ble.LERole {
+	return ble.LERoleUnknown
+}
+
+// PublicTargetAddrs is not supported; CoreBluetooth doesn't pass the field
+// along.
+func (a *adv) PublicTargetAddrs() []ble.Addr {
+	return nil
+}
+
+// Records is not supported; CoreBluetooth only passes the parsed fields.
+func (a *adv) Records() []ble.ADStructure {
+	return nil
 }
 
 func (a *adv) Connectable() bool {
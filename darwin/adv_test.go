@@ -0,0 +1,57 @@
+package darwin
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/advtest"
+	"github.com/raff/goble/xpc"
+)
+
+// advOf returns the adv of c, with the advertisement data as CoreBluetooth
+// passes it.
+func advOf(c advtest.Case) *adv {
+	be := func(us []ble.UUID) xpc.Array {
+		var a xpc.Array
+		for _, u := range us {
+			a = append(a, ble.Reverse(u))
+		}
+		return a
+	}
+	ad := xpc.Dict{}
+	if c.LocalName != "" {
+		ad["kCBAdvDataLocalName"] = c.LocalName
+	}
+	if c.ManufacturerData != nil {
+		ad["kCBAdvDataManufacturerData"] = c.ManufacturerData
+	}
+	if c.TxPowerLevel != 0 {
+		ad["kCBAdvDataTxPowerLevel"] = int64(c.TxPowerLevel)
+	}
+	if c.Services != nil {
+		ad["kCBAdvDataServiceUUIDs"] = be(c.Services)
+	}
+	if c.Solicited != nil {
+		ad["kCBAdvDataSolicitedServiceUUIDs"] = be(c.Solicited)
+	}
+	if c.ServiceData != nil {
+		var sd xpc.Array
+		for _, d := range c.ServiceData {
+			sd = append(sd, ble.Reverse(d.UUID), d.Data)
+		}
+		ad["kCBAdvDataServiceData"] = sd
+	}
+	var conn int64
+	if c.Connectable {
+		conn = 1
+	}
+	ad["kCBAdvDataIsConnectable"] = conn
+	args := xpc.Dict{"kCBMsgArgRssi": int64(c.RSSI)}
+	return &adv{args: args, ad: ad}
+}
+
+func TestAdvConformance(t *testing.T) {
+	for _, c := range advtest.Cases {
+		advtest.Check(t, c, advOf(c))
+	}
+}
@@ -1,6 +1,7 @@
 package darwin
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/kirbo/ble"
@@ -45,6 +46,7 @@ func (cln *Client) DiscoverProfile(force bool) (*ble.Profile, error) {
 	if cln.profile != nil && !force {
 		return cln.profile, nil
 	}
+	cln.profile = nil
 	ss, err := cln.DiscoverServices(nil)
 	if err != nil {
 		return nil, fmt.Errorf("can't discover services: %s", err)
@@ -78,21 +80,64 @@ func (cln *Client) DiscoverServices(ss []ble.UUID) ([]*ble.Service, error) {
 	if err := rsp.err(); err != nil {
 		return nil, err
 	}
+	// Profile accumulates partial discoveries, as on Linux.
+	if cln.profile == nil {
+		cln.profile = &ble.Profile{}
+	}
 	svcs := []*ble.Service{}
 	for _, xss := range rsp.services() {
 		xs := msg(xss.(xpc.Dict))
-		svcs = append(svcs, &ble.Service{
+		svcs = append(svcs, mergeService(cln.profile, &ble.Service{
 			UUID:      ble.MustParse(xs.uuid()),
 			Handle:    uint16(xs.serviceStartHandle()),
 			EndHandle: uint16(xs.serviceEndHandle()),
-		})
-	}
-	if cln.profile == nil {
-		cln.profile = &ble.Profile{Services: svcs}
+		}))
 	}
 	return svcs, nil
 }
 
+// mergeService adds the service s to the profile p, and returns it, unless
+// p has it already, discovered before with the same handle and UUID. The one
+// of p is returned then, along with the characteristics discovered of it.
+func mergeService(p *ble.Profile, s *ble.Service) *ble.Service {
+	for _, ps := range p.Services {
+		if ps.Handle == s.Handle && ps.UUID.Equal(s.UUID) {
+			ps.EndHandle = s.EndHandle
+			return ps
+		}
+	}
+	p.Services = append(p.Services, s)
+	return s
+}
+
+// mergeCharacteristic adds the characteristic c to the service s, and
+// returns it, unless s has it already, discovered before with the same value
+// handle. The one of s is returned then, along with the descriptors
+// discovered of it.
+func mergeCharacteristic(s *ble.Service, c *ble.Characteristic) *ble.Characteristic {
+	for _, sc := range s.Characteristics {
+		if sc.ValueHandle == c.ValueHandle {
+			sc.UUID, sc.Property, sc.Handle = c.UUID, c.Property, c.Handle
+			return sc
+		}
+	}
+	s.Characteristics = append(s.Characteristics, c)
+	return c
+}
+
+// mergeDescriptor adds the descriptor d to the characteristic c, and returns
+// it, unless c has it already, discovered before with the same handle.
+func mergeDescriptor(c *ble.Characteristic, d *ble.Descriptor) *ble.Descriptor {
+	for _, cd := range c.Descriptors {
+		if cd.Handle == d.Handle {
+			cd.UUID = d.UUID
+			return cd
+		}
+	}
+	c.Descriptors = append(c.Descriptors, d)
+	return d
+}
+
 // DiscoverIncludedServices finds the included services of a service. [Vol 3, Part G, 4.5.1]
 // If filter is specified, only filtered services are returned.
 func (cln *Client) DiscoverIncludedServices(ss []ble.UUID, s *ble.Service) ([]*ble.Service, error) {
@@ -112,7 +157,8 @@ func (cln *Client) DiscoverIncludedServices(ss []ble.UUID, s *ble.Service) ([]*b
 }
 
 // DiscoverCharacteristics finds all the characteristics within a service. [Vol 3, Part G, 4.6.1]
-// If filter is specified, only filtered characteristics are returned.
+// If filter is specified, only filtered characteristics are returned. They are
+// merged into s by their value handles, so that discoveries may be repeated.
 func (cln *Client) DiscoverCharacteristics(cs []ble.UUID, s *ble.Service) ([]*ble.Characteristic, error) {
 	rsp, err := cln.conn.sendReq(cmdDiscoverCharacteristics, xpc.Dict{
 		"kCBMsgArgDeviceUUID":         cln.id,
@@ -126,20 +172,22 @@ func (cln *Client) DiscoverCharacteristics(cs []ble.UUID, s *ble.Service) ([]*bl
 	if err := rsp.err(); err != nil {
 		return nil, err
 	}
+	var chars []*ble.Characteristic
 	for _, xcs := range rsp.characteristics() {
 		xc := msg(xcs.(xpc.Dict))
-		s.Characteristics = append(s.Characteristics, &ble.Characteristic{
+		chars = append(chars, mergeCharacteristic(s, &ble.Characteristic{
 			UUID:        ble.MustParse(xc.uuid()),
 			Property:    ble.Property(xc.characteristicProperties()),
 			Handle:      uint16(xc.characteristicHandle()),
 			ValueHandle: uint16(xc.characteristicValueHandle()),
-		})
+		}))
 	}
-	return s.Characteristics, nil
+	return chars, nil
 }
 
 // DiscoverDescriptors finds all the descriptors within a characteristic. [Vol 3, Part G, 4.7.1]
-// If filter is specified, only filtered descriptors are returned.
+// If filter is specified, only filtered descriptors are returned. They are
+// merged into c by their handles, so that discoveries may be repeated.
 func (cln *Client) DiscoverDescriptors(ds []ble.UUID, c *ble.Characteristic) ([]*ble.Descriptor, error) {
 	rsp, err := cln.conn.sendReq(cmdDiscoverDescriptors, xpc.Dict{
 		"kCBMsgArgDeviceUUID":                cln.id,
@@ -153,14 +201,23 @@ func (cln *Client) DiscoverDescriptors(ds []ble.UUID, c *ble.Characteristic) ([]
 	if err := rsp.err(); err != nil {
 		return nil, err
 	}
+	var descs []*ble.Descriptor
 	for _, xds := range rsp.descriptors() {
 		xd := msg(xds.(xpc.Dict))
-		c.Descriptors = append(c.Descriptors, &ble.Descriptor{
+		descs = append(descs, mergeDescriptor(c, &ble.Descriptor{
 			UUID:   ble.MustParse(xd.uuid()),
 			Handle: uint16(xd.descriptorHandle()),
-		})
+		}))
 	}
-	return c.Descriptors, nil
+	return descs, nil
+}
+
+// Characteristics returns all the discovered characteristics with the UUID, in handle order.
+func (cln *Client) Characteristics(u ble.UUID) []*ble.Characteristic {
+	if cln.profile == nil {
+		return nil
+	}
+	return cln.profile.FindCharacteristics(u)
 }
 
 // ReadCharacteristic reads a characteristic value from a server. [Vol 3, Part G, 4.8.1]
@@ -180,6 +237,62 @@ func (cln *Client) ReadCharacteristic(c *ble.Characteristic) ([]byte, error) {
 	return rsp.data(), nil
 }
 
+// ReadCharacteristicByUUID reads the value of the first characteristic with UUID chr,
+// within the first service with UUID svc, or anywhere if svc is nil. CoreBluetooth
+// reads discovered characteristics only, so they are looked up in the discovered
+// profile, or else discovered by their UUIDs.
+func (cln *Client) ReadCharacteristicByUUID(svc, chr ble.UUID) ([]byte, error) {
+	if c := cln.discoveredCharacteristic(svc, chr); c != nil {
+		return cln.ReadCharacteristic(c)
+	}
+	var filter []ble.UUID
+	if svc != nil {
+		filter = []ble.UUID{svc}
+	}
+	ss, err := cln.DiscoverServices(filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range ss {
+		cs, err := cln.DiscoverCharacteristics([]ble.UUID{chr}, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range cs {
+			if c.UUID.Equal(chr) {
+				return cln.ReadCharacteristic(c)
+			}
+		}
+		if svc != nil {
+			break
+		}
+	}
+	return nil, ble.ErrAttrNotFound
+}
+
+// discoveredCharacteristic returns the first characteristic with UUID chr of
+// the discovered profile, within the first service with UUID svc, or
+// anywhere if svc is nil, or nil if it hasn't been discovered.
+func (cln *Client) discoveredCharacteristic(svc, chr ble.UUID) *ble.Characteristic {
+	if cln.profile == nil {
+		return nil
+	}
+	for _, s := range cln.profile.Services {
+		if svc != nil && !s.UUID.Equal(svc) {
+			continue
+		}
+		for _, c := range s.Characteristics {
+			if c.UUID.Equal(chr) {
+				return c
+			}
+		}
+		if svc != nil {
+			break
+		}
+	}
+	return nil
+}
+
 // ReadLongCharacteristic reads a characteristic value which is longer than the MTU. [Vol 3, Part G, 4.8.3]
 func (cln *Client) ReadLongCharacteristic(c *ble.Characteristic) ([]byte, error) {
 	return nil, ble.ErrNotImplemented
@@ -204,6 +317,26 @@ func (cln *Client) WriteCharacteristic(c *ble.Characteristic, b []byte, noRsp bo
 	return m.err()
 }
 
+// WriteLongCharacteristic writes a characteristic value which is longer than the MTU.
+// CoreBluetooth splits long writes itself, but doesn't expose reliable writes.
+func (cln *Client) WriteLongCharacteristic(c *ble.Characteristic, b []byte, reliable bool) error {
+	if reliable {
+		return ble.ErrNotImplemented
+	}
+	return cln.WriteCharacteristic(c, b, false)
+}
+
+// RawATT is not supported, as CoreBluetooth doesn't expose the ATT bearer.
+func (cln *Client) RawATT(ctx context.Context, pdu []byte) ([]byte, error) {
+	return nil, ble.ErrNotImplemented
+}
+
+// Flush returns at once, as CoreBluetooth queues the writes without response
+// itself, and doesn't tell when they are sent.
+func (cln *Client) Flush(ctx context.Context) error {
+	return nil
+}
+
 // ReadDescriptor reads a characteristic descriptor from a server. [Vol 3, Part G, 4.12.1]
 func (cln *Client) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
 	rsp, err := cln.conn.sendReq(cmdReadDescriptor, xpc.Dict{
@@ -245,6 +378,27 @@ func (cln *Client) ReadRSSI() int {
 	return rsp.rssi()
 }
 
+// Security returns SecurityUnknown; CoreBluetooth doesn't expose the
+// security of the link.
+func (cln *Client) Security() ble.Security {
+	return ble.Security{}
+}
+
+// Encrypted isn't known, as CoreBluetooth doesn't tell.
+func (cln *Client) Encrypted() bool {
+	return false
+}
+
+// SecurityLevel is SecurityUnknown, as CoreBluetooth doesn't tell.
+func (cln *Client) SecurityLevel() ble.SecurityLevel {
+	return ble.SecurityUnknown
+}
+
+// RemoteInfo isn't known, as CoreBluetooth doesn't tell.
+func (cln *Client) RemoteInfo() ble.RemoteInfo {
+	return ble.RemoteInfo{}
+}
+
 // ExchangeMTU set the ATT_MTU to the maximum possible value that can be
 // supported by both devices [Vol 3, Part G, 4.3.1]
 func (cln *Client) ExchangeMTU(mtu int) (int, error) {
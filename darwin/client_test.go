@@ -0,0 +1,68 @@
+package darwin
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+)
+
+func TestMergeDiscoveries(t *testing.T) {
+	svcUUID, chrUUID, otherUUID := ble.UUID16(0x180F), ble.UUID16(0x2A19), ble.UUID16(0x2A1A)
+
+	// A filtered discovery, followed by a full one, twice over.
+	p := &ble.Profile{}
+	for i := 0; i < 2; i++ {
+		s := mergeService(p, &ble.Service{UUID: svcUUID, Handle: 0x10, EndHandle: 0x1F})
+		cs := []*ble.Characteristic{mergeCharacteristic(s, &ble.Characteristic{UUID: chrUUID, Handle: 0x11, ValueHandle: 0x12})}
+		// The other characteristic is in the service after the first full
+		// discovery.
+		if len(cs) != 1 || len(s.Characteristics) != i+1 {
+			t.Fatalf("filtered discovery %d: %d characteristics, %d in the service", i, len(cs), len(s.Characteristics))
+		}
+		cs = []*ble.Characteristic{
+			mergeCharacteristic(s, &ble.Characteristic{UUID: chrUUID, Handle: 0x11, ValueHandle: 0x12}),
+			mergeCharacteristic(s, &ble.Characteristic{UUID: otherUUID, Handle: 0x14, ValueHandle: 0x15}),
+		}
+		if cs[0] != s.Characteristics[0] {
+			t.Errorf("discovery %d: the characteristic discovered before isn't kept", i)
+		}
+		for _, c := range cs {
+			mergeDescriptor(c, &ble.Descriptor{UUID: ble.ClientCharacteristicConfigUUID, Handle: c.ValueHandle + 1})
+		}
+	}
+	if len(p.Services) != 1 {
+		t.Fatalf("%d services, want 1", len(p.Services))
+	}
+	s := p.Services[0]
+	if len(s.Characteristics) != 2 {
+		t.Fatalf("%d characteristics, want 2", len(s.Characteristics))
+	}
+	for _, c := range s.Characteristics {
+		if len(c.Descriptors) != 1 {
+			t.Errorf("characteristic %s: %d descriptors, want 1", c.UUID, len(c.Descriptors))
+		}
+	}
+}
+
+func TestDiscoveredCharacteristic(t *testing.T) {
+	svcUUID, chrUUID := ble.UUID16(0x180F), ble.UUID16(0x2A19)
+	cln := &Client{}
+	if c := cln.discoveredCharacteristic(svcUUID, chrUUID); c != nil {
+		t.Errorf("found %v before the discovery", c)
+	}
+
+	// The characteristic is in the second service of the UUID only, after a
+	// service of another UUID.
+	c := &ble.Characteristic{UUID: chrUUID, ValueHandle: 0x22}
+	cln.profile = &ble.Profile{Services: []*ble.Service{
+		{UUID: ble.UUID16(0x180A), Handle: 0x01},
+		{UUID: svcUUID, Handle: 0x10},
+		{UUID: svcUUID, Handle: 0x20, Characteristics: []*ble.Characteristic{c}},
+	}}
+	if got := cln.discoveredCharacteristic(svcUUID, chrUUID); got != nil {
+		t.Errorf("found %v out of the first service", got)
+	}
+	if got := cln.discoveredCharacteristic(nil, chrUUID); got != c {
+		t.Errorf("discoveredCharacteristic(nil) = %v, want %v", got, c)
+	}
+}
@@ -2,6 +2,7 @@ package darwin
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 
@@ -134,8 +135,14 @@ func (c *conn) sendReq(id int, args xpc.Dict) (msg, error) {
 	if err != nil {
 		return msg{}, err
 	}
-	m := <-c.rspc
-	return msg(m.args()), nil
+	select {
+	case m := <-c.rspc:
+		return msg(m.args()), nil
+	case <-c.done:
+		return msg{}, fmt.Errorf("request failed: %w", ble.ErrDisconnected)
+	case <-c.dev.chDone:
+		return msg{}, ErrStopped
+	}
 }
 
 func (c *conn) sendCmd(id int, args xpc.Dict) error {
@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/kirbo/ble"
@@ -32,14 +33,49 @@ type Device struct {
 	advHandler ble.AdvHandler
 	chConn     chan *conn
 
+	// filterDup overrides the allowDup argument of Scan if filterDupSet.
+	filterDupSet bool
+	filterDup    bool
+
 	connectedHandler    func(evt.LEConnectionComplete)
 	disconnectedHandler func(evt.DisconnectionComplete)
 
 	// Only used in server/peripheralManager implementation
 	chars map[int]*ble.Characteristic
 	base  int
+
+	// scanResp is merged into the advertising data, if set.
+	scanResp *scanResp
+	srLock   sync.Mutex
+
+	// advData is what was last advertised, which StartAdvertising advertises
+	// again.
+	advData xpc.Dict
+	advLock sync.Mutex
+
+	// The state changes go to stateHandler, but for the one which answers
+	// the initialization of a manager, while awaitState is 1. state is the
+	// last state reported, as both managers report it.
+	stateHandler func(ble.AdapterState)
+	awaitState   int32
+	state        int32
+
+	// restoreID is the restore identifier of the managers, and restoreHandler
+	// is given their restored state.
+	restoreID      string
+	restoreHandler func(ble.RestoredState)
+
+	// chDone is closed by Stop, which tears the XPC connections down once.
+	chDone   chan struct{}
+	stopOnce sync.Once
 }
 
+// ErrStopped is returned by the requests of a device, which has been stopped.
+var ErrStopped = errors.New("device stopped")
+
+// stopTimeout bounds the disconnection of the connections by Stop.
+const stopTimeout = 5 * time.Second
+
 // NewDevice returns a BLE device.
 func NewDevice(opts ...ble.Option) (*Device, error) {
 	err := initXpcIDs()
@@ -53,6 +89,8 @@ func NewDevice(opts ...ble.Option) (*Device, error) {
 		chConn: make(chan *conn),
 		chars:  make(map[int]*ble.Characteristic),
 		base:   1,
+		state:  -1,
+		chDone: make(chan struct{}),
 	}
 	if err := d.Option(opts...); err != nil {
 		return nil, err
@@ -61,7 +99,11 @@ func NewDevice(opts ...ble.Option) (*Device, error) {
 	d.pm = xpc.XpcConnect(serviceID, d)
 	d.cm = xpc.XpcConnect(serviceID, d)
 
-	return d, errors.Wrap(d.Init(), "can't init")
+	if err := d.Init(); err != nil {
+		d.Stop()
+		return nil, fmt.Errorf("can't init: %w", err)
+	}
+	return d, nil
 }
 
 // Option sets the options specified.
@@ -75,45 +117,45 @@ func (d *Device) Option(opts ...ble.Option) error {
 
 // Init ...
 func (d *Device) Init() error {
-	rsp, err := d.sendReq(d.cm, cmdInit, xpc.Dict{
-		"kCBMsgArgName": fmt.Sprintf("gopher-%v", time.Now().Unix()),
-		"kCBMsgArgOptions": xpc.Dict{
-			"kCBInitOptionShowPowerAlert": 1,
-		},
-		"kCBMsgArgType": 0,
-	})
-	if err != nil {
+	if err := d.initManager(d.cm, 0); err != nil {
 		return err
 	}
-	s := State(rsp.state())
-	if s != StatePoweredOn {
-		return fmt.Errorf("state: %s", s)
-	}
+	return d.initManager(d.pm, 1)
+}
 
-	rsp, err = d.sendReq(d.pm, cmdInit, xpc.Dict{
-		"kCBMsgArgName": fmt.Sprintf("gopher-%v", time.Now().Unix()),
-		"kCBMsgArgOptions": xpc.Dict{
-			"kCBInitOptionShowPowerAlert": 1,
-		},
-		"kCBMsgArgType": 1,
+// initManager initializes the centralManager (0) or the peripheralManager (1).
+// It fails unless the adapter is powered on, or a state handler waits for it.
+func (d *Device) initManager(x xpc.XPC, typ int) error {
+	opts := xpc.Dict{
+		"kCBInitOptionShowPowerAlert": 1,
+	}
+	if d.restoreID != "" {
+		opts["kCBInitOptionRestoreIdentifier"] = d.restoreID
+	}
+	atomic.StoreInt32(&d.awaitState, 1)
+	rsp, err := d.sendReq(x, cmdInit, xpc.Dict{
+		"kCBMsgArgName":    fmt.Sprintf("gopher-%v", time.Now().Unix()),
+		"kCBMsgArgOptions": opts,
+		"kCBMsgArgType":    typ,
 	})
 	if err != nil {
+		atomic.StoreInt32(&d.awaitState, 0)
 		return err
 	}
-	s = State(rsp.state())
-	if s != StatePoweredOn {
-		return fmt.Errorf("state: %s", s)
+	d.restore(rsp)
+	if s := State(rsp.state()); s != StatePoweredOn && d.stateHandler == nil {
+		return &ble.AdapterStateError{State: ble.AdapterState(s)}
 	}
 	return nil
 }
 
 // Advertise advertises the given Advertisement
 func (d *Device) Advertise(ctx context.Context, adv ble.Advertisement) error {
-	rsp, err := d.sendReq(d.pm, cmdAdvertiseStart, xpc.Dict{
+	rsp, err := d.startAdvertising(d.withScanResponse(xpc.Dict{
 		"kCBAdvDataLocalName":    adv.LocalName(),
-		"kCBAdvDataServiceUUIDs": adv.Services(),
+		"kCBAdvDataServiceUUIDs": uuidSlice(adv.Services()),
 		"kCBAdvDataAppleMfgData": adv.ManufacturerData(),
-	})
+	}))
 	if err != nil {
 		return err
 	}
@@ -130,9 +172,9 @@ func (d *Device) Advertise(ctx context.Context, adv ble.Advertisement) error {
 func (d *Device) AdvertiseMfgData(ctx context.Context, id uint16, md []byte) error {
 	l := len(md)
 	b := []byte{byte(l + 3), 0xFF, uint8(id), uint8(id >> 8)}
-	rsp, err := d.sendReq(d.pm, cmdAdvertiseStart, xpc.Dict{
+	rsp, err := d.startAdvertising(d.withScanResponse(xpc.Dict{
 		"kCBAdvDataAppleMfgData": append(b, md...),
-	})
+	}))
 	if err != nil {
 		return err
 	}
@@ -150,9 +192,9 @@ func (d *Device) AdvertiseServiceData16(ctx context.Context, id uint16, b []byte
 		0x03, 0x03, uint8(id), uint8(id >> 8),
 		byte(l + 3), 0x16, uint8(id), uint8(id >> 8),
 	}
-	rsp, err := d.sendReq(d.pm, cmdAdvertiseStart, xpc.Dict{
+	rsp, err := d.startAdvertising(d.withScanResponse(xpc.Dict{
 		"kCBAdvDataAppleMfgData": append(prefix, b...),
-	})
+	}))
 	if err != nil {
 		return err
 	}
@@ -165,10 +207,10 @@ func (d *Device) AdvertiseServiceData16(ctx context.Context, id uint16, b []byte
 
 // AdvertiseNameAndServices advertises name and specifid service UUIDs.
 func (d *Device) AdvertiseNameAndServices(ctx context.Context, name string, ss ...ble.UUID) error {
-	rsp, err := d.sendReq(d.pm, cmdAdvertiseStart, xpc.Dict{
+	rsp, err := d.startAdvertising(d.withScanResponse(xpc.Dict{
 		"kCBAdvDataLocalName":    name,
-		"kCBAdvDataServiceUUIDs": uuidSlice(ss)},
-	)
+		"kCBAdvDataServiceUUIDs": uuidSlice(ss),
+	}))
 	if err != nil {
 		return err
 	}
@@ -192,7 +234,7 @@ func (d *Device) AdvertiseIBeaconData(ctx context.Context, md []byte) error {
 		ibeaconCode := []byte{0x02, 0x15}
 		return d.AdvertiseMfgData(ctx, 0x004C, append(ibeaconCode, md...))
 	}
-	rsp, err := d.sendReq(d.pm, cmdAdvertiseStart, xpc.Dict{"kCBAdvDataAppleBeaconKey": md})
+	rsp, err := d.startAdvertising(xpc.Dict{"kCBAdvDataAppleBeaconKey": md})
 	if err != nil {
 		return err
 	}
@@ -213,6 +255,39 @@ func (d *Device) AdvertiseIBeacon(ctx context.Context, u ble.UUID, major, minor
 	return d.AdvertiseIBeaconData(ctx, b)
 }
 
+// startAdvertising advertises x, keeping it for StartAdvertising.
+func (d *Device) startAdvertising(x xpc.Dict) (msg, error) {
+	d.advLock.Lock()
+	d.advData = x
+	d.advLock.Unlock()
+	return d.sendReq(d.pm, cmdAdvertiseStart, x)
+}
+
+// StartAdvertising advertises again what was advertised, once stopped by
+// StopAdvertising.
+func (d *Device) StartAdvertising() error {
+	d.advLock.Lock()
+	x := d.advData
+	d.advLock.Unlock()
+	if x == nil {
+		return errors.New("nothing advertised")
+	}
+	rsp, err := d.sendReq(d.pm, cmdAdvertiseStart, x)
+	if err != nil {
+		return errors.Wrap(err, "can't send start advertising")
+	}
+	if err := rsp.err(); err != nil {
+		return errors.Wrap(err, "can't advertise")
+	}
+	return nil
+}
+
+// StopAdvertising stops advertising, until StartAdvertising, or the next
+// Advertise call.
+func (d *Device) StopAdvertising() error {
+	return d.stopAdvertising()
+}
+
 // stopAdvertising stops advertising.
 func (d *Device) stopAdvertising() error {
 	rsp, err := d.sendReq(d.pm, cmdAdvertiseStop, nil)
@@ -228,6 +303,9 @@ func (d *Device) stopAdvertising() error {
 // Scan ...
 func (d *Device) Scan(ctx context.Context, allowDup bool, h ble.AdvHandler) error {
 	d.advHandler = h
+	if d.filterDupSet {
+		allowDup = !d.filterDup
+	}
 	if err := d.sendCmd(d.cm, cmdScanningStart, xpc.Dict{
 		// "kCBMsgArgUUIDs": uuidSlice(ss),
 		"kCBMsgArgOptions": xpc.Dict{
@@ -250,7 +328,12 @@ func (d *Device) stopScanning() error {
 
 // RemoveAllServices removes all services of device's
 func (d *Device) RemoveAllServices() error {
-	return d.sendCmd(d.pm, cmdServicesRemove, nil)
+	if err := d.sendCmd(d.pm, cmdServicesRemove, nil); err != nil {
+		return err
+	}
+	d.chars = make(map[int]*ble.Characteristic)
+	d.base = 1
+	return nil
 }
 
 // AddService adds a service to device's database.
@@ -262,7 +345,13 @@ func (d *Device) RemoveAllServices() error {
 // 0x180A (Device Information)
 // 0x180F (Battery Service)
 // 0x1812 (Human Interface Device)
+//
+// CoreBluetooth hosts only static User Description and Presentation Format
+// descriptors, besides the CCCD it manages; other descriptors are skipped.
 func (d *Device) AddService(s *ble.Service) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
 	if s.UUID.Equal(ble.GAPUUID) ||
 		s.UUID.Equal(ble.GATTUUID) ||
 		s.UUID.Equal(ble.CurrentTimeUUID) ||
@@ -286,7 +375,7 @@ func (d *Device) AddService(s *ble.Service) error {
 		perm := 0
 		if c.Property&ble.CharRead != 0 {
 			props |= 0x02
-			if ble.CharRead&c.Secure != 0 {
+			if ble.CharRead&c.Secure != 0 || c.ReadPermission&(ble.PermEncrypt|ble.PermAuthenticate) != 0 {
 				perm |= 0x04
 			} else {
 				perm |= 0x01
@@ -294,7 +383,7 @@ func (d *Device) AddService(s *ble.Service) error {
 		}
 		if c.Property&ble.CharWriteNR != 0 {
 			props |= 0x04
-			if c.Secure&ble.CharWriteNR != 0 {
+			if c.Secure&ble.CharWriteNR != 0 || c.WritePermission&(ble.PermEncrypt|ble.PermAuthenticate) != 0 {
 				perm |= 0x08
 			} else {
 				perm |= 0x02
@@ -302,7 +391,7 @@ func (d *Device) AddService(s *ble.Service) error {
 		}
 		if c.Property&ble.CharWrite != 0 {
 			props |= 0x08
-			if c.Secure&ble.CharWrite != 0 {
+			if c.Secure&ble.CharWrite != 0 || c.WritePermission&(ble.PermEncrypt|ble.PermAuthenticate) != 0 {
 				perm |= 0x08
 			} else {
 				perm |= 0x02
@@ -344,6 +433,17 @@ func (d *Device) AddService(s *ble.Service) error {
 				"kCBMsgArgData": d.Value,
 				"kCBMsgArgUUID": ble.Reverse(d.UUID),
 			}
+			switch {
+			case d.ReadHandler != nil || d.WriteHandler != nil:
+				log.Printf("skipping descriptor %s of %s: not static", d.UUID, c.UUID)
+				continue
+			case d.UUID.Equal(ble.UserDescriptionUUID):
+				// CBMutableDescriptor takes the User Description as a string.
+				xd["kCBMsgArgData"] = string(d.Value)
+			case !d.UUID.Equal(ble.PresentationFormatUUID):
+				log.Printf("skipping descriptor %s of %s: not supported", d.UUID, c.UUID)
+				continue
+			}
 			xds = append(xds, xd)
 		}
 		xc["kCBMsgArgDescriptors"] = xds
@@ -361,7 +461,7 @@ func (d *Device) AddService(s *ble.Service) error {
 // SetServices ...
 func (d *Device) SetServices(ss []*ble.Service) error {
 	if err := d.RemoveAllServices(); err != nil {
-		return nil
+		return err
 	}
 	for _, s := range ss {
 		if err := d.AddService(s); err != nil {
@@ -371,6 +471,11 @@ func (d *Device) SetServices(ss []*ble.Service) error {
 	return nil
 }
 
+// GATTDump isn't supported, as the attribute table is kept by CoreBluetooth.
+func (d *Device) GATTDump() ([]ble.Attribute, error) {
+	return nil, ble.ErrNotImplemented
+}
+
 // Dial ...
 func (d *Device) Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
 	err := d.sendCmd(d.cm, cmdConnect, xpc.Dict{
@@ -385,17 +490,69 @@ func (d *Device) Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
 	select {
 	case <-ctx.Done():
 		return nil, ctx.Err()
+	case <-d.chDone:
+		return nil, ErrStopped
 	case c := <-d.chConn:
 		c.SetContext(ctx)
 		return NewClient(c)
 	}
 }
 
-// Stop ...
+// Stop disconnects the connections, and tears down the XPC connections, which
+// also ends the scanning and the advertising. The requests in progress fail
+// with ErrStopped, and so do those made afterwards.
 func (d *Device) Stop() error {
+	var err error
+	d.stopOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		defer cancel()
+		err = d.DisconnectAll(ctx)
+		close(d.chDone)
+		cancelXPC(&d.pm)
+		cancelXPC(&d.cm)
+	})
+	return err
+}
+
+// DisconnectAll cancels every connection, and waits until they are
+// disconnected, or ctx is done.
+func (d *Device) DisconnectAll(ctx context.Context) error {
+	d.connLock.Lock()
+	conns := make([]*conn, 0, len(d.conns))
+	for _, c := range d.conns {
+		conns = append(conns, c)
+	}
+	d.connLock.Unlock()
+
+	for _, c := range conns {
+		if err := d.sendCmd(d.cm, cmdDisconnect, xpc.Dict{
+			"kCBMsgArgDeviceUUID": xpc.MakeUUID(c.RemoteAddr().String()),
+		}); err != nil {
+			return errors.Wrapf(err, "can't disconnect %s", c.RemoteAddr())
+		}
+	}
+	for _, c := range conns {
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 	return nil
 }
 
+// Reset cancels every connection, and stops advertising and scanning.
+// CoreBluetooth doesn't expose the controller, which isn't reset.
+func (d *Device) Reset(ctx context.Context) error {
+	if err := d.DisconnectAll(ctx); err != nil {
+		return err
+	}
+	if err := d.stopAdvertising(); err != nil {
+		return err
+	}
+	return d.stopScanning()
+}
+
 // HandleXpcEvent process Device events and asynchronous errors.
 func (d *Device) HandleXpcEvent(event xpc.Dict, err error) {
 	if err != nil {
@@ -411,12 +568,26 @@ func (d *Device) HandleXpcEvent(event xpc.Dict, err error) {
 	log.Printf("m.id(): %#v", m.id())
 
 	switch m.id() {
+	case evtStateChanged:
+		if atomic.CompareAndSwapInt32(&d.awaitState, 1, 0) {
+			select {
+			case d.rspc <- args:
+			case <-d.chDone:
+			}
+		}
+		s := int32(args.state())
+		if d.stateHandler != nil && atomic.SwapInt32(&d.state, s) != s {
+			go d.stateHandler(ble.AdapterState(s))
+		}
+
 	case // Device event
-		evtStateChanged,
 		evtAdvertisingStarted,
 		evtAdvertisingStopped,
 		evtServiceAdded:
-		d.rspc <- args
+		select {
+		case d.rspc <- args:
+		case <-d.chDone:
+		}
 
 	case evtPeripheralDiscovered:
 		if d.advHandler == nil {
@@ -446,55 +617,94 @@ func (d *Device) HandleXpcEvent(event xpc.Dict, err error) {
 	case evtReadRequest:
 		aid := args.attributeID()
 		char := d.chars[aid]
-		v := char.Value
-		if v == nil {
+		var v []byte
+		status := ble.ErrSuccess
+		switch {
+		case char == nil:
+			status = ble.ErrInvalidHandle
+		case char.Value != nil:
+			v = char.Value
+			if off := args.offset(); off > len(v) {
+				v, status = nil, ble.ErrInvalidOffset
+			} else {
+				v = v[off:]
+			}
+		case char.ReadHandler == nil:
+			status = ble.ErrReadNotPerm
+		default:
 			c := d.conn(args)
 			req := ble.NewRequest(c, nil, args.offset())
 			buf := bytes.NewBuffer(make([]byte, 0, c.txMTU-1))
 			rsp := ble.NewResponseWriter(buf)
 			char.ReadHandler.ServeRead(req, rsp)
-			v = buf.Bytes()
+			v, status = buf.Bytes(), rsp.Status()
 		}
 
+		// CBATTError uses the ATT error codes.
 		err := d.sendCmd(d.pm, cmdSendData, xpc.Dict{
 			"kCBMsgArgAttributeID":   aid,
 			"kCBMsgArgData":          v,
 			"kCBMsgArgTransactionID": args.transactionID(),
-			"kCBMsgArgResult":        0,
+			"kCBMsgArgResult":        int(status),
 		})
 		if err != nil {
 			log.Printf("error: %v", err)
 			return
 		}
 	case evtWriteRequest:
-		for _, xxw := range args.attWrites() {
+		// The writes of a transaction, such as a prepared write, are
+		// answered once, with the status of the first which fails.
+		aid, status, respond := 0, ble.ErrSuccess, false
+		for i, xxw := range args.attWrites() {
 			xw := msg(xxw.(xpc.Dict))
-			aid := xw.attributeID()
-			char := d.chars[aid]
-			req := ble.NewRequest(d.conn(args), xw.data(), xw.offset())
-			char.WriteHandler.ServeWrite(req, nil)
-			if xw.ignoreResponse() == 1 {
-				continue
+			if i == 0 {
+				aid = xw.attributeID()
+			}
+			if xw.ignoreResponse() != 1 {
+				respond = true
 			}
-			err := d.sendCmd(d.pm, cmdSendData, xpc.Dict{
-				"kCBMsgArgAttributeID":   aid,
-				"kCBMsgArgData":          nil,
-				"kCBMsgArgTransactionID": args.transactionID(),
-				"kCBMsgArgResult":        0,
-			})
-			if err != nil {
-				log.Println("error:", err)
-				return
+			char := d.chars[xw.attributeID()]
+			s := ble.ErrSuccess
+			switch {
+			case char == nil:
+				s = ble.ErrInvalidHandle
+			case char.WriteHandler == nil:
+				s = ble.ErrWriteNotPerm
+			default:
+				req := ble.NewRequest(d.conn(args), xw.data(), xw.offset())
+				rsp := ble.NewResponseWriter(nil)
+				char.WriteHandler.ServeWrite(req, rsp)
+				s = rsp.Status()
 			}
+			if s != ble.ErrSuccess && status == ble.ErrSuccess {
+				aid, status = xw.attributeID(), s
+			}
+		}
+		if !respond {
+			return
+		}
+		err := d.sendCmd(d.pm, cmdSendData, xpc.Dict{
+			"kCBMsgArgAttributeID":   aid,
+			"kCBMsgArgData":          nil,
+			"kCBMsgArgTransactionID": args.transactionID(),
+			"kCBMsgArgResult":        int(status),
+		})
+		if err != nil {
+			log.Println("error:", err)
+			return
 		}
 
 	case evtSubscribe:
 		// characteristic is subscribed by remote central.
-		d.conn(args).subscribed(d.chars[args.attributeID()])
+		if char := d.chars[args.attributeID()]; char != nil && char.NotifyHandler != nil {
+			d.conn(args).subscribed(char)
+		}
 
 	case evtUnsubscribe:
 		// characteristic is unsubscribed by remote central.
-		d.conn(args).unsubscribed(d.chars[args.attributeID()])
+		if char := d.chars[args.attributeID()]; char != nil {
+			d.conn(args).unsubscribed(char)
+		}
 
 	case evtPeripheralConnected:
 		log.Printf("d: %#v", d)
@@ -574,10 +784,20 @@ func (d *Device) sendReq(x xpc.XPC, id int, args xpc.Dict) (msg, error) {
 	if err != nil {
 		return msg{}, err
 	}
-	return <-d.rspc, nil
+	select {
+	case m := <-d.rspc:
+		return m, nil
+	case <-d.chDone:
+		return msg{}, ErrStopped
+	}
 }
 
 func (d *Device) sendCmd(x xpc.XPC, id int, args xpc.Dict) error {
+	select {
+	case <-d.chDone:
+		return ErrStopped
+	default:
+	}
 	logger.Info("send", "id", id, "args", fmt.Sprintf("%v", args))
 	x.Send(xpc.Dict{"kCBMsgId": id, "kCBMsgArgs": args}, false)
 	return nil
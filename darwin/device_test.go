@@ -0,0 +1,62 @@
+package darwin
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// openFiles returns the number of open file descriptors of the process.
+func openFiles(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/dev/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(fds)
+}
+
+func TestNewDeviceStop(t *testing.T) {
+	newDevice := func() *Device {
+		ch := make(chan *Device, 1)
+		go func() {
+			d, err := NewDevice()
+			if err != nil {
+				t.Logf("can't create device: %s", err)
+			}
+			ch <- d
+		}()
+		select {
+		case d := <-ch:
+			return d
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	}
+	d := newDevice()
+	if d == nil {
+		t.Skip("Bluetooth isn't available")
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	n := openFiles(t)
+	for i := 0; i < 50; i++ {
+		d := newDevice()
+		if d == nil {
+			t.Fatalf("device %d not created", i)
+		}
+		if err := d.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Stop(); err != nil {
+			t.Fatalf("second Stop() = %v", err)
+		}
+		if _, err := d.sendReq(d.cm, cmdInit, nil); err != ErrStopped {
+			t.Fatalf("request after Stop() = %v, want ErrStopped", err)
+		}
+	}
+	if m := openFiles(t); m > n+2 {
+		t.Errorf("%d files open after 50 devices, %d before", m, n)
+	}
+}
@@ -63,7 +63,7 @@ func (m msg) supervisionTimeout() int { return xpc.Dict(m).MustGetInt("kCBMsgArg
 
 func (m msg) err() error {
 	if code := m.result(); code != 0 {
-		return ble.ATTError(code)
+		return &ble.ErrATT{ErrCode: ble.ATTError(code)}
 	}
 	return nil
 }
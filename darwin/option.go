@@ -2,8 +2,10 @@ package darwin
 
 import (
 	"errors"
+	"io"
 	"time"
 
+	"github.com/kirbo/ble"
 	"github.com/kirbo/ble/linux/hci/cmd"
 	"github.com/kirbo/ble/linux/hci/evt"
 )
@@ -32,6 +34,20 @@ func (d *Device) SetCentralRole() error {
 	return nil
 }
 
+// SetRole configures the device to perform the tasks of a single role, as
+// the device serves either role.
+func (d *Device) SetRole(r ble.Role) error {
+	switch r {
+	case ble.RoleCentral:
+		d.role = 0
+	case ble.RolePeripheral:
+		d.role = 1
+	default:
+		return errors.New("Not supported")
+	}
+	return nil
+}
+
 // SetDeviceID sets HCI device ID.
 func (d *Device) SetDeviceID(id int) error {
 	return errors.New("Not supported")
@@ -61,3 +77,269 @@ func (d *Device) SetScanParams(param cmd.LESetScanParameters) error {
 func (d *Device) SetAdvParams(param cmd.LESetAdvertisingParameters) error {
 	return errors.New("Not supported")
 }
+
+// SetScanInterval is not supported; CoreBluetooth sets the duty cycle.
+func (d *Device) SetScanInterval(t time.Duration) error {
+	return errors.New("Not supported")
+}
+
+// SetScanWindow is not supported; CoreBluetooth sets the duty cycle.
+func (d *Device) SetScanWindow(t time.Duration) error {
+	return errors.New("Not supported")
+}
+
+// SetActiveScan is not supported; CoreBluetooth decides on the scan
+// responses.
+func (d *Device) SetActiveScan(active bool) error {
+	return errors.New("Not supported")
+}
+
+// SetScanPHYs is not supported; CoreBluetooth picks the PHYs.
+func (d *Device) SetScanPHYs(m ble.ScanPHY) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvIntervalMin is not supported; CoreBluetooth sets the interval.
+func (d *Device) SetAdvIntervalMin(t time.Duration) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvIntervalMax is not supported; CoreBluetooth sets the interval.
+func (d *Device) SetAdvIntervalMax(t time.Duration) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvChannelMap is not supported; CoreBluetooth advertises on all the
+// channels.
+func (d *Device) SetAdvChannelMap(m ble.AdvChannel) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvOwnAddressType is not supported; CoreBluetooth manages the address.
+func (d *Device) SetAdvOwnAddressType(t ble.OwnAddressType) error {
+	return errors.New("Not supported")
+}
+
+// SetDedupCache is not supported; CoreBluetooth filters duplicates itself.
+func (d *Device) SetDedupCache(key ble.DedupKey, ttl time.Duration) error {
+	return errors.New("Not supported")
+}
+
+// SetFilterDuplicates enables or disables duplicate filtering regardless of
+// the allowDup argument of Scan.
+func (d *Device) SetFilterDuplicates(enable bool) error {
+	d.filterDupSet = true
+	d.filterDup = enable
+	return nil
+}
+
+// SetBondStore is not supported; CoreBluetooth keeps the keys itself.
+func (d *Device) SetBondStore(s ble.BondStore) error {
+	return errors.New("Not supported")
+}
+
+// SetGATTCache is not supported; CoreBluetooth caches the GATT database itself.
+func (d *Device) SetGATTCache(enable bool) error {
+	return errors.New("Not supported")
+}
+
+// SetRemoteInfo is not supported.
+func (d *Device) SetRemoteInfo(enable bool) error {
+	return errors.New("Not supported")
+}
+
+// SetConnMonitor is not supported.
+func (d *Device) SetConnMonitor(m ble.ConnMonitor, poll time.Duration) error {
+	return errors.New("Not supported")
+}
+
+// SetIntervalTuning is not supported, as the connection parameters are left
+// to the platform.
+func (d *Device) SetIntervalTuning(t ble.IntervalTuning) error {
+	return errors.New("Not supported")
+}
+
+// SetScanBuffer is not supported.
+func (d *Device) SetScanBuffer(size int, onOverflow func(dropped int)) error {
+	return errors.New("Not supported")
+}
+
+// SetHCICapture is not supported; there's no HCI socket.
+func (d *Device) SetHCICapture(w io.Writer) error {
+	return errors.New("Not supported")
+}
+
+// SetTransport is not supported; there's no HCI socket.
+func (d *Device) SetTransport(t io.ReadWriteCloser) error {
+	return errors.New("Not supported")
+}
+
+// SetLogger is not supported.
+func (d *Device) SetLogger(l ble.Logger) error {
+	return errors.New("Not supported")
+}
+
+// SetMetrics is not supported.
+func (d *Device) SetMetrics(m ble.MetricsCollector) error {
+	return errors.New("Not supported")
+}
+
+// SetMaxConnections is not supported.
+func (d *Device) SetMaxConnections(n int) error {
+	return errors.New("Not supported")
+}
+
+// SetIndicationPolicy is not supported.
+func (d *Device) SetIndicationPolicy(p ble.IndicationPolicy) error {
+	return errors.New("Not supported")
+}
+
+// SetScanFilters is not supported.
+func (d *Device) SetScanFilters(fs []ble.ScanFilter) error {
+	return errors.New("Not supported")
+}
+
+// SetScanOffload is not supported.
+func (d *Device) SetScanOffload(o ble.ScanOffload) error {
+	return errors.New("Not supported")
+}
+
+// SetRandomAddress is not supported; CoreBluetooth manages the addresses.
+func (d *Device) SetRandomAddress(a ble.Addr) error {
+	return errors.New("Not supported")
+}
+
+// SetPublicAddress is not supported; CoreBluetooth manages the addresses.
+func (d *Device) SetPublicAddress(a ble.Addr) error {
+	return errors.New("Not supported")
+}
+
+// SetSecurityPolicy is not supported; CoreBluetooth manages the pairing.
+func (d *Device) SetSecurityPolicy(p ble.SecurityPolicy) error {
+	return errors.New("Not supported")
+}
+
+// SetStateHandler sets the handler of the changes of the CBManagerState.
+func (d *Device) SetStateHandler(f func(ble.AdapterState)) error {
+	d.stateHandler = f
+	return nil
+}
+
+// SetStateRestoration sets the restore identifier of the managers, and the
+// handler of their restored state.
+func (d *Device) SetStateRestoration(id string, f func(ble.RestoredState)) error {
+	d.restoreID = id
+	d.restoreHandler = f
+	return nil
+}
+
+// SetClock is not supported; CoreBluetooth times the operations.
+func (d *Device) SetClock(c ble.Clock) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvTxPower is not supported.
+func (d *Device) SetAdvTxPower(enable bool) error {
+	return errors.New("Not supported")
+}
+
+// SetAuthorizer is not supported; CoreBluetooth authorizes the accesses.
+func (d *Device) SetAuthorizer(a ble.Authorizer) error {
+	return errors.New("Not supported")
+}
+
+// SetExtendedScan is not supported; CoreBluetooth selects the PDUs scanned.
+func (d *Device) SetExtendedScan(enable bool) error {
+	return errors.New("Not supported")
+}
+
+// SetPowerProfile is not supported; CoreBluetooth sets the duty cycles.
+func (d *Device) SetPowerProfile(p ble.PowerProfile) error {
+	return errors.New("Not supported")
+}
+
+// SetAuditHook is not supported; CoreBluetooth serves the attributes.
+func (d *Device) SetAuditHook(f ble.AuditHook) error {
+	return errors.New("Not supported")
+}
+
+// SetRadioHandler is not supported; CoreBluetooth schedules the radio.
+func (d *Device) SetRadioHandler(f ble.RadioHandler) error {
+	return errors.New("Not supported")
+}
+
+// SetDropMalformed is not supported; CoreBluetooth parses the PDUs.
+func (d *Device) SetDropMalformed(enable bool) error {
+	return errors.New("Not supported")
+}
+
+// SetFallbackTCP is not supported; CoreBluetooth owns the transport.
+func (d *Device) SetFallbackTCP(addr string) error {
+	return errors.New("Not supported")
+}
+
+// SetAdapterRemovedHandler is not supported; the state handler tells the
+// adapter gone.
+func (d *Device) SetAdapterRemovedHandler(f func(), reopen bool) error {
+	return errors.New("Not supported")
+}
+
+// SetServerLimits is not supported; CoreBluetooth serves the attributes.
+func (d *Device) SetServerLimits(l ble.ServerLimits) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvRestartOnDisconnect is not supported; CoreBluetooth restarts the
+// advertising itself.
+func (d *Device) SetAdvRestartOnDisconnect(on bool) error {
+	return errors.New("Not supported")
+}
+
+// SetAdvWhileConnected is not supported; CoreBluetooth keeps advertising
+// while connected.
+func (d *Device) SetAdvWhileConnected(on bool) error {
+	return errors.New("Not supported")
+}
+
+// SetPacketHooks is not supported; CoreBluetooth hides the HCI packets.
+func (d *Device) SetPacketHooks(hooks ...ble.PacketHook) error {
+	return errors.New("Not supported")
+}
+
+// SetMemoryLimits is not supported; CoreBluetooth does the buffering.
+func (d *Device) SetMemoryLimits(l ble.MemoryLimits) error {
+	return errors.New("Not supported")
+}
+
+// SetAutoSecurity is not supported; CoreBluetooth pairs on its own, as the
+// servers refuse the operations.
+func (d *Device) SetAutoSecurity(l ble.SecurityLevel) error {
+	return errors.New("Not supported")
+}
+
+// SetPreferredConnParams is not supported, as the connection parameters are
+// left to the platform.
+func (d *Device) SetPreferredConnParams(p ble.PreferredConnParams) error {
+	return errors.New("Not supported")
+}
+
+// SetPreferredPHY is not supported; CoreBluetooth picks the PHYs.
+func (d *Device) SetPreferredPHY(p ble.PreferredPHY) error {
+	return errors.New("Not supported")
+}
+
+// SetRFKillUnblock is not supported; there's no rfkill on darwin.
+func (d *Device) SetRFKillUnblock(on bool) error {
+	return errors.New("Not supported")
+}
+
+// SetShortenName is not supported; CoreBluetooth composes the advertising.
+func (d *Device) SetShortenName(on bool) error {
+	return errors.New("Not supported")
+}
+
+// SetHostChannelClassification is not supported; CoreBluetooth manages the
+// channel maps.
+func (d *Device) SetHostChannelClassification(m ble.ChannelMap) error {
+	return errors.New("Not supported")
+}
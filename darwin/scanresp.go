@@ -0,0 +1,96 @@
+package darwin
+
+import (
+	"github.com/kirbo/ble"
+	bleadv "github.com/kirbo/ble/linux/adv"
+	"github.com/raff/goble/xpc"
+)
+
+// AD types, which CoreBluetooth advertises.
+const (
+	adFlags        = 0x01
+	adSomeUUID16   = 0x02
+	adAllUUID128   = 0x07
+	adShortName    = 0x08
+	adCompleteName = 0x09
+)
+
+// scanResp is the part of a scan response, which CoreBluetooth advertises.
+type scanResp struct {
+	name     string
+	services []ble.UUID
+}
+
+// parseScanResponse returns the scan response of fields, and the
+// *ble.UnsupportedADError of the fields CoreBluetooth doesn't advertise, if
+// any.
+func parseScanResponse(fields ...bleadv.Field) (*scanResp, error) {
+	p, err := bleadv.NewExtendedPacket(fields...)
+	if err != nil {
+		return nil, err
+	}
+	sr := &scanResp{name: p.LocalName(), services: p.UUIDs()}
+	var bad []byte
+	for _, r := range p.Records() {
+		switch {
+		case r.Type == adFlags:
+			// Set by CoreBluetooth itself.
+		case r.Type >= adSomeUUID16 && r.Type <= adAllUUID128:
+		case r.Type == adShortName, r.Type == adCompleteName:
+		default:
+			bad = append(bad, r.Type)
+		}
+	}
+	if bad != nil {
+		return sr, &ble.UnsupportedADError{Types: bad}
+	}
+	return sr, nil
+}
+
+// merge adds the name, unless x has one, and the services of the scan
+// response to the advertising data x.
+func (sr *scanResp) merge(x xpc.Dict) xpc.Dict {
+	if sr == nil {
+		return x
+	}
+	if n, _ := x["kCBAdvDataLocalName"].(string); n == "" && sr.name != "" {
+		x["kCBAdvDataLocalName"] = sr.name
+	}
+	if len(sr.services) > 0 {
+		us, _ := x["kCBAdvDataServiceUUIDs"].([][]byte)
+		x["kCBAdvDataServiceUUIDs"] = append(us, uuidSlice(sr.services)...)
+	}
+	return x
+}
+
+// SetScanResponse sets the scan response, which the later Advertise methods,
+// but of the iBeacons, send along, as far as CoreBluetooth allows: the local
+// name, and the service UUIDs, which it places in the scan response, or in
+// its overflow area, as they don't fit the advertising data. The fields it
+// doesn't advertise are told by a *ble.UnsupportedADError, while the others
+// are set anyway, so the scan responses of Linux degrade predictably. No
+// fields set an empty scan response.
+func (d *Device) SetScanResponse(fields ...bleadv.Field) error {
+	sr, err := parseScanResponse(fields...)
+	if sr != nil {
+		d.srLock.Lock()
+		d.scanResp = sr
+		d.srLock.Unlock()
+	}
+	return err
+}
+
+// ClearScanResponse clears the scan response of SetScanResponse.
+func (d *Device) ClearScanResponse() {
+	d.srLock.Lock()
+	d.scanResp = nil
+	d.srLock.Unlock()
+}
+
+// withScanResponse returns the advertising data x, with the scan response
+// merged.
+func (d *Device) withScanResponse(x xpc.Dict) xpc.Dict {
+	d.srLock.Lock()
+	defer d.srLock.Unlock()
+	return d.scanResp.merge(x)
+}
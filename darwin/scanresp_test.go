@@ -0,0 +1,44 @@
+package darwin
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kirbo/ble"
+	bleadv "github.com/kirbo/ble/linux/adv"
+	"github.com/raff/goble/xpc"
+)
+
+func TestScanResponse(t *testing.T) {
+	svc := ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+	sr, err := parseScanResponse(
+		bleadv.Flags(bleadv.FlagGeneralDiscoverable),
+		bleadv.CompleteName("Sensor"),
+		bleadv.AllUUID(svc),
+		bleadv.ManufacturerData(0x004C, []byte{1}),
+		bleadv.TxPower(-8),
+	)
+	var ue *ble.UnsupportedADError
+	if !errors.As(err, &ue) || !errors.Is(err, ble.ErrNotImplemented) {
+		t.Fatalf("err = %v, want an UnsupportedADError", err)
+	}
+	if len(ue.Types) != 2 || ue.Types[0] != 0xFF || ue.Types[1] != 0x0A {
+		t.Errorf("unsupported types = % X, want FF 0A", ue.Types)
+	}
+
+	x := sr.merge(xpc.Dict{
+		"kCBAdvDataLocalName":    "",
+		"kCBAdvDataServiceUUIDs": uuidSlice([]ble.UUID{ble.UUID16(0x180F)}),
+	})
+	if n := x["kCBAdvDataLocalName"]; n != "Sensor" {
+		t.Errorf("name = %v, want Sensor", n)
+	}
+	us := x["kCBAdvDataServiceUUIDs"].([][]byte)
+	if len(us) != 2 || !ble.UUID(ble.Reverse(us[1])).Equal(svc) {
+		t.Errorf("services = %x", us)
+	}
+
+	if _, err := parseScanResponse(bleadv.ShortName("S")); err != nil {
+		t.Errorf("name only: %v", err)
+	}
+}
@@ -1,5 +1,10 @@
 package darwin
 
+import (
+	"github.com/kirbo/ble"
+	"github.com/raff/goble/xpc"
+)
+
 // State ...
 type State int
 
@@ -24,3 +29,53 @@ func (s State) String() string {
 	}
 	return str[int(s)]
 }
+
+// restore gives the state, which CoreBluetooth restored for the restore
+// identifier along with the state of a manager, to the restoration handler.
+func (d *Device) restore(m msg) {
+	if d.restoreHandler == nil {
+		return
+	}
+	x := xpc.Dict(m)
+	rs := ble.RestoredState{}
+	restored := false
+	if ps, ok := x["kCBRestoredPeripherals"].(xpc.Array); ok {
+		restored = true
+		for _, p := range ps {
+			if p, ok := p.(xpc.Dict); ok && p.Contains("kCBMsgArgDeviceUUID") {
+				rs.Peripherals = append(rs.Peripherals, ble.MustParse(msg(p).deviceUUID().String()))
+			}
+		}
+	}
+	if us, ok := x["kCBRestoredScanServices"]; ok {
+		restored = true
+		rs.ScanServices = restoredUUIDs(us)
+	}
+	if ss, ok := x["kCBRestoredServices"].(xpc.Array); ok {
+		restored = true
+		for _, s := range ss {
+			if s, ok := s.(xpc.Dict); ok && s.Contains("kCBMsgArgUUID") {
+				rs.Services = append(rs.Services, ble.MustParse(msg(s).uuid()))
+			}
+		}
+	}
+	if _, ok := x["kCBRestoredAdvertisement"]; ok {
+		restored = true
+		rs.Advertised = true
+	}
+	if restored {
+		go d.restoreHandler(rs)
+	}
+}
+
+// restoredUUIDs returns the UUIDs of an array, in the order of uuidSlice.
+func restoredUUIDs(v interface{}) []ble.UUID {
+	a, _ := v.(xpc.Array)
+	us := []ble.UUID{}
+	for _, u := range a {
+		if b, ok := u.([]byte); ok {
+			us = append(us, ble.UUID(ble.Reverse(b)))
+		}
+	}
+	return us
+}
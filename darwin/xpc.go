@@ -0,0 +1,24 @@
+package darwin
+
+/*
+#include <xpc/xpc.h>
+
+static void xpc_cancel_release(void *conn) {
+	xpc_connection_cancel((xpc_connection_t)conn);
+	xpc_release((xpc_connection_t)conn);
+}
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/raff/goble/xpc"
+)
+
+// cancelXPC cancels the connection of x and releases it, which the xpc package
+// offers no way to. An XPC holds nothing but the connection. The dispatch
+// queue, which the connection targets, is released with it.
+func cancelXPC(x *xpc.XPC) {
+	C.xpc_cancel_release(*(*unsafe.Pointer)(unsafe.Pointer(x)))
+}
@@ -0,0 +1,207 @@
+package ble
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+)
+
+// ErrDescriptorNotFound is returned for descriptors, which the characteristic
+// wasn't discovered with.
+var ErrDescriptorNotFound = errors.New("descriptor not found")
+
+// Formats of the Characteristic Presentation Format.
+// [Assigned Numbers, 2.4.1]
+const (
+	FormatBool    = 0x01
+	FormatUint8   = 0x04
+	FormatUint16  = 0x06
+	FormatUint32  = 0x08
+	FormatUint64  = 0x0A
+	FormatSint8   = 0x0C
+	FormatSint16  = 0x0E
+	FormatSint32  = 0x10
+	FormatSint64  = 0x12
+	FormatFloat32 = 0x14
+	FormatFloat64 = 0x15
+	FormatUTF8    = 0x19
+	FormatStruct  = 0x1B
+)
+
+// NamespaceBluetoothSIG is the namespace of the descriptions, which the
+// Bluetooth SIG assigns.
+const NamespaceBluetoothSIG = 0x01
+
+// A PresentationFormat is the value of a Characteristic Presentation Format
+// descriptor. [Vol 3, Part G, 3.3.3.5]
+type PresentationFormat struct {
+	Format      uint8  // Format of the value, such as FormatUint16.
+	Exponent    int8   // Base 10 exponent of the value.
+	Unit        uint16 // UUID of the unit, such as 0x272F for degrees Celsius.
+	Namespace   uint8  // Namespace of the description.
+	Description uint16 // Description, in the namespace.
+}
+
+// MarshalBinary returns the 7 bytes of the descriptor value.
+func (f PresentationFormat) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 7)
+	b[0] = f.Format
+	b[1] = byte(f.Exponent)
+	binary.LittleEndian.PutUint16(b[2:], f.Unit)
+	b[4] = f.Namespace
+	binary.LittleEndian.PutUint16(b[5:], f.Description)
+	return b, nil
+}
+
+// UnmarshalBinary parses the value of the descriptor.
+func (f *PresentationFormat) UnmarshalBinary(b []byte) error {
+	if len(b) != 7 {
+		return ErrInvalAttrValueLen
+	}
+	*f = PresentationFormat{
+		Format:      b[0],
+		Exponent:    int8(b[1]),
+		Unit:        binary.LittleEndian.Uint16(b[2:]),
+		Namespace:   b[4],
+		Description: binary.LittleEndian.Uint16(b[5:]),
+	}
+	return nil
+}
+
+// FindDescriptor returns the descriptor of the characteristic with UUID u,
+// or nil.
+func (c *Characteristic) FindDescriptor(u UUID) *Descriptor {
+	for _, d := range c.Descriptors {
+		if d.UUID.Equal(u) {
+			return d
+		}
+	}
+	return nil
+}
+
+// SetUserDescription adds a read-only Characteristic User Description
+// descriptor, which describes the characteristic to users.
+func (c *Characteristic) SetUserDescription(s string) *Descriptor {
+	d := c.NewDescriptor(UserDescriptionUUID)
+	d.SetValue([]byte(s))
+	return d
+}
+
+// AddPresentationFormat adds a Characteristic Presentation Format
+// descriptor. Characteristics of several fields have a format for each,
+// in the order of the fields, which SetAggregateFormat puts together.
+func (c *Characteristic) AddPresentationFormat(f PresentationFormat) *Descriptor {
+	b, _ := f.MarshalBinary()
+	d := NewDescriptor(PresentationFormatUUID)
+	d.SetValue(b)
+	c.Descriptors = append(c.Descriptors, d)
+	return d
+}
+
+// SetAggregateFormat adds a Characteristic Aggregate Format descriptor, of
+// the presentation formats ds. The handles of ds are known once the service
+// is added to a server, so it isn't supported by the platforms which assign
+// them.
+func (c *Characteristic) SetAggregateFormat(ds ...*Descriptor) *Descriptor {
+	d := c.NewDescriptor(AggregateFormatUUID)
+	d.HandleRead(ReadHandlerFunc(func(req Request, rsp ResponseWriter) {
+		for _, f := range ds {
+			binary.Write(rsp, binary.LittleEndian, f.Handle)
+		}
+	}))
+	return d
+}
+
+// NewServerConfig adds a Server Characteristic Configuration descriptor, which
+// lets clients enable the broadcast of the value, in advertisements. Unlike
+// the CCCD, the configuration is shared by all the clients. f is called as
+// the configuration is written.
+func (c *Characteristic) NewServerConfig(f func(broadcast bool)) *Descriptor {
+	var mu sync.Mutex
+	var scc uint16
+	c.Property |= CharBroadcast
+	d := c.NewDescriptor(ServerCharacteristicConfigUUID)
+	d.HandleRead(ReadHandlerFunc(func(req Request, rsp ResponseWriter) {
+		mu.Lock()
+		defer mu.Unlock()
+		binary.Write(rsp, binary.LittleEndian, scc)
+	}))
+	d.HandleWrite(WriteHandlerFunc(func(req Request, rsp ResponseWriter) {
+		if len(req.Data()) != 2 {
+			rsp.SetStatus(ErrInvalAttrValueLen)
+			return
+		}
+		mu.Lock()
+		scc = binary.LittleEndian.Uint16(req.Data())
+		mu.Unlock()
+		if f != nil {
+			f(scc&0x0001 != 0)
+		}
+	}))
+	return d
+}
+
+// readDescriptor reads the descriptor of c with UUID u.
+func readDescriptor(cln Client, c *Characteristic, u UUID) ([]byte, error) {
+	d := c.FindDescriptor(u)
+	if d == nil {
+		return nil, ErrDescriptorNotFound
+	}
+	return cln.ReadDescriptor(d)
+}
+
+// ReadUserDescription reads the Characteristic User Description of c, which
+// must have been discovered with its descriptors.
+func ReadUserDescription(cln Client, c *Characteristic) (string, error) {
+	b, err := readDescriptor(cln, c, UserDescriptionUUID)
+	return string(b), err
+}
+
+// ReadPresentationFormat reads the Characteristic Presentation Format of c,
+// which must have been discovered with its descriptors. Characteristics of
+// several fields are read with ReadAggregateFormat.
+func ReadPresentationFormat(cln Client, c *Characteristic) (PresentationFormat, error) {
+	var f PresentationFormat
+	b, err := readDescriptor(cln, c, PresentationFormatUUID)
+	if err != nil {
+		return f, err
+	}
+	return f, f.UnmarshalBinary(b)
+}
+
+// ReadAggregateFormat reads the Characteristic Aggregate Format of c, and the
+// presentation formats it refers to, in the order of the fields of the value.
+func ReadAggregateFormat(cln Client, c *Characteristic) ([]PresentationFormat, error) {
+	b, err := readDescriptor(cln, c, AggregateFormatUUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(b)%2 != 0 {
+		return nil, ErrInvalAttrValueLen
+	}
+	fs := make([]PresentationFormat, len(b)/2)
+	for i := range fs {
+		v, err := cln.ReadDescriptor(&Descriptor{UUID: PresentationFormatUUID, Handle: binary.LittleEndian.Uint16(b[2*i:])})
+		if err != nil {
+			return nil, err
+		}
+		if err := fs[i].UnmarshalBinary(v); err != nil {
+			return nil, err
+		}
+	}
+	return fs, nil
+}
+
+// WriteServerConfig enables, or disables, the broadcast of the value of c,
+// with its Server Characteristic Configuration.
+func WriteServerConfig(cln Client, c *Characteristic, broadcast bool) error {
+	d := c.FindDescriptor(ServerCharacteristicConfigUUID)
+	if d == nil {
+		return ErrDescriptorNotFound
+	}
+	var v uint16
+	if broadcast {
+		v = 0x0001
+	}
+	return cln.WriteDescriptor(d, []byte{byte(v), byte(v >> 8)})
+}
@@ -0,0 +1,51 @@
+package ble_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestDescriptors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	temp := ble.PresentationFormat{Format: ble.FormatSint16, Exponent: -2, Unit: 0x272F, Namespace: ble.NamespaceBluetoothSIG}
+	hum := ble.PresentationFormat{Format: ble.FormatUint16, Exponent: -2, Unit: 0x27AD, Namespace: ble.NamespaceBluetoothSIG}
+	broadcast := make(chan bool, 1)
+	svc := ble.NewService(gatttest.ServiceUUID)
+	ch := svc.NewCharacteristic(gatttest.ReadCharUUID)
+	ch.SetValue([]byte{0x10, 0x09, 0x88, 0x13})
+	ch.SetUserDescription("Environment")
+	ch.SetAggregateFormat(ch.AddPresentationFormat(temp), ch.AddPresentationFormat(hum))
+	ch.NewServerConfig(func(b bool) { broadcast <- b })
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	if s, err := ble.ReadUserDescription(cln, rc); err != nil || s != "Environment" {
+		t.Errorf("ReadUserDescription() = %q, %v, want Environment", s, err)
+	}
+	if f, err := ble.ReadPresentationFormat(cln, rc); err != nil || f != temp {
+		t.Errorf("ReadPresentationFormat() = %+v, %v, want %+v", f, err, temp)
+	}
+	fs, err := ble.ReadAggregateFormat(cln, rc)
+	if err != nil || len(fs) != 2 || fs[0] != temp || fs[1] != hum {
+		t.Errorf("ReadAggregateFormat() = %+v, %v, want [%+v %+v]", fs, err, temp, hum)
+	}
+	if err := ble.WriteServerConfig(cln, rc, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-broadcast:
+		if !b {
+			t.Error("broadcast not enabled")
+		}
+	case <-ctx.Done():
+		t.Fatal("server configuration not written")
+	}
+}
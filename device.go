@@ -14,6 +14,9 @@ type Device interface {
 	// It removes all currently added services, if any.
 	SetServices(svcs []*Service) error
 
+	// GATTDump returns the attribute table of the GATT server.
+	GATTDump() ([]Attribute, error)
+
 	// Stop detatch the GATT server from a peripheral device.
 	Stop() error
 
@@ -42,4 +45,12 @@ type Device interface {
 
 	// Dial ...
 	Dial(ctx context.Context, a Addr) (Client, error)
+
+	// DisconnectAll terminates every connection, and waits until they are
+	// disconnected, or ctx is done.
+	DisconnectAll(ctx context.Context) error
+
+	// Reset terminates every connection, stops advertising and scanning,
+	// and resets the controller to its initial state.
+	Reset(ctx context.Context) error
 }
@@ -0,0 +1,123 @@
+package ble
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrDeviceNotFound is returned by DialByName, when no advertisement of the
+// name is received before the scan times out.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// DefaultDialByNameScanTimeout is how long DialByName scans for the name.
+const DefaultDialByNameScanTimeout = 10 * time.Second
+
+// A DialByNameOption configures DialByName.
+type DialByNameOption func(*dialByName)
+
+type dialByName struct {
+	scanTimeout time.Duration
+	exact       bool
+	fold        bool
+}
+
+// DialByNameScanTimeout sets how long DialByName scans for the name, in place
+// of DefaultDialByNameScanTimeout, or until ctx is done if d is 0.
+func DialByNameScanTimeout(d time.Duration) DialByNameOption {
+	return func(c *dialByName) { c.scanTimeout = d }
+}
+
+// DialByNameExact matches the complete local names only, rather than the
+// shortened ones the name starts with too.
+func DialByNameExact() DialByNameOption {
+	return func(c *dialByName) { c.exact = true }
+}
+
+// DialByNameFold matches the names regardless of case.
+func DialByNameFold() DialByNameOption {
+	return func(c *dialByName) { c.fold = true }
+}
+
+// DialByName scans on d, or the default device if d is nil, for a peripheral
+// advertising the local name, and dials the first one found. A shortened name
+// matches, if the name starts with it. It fails with ErrDeviceNotFound if the
+// scan times out, and with the error of ctx if ctx is done first.
+func DialByName(ctx context.Context, d Device, name string, opts ...DialByNameOption) (Client, error) {
+	if d == nil {
+		d = defaultDevice
+	}
+	if d == nil {
+		return nil, ErrDefaultDevice
+	}
+	c := dialByName{scanTimeout: DefaultDialByNameScanTimeout}
+	for _, o := range opts {
+		o(&c)
+	}
+
+	var sctx context.Context
+	var cancel context.CancelFunc
+	if c.scanTimeout > 0 {
+		sctx, cancel = context.WithTimeout(ctx, c.scanTimeout)
+	} else {
+		sctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// The first match is kept before the scan is canceled, and any later
+	// ones are dropped.
+	ch := make(chan Advertisement, 1)
+	err := d.Scan(sctx, false, func(a Advertisement) {
+		if !c.match(a, name) {
+			return
+		}
+		select {
+		case ch <- a:
+		default:
+		}
+		cancel()
+	})
+
+	var a Advertisement
+	select {
+	case a = <-ch:
+	default:
+		switch {
+		case ctx.Err() != nil:
+			return nil, wrap(ctx.Err(), "can't scan")
+		case sctx.Err() == nil && err != nil:
+			return nil, wrap(err, "can't scan")
+		}
+		return nil, wrap(ErrDeviceNotFound, fmt.Sprintf("no advertisement of %q", name))
+	}
+	cln, err := d.Dial(WithAdvertisement(ctx, a), a.Addr())
+	return cln, wrap(err, fmt.Sprintf("can't dial %q", name))
+}
+
+// match reports whether a advertises the name. The advertisements of the
+// platforms, which don't tell the AD structures, match by LocalName.
+func (c *dialByName) match(a Advertisement, name string) bool {
+	eq, prefix := func(s string) bool { return s == name }, strings.HasPrefix
+	if c.fold {
+		eq = func(s string) bool { return strings.EqualFold(s, name) }
+		prefix = func(s, p string) bool { return strings.HasPrefix(strings.ToLower(s), strings.ToLower(p)) }
+	}
+	named := false
+	for _, r := range a.Records() {
+		switch r.Type {
+		case 0x09: // Complete Local Name
+			named = true
+			if eq(string(r.Data)) {
+				return true
+			}
+		case 0x08: // Shortened Local Name
+			named = true
+			if !c.exact && len(r.Data) > 0 && prefix(name, string(r.Data)) {
+				return true
+			}
+		}
+	}
+	return !named && a.LocalName() != "" && eq(a.LocalName())
+}
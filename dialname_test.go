@@ -0,0 +1,32 @@
+package ble_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestDialByName(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := gatttest.New(ctx, t, 10*time.Millisecond)
+	defer f.Stop()
+
+	cln, err := ble.DialByName(ctx, f.Central, "peripheral", ble.DialByNameFold())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cln.Addr().String() != f.Peripheral.Addr().String() {
+		t.Errorf("Addr() = %s, want %s", cln.Addr(), f.Peripheral.Addr())
+	}
+	cln.CancelConnection()
+
+	if _, err := ble.DialByName(ctx, f.Central, "peripheral", ble.DialByNameScanTimeout(100*time.Millisecond)); !errors.Is(err, ble.ErrDeviceNotFound) {
+		t.Errorf("DialByName(case mismatch) = %v, want ErrDeviceNotFound", err)
+	}
+}
@@ -3,6 +3,7 @@ package ble
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // ErrEIRPacketTooLong is the error returned when an AdvertisingPacket
@@ -12,6 +13,111 @@ var ErrEIRPacketTooLong = errors.New("max packet length is 31")
 // ErrNotImplemented means the functionality is not implemented.
 var ErrNotImplemented = errors.New("not implemented")
 
+// ErrDisconnected is the error, as told by errors.Is, of the operations of a
+// connection, which is lost, and of a device, whose adapter is gone.
+var ErrDisconnected = errors.New("disconnected")
+
+// ErrPermissionDenied is the error, as told by errors.Is, of a device, which
+// the platform doesn't allow to use Bluetooth, such as without the
+// CAP_NET_ADMIN capability on linux, or unauthorized on darwin.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ErrAdapterRemoved is the error, as told by errors.Is, of a device, whose
+// adapter is gone, such as a USB dongle unplugged. It tells as
+// ErrDisconnected too.
+var ErrAdapterRemoved = errors.New("adapter removed")
+
+// ErrNoBluetoothSupport is the error, as told by errors.Is, of a device on a
+// host, or in a container, without Bluetooth support, see NoBluetoothError.
+var ErrNoBluetoothSupport = errors.New("no Bluetooth support")
+
+// ErrIndicationsPending is the error of the indications to a client, which
+// has the MaxPending of the IndicationPolicy pending already.
+var ErrIndicationsPending = errors.New("too many indications pending")
+
+// A NoBluetoothError tells that the platform has no Bluetooth, such as a
+// kernel without it, or a container not sharing the network of the host,
+// with guidance to get it. It tells as ErrNoBluetoothSupport.
+type NoBluetoothError struct {
+	Err       error  // The error of the platform.
+	Container string // The container runtime detected, such as "docker", or "".
+	Guidance  string // What may give the process Bluetooth.
+}
+
+func (e *NoBluetoothError) Error() string {
+	msg := ErrNoBluetoothSupport.Error()
+	if e.Container != "" {
+		msg += " in " + e.Container + " container"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Guidance != "" {
+		msg += "; " + e.Guidance
+	}
+	return msg
+}
+
+func (e *NoBluetoothError) Unwrap() error        { return e.Err }
+func (e *NoBluetoothError) Is(target error) bool { return target == ErrNoBluetoothSupport }
+
+// UnsupportedADError is the error of the advertising data, or scan responses,
+// with fields the platform can't advertise, such as CoreBluetooth, which
+// advertises the local name and the service UUIDs only. It is told by
+// errors.Is(err, ErrNotImplemented).
+type UnsupportedADError struct {
+	Types []byte // AD types of the fields not advertised. [CSS, Part A, 1]
+}
+
+func (e *UnsupportedADError) Error() string {
+	ts := make([]string, len(e.Types))
+	for i, t := range e.Types {
+		ts[i] = fmt.Sprintf("0x%02X", t)
+	}
+	return "unsupported advertising fields: " + strings.Join(ts, ", ")
+}
+
+func (e *UnsupportedADError) Is(target error) bool { return target == ErrNotImplemented }
+
+// ErrATT is the Error Response of a server to a request. It unwraps to its
+// ATTError, so errors.Is(err, ErrAttrNotFound) tells it. [Vol 3, Part F, 3.4.1.1]
+type ErrATT struct {
+	Opcode  byte     // Opcode of the request in error, or 0 if the platform doesn't tell.
+	Handle  uint16   // Handle of the attribute in error, or 0 if the platform doesn't tell.
+	ErrCode ATTError // Error code of the response.
+}
+
+func (e *ErrATT) Error() string {
+	if e.Opcode == 0 {
+		return e.ErrCode.Error()
+	}
+	return fmt.Sprintf("%s (request 0x%02X, handle 0x%04X)", e.ErrCode, e.Opcode, e.Handle)
+}
+
+// Unwrap returns the error code.
+func (e *ErrATT) Unwrap() error {
+	return e.ErrCode
+}
+
+// ErrHCI is the status of an HCI command, or event, which failed, as told by
+// errors.As. [Vol 2, Part D, 1.3]
+type ErrHCI struct {
+	Status uint8
+}
+
+func (e *ErrHCI) Error() string {
+	if s := HCIStatusText(e.Status); s != "" {
+		return fmt.Sprintf("HCI error 0x%02X: %s", e.Status, s)
+	}
+	return fmt.Sprintf("HCI error 0x%02X", e.Status)
+}
+
+// Is tells the ErrHCI of the same status.
+func (e *ErrHCI) Is(target error) bool {
+	t, ok := target.(*ErrHCI)
+	return ok && t.Status == e.Status
+}
+
 // ATTError is the error code of Attribute Protocol [Vol 3, Part F, 3.4.1.1].
 type ATTError byte
 
@@ -35,19 +141,27 @@ const (
 	ErrInsuffEnc         ATTError = 0x0f // ErrInsuffEnc means the attribute requires encryption before it can be read or written.
 	ErrUnsuppGrpType     ATTError = 0x10 // ErrUnsuppGrpType means the attribute type is not a supported grouping attribute as defined by a higher layer specification.
 	ErrInsuffResources   ATTError = 0x11 // ErrInsuffResources means insufficient resources to complete the request.
+	ErrDBOutOfSync       ATTError = 0x12 // ErrDBOutOfSync means the server requests the client to rediscover the database.
+	ErrValueNotAllowed   ATTError = 0x13 // ErrValueNotAllowed means the attribute parameter value was not allowed.
+
+	// Common profile and service error codes. [Core Specification Supplement, Part B, 1.2]
+	ErrWriteReqRejected ATTError = 0xFC // ErrWriteReqRejected means the write request was rejected by the profile or service.
+	ErrCCCDImproper     ATTError = 0xFD // ErrCCCDImproper means the Client Characteristic Configuration Descriptor is improperly configured.
+	ErrProcInProgress   ATTError = 0xFE // ErrProcInProgress means a previously triggered procedure is still in progress.
+	ErrOutOfRange       ATTError = 0xFF // ErrOutOfRange means the attribute value is out of range.
 )
 
 func (e ATTError) Error() string {
 	switch i := int(e); {
-	case i < 0x11:
+	case i <= 0x13 || i >= 0xFC:
 		return errName[e]
-	case i >= 0x12 && i <= 0x7F: // Reserved for future use.
+	case i >= 0x14 && i <= 0x7F: // Reserved for future use.
 		return fmt.Sprintf("reserved error code (0x%02X)", i)
 	case i >= 0x80 && i <= 0x9F: // Application error, defined by higher level.
 		return fmt.Sprintf("application error code (0x%02X)", i)
 	case i >= 0xA0 && i <= 0xDF: // Reserved for future use.
 		return fmt.Sprintf("reserved error code (0x%02X)", i)
-	case i >= 0xE0 && i <= 0xFF: // Common profile and service error codes.
+	case i >= 0xE0 && i <= 0xFB: // Common profile and service error codes.
 		return "profile or service error"
 	}
 	return "unknown error"
@@ -72,4 +186,29 @@ var errName = map[ATTError]string{
 	ErrInsuffEnc:         "insufficient encryption",
 	ErrUnsuppGrpType:     "unsupported group type",
 	ErrInsuffResources:   "insufficient resources",
+	ErrDBOutOfSync:       "database out of sync",
+	ErrValueNotAllowed:   "value not allowed",
+	ErrWriteReqRejected:  "write request rejected",
+	ErrCCCDImproper:      "client characteristic configuration descriptor improperly configured",
+	ErrProcInProgress:    "procedure already in progress",
+	ErrOutOfRange:        "out of range",
+}
+
+// wrapError annotates an error, which stays the cause of it, as told both by
+// errors.Is and errors.As, and by errors.Cause of github.com/pkg/errors.
+type wrapError struct {
+	msg string
+	err error
+}
+
+func (e *wrapError) Error() string { return e.msg + ": " + e.err.Error() }
+func (e *wrapError) Unwrap() error { return e.err }
+func (e *wrapError) Cause() error  { return e.err }
+
+// wrap annotates err with msg, or returns nil if err is nil.
+func wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	return &wrapError{msg: msg, err: err}
 }
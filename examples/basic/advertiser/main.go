@@ -20,11 +20,10 @@ var (
 func main() {
 	flag.Parse()
 
-	d, err := dev.NewDevice("default")
-	if err != nil {
+	if _, err := dev.GetDevice(); err != nil {
 		log.Fatalf("can't new device : %s", err)
 	}
-	ble.SetDefaultDevice(d)
+	defer dev.Stop()
 
 	// Advertise for specified durantion, or until interrupted by user.
 	fmt.Printf("Advertising for %s...\n", *du)
@@ -24,11 +24,10 @@ var (
 func main() {
 	flag.Parse()
 
-	d, err := dev.NewDevice(*device)
-	if err != nil {
+	if _, err := dev.GetDevice(); err != nil {
 		log.Fatalf("can't new device : %s", err)
 	}
-	ble.SetDefaultDevice(d)
+	defer dev.Stop()
 
 	// Default to search device with name of Gopher (or specified by user).
 	filter := func(a ble.Advertisement) bool {
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/examples/lib/dev"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+var (
+	device   = flag.String("device", "default", "implementation of ble")
+	name     = flag.String("name", "Gopher", "name of remote peripheral")
+	addr     = flag.String("addr", "", "address of remote peripheral (MAC on Linux, UUID on OS X)")
+	sd       = flag.Duration("sd", 5*time.Second, "scanning duration, 0 for indefinitely")
+	interval = flag.Duration("interval", 30*time.Millisecond, "connection interval")
+	latency  = flag.Int("latency", 0, "peripheral latency, in connection events")
+	timeout  = flag.Duration("timeout", 720*time.Millisecond, "supervision timeout")
+	missed   = flag.Int("missed", 6, "consecutive connection events to survive, for the recommendation")
+	period   = flag.Duration("period", 100*time.Millisecond, "heartbeat period")
+)
+
+func main() {
+	flag.Parse()
+
+	p := ble.ConnParams{Interval: *interval, Latency: *latency, SupervisionTimeout: *timeout}
+	if err := p.Validate(); err != nil {
+		log.Fatalf("invalid connection parameters: %s", err)
+	}
+
+	// Connection parameters are only applied by the Linux HCI backend.
+	d, err := dev.NewDevice(*device, ble.OptConnParams(cmd.LECreateConnection{
+		LEScanInterval:     0x0004,
+		LEScanWindow:       0x0004,
+		ConnIntervalMin:    uint16(p.Interval / (1250 * time.Microsecond)),
+		ConnIntervalMax:    uint16(p.Interval / (1250 * time.Microsecond)),
+		ConnLatency:        uint16(p.Latency),
+		SupervisionTimeout: uint16(p.SupervisionTimeout / (10 * time.Millisecond)),
+	}))
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	ble.SetDefaultDevice(d)
+
+	filter := func(a ble.Advertisement) bool {
+		return strings.ToUpper(a.LocalName()) == strings.ToUpper(*name)
+	}
+	if len(*addr) != 0 {
+		filter = func(a ble.Advertisement) bool {
+			return strings.ToUpper(a.Addr().String()) == strings.ToUpper(*addr)
+		}
+	}
+
+	fmt.Printf("Scanning for %s...\n", *sd)
+	ctx := ble.WithSigHandler(context.WithTimeout(context.Background(), *sd))
+	cln, err := ble.Connect(ctx, filter)
+	if err != nil {
+		log.Fatalf("can't connect : %s", err)
+	}
+
+	// The Device Name of the GAP service is always present, and readable.
+	prof, err := cln.DiscoverProfile(true)
+	if err != nil {
+		log.Fatalf("can't discover profile: %s", err)
+	}
+	c, ok := prof.Find(ble.NewCharacteristic(ble.DeviceNameUUID)).(*ble.Characteristic)
+	if !ok {
+		log.Fatalf("can't find the device name characteristic")
+	}
+	beat := func(cln ble.Client) error {
+		_, err := cln.ReadCharacteristic(c)
+		return err
+	}
+
+	fmt.Printf("Connected to [ %s ] with %s interval, %d latency, %s supervision timeout\n",
+		cln.Addr(), p.Interval, p.Latency, p.SupervisionTimeout)
+	fmt.Printf("Now power off or shield the peripheral, and wait for the disconnection...\n")
+
+	ctx = ble.WithSigHandler(context.WithCancel(context.Background()))
+	r, err := ble.Heartbeat(ctx, cln, *period, beat)
+	if err != nil {
+		cln.CancelConnection()
+		log.Fatalf("heartbeat stopped: %s", err)
+	}
+
+	fmt.Printf("Disconnected after %d heartbeats, %d failed\n", r.Beats, r.Failures)
+	fmt.Printf("Detected the loss in %s, between %s and %s after the radio was lost\n",
+		r.Detection, r.Detection-*period, r.Detection)
+
+	rec := ble.RecommendConnParams(p.Interval, p.Latency, *missed)
+	fmt.Printf("To survive %d lost connection events, and detect a loss as soon as possible, use\n", *missed)
+	fmt.Printf("  -interval %s -latency %d -timeout %s\n", rec.Interval, rec.Latency, rec.SupervisionTimeout)
+	fmt.Printf("The current parameters survive %d lost connection events\n", p.MissedEvents())
+}
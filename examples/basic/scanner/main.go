@@ -21,11 +21,10 @@ var (
 func main() {
 	flag.Parse()
 
-	d, err := dev.NewDevice(*device)
-	if err != nil {
+	if _, err := dev.GetDevice(); err != nil {
 		log.Fatalf("can't new device : %s", err)
 	}
-	ble.SetDefaultDevice(d)
+	defer dev.Stop()
 
 	// Scan for specified durantion, or until interrupted by user.
 	fmt.Printf("Scanning for %s...\n", *du)
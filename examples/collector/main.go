@@ -0,0 +1,147 @@
+// Collector keeps connections to a set of sensors, subscribes to one of their
+// characteristics, and persists the readings to a CSV file.
+//
+// It is laid out as a gateway would be:
+//
+//   - A single scan runs for the whole session, and records the
+//     advertisements in an AdvHistory, which tells which sensors are present.
+//   - Every sensor has a Connector, which dials it once it's present, restores
+//     the subscription on every connection, and backs off while it's away.
+//   - The readings are passed to a single writer, so slow storage doesn't
+//     stall the notifications.
+//   - The metrics of the stack, and of the collector, are served over HTTP.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/examples/lib/dev"
+)
+
+var (
+	device   = flag.String("device", "default", "implementation of ble")
+	addrs    = flag.String("addrs", "", "comma separated addresses of the sensors")
+	char     = flag.String("char", "2a37", "UUID of the characteristic to subscribe to")
+	ind      = flag.Bool("ind", false, "subscribe to indications, rather than notifications")
+	out      = flag.String("out", "readings.csv", "CSV file the readings are appended to")
+	listen   = flag.String("listen", ":9100", "address the metrics are served at, empty to disable")
+	presence = flag.Duration("presence", 10*time.Second, "time since the last advertisement, for a sensor to be present")
+	attempt  = flag.Duration("attempt", 10*time.Second, "limit of each attempt to connect")
+	optmo    = flag.Duration("optimeout", 5*time.Second, "timeout of the GATT operations")
+)
+
+// Metrics of the collector, served along with the ones of the stack.
+const (
+	metricReadings  = "collector_readings_total"
+	metricConnected = "collector_sensors_connected"
+	metricDropped   = "collector_readings_dropped_total"
+)
+
+func main() {
+	flag.Parse()
+
+	if *addrs == "" {
+		log.Fatalf("no sensors given with -addrs")
+	}
+	u, err := ble.Parse(*char)
+	if err != nil {
+		log.Fatalf("invalid characteristic UUID: %s", err)
+	}
+
+	m := ble.NewMetrics("app", "collector")
+	d, err := dev.NewDevice(*device, ble.OptMetrics(m))
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	ble.SetDefaultDevice(d)
+	defer d.Stop()
+
+	s, err := newStore(*out, m)
+	if err != nil {
+		log.Fatalf("can't open store: %s", err)
+	}
+	defer s.Close()
+
+	if *listen != "" {
+		http.Handle("/metrics", m)
+		go func() { log.Printf("metrics server stopped: %s", http.ListenAndServe(*listen, nil)) }()
+	}
+
+	ctx := ble.WithSigHandler(context.WithCancel(context.Background()))
+
+	// Keep a few advertisements of every sensor, which is plenty to tell
+	// whether it's around.
+	sensors := strings.Split(*addrs, ",")
+	hist := ble.NewAdvHistory(16*len(sensors), *presence)
+	go func() {
+		err := ble.Scan(ctx, true, hist.Handler(nil), nil)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("scan stopped: %s", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for _, a := range sensors {
+		a := ble.NewAddr(strings.TrimSpace(a))
+		c := ble.NewConnector(dialPresent(hist, a), ble.ReconnectPolicy{
+			MaxBackoff:     30 * time.Second,
+			Jitter:         0.2,
+			AttemptTimeout: *attempt,
+		})
+		c.OnStateChange = func(st ble.ConnState, err error) {
+			switch {
+			case st == ble.StateConnected:
+				m.Add(metricConnected, 1)
+			case st == ble.StateDisconnected && err == nil:
+				m.Add(metricConnected, -1)
+			}
+			if err != nil {
+				log.Printf("[ %s ] %s: %s", a, st, err)
+				return
+			}
+			log.Printf("[ %s ] %s", a, st)
+		}
+		if err := c.Subscribe(u, *ind, func(b []byte) { s.Write(a, b) }); err != nil {
+			log.Fatalf("can't subscribe: %s", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[ %s ] gave up: %s", a, err)
+			}
+		}()
+	}
+
+	fmt.Printf("Collecting %s from %d sensors into %s\n", u, len(sensors), *out)
+	wg.Wait()
+}
+
+// dialPresent returns a Dialer, which waits until a is present, and then
+// dials it with the address type it advertised with. Dialing a sensor which
+// is away would hold up the dials of the others.
+func dialPresent(hist *ble.AdvHistory, a ble.Addr) ble.Dialer {
+	return func(ctx context.Context) (ble.Client, error) {
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			if r, ok := hist.Last(a); ok && time.Since(r.Time) < *presence {
+				ctx = ble.WithAdvertisement(ctx, r.Advertisement)
+				return ble.Dial(ble.WithClientTimeout(ctx, *optmo), a)
+			}
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// A reading is a value notified by a sensor.
+type reading struct {
+	time time.Time
+	addr ble.Addr
+	v    []byte
+}
+
+// A store appends the readings to a CSV file, with a row of the time, the
+// address of the sensor, and the value in hex. The file is written by a
+// single goroutine, and readings are dropped rather than stalling the
+// notifications when it falls behind.
+type store struct {
+	f    *os.File
+	ch   chan reading
+	done chan struct{}
+	m    ble.MetricsCollector
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newStore(name string, m ble.MetricsCollector) (*store, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &store{f: f, ch: make(chan reading, 256), done: make(chan struct{}), m: m}
+	go s.loop()
+	return s, nil
+}
+
+// Write queues a reading. The value is copied, as the buffers of the
+// notifications are reused. Readings after Close are dropped.
+func (s *store) Write(a ble.Addr, v []byte) {
+	r := reading{time: time.Now(), addr: a, v: append([]byte(nil), v...)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- r:
+		s.m.Add(metricReadings, 1)
+	default:
+		s.m.Add(metricDropped, 1)
+	}
+}
+
+// Close writes the queued readings, and closes the file.
+func (s *store) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	close(s.ch)
+	s.mu.Unlock()
+	<-s.done
+	return s.f.Close()
+}
+
+func (s *store) loop() {
+	defer close(s.done)
+	w := csv.NewWriter(s.f)
+	for r := range s.ch {
+		w.Write([]string{r.time.UTC().Format(time.RFC3339Nano), r.addr.String(), fmt.Sprintf("%x", r.v)})
+		// Flush when idle, so a crash loses little, without a write per reading.
+		if len(s.ch) == 0 {
+			w.Flush()
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/examples/integration"
+	"github.com/kirbo/ble/examples/lib/dev"
+)
+
+var (
+	hci  = flag.Int("hci", -1, "id of the HCI adapter, or -1 for the default one")
+	name = flag.String("name", integration.DefaultName, "local name of the peripheral")
+	tmo  = flag.Duration("timeout", 30*time.Second, "timeout of the session")
+)
+
+func main() {
+	flag.Parse()
+
+	var opts []ble.Option
+	if *hci >= 0 {
+		opts = append(opts, ble.OptDeviceID(*hci))
+	}
+	d, err := dev.GetDevice(opts...)
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	defer dev.Stop()
+
+	ctx := ble.WithSigHandler(context.WithTimeout(context.Background(), *tmo))
+	steps, err := integration.Central(ctx, d, *name)
+	for _, s := range steps {
+		fmt.Printf("PASS %-16s %s\n", s.Name, s.Duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		// Exit after stopping the device, so that the connection is closed.
+		dev.Stop()
+		log.Fatalf("FAIL %s", err)
+	}
+	fmt.Printf("ok\n")
+}
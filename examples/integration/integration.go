@@ -0,0 +1,230 @@
+// Package integration is the end-to-end test of a pair of devices: the
+// peripheral serves the test service, and the central runs the steps of a
+// session against it, scan and connect, discover, read, write, subscribe and
+// disconnect. The peripheral and central binaries run them on two adapters,
+// and the test harness runs them on the mock transport, or on the adapters
+// it's given.
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// Private 128-bit UUIDs of the test service, and its characteristics.
+var (
+	ServiceUUID    = ble.MustParse("00030000-0001-1000-8000-00805F9B34FB")
+	ReadCharUUID   = ble.MustParse("00030000-0002-1000-8000-00805F9B34FB")
+	WriteCharUUID  = ble.MustParse("00030000-0003-1000-8000-00805F9B34FB")
+	NotifyCharUUID = ble.MustParse("00030000-0004-1000-8000-00805F9B34FB")
+)
+
+// DefaultName is the local name the peripheral advertises.
+const DefaultName = "ble-integration"
+
+// Greeting is the value of the read characteristic.
+var Greeting = []byte("hello, central")
+
+// Notifications is the number of values the notify characteristic notifies
+// to each subscriber, the decimal numbers from 0.
+const Notifications = 5
+
+// NotifyInterval is the time between the notifications.
+const NotifyInterval = 20 * time.Millisecond
+
+// NewService returns the test service: a read characteristic of Greeting, a
+// characteristic, whose reads return the value last written, and a
+// characteristic, which notifies Notifications values to each subscriber.
+func NewService() *ble.Service {
+	svc := ble.NewService(ServiceUUID)
+	svc.NewCharacteristic(ReadCharUUID).SetValue(Greeting)
+
+	var mu sync.Mutex
+	var v []byte
+	wc := svc.NewCharacteristic(WriteCharUUID)
+	wc.HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		mu.Lock()
+		defer mu.Unlock()
+		rsp.Write(v)
+	}))
+	wc.HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		mu.Lock()
+		defer mu.Unlock()
+		v = append([]byte(nil), req.Data()...)
+	}))
+
+	svc.NewCharacteristic(NotifyCharUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		for i := 0; i < Notifications; i++ {
+			select {
+			case <-n.Context().Done():
+				return
+			case <-time.After(NotifyInterval):
+			}
+			if _, err := n.Write([]byte(strconv.Itoa(i))); err != nil {
+				return
+			}
+		}
+	}))
+	return svc
+}
+
+// Peripheral serves the test service on d, and advertises it with the local
+// name, until ctx is done.
+func Peripheral(ctx context.Context, d ble.Device, name string) error {
+	if err := d.AddService(NewService()); err != nil {
+		return fmt.Errorf("can't add service: %w", err)
+	}
+	if err := d.AdvertiseNameAndServices(ctx, name, ServiceUUID); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("can't advertise: %w", err)
+	}
+	return nil
+}
+
+// A Step is a step of the session of the central, passed.
+type Step struct {
+	Name     string
+	Duration time.Duration
+}
+
+// A StepError is the error of the step of the session, which failed.
+type StepError struct {
+	Step string
+	Err  error
+}
+
+func (e *StepError) Error() string { return fmt.Sprintf("%s: %v", e.Step, e.Err) }
+
+// Unwrap returns the error of the step.
+func (e *StepError) Unwrap() error { return e.Err }
+
+// The steps of the session.
+const (
+	StepConnect    = "scan and connect"
+	StepDiscover   = "discover"
+	StepRead       = "read"
+	StepWrite      = "write"
+	StepSubscribe  = "subscribe"
+	StepDisconnect = "disconnect"
+)
+
+// Central runs the session on d against the peripheral advertising the local
+// name, and returns the steps passed, and the *StepError of the one failed,
+// if any. The connection is closed, whichever step fails.
+func Central(ctx context.Context, d ble.Device, name string) ([]Step, error) {
+	s := &session{}
+
+	var cln ble.Client
+	if err := s.run(StepConnect, func() (err error) {
+		cln, err = ble.DialByName(ctx, d, name, ble.DialByNameExact())
+		return err
+	}); err != nil {
+		return s.steps, err
+	}
+	defer cln.CancelConnection()
+
+	var rc, wc, nc *ble.Characteristic
+	if err := s.run(StepDiscover, func() error {
+		p, err := cln.DiscoverProfile(true)
+		if err != nil {
+			return err
+		}
+		rc = p.FindCharacteristic(ble.NewCharacteristic(ReadCharUUID))
+		wc = p.FindCharacteristic(ble.NewCharacteristic(WriteCharUUID))
+		nc = p.FindCharacteristic(ble.NewCharacteristic(NotifyCharUUID))
+		if rc == nil || wc == nil || nc == nil {
+			return fmt.Errorf("characteristics of %s not found", ServiceUUID)
+		}
+		return nil
+	}); err != nil {
+		return s.steps, err
+	}
+
+	if err := s.run(StepRead, func() error {
+		v, err := cln.ReadCharacteristic(rc)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(v, Greeting) {
+			return fmt.Errorf("read %q, want %q", v, Greeting)
+		}
+		return nil
+	}); err != nil {
+		return s.steps, err
+	}
+
+	if err := s.run(StepWrite, func() error {
+		// Fits in a write of the default MTU.
+		want := []byte(strconv.FormatInt(time.Now().UnixNano(), 36))
+		if err := cln.WriteCharacteristic(wc, want, false); err != nil {
+			return err
+		}
+		v, err := cln.ReadCharacteristic(wc)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(v, want) {
+			return fmt.Errorf("read back %q, want %q", v, want)
+		}
+		return nil
+	}); err != nil {
+		return s.steps, err
+	}
+
+	if err := s.run(StepSubscribe, func() error {
+		vs, unsub, err := ble.SubscribeChan(cln, nc, false)
+		if err != nil {
+			return err
+		}
+		defer unsub()
+		for i := 0; i < Notifications; i++ {
+			select {
+			case v, ok := <-vs:
+				if !ok {
+					return ble.ErrDisconnected
+				}
+				if want := strconv.Itoa(i); string(v) != want {
+					return fmt.Errorf("notified %q, want %q", v, want)
+				}
+			case <-ctx.Done():
+				return fmt.Errorf("notified %d of %d values: %w", i, Notifications, ctx.Err())
+			}
+		}
+		return nil
+	}); err != nil {
+		return s.steps, err
+	}
+
+	err := s.run(StepDisconnect, func() error {
+		if err := cln.CancelConnection(); err != nil {
+			return err
+		}
+		select {
+		case <-cln.Disconnected():
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	return s.steps, err
+}
+
+// session keeps the steps passed.
+type session struct {
+	steps []Step
+}
+
+// run runs the step f, and keeps it, if passed.
+func (s *session) run(name string, f func() error) error {
+	start := time.Now()
+	if err := f(); err != nil {
+		return &StepError{Step: name, Err: err}
+	}
+	s.steps = append(s.steps, Step{Name: name, Duration: time.Since(start)})
+	return nil
+}
@@ -0,0 +1,142 @@
+package integration
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble/mock"
+)
+
+// The adapters to run the binaries on, such as with
+//
+//	sudo go test ./examples/integration -peripheral.hci 0 -central.hci 1
+//
+// The session runs on the mock transport without them.
+var (
+	peripheralHCI = flag.Int("peripheral.hci", -1, "id of the HCI adapter of the peripheral binary")
+	centralHCI    = flag.Int("central.hci", -1, "id of the HCI adapter of the central binary")
+)
+
+var allSteps = []string{StepConnect, StepDiscover, StepRead, StepWrite, StepSubscribe, StepDisconnect}
+
+func checkSteps(t *testing.T, steps []Step) {
+	t.Helper()
+	if len(steps) != len(allSteps) {
+		t.Fatalf("passed %d steps, want %d", len(steps), len(allSteps))
+	}
+	for i, s := range steps {
+		if s.Name != allSteps[i] {
+			t.Errorf("step %d is %q, want %q", i, s.Name, allSteps[i])
+		}
+	}
+}
+
+func TestMock(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Central")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	pctx, stop := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() { served <- Peripheral(pctx, p, DefaultName) }()
+
+	steps, err := Central(ctx, c, DefaultName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSteps(t, steps)
+
+	stop()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+
+	// A peripheral of another name isn't found.
+	sctx, scancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer scancel()
+	steps, err = Central(sctx, c, "missing")
+	var se *StepError
+	if !errors.As(err, &se) || se.Step != StepConnect || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("session with a missing peripheral: %v, want %q to time out", err, StepConnect)
+	}
+	if len(steps) != 0 {
+		t.Errorf("passed %d steps with a missing peripheral", len(steps))
+	}
+}
+
+// TestHardware builds the binaries, and runs them on the adapters of the
+// flags.
+func TestHardware(t *testing.T) {
+	if *peripheralHCI < 0 || *centralHCI < 0 {
+		t.Skip("no adapters, as -peripheral.hci and -central.hci tell")
+	}
+	dir, err := ioutil.TempDir("", "ble-integration")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	bin := func(name string) string {
+		out := filepath.Join(dir, name)
+		if b, err := exec.Command("go", "build", "-o", out, "./"+name).CombinedOutput(); err != nil {
+			t.Fatalf("can't build %s: %v\n%s", name, err, b)
+		}
+		return out
+	}
+	pbin, cbin := bin("peripheral"), bin("central")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	name := DefaultName + "-" + strconv.Itoa(os.Getpid())
+
+	per := exec.CommandContext(ctx, pbin, "-hci", strconv.Itoa(*peripheralHCI), "-name", name)
+	pout, err := per.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	per.Stderr = os.Stderr
+	if err := per.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer per.Process.Kill()
+	// Wait for it to serve.
+	r := bufio.NewReader(pout)
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "Serving") {
+		t.Fatalf("peripheral didn't start: %q, %v", line, err)
+	}
+	go ioutil.ReadAll(r)
+
+	out, err := exec.CommandContext(ctx, cbin, "-hci", strconv.Itoa(*centralHCI), "-name", name).CombinedOutput()
+	t.Logf("central:\n%s", out)
+	if err != nil {
+		t.Fatalf("central failed: %v", err)
+	}
+	if n := strings.Count(string(out), "PASS "); n != len(allSteps) {
+		t.Errorf("passed %d steps, want %d", n, len(allSteps))
+	}
+
+	per.Process.Signal(os.Interrupt)
+	if err := per.Wait(); err != nil {
+		t.Errorf("peripheral failed: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/examples/integration"
+	"github.com/kirbo/ble/examples/lib/dev"
+)
+
+var (
+	hci  = flag.Int("hci", -1, "id of the HCI adapter, or -1 for the default one")
+	name = flag.String("name", integration.DefaultName, "local name to advertise")
+	du   = flag.Duration("du", 0, "serving duration, 0 for until interrupted")
+)
+
+func main() {
+	flag.Parse()
+
+	var opts []ble.Option
+	if *hci >= 0 {
+		opts = append(opts, ble.OptDeviceID(*hci))
+	}
+	d, err := dev.GetDevice(opts...)
+	if err != nil {
+		log.Fatalf("can't new device : %s", err)
+	}
+	defer dev.Stop()
+
+	var ctx context.Context
+	if *du > 0 {
+		ctx = ble.WithSigHandler(context.WithTimeout(context.Background(), *du))
+	} else {
+		ctx = ble.WithSigHandler(context.WithCancel(context.Background()))
+	}
+
+	// The harness waits for this line, before starting the central.
+	fmt.Printf("Serving %s as %q...\n", integration.ServiceUUID, *name)
+	start := time.Now()
+	if err := integration.Peripheral(ctx, d, *name); err != nil {
+		log.Fatalf("can't serve: %s", err)
+	}
+	fmt.Printf("done after %s\n", time.Since(start).Round(time.Millisecond))
+}
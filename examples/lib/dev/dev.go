@@ -1,10 +1,60 @@
 package dev
 
 import (
+	"sync"
+
 	"github.com/kirbo/ble"
 )
 
+var (
+	mu     sync.Mutex
+	device ble.Device
+)
+
 // NewDevice ...
 func NewDevice(impl string, opts ...ble.Option) (d ble.Device, err error) {
 	return DefaultDevice(opts...)
 }
+
+// GetDevice returns the device shared by the example, creating the default
+// one with opts, and setting it as the default device of ble, on the first
+// call. The opts of the later calls are ignored. It is safe for concurrent
+// use.
+func GetDevice(opts ...ble.Option) (ble.Device, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if device != nil {
+		return device, nil
+	}
+	d, err := DefaultDevice(opts...)
+	if err != nil {
+		return nil, err
+	}
+	device = d
+	ble.SetDefaultDevice(d)
+	return d, nil
+}
+
+// SetDefaultDevice sets the device GetDevice returns, e.g. a fake one in
+// tests, in place of creating the default one. It doesn't stop the device
+// set before.
+func SetDefaultDevice(d ble.Device) {
+	mu.Lock()
+	defer mu.Unlock()
+	device = d
+	ble.SetDefaultDevice(d)
+}
+
+// Stop stops the device returned by GetDevice, if any, so that the next
+// call creates it again. The examples defer it in main, for the device to
+// be released on shutdown.
+func Stop() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if device == nil {
+		return nil
+	}
+	d := device
+	device = nil
+	return d.Stop()
+}
@@ -0,0 +1,227 @@
+package ble
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoFanout is the error of Notify and UpdateValue, on a characteristic
+// not configured with HandleFanout.
+var ErrNoFanout = errors.New("characteristic isn't configured with HandleFanout")
+
+// FanoutPolicy configures the sending of the values of a characteristic to
+// the centrals subscribed to it, by Notify and UpdateValue.
+type FanoutPolicy struct {
+	// Indicate makes the characteristic support indications too. The
+	// values are indicated to the centrals, which enabled indications,
+	// and notified to those, which enabled notifications.
+	Indicate bool
+
+	// MinInterval is the least time between two values sent to a central.
+	// The values set meanwhile are coalesced: only the latest is sent, once
+	// the interval elapses. Every value is sent, if 0.
+	MinInterval time.Duration
+
+	// OnError, if set, is called with the errors of the values sent once
+	// the interval elapses, which Notify returned before.
+	OnError func(*DeliveryError)
+}
+
+// A DeliveryError is the error of sending a value to a central.
+type DeliveryError struct {
+	Conn Conn
+	Err  error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("can't send to %s: %v", e.Conn.RemoteAddr(), e.Err)
+}
+
+// Unwrap returns the error of the send.
+func (e *DeliveryError) Unwrap() error { return e.Err }
+
+// DeliveryErrors are the errors of sending a value to the centrals, which it
+// couldn't be sent to; the others got it.
+type DeliveryErrors []*DeliveryError
+
+func (es DeliveryErrors) Error() string {
+	ss := make([]string, len(es))
+	for i, e := range es {
+		ss[i] = e.Error()
+	}
+	return strings.Join(ss, "; ")
+}
+
+// HandleFanout makes the characteristic support notify requests, and
+// indicate requests, if p.Indicate, and sends the values of Notify and
+// UpdateValue to the centrals subscribed. The reads of a characteristic
+// readable without a ReadHandler, such as with a static value set by
+// SetValue, return the value last set by UpdateValue, starting with the
+// static one. HandleFanout must be called before the containing service is
+// added to a server.
+func (c *Characteristic) HandleFanout(p FanoutPolicy) {
+	f := &fanout{p: p, subs: map[*subscriber]bool{}}
+	if c.Value != nil || c.Property&CharRead != 0 && c.ReadHandler == nil {
+		f.v, c.Value = c.Value, nil
+		c.ReadHandler = ReadHandlerFunc(f.read)
+	}
+	c.fanout = f
+	c.HandleNotify(f)
+	if p.Indicate {
+		c.HandleIndicate(f)
+	}
+}
+
+// Notify sends v to every central subscribed to the characteristic, as much
+// of it as fits in a notification, or an indication, of its connection. The
+// centrals, which a value was sent to less than the MinInterval ago, get v
+// once it elapses, unless superseded; their errors are told to OnError. The
+// others get v before Notify returns, and their errors are returned, as
+// DeliveryErrors.
+func (c *Characteristic) Notify(v []byte) error {
+	if c.fanout == nil {
+		return ErrNoFanout
+	}
+	return c.fanout.notify(append([]byte(nil), v...))
+}
+
+// UpdateValue sets the value, which the reads return, and sends it to the
+// centrals subscribed, as Notify does, if notify.
+func (c *Characteristic) UpdateValue(v []byte, notify bool) error {
+	if c.fanout == nil {
+		return ErrNoFanout
+	}
+	v = append([]byte(nil), v...)
+	c.fanout.mu.Lock()
+	c.fanout.v = v
+	c.fanout.mu.Unlock()
+	if !notify {
+		return nil
+	}
+	return c.fanout.notify(v)
+}
+
+// fanout sends the values of a characteristic to its subscribers.
+type fanout struct {
+	p    FanoutPolicy
+	mu   sync.Mutex
+	v    []byte
+	subs map[*subscriber]bool
+}
+
+// subscriber is a central subscribed to the characteristic.
+type subscriber struct {
+	conn Conn
+	n    Notifier
+
+	// mu serializes the values sent, so they arrive in order.
+	mu      sync.Mutex
+	last    time.Time   // When the last value was sent.
+	pending bool        // Whether v awaits the interval to elapse.
+	v       []byte      // Value pending.
+	timer   *time.Timer // Sends v.
+}
+
+func (f *fanout) read(req Request, rsp ResponseWriter) {
+	f.mu.Lock()
+	v := f.v
+	f.mu.Unlock()
+	if req.Offset() > len(v) {
+		rsp.SetStatus(ErrInvalidOffset)
+		return
+	}
+	rsp.Write(v[req.Offset():])
+}
+
+// ServeNotify keeps n among the subscribers, until it's closed.
+func (f *fanout) ServeNotify(req Request, n Notifier) {
+	s := &subscriber{conn: req.Conn(), n: n}
+	f.mu.Lock()
+	f.subs[s] = true
+	f.mu.Unlock()
+	<-n.Context().Done()
+	f.mu.Lock()
+	delete(f.subs, s)
+	f.mu.Unlock()
+	s.mu.Lock()
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.pending, s.v = false, nil
+	s.mu.Unlock()
+}
+
+func (f *fanout) notify(v []byte) error {
+	f.mu.Lock()
+	subs := make([]*subscriber, 0, len(f.subs))
+	for s := range f.subs {
+		subs = append(subs, s)
+	}
+	f.mu.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(subs))
+	for i, s := range subs {
+		wg.Add(1)
+		go func(i int, s *subscriber) {
+			defer wg.Done()
+			errs[i] = f.send(s, v)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var des DeliveryErrors
+	for i, err := range errs {
+		if err != nil {
+			des = append(des, &DeliveryError{Conn: subs[i].conn, Err: err})
+		}
+	}
+	if des != nil {
+		return des
+	}
+	return nil
+}
+
+// send sends v to s, or leaves it pending, if the interval hasn't elapsed.
+func (f *fanout) send(s *subscriber, v []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n.Context().Err() != nil {
+		return nil
+	}
+	if wait := f.p.MinInterval - time.Since(s.last); s.pending || wait > 0 {
+		if !s.pending {
+			s.timer = time.AfterFunc(wait, func() { f.flush(s) })
+		}
+		s.pending, s.v = true, v
+		return nil
+	}
+	return f.write(s, v)
+}
+
+// flush sends the value pending of s, once the interval elapsed.
+func (f *fanout) flush(s *subscriber) {
+	s.mu.Lock()
+	var err error
+	if s.pending && s.n.Context().Err() == nil {
+		err = f.write(s, s.v)
+	}
+	s.pending, s.v = false, nil
+	s.mu.Unlock()
+	if err != nil && f.p.OnError != nil {
+		f.p.OnError(&DeliveryError{Conn: s.conn, Err: err})
+	}
+}
+
+// write sends v to s, as much of it as fits, with s.mu held.
+func (f *fanout) write(s *subscriber, v []byte) error {
+	if c := s.n.Cap(); c > 0 && len(v) > c {
+		v = v[:c]
+	}
+	s.last = time.Now()
+	_, err := s.n.Write(v)
+	return err
+}
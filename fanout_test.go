@@ -0,0 +1,85 @@
+package ble_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestFanout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	ch := svc.NewCharacteristic(gatttest.NotifyCharUUID)
+	ch.SetValue([]byte("init"))
+	ch.HandleFanout(ble.FanoutPolicy{MinInterval: 200 * time.Millisecond})
+	f := gatttest.New(ctx, t, 0, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	nc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+	if v, err := cln.ReadCharacteristic(nc); err != nil || string(v) != "init" {
+		t.Fatalf("read %q, %v, want %q", v, err, "init")
+	}
+
+	got := make(chan []byte, 10)
+	if err := cln.Subscribe(nc, false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	// The subscriber is served in the background.
+	var first []byte
+	for first == nil {
+		if err := ch.UpdateValue([]byte("a"), true); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case first = <-got:
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("notification not received")
+		}
+	}
+	if v, err := cln.ReadCharacteristic(nc); err != nil || string(v) != "a" {
+		t.Fatalf("read %q, %v, want %q", v, err, "a")
+	}
+
+	// Coalesced within the interval.
+	ch.Notify([]byte("b"))
+	ch.Notify([]byte("c"))
+	select {
+	case b := <-got:
+		if string(b) != "c" {
+			t.Fatalf("notified %q, want %q", b, "c")
+		}
+	case <-ctx.Done():
+		t.Fatal("notification not received")
+	}
+	select {
+	case b := <-got:
+		t.Fatalf("notified %q after the coalesced value", b)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Cut to the MTU of the connection.
+	long := bytes.Repeat([]byte{0x55}, 100)
+	if err := ch.Notify(long); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-got:
+		if len(b) != ble.DefaultMTU-3 {
+			t.Fatalf("notified %d bytes, want %d", len(b), ble.DefaultMTU-3)
+		}
+	case <-ctx.Done():
+		t.Fatal("notification not received")
+	}
+
+	if err := ble.NewCharacteristic(gatttest.ReadCharUUID).Notify(nil); err != ble.ErrNoFanout {
+		t.Fatalf("Notify without HandleFanout: %v, want ErrNoFanout", err)
+	}
+}
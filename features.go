@@ -0,0 +1,33 @@
+package ble
+
+// Backend identifies the platform implementation a binary is built against.
+type Backend string
+
+// Backends.
+const (
+	BackendNone Backend = ""
+	BackendHCI  Backend = "hci" // Linux HCI user channel
+	BackendXPC  Backend = "xpc" // macOS blued/bluetoothd over XPC
+)
+
+// FeatureSet reports the optional capabilities supported by the built binary
+// on the current platform.
+type FeatureSet struct {
+	Backend Backend
+
+	Central    bool // scanning and dialing remote peripherals
+	Peripheral bool // advertising and serving a local GATT database
+
+	SimultaneousRoles bool // central and peripheral at once, as far as the controller supports it
+
+	ExtendedAdvertising bool // LE extended advertising and scanning (BT 5.0)
+	SMP                 bool // pairing and link encryption
+	CoC                 bool // L2CAP LE credit based connection-oriented channels
+	EATT                bool // enhanced ATT bearers (BT 5.2)
+}
+
+// Features returns the capabilities of the current platform, so applications
+// running on several platforms can adapt at runtime instead of by build tags.
+func Features() FeatureSet {
+	return features
+}
@@ -0,0 +1,9 @@
+package ble
+
+// CoreBluetooth pairs and encrypts links on its own; the host isn't involved.
+var features = FeatureSet{
+	Backend:    BackendXPC,
+	Central:    true,
+	Peripheral: true,
+	SMP:        true,
+}
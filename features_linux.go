@@ -0,0 +1,12 @@
+package ble
+
+var features = FeatureSet{
+	Backend:             BackendHCI,
+	Central:             true,
+	Peripheral:          true,
+	SimultaneousRoles:   true,
+	ExtendedAdvertising: true,
+	SMP:                 true,
+	CoC:                 true,
+	EATT:                true,
+}
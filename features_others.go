@@ -0,0 +1,6 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package ble
+
+var features = FeatureSet{Backend: BackendNone}
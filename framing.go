@@ -0,0 +1,246 @@
+package ble
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// A Framer frames the data of a CharStream, so that each read returns the
+// data of a single write of the peer, however the values of the MTU cut it.
+// A Framer keeps the state of the frame being received, so each stream has
+// its own.
+type Framer interface {
+	// Encode returns the frame of the data p.
+	Encode(p []byte) ([]byte, error)
+
+	// Decode returns the data of the frames, which b, received after the
+	// bytes passed before, completes. The frames, which don't decode, are
+	// dropped.
+	Decode(b []byte) [][]byte
+}
+
+// LengthFramer returns a Framer, which prefixes each frame with its length,
+// in n bytes, 1, 2 or 4, little endian. Of 2, it frames as FramingLength.
+func LengthFramer(n int) Framer {
+	if n != 1 && n != 4 {
+		n = 2
+	}
+	return &lengthFramer{n: n, want: -1}
+}
+
+type lengthFramer struct {
+	n     int
+	frame []byte
+	want  int // Length of the frame being received, or -1 if not known yet.
+}
+
+func (f *lengthFramer) max() int {
+	switch f.n {
+	case 1:
+		return 0xFF
+	case 2:
+		return MaxFrameLen
+	}
+	return 1<<31 - 1
+}
+
+func (f *lengthFramer) Encode(p []byte) ([]byte, error) {
+	if len(p) > f.max() {
+		return nil, ErrFrameTooLong
+	}
+	b := make([]byte, f.n+len(p))
+	switch f.n {
+	case 1:
+		b[0] = byte(len(p))
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(len(p)))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(len(p)))
+	}
+	copy(b[f.n:], p)
+	return b, nil
+}
+
+func (f *lengthFramer) Decode(b []byte) [][]byte {
+	var fs [][]byte
+	f.frame = append(f.frame, b...)
+	for {
+		if f.want < 0 {
+			if len(f.frame) < f.n {
+				return fs
+			}
+			switch f.n {
+			case 1:
+				f.want = int(f.frame[0])
+			case 2:
+				f.want = int(binary.LittleEndian.Uint16(f.frame))
+			case 4:
+				f.want = int(binary.LittleEndian.Uint32(f.frame) & 0x7FFFFFFF)
+			}
+			f.frame = f.frame[f.n:]
+		}
+		if len(f.frame) < f.want {
+			return fs
+		}
+		fs = append(fs, append([]byte(nil), f.frame[:f.want]...))
+		f.frame = append([]byte(nil), f.frame[f.want:]...)
+		f.want = -1
+	}
+}
+
+// DelimiterFramer returns a Framer, which ends each frame with the byte d,
+// such as '\n' of the line-based protocols. The data must not contain d; the
+// empty frames are dropped.
+func DelimiterFramer(d byte) Framer {
+	return &delimFramer{d: d}
+}
+
+type delimFramer struct {
+	d     byte
+	frame []byte
+}
+
+// ErrFrameDelimiter is returned by the writes of data, which contain the
+// delimiter of a DelimiterFramer.
+var ErrFrameDelimiter = errors.New("frame contains its delimiter")
+
+func (f *delimFramer) Encode(p []byte) ([]byte, error) {
+	if bytes.IndexByte(p, f.d) >= 0 {
+		return nil, ErrFrameDelimiter
+	}
+	return append(append([]byte(nil), p...), f.d), nil
+}
+
+func (f *delimFramer) Decode(b []byte) [][]byte {
+	return splitFrames(&f.frame, b, f.d, func(p []byte) ([]byte, bool) {
+		return append([]byte(nil), p...), true
+	})
+}
+
+// splitFrames appends b to the frame *frame, and returns the frames, ended
+// by d, decoded by dec. The empty frames, and those dec fails, are dropped.
+func splitFrames(frame *[]byte, b []byte, d byte, dec func([]byte) ([]byte, bool)) [][]byte {
+	var fs [][]byte
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, d)
+		if i < 0 {
+			*frame = append(*frame, b...)
+			break
+		}
+		*frame = append(*frame, b[:i]...)
+		b = b[i+1:]
+		if len(*frame) > 0 {
+			if p, ok := dec(*frame); ok {
+				fs = append(fs, p)
+			}
+		}
+		*frame = (*frame)[:0]
+	}
+	return fs
+}
+
+// SLIP bytes. [RFC 1055]
+const (
+	slipEnd    = 0xC0
+	slipEsc    = 0xDB
+	slipEscEnd = 0xDC
+	slipEscEsc = 0xDD
+)
+
+// SLIPFramer returns a Framer of SLIP, which ends each frame with 0xC0, the
+// occurrences of which, and of 0xDB, in the data are escaped. The frames
+// start with 0xC0 too, which flushes any noise the peer received before.
+// [RFC 1055]
+func SLIPFramer() Framer {
+	return &slipFramer{}
+}
+
+type slipFramer struct {
+	frame []byte
+}
+
+func (f *slipFramer) Encode(p []byte) ([]byte, error) {
+	b := make([]byte, 0, len(p)+2)
+	b = append(b, slipEnd)
+	for _, c := range p {
+		switch c {
+		case slipEnd:
+			b = append(b, slipEsc, slipEscEnd)
+		case slipEsc:
+			b = append(b, slipEsc, slipEscEsc)
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, slipEnd), nil
+}
+
+func (f *slipFramer) Decode(b []byte) [][]byte {
+	return splitFrames(&f.frame, b, slipEnd, func(e []byte) ([]byte, bool) {
+		p := make([]byte, 0, len(e))
+		for i := 0; i < len(e); i++ {
+			if e[i] != slipEsc {
+				p = append(p, e[i])
+				continue
+			}
+			if i++; i == len(e) {
+				return nil, false
+			}
+			switch e[i] {
+			case slipEscEnd:
+				p = append(p, slipEnd)
+			case slipEscEsc:
+				p = append(p, slipEsc)
+			default:
+				return nil, false
+			}
+		}
+		return p, true
+	})
+}
+
+// COBSFramer returns a Framer of Consistent Overhead Byte Stuffing, which
+// ends each frame with 0x00, and encodes the data without it, at an overhead
+// of a byte per 254.
+func COBSFramer() Framer {
+	return &cobsFramer{}
+}
+
+type cobsFramer struct {
+	frame []byte
+}
+
+func (f *cobsFramer) Encode(p []byte) ([]byte, error) {
+	b := make([]byte, 1, len(p)+len(p)/254+2)
+	code := 0 // Index of the code byte of the block being encoded.
+	for _, c := range p {
+		if c != 0 {
+			b = append(b, c)
+		}
+		if c == 0 || len(b)-code == 0xFF {
+			b[code] = byte(len(b) - code)
+			code = len(b)
+			b = append(b, 0)
+		}
+	}
+	b[code] = byte(len(b) - code)
+	return append(b, 0), nil
+}
+
+func (f *cobsFramer) Decode(b []byte) [][]byte {
+	return splitFrames(&f.frame, b, 0, func(e []byte) ([]byte, bool) {
+		p := make([]byte, 0, len(e))
+		for i := 0; i < len(e); {
+			n := int(e[i])
+			if n == 0 || i+n > len(e) {
+				return nil, false
+			}
+			p = append(p, e[i+1:i+n]...)
+			if i += n; n < 0xFF && i < len(e) {
+				p = append(p, 0)
+			}
+		}
+		return p, true
+	})
+}
@@ -0,0 +1,88 @@
+package ble
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFramers(t *testing.T) {
+	data := [][]byte{
+		{},
+		{0x00},
+		{0x11, 0x22, 0x00, 0x33},
+		{0xC0, 0xDB, 0xDC, 0xDD, 0x0A},
+		bytes.Repeat([]byte{0x01}, 254),
+		bytes.Repeat([]byte{0x02}, 600),
+	}
+	for _, c := range []struct {
+		name  string
+		new   func() Framer
+		empty bool // Drops the empty frames.
+		delim bool // Rejects the data of '\n'.
+	}{
+		{"length 1", func() Framer { return LengthFramer(1) }, false, false},
+		{"length 2", func() Framer { return LengthFramer(2) }, false, false},
+		{"length 4", func() Framer { return LengthFramer(4) }, false, false},
+		{"delimiter", func() Framer { return DelimiterFramer('\n') }, true, true},
+		{"slip", SLIPFramer, true, false},
+		{"cobs", COBSFramer, false, false},
+	} {
+		enc, dec := c.new(), c.new()
+		var stream []byte
+		var want [][]byte
+		for _, p := range data {
+			b, err := enc.Encode(p)
+			if err != nil {
+				continue
+			}
+			if c.delim && bytes.IndexByte(p, '\n') >= 0 {
+				t.Errorf("%s: encoded % X, which has the delimiter", c.name, p)
+			}
+			stream = append(stream, b...)
+			if !c.empty || len(p) > 0 {
+				want = append(want, p)
+			}
+		}
+		// Received a byte at a time, as the values of the MTU may cut them.
+		var got [][]byte
+		for i := range stream {
+			got = append(got, dec.Decode(stream[i:i+1])...)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: %d frames, want %d", c.name, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if !bytes.Equal(got[i], want[i]) {
+				t.Errorf("%s: frame %d = % X, want % X", c.name, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestFramerEncodings(t *testing.T) {
+	for _, c := range []struct {
+		f    Framer
+		p    []byte
+		want []byte
+	}{
+		{COBSFramer(), []byte{0x00}, []byte{0x01, 0x01, 0x00}},
+		{COBSFramer(), []byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33, 0x00}},
+		{SLIPFramer(), []byte{0x01, 0xC0, 0xDB}, []byte{0xC0, 0x01, 0xDB, 0xDC, 0xDB, 0xDD, 0xC0}},
+		{LengthFramer(2), []byte{0xAA}, []byte{0x01, 0x00, 0xAA}},
+	} {
+		if b, err := c.f.Encode(c.p); err != nil || !bytes.Equal(b, c.want) {
+			t.Errorf("%T.Encode(% X) = % X, %v, want % X", c.f, c.p, b, err, c.want)
+		}
+	}
+	if _, err := LengthFramer(1).Encode(make([]byte, 256)); err != ErrFrameTooLong {
+		t.Errorf("long frame: %v, want ErrFrameTooLong", err)
+	}
+	if _, err := DelimiterFramer('\n').Encode([]byte("a\nb")); err != ErrFrameDelimiter {
+		t.Errorf("delimited frame: %v, want ErrFrameDelimiter", err)
+	}
+	// Malformed frames are dropped, and the next ones decoded.
+	if got := SLIPFramer().Decode([]byte{0xDB, 0x01, 0xC0, 0x02, 0xC0}); len(got) != 1 || !bytes.Equal(got[0], []byte{0x02}) {
+		t.Errorf("SLIP after a bad escape = % X", got)
+	}
+}
@@ -133,37 +133,46 @@ func Connect(ctx context.Context, f AdvFilter) (Client, error) {
 		}
 	}()
 
-	ch := make(chan Advertisement)
+	// The first match is kept before the scan is canceled, and any later
+	// ones are dropped.
+	ch := make(chan Advertisement, 1)
 	fn := func(a Advertisement) {
+		select {
+		case ch <- a:
+		default:
+		}
 		cancel()
-		ch <- a
 	}
 	if err := Scan(ctx2, false, fn, f); err != nil {
 		if err != context.Canceled {
-			return nil, errors.Wrap(err, "can't scan")
+			return nil, wrap(err, "can't scan")
 		}
 	}
 
-	cln, err := Dial(ctx, (<-ch).Addr())
-	return cln, errors.Wrap(err, "can't dial")
+	var a Advertisement
+	select {
+	case a = <-ch:
+	default:
+		// ctx was canceled before a match was found.
+		return nil, wrap(ctx.Err(), "can't scan")
+	}
+	cln, err := Dial(WithAdvertisement(ctx, a), a.Addr())
+	return cln, wrap(err, "can't dial")
 }
 
 // A NotificationHandler handles notification or indication from a server.
 type NotificationHandler func(req []byte)
 
-// WithSigHandler ...
+// WithSigHandler returns a copy of ctx, which makes the package level
+// functions call cancel on SIGINT or SIGTERM while they run.
 func WithSigHandler(ctx context.Context, cancel func()) context.Context {
 	return context.WithValue(ctx, ContextKeySig, cancel)
 }
 
 // Cleanup for the interrupted case.
 func trap(ctx context.Context) chan<- os.Signal {
-	v := ctx.Value(ContextKeySig)
-	if v == nil {
-		return nil
-	}
-	cancel, ok := v.(func())
-	if cancel == nil || !ok {
+	cancel, ok := SigHandlerFromContext(ctx)
+	if !ok {
 		return nil
 	}
 
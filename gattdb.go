@@ -0,0 +1,306 @@
+package ble
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// An Attribute is an entry of the attribute table of a GATT server, as
+// returned by the GATTDump of a Device, such as to debug the handles a
+// client caches. [Vol 3, Part F, 3.2]
+type Attribute struct {
+	Handle    uint16
+	EndHandle uint16 // Last handle of a service, or a characteristic, of its declaration; 0 otherwise.
+	Type      UUID
+	Value     []byte // Static value, or nil if served by a handler.
+	Dynamic   bool   // The value is served by a handler.
+
+	ReadPermission  Permission
+	WritePermission Permission
+}
+
+func (a Attribute) String() string {
+	s := fmt.Sprintf("0x%04X", a.Handle)
+	if a.EndHandle != 0 {
+		s += fmt.Sprintf("-0x%04X", a.EndHandle)
+	}
+	s += " " + a.Type.String()
+	if n := Name(a.Type); n != "" {
+		s += " (" + n + ")"
+	}
+	switch {
+	case a.Dynamic:
+		s += " <dynamic>"
+	case a.Value != nil:
+		s += fmt.Sprintf(" [% X]", a.Value)
+	}
+	if a.ReadPermission != 0 || a.WritePermission != 0 {
+		s += fmt.Sprintf(" read %s write %s", permNames(a.ReadPermission), permNames(a.WritePermission))
+	}
+	return s
+}
+
+// A ServiceDesc describes a service declaratively, as LoadServices loads it
+// from JSON, and DescribeServices exports it, such as for tests, simulators
+// and debugging. The UUIDs are as Parse takes them, and the values in hex.
+type ServiceDesc struct {
+	UUID            string               `json:"uuid"`
+	Characteristics []CharacteristicDesc `json:"characteristics,omitempty"`
+}
+
+// A CharacteristicDesc describes a characteristic of a ServiceDesc. The
+// properties are of "broadcast", "read", "writeWithoutResponse", "write",
+// "notify", "indicate", "signedWrite" and "extended", and the permissions of
+// "encrypt", "authenticate" and "authorize".
+type CharacteristicDesc struct {
+	UUID            string           `json:"uuid"`
+	Properties      []string         `json:"properties"`
+	Value           string           `json:"value,omitempty"`
+	ReadPermission  []string         `json:"readPermission,omitempty"`
+	WritePermission []string         `json:"writePermission,omitempty"`
+	Descriptors     []DescriptorDesc `json:"descriptors,omitempty"`
+}
+
+// A DescriptorDesc describes a descriptor of the static value Value of a
+// CharacteristicDesc.
+type DescriptorDesc struct {
+	UUID  string `json:"uuid"`
+	Value string `json:"value,omitempty"`
+}
+
+var propNames = []struct {
+	p    Property
+	name string
+}{
+	{CharBroadcast, "broadcast"},
+	{CharRead, "read"},
+	{CharWriteNR, "writeWithoutResponse"},
+	{CharWrite, "write"},
+	{CharNotify, "notify"},
+	{CharIndicate, "indicate"},
+	{CharSignedWrite, "signedWrite"},
+	{CharExtended, "extended"},
+}
+
+var permNamesOf = []struct {
+	p    Permission
+	name string
+}{
+	{PermEncrypt, "encrypt"},
+	{PermAuthenticate, "authenticate"},
+	{PermAuthorize, "authorize"},
+}
+
+func permNames(p Permission) []string {
+	var ns []string
+	for _, pn := range permNamesOf {
+		if p&pn.p != 0 {
+			ns = append(ns, pn.name)
+		}
+	}
+	return ns
+}
+
+func parsePerm(ns []string) (Permission, error) {
+	var p Permission
+next:
+	for _, n := range ns {
+		for _, pn := range permNamesOf {
+			if strings.EqualFold(n, pn.name) {
+				p |= pn.p
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("unknown permission %q", n)
+	}
+	return p, nil
+}
+
+// LoadServices loads the services of the JSON array of ServiceDescs r
+// reads. The characteristics, which are written, serve the values last
+// written, and notify or indicate them to the subscribed clients; the rest
+// serve their static values.
+func LoadServices(r io.Reader) ([]*Service, error) {
+	var ds []ServiceDesc
+	if err := json.NewDecoder(r).Decode(&ds); err != nil {
+		return nil, err
+	}
+	var ss []*Service
+	for _, d := range ds {
+		s, err := d.Service()
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, nil
+}
+
+// Service returns the service d describes.
+func (d ServiceDesc) Service() (*Service, error) {
+	u, err := Parse(d.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("service %q: %v", d.UUID, err)
+	}
+	s := NewService(u)
+	for _, cd := range d.Characteristics {
+		if err := cd.build(s); err != nil {
+			return nil, fmt.Errorf("service %s: characteristic %q: %v", u, cd.UUID, err)
+		}
+	}
+	return s, s.Validate()
+}
+
+func (cd CharacteristicDesc) build(s *Service) error {
+	u, err := Parse(cd.UUID)
+	if err != nil {
+		return err
+	}
+	var p Property
+next:
+	for _, n := range cd.Properties {
+		for _, pn := range propNames {
+			if strings.EqualFold(n, pn.name) {
+				p |= pn.p
+				continue next
+			}
+		}
+		return fmt.Errorf("unknown property %q", n)
+	}
+	v, err := hex.DecodeString(cd.Value)
+	if err != nil {
+		return err
+	}
+	rp, err := parsePerm(cd.ReadPermission)
+	if err != nil {
+		return err
+	}
+	wp, err := parsePerm(cd.WritePermission)
+	if err != nil {
+		return err
+	}
+	b := s.BuildCharacteristic(u).Properties(p).Permissions(rp, wp)
+	const writes = CharWrite | CharWriteNR | CharSignedWrite
+	if p&(writes|CharNotify|CharIndicate) == 0 {
+		if p&CharRead != 0 {
+			b.Value(v)
+		}
+	} else {
+		sv := &storedValue{v: v, subs: map[chan []byte]bool{}}
+		if p&CharRead != 0 {
+			b.OnRead(ReadHandlerFunc(sv.read))
+		}
+		if p&writes != 0 {
+			b.OnWrite(WriteHandlerFunc(sv.write))
+		}
+		if p&CharNotify != 0 {
+			b.OnNotify(NotifyHandlerFunc(sv.notify))
+		}
+		if p&CharIndicate != 0 {
+			b.OnIndicate(NotifyHandlerFunc(sv.notify))
+		}
+	}
+	for _, dd := range cd.Descriptors {
+		du, err := Parse(dd.UUID)
+		if err != nil {
+			return fmt.Errorf("descriptor %q: %v", dd.UUID, err)
+		}
+		dv, err := hex.DecodeString(dd.Value)
+		if err != nil {
+			return fmt.Errorf("descriptor %q: %v", dd.UUID, err)
+		}
+		b.Descriptor(du, dv)
+	}
+	return nil
+}
+
+// storedValue is the value of a characteristic, loaded by LoadServices,
+// which the writes change, and the subscribed clients are notified of.
+type storedValue struct {
+	mu   sync.Mutex
+	v    []byte
+	subs map[chan []byte]bool
+}
+
+func (sv *storedValue) read(req Request, rsp ResponseWriter) {
+	sv.mu.Lock()
+	v := sv.v
+	sv.mu.Unlock()
+	if req.Offset() > len(v) {
+		rsp.SetStatus(ErrInvalidOffset)
+		return
+	}
+	rsp.Write(v[req.Offset():])
+}
+
+func (sv *storedValue) write(req Request, rsp ResponseWriter) {
+	v := append([]byte(nil), req.Data()...)
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.v = v
+	for ch := range sv.subs {
+		select {
+		case ch <- v:
+		default:
+		}
+	}
+}
+
+func (sv *storedValue) notify(req Request, n Notifier) {
+	ch := make(chan []byte, 1)
+	sv.mu.Lock()
+	sv.subs[ch] = true
+	sv.mu.Unlock()
+	defer func() {
+		sv.mu.Lock()
+		delete(sv.subs, ch)
+		sv.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-n.Context().Done():
+			return
+		case v := <-ch:
+			if _, err := n.Write(v); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// DescribeServices returns the ServiceDescs of ss, which LoadServices loads
+// back, as far as the values are static: the values of the handlers are
+// left out.
+func DescribeServices(ss []*Service) []ServiceDesc {
+	var ds []ServiceDesc
+	for _, s := range ss {
+		d := ServiceDesc{UUID: s.UUID.String()}
+		for _, c := range s.Characteristics {
+			cd := CharacteristicDesc{
+				UUID:            c.UUID.String(),
+				Properties:      []string{},
+				Value:           hex.EncodeToString(c.Value),
+				ReadPermission:  permNames(c.ReadPermission),
+				WritePermission: permNames(c.WritePermission),
+			}
+			for _, pn := range propNames {
+				if c.Property&pn.p != 0 {
+					cd.Properties = append(cd.Properties, pn.name)
+				}
+			}
+			for _, dsc := range c.Descriptors {
+				if dsc.UUID.Equal(ClientCharacteristicConfigUUID) {
+					continue // Added by the server.
+				}
+				cd.Descriptors = append(cd.Descriptors, DescriptorDesc{UUID: dsc.UUID.String(), Value: hex.EncodeToString(dsc.Value)})
+			}
+			d.Characteristics = append(d.Characteristics, cd)
+		}
+		ds = append(ds, d)
+	}
+	return ds
+}
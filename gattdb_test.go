@@ -0,0 +1,64 @@
+package ble
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testServicesJSON = `[{
+	"uuid": "5e0a0001-0000-1000-8000-00805f9b34fb",
+	"characteristics": [
+		{"uuid": "2a29", "properties": ["read"], "value": "6b6972626f", "readPermission": ["encrypt"],
+		 "descriptors": [{"uuid": "2901", "value": "4d616b6572"}]},
+		{"uuid": "5e0a0002-0000-1000-8000-00805f9b34fb", "properties": ["read", "write", "notify"], "value": "00"}
+	]
+}]`
+
+func TestLoadServices(t *testing.T) {
+	ss, err := LoadServices(strings.NewReader(testServicesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 1 || len(ss[0].Characteristics) != 2 {
+		t.Fatalf("services %v", ss)
+	}
+	c := ss[0].Characteristics[0]
+	if string(c.Value) != "kirbo" || c.ReadPermission != PermEncrypt || len(c.Descriptors) != 1 {
+		t.Errorf("static characteristic %+v", c)
+	}
+	c = ss[0].Characteristics[1]
+	if c.Value != nil || c.ReadHandler == nil || c.WriteHandler == nil || c.NotifyHandler == nil || c.IndicateHandler != nil {
+		t.Errorf("stored characteristic %+v", c)
+	}
+
+	ds := DescribeServices(ss)
+	if ds[0].UUID != "5e0a000100001000800000805f9b34fb" || ds[0].Characteristics[0].Value != "6b6972626f" {
+		t.Errorf("described %+v", ds)
+	}
+	if p := ds[0].Characteristics[1].Properties; !reflect.DeepEqual(p, []string{"read", "write", "notify"}) {
+		t.Errorf("described properties %v", p)
+	}
+	s, err := ds[0].Service()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := DescribeServices([]*Service{s}); !reflect.DeepEqual(d, ds) {
+		t.Errorf("reloaded %+v, want %+v", d, ds)
+	}
+}
+
+func TestLoadServicesInvalid(t *testing.T) {
+	for _, tt := range []struct{ name, json string }{
+		{"syntax", `[{`},
+		{"uuid", `[{"uuid": "nope"}]`},
+		{"property", `[{"uuid": "180f", "characteristics": [{"uuid": "2a19", "properties": ["readable"]}]}]`},
+		{"permission", `[{"uuid": "180f", "characteristics": [{"uuid": "2a19", "properties": ["read"], "readPermission": ["secret"]}]}]`},
+		{"value", `[{"uuid": "180f", "characteristics": [{"uuid": "2a19", "properties": ["read"], "value": "zz"}]}]`},
+		{"inconsistent", `[{"uuid": "180f", "characteristics": [{"uuid": "2a19", "properties": ["write"], "readPermission": ["encrypt"]}]}]`},
+	} {
+		if _, err := LoadServices(strings.NewReader(tt.json)); err == nil {
+			t.Errorf("%s: loaded", tt.name)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package ble
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
+	"time"
 )
 
 // A ReadHandler handles GATT requests.
@@ -19,6 +21,17 @@ func (f ReadHandlerFunc) ServeRead(req Request, rsp ResponseWriter) {
 	f(req, rsp)
 }
 
+// ReadErrorHandlerFunc is an adapter to allow the use of ordinary functions,
+// which return an error, as Handlers. The error is reported with SetError.
+type ReadErrorHandlerFunc func(req Request, rsp ResponseWriter) error
+
+// ServeRead calls f(req, rsp), and reports its error.
+func (f ReadErrorHandlerFunc) ServeRead(req Request, rsp ResponseWriter) {
+	if err := f(req, rsp); err != nil {
+		rsp.SetError(err)
+	}
+}
+
 // A WriteHandler handles GATT requests.
 type WriteHandler interface {
 	ServeWrite(req Request, rsp ResponseWriter)
@@ -32,6 +45,17 @@ func (f WriteHandlerFunc) ServeWrite(req Request, rsp ResponseWriter) {
 	f(req, rsp)
 }
 
+// WriteErrorHandlerFunc is an adapter to allow the use of ordinary functions,
+// which return an error, as Handlers. The error is reported with SetError.
+type WriteErrorHandlerFunc func(req Request, rsp ResponseWriter) error
+
+// ServeWrite calls f(req, rsp), and reports its error.
+func (f WriteErrorHandlerFunc) ServeWrite(req Request, rsp ResponseWriter) {
+	if err := f(req, rsp); err != nil {
+		rsp.SetError(err)
+	}
+}
+
 // A NotifyHandler handles GATT requests.
 type NotifyHandler interface {
 	ServeNotify(req Request, n Notifier)
@@ -45,8 +69,11 @@ func (f NotifyHandlerFunc) ServeNotify(req Request, n Notifier) {
 	f(req, n)
 }
 
-// Request ...
+// A Request is made by a central to the GATT server. Every central is served
+// on its own connection, with its own MTU, subscriptions and prepared writes.
 type Request interface {
+	// Conn returns the connection of the central, whose RemoteAddr
+	// identifies it, and whose Context carries the per-connection state.
 	Conn() Conn
 	Data() []byte
 	Offset() int
@@ -79,6 +106,12 @@ type ResponseWriter interface {
 	// SetStatus reports the result of the request.
 	SetStatus(status ATTError)
 
+	// SetError reports the request failed with err. An ATTError, which may
+	// be wrapped, is sent to the client as is, so it can react to it, such
+	// as by pairing on ErrAuthentication. Other errors are sent as
+	// ErrUnlikely.
+	SetError(err error)
+
 	// Len ...
 	Len() int
 
@@ -107,6 +140,19 @@ func (r *responseWriter) SetStatus(status ATTError) {
 	r.status = status
 }
 
+// SetError reports the request failed with err.
+func (r *responseWriter) SetError(err error) {
+	var e ATTError
+	switch {
+	case err == nil:
+		r.status = ErrSuccess
+	case errors.As(err, &e):
+		r.status = e
+	default:
+		r.status = ErrUnlikely
+	}
+}
+
 // Len returns length of the buffer.
 // Len returns 0 if it is a dummy write response for WriteCommand.
 func (r *responseWriter) Len() int {
@@ -153,9 +199,51 @@ type Notifier interface {
 	Cap() int
 }
 
+// IndicationPolicy configures the server-side delivery of indications. The
+// indications to a subscriber are queued, and sent one at a time, each being
+// awaited for confirmation.
+type IndicationPolicy struct {
+	// QueueLen is the number of indications that can be pending per
+	// subscriber. Write blocks while the queue is full. Defaults to 16.
+	QueueLen int
+
+	// Timeout is how long to wait for each confirmation. Defaults to 30s,
+	// the ATT transaction timeout. [Vol 3, Part F, 3.3.3]
+	Timeout time.Duration
+
+	// Retries is how many times an unconfirmed indication is resent.
+	Retries int
+
+	// Disconnect closes the connection if an indication remains unconfirmed.
+	Disconnect bool
+
+	// Unsubscribe drops the subscription of a characteristic, whose
+	// indication remains unconfirmed, as if the client disabled its
+	// indications, so the handler is done.
+	Unsubscribe bool
+
+	// MaxPending is the number of indications a client may have pending,
+	// queued or awaiting confirmation, across its subscriptions. Beyond it,
+	// Write fails with ErrIndicationsPending, rather than block. Unlimited if 0.
+	MaxPending int
+
+	// OnResult, if set, is called with the outcome of every indication.
+	OnResult func(IndicationResult)
+}
+
+// IndicationResult is the outcome of an indication.
+type IndicationResult struct {
+	Conn     Conn
+	Handle   uint16 // Handle of the characteristic value.
+	Data     []byte
+	Attempts int
+	Err      error // nil if confirmed.
+}
+
 type notifier struct {
 	ctx    context.Context
 	maxlen int
+	capf   func() int
 	cancel func()
 	send   func([]byte) (int, error)
 }
@@ -169,6 +257,14 @@ func NewNotifier(send func([]byte) (int, error)) Notifier {
 	return n
 }
 
+// NewNotifierWithCap returns a Notifier, whose Cap is told by capf, as the
+// MTU of the connection may change while subscribed.
+func NewNotifierWithCap(send func([]byte) (int, error), capf func() int) Notifier {
+	n := NewNotifier(send).(*notifier)
+	n.capf = capf
+	return n
+}
+
 func (n *notifier) Context() context.Context {
 	return n.ctx
 }
@@ -183,5 +279,8 @@ func (n *notifier) Close() error {
 }
 
 func (n *notifier) Cap() int {
+	if n.capf != nil {
+		return n.capf()
+	}
 	return n.maxlen
 }
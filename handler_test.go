@@ -0,0 +1,25 @@
+package ble
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestResponseWriterSetError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want ATTError
+	}{
+		{nil, ErrSuccess},
+		{ErrAuthorization, ErrAuthorization},
+		{fmt.Errorf("locked: %w", ErrAuthentication), ErrAuthentication},
+		{errors.New("boom"), ErrUnlikely},
+	} {
+		rsp := NewResponseWriter(nil)
+		rsp.SetError(tt.err)
+		if got := rsp.Status(); got != tt.want {
+			t.Errorf("SetError(%v): got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
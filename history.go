@@ -0,0 +1,114 @@
+package ble
+
+import (
+	"sync"
+	"time"
+)
+
+// An AdvRecord is an advertisement, and the time it was received.
+type AdvRecord struct {
+	Time time.Time
+	Advertisement
+}
+
+// An AdvHistory keeps the recent advertisements, bounded by count and age,
+// so that diagnostics can tell what was last heard from a device without the
+// application logging the advertisements itself. It is safe for concurrent
+// use.
+type AdvHistory struct {
+	mu     sync.Mutex
+	recs   []AdvRecord // Ring buffer, next is the oldest once full.
+	next   int
+	full   bool
+	maxAge time.Duration
+	now    func() time.Time
+}
+
+// NewAdvHistory returns an AdvHistory of the last n advertisements, which
+// are at most maxAge old, or of any age if maxAge is 0.
+func NewAdvHistory(n int, maxAge time.Duration) *AdvHistory {
+	if n < 1 {
+		n = 1
+	}
+	return &AdvHistory{recs: make([]AdvRecord, n), maxAge: maxAge, now: time.Now}
+}
+
+// Add records a, as received now.
+func (h *AdvHistory) Add(a Advertisement) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recs[h.next] = AdvRecord{Time: h.now(), Advertisement: a}
+	h.next++
+	if h.next == len(h.recs) {
+		h.next, h.full = 0, true
+	}
+}
+
+// Handler returns an AdvHandler, which records the advertisements, and then
+// passes them to next, unless it is nil.
+func (h *AdvHistory) Handler(next AdvHandler) AdvHandler {
+	return func(a Advertisement) {
+		h.Add(a)
+		if next != nil {
+			next(a)
+		}
+	}
+}
+
+// An AdvQuery selects records of an AdvHistory. Zero fields match any record.
+type AdvQuery struct {
+	Filter ScanFilter // Address and service of the advertisements.
+	Since  time.Time  // Records received at or after Since.
+	Until  time.Time  // Records received before Until.
+	Max    int        // Maximum number of records returned.
+}
+
+// Query returns the records which match q, the most recent first.
+func (h *AdvHistory) Query(q AdvQuery) []AdvRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var since time.Time
+	if h.maxAge > 0 {
+		since = h.now().Add(-h.maxAge)
+	}
+	if q.Since.After(since) {
+		since = q.Since
+	}
+	var recs []AdvRecord
+	n := h.next
+	if h.full {
+		n = len(h.recs)
+	}
+	for i := 0; i < n && (q.Max <= 0 || len(recs) < q.Max); i++ {
+		r := h.recs[(h.next-1-i+len(h.recs))%len(h.recs)]
+		if r.Time.Before(since) {
+			break // The older records are even older.
+		}
+		if !q.Until.IsZero() && !r.Time.Before(q.Until) {
+			continue
+		}
+		if q.Filter.Match(r) {
+			recs = append(recs, r)
+		}
+	}
+	return recs
+}
+
+// Last returns the most recent record of the advertisements from a.
+func (h *AdvHistory) Last(a Addr) (AdvRecord, bool) {
+	recs := h.Query(AdvQuery{Filter: ScanFilter{Addr: a}, Max: 1})
+	if len(recs) == 0 {
+		return AdvRecord{}, false
+	}
+	return recs[0], true
+}
+
+// Len returns the number of records, including those past the maximum age.
+func (h *AdvHistory) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.full {
+		return len(h.recs)
+	}
+	return h.next
+}
@@ -0,0 +1,55 @@
+package ble
+
+import (
+	"testing"
+	"time"
+)
+
+type testAdv struct {
+	Advertisement
+	addr     Addr
+	services []UUID
+}
+
+func (a *testAdv) Addr() Addr       { return a.addr }
+func (a *testAdv) Services() []UUID { return a.services }
+
+func TestAdvHistory(t *testing.T) {
+	now := time.Unix(1000, 0)
+	h := NewAdvHistory(3, time.Minute)
+	h.now = func() time.Time { return now }
+
+	x, y := NewAddr("11:22:33:44:55:66"), NewAddr("66:55:44:33:22:11")
+	battery := UUID16(0x180F)
+	for i, a := range []*testAdv{
+		{addr: x},
+		{addr: y, services: []UUID{battery}},
+		{addr: x},
+		{addr: y},
+	} {
+		now = time.Unix(1000+int64(i)*10, 0)
+		h.Add(a)
+	}
+
+	if n := h.Len(); n != 3 {
+		t.Errorf("Len() = %d, want 3", n)
+	}
+	if r, ok := h.Last(x); !ok || r.Time.Unix() != 1020 {
+		t.Errorf("Last(x) = %v, %v, want the record at 1020", r.Time.Unix(), ok)
+	}
+	if recs := h.Query(AdvQuery{Filter: ScanFilter{Service: battery}}); len(recs) != 1 || recs[0].Time.Unix() != 1010 {
+		t.Errorf("Query(battery) = %d records, want the one at 1010", len(recs))
+	}
+	if recs := h.Query(AdvQuery{Since: time.Unix(1015, 0), Until: time.Unix(1030, 0)}); len(recs) != 1 || recs[0].Time.Unix() != 1020 {
+		t.Errorf("Query(1015..1030) = %d records, want the one at 1020", len(recs))
+	}
+
+	// The first record is evicted by count, and the others expire by age.
+	now = time.Unix(1085, 0)
+	if recs := h.Query(AdvQuery{}); len(recs) != 1 || recs[0].Time.Unix() != 1030 {
+		t.Errorf("Query() after a minute = %d records, want the one at 1030", len(recs))
+	}
+	if _, ok := h.Last(x); ok {
+		t.Error("Last(x) found an expired record")
+	}
+}
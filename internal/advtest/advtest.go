@@ -0,0 +1,152 @@
+// Package advtest checks that the Advertisements of the backends expose the
+// fields of the advertising data alike, so that applications need no
+// per-OS branches to read them.
+package advtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kirbo/ble"
+)
+
+// A Case is an advertisement, with the fields it carries.
+type Case struct {
+	Name             string
+	LocalName        string
+	ManufacturerData []byte
+	ServiceData      []ble.ServiceData
+	Services         []ble.UUID
+	Solicited        []ble.UUID
+	TxPowerLevel     int // Present if not 0.
+	Connectable      bool
+	RSSI             int
+}
+
+// Cases are the advertisements which every backend reports alike.
+var Cases = []Case{
+	{
+		Name:         "name",
+		LocalName:    "Sensor",
+		Services:     []ble.UUID{ble.UUID16(0x180D), ble.UUID16(0x180F)},
+		TxPowerLevel: -8,
+		Connectable:  true,
+		RSSI:         -60,
+	},
+	{
+		Name:             "service data",
+		ManufacturerData: []byte{0x4C, 0x00, 0x02, 0x15},
+		ServiceData:      []ble.ServiceData{{UUID: ble.UUID16(0xFEAA), Data: []byte{0x10, 0xF4, 0x00}}},
+		RSSI:             -72,
+	},
+	{
+		Name:        "128-bit",
+		Services:    []ble.UUID{ble.MustParse("6E400001-B5A3-F393-E0A9-E50E24DCCA9E")},
+		Solicited:   []ble.UUID{ble.UUID16(0x1812)},
+		Connectable: true,
+		RSSI:        -90,
+	},
+}
+
+// AD types of the fields of the Cases. [Core Specification Supplement, Part A, 1]
+const (
+	adFlags          = 0x01
+	adUUID16         = 0x03
+	adUUID32         = 0x05
+	adUUID128        = 0x07
+	adName           = 0x09
+	adTxPower        = 0x0A
+	adSol16          = 0x14
+	adSol128         = 0x15
+	adServiceData16  = 0x16
+	adSol32          = 0x1F
+	adServiceData32  = 0x20
+	adServiceData128 = 0x21
+	adMfrData        = 0xFF
+)
+
+// Data returns the advertising data of c, as a peripheral sends it.
+func (c Case) Data() []byte {
+	var b []byte
+	field := func(typ byte, d []byte) {
+		b = append(append(b, byte(len(d)+1), typ), d...)
+	}
+	field(adFlags, []byte{0x06})
+	if c.LocalName != "" {
+		field(adName, []byte(c.LocalName))
+	}
+	if c.TxPowerLevel != 0 {
+		field(adTxPower, []byte{byte(int8(c.TxPowerLevel))})
+	}
+	uuids := func(us []ble.UUID, t16, t32, t128 byte) {
+		for _, t := range []struct {
+			typ byte
+			w   int
+		}{{t16, 2}, {t32, 4}, {t128, 16}} {
+			var d []byte
+			for _, u := range us {
+				if len(u) == t.w {
+					d = append(d, u...)
+				}
+			}
+			if len(d) != 0 {
+				field(t.typ, d)
+			}
+		}
+	}
+	uuids(c.Services, adUUID16, adUUID32, adUUID128)
+	uuids(c.Solicited, adSol16, adSol32, adSol128)
+	for _, sd := range c.ServiceData {
+		typ := map[int]byte{2: adServiceData16, 4: adServiceData32, 16: adServiceData128}[len(sd.UUID)]
+		field(typ, append(append([]byte{}, sd.UUID...), sd.Data...))
+	}
+	if c.ManufacturerData != nil {
+		field(adMfrData, c.ManufacturerData)
+	}
+	return b
+}
+
+// Check reports the fields of a which differ from those of c.
+func Check(t testing.TB, c Case, a ble.Advertisement) {
+	t.Helper()
+	if got := a.LocalName(); got != c.LocalName {
+		t.Errorf("%s: LocalName() = %q, want %q", c.Name, got, c.LocalName)
+	}
+	if got := a.ManufacturerData(); !bytes.Equal(got, c.ManufacturerData) {
+		t.Errorf("%s: ManufacturerData() = [% X], want [% X]", c.Name, got, c.ManufacturerData)
+	}
+	sd := a.ServiceData()
+	if len(sd) != len(c.ServiceData) {
+		t.Errorf("%s: ServiceData() = %v, want %v", c.Name, sd, c.ServiceData)
+	} else {
+		for i, want := range c.ServiceData {
+			if !sd[i].UUID.Equal(want.UUID) || !bytes.Equal(sd[i].Data, want.Data) {
+				t.Errorf("%s: ServiceData()[%d] = %s [% X], want %s [% X]", c.Name, i, sd[i].UUID, sd[i].Data, want.UUID, want.Data)
+			}
+		}
+	}
+	checkUUIDs(t, c.Name, "Services()", a.Services(), c.Services)
+	checkUUIDs(t, c.Name, "SolicitedService()", a.SolicitedService(), c.Solicited)
+	if got := a.TxPowerLevel(); got != c.TxPowerLevel {
+		t.Errorf("%s: TxPowerLevel() = %d, want %d", c.Name, got, c.TxPowerLevel)
+	}
+	if got := a.Connectable(); got != c.Connectable {
+		t.Errorf("%s: Connectable() = %v, want %v", c.Name, got, c.Connectable)
+	}
+	if got := a.RSSI(); got != c.RSSI {
+		t.Errorf("%s: RSSI() = %d, want %d", c.Name, got, c.RSSI)
+	}
+}
+
+func checkUUIDs(t testing.TB, name, what string, got, want []ble.UUID) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: %s = %v, want %v", name, what, got, want)
+		return
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("%s: %s[%d] = %s, want %s", name, what, i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Package gatttest is the fixture of the tests of GATT clients and servers:
+// a peripheral and a central of the mock transport, on a network of their
+// own, so that the tests of each package set up the same pair alike.
+package gatttest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/mock"
+)
+
+// Private 128-bit UUIDs of the test service, and its characteristics.
+var (
+	ServiceUUID    = ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+	ReadCharUUID   = ble.MustParse("5e0a0002-0000-1000-8000-00805f9b34fb")
+	NotifyCharUUID = ble.MustParse("5e0a0003-0000-1000-8000-00805f9b34fb")
+)
+
+// The addresses and names of the devices.
+const (
+	PeripheralAddr = "11:22:33:44:55:66"
+	PeripheralName = "Peripheral"
+	CentralAddr    = "66:55:44:33:22:11"
+	CentralName    = "Central"
+)
+
+// A Fixture is a peripheral and a central on a network of their own.
+type Fixture struct {
+	t       testing.TB
+	clients []ble.Client
+
+	Network    *mock.Network
+	Peripheral *mock.Device
+	Central    *mock.Device
+}
+
+// New returns a Fixture on a network of the advertising interval, whose
+// peripheral serves svcs, and advertises them until ctx is done. It fails t
+// on error. The devices are stopped by Stop.
+func New(ctx context.Context, t testing.TB, interval time.Duration, svcs ...*ble.Service) *Fixture {
+	t.Helper()
+	f := &Fixture{t: t, Network: mock.NewNetwork(interval)}
+	var err error
+	if f.Peripheral, err = f.Network.NewDevice(PeripheralAddr, PeripheralName); err != nil {
+		t.Fatal(err)
+	}
+	if f.Central, err = f.Network.NewDevice(CentralAddr, CentralName); err != nil {
+		f.Stop()
+		t.Fatal(err)
+	}
+	var uuids []ble.UUID
+	for _, svc := range svcs {
+		if err := f.Peripheral.AddService(svc); err != nil {
+			f.Stop()
+			t.Fatal(err)
+		}
+		uuids = append(uuids, svc.UUID)
+	}
+	go f.Peripheral.AdvertiseNameAndServices(ctx, PeripheralName, uuids...)
+	return f
+}
+
+// Dial connects the central to the peripheral with ctx, without discovering
+// its profile. It fails t on error. The connection is closed by Stop.
+func (f *Fixture) Dial(ctx context.Context) ble.Client {
+	f.t.Helper()
+	cln, err := f.Central.Dial(ctx, f.Peripheral.Addr())
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.clients = append(f.clients, cln)
+	return cln
+}
+
+// Connect connects the central to the peripheral with ctx, and discovers its
+// profile. It fails t on error. The connection is closed by Stop.
+func (f *Fixture) Connect(ctx context.Context) ble.Client {
+	f.t.Helper()
+	cln := f.Dial(ctx)
+	if _, err := cln.DiscoverProfile(true); err != nil {
+		f.t.Fatal(err)
+	}
+	return cln
+}
+
+// Characteristic returns the characteristic u of the discovered profile of
+// cln. It fails t, if there's none.
+func (f *Fixture) Characteristic(cln ble.Client, u ble.UUID) *ble.Characteristic {
+	f.t.Helper()
+	c := cln.Profile().FindCharacteristic(ble.NewCharacteristic(u))
+	if c == nil {
+		f.t.Fatalf("characteristic %s not found", u)
+	}
+	return c
+}
+
+// Stop closes the connections, and stops the devices.
+func (f *Fixture) Stop() {
+	for _, cln := range f.clients {
+		cln.CancelConnection()
+	}
+	if f.Central != nil {
+		f.Central.Stop()
+	}
+	if f.Peripheral != nil {
+		f.Peripheral.Stop()
+	}
+}
@@ -0,0 +1,347 @@
+// Package ws implements the WebSocket connections of the bridges, as much of
+// them as they use: the handshakes, and the frames, read as a stream, or as
+// messages. [RFC 6455]
+package ws
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// guid is appended to the keys of the handshakes. [RFC 6455, 1.3]
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Opcodes of the frames. [RFC 6455, 5.2]
+const (
+	opContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// MaxMessage bounds the messages read by ReadMessage.
+const MaxMessage = 1 << 20
+
+// ErrProtocol is returned by the reads of the frames, which break the
+// protocol.
+var ErrProtocol = errors.New("websocket protocol error")
+
+// A HandshakeError is returned by Dial, if the server doesn't switch
+// protocols.
+type HandshakeError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket handshake: " + e.Status
+}
+
+// Accept accepts the WebSocket request r, or responds with an error, and
+// returns it, if r isn't one.
+func Accept(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" ||
+		!strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		!strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "websocket expected", http.StatusBadRequest)
+		return nil, ErrProtocol
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket unsupported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response can't be hijacked")
+	}
+	c, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return newConn(c, brw.Reader, false), nil
+}
+
+// Dial connects to the ws, or wss, URL rawurl, passing the header h along
+// with the handshake.
+func Dial(rawurl string, h http.Header) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"ws": "80", "wss": "443"}[u.Scheme])
+	}
+	var c net.Conn
+	switch u.Scheme {
+	case "ws":
+		c, err = net.Dial("tcp", host)
+	case "wss":
+		c, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		c.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	hdr := http.Header{}
+	for k, v := range h {
+		hdr[k] = v
+	}
+	hdr.Set("Upgrade", "websocket")
+	hdr.Set("Connection", "Upgrade")
+	hdr.Set("Sec-WebSocket-Key", key)
+	hdr.Set("Sec-WebSocket-Version", "13")
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Path: u.Path, RawQuery: u.RawQuery},
+		Host:   u.Host,
+		Header: hdr,
+	}
+	if err := req.Write(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(c)
+	rsp, err := http.ReadResponse(br, req)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	rsp.Body.Close()
+	if rsp.StatusCode != http.StatusSwitchingProtocols {
+		c.Close()
+		return nil, &HandshakeError{StatusCode: rsp.StatusCode, Status: rsp.Status}
+	}
+	if rsp.Header.Get("Sec-WebSocket-Accept") != accept(key) {
+		c.Close()
+		return nil, ErrProtocol
+	}
+	return newConn(c, br, true), nil
+}
+
+func accept(key string) string {
+	h := sha1.Sum([]byte(key + guid))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// A Conn is a WebSocket connection. Its Read and Write pass the payloads of
+// the data frames as a stream, of binary frames, ignoring their boundaries,
+// while ReadMessage and WriteMessage pass whole messages; a connection is
+// read either way, not both.
+type Conn struct {
+	c      net.Conn
+	br     *bufio.Reader
+	client bool // Masks the frames written, and expects those read unmasked.
+
+	wmu sync.Mutex
+
+	// The data frame being read.
+	op     byte
+	fin    bool
+	n      uint64 // Bytes of the payload left.
+	masked bool
+	mask   [4]byte
+	off    int
+}
+
+func newConn(c net.Conn, br *bufio.Reader, client bool) *Conn {
+	return &Conn{c: c, br: br, client: client}
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	for c.n == 0 {
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	if uint64(len(p)) > c.n {
+		p = p[:c.n]
+	}
+	n, err := c.br.Read(p)
+	c.unmask(p[:n])
+	c.n -= uint64(n)
+	return n, err
+}
+
+// ReadMessage reads the next message, and returns its opcode, OpText or
+// OpBinary, and its payload.
+func (c *Conn) ReadMessage() (byte, []byte, error) {
+	var msg []byte
+	if err := c.next(); err != nil {
+		return 0, nil, err
+	}
+	op := c.op
+	for {
+		if uint64(len(msg))+c.n > MaxMessage {
+			return 0, nil, fmt.Errorf("%w: message too long", ErrProtocol)
+		}
+		b := make([]byte, c.n)
+		if _, err := io.ReadFull(c.br, b); err != nil {
+			return 0, nil, err
+		}
+		c.unmask(b)
+		c.n = 0
+		msg = append(msg, b...)
+		if c.fin {
+			return op, msg, nil
+		}
+		if err := c.next(); err != nil {
+			return 0, nil, err
+		}
+		if c.op != opContinuation {
+			return 0, nil, ErrProtocol
+		}
+	}
+}
+
+// next reads the header of the next data frame, and handles the control
+// frames preceding it.
+func (c *Conn) next() error {
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(c.br, h[:]); err != nil {
+			return err
+		}
+		op := h[0] & 0x0F
+		c.masked = h[1]&0x80 != 0
+		if c.masked == c.client {
+			return ErrProtocol
+		}
+		n := uint64(h[1] & 0x7F)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return err
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(c.br, b[:]); err != nil {
+				return err
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if c.masked {
+			if _, err := io.ReadFull(c.br, c.mask[:]); err != nil {
+				return err
+			}
+		}
+		c.off = 0
+		switch op {
+		case opContinuation, OpText, OpBinary:
+			c.op, c.fin, c.n = op, h[0]&0x80 != 0, n
+			return nil
+		case opClose, opPing, opPong:
+			if n > 125 {
+				return ErrProtocol
+			}
+			b := make([]byte, n)
+			if _, err := io.ReadFull(c.br, b); err != nil {
+				return err
+			}
+			c.unmask(b)
+			switch op {
+			case opClose:
+				c.write(opClose, nil)
+				return io.EOF
+			case opPing:
+				if err := c.write(opPong, b); err != nil {
+					return err
+				}
+			}
+		default:
+			return ErrProtocol
+		}
+	}
+}
+
+func (c *Conn) unmask(b []byte) {
+	if !c.masked {
+		return
+	}
+	for i := range b {
+		b[i] ^= c.mask[(c.off+i)%4]
+	}
+	c.off += len(b)
+}
+
+// Write writes p as a binary message.
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.write(OpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteMessage writes p as a message of op, OpText or OpBinary.
+func (c *Conn) WriteMessage(op byte, p []byte) error {
+	return c.write(op, p)
+}
+
+// write writes a frame of op, and the payload p.
+func (c *Conn) write(op byte, p []byte) error {
+	b := []byte{0x80 | op, 0}
+	switch n := len(p); {
+	case n < 126:
+		b[1] = byte(n)
+	case n <= 0xFFFF:
+		b[1] = 126
+		b = append(b, byte(n>>8), byte(n))
+	default:
+		b[1] = 127
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(n))
+		b = append(b, l[:]...)
+	}
+	if !c.client {
+		b = append(b, p...)
+	} else {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		b[1] |= 0x80
+		b = append(b, mask[:]...)
+		for i, v := range p {
+			b = append(b, v^mask[i%4])
+		}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.c.Write(b)
+	return err
+}
+
+// Close closes the connection, with a Close frame of the normal closure.
+func (c *Conn) Close() error {
+	c.write(opClose, []byte{0x03, 0xE8})
+	return c.c.Close()
+}
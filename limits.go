@@ -0,0 +1,19 @@
+package ble
+
+// ServerLimits limit what each client of the GATT server may do, so that a
+// misbehaving, or malicious, one can't exhaust the server. The zero values
+// impose no limits.
+type ServerLimits struct {
+	// OpsPerSecond is the rate of requests and commands a client may send,
+	// in bursts of up to as many. A client exceeding it is disconnected.
+	OpsPerSecond int
+
+	// PreparedWrites is the number of Prepare Write requests a client may
+	// queue, up to 64. The ones beyond it are rejected with ErrPrepQueueFull.
+	PreparedWrites int
+
+	// Subscriptions is the number of characteristics a client may subscribe
+	// to the notifications, or indications, of. The ones beyond it are
+	// rejected with ErrInsuffResources.
+	Subscriptions int
+}
@@ -6,6 +6,11 @@ import "errors"
 // and ScanResponsePacket length.
 const MaxEIRPacketLength = 31
 
+// MaxExtendedPacketLength is the maximum allowed length of the advertising
+// data and scan response data of extended advertising. Controllers may
+// support less. [Vol 2, Part E, 7.8.57]
+const MaxExtendedPacketLength = 1650
+
 // ErrNotFit ...
 var (
 	ErrInvalid = errors.New("invalid argument")
@@ -21,6 +26,34 @@ const (
 	FlagBothHost            = 0x10 // Simultaneous LE and BR/EDR to Same Device Capable (Host).
 )
 
+// uriSchemes are the schemes of URI fields, indexed by code point. Code point
+// 0x01 stands for a URI without scheme substitution. [Assigned Numbers, 2.7]
+var uriSchemes = []string{
+	0x01: "",
+	0x02: "aaa:",
+	0x03: "aaas:",
+	0x04: "about:",
+	0x05: "acap:",
+	0x06: "acct:",
+	0x07: "cap:",
+	0x08: "cid:",
+	0x09: "coap:",
+	0x0A: "coaps:",
+	0x0B: "crid:",
+	0x0C: "data:",
+	0x0D: "dav:",
+	0x0E: "dict:",
+	0x0F: "dns:",
+	0x10: "file:",
+	0x11: "ftp:",
+	0x12: "geo:",
+	0x13: "go:",
+	0x14: "gopher:",
+	0x15: "h323:",
+	0x16: "http:",
+	0x17: "https:",
+}
+
 // Advertising data field s
 const (
 	flags             = 0x01 // Flags
@@ -53,5 +86,6 @@ const (
 	serviceData128    = 0x21 // Service Data - 128-bit UUID
 	leSecConfirm      = 0x22 // LE Secure Connections Confirmation Value
 	leSecRandom       = 0x23 // LE Secure Connections Random Value
+	uri               = 0x24 // URI
 	manufacturerData  = 0xFF // Manufacturer Specific Data
 )
@@ -0,0 +1,139 @@
+package adv
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kirbo/ble"
+)
+
+// typeNames are the names of the AD types. [Assigned Numbers, 2.3]
+var typeNames = map[byte]string{
+	flags:             "Flags",
+	someUUID16:        "Incomplete List of 16-bit Service UUIDs",
+	allUUID16:         "Complete List of 16-bit Service UUIDs",
+	someUUID32:        "Incomplete List of 32-bit Service UUIDs",
+	allUUID32:         "Complete List of 32-bit Service UUIDs",
+	someUUID128:       "Incomplete List of 128-bit Service UUIDs",
+	allUUID128:        "Complete List of 128-bit Service UUIDs",
+	shortName:         "Shortened Local Name",
+	completeName:      "Complete Local Name",
+	txPower:           "Tx Power Level",
+	classOfDevice:     "Class of Device",
+	simplePairingC192: "Simple Pairing Hash C-192",
+	simplePairingR192: "Simple Pairing Randomizer R-192",
+	secManagerTK:      "Security Manager TK Value",
+	secManagerOOB:     "Security Manager Out of Band Flags",
+	slaveConnInt:      "Peripheral Connection Interval Range",
+	serviceSol16:      "List of 16-bit Service Solicitation UUIDs",
+	serviceSol128:     "List of 128-bit Service Solicitation UUIDs",
+	serviceData16:     "Service Data - 16-bit UUID",
+	pubTargetAddr:     "Public Target Address",
+	randTargetAddr:    "Random Target Address",
+	appearance:        "Appearance",
+	advInterval:       "Advertising Interval",
+	leDeviceAddr:      "LE Bluetooth Device Address",
+	leRole:            "LE Role",
+	serviceSol32:      "List of 32-bit Service Solicitation UUIDs",
+	serviceData32:     "Service Data - 32-bit UUID",
+	serviceData128:    "Service Data - 128-bit UUID",
+	leSecConfirm:      "LE Secure Connections Confirmation Value",
+	leSecRandom:       "LE Secure Connections Random Value",
+	uri:               "URI",
+	manufacturerData:  "Manufacturer Specific Data",
+}
+
+// A StructureSize is the size of an AD structure, including its length and
+// type octets.
+type StructureSize struct {
+	Type byte
+	Size int
+}
+
+func (s StructureSize) String() string {
+	n, ok := typeNames[s.Type]
+	if !ok {
+		n = fmt.Sprintf("0x%02X", s.Type)
+	}
+	return fmt.Sprintf("%s %d", n, s.Size)
+}
+
+// A LengthError tells that advertising data, or a scan response, exceed the
+// length allowed, with the size of each of their AD structures, so that one
+// can tell which to leave out, or to move to the scan response. It tells as
+// ErrNotFit, and ble.ErrEIRPacketTooLong.
+type LengthError struct {
+	Len        int             // Length of the data.
+	Max        int             // Length allowed.
+	Structures []StructureSize // AD structures of the data, in order.
+	Err        error           // Why no more is allowed, if told, such as for want of extended advertising.
+}
+
+// NewLengthError returns the LengthError of the data b, which exceed max.
+func NewLengthError(b []byte, max int) *LengthError {
+	e := &LengthError{Len: len(b), Max: max}
+	for _, r := range NewRawPacket(b).Records() {
+		e.Structures = append(e.Structures, StructureSize{Type: r.Type, Size: 2 + len(r.Data)})
+	}
+	return e
+}
+
+func (e *LengthError) Error() string {
+	ss := make([]string, len(e.Structures))
+	for i, s := range e.Structures {
+		ss[i] = s.String()
+	}
+	msg := fmt.Sprintf("data of %d bytes exceed %d bytes (%s)", e.Len, e.Max, strings.Join(ss, ", "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns why no more is allowed, if told.
+func (e *LengthError) Unwrap() error { return e.Err }
+
+// Is tells e as ErrNotFit, and ble.ErrEIRPacketTooLong.
+func (e *LengthError) Is(target error) bool {
+	return target == ErrNotFit || target == ble.ErrEIRPacketTooLong
+}
+
+// lengthError returns the LengthError of the fields, which don't fit in a
+// packet of max bytes. The fields, which don't fit in an AD structure at
+// all, are left out.
+func lengthError(max int, fields []Field) *LengthError {
+	p := &Packet{max: 1<<31 - 1}
+	for _, f := range fields {
+		f(p)
+	}
+	return NewLengthError(p.b, max)
+}
+
+// FitName is the complete local name n, if it fits in the packet, or else the
+// shortened local name of as many of its leading characters as fit. [CSS,
+// Part A, 1.2]
+func FitName(n string) Field {
+	return func(p *Packet) error {
+		if err := p.append(completeName, []byte(n)); err != ErrNotFit {
+			return err
+		}
+		s := n
+		if len(s) > 0xFF-1 {
+			s = s[:0xFF-1]
+		}
+		if room := p.limit() - p.Len() - 2; room < len(s) {
+			if room < 0 {
+				room = 0
+			}
+			s = s[:room]
+		}
+		for len(s) > 0 && !utf8.ValidString(s) {
+			s = s[:len(s)-1]
+		}
+		if s == "" {
+			return ErrNotFit
+		}
+		return p.append(shortName, []byte(s))
+	}
+}
@@ -0,0 +1,56 @@
+package adv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kirbo/ble"
+)
+
+func TestLengthError(t *testing.T) {
+	_, err := NewPacket(
+		Flags(FlagGeneralDiscoverable|FlagLEOnly),
+		CompleteName("Thermometer"),
+		ManufacturerData(0xFFFF, make([]byte, 14)),
+	)
+	var le *LengthError
+	if !errors.As(err, &le) || !errors.Is(err, ErrNotFit) || !errors.Is(err, ble.ErrEIRPacketTooLong) {
+		t.Fatalf("NewPacket() = %v, want a LengthError", err)
+	}
+	want := []StructureSize{{flags, 3}, {completeName, 13}, {manufacturerData, 18}}
+	if le.Len != 34 || le.Max != MaxEIRPacketLength || len(le.Structures) != len(want) {
+		t.Fatalf("LengthError = %+v", le)
+	}
+	for i, s := range want {
+		if le.Structures[i] != s {
+			t.Errorf("structure %d = %v, want %v", i, le.Structures[i], s)
+		}
+	}
+	if msg := le.Error(); !strings.Contains(msg, "Complete Local Name 13") || !strings.Contains(msg, "Manufacturer Specific Data 18") {
+		t.Errorf("Error() = %q", msg)
+	}
+}
+
+func TestFitName(t *testing.T) {
+	p, err := NewPacket(Flags(FlagGeneralDiscoverable), FitName("Short"))
+	if err != nil || p.LocalName() != "Short" || p.Field(completeName) == nil {
+		t.Fatalf("FitName() = %q, %v, want the complete name", p.LocalName(), err)
+	}
+
+	// 3 bytes of flags, and 2 of the header, leave 26 bytes, which end
+	// within the 13th é.
+	name := "x" + strings.Repeat("é", 20)
+	p, err = NewPacket(Flags(FlagGeneralDiscoverable), FitName(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Field(shortName); string(n) != "x"+strings.Repeat("é", 12) {
+		t.Errorf("shortened name %q", n)
+	}
+
+	full, _ := NewPacket(Raw(make([]byte, MaxEIRPacketLength-2)))
+	if err := full.Append(FitName(name)); err != ErrNotFit {
+		t.Errorf("FitName() in a full packet = %v, want ErrNotFit", err)
+	}
+}
@@ -2,6 +2,10 @@ package adv
 
 import (
 	"encoding/binary"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/kirbo/ble"
 )
@@ -9,7 +13,8 @@ import (
 // Packet is an implemntation of ble.AdvPacket for crafting or parsing an advertising packet or scan response.
 // Refer to Supplement to Bluetooth Core Specification | CSSv6, Part A.
 type Packet struct {
-	b []byte
+	b   []byte
+	max int
 }
 
 // Bytes returns the bytes of the packet.
@@ -22,11 +27,24 @@ func (p *Packet) Len() int {
 	return len(p.b)
 }
 
-// NewPacket returns a new advertising Packet.
+// NewPacket returns a new advertising Packet. It returns a LengthError, if
+// the fields don't fit in it.
 func NewPacket(fields ...Field) (*Packet, error) {
-	p := &Packet{b: make([]byte, 0, MaxEIRPacketLength)}
+	return newPacket(MaxEIRPacketLength, fields)
+}
+
+// NewExtendedPacket returns a new advertising Packet, which may exceed the
+// legacy length, up to MaxExtendedPacketLength, for extended advertising.
+func NewExtendedPacket(fields ...Field) (*Packet, error) {
+	return newPacket(MaxExtendedPacketLength, fields)
+}
+
+func newPacket(max int, fields []Field) (*Packet, error) {
+	p := &Packet{b: make([]byte, 0, MaxEIRPacketLength), max: max}
 	for _, f := range fields {
-		if err := f(p); err != nil {
+		if err := f(p); err == ErrNotFit {
+			return nil, lengthError(max, fields)
+		} else if err != nil {
 			return nil, err
 		}
 	}
@@ -51,10 +69,18 @@ func (p *Packet) Append(f Field) error {
 	return f(p)
 }
 
+// limit returns the maximum length of the packet.
+func (p *Packet) limit() int {
+	if p.max == 0 {
+		return MaxEIRPacketLength
+	}
+	return p.max
+}
+
 // appends appends a field to the packet. It returns ErrNotFit if the field
 // doesn't fit into the packet, and leaves the packet intact.
 func (p *Packet) append(typ byte, b []byte) error {
-	if p.Len()+1+1+len(b) > MaxEIRPacketLength {
+	if len(b)+1 > 0xFF || p.Len()+1+1+len(b) > p.limit() {
 		return ErrNotFit
 	}
 	p.b = append(p.b, byte(len(b)+1))
@@ -67,7 +93,7 @@ func (p *Packet) append(typ byte, b []byte) error {
 // This is helpful for creating new packet from existing packets.
 func Raw(b []byte) Field {
 	return func(p *Packet) error {
-		if p.Len()+len(b) > MaxEIRPacketLength {
+		if p.Len()+len(b) > p.limit() {
 			return ErrNotFit
 		}
 		p.b = append(p.b, b...)
@@ -106,6 +132,13 @@ func Flags(f byte) Field {
 	}
 }
 
+// TxPower is the Tx Power Level, in dBm, at which the packet is transmitted.
+func TxPower(pwr int8) Field {
+	return func(p *Packet) error {
+		return p.append(txPower, []byte{uint8(pwr)})
+	}
+}
+
 // ShortName is a short local name.
 func ShortName(n string) Field {
 	return func(p *Packet) error {
@@ -154,6 +187,31 @@ func SomeUUID(u ble.UUID) Field {
 	}
 }
 
+// SolicitedUUID is one of the service solicitation UUID list.
+func SolicitedUUID(u ble.UUID) Field {
+	return func(p *Packet) error {
+		if u.Len() == 2 {
+			return p.append(serviceSol16, u)
+		}
+		if u.Len() == 4 {
+			return p.append(serviceSol32, u)
+		}
+		return p.append(serviceSol128, u)
+	}
+}
+
+// URI is a URI, whose scheme is encoded as a code point if it's known.
+func URI(s string) Field {
+	return func(p *Packet) error {
+		for i := len(uriSchemes) - 1; i > 1; i-- {
+			if strings.HasPrefix(s, uriSchemes[i]) {
+				return p.append(uri, append([]byte(string(rune(i))), s[len(uriSchemes[i]):]...))
+			}
+		}
+		return p.append(uri, append([]byte{0x01}, s...))
+	}
+}
+
 // ServiceData16 is service data for a 16bit service uuid
 func ServiceData16(id uint16, b []byte) Field {
 	return func(p *Packet) error {
@@ -227,12 +285,12 @@ func (p *Packet) fieldPos(typ byte, offset int) ([]byte, int) {
 }
 
 // Flags returns the flags of the packet.
-func (p *Packet) Flags() (flags byte, present bool) {
+func (p *Packet) Flags() (f byte, present bool) {
 	b := p.Field(flags)
-	if len(b) < 2 {
+	if len(b) < 1 {
 		return 0, false
 	}
-	return b[2], true
+	return b[0], true
 }
 
 // LocalName returns the ShortName or CompleteName if it presents.
@@ -246,10 +304,10 @@ func (p *Packet) LocalName() string {
 // TxPower returns the TxPower, if it presents.
 func (p *Packet) TxPower() (power int, present bool) {
 	b := p.Field(txPower)
-	if len(b) < 3 {
+	if len(b) < 1 {
 		return 0, false
 	}
-	return int(int8(b[2])), true
+	return int(int8(b[0])), true
 }
 
 // UUIDs returns a list of service UUIDs.
@@ -264,34 +322,102 @@ func (p *Packet) UUIDs() []ble.UUID {
 	return u
 }
 
-// ServiceSol ...
+// ServiceSol returns a list of service solicitation UUIDs.
 func (p *Packet) ServiceSol() []ble.UUID {
 	var u []ble.UUID
-	if b := p.Field(serviceSol16); b != nil {
-		u = uuidList(u, b, 2)
-	}
-	if b := p.Field(serviceSol32); b != nil {
-		u = uuidList(u, b, 16)
+	u = p.getUUIDsByType(serviceSol16, u, 2)
+	u = p.getUUIDsByType(serviceSol32, u, 4)
+	u = p.getUUIDsByType(serviceSol128, u, 16)
+	return u
+}
+
+// URI returns the URI, if it presents, and its scheme is known.
+func (p *Packet) URI() string {
+	b := p.Field(uri)
+	if len(b) == 0 {
+		return ""
 	}
-	if b := p.Field(serviceSol128); b != nil {
-		u = uuidList(u, b, 16)
+	r, n := utf8.DecodeRune(b)
+	if r < 0x01 || int(r) >= len(uriSchemes) {
+		return ""
 	}
-	return u
+	return uriSchemes[r] + string(b[n:])
 }
 
 // ServiceData ...
 func (p *Packet) ServiceData() []ble.ServiceData {
 	var s []ble.ServiceData
-	if b := p.Field(serviceData16); b != nil {
-		s = serviceDataList(s, b, 2)
+	for _, r := range p.Records() {
+		switch r.Type {
+		case serviceData16:
+			s = serviceDataList(s, r.Data, 2)
+		case serviceData32:
+			s = serviceDataList(s, r.Data, 4)
+		case serviceData128:
+			s = serviceDataList(s, r.Data, 16)
+		}
+	}
+	return s
+}
+
+// Appearance returns the Appearance, if it presents.
+func (p *Packet) Appearance() (a uint16, present bool) {
+	b := p.Field(appearance)
+	if len(b) < 2 {
+		return 0, false
 	}
-	if b := p.Field(serviceData32); b != nil {
-		s = serviceDataList(s, b, 4)
+	return binary.LittleEndian.Uint16(b), true
+}
+
+// AdvInterval returns the Advertising Interval, if it presents.
+func (p *Packet) AdvInterval() (d time.Duration, present bool) {
+	b := p.Field(advInterval)
+	if len(b) < 2 || len(b) > 4 {
+		return 0, false
 	}
-	if b := p.Field(serviceData128); b != nil {
-		s = serviceDataList(s, b, 16)
+	var n uint32
+	for i := len(b) - 1; i >= 0; i-- {
+		n = n<<8 | uint32(b[i])
 	}
-	return s
+	return time.Duration(n) * 625 * time.Microsecond, true
+}
+
+// LERole returns the LE Role, or ble.LERoleUnknown if it doesn't present.
+func (p *Packet) LERole() ble.LERole {
+	b := p.Field(leRole)
+	if len(b) < 1 || b[0] > 0x03 {
+		return ble.LERoleUnknown
+	}
+	return ble.LERole(b[0])
+}
+
+// PublicTargetAddrs returns the addresses of the Public Target Address field.
+func (p *Packet) PublicTargetAddrs() []ble.Addr {
+	var as []ble.Addr
+	for _, r := range p.Records() {
+		if r.Type != pubTargetAddr {
+			continue
+		}
+		for d := r.Data; len(d) >= 6; d = d[6:] {
+			as = append(as, net.HardwareAddr{d[5], d[4], d[3], d[2], d[1], d[0]})
+		}
+	}
+	return as
+}
+
+// Records returns the AD structures of the packet, up to the first malformed
+// one.
+func (p *Packet) Records() []ble.ADStructure {
+	var rs []ble.ADStructure
+	for b := p.b; len(b) >= 2; {
+		l := int(b[0])
+		if l < 1 || len(b) < 1+l {
+			break
+		}
+		rs = append(rs, ble.ADStructure{Type: b[1], Data: b[2 : 1+l]})
+		b = b[1+l:]
+	}
+	return rs
 }
 
 // ManufacturerData returns the ManufacturerData field if it presents.
@@ -309,10 +435,13 @@ func uuidList(u []ble.UUID, d []byte, w int) []ble.UUID {
 }
 
 func serviceDataList(sd []ble.ServiceData, d []byte, w int) []ble.ServiceData {
+	if len(d) < w {
+		return sd
+	}
 	serviceData := ble.ServiceData{
 		UUID: ble.UUID(d[:w]),
 		Data: make([]byte, len(d)-w),
 	}
-	copy(serviceData.Data, d[2:])
+	copy(serviceData.Data, d[w:])
 	return append(sd, serviceData)
 }
@@ -0,0 +1,107 @@
+package adv
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+func TestServiceSolAndURI(t *testing.T) {
+	p, err := NewPacket(
+		SolicitedUUID(ble.UUID16(0x180D)),
+		SolicitedUUID(ble.UUID16(0x180F)),
+		SolicitedUUID(ble.UUID([]byte{0x01, 0x02, 0x03, 0x04})),
+		URI("https://go.dev"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ble.UUID{ble.UUID16(0x180D), ble.UUID16(0x180F), ble.UUID([]byte{0x01, 0x02, 0x03, 0x04})}
+	got := p.ServiceSol()
+	if len(got) != len(want) {
+		t.Fatalf("ServiceSol() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("ServiceSol()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if b := p.Field(uri); len(b) == 0 || b[0] != 0x17 {
+		t.Errorf("URI field = [% X], want the https: code point", b)
+	}
+	if u := p.URI(); u != "https://go.dev" {
+		t.Errorf("URI() = %q, want %q", u, "https://go.dev")
+	}
+}
+
+func TestStandardFields(t *testing.T) {
+	p := NewRawPacket(
+		[]byte{0x02, flags, 0x06},
+		[]byte{0x02, txPower, 0xF8},
+		[]byte{0x03, appearance, 0x41, 0x03},
+		[]byte{0x03, advInterval, 0x40, 0x06},
+		[]byte{0x02, leRole, 0x02},
+		[]byte{0x07, pubTargetAddr, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11},
+		[]byte{0x04, serviceData16, 0x0F, 0x18, 100},
+		[]byte{0x07, serviceData32, 0x01, 0x02, 0x03, 0x04, 0xAA, 0xBB},
+	)
+	if f, ok := p.Flags(); !ok || f != 0x06 {
+		t.Errorf("Flags() = 0x%02X, %v, want 0x06", f, ok)
+	}
+	if pwr, ok := p.TxPower(); !ok || pwr != -8 {
+		t.Errorf("TxPower() = %d, %v, want -8", pwr, ok)
+	}
+	if a, ok := p.Appearance(); !ok || a != 0x0341 {
+		t.Errorf("Appearance() = 0x%04X, %v, want 0x0341", a, ok)
+	}
+	if d, ok := p.AdvInterval(); !ok || d != time.Second {
+		t.Errorf("AdvInterval() = %s, %v, want 1s", d, ok)
+	}
+	if r := p.LERole(); r != ble.LERolePeripheralPreferred {
+		t.Errorf("LERole() = %d, want LERolePeripheralPreferred", r)
+	}
+	if as := p.PublicTargetAddrs(); len(as) != 1 || as[0].String() != "11:22:33:44:55:66" {
+		t.Errorf("PublicTargetAddrs() = %v, want [11:22:33:44:55:66]", as)
+	}
+	sd := p.ServiceData()
+	if len(sd) != 2 || !sd[0].UUID.Equal(ble.UUID16(0x180F)) || !bytes.Equal(sd[1].Data, []byte{0xAA, 0xBB}) {
+		t.Errorf("ServiceData() = %v, want the battery level and the 32-bit UUID's", sd)
+	}
+	if rs := p.Records(); len(rs) != 8 || rs[7].Type != serviceData32 || len(rs[7].Data) != 6 {
+		t.Errorf("Records() = %v, want the 8 fields", rs)
+	}
+	if r := NewRawPacket([]byte{0x02, flags, 0x06}).LERole(); r != ble.LERoleUnknown {
+		t.Errorf("LERole() of no field = %d, want LERoleUnknown", r)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	level := uint8(90)
+	tmpl := NewTemplate(
+		Fixed(Flags(FlagGeneralDiscoverable|FlagLEOnly)),
+		Counter(0xFFFF),
+		BatteryLevel(func() uint8 { return level }),
+	)
+	for i := 0; i < 2; i++ {
+		p, err := tmpl.Packet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if md := p.ManufacturerData(); len(md) != 4 || md[2] != byte(i) || md[3] != 0 {
+			t.Errorf("counter = [% X], want %d", md, i)
+		}
+		sd := p.ServiceData()
+		if len(sd) != 1 || !sd[0].UUID.Equal(ble.UUID16(0x180F)) || len(sd[0].Data) != 1 || sd[0].Data[0] != level {
+			t.Errorf("battery level = %v, want %d", sd, level)
+		}
+		level--
+	}
+
+	big := NewTemplate(ManufacturerDataFunc(0xFFFF, func() []byte { return make([]byte, MaxEIRPacketLength) }))
+	if _, err := big.Packet(); !errors.Is(err, ErrNotFit) {
+		t.Errorf("Packet() err = %v, want ErrNotFit", err)
+	}
+}
@@ -0,0 +1,67 @@
+package adv
+
+import "github.com/kirbo/ble"
+
+// A Var is a field of a Template, which is evaluated whenever the template
+// is. It may return a different field each time, such as one carrying the
+// latest reading of a sensor.
+type Var func() Field
+
+// Fixed is a Var of a field which doesn't change.
+func Fixed(f Field) Var {
+	return func() Field { return f }
+}
+
+// ManufacturerDataFunc is manufacturer specific data, which is returned by f.
+func ManufacturerDataFunc(id uint16, f func() []byte) Var {
+	return func() Field { return ManufacturerData(id, f()) }
+}
+
+// ServiceData16Func is service data for a 16bit service uuid, which is
+// returned by f. Unlike ServiceData16, the uuid isn't listed in the packet.
+func ServiceData16Func(id uint16, f func() []byte) Var {
+	return func() Field {
+		return func(p *Packet) error {
+			return p.append(serviceData16, append(ble.UUID16(id), f()...))
+		}
+	}
+}
+
+// BatteryLevel is the battery level in percent, which is returned by f. It's
+// the service data of the Battery Service.
+func BatteryLevel(f func() uint8) Var {
+	return ServiceData16Func(0x180F, func() []byte { return []byte{f()} })
+}
+
+// Counter is manufacturer specific data of a 16bit little-endian counter,
+// which increments, and wraps around, every time the template is evaluated.
+// Scanners may tell a fresh packet from a repeated one by it.
+func Counter(id uint16) Var {
+	var n uint16
+	return func() Field {
+		b := []byte{uint8(n), uint8(n >> 8)}
+		n++
+		return ManufacturerData(id, b)
+	}
+}
+
+// A Template crafts an advertising packet or scan response from its Vars.
+type Template struct {
+	vars []Var
+}
+
+// NewTemplate returns a Template of the vars, in order.
+func NewTemplate(vars ...Var) *Template {
+	return &Template{vars: vars}
+}
+
+// Packet evaluates the vars, and returns the packet of the resulting fields.
+// It returns a LengthError, which tells as ErrNotFit, if the fields don't fit
+// into a packet.
+func (t *Template) Packet() (*Packet, error) {
+	fields := make([]Field, 0, len(t.vars))
+	for _, v := range t.vars {
+		fields = append(fields, v())
+	}
+	return NewPacket(fields...)
+}
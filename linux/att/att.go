@@ -12,6 +12,9 @@ var (
 	// ErrSeqProtoTimeout means the request hasn't been acknowledged in 30 seconds.
 	// [Vol 3, Part F, 3.3.3]
 	ErrSeqProtoTimeout = errors.New("req timeout")
+
+	// ErrNotBonded means there is no signing key for the peer.
+	ErrNotBonded = errors.New("not bonded")
 )
 
 var rspOfReq = map[byte]byte{
@@ -28,3 +31,23 @@ var rspOfReq = map[byte]byte{
 	ExecuteWriteRequestCode:    ExecuteWriteResponseCode,
 	HandleValueIndicationCode:  HandleValueConfirmationCode,
 }
+
+// responseOf returns the opcode of the response to a request. Requests the
+// library doesn't model are assumed to follow the convention of the spec,
+// where the response opcode is the one of the request plus one.
+func responseOf(req byte) byte {
+	if rsp, ok := rspOfReq[req]; ok {
+		return rsp
+	}
+	return req + 1
+}
+
+// isResponse reports whether op is the opcode of a known response.
+func isResponse(op byte) bool {
+	for _, rsp := range rspOfReq {
+		if rsp == op {
+			return true
+		}
+	}
+	return false
+}
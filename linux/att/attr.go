@@ -11,4 +11,9 @@ type attr struct {
 	v  []byte
 	rh ble.ReadHandler
 	wh ble.WriteHandler
+
+	// The characteristic of a value attribute, and its permissions.
+	c     *ble.Characteristic
+	rperm ble.Permission
+	wperm ble.Permission
 }
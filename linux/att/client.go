@@ -1,8 +1,12 @@
 package att
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kirbo/ble"
@@ -14,31 +18,115 @@ type NotificationHandler interface {
 	HandleNotification(req []byte)
 }
 
+// SeqNotificationHandler is a NotificationHandler, which is also told the
+// sequence number of each notification, or indication, among the PDUs
+// received from the server.
+type SeqNotificationHandler interface {
+	HandleSeqNotification(req []byte, seq uint64)
+}
+
+// response is a response received, and its sequence number.
+type response struct {
+	b   []byte
+	seq uint64
+}
+
 // Client implementa an Attribute Protocol Client.
 type Client struct {
 	l2c  ble.Conn
-	rspc chan []byte
-
-	rxBuf   []byte
-	chTxBuf chan []byte
-	chErr   chan error
-	handler NotificationHandler
+	rspc chan response
+
+	// seq numbers the PDUs received, and may be shared with the other
+	// bearers. rspSeq is the number of the last response to a request.
+	seq    *uint64
+	rspSeq uint64
+
+	// pending are the notifications received, but not handled yet, which
+	// the responses received after them wait for.
+	pending *sync.WaitGroup
+
+	// idle holds a token while no request is outstanding. A request, whose
+	// caller gave up, holds it until its response arrives, or times out,
+	// so that it isn't taken for the response of the next one.
+	idle chan struct{}
+
+	// ctx bounds the requests of the Client, as set by WithContext.
+	ctx context.Context
+
+	rxBuf    []byte
+	chTxBuf  chan []byte
+	chCmdBuf chan []byte // Of the commands, which don't wait for the requests.
+	chErr    chan error
+	handler  NotificationHandler
+
+	log     ble.SubsystemLogger
+	metrics ble.MetricsCollector
+	clock   ble.Clock
+	tmo     time.Duration
+	retry   ble.RetryPolicy
+
+	// autoSec is the security level the requests refused for the security
+	// of the link elevate it to, if set.
+	autoSec ble.SecurityLevel
 }
 
+// transactionTimeout is the timeout of ATT transactions. [Vol 3, Part F, 3.3.3]
+const transactionTimeout = 30 * time.Second
+
 // NewClient returns an Attribute Protocol Client.
 func NewClient(l2c ble.Conn, h NotificationHandler) *Client {
 	c := &Client{
-		l2c:     l2c,
-		rspc:    make(chan []byte),
-		chTxBuf: make(chan []byte, 1),
-		rxBuf:   make([]byte, ble.MaxMTU),
-		chErr:   make(chan error, 1),
-		handler: h,
-	}
+		l2c:      l2c,
+		rspc:     make(chan response),
+		seq:      new(uint64),
+		pending:  new(sync.WaitGroup),
+		idle:     make(chan struct{}, 1),
+		ctx:      context.Background(),
+		chTxBuf:  make(chan []byte, 1),
+		chCmdBuf: make(chan []byte, 1),
+		rxBuf:    make([]byte, ble.MaxMTU),
+		chErr:    make(chan error, 1),
+		handler:  h,
+		log:      connLogger(l2c),
+		metrics:  ble.MetricsFromContext(l2c.Context()),
+		clock:    ble.ClockFromContext(l2c.Context()),
+		tmo:      transactionTimeout,
+	}
+	if d, ok := ble.ClientTimeoutFromContext(l2c.Context()); ok {
+		c.tmo = d
+	}
+	c.retry, _ = ble.RetryPolicyFromContext(l2c.Context())
+	c.autoSec, _ = ble.AutoSecurityFromContext(l2c.Context())
 	c.chTxBuf <- make([]byte, l2c.TxMTU(), l2c.TxMTU())
+	c.chCmdBuf <- make([]byte, l2c.TxMTU(), l2c.TxMTU())
+	c.idle <- struct{}{}
 	return c
 }
 
+// WithContext returns a copy of c, sharing its bearer, whose requests give
+// up once ctx is done, returning its error, rather than wait up to the
+// transaction timeout. ATT has no way to cancel a request, so its response
+// is still waited for, in the background, before the next request is sent.
+// The copy is used in place of c, but for Loop.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// SetSeq makes c number the PDUs it receives with seq, shared with the other
+// bearers of the connection, so that the numbers tell the order they were
+// received in across the bearers. It's called before Loop.
+func (c *Client) SetSeq(seq *uint64) {
+	c.seq = seq
+}
+
+// RspSeq returns the sequence number of the response to the last request,
+// which tells it apart from the notifications received before and after.
+func (c *Client) RspSeq() uint64 {
+	return c.rspSeq
+}
+
 // ExchangeMTU informs the server of the client’s maximum receive MTU size and
 // request the server to respond with its maximum receive MTU size. [Vol 3, Part F, 3.4.2.1]
 func (c *Client) ExchangeMTU(clientRxMTU int) (serverRxMTU int, err error) {
@@ -68,7 +156,7 @@ func (c *Client) ExchangeMTU(clientRxMTU int) (serverRxMTU int, err error) {
 	rsp := ExchangeMTUResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return 0, ble.ATTError(rsp[4])
+		return 0, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -115,7 +203,7 @@ func (c *Client) FindInformation(starth, endh uint16) (fmt int, data []byte, err
 	rsp := FindInformationResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return 0x00, nil, ble.ATTError(rsp[4])
+		return 0x00, nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -130,19 +218,47 @@ func (c *Client) FindInformation(starth, endh uint16) (fmt int, data []byte, err
 	return int(rsp.Format()), rsp.InformationData(), nil
 }
 
-// // HandleInformationList ...
-// type HandleInformationList []byte
-//
-// // FoundAttributeHandle ...
-// func (l HandleInformationList) FoundAttributeHandle() []byte { return l[:2] }
-//
-// // GroupEndHandle ...
-// func (l HandleInformationList) GroupEndHandle() []byte { return l[2:4] }
-//
-// // FindByTypeValue ...
-// func (c *Client) FindByTypeValue(starth, endh, attrType uint16, value []byte) ([]HandleInformationList, error) {
-// 	return nil, nil
-// }
+// FindByTypeValue obtains the handles of attributes of a 16-bit type and a
+// value, and the ends of their groups, as pairs of 4 bytes of the Handles
+// Information List. [Vol 3, Part F, 3.4.3.3 & 3.4.3.4]
+func (c *Client) FindByTypeValue(starth, endh, attrType uint16, value []byte) ([]byte, error) {
+	if starth == 0 || starth > endh {
+		return nil, ErrInvalidArgument
+	}
+
+	// Acquire and reuse the txBuf, and release it after usage.
+	txBuf := <-c.chTxBuf
+	defer func() { c.chTxBuf <- txBuf }()
+	if 7+len(value) > len(txBuf) {
+		return nil, ErrInvalidArgument
+	}
+
+	req := FindByTypeValueRequest(txBuf[:7+len(value)])
+	req.SetAttributeOpcode()
+	req.SetStartingHandle(starth)
+	req.SetEndingHandle(endh)
+	req.SetAttributeType(attrType)
+	req.SetAttributeValue(value)
+
+	b, err := c.sendReq(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert and validate the response.
+	rsp := FindByTypeValueResponse(b)
+	switch {
+	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
+		return nil, errorResponse(rsp)
+	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
+		fallthrough
+	case rsp[0] != rsp.AttributeOpcode():
+		fallthrough
+	case len(rsp) < 5 || len(rsp.HandleInformationList())%4 != 0:
+		return nil, ErrInvalidResponse
+	}
+	return rsp.HandleInformationList(), nil
+}
 
 // ReadByType obtains the values of attributes where the attribute type is known
 // but the handle is not known. [Vol 3, Part F, 3.4.4.1 & 3.4.4.2]
@@ -170,7 +286,7 @@ func (c *Client) ReadByType(starth, endh uint16, uuid ble.UUID) (int, []byte, er
 	rsp := ReadByTypeResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return 0, nil, ble.ATTError(rsp[4])
+		return 0, nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -202,7 +318,7 @@ func (c *Client) Read(handle uint16) ([]byte, error) {
 	rsp := ReadResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return nil, ble.ATTError(rsp[4])
+		return nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -236,7 +352,7 @@ func (c *Client) ReadBlob(handle, offset uint16) ([]byte, error) {
 	rsp := ReadBlobResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return nil, ble.ATTError(rsp[4])
+		return nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -280,7 +396,7 @@ func (c *Client) ReadMultiple(handles []uint16) ([]byte, error) {
 	rsp := ReadMultipleResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return nil, ble.ATTError(rsp[4])
+		return nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -318,7 +434,7 @@ func (c *Client) ReadByGroupType(starth, endh uint16, uuid ble.UUID) (int, []byt
 	rsp := ReadByGroupTypeResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return 0, nil, ble.ATTError(rsp[4])
+		return 0, nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -335,6 +451,17 @@ func (c *Client) ReadByGroupType(starth, endh uint16, uuid ble.UUID) (int, []byt
 // Write requests the server to write the value of an attribute and acknowledge that
 // this has been achieved in a Write Response. [Vol 3, Part F, 3.4.5.1 & 3.4.5.2]
 func (c *Client) Write(handle uint16, value []byte) error {
+	return c.write(handle, value, c.retry.Writes)
+}
+
+// WriteIdempotent writes the value of the attribute of handle, as Write does,
+// but retries as told by the RetryPolicy of the connection, as a write which
+// may be repeated, such as of a CCCD.
+func (c *Client) WriteIdempotent(handle uint16, value []byte) error {
+	return c.write(handle, value, true)
+}
+
+func (c *Client) write(handle uint16, value []byte, retry bool) error {
 	if len(value) > c.l2c.TxMTU()-3 {
 		return ErrInvalidArgument
 	}
@@ -348,7 +475,7 @@ func (c *Client) Write(handle uint16, value []byte) error {
 	req.SetAttributeHandle(handle)
 	req.SetAttributeValue(value)
 
-	b, err := c.sendReq(req)
+	b, err := c.sendReqRetry(req, retry)
 	if err != nil {
 		return err
 	}
@@ -357,7 +484,7 @@ func (c *Client) Write(handle uint16, value []byte) error {
 	rsp := WriteResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return ble.ATTError(rsp[4])
+		return errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -367,15 +494,20 @@ func (c *Client) Write(handle uint16, value []byte) error {
 }
 
 // WriteCommand requests the server to write the value of an attribute, typically
-// into a control-point attribute. [Vol 3, Part F, 3.4.5.3]
+// into a control-point attribute. The commands may be sent while a request is
+// outstanding, so it doesn't wait for the request. [Vol 3, Part F, 3.3.2 & 3.4.5.3]
 func (c *Client) WriteCommand(handle uint16, value []byte) error {
 	if len(value) > c.l2c.TxMTU()-3 {
 		return ErrInvalidArgument
 	}
 
-	// Acquire and reuse the txBuf, and release it after usage.
-	txBuf := <-c.chTxBuf
-	defer func() { c.chTxBuf <- txBuf }()
+	// Acquire and reuse the buffer of the commands, and release it after usage.
+	txBuf := <-c.chCmdBuf
+	if len(txBuf) < c.l2c.TxMTU() {
+		// Grown by ExchangeMTU.
+		txBuf = make([]byte, c.l2c.TxMTU())
+	}
+	defer func() { c.chCmdBuf <- txBuf }()
 
 	req := WriteCommand(txBuf[:3+len(value)])
 	req.SetAttributeOpcode()
@@ -400,7 +532,44 @@ func (c *Client) SignedWrite(handle uint16, value []byte, signature [12]byte) er
 	req.SetAttributeOpcode()
 	req.SetAttributeHandle(handle)
 	req.SetAttributeValue(value)
-	req.SetAuthenticationSignature(signature)
+	// The generated setter assumes the value is the last field.
+	copy(req[3+len(value):], signature[:])
+
+	return c.sendCmd(req)
+}
+
+// WriteSigned is like SignedWrite, but signs the value with the local CSRK of
+// the peer, and the next sign counter, from the BondStore of the connection.
+func (c *Client) WriteSigned(handle uint16, value []byte) error {
+	if len(value) > c.l2c.TxMTU()-15 {
+		return ErrInvalidArgument
+	}
+	s, ok := ble.BondStoreFromContext(c.l2c.Context())
+	if !ok {
+		return ErrNotBonded
+	}
+	peer := c.l2c.RemoteAddr()
+	csrk, err := s.Load(peer, ble.BondLocalCSRK)
+	if err == ble.ErrNotFound || (err == nil && len(csrk) != 16) {
+		return ErrNotBonded
+	} else if err != nil {
+		return errors.Wrap(err, "can't load signing key")
+	}
+	counter, err := nextSignCounter(s, peer)
+	if err != nil {
+		return errors.Wrap(err, "can't update sign counter")
+	}
+
+	// Acquire and reuse the txBuf, and release it after usage.
+	txBuf := <-c.chTxBuf
+	defer func() { c.chTxBuf <- txBuf }()
+
+	req := SignedWriteCommand(txBuf[:15+len(value)])
+	req.SetAttributeOpcode()
+	req.SetAttributeHandle(handle)
+	req.SetAttributeValue(value)
+	sig := signPDU(csrk, counter, req[:3+len(value)])
+	copy(req[3+len(value):], sig[:])
 
 	return c.sendCmd(req)
 }
@@ -422,6 +591,7 @@ func (c *Client) PrepareWrite(handle uint16, offset uint16, value []byte) (uint1
 	req.SetAttributeOpcode()
 	req.SetAttributeHandle(handle)
 	req.SetValueOffset(offset)
+	req.SetPartAttributeValue(value)
 
 	b, err := c.sendReq(req)
 	if err != nil {
@@ -432,7 +602,7 @@ func (c *Client) PrepareWrite(handle uint16, offset uint16, value []byte) (uint1
 	rsp := PrepareWriteResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return 0, 0, nil, ble.ATTError(rsp[4])
+		return 0, 0, nil, errorResponse(rsp)
 	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
@@ -452,7 +622,7 @@ func (c *Client) ExecuteWrite(flags uint8) error {
 	txBuf := <-c.chTxBuf
 	defer func() { c.chTxBuf <- txBuf }()
 
-	req := ExecuteWriteRequest(txBuf[:1])
+	req := ExecuteWriteRequest(txBuf[:2])
 	req.SetAttributeOpcode()
 	req.SetFlags(flags)
 
@@ -465,8 +635,8 @@ func (c *Client) ExecuteWrite(flags uint8) error {
 	rsp := ExecuteWriteResponse(b)
 	switch {
 	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
-		return ble.ATTError(rsp[4])
-	case rsp[0] == ErrorResponseCode && len(rsp) == 5:
+		return errorResponse(rsp)
+	case rsp[0] == ErrorResponseCode && len(rsp) != 5:
 		fallthrough
 	case rsp[0] != rsp.AttributeOpcode():
 		return ErrInvalidResponse
@@ -474,20 +644,184 @@ func (c *Client) ExecuteWrite(flags uint8) error {
 	return nil
 }
 
+// RawATT sends an ATT PDU the library doesn't otherwise model, and returns
+// the response if the opcode is a request. Commands (opcodes with the Command
+// Flag set) return as soon as they are sent. It is serialized with the other
+// requests of the Client, so ctx only bounds the wait of the caller.
+func (c *Client) RawATT(ctx context.Context, pdu []byte) ([]byte, error) {
+	if len(pdu) == 0 {
+		return nil, ErrInvalidArgument
+	}
+	switch op := pdu[0]; {
+	case op == ExchangeMTURequestCode:
+		// The MTU is tracked by the Client; use ExchangeMTU.
+		return nil, ErrInvalidArgument
+	case op == ErrorResponseCode, isResponse(op),
+		op == HandleValueNotificationCode, op == HandleValueIndicationCode, op == HandleValueConfirmationCode:
+		// Sent by servers, or generated by the Client itself.
+		return nil, ErrInvalidArgument
+	}
+
+	var txBuf []byte
+	select {
+	case txBuf = <-c.chTxBuf:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if len(pdu) > len(txBuf) {
+		c.chTxBuf <- txBuf
+		return nil, ErrInvalidArgument
+	}
+	b := txBuf[:len(pdu)]
+	copy(b, pdu)
+
+	if b[0]&0x40 != 0 {
+		defer func() { c.chTxBuf <- txBuf }()
+		return nil, c.sendCmd(b)
+	}
+
+	// Keep holding the txBuf until the response arrives, even if the caller
+	// gives up, so it can't be mistaken for the response of a later request.
+	type result struct {
+		rsp []byte
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		rsp, err := c.sendReq(b)
+		c.chTxBuf <- txBuf
+		ch <- result{rsp, err}
+	}()
+	select {
+	case r := <-ch:
+		switch {
+		case r.err != nil:
+			return nil, r.err
+		case r.rsp[0] == ErrorResponseCode && len(r.rsp) == 5:
+			return nil, errorResponse(r.rsp)
+		case r.rsp[0] == ErrorResponseCode:
+			return nil, ErrInvalidResponse
+		}
+		return r.rsp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// errorResponse returns the error of an Error Response.
+func errorResponse(b []byte) error {
+	r := ErrorResponse(b)
+	return &ble.ErrATT{Opcode: r.RequestOpcodeInError(), Handle: r.AttributeInError(), ErrCode: ble.ATTError(r.ErrorCode())}
+}
+
 func (c *Client) sendCmd(b []byte) error {
 	_, err := c.l2c.Write(b)
 	return err
 }
 
-func (c *Client) sendReq(b []byte) (rsp []byte, err error) {
-	logger.Debug("client", "req", fmt.Sprintf("% X", b))
-	if _, err := c.l2c.Write(b); err != nil {
-		return nil, errors.Wrap(err, "send ATT request failed")
+// sendReq sends the request b, and returns the response. The requests, but
+// the writes, which may not be idempotent, are retried as told by the
+// RetryPolicy of the connection, if any.
+func (c *Client) sendReq(b []byte) ([]byte, error) {
+	switch b[0] {
+	case WriteRequestCode, PrepareWriteRequestCode, ExecuteWriteRequestCode:
+		return c.sendReqRetry(b, c.retry.Writes)
 	}
+	return c.sendReqRetry(b, true)
+}
+
+// sendReqRetry sends the request b, and returns the response, retrying as
+// told by the RetryPolicy of the connection, if retry is set.
+func (c *Client) sendReqRetry(b []byte, retry bool) (rsp []byte, err error) {
+	elevated := false
+	for attempt := 1; ; attempt++ {
+		rsp, err = c.sendReqOnce(b)
+		if !elevated && err == nil && c.elevate(rsp) {
+			// Refused before it was carried out, so it's sent again,
+			// writes too.
+			elevated = true
+			attempt--
+			continue
+		}
+		if !retry {
+			return rsp, err
+		}
+		e := err
+		if e == nil && rsp[0] == ErrorResponseCode && len(rsp) == 5 {
+			e = errorResponse(rsp)
+		}
+		d, ok := c.retry.Retry(e, attempt)
+		if !ok || c.ctx.Err() != nil {
+			return rsp, err
+		}
+		c.log.Debug("client retry", "attempt", attempt, "err", e, "backoff", d)
+		if d > 0 {
+			t := c.clock.NewTimer(d)
+			select {
+			case <-t.C():
+			case <-c.ctx.Done():
+				t.Stop()
+				return nil, c.ctx.Err()
+			}
+		}
+	}
+}
+
+// elevate elevates the security of the link, if rsp refuses the request for
+// it, and the connection elevates to autoSec. It reports whether the request
+// is to be sent again.
+func (c *Client) elevate(rsp []byte) bool {
+	if c.autoSec <= ble.SecurityNone || rsp[0] != ErrorResponseCode || len(rsp) != 5 {
+		return false
+	}
+	switch ble.ATTError(ErrorResponse(rsp).ErrorCode()) {
+	case ble.ErrInsuffEnc, ble.ErrAuthentication, ble.ErrInsuffEncrKeySize:
+	default:
+		return false
+	}
+	e, ok := c.l2c.(interface{ Elevate(ble.SecurityLevel) error })
+	if !ok {
+		return false
+	}
+	if err := e.Elevate(c.autoSec); err != nil {
+		c.log.Warn("can't elevate security", "level", c.autoSec, "err", err)
+		return false
+	}
+	return true
+}
+
+func (c *Client) sendReqOnce(b []byte) (rsp []byte, err error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	select {
+	case <-c.idle:
+	case <-c.ctx.Done():
+		return nil, c.ctx.Err()
+	}
+	c.log.Debug("client send", "pdu", fmt.Sprintf("[% X]", b))
+	c.metrics.Add(ble.MetricATTRequestsActive, 1)
+	defer c.metrics.Add(ble.MetricATTRequestsActive, -1)
+	start := c.clock.Now()
+	if _, err := c.l2c.Write(b); err != nil {
+		c.idle <- struct{}{}
+		return nil, c.linkError("send ATT request failed", err)
+	}
+	t := c.clock.NewTimer(c.tmo)
+	abandoned := false
+	defer func() {
+		if !abandoned {
+			t.Stop()
+			c.idle <- struct{}{}
+		}
+	}()
 	for {
 		select {
-		case rsp := <-c.rspc:
-			if rsp[0] == ErrorResponseCode || rsp[0] == rspOfReq[b[0]] {
+		case r := <-c.rspc:
+			rsp := r.b
+			if rsp[0] == ErrorResponseCode || rsp[0] == responseOf(b[0]) {
+				c.observe(b[0], c.clock.Now().Sub(start))
+				c.rspSeq = r.seq
 				return rsp, nil
 			}
 			// Sometimes when we connect to an Apple device, it sends
@@ -495,16 +829,69 @@ func (c *Client) sendReq(b []byte) (rsp []byte, err error) {
 			// returns an ErrReqNotSupp response, and continue to wait
 			// the response to our request.
 			errRsp := newErrorResponse(rsp[0], 0x0000, ble.ErrReqNotSupp)
-			logger.Debug("client", "req", fmt.Sprintf("% X", b))
+			c.log.Debug("client send", "pdu", fmt.Sprintf("[% X]", errRsp))
 			_, err := c.l2c.Write(errRsp)
 			if err != nil {
-				return nil, errors.Wrap(err, "unexpected ATT response received")
+				return nil, c.linkError("unexpected ATT response received", err)
+			}
+		case err := <-c.chErr:
+			return nil, c.linkError("ATT request failed", err)
+		case <-t.C():
+			return nil, fmt.Errorf("ATT request timeout: %w", ErrSeqProtoTimeout)
+		case <-c.ctx.Done():
+			abandoned = true
+			go c.abandon(b[0], t)
+			return nil, c.ctx.Err()
+		}
+	}
+}
+
+// abandon waits for the response to the request of the opcode op, whose
+// caller gave up, until the link fails, or t fires, and then lets the next
+// request be sent.
+func (c *Client) abandon(op byte, t ble.Timer) {
+	defer t.Stop()
+	c.log.Debug("client request abandoned", "opcode", fmt.Sprintf("0x%02X", op))
+	for {
+		select {
+		case r := <-c.rspc:
+			if r.b[0] != ErrorResponseCode && r.b[0] != responseOf(op) {
+				// A request of the server, as in sendReqOnce.
+				_, _ = c.l2c.Write(newErrorResponse(r.b[0], 0x0000, ble.ErrReqNotSupp))
+				continue
 			}
 		case err := <-c.chErr:
-			return nil, errors.Wrap(err, "ATT request failed")
-		case <-time.After(30 * time.Second):
-			return nil, errors.Wrap(ErrSeqProtoTimeout, "ATT request timeout")
+			// Left for the next request to fail with.
+			c.chErr <- err
+		case <-t.C():
 		}
+		c.idle <- struct{}{}
+		return
+	}
+}
+
+// linkError wraps the error of the bearer, which is ErrDisconnected once the
+// link is lost.
+func (c *Client) linkError(msg string, err error) error {
+	select {
+	case <-c.l2c.Disconnected():
+		return fmt.Errorf("%s: %w", msg, ble.ErrDisconnected)
+	default:
+	}
+	switch errors.Cause(err) {
+	case io.EOF, io.ErrClosedPipe:
+		return fmt.Errorf("%s: %w", msg, ble.ErrDisconnected)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// observe passes the round trip of a read or write request to the metrics.
+func (c *Client) observe(op byte, d time.Duration) {
+	switch op {
+	case ReadRequestCode, ReadBlobRequestCode, ReadMultipleRequestCode:
+		ble.ObserveLatency(c.metrics, ble.MetricReadLatency, d)
+	case WriteRequestCode, ExecuteWriteRequestCode:
+		ble.ObserveLatency(c.metrics, ble.MetricWriteLatency, d)
 	}
 }
 
@@ -512,22 +899,27 @@ func (c *Client) sendReq(b []byte) (rsp []byte, err error) {
 func (c *Client) Loop() {
 
 	type asyncWork struct {
-		handle func([]byte)
-		data   []byte
+		data []byte
+		seq  uint64
 	}
 
 	ch := make(chan asyncWork, 16)
 	defer close(ch)
+	sh, _ := c.handler.(SeqNotificationHandler)
 	go func() {
 		for w := range ch {
-			w.handle(w.data)
+			if sh != nil {
+				sh.HandleSeqNotification(w.data, w.seq)
+			} else {
+				c.handler.HandleNotification(w.data)
+			}
+			c.pending.Done()
 		}
 	}()
 
 	confirmation := []byte{HandleValueConfirmationCode}
 	for {
 		n, err := c.l2c.Read(c.rxBuf)
-		logger.Debug("client", "rsp", fmt.Sprintf("% X", c.rxBuf[:n]))
 		if err != nil {
 			// We don't expect any error from the bearer (L2CAP ACL-U)
 			// Pass it along to the pending request, if any, and escape.
@@ -537,23 +929,33 @@ func (c *Client) Loop() {
 
 		b := make([]byte, n)
 		copy(b, c.rxBuf)
+		c.log.Debug("client recv", "pdu", fmt.Sprintf("[% X]", b))
 
+		seq := atomic.AddUint64(c.seq, 1)
 		if (b[0] != HandleValueNotificationCode) && (b[0] != HandleValueIndicationCode) {
-			c.rspc <- b
+			// The notifications received before the response are handled
+			// first, so that they're queued to their subscriptions in
+			// the order they were received in.
+			c.pending.Wait()
+			c.rspc <- response{b, seq}
 			continue
 		}
 
+		c.metrics.Add(ble.MetricNotificationsRx, 1)
+
 		// Deliver the full request to upper layer.
+		c.pending.Add(1)
 		select {
-		case ch <- asyncWork{handle: c.handler.HandleNotification, data: b}:
+		case ch <- asyncWork{data: b, seq: seq}:
 		default:
+			c.pending.Done()
 			// If this really happens, especially on a slow machine, enlarge the channel buffer.
-			_ = logger.Error("client", "req", "can't enqueue incoming notification.")
+			c.log.Error("can't enqueue incoming notification", "pdu", fmt.Sprintf("[% X]", b))
 		}
 
 		// Always write aknowledgement for an indication, even it was an invalid request.
 		if b[0] == HandleValueIndicationCode {
-			logger.Debug("client", "req", fmt.Sprintf("% X", b))
+			c.log.Debug("client send", "pdu", fmt.Sprintf("[% X]", confirmation))
 			_, _ = c.l2c.Write(confirmation)
 		}
 	}
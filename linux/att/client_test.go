@@ -0,0 +1,60 @@
+package att
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kirbo/ble"
+)
+
+func TestRequestAbandoned(t *testing.T) {
+	cc, sc := newPipeConns()
+	defer cc.Close()
+	defer sc.Close()
+	c := NewClient(cc, nil)
+	go c.Loop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.WithContext(ctx).Read(0x0003)
+		errc <- err
+	}()
+	b := make([]byte, ble.MaxMTU)
+	if n, err := sc.Read(b); err != nil || n != 3 || b[0] != ReadRequestCode || b[1] != 0x03 {
+		t.Fatalf("request [% X], %v", b[:n], err)
+	}
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("Read() = %v, want context.Canceled", err)
+	}
+
+	// The response to the abandoned request isn't taken for that of the
+	// next one.
+	type result struct {
+		v   []byte
+		err error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		v, err := c.Read(0x0005)
+		rc <- result{v, err}
+	}()
+	if _, err := sc.Write([]byte{ReadResponseCode, 0xAA}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := sc.Read(b); err != nil || n != 3 || b[1] != 0x05 {
+		t.Fatalf("request [% X], %v", b[:n], err)
+	}
+	if _, err := sc.Write([]byte{ReadResponseCode, 0xBB}); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-rc; r.err != nil || len(r.v) != 1 || r.v[0] != 0xBB {
+		t.Errorf("Read() = [% X], %v, want [BB]", r.v, r.err)
+	}
+
+	// Nor is a request sent, once its context is done.
+	if _, err := c.WithContext(ctx).Read(0x0007); err != context.Canceled {
+		t.Errorf("Read() = %v, want context.Canceled", err)
+	}
+}
@@ -61,6 +61,28 @@ func (r *DB) subrange(start, end uint16) []*attr {
 	return r.attrs[startidx:endidx]
 }
 
+// Attributes returns the attribute table of the database.
+func (r *DB) Attributes() []ble.Attribute {
+	as := make([]ble.Attribute, 0, len(r.attrs))
+	for _, a := range r.attrs {
+		ba := ble.Attribute{
+			Handle:          a.h,
+			Type:            a.typ,
+			Dynamic:         a.rh != nil || a.wh != nil,
+			ReadPermission:  a.rperm,
+			WritePermission: a.wperm,
+		}
+		if a.typ.Equal(ble.PrimaryServiceUUID) || a.typ.Equal(ble.CharacteristicUUID) {
+			ba.EndHandle = a.endh
+		}
+		if a.v != nil {
+			ba.Value = append([]byte(nil), a.v...)
+		}
+		as = append(as, ba)
+	}
+	return as
+}
+
 // NewDB ...
 func NewDB(ss []*ble.Service, base uint16) *DB {
 	h := base
@@ -111,6 +133,10 @@ func genCharAttr(c *ble.Characteristic, h uint16) (uint16, []*attr) {
 		v:   c.Value,
 		rh:  c.ReadHandler,
 		wh:  c.WriteHandler,
+
+		c:     c,
+		rperm: c.ReadPermission,
+		wperm: c.WritePermission,
 	}
 
 	c.Handle = h
@@ -133,6 +159,7 @@ func genCharAttr(c *ble.Characteristic, h uint16) (uint16, []*attr) {
 }
 
 func genDescAttr(d *ble.Descriptor, h uint16) *attr {
+	d.Handle = h
 	return &attr{
 		h:   h,
 		typ: d.UUID,
@@ -144,14 +171,14 @@ func genDescAttr(d *ble.Descriptor, h uint16) *attr {
 
 // DumpAttributes ...
 func DumpAttributes(aa []*attr) {
-	logger.Debug("server", "db", "Generating attribute table:")
-	logger.Debug("server", "db", "handle   endh   type")
+	logger := ble.SubsystemLogger{Logger: ble.DefaultLogger, Subsystem: ble.LogATT}
+	logger.Debug("generating attribute table")
 	for _, a := range aa {
 		if a.v != nil {
-			logger.Debug("server", "db", fmt.Sprintf("0x%04X 0x%04X 0x%s [% X]", a.h, a.endh, a.typ, a.v))
+			logger.Debug("attribute", "handle", fmt.Sprintf("0x%04X", a.h), "endh", fmt.Sprintf("0x%04X", a.endh), "type", a.typ, "value", fmt.Sprintf("[% X]", a.v))
 			continue
 		}
-		logger.Debug("server", "db", fmt.Sprintf("0x%04X 0x%04X 0x%s", a.h, a.endh, a.typ))
+		logger.Debug("attribute", "handle", fmt.Sprintf("0x%04X", a.h), "endh", fmt.Sprintf("0x%04X", a.endh), "type", a.typ)
 	}
 }
 
@@ -160,32 +187,55 @@ const (
 	cccIndicate = 0x0002
 )
 
+// subscriptions returns the number of characteristics of cccs, which are
+// subscribed to.
+func subscriptions(cccs map[uint16]uint16) int {
+	n := 0
+	for _, ccc := range cccs {
+		if ccc != 0 {
+			n++
+		}
+	}
+	return n
+}
+
 func newCCCD(c *ble.Characteristic) *ble.Descriptor {
 	d := ble.NewDescriptor(ble.ClientCharacteristicConfigUUID)
 
 	d.HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
-		cccs := req.Conn().(*conn).cccs
-		ccc := cccs[c.Handle]
+		cn := req.Conn().(*conn)
+		cn.mu.Lock()
+		ccc := cn.cccs[c.Handle]
+		cn.mu.Unlock()
 		binary.Write(rsp, binary.LittleEndian, ccc)
 	}))
 
 	d.HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
 		cn := req.Conn().(*conn)
+		cn.mu.Lock()
+		defer cn.mu.Unlock()
 		old := cn.cccs[c.Handle]
 		ccc := binary.LittleEndian.Uint16(req.Data())
+		if max := cn.svr.limits.Subscriptions; max > 0 && old == 0 && ccc != 0 && subscriptions(cn.cccs) >= max {
+			rsp.SetStatus(ble.ErrInsuffResources)
+			return
+		}
 
 		oldNotify := old&cccNotify != 0
 		oldIndicate := old&cccIndicate != 0
 		newNotify := ccc&cccNotify != 0
 		newIndicate := ccc&cccIndicate != 0
 
+		// A notification, or an indication, carries ATT_MTU-3 bytes of the
+		// value. [Vol 3, Part F, 3.4.7.1]
+		capf := func() int { return cn.TxMTU() - 3 }
 		if newNotify && !oldNotify {
 			if c.Property&ble.CharNotify == 0 {
-				rsp.SetStatus(ble.ErrUnlikely)
+				rsp.SetStatus(ble.ErrCCCDImproper)
 				return
 			}
 			send := func(b []byte) (int, error) { return cn.svr.notify(c.ValueHandle, b) }
-			cn.nn[c.Handle] = ble.NewNotifier(send)
+			cn.nn[c.Handle] = ble.NewNotifierWithCap(send, capf)
 			go c.NotifyHandler.ServeNotify(req, cn.nn[c.Handle])
 		}
 		if !newNotify && oldNotify {
@@ -194,11 +244,11 @@ func newCCCD(c *ble.Characteristic) *ble.Descriptor {
 
 		if newIndicate && !oldIndicate {
 			if c.Property&ble.CharIndicate == 0 {
-				rsp.SetStatus(ble.ErrUnlikely)
+				rsp.SetStatus(ble.ErrCCCDImproper)
 				return
 			}
 			send := func(b []byte) (int, error) { return cn.svr.indicate(c.ValueHandle, b) }
-			cn.in[c.Handle] = ble.NewNotifier(send)
+			cn.in[c.Handle] = ble.NewNotifierWithCap(send, capf)
 			go c.IndicateHandler.ServeNotify(req, cn.in[c.Handle])
 		}
 		if !newIndicate && oldIndicate {
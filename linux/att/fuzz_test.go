@@ -0,0 +1,36 @@
+//go:build go1.18
+// +build go1.18
+
+package att
+
+import "testing"
+
+func FuzzValidate(f *testing.F) {
+	f.Add([]byte{ErrorResponseCode, ReadRequestCode, 0x03, 0x00, 0x0A})
+	f.Add([]byte{FindInformationResponseCode, 0x01, 0x01, 0x00, 0x00, 0x28})
+	f.Add([]byte{ReadByTypeResponseCode, 0x07, 0x02, 0x00, 0x02, 0x03, 0x00, 0x00, 0x2A})
+	f.Add([]byte{ReadByGroupTypeResponseCode, 0x06, 0x01, 0x00, 0x05, 0x00, 0x00, 0x18})
+	f.Add([]byte{HandleValueNotificationCode, 0x03, 0x00, 0x01})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if Validate(b) != nil {
+			return
+		}
+		// The fields, and the entries of the lists, of a valid PDU are in
+		// range.
+		switch b[0] {
+		case FindInformationResponseCode:
+			n := map[byte]int{0x01: 4, 0x02: 18}[b[1]]
+			for i := 2; i < len(b); i += n {
+				_ = b[i : i+n]
+			}
+		case ReadByTypeResponseCode, ReadByGroupTypeResponseCode:
+			for i := 2; i < len(b); i += int(b[1]) {
+				_ = b[i : i+int(b[1])]
+			}
+		case HandleValueNotificationCode, HandleValueIndicationCode:
+			_ = HandleValueNotification(b).AttributeHandle()
+		case ErrorResponseCode:
+			_ = ErrorResponse(b).ErrorCode()
+		}
+	})
+}
@@ -1,7 +1,8 @@
 package att
 
-import (
-	"github.com/mgutz/logxi/v1"
-)
+import "github.com/kirbo/ble"
 
-var logger = log.New("att")
+// connLogger returns the logger of the ATT events on l2c.
+func connLogger(l2c ble.Conn) ble.SubsystemLogger {
+	return ble.SubsystemLogger{Logger: ble.LoggerFromContext(l2c.Context()), Subsystem: ble.LogATT}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kirbo/ble"
@@ -12,7 +14,11 @@ import (
 
 type conn struct {
 	ble.Conn
-	svr  *Server
+	svr *Server
+
+	// The client characteristic configurations are shared by all the
+	// bearers of a client, so they are guarded by mu.
+	mu   *sync.Mutex
 	cccs map[uint16]uint16
 	nn   map[uint16]ble.Notifier
 	in   map[uint16]ble.Notifier
@@ -31,13 +37,47 @@ type Server struct {
 	chIndBuf  chan []byte
 	chConfirm chan bool
 
+	// Indications are queued, and sent one at a time by indicationLoop.
+	indPolicy ble.IndicationPolicy
+	chInd     chan *indication
+	pending   int32 // Indications pending, queued or sent, guarded by atomic.
+	done      chan struct{}
+
 	dummyRspWriter ble.ResponseWriter
 
-	// Store a write handler for defer execute once receiving ExecuteWriteRequest
-	prepareWriteRequestAttr *attr
-	prepareWriteRequestData bytes.Buffer
+	// Queued writes of this connection, executed once receiving
+	// ExecuteWriteRequest. [Vol 3, Part F, 3.4.6]
+	prepQueue []preparedWrite
+
+	// bearer is set for the additional bearers created by NewBearer.
+	bearer bool
+
+	authorizer ble.Authorizer
+	auditHook  ble.AuditHook
+
+	// The limits of the client, and its rate, as a bucket of tokens, which
+	// is shared by its bearers.
+	limits  ble.ServerLimits
+	rateMu  sync.Mutex
+	tokens  float64
+	tokenAt time.Time
+	limited bool
+
+	log     ble.SubsystemLogger
+	metrics ble.MetricsCollector
+	clock   ble.Clock
 }
 
+// preparedWrite is an entry of the prepare queue.
+type preparedWrite struct {
+	a      *attr
+	offset uint16
+	data   []byte
+}
+
+// maxPrepQueueLen is the number of Prepare Write requests a connection can queue.
+const maxPrepQueueLen = 64
+
 // NewServer returns an ATT (Attribute Protocol) server.
 func NewServer(db *DB, l2c ble.Conn) (*Server, error) {
 	mtu := l2c.RxMTU()
@@ -50,6 +90,7 @@ func NewServer(db *DB, l2c ble.Conn) (*Server, error) {
 	s := &Server{
 		conn: &conn{
 			Conn: l2c,
+			mu:   &sync.Mutex{},
 			cccs: make(map[uint16]uint16),
 			in:   make(map[uint16]ble.Notifier),
 			nn:   make(map[uint16]ble.Notifier),
@@ -61,8 +102,14 @@ func NewServer(db *DB, l2c ble.Conn) (*Server, error) {
 		chNotBuf:  make(chan []byte, 1),
 		chIndBuf:  make(chan []byte, 1),
 		chConfirm: make(chan bool),
+		chInd:     make(chan *indication, defaultIndQueueLen),
+		done:      make(chan struct{}),
 
 		dummyRspWriter: ble.NewResponseWriter(nil),
+
+		log:     connLogger(l2c),
+		metrics: ble.MetricsFromContext(l2c.Context()),
+		clock:   ble.ClockFromContext(l2c.Context()),
 	}
 	s.conn.svr = s
 	s.chNotBuf <- make([]byte, ble.DefaultMTU, ble.DefaultMTU)
@@ -70,6 +117,27 @@ func NewServer(db *DB, l2c ble.Conn) (*Server, error) {
 	return s, nil
 }
 
+// NewBearer returns an ATT server for an additional bearer of the same client,
+// such as an EATT bearer. It serves the same DB, and shares the client
+// characteristic configurations with s, whose bearer carries the
+// notifications and indications. [Vol 3, Part G, 5.3]
+func (s *Server) NewBearer(l2c ble.Conn) (*Server, error) {
+	b, err := NewServer(s.db, l2c)
+	if err != nil {
+		return nil, err
+	}
+	b.conn.svr = s
+	b.conn.mu = s.conn.mu
+	b.conn.cccs = s.conn.cccs
+	b.conn.nn = s.conn.nn
+	b.conn.in = s.conn.in
+	b.bearer = true
+
+	// The MTUs of an EATT bearer are set when its channel is opened.
+	b.txBuf = make([]byte, l2c.TxMTU(), l2c.TxMTU())
+	return b, nil
+}
+
 // notify sends notification to remote central.
 func (s *Server) notify(h uint16, data []byte) (int, error) {
 	// Acquire and reuse notifyBuffer. Release it after usage.
@@ -85,36 +153,280 @@ func (s *Server) notify(h uint16, data []byte) (int, error) {
 		data = data[:buf.Cap()]
 	}
 	buf.Write(data)
-	return s.conn.Write(rsp[:3+buf.Len()])
+	n, err := s.conn.Write(rsp[:3+buf.Len()])
+	if err == nil {
+		s.metrics.Add(ble.MetricNotificationsTx, 1)
+	}
+	return n, err
+}
+
+// defaultIndQueueLen is the number of pending indications per subscriber,
+// unless set by the IndicationPolicy.
+const defaultIndQueueLen = 16
+
+type indication struct {
+	h    uint16
+	data []byte
+	done chan error
+}
+
+// SetIndicationPolicy configures the delivery of indications. It must be
+// called before Loop.
+func (s *Server) SetIndicationPolicy(p ble.IndicationPolicy) {
+	if p.QueueLen <= 0 {
+		p.QueueLen = defaultIndQueueLen
+	}
+	if p.Timeout <= 0 {
+		p.Timeout = 30 * time.Second
+	}
+	s.indPolicy = p
+	s.chInd = make(chan *indication, p.QueueLen)
+}
+
+// SetAuthorizer sets the Authorizer of the characteristics, which require
+// ble.PermAuthorize, for s and its bearers. Without one, their accesses are
+// denied. It must be called before Loop.
+func (s *Server) SetAuthorizer(a ble.Authorizer) {
+	s.authorizer = a
+}
+
+// SetAuditHook sets the AuditHook, which is told the attribute operations of
+// s and its bearers. It must be called before Loop.
+func (s *Server) SetAuditHook(f ble.AuditHook) {
+	s.auditHook = f
+}
+
+// SetLimits sets the ServerLimits of the client of s and its bearers. It
+// must be called before Loop.
+func (s *Server) SetLimits(l ble.ServerLimits) {
+	s.limits = l
+	s.tokens = float64(l.OpsPerSecond)
+}
+
+// overRate takes a token of the rate of the client for a request, or command,
+// and reports false if there's none, so that the request is dropped. The
+// client is disconnected once it runs out of them.
+func (s *Server) overRate() bool {
+	svr := s.conn.svr
+	rate := float64(svr.limits.OpsPerSecond)
+	if rate <= 0 {
+		return false
+	}
+	svr.rateMu.Lock()
+	defer svr.rateMu.Unlock()
+	if svr.limited {
+		return true
+	}
+	now := s.clock.Now()
+	if !svr.tokenAt.IsZero() {
+		svr.tokens += now.Sub(svr.tokenAt).Seconds() * rate
+		if svr.tokens > rate {
+			svr.tokens = rate
+		}
+	}
+	svr.tokenAt = now
+	if svr.tokens >= 1 {
+		svr.tokens--
+		return false
+	}
+	svr.limited = true
+	s.log.Warn("client exceeds the rate limit, disconnecting", "addr", svr.conn.RemoteAddr(), "rate", svr.limits.OpsPerSecond)
+	s.metrics.Add(ble.MetricATTClientsLimited, 1)
+	svr.conn.Close()
+	return true
+}
+
+// maxPrepQueue returns the number of Prepare Write requests the client may
+// queue.
+func (s *Server) maxPrepQueue() int {
+	if n := s.conn.svr.limits.PreparedWrites; n > 0 && n < maxPrepQueueLen {
+		return n
+	}
+	return maxPrepQueueLen
+}
+
+// audit tells the AuditHook, if any, the outcome of the request, or command,
+// b, as told by the response rsp.
+func (s *Server) audit(b, rsp []byte) {
+	// The bearers of a client share the hook of its first one.
+	svr := s.conn.svr
+	if svr.auditHook == nil {
+		return
+	}
+	op := ble.ATTOp{Addr: svr.conn.RemoteAddr(), Opcode: b[0]}
+	switch b[0] {
+	case ExchangeMTURequestCode, ExecuteWriteRequestCode:
+	default:
+		if len(b) >= 3 {
+			op.Handle = binary.LittleEndian.Uint16(b[1:])
+		}
+	}
+	if len(rsp) == 5 && rsp[0] == ErrorResponseCode {
+		op.Handle = ErrorResponse(rsp).AttributeInError()
+		op.Err = ble.ATTError(ErrorResponse(rsp).ErrorCode())
+	}
+	svr.auditHook(op)
+}
+
+// checkPermission returns ErrSuccess if the link meets the permission of
+// reading, or writing if write is set, a, or else the ATT error to respond
+// with.
+func (s *Server) checkPermission(a *attr, write bool) ble.ATTError {
+	p := a.rperm
+	if write {
+		p = a.wperm
+	}
+	if p == 0 {
+		return ble.ErrSuccess
+	}
+	// The bearers of a client share the link, and the Authorizer, of its
+	// first one.
+	svr := s.conn.svr
+	var sec ble.Security
+	if c, ok := svr.conn.Conn.(interface{ Security() ble.Security }); ok {
+		sec = c.Security()
+	}
+	var authorize func() bool
+	if svr.authorizer != nil {
+		authorize = func() bool { return svr.authorizer(svr.conn, a.c, write) }
+	}
+	if err := ble.CheckPermission(p, sec, authorize); err != nil {
+		s.log.Debug("access denied", "addr", svr.conn.RemoteAddr(), "handle", fmt.Sprintf("0x%04X", a.h), "security", sec, "err", err)
+		return err.(ble.ATTError)
+	}
+	return ble.ErrSuccess
 }
 
-// indicate sends indication to remote central.
+// indicate queues an indication to remote central, and waits for the result.
 func (s *Server) indicate(h uint16, data []byte) (int, error) {
+	if max := s.indPolicy.MaxPending; max > 0 {
+		if atomic.AddInt32(&s.pending, 1) > int32(max) {
+			atomic.AddInt32(&s.pending, -1)
+			return 0, ble.ErrIndicationsPending
+		}
+		defer atomic.AddInt32(&s.pending, -1)
+	}
+	ind := &indication{h: h, data: append([]byte(nil), data...), done: make(chan error, 1)}
+	select {
+	case s.chInd <- ind:
+	case <-s.done:
+		return 0, io.ErrClosedPipe
+	}
+	var err error
+	select {
+	case err = <-ind.done:
+	case <-s.done:
+		// The indicationLoop may have returned without seeing ind.
+		select {
+		case err = <-ind.done:
+		default:
+			err = io.ErrClosedPipe
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(ind.data), nil
+}
+
+// indicationLoop sends the queued indications in order.
+func (s *Server) indicationLoop() {
+	p := s.indPolicy
+	if p.Timeout <= 0 {
+		p.Timeout = 30 * time.Second
+	}
+	for {
+		select {
+		case ind := <-s.chInd:
+			var err error
+			attempts := 0
+			for attempts <= p.Retries {
+				attempts++
+				if err = s.sendIndication(ind, p.Timeout); err != ErrSeqProtoTimeout {
+					break
+				}
+			}
+			if err == ErrSeqProtoTimeout {
+				s.log.Warn("indication unconfirmed", "addr", s.conn.RemoteAddr(), "handle", fmt.Sprintf("0x%04X", ind.h), "attempts", attempts)
+				if p.Unsubscribe {
+					s.unsubscribe(ind.h)
+				}
+			}
+			ind.done <- err
+			if p.OnResult != nil {
+				p.OnResult(ble.IndicationResult{
+					Conn:     s.conn,
+					Handle:   ind.h,
+					Data:     ind.data,
+					Attempts: attempts,
+					Err:      err,
+				})
+			}
+			if err == ErrSeqProtoTimeout && p.Disconnect {
+				s.conn.Close()
+			}
+		case <-s.done:
+			// Fail the indications that are still queued.
+			for {
+				select {
+				case ind := <-s.chInd:
+					ind.done <- io.ErrClosedPipe
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// unsubscribe disables the indications of the characteristic of the value
+// handle vh, as the client would with its CCCD.
+func (s *Server) unsubscribe(vh uint16) {
+	a, ok := s.db.at(vh)
+	if !ok || a.c == nil {
+		return
+	}
+	h := a.c.Handle
+	cn := s.conn
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	if cn.cccs[h]&cccIndicate == 0 {
+		return
+	}
+	cn.in[h].Close()
+	cn.cccs[h] &^= cccIndicate
+	s.log.Info("indications disabled", "addr", s.conn.RemoteAddr(), "handle", fmt.Sprintf("0x%04X", h))
+}
+
+// sendIndication sends an indication, and waits for its confirmation.
+func (s *Server) sendIndication(ind *indication, tmo time.Duration) error {
 	// Acquire and reuse indicateBuffer. Release it after usage.
 	iBuf := <-s.chIndBuf
 	defer func() { s.chIndBuf <- iBuf }()
 
 	rsp := HandleValueIndication(iBuf)
 	rsp.SetAttributeOpcode()
-	rsp.SetAttributeHandle(h)
+	rsp.SetAttributeHandle(ind.h)
 	buf := bytes.NewBuffer(rsp.AttributeValue())
 	buf.Reset()
-	if len(data) > buf.Cap() {
-		data = data[:buf.Cap()]
+	if len(ind.data) > buf.Cap() {
+		ind.data = ind.data[:buf.Cap()]
 	}
-	buf.Write(data)
-	n, err := s.conn.Write(rsp[:3+buf.Len()])
-	if err != nil {
-		return n, err
+	buf.Write(ind.data)
+	if _, err := s.conn.Write(rsp[:3+buf.Len()]); err != nil {
+		return err
 	}
+	s.metrics.Add(ble.MetricNotificationsTx, 1)
+	t := s.clock.NewTimer(tmo)
+	defer t.Stop()
 	select {
 	case _, ok := <-s.chConfirm:
 		if !ok {
-			return 0, io.ErrClosedPipe
+			return io.ErrClosedPipe
 		}
-		return n, nil
-	case <-time.After(time.Second * 30):
-		return 0, ErrSeqProtoTimeout
+		return nil
+	case <-t.C():
+		return ErrSeqProtoTimeout
 	}
 }
 
@@ -128,6 +440,8 @@ func (s *Server) Loop() {
 	pool <- &sbuf{buf: make([]byte, s.rxMTU)}
 	pool <- &sbuf{buf: make([]byte, s.rxMTU)}
 
+	go s.indicationLoop()
+
 	seq := make(chan *sbuf)
 	go func() {
 		b := <-pool
@@ -136,6 +450,7 @@ func (s *Server) Loop() {
 			if n == 0 || err != nil {
 				close(seq)
 				close(s.chConfirm)
+				close(s.done)
 				_ = s.conn.Close()
 				return
 			}
@@ -143,7 +458,7 @@ func (s *Server) Loop() {
 				select {
 				case s.chConfirm <- true:
 				default:
-					logger.Error("server", "received a spurious confirmation", nil)
+					s.log.Warn("received a spurious confirmation")
 				}
 				continue
 			}
@@ -153,6 +468,10 @@ func (s *Server) Loop() {
 		}
 	}()
 	for req := range seq {
+		if s.overRate() {
+			pool <- req
+			continue
+		}
 		if rsp := s.handleRequest(req.buf[:req.len]); rsp != nil {
 			if len(rsp) != 0 {
 				s.conn.Write(rsp)
@@ -160,9 +479,16 @@ func (s *Server) Loop() {
 		}
 		pool <- req
 	}
+	// The notifiers outlive additional bearers, and are closed with the
+	// client's original bearer.
+	if s.bearer {
+		return
+	}
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
 	for h, ccc := range s.conn.cccs {
 		if ccc != 0 {
-			logger.Info("cleanup", ble.ContextKeyCCC, fmt.Sprintf("0x%02X", ccc))
+			s.log.Info("cleanup", "handle", fmt.Sprintf("0x%04X", h), "ccc", fmt.Sprintf("0x%02X", ccc))
 		}
 		if ccc&cccIndicate != 0 {
 			s.conn.in[h].Close()
@@ -175,9 +501,14 @@ func (s *Server) Loop() {
 
 func (s *Server) handleRequest(b []byte) []byte {
 	var resp []byte
-	logger.Debug("server", "req", fmt.Sprintf("% X", b))
+	s.log.Debug("server recv", "pdu", fmt.Sprintf("[% X]", b))
 	switch reqType := b[0]; reqType {
 	case ExchangeMTURequestCode:
+		// MTU exchange isn't allowed on EATT bearers. [Vol 3, Part F, 3.4.2.1]
+		if s.bearer {
+			resp = newErrorResponse(reqType, 0x0000, ble.ErrReqNotSupp)
+			break
+		}
 		resp = s.handleExchangeMTURequest(b)
 	case FindInformationRequestCode:
 		resp = s.handleFindInformationRequest(b)
@@ -194,18 +525,24 @@ func (s *Server) handleRequest(b []byte) []byte {
 	case WriteRequestCode:
 		resp = s.handleWriteRequest(b)
 	case WriteCommandCode:
-		s.handleWriteCommand(b)
+		resp = s.handleWriteCommand(b)
 	case PrepareWriteRequestCode:
 		resp = s.handlePrepareWriteRequest(b)
 	case ExecuteWriteRequestCode:
 		resp = s.handleExecuteWriteRequest(b)
-	case ReadMultipleRequestCode,
-		SignedWriteCommandCode:
+	case SignedWriteCommandCode:
+		resp = s.handleSignedWriteCommand(b)
+	case ReadMultipleRequestCode:
 		fallthrough
 	default:
 		resp = newErrorResponse(reqType, 0x0000, ble.ErrReqNotSupp)
 	}
-	logger.Debug("server", "rsp", fmt.Sprintf("% X", resp))
+	s.audit(b, resp)
+	if b[0] == WriteCommandCode || b[0] == SignedWriteCommandCode {
+		// The errors of commands are only audited. [Vol 3, Part F, 3.4.5.3]
+		return nil
+	}
+	s.log.Debug("server send", "pdu", fmt.Sprintf("[% X]", resp))
 	return resp
 }
 
@@ -355,13 +692,19 @@ func (s *Server) handleReadByTypeRequest(r ReadByTypeRequest) []byte {
 		if !a.typ.Equal(ble.UUID(r.AttributeType())) {
 			continue
 		}
+		if e := s.checkPermission(a, false); e != ble.ErrSuccess {
+			if dlen == 0 {
+				return newErrorResponse(r.AttributeOpcode(), a.h, e)
+			}
+			break
+		}
 		v := a.v
 		if v == nil {
 			buf2 := bytes.NewBuffer(make([]byte, 0, len(s.txBuf)-2))
 			if e := handleATT(a, s, r, ble.NewResponseWriter(buf2)); e != ble.ErrSuccess {
 				// Return if the first value read cause an error.
 				if dlen == 0 {
-					return newErrorResponse(r.AttributeOpcode(), r.StartingHandle(), e)
+					return newErrorResponse(r.AttributeOpcode(), a.h, e)
 				}
 				// Otherwise, skip to the next one.
 				break
@@ -411,11 +754,14 @@ func (s *Server) handleReadRequest(r ReadRequest) []byte {
 	if !ok {
 		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrInvalidHandle)
 	}
+	if e := s.checkPermission(a, false); e != ble.ErrSuccess {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
+	}
 
 	// Simple case. Read-only, no-authorization, no-authentication.
+	// Values longer than the MTU are read on with Read Blob.
 	if a.v != nil {
-		binary.Write(buf, binary.LittleEndian, a.v)
-		return rsp[:1+buf.Len()]
+		return rsp[:1+copy(rsp.AttributeValue(), a.v)]
 	}
 
 	// Pass the request to upper layer with the ResponseWriter, which caps
@@ -444,10 +790,16 @@ func (s *Server) handleReadBlobRequest(r ReadBlobRequest) []byte {
 	buf := bytes.NewBuffer(rsp.PartAttributeValue())
 	buf.Reset()
 
+	if e := s.checkPermission(a, false); e != ble.ErrSuccess {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
+	}
+
 	// Simple case. Read-only, no-authorization, no-authentication.
 	if a.v != nil {
-		binary.Write(buf, binary.LittleEndian, a.v)
-		return rsp[:1+buf.Len()]
+		if int(r.ValueOffset()) > len(a.v) {
+			return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrInvalidOffset)
+		}
+		return rsp[:1+copy(rsp.PartAttributeValue(), a.v[r.ValueOffset():])]
 	}
 
 	// Pass the request to upper layer with the ResponseWriter, which caps
@@ -479,7 +831,7 @@ func (s *Server) handleReadByGroupRequest(r ReadByGroupTypeRequest) []byte {
 		if v == nil {
 			buf2 := bytes.NewBuffer(make([]byte, buf.Cap()-buf.Len()-4))
 			if e := handleATT(a, s, r, ble.NewResponseWriter(buf2)); e != ble.ErrSuccess {
-				return newErrorResponse(r.AttributeOpcode(), r.StartingHandle(), e)
+				return newErrorResponse(r.AttributeOpcode(), a.h, e)
 			}
 			v = buf2.Bytes()
 		}
@@ -526,17 +878,20 @@ func (s *Server) handleWriteRequest(r WriteRequest) []byte {
 	if a == nil {
 		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrWriteNotPerm)
 	}
+	if e := s.checkPermission(a, true); e != ble.ErrSuccess {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
+	}
 	if e := handleATT(a, s, r, ble.NewResponseWriter(nil)); e != ble.ErrSuccess {
 		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
 	}
 	return []byte{WriteResponseCode}
 }
 
+// handle Prepare Write request. [Vol 3, Part F, 3.4.6.1 & 3.4.6.2]
 func (s *Server) handlePrepareWriteRequest(r PrepareWriteRequest) []byte {
-	logger.Debug("handlePrepareWriteRequest ->", "r.AttributeHandle", r.AttributeHandle())
 	// Validate the request.
 	switch {
-	case len(r) < 3:
+	case len(r) < 5:
 		return newErrorResponse(r.AttributeOpcode(), 0x0000, ble.ErrInvalidPDU)
 	}
 
@@ -546,20 +901,28 @@ func (s *Server) handlePrepareWriteRequest(r PrepareWriteRequest) []byte {
 	}
 
 	// We don't support write to static value. Pass the request to upper layer.
-	if a == nil {
+	if a == nil || a.wh == nil {
 		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrWriteNotPerm)
 	}
-
-	if e := handleATT(a, s, r, ble.NewResponseWriter(nil)); e != ble.ErrSuccess {
+	if e := s.checkPermission(a, true); e != ble.ErrSuccess {
 		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
 	}
+	if len(s.prepQueue) >= s.maxPrepQueue() {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrPrepQueueFull)
+	}
+
+	// The request is backed by the read buffer, which is reused.
+	data := make([]byte, len(r.PartAttributeValue()))
+	copy(data, r.PartAttributeValue())
+	s.prepQueue = append(s.prepQueue, preparedWrite{a: a, offset: r.ValueOffset(), data: data})
 
-	// Convert and validate the response.
+	// Echo the request back, so the client can verify it. [Vol 3, Part G, 4.9.5]
 	rsp := PrepareWriteResponse(r)
 	rsp.SetAttributeOpcode()
 	return rsp
 }
 
+// handle Execute Write request. [Vol 3, Part F, 3.4.6.3 & 3.4.6.4]
 func (s *Server) handleExecuteWriteRequest(r ExecuteWriteRequest) []byte {
 	// Validate the request.
 	switch {
@@ -567,16 +930,41 @@ func (s *Server) handleExecuteWriteRequest(r ExecuteWriteRequest) []byte {
 		return newErrorResponse(r.AttributeOpcode(), 0x0000, ble.ErrInvalidPDU)
 	}
 
+	q := s.prepQueue
+	s.prepQueue = nil
+
 	switch r.Flags() {
 	case 0:
 		// 0x00 – Cancel all prepared writes
-		s.prepareWriteRequestAttr = nil
 	case 1:
 		// 0x01 – Immediately write all pending prepared values
-		a := s.prepareWriteRequestAttr
-		if e := handleATT(a, s, r, ble.NewResponseWriter(nil)); e != ble.ErrSuccess {
-			return newErrorResponse(r.AttributeOpcode(), 0, e)
+		// Assemble the values per attribute, in the order they were first prepared.
+		var attrs []*attr
+		vals := make(map[*attr][]byte)
+		for _, w := range q {
+			v, ok := vals[w.a]
+			if !ok {
+				attrs = append(attrs, w.a)
+			}
+			if int(w.offset) > len(v) {
+				return newErrorResponse(r.AttributeOpcode(), w.a.h, ble.ErrInvalidOffset)
+			}
+			v = append(v[:w.offset], w.data...)
+			if len(v) > 512 {
+				return newErrorResponse(r.AttributeOpcode(), w.a.h, ble.ErrInvalAttrValueLen)
+			}
+			vals[w.a] = v
 		}
+		for _, a := range attrs {
+			rsp := ble.NewResponseWriter(nil)
+			rsp.SetStatus(ble.ErrSuccess)
+			a.wh.ServeWrite(ble.NewRequest(s.conn, vals[a], 0), rsp)
+			if e := rsp.Status(); e != ble.ErrSuccess {
+				return newErrorResponse(r.AttributeOpcode(), a.h, e)
+			}
+		}
+	default:
+		return newErrorResponse(r.AttributeOpcode(), 0x0000, ble.ErrInvalidPDU)
 	}
 
 	return []byte{ExecuteWriteResponseCode}
@@ -587,20 +975,79 @@ func (s *Server) handleWriteCommand(r WriteCommand) []byte {
 	// Validate the request.
 	switch {
 	case len(r) <= 3:
-		return nil
+		return newErrorResponse(r.AttributeOpcode(), 0x0000, ble.ErrInvalidPDU)
 	}
 
 	a, ok := s.db.at(r.AttributeHandle())
 	if !ok {
-		return nil
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrInvalidHandle)
 	}
 
 	// We don't support write to static value. Pass the request to upper layer.
 	if a == nil {
-		return nil
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrWriteNotPerm)
+	}
+	if e := s.checkPermission(a, true); e != ble.ErrSuccess {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
 	}
 	if e := handleATT(a, s, r, s.dummyRspWriter); e != ble.ErrSuccess {
-		return nil
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
+	}
+	return nil
+}
+
+// handle Signed Write command. [Vol 3, Part F, 3.4.5.4]
+// The signature is verified against the peer CSRK in the BondStore of the
+// connection, and the sign counter has to increase. [Vol 3, Part C, 10.4.2]
+func (s *Server) handleSignedWriteCommand(r SignedWriteCommand) []byte {
+	// Validate the request.
+	switch {
+	case len(r) < 15:
+		return newErrorResponse(r.AttributeOpcode(), 0x0000, ble.ErrInvalidPDU)
+	}
+
+	a, ok := s.db.at(r.AttributeHandle())
+	if !ok {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrInvalidHandle)
+	}
+	if a == nil {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrWriteNotPerm)
+	}
+
+	store, ok := ble.BondStoreFromContext(s.conn.Context())
+	if !ok {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrAuthentication)
+	}
+	peer := s.conn.RemoteAddr()
+	csrk, err := store.Load(peer, ble.BondPeerCSRK)
+	if err != nil || len(csrk) != 16 {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrAuthentication)
+	}
+	counter, ok := verifyPDU(csrk, r)
+	if !ok {
+		s.log.Warn("signed write with an invalid signature", "addr", peer)
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrAuthentication)
+	}
+	if b, err := store.Load(peer, ble.BondPeerSignCounter); err == nil && len(b) == 4 {
+		if counter <= binary.LittleEndian.Uint32(b) {
+			s.log.Warn("signed write with a replayed sign counter", "addr", peer, "counter", counter)
+			return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrAuthentication)
+		}
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, counter)
+	if err := store.Save(peer, ble.BondPeerSignCounter, b); err != nil {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrUnlikely)
+	}
+
+	// The signature stands for the encryption, on the unencrypted links it is
+	// used on, but not for an authorization. [Vol 3, Part C, 10.4.2]
+	if svr := s.conn.svr; a.wperm&ble.PermAuthorize != 0 && (svr.authorizer == nil || !svr.authorizer(svr.conn, a.c, true)) {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), ble.ErrAuthorization)
+	}
+
+	if e := handleATT(a, s, r, s.dummyRspWriter); e != ble.ErrSuccess {
+		return newErrorResponse(r.AttributeOpcode(), r.AttributeHandle(), e)
 	}
 	return nil
 }
@@ -633,27 +1080,6 @@ func handleATT(a *attr, s *Server, req []byte, rsp ble.ResponseWriter) ble.ATTEr
 		}
 		offset = int(ReadBlobRequest(req).ValueOffset())
 		a.rh.ServeRead(ble.NewRequest(conn, data, offset), rsp)
-	case PrepareWriteRequestCode:
-		if a.wh == nil {
-			return ble.ErrWriteNotPerm
-		}
-		data = PrepareWriteRequest(req).PartAttributeValue()
-		logger.Debug("handleATT", "PartAttributeValue",
-			fmt.Sprintf("data: %x, offset: %d, %p\n", data, int(PrepareWriteRequest(req).ValueOffset()), s.prepareWriteRequestAttr))
-
-		if s.prepareWriteRequestAttr == nil {
-			s.prepareWriteRequestAttr = a
-			s.prepareWriteRequestData.Reset()
-		}
-		s.prepareWriteRequestData.Write(data)
-
-	case ExecuteWriteRequestCode:
-		if a.wh == nil {
-			return ble.ErrWriteNotPerm
-		}
-		data = s.prepareWriteRequestData.Bytes()
-		a.wh.ServeWrite(ble.NewRequest(conn, data, offset), rsp)
-		s.prepareWriteRequestAttr = nil
 	case WriteRequestCode:
 		fallthrough
 	case WriteCommandCode:
@@ -662,7 +1088,13 @@ func handleATT(a *attr, s *Server, req []byte, rsp ble.ResponseWriter) ble.ATTEr
 		}
 		data = WriteRequest(req).AttributeValue()
 		a.wh.ServeWrite(ble.NewRequest(conn, data, offset), rsp)
-	// case SignedWriteCommandCode:
+	case SignedWriteCommandCode:
+		if a.wh == nil {
+			return ble.ErrWriteNotPerm
+		}
+		// The generated accessor assumes the value is the last field.
+		data = req[3 : len(req)-12]
+		a.wh.ServeWrite(ble.NewRequest(conn, data, offset), rsp)
 	// case ReadByGroupTypeRequestCode:
 	// case ReadMultipleRequestCode:
 	default:
@@ -0,0 +1,167 @@
+package att
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// pipeConn is a ble.Conn over one end of a net.Pipe, which keeps the PDUs
+// apart, as long as they're read whole.
+type pipeConn struct {
+	net.Conn
+	ctx  context.Context
+	done chan struct{}
+	once sync.Once
+}
+
+func newPipeConns() (*pipeConn, *pipeConn) {
+	a, b := net.Pipe()
+	return &pipeConn{Conn: a, ctx: context.Background(), done: make(chan struct{})},
+		&pipeConn{Conn: b, ctx: context.Background(), done: make(chan struct{})}
+}
+
+func (c *pipeConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+func (c *pipeConn) Context() context.Context       { return c.ctx }
+func (c *pipeConn) SetContext(ctx context.Context) { c.ctx = ctx }
+func (c *pipeConn) LocalAddr() ble.Addr            { return ble.NewAddr("11:22:33:44:55:66") }
+func (c *pipeConn) RemoteAddr() ble.Addr           { return ble.NewAddr("66:55:44:33:22:11") }
+func (c *pipeConn) RxMTU() int                     { return ble.DefaultMTU }
+func (c *pipeConn) SetRxMTU(mtu int)               {}
+func (c *pipeConn) TxMTU() int                     { return ble.DefaultMTU }
+func (c *pipeConn) SetTxMTU(mtu int)               {}
+func (c *pipeConn) Disconnected() <-chan struct{}  { return c.done }
+
+// indicatingServer returns a server of a characteristic, whose handler
+// indicates the values of vals, and passes on the errors of Write, and the
+// client end of its connection, which the caller closes.
+func indicatingServer(t *testing.T, p ble.IndicationPolicy, vals <-chan []byte, errs chan<- error) (*Server, *pipeConn, *ble.Characteristic) {
+	svc := ble.NewService(ble.UUID16(0x1800))
+	c := svc.NewCharacteristic(ble.UUID16(0x2a00))
+	c.HandleIndicate(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		for {
+			select {
+			case v := <-vals:
+				go func() {
+					_, err := n.Write(v)
+					errs <- err
+				}()
+			case <-n.Context().Done():
+				errs <- n.Context().Err()
+				return
+			}
+		}
+	}))
+	db := NewDB([]*ble.Service{svc}, 1)
+	sc, cc := newPipeConns()
+	s, err := NewServer(db, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetIndicationPolicy(p)
+	go s.Loop()
+	return s, cc, c
+}
+
+// subscribe enables the indications of c on the client end cc.
+func subscribe(t *testing.T, cc *pipeConn, c *ble.Characteristic) {
+	req := []byte{WriteRequestCode, byte(c.CCCD.Handle), byte(c.CCCD.Handle >> 8), 0x02, 0x00}
+	if _, err := cc.Write(req); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, ble.DefaultMTU)
+	n, err := cc.Read(b)
+	if err != nil || n != 1 || b[0] != WriteResponseCode {
+		t.Fatalf("write response % X, err %v", b[:n], err)
+	}
+}
+
+func TestIndicationUnconfirmed(t *testing.T) {
+	vals := make(chan []byte, 1)
+	errs := make(chan error, 2)
+	results := make(chan ble.IndicationResult, 1)
+	p := ble.IndicationPolicy{Timeout: 50 * time.Millisecond, Unsubscribe: true, OnResult: func(r ble.IndicationResult) { results <- r }}
+	s, cc, c := indicatingServer(t, p, vals, errs)
+	defer cc.Close()
+	subscribe(t, cc, c)
+
+	vals <- []byte{0x01}
+	b := make([]byte, ble.DefaultMTU)
+	if n, err := cc.Read(b); err != nil || n != 4 || b[0] != HandleValueIndicationCode {
+		t.Fatalf("indication % X, err %v", b[:n], err)
+	}
+	// Left unconfirmed, the indication times out, and the subscription is
+	// dropped, which ends the handler.
+	r := <-results
+	if r.Err != ErrSeqProtoTimeout || r.Handle != c.ValueHandle || r.Attempts != 1 {
+		t.Errorf("result %+v", r)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err == nil {
+				t.Error("unconfirmed indication written")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("handler not done")
+		}
+	}
+	s.conn.mu.Lock()
+	ccc := s.conn.cccs[c.Handle]
+	s.conn.mu.Unlock()
+	if ccc != 0 {
+		t.Errorf("CCC 0x%04X, want 0", ccc)
+	}
+}
+
+func TestIndicationMaxPending(t *testing.T) {
+	vals := make(chan []byte)
+	errs := make(chan error, 3)
+	p := ble.IndicationPolicy{Timeout: time.Second, MaxPending: 2}
+	_, cc, c := indicatingServer(t, p, vals, errs)
+	defer cc.Close()
+	subscribe(t, cc, c)
+
+	// The first indication is awaiting confirmation, the second is queued,
+	// and the third is one too many.
+	b := make([]byte, ble.DefaultMTU)
+	vals <- []byte{0x01}
+	if n, err := cc.Read(b); err != nil || b[0] != HandleValueIndicationCode {
+		t.Fatalf("indication % X, err %v", b[:n], err)
+	}
+	vals <- []byte{0x02}
+	time.Sleep(20 * time.Millisecond)
+	vals <- []byte{0x03}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ble.ErrIndicationsPending) {
+			t.Fatalf("err %v, want %v", err, ble.ErrIndicationsPending)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("indication beyond the limit not failed")
+	}
+
+	// As they're confirmed, the pending ones are done.
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			if n, err := cc.Read(b); err != nil || b[0] != HandleValueIndicationCode {
+				t.Fatalf("indication % X, err %v", b[:n], err)
+			}
+		}
+		if _, err := cc.Write([]byte{HandleValueConfirmationCode}); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package att
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/smp"
+)
+
+// signPDU returns the Authentication Signature of an ATT PDU, which is the
+// sign counter followed by the most significant 64 bits of the AES-CMAC of
+// the PDU and the counter. The Bluetooth fields are little endian, while
+// AES-CMAC is defined on big endian octet strings. [Vol 3, Part H, 2.4.5]
+func signPDU(csrk []byte, counter uint32, pdu []byte) [12]byte {
+	m := make([]byte, len(pdu)+4)
+	copy(m, pdu)
+	binary.LittleEndian.PutUint32(m[len(pdu):], counter)
+
+	mac := smp.AESCMAC(reverse(csrk), reverse(m))
+
+	var sig [12]byte
+	binary.LittleEndian.PutUint32(sig[:], counter)
+	copy(sig[4:], reverse(mac[:8]))
+	return sig
+}
+
+// verifyPDU checks the Authentication Signature at the end of a signed PDU,
+// and returns its sign counter.
+func verifyPDU(csrk []byte, b []byte) (uint32, bool) {
+	if len(b) < 12 {
+		return 0, false
+	}
+	pdu, sig := b[:len(b)-12], b[len(b)-12:]
+	counter := binary.LittleEndian.Uint32(sig)
+	want := signPDU(csrk, counter, pdu)
+	return counter, subtle.ConstantTimeCompare(want[:], sig) == 1
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
+
+// nextSignCounter returns the counter for the next outgoing signed write to
+// peer, and persists its successor before the counter is used.
+func nextSignCounter(s ble.BondStore, peer ble.Addr) (uint32, error) {
+	var counter uint32
+	switch b, err := s.Load(peer, ble.BondLocalSignCounter); {
+	case err == ble.ErrNotFound:
+	case err != nil:
+		return 0, err
+	case len(b) == 4:
+		counter = binary.LittleEndian.Uint32(b)
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, counter+1)
+	return counter, s.Save(peer, ble.BondLocalSignCounter, b)
+}
@@ -0,0 +1,17 @@
+package att
+
+import "testing"
+
+func TestSignPDU(t *testing.T) {
+	csrk := []byte{0: 0x01, 15: 0xff}
+	b := []byte{SignedWriteCommandCode, 0x03, 0x00, 'h', 'i'}
+	sig := signPDU(csrk, 7, b)
+	b = append(b, sig[:]...)
+	if n, ok := verifyPDU(csrk, b); !ok || n != 7 {
+		t.Fatalf("verifyPDU: got %d, %v", n, ok)
+	}
+	b[3] ^= 0x01
+	if _, ok := verifyPDU(csrk, b); ok {
+		t.Errorf("verifyPDU accepted a tampered PDU")
+	}
+}
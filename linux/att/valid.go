@@ -0,0 +1,69 @@
+package att
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMalformedPDU is returned by Validate, for the PDUs too short for their
+// fields.
+var ErrMalformedPDU = errors.New("malformed PDU")
+
+// minLen is the length of the PDUs which the clients receive, up to their
+// last fixed field.
+var minLen = map[byte]int{
+	ErrorResponseCode:           5,
+	ExchangeMTUResponseCode:     3,
+	FindInformationResponseCode: 2,
+	FindByTypeValueResponseCode: 1,
+	ReadByTypeResponseCode:      2,
+	ReadResponseCode:            1,
+	ReadBlobResponseCode:        1,
+	ReadMultipleResponseCode:    1,
+	ReadByGroupTypeResponseCode: 2,
+	WriteResponseCode:           1,
+	PrepareWriteResponseCode:    5,
+	ExecuteWriteResponseCode:    1,
+	HandleValueNotificationCode: 3,
+	HandleValueIndicationCode:   3,
+}
+
+// Validate returns an error wrapping ErrMalformedPDU, if the PDU b is empty,
+// or is a response, a notification or an indication too short for its
+// fields, or whose lists aren't of whole entries. The requests and commands
+// are left to the server, which responds to the malformed ones with Invalid
+// PDU. [Vol 3, Part F, 3.4.1.1]
+func Validate(b []byte) error {
+	if len(b) == 0 {
+		return fmt.Errorf("%w: empty", ErrMalformedPDU)
+	}
+	if n, ok := minLen[b[0]]; ok && len(b) < n {
+		return malformedPDU(b)
+	}
+	switch b[0] {
+	case FindInformationResponseCode:
+		// Pairs of a handle and a 16-bit, or 128-bit, UUID.
+		n := map[byte]int{0x01: 4, 0x02: 18}[b[1]]
+		if n == 0 || (len(b)-2)%n != 0 {
+			return malformedPDU(b)
+		}
+	case FindByTypeValueResponseCode:
+		if (len(b)-1)%4 != 0 {
+			return malformedPDU(b)
+		}
+	case ReadByTypeResponseCode, ReadByGroupTypeResponseCode:
+		// Entries of the length b[1], of the handles and the value.
+		hdr := 2
+		if b[0] == ReadByGroupTypeResponseCode {
+			hdr = 4
+		}
+		if n := int(b[1]); n < hdr || (len(b)-2)%n != 0 {
+			return malformedPDU(b)
+		}
+	}
+	return nil
+}
+
+func malformedPDU(b []byte) error {
+	return fmt.Errorf("%w: [% X]", ErrMalformedPDU, b)
+}
@@ -0,0 +1,207 @@
+//go:build linux
+// +build linux
+
+// Package bredr runs ATT over BR/EDR, on the L2CAP channel of the fixed PSM
+// of ATT, for the dual-mode peripherals which expose their services there.
+// [Vol 3, Part F, 3.2.11]
+//
+// The channel is opened with the L2CAP sockets of the kernel, so the adapter
+// it's opened from is to be up, and not bound to the HCI User Channel, which
+// the hci package opens the devices with.
+package bredr
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/gatt"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// PSMATT is the PSM of ATT over BR/EDR. [Assigned Numbers, 2.2]
+const PSMATT uint16 = 0x001F
+
+// Socket options of the L2CAP sockets, from <bluetooth/l2cap.h>.
+const (
+	solL2CAP     = 6
+	l2capOptions = 0x01
+)
+
+// l2capOpts is the struct l2cap_options of the kernel.
+type l2capOpts struct {
+	OMTU     uint16
+	IMTU     uint16
+	FlushTo  uint16
+	Mode     uint8
+	FCS      uint8
+	MaxTx    uint8
+	_        uint8
+	TxWindow uint16
+}
+
+// pollInterval is how often Dial checks ctx while connecting.
+const pollInterval = 100 * time.Millisecond
+
+// Dial opens the ATT channel over BR/EDR to the peer of the public address a,
+// from any adapter of the kernel, and returns a GATT client of it. The ATT_MTU
+// is that of the L2CAP configuration, so the client is not to exchange it.
+func Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
+	c, err := dial(ctx, a)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't dial over BR/EDR")
+	}
+	return gatt.NewClient(c)
+}
+
+func dial(ctx context.Context, a ble.Addr) (*conn, error) {
+	mac, err := net.ParseMAC(a.String())
+	if err != nil || len(mac) != 6 {
+		return nil, errors.Errorf("invalid address %s", a)
+	}
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, unix.BTPROTO_L2CAP)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create L2CAP socket")
+	}
+	// The ATT client reads PDUs of up to ble.MaxMTU bytes, so the channel is
+	// configured not to take more.
+	opts := l2capOpts{}
+	err = sockopt(unix.SYS_GETSOCKOPT, fd, &opts)
+	if err == nil {
+		opts.IMTU = ble.MaxMTU
+		err = sockopt(unix.SYS_SETSOCKOPT, fd, &opts)
+	}
+	if err == nil {
+		err = connect(ctx, fd, mac)
+	}
+	if err == nil {
+		err = sockopt(unix.SYS_GETSOCKOPT, fd, &opts)
+	}
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	c := &conn{
+		fd:     fd,
+		ctx:    ctx,
+		remote: net.HardwareAddr(mac),
+		rxMTU:  int(opts.IMTU),
+		txMTU:  int(opts.OMTU),
+		chDone: make(chan struct{}),
+	}
+	if sa, err := unix.Getsockname(fd); err == nil {
+		if l2, ok := sa.(*unix.SockaddrL2); ok {
+			b := l2.Addr
+			c.local = net.HardwareAddr{b[5], b[4], b[3], b[2], b[1], b[0]}
+		}
+	}
+	return c, nil
+}
+
+// sockopt gets, or sets, the L2CAP options of fd.
+func sockopt(trap uintptr, fd int, opts *l2capOpts) error {
+	l := uint32(unsafe.Sizeof(*opts))
+	var p uintptr
+	if trap == unix.SYS_GETSOCKOPT {
+		p = uintptr(unsafe.Pointer(&l))
+	} else {
+		p = uintptr(l)
+	}
+	_, _, e := unix.Syscall6(trap, uintptr(fd), solL2CAP, l2capOptions, uintptr(unsafe.Pointer(opts)), p, 0)
+	if e != 0 {
+		return errors.Wrap(e, "can't access L2CAP options")
+	}
+	return nil
+}
+
+// connect connects fd to the ATT channel of the peer, until ctx is done.
+func connect(ctx context.Context, fd int, mac net.HardwareAddr) error {
+	if err := unix.SetNonblock(fd, true); err != nil {
+		return err
+	}
+	sa := &unix.SockaddrL2{PSM: PSMATT}
+	copy(sa.Addr[:], mac)
+	if err := unix.Connect(fd, sa); err != nil && err != unix.EINPROGRESS {
+		return errors.Wrap(err, "can't connect")
+	}
+	for {
+		pfds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLOUT}}
+		n, err := unix.Poll(pfds, int(pollInterval/time.Millisecond))
+		if err != nil && err != unix.EINTR {
+			return err
+		}
+		if n > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+	if e, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR); err != nil || e != 0 {
+		if err == nil {
+			err = unix.Errno(e)
+		}
+		return errors.Wrap(err, "can't connect")
+	}
+	return unix.SetNonblock(fd, false)
+}
+
+// conn is the ATT channel over BR/EDR. Each read and write of the socket is
+// a single PDU.
+type conn struct {
+	fd  int
+	ctx context.Context
+
+	local  ble.Addr
+	remote ble.Addr
+
+	rxMTU int
+	txMTU int
+
+	closeOnce sync.Once
+	chDone    chan struct{}
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	n, err := unix.Read(c.fd, b)
+	if err != nil || n == 0 {
+		c.Close()
+		if err == nil {
+			err = errors.New("disconnected")
+		}
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c *conn) Write(b []byte) (int, error) {
+	return unix.Write(c.fd, b)
+}
+
+// Close closes the channel, which disconnects the link once it carries no
+// other channels.
+func (c *conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		unix.Shutdown(c.fd, unix.SHUT_RDWR)
+		err = unix.Close(c.fd)
+		close(c.chDone)
+	})
+	return err
+}
+
+func (c *conn) Context() context.Context       { return c.ctx }
+func (c *conn) SetContext(ctx context.Context) { c.ctx = ctx }
+func (c *conn) LocalAddr() ble.Addr            { return c.local }
+func (c *conn) RemoteAddr() ble.Addr           { return c.remote }
+func (c *conn) RxMTU() int                     { return c.rxMTU }
+func (c *conn) SetRxMTU(mtu int)               {}
+func (c *conn) TxMTU() int                     { return c.txMTU }
+func (c *conn) SetTxMTU(mtu int)               {}
+func (c *conn) Disconnected() <-chan struct{}  { return c.chDone }
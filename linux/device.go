@@ -1,11 +1,14 @@
 package linux
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"io"
-	"log"
+	"time"
 
 	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
 	"github.com/kirbo/ble/linux/att"
 	"github.com/kirbo/ble/linux/gatt"
 	"github.com/kirbo/ble/linux/hci"
@@ -25,11 +28,11 @@ func NewDeviceWithName(name string, opts ...ble.Option) (*Device, error) {
 func NewDeviceWithNameAndHandler(name string, handler ble.NotifyHandler, opts ...ble.Option) (*Device, error) {
 	dev, err := hci.NewHCI(opts...)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't create hci")
+		return nil, fmt.Errorf("can't create hci: %w", err)
 	}
 	if err = dev.Init(); err != nil {
 		dev.Close()
-		return nil, errors.Wrap(err, "can't init hci")
+		return nil, fmt.Errorf("can't init hci: %w", err)
 	}
 
 	srv, err := gatt.NewServerWithNameAndHandler(name, handler)
@@ -37,6 +40,9 @@ func NewDeviceWithNameAndHandler(name string, handler ble.NotifyHandler, opts ..
 		dev.Close()
 		return nil, errors.Wrap(err, "can't create server")
 	}
+	if p, ok := dev.PreferredConnParams(); ok {
+		srv.SetPreferredConnParams(p)
+	}
 
 	// mtu := ble.DefaultMTU
 	mtu := ble.MaxMTU // TODO: get this from user using Option.
@@ -45,7 +51,9 @@ func NewDeviceWithNameAndHandler(name string, handler ble.NotifyHandler, opts ..
 		return nil, errors.Wrapf(err, "maximum ATT_MTU is %d", ble.MaxMTU)
 	}
 
-	go loop(dev, srv, mtu)
+	if dev.Role().Peripheral() {
+		go loop(dev, srv, mtu)
+	}
 
 	return &Device{HCI: dev, Server: srv}, nil
 }
@@ -53,11 +61,14 @@ func NewDeviceWithNameAndHandler(name string, handler ble.NotifyHandler, opts ..
 func loop(dev *hci.HCI, s *gatt.Server, mtu int) {
 	for {
 		l2c, err := dev.Accept()
+		if dev.WaitReopened(err) {
+			continue
+		}
 		if err != nil {
 			// An EOF error indicates that the HCI socket was closed during
 			// the read.  Don't report this as an error.
 			if err != io.EOF {
-				log.Printf("can't accept: %s", err)
+				ble.SubsystemLogger{Logger: dev.Logger(), Subsystem: ble.LogConn}.Error("can't accept", "err", err)
 			}
 			return
 		}
@@ -70,11 +81,34 @@ func loop(dev *hci.HCI, s *gatt.Server, mtu int) {
 		as, err := att.NewServer(s.DB(), l2c)
 		s.Unlock()
 		if err != nil {
-			log.Printf("can't create ATT server: %s", err)
+			ble.SubsystemLogger{Logger: dev.Logger(), Subsystem: ble.LogATT}.Error("can't create ATT server", "addr", l2c.RemoteAddr(), "err", err)
 			continue
-
 		}
+		as.SetIndicationPolicy(dev.IndicationPolicy())
+		as.SetAuthorizer(dev.Authorizer())
+		as.SetAuditHook(dev.AuditHook())
+		as.SetLimits(dev.ServerLimits())
 		go as.Loop()
+		if c, ok := l2c.(*hci.Conn); ok {
+			go serveEATT(c, as)
+		}
+	}
+}
+
+// serveEATT serves the EATT bearers the remote device opens on c.
+func serveEATT(c *hci.Conn, as *att.Server) {
+	for {
+		l2c, err := c.AcceptEATT()
+		if err != nil {
+			return
+		}
+		bs, err := as.NewBearer(l2c)
+		if err != nil {
+			ble.SubsystemLogger{Logger: ble.LoggerFromContext(c.Context()), Subsystem: ble.LogATT}.Error("can't create ATT server for EATT bearer", "addr", c.RemoteAddr(), "err", err)
+			l2c.Close()
+			continue
+		}
+		go bs.Loop()
 	}
 }
 
@@ -100,11 +134,34 @@ func (d *Device) SetServices(svcs []*ble.Service) error {
 	return d.Server.SetServices(svcs)
 }
 
+// GATTDump returns the attribute table of the GATT server.
+func (d *Device) GATTDump() ([]ble.Attribute, error) {
+	return d.Server.GATTDump(), nil
+}
+
 // Stop stops gatt server.
 func (d *Device) Stop() error {
 	return d.HCI.Close()
 }
 
+// defaultShutdownTimeout bounds the shutdown of Close.
+const defaultShutdownTimeout = 5 * time.Second
+
+// Close shuts the device down in order, as Shutdown does, within 5 seconds.
+func (d *Device) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+	defer cancel()
+	return d.Shutdown(ctx)
+}
+
+// Shutdown shuts the device down in order, rather than closing the socket
+// abruptly, as Stop does, leaving the controller idle: scanning and
+// advertising stopped, the pending connection canceled, the links
+// disconnected, and the commands completed. See hci.Shutdown.
+func (d *Device) Shutdown(ctx context.Context) error {
+	return d.HCI.Shutdown(ctx)
+}
+
 func (d *Device) Advertise(ctx context.Context, adv ble.Advertisement) error {
 	if err := d.HCI.AdvertiseAdv(adv); err != nil {
 		return err
@@ -127,6 +184,13 @@ func (d *Device) AdvertiseNameAndServices(ctx context.Context, name string, uuid
 	return ctx.Err()
 }
 
+// AdvertiseDirected advertises connectable to the peer only, such as a bonded
+// central to reconnect quickly, and returns its connection. See
+// hci.AdvertiseDirected.
+func (d *Device) AdvertiseDirected(ctx context.Context, peer ble.Addr, highDuty bool) (ble.Conn, error) {
+	return d.HCI.AdvertiseDirected(ctx, peer, highDuty)
+}
+
 // AdvertiseMfgData avertises the given manufacturer data.
 func (d *Device) AdvertiseMfgData(ctx context.Context, id uint16, b []byte) error {
 	if err := d.HCI.AdvertiseMfgData(id, b); err != nil {
@@ -167,8 +231,92 @@ func (d *Device) AdvertiseIBeacon(ctx context.Context, u ble.UUID, major, minor
 	return ctx.Err()
 }
 
+// ControllerInfo returns what the controller supports. See
+// hci.ControllerInfo.
+func (d *Device) ControllerInfo() ble.ControllerInfo {
+	return d.HCI.ControllerInfo()
+}
+
+// UpdateAdvertisement replaces the advertising data ad, and the scan response
+// sr, unless it's nil, of the advertising in progress, without stopping it.
+// See hci.UpdateAdvertisement.
+func (d *Device) UpdateAdvertisement(ad, sr []byte) error {
+	return d.HCI.UpdateAdvertisement(ad, sr)
+}
+
+// AdvertiseTemplate advertises the packets crafted from the templates ad and
+// sr, which may be nil for no scan response. The templates are evaluated
+// again every refresh, and the data which changed are set while advertising,
+// advertising data before scan response, without restarting advertising.
+func (d *Device) AdvertiseTemplate(ctx context.Context, ad, sr *adv.Template, refresh time.Duration) error {
+	if refresh <= 0 {
+		return errors.New("invalid refresh period")
+	}
+	var lastAD, lastSR []byte
+	update := func() error {
+		b, err := packetBytes(ad)
+		if err != nil {
+			return errors.Wrap(err, "can't craft advertising data")
+		}
+		s, err := packetBytes(sr)
+		if err != nil {
+			return errors.Wrap(err, "can't craft scan response")
+		}
+		if lastAD == nil || !bytes.Equal(b, lastAD) {
+			if err := d.HCI.SetAdvertisingData(b); err != nil {
+				return errors.Wrap(err, "can't set advertising data")
+			}
+			lastAD = b
+		}
+		if lastSR == nil || !bytes.Equal(s, lastSR) {
+			if err := d.HCI.SetScanResponseData(s); err != nil {
+				return errors.Wrap(err, "can't set scan response")
+			}
+			lastSR = s
+		}
+		return nil
+	}
+
+	if err := update(); err != nil {
+		return err
+	}
+	if err := d.HCI.Advertise(); err != nil {
+		return err
+	}
+	defer d.HCI.StopAdvertising()
+
+	t := d.HCI.Clock().NewTicker(refresh)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C():
+			if err := update(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// packetBytes evaluates t, and returns the bytes of the packet. A nil t is an
+// empty packet.
+func packetBytes(t *adv.Template) ([]byte, error) {
+	if t == nil {
+		return []byte{}, nil
+	}
+	p, err := t.Packet()
+	if err != nil {
+		return nil, err
+	}
+	return p.Bytes(), nil
+}
+
 // Scan starts scanning. Duplicated advertisements will be filtered out if allowDup is set to false.
 func (d *Device) Scan(ctx context.Context, allowDup bool, h ble.AdvHandler) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if err := d.HCI.SetAdvHandler(h); err != nil {
 		return err
 	}
@@ -185,7 +333,45 @@ func (d *Device) Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
 	// d.HCI.Dial is a blocking call, although most of time it should return immediately.
 	// But in case passing wrong device address or the device went non-connectable, it blocks.
 	cln, err := d.HCI.Dial(ctx, a)
-	return cln, errors.Wrap(err, "can't dial")
+	if err != nil {
+		return nil, fmt.Errorf("can't dial: %w", err)
+	}
+	return cln, nil
+}
+
+// DisconnectAll terminates every connection, and waits until they are
+// disconnected, or ctx is done.
+func (d *Device) DisconnectAll(ctx context.Context) error {
+	return errors.Wrap(d.HCI.DisconnectAll(ctx), "can't disconnect all")
+}
+
+// Reset terminates every connection, stops advertising and scanning, and
+// resets the controller with an HCI Reset.
+func (d *Device) Reset(ctx context.Context) error {
+	return errors.Wrap(d.HCI.Reset(ctx), "can't reset")
+}
+
+// SetPowerProfile switches the duty cycles of scanning and advertising to
+// those of the power profile, while scanning and advertising.
+func (d *Device) SetPowerProfile(p ble.PowerProfile) error {
+	return errors.Wrap(d.HCI.SetPowerProfile(p), "can't set power profile")
+}
+
+// SetHostChannelClassification classifies the data channels, which m doesn't
+// have, as bad, so that the controller leaves them out of the channel maps of
+// the connections.
+func (d *Device) SetHostChannelClassification(m ble.ChannelMap) error {
+	return errors.Wrap(d.HCI.SetHostChannelClassification(m), "can't set host channel classification")
+}
+
+// Stats samples the counters of the adapter, with their rates since the
+// previous sample. See hci.Stats.
+func (d *Device) Stats() (ble.AdapterStats, error) {
+	s, err := d.HCI.Stats()
+	if err != nil {
+		return s, fmt.Errorf("can't read stats: %w", err)
+	}
+	return s, nil
 }
 
 // Address returns the listener's device address.
@@ -0,0 +1,178 @@
+package gatt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/kirbo/ble"
+)
+
+// cachedProfile is the form in which a discovered profile is kept in the
+// BondStore, along with the Database Hash of the server, if it has one.
+type cachedProfile struct {
+	Hash     []byte
+	Services []cachedService
+}
+
+type cachedService struct {
+	UUID            ble.UUID
+	Handle          uint16
+	EndHandle       uint16
+	Characteristics []cachedCharacteristic
+}
+
+type cachedCharacteristic struct {
+	UUID        ble.UUID
+	Property    ble.Property
+	Handle      uint16
+	ValueHandle uint16
+	EndHandle   uint16
+	Descriptors []cachedDescriptor
+}
+
+type cachedDescriptor struct {
+	UUID   ble.UUID
+	Handle uint16
+}
+
+func encodeProfile(prof *ble.Profile, hash []byte) ([]byte, error) {
+	cp := cachedProfile{Hash: hash}
+	for _, s := range prof.Services {
+		cs := cachedService{UUID: s.UUID, Handle: s.Handle, EndHandle: s.EndHandle}
+		for _, c := range s.Characteristics {
+			cc := cachedCharacteristic{
+				UUID:        c.UUID,
+				Property:    c.Property,
+				Handle:      c.Handle,
+				ValueHandle: c.ValueHandle,
+				EndHandle:   c.EndHandle,
+			}
+			for _, d := range c.Descriptors {
+				cc.Descriptors = append(cc.Descriptors, cachedDescriptor{UUID: d.UUID, Handle: d.Handle})
+			}
+			cs.Characteristics = append(cs.Characteristics, cc)
+		}
+		cp.Services = append(cp.Services, cs)
+	}
+	return json.Marshal(cp)
+}
+
+func decodeProfile(b []byte) (*ble.Profile, []byte, error) {
+	var cp cachedProfile
+	if err := json.Unmarshal(b, &cp); err != nil {
+		return nil, nil, err
+	}
+	prof := &ble.Profile{}
+	for _, cs := range cp.Services {
+		s := &ble.Service{UUID: cs.UUID, Handle: cs.Handle, EndHandle: cs.EndHandle}
+		for _, cc := range cs.Characteristics {
+			c := &ble.Characteristic{
+				UUID:        cc.UUID,
+				Property:    cc.Property,
+				Handle:      cc.Handle,
+				ValueHandle: cc.ValueHandle,
+				EndHandle:   cc.EndHandle,
+			}
+			for _, cd := range cc.Descriptors {
+				d := &ble.Descriptor{UUID: cd.UUID, Handle: cd.Handle}
+				c.Descriptors = append(c.Descriptors, d)
+				if d.UUID.Equal(ble.ClientCharacteristicConfigUUID) {
+					c.CCCD = d
+				}
+			}
+			s.Characteristics = append(s.Characteristics, c)
+		}
+		prof.Services = append(prof.Services, s)
+	}
+	return prof, cp.Hash, nil
+}
+
+// cacheStore returns the BondStore caching the profile of the server, if
+// the cache is enabled.
+func (p *Client) cacheStore() ble.BondStore {
+	s, _ := ble.GATTCacheFromContext(p.conn.Context())
+	return s
+}
+
+// readDatabaseHash reads the Database Hash of the server, which changes
+// whenever its GATT database does. It returns nil if the server doesn't
+// have one. [Vol 3, Part G, 7.3]
+func (p *Client) readDatabaseHash() ([]byte, error) {
+	length, b, err := p.bound(p.ac).ReadByType(0x0001, 0xFFFF, ble.DatabaseHashUUID)
+	if errors.Is(err, ble.ErrAttrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), b[2:length]...), nil
+}
+
+// loadCachedProfile returns the cached profile of the server, if any, and if
+// the Database Hash of the server still matches.
+func (p *Client) loadCachedProfile() *ble.Profile {
+	s := p.cacheStore()
+	if s == nil {
+		return nil
+	}
+	b, err := s.Load(p.conn.RemoteAddr(), ble.BondGATTCache)
+	if err != nil || len(b) == 0 {
+		return nil
+	}
+	prof, hash, err := decodeProfile(b)
+	if err != nil {
+		return nil
+	}
+	cur, err := p.readDatabaseHash()
+	if err != nil || !bytes.Equal(cur, hash) {
+		return nil
+	}
+	return prof
+}
+
+// saveCachedProfile caches a discovered profile, if the cache is enabled.
+func (p *Client) saveCachedProfile(prof *ble.Profile) error {
+	s := p.cacheStore()
+	if s == nil {
+		return nil
+	}
+	hash, err := p.readDatabaseHash()
+	if err != nil {
+		return err
+	}
+	b, err := encodeProfile(prof, hash)
+	if err != nil {
+		return err
+	}
+	return s.Save(p.conn.RemoteAddr(), ble.BondGATTCache, b)
+}
+
+// watchServiceChanged subscribes to the Service Changed indications of the
+// server, which invalidate the cached profile. [Vol 3, Part G, 7.1]
+func (p *Client) watchServiceChanged(prof *ble.Profile) error {
+	if p.cacheStore() == nil {
+		return nil
+	}
+	for _, c := range prof.FindCharacteristics(ble.ServiceChangedUUID) {
+		if c.CCCD == nil {
+			continue
+		}
+		if err := p.Subscribe(c, true, p.invalidateCache); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invalidateCache drops the cached and the discovered profile, so that the
+// next DiscoverProfile discovers the server again. The BondStore can only
+// delete all the entries of a peer, so the cache entry is emptied instead.
+func (p *Client) invalidateCache(req []byte) {
+	if s := p.cacheStore(); s != nil {
+		s.Save(p.conn.RemoteAddr(), ble.BondGATTCache, nil)
+	}
+	p.Lock()
+	p.profile = nil
+	p.Unlock()
+}
@@ -1,9 +1,12 @@
 package gatt
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"log"
+	"sort"
 	"sync"
 
 	"github.com/kirbo/ble"
@@ -18,24 +21,72 @@ const (
 // NewClient returns a GATT Client.
 func NewClient(conn ble.Conn) (*Client, error) {
 	p := &Client{
-		subs: make(map[uint16]*sub),
-		conn: conn,
+		client: &client{
+			subs:    make(map[uint16]*sub),
+			conn:    conn,
+			bearers: make(chan *bearer, 1+maxEATTBearers),
+		},
+		ctx: context.Background(),
 	}
 	p.ac = att.NewClient(conn, p)
+	p.ac.SetSeq(&p.seq)
 	go p.ac.Loop()
+	p.bearers <- &bearer{ac: p.ac, l2c: conn}
 	return p, nil
 }
 
 // A Client is a GATT Client.
 type Client struct {
+	*client
+
+	// ctx bounds the operations of the Client, as set by WithContext.
+	ctx context.Context
+}
+
+// client is the state of a Client, shared by its copies of WithContext.
+type client struct {
 	sync.RWMutex
 
 	profile *ble.Profile
 	name    string
-	subs    map[uint16]*sub
+
+	// subMu serializes the changes of the subscriptions, along with the
+	// writes of their CCCDs. subsMu guards subs, and is never held across
+	// a request, as the notifications received before its response are
+	// handled first, and take it too.
+	subMu  sync.Mutex
+	subsMu sync.Mutex
+	subs   map[uint16]*sub
 
 	ac   *att.Client
 	conn ble.Conn
+
+	// Reads and writes take an idle bearer from the pool, so they can be
+	// in flight concurrently once EATT is enabled.
+	bearers  chan *bearer
+	nBearers int32 // Accessed atomically.
+
+	// seq numbers the PDUs received on all the bearers, accessed
+	// atomically.
+	seq uint64
+}
+
+// WithContext returns a copy of p, sharing its connection, profile and
+// subscriptions, whose operations give up once ctx is done, returning its
+// error: the waits for an idle bearer, and for the responses, and the
+// backoffs of the retries. The requests abandoned are still responded to in
+// the background, before the bearer sends another. It bounds the writes of
+// the CCCD of Subscribe, but not the subscriptions. The copy is a *Client.
+func (p *Client) WithContext(ctx context.Context) ble.Client {
+	return &Client{client: p.client, ctx: ctx}
+}
+
+// bound returns ac, whose requests give up once the ctx of p is done.
+func (p *Client) bound(ac *att.Client) *att.Client {
+	if p.ctx.Done() == nil {
+		return ac
+	}
+	return ac.WithContext(p.ctx)
 }
 
 // Addr returns the address of the client.
@@ -60,10 +111,23 @@ func (p *Client) Profile() *ble.Profile {
 }
 
 // DiscoverProfile discovers the whole hierarchy of a server.
+// If the GATT cache is enabled, a cached profile is used unless force is set,
+// or the server's database has changed since.
 func (p *Client) DiscoverProfile(force bool) (*ble.Profile, error) {
-	if p.profile != nil && !force {
-		return p.profile, nil
+	if prof := p.Profile(); prof != nil && !force {
+		return prof, nil
 	}
+	if !force {
+		if prof := p.loadCachedProfile(); prof != nil {
+			p.Lock()
+			p.profile = prof
+			p.Unlock()
+			return prof, p.watchServiceChanged(prof)
+		}
+	}
+	p.Lock()
+	p.profile = nil
+	p.Unlock()
 	ss, err := p.DiscoverServices(nil)
 	if err != nil {
 		return nil, fmt.Errorf("can't discover services: %s", err)
@@ -80,22 +144,39 @@ func (p *Client) DiscoverProfile(force bool) (*ble.Profile, error) {
 			}
 		}
 	}
-	p.profile = &ble.Profile{Services: ss}
-	return p.profile, nil
+	prof := &ble.Profile{Services: ss}
+	p.Lock()
+	p.profile = prof
+	p.Unlock()
+	if err := p.saveCachedProfile(prof); err != nil {
+		return nil, fmt.Errorf("can't cache profile: %s", err)
+	}
+	return prof, p.watchServiceChanged(prof)
 }
 
 // DiscoverServices finds all the primary services on a server. [Vol 3, Part G, 4.4.1]
-// If filter is specified, only filtered services are returned.
+// If filter is specified, only filtered services are returned, which are found by
+// their UUIDs, unless the server doesn't support it. [Vol 3, Part G, 4.4.2]
 func (p *Client) DiscoverServices(filter []ble.UUID) ([]*ble.Service, error) {
 	p.Lock()
 	defer p.Unlock()
 	if p.profile == nil {
 		p.profile = &ble.Profile{}
 	}
+	if len(filter) != 0 {
+		ss, err := p.findServices(filter)
+		if err == nil {
+			p.profile.Services = append(p.profile.Services, ss...)
+			return p.profile.Services, nil
+		}
+		if !errors.Is(err, ble.ErrReqNotSupp) {
+			return nil, err
+		}
+	}
 	start := uint16(0x0001)
 	for {
-		length, b, err := p.ac.ReadByGroupType(start, 0xFFFF, ble.PrimaryServiceUUID)
-		if err == ble.ErrAttrNotFound {
+		length, b, err := p.bound(p.ac).ReadByGroupType(start, 0xFFFF, ble.PrimaryServiceUUID)
+		if errors.Is(err, ble.ErrAttrNotFound) {
 			return p.profile.Services, nil
 		}
 		if err != nil {
@@ -122,6 +203,39 @@ func (p *Client) DiscoverServices(filter []ble.UUID) ([]*ble.Service, error) {
 	}
 }
 
+// findServices finds the primary services of the UUIDs, in handle order, with
+// Find By Type Value. [Vol 3, Part G, 4.4.2]
+func (p *Client) findServices(uu []ble.UUID) ([]*ble.Service, error) {
+	var ss []*ble.Service
+	for _, u := range uu {
+		start := uint16(0x0001)
+		for {
+			b, err := p.bound(p.ac).FindByTypeValue(start, 0xFFFF, 0x2800, u)
+			if errors.Is(err, ble.ErrAttrNotFound) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			var endh uint16
+			for ; len(b) != 0; b = b[4:] {
+				endh = binary.LittleEndian.Uint16(b[2:4])
+				ss = append(ss, &ble.Service{
+					UUID:      u,
+					Handle:    binary.LittleEndian.Uint16(b[:2]),
+					EndHandle: endh,
+				})
+			}
+			if endh == 0xFFFF {
+				break
+			}
+			start = endh + 1
+		}
+	}
+	sort.Slice(ss, func(i, j int) bool { return ss[i].Handle < ss[j].Handle })
+	return ss, nil
+}
+
 // DiscoverIncludedServices finds the included services of a service. [Vol 3, Part G, 4.5.1]
 // If filter is specified, only filtered services are returned.
 func (p *Client) DiscoverIncludedServices(ss []ble.UUID, s *ble.Service) ([]*ble.Service, error) {
@@ -138,8 +252,8 @@ func (p *Client) DiscoverCharacteristics(filter []ble.UUID, s *ble.Service) ([]*
 	start := s.Handle
 	var lastChar *ble.Characteristic
 	for start <= s.EndHandle {
-		length, b, err := p.ac.ReadByType(start, s.EndHandle, ble.CharacteristicUUID)
-		if err == ble.ErrAttrNotFound {
+		length, b, err := p.bound(p.ac).ReadByType(start, s.EndHandle, ble.CharacteristicUUID)
+		if errors.Is(err, ble.ErrAttrNotFound) {
 			break
 		} else if err != nil {
 			return nil, err
@@ -177,8 +291,8 @@ func (p *Client) DiscoverDescriptors(filter []ble.UUID, c *ble.Characteristic) (
 	defer p.Unlock()
 	start := c.ValueHandle + 1
 	for start <= c.EndHandle {
-		fmt, b, err := p.ac.FindInformation(start, c.EndHandle)
-		if err == ble.ErrAttrNotFound {
+		fmt, b, err := p.bound(p.ac).FindInformation(start, c.EndHandle)
+		if errors.Is(err, ble.ErrAttrNotFound) {
 			break
 		} else if err != nil {
 			return nil, err
@@ -204,11 +318,26 @@ func (p *Client) DiscoverDescriptors(filter []ble.UUID, c *ble.Characteristic) (
 	return c.Descriptors, nil
 }
 
+// Characteristics returns all the discovered characteristics with the UUID, in handle order.
+func (p *Client) Characteristics(u ble.UUID) []*ble.Characteristic {
+	p.RLock()
+	defer p.RUnlock()
+	if p.profile == nil {
+		return nil
+	}
+	return p.profile.FindCharacteristics(u)
+}
+
 // ReadCharacteristic reads a characteristic value from a server. [Vol 3, Part G, 4.8.1]
 func (p *Client) ReadCharacteristic(c *ble.Characteristic) ([]byte, error) {
-	p.Lock()
-	defer p.Unlock()
-	val, err := p.ac.Read(c.ValueHandle)
+	p.RLock()
+	defer p.RUnlock()
+	b, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(b)
+	val, err := b.ac.Read(c.ValueHandle)
 	if err != nil {
 		return nil, err
 	}
@@ -217,22 +346,111 @@ func (p *Client) ReadCharacteristic(c *ble.Characteristic) ([]byte, error) {
 	return val, nil
 }
 
+// ReadCharacteristicSeq is like ReadCharacteristic, but also returns the
+// sequence number of the response, which orders the value read among the
+// Notifications of SubscribeSeq.
+func (p *Client) ReadCharacteristicSeq(c *ble.Characteristic) ([]byte, uint64, error) {
+	p.RLock()
+	defer p.RUnlock()
+	b, err := p.acquire()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer p.release(b)
+	val, err := b.ac.Read(c.ValueHandle)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.Value = val
+	return val, b.ac.RspSeq(), nil
+}
+
+// ReadCharacteristicByUUID reads the value of the first characteristic with UUID chr,
+// within the first service with UUID svc, or anywhere if svc is nil, without
+// discovering them. The service is looked up in the discovered profile, or else
+// found by its UUID. [Vol 3, Part G, 4.8.2]
+func (p *Client) ReadCharacteristicByUUID(svc, chr ble.UUID) ([]byte, error) {
+	p.RLock()
+	defer p.RUnlock()
+	b, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(b)
+
+	start, end := uint16(0x0001), uint16(0xFFFF)
+	if svc != nil {
+		s := p.discoveredService(svc)
+		if s == nil {
+			hl, err := b.ac.FindByTypeValue(start, end, 0x2800, svc)
+			if err != nil {
+				return nil, err
+			}
+			s = &ble.Service{Handle: binary.LittleEndian.Uint16(hl[:2]), EndHandle: binary.LittleEndian.Uint16(hl[2:4])}
+		}
+		start, end = s.Handle, s.EndHandle
+	}
+	length, data, err := b.ac.ReadByType(start, end, chr)
+	if err != nil {
+		return nil, err
+	}
+	h := binary.LittleEndian.Uint16(data[:2])
+	val := append([]byte(nil), data[2:length]...)
+
+	// The value may be truncated to the MTU, and is read on with Read Blob. [Vol 3, Part G, 4.8.2]
+	if len(val) < b.l2c.TxMTU()-4 {
+		return val, nil
+	}
+	for {
+		read, err := b.ac.ReadBlob(h, uint16(len(val)))
+		if errors.Is(err, ble.ErrAttrNotLong) || errors.Is(err, ble.ErrInvalidOffset) {
+			return val, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		val = append(val, read...)
+		if len(read) < b.l2c.TxMTU()-1 {
+			return val, nil
+		}
+	}
+}
+
+// discoveredService returns the first discovered service with UUID u, or nil.
+// Must be called with p locked.
+func (p *Client) discoveredService(u ble.UUID) *ble.Service {
+	if p.profile == nil {
+		return nil
+	}
+	for _, s := range p.profile.Services {
+		if s.UUID.Equal(u) {
+			return s
+		}
+	}
+	return nil
+}
+
 // ReadLongCharacteristic reads a characteristic value which is longer than the MTU. [Vol 3, Part G, 4.8.3]
 func (p *Client) ReadLongCharacteristic(c *ble.Characteristic) ([]byte, error) {
-	p.Lock()
-	defer p.Unlock()
+	p.RLock()
+	defer p.RUnlock()
+	b, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(b)
 
 	// The maximum length of an attribute value shall be 512 octects [Vol 3, 3.2.9]
 	buffer := make([]byte, 0, 512)
 
-	read, err := p.ac.Read(c.ValueHandle)
+	read, err := b.ac.Read(c.ValueHandle)
 	if err != nil {
 		return nil, err
 	}
 	buffer = append(buffer, read...)
 
-	for len(read) >= p.conn.TxMTU()-1 {
-		if read, err = p.ac.ReadBlob(c.ValueHandle, uint16(len(buffer))); err != nil {
+	for len(read) >= b.l2c.TxMTU()-1 {
+		if read, err = b.ac.ReadBlob(c.ValueHandle, uint16(len(buffer))); err != nil {
 			return nil, err
 		}
 		buffer = append(buffer, read...)
@@ -244,19 +462,91 @@ func (p *Client) ReadLongCharacteristic(c *ble.Characteristic) ([]byte, error) {
 
 // WriteCharacteristic writes a characteristic value to a server. [Vol 3, Part G, 4.9.3]
 func (p *Client) WriteCharacteristic(c *ble.Characteristic, v []byte, noRsp bool) error {
-	p.Lock()
-	defer p.Unlock()
+	p.RLock()
+	defer p.RUnlock()
 	if noRsp {
+		// Not waiting for a bearer, as the commands don't wait for the
+		// requests outstanding.
 		return p.ac.WriteCommand(c.ValueHandle, v)
 	}
-	return p.ac.Write(c.ValueHandle, v)
+	b, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.release(b)
+	return b.ac.Write(c.ValueHandle, v)
 }
 
-// ReadDescriptor reads a characteristic descriptor from a server. [Vol 3, Part G, 4.12.1]
-func (p *Client) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
+// Flush waits until the controller has sent the writes without response
+// queued, or ctx is done.
+func (p *Client) Flush(ctx context.Context) error {
+	p.RLock()
+	c := p.conn
+	p.RUnlock()
+	if f, ok := c.(interface{ Flush(context.Context) error }); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
+// WriteLongCharacteristic writes a characteristic value which is longer than the
+// MTU, using queued writes. If reliable is set, each prepared part echoed by the
+// server is verified, and the write is cancelled on mismatch. [Vol 3, Part G, 4.9.4 & 4.9.5]
+func (p *Client) WriteLongCharacteristic(c *ble.Characteristic, v []byte, reliable bool) error {
+	p.RLock()
+	defer p.RUnlock()
+	// The prepare queue is per bearer, so all the parts go on the same one.
+	br, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.release(br)
+	n := br.l2c.TxMTU() - 5
+	for off := 0; off < len(v); off += n {
+		end := off + n
+		if end > len(v) {
+			end = len(v)
+		}
+		h, o, b, err := br.ac.PrepareWrite(c.ValueHandle, uint16(off), v[off:end])
+		if err != nil {
+			br.ac.ExecuteWrite(0)
+			return err
+		}
+		if reliable && (h != c.ValueHandle || int(o) != off || !bytes.Equal(b, v[off:end])) {
+			br.ac.ExecuteWrite(0)
+			return fmt.Errorf("reliable write: prepared value mismatch at offset %d", off)
+		}
+	}
+	return br.ac.ExecuteWrite(1)
+}
+
+// SignedWriteCharacteristic writes a characteristic value to a bonded server
+// without response, signed with the local CSRK. This is linux specific.
+// [Vol 3, Part G, 4.9.2]
+func (p *Client) SignedWriteCharacteristic(c *ble.Characteristic, v []byte) error {
 	p.Lock()
 	defer p.Unlock()
-	val, err := p.ac.Read(d.Handle)
+	return p.ac.WriteSigned(c.ValueHandle, v)
+}
+
+// RawATT sends an ATT PDU the library doesn't otherwise model, and returns the
+// response if the opcode is a request.
+func (p *Client) RawATT(ctx context.Context, pdu []byte) ([]byte, error) {
+	p.Lock()
+	defer p.Unlock()
+	return p.ac.RawATT(ctx, pdu)
+}
+
+// ReadDescriptor reads a characteristic descriptor from a server. [Vol 3, Part G, 4.12.1]
+func (p *Client) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
+	p.RLock()
+	defer p.RUnlock()
+	b, err := p.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(b)
+	val, err := b.ac.Read(d.Handle)
 	if err != nil {
 		return nil, err
 	}
@@ -267,92 +557,269 @@ func (p *Client) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
 
 // WriteDescriptor writes a characteristic descriptor to a server. [Vol 3, Part G, 4.12.3]
 func (p *Client) WriteDescriptor(d *ble.Descriptor, v []byte) error {
-	p.Lock()
-	defer p.Unlock()
-	return p.ac.Write(d.Handle, v)
+	p.RLock()
+	defer p.RUnlock()
+	b, err := p.acquire()
+	if err != nil {
+		return err
+	}
+	defer p.release(b)
+	if d.UUID.Equal(ble.ClientCharacteristicConfigUUID) {
+		return b.ac.WriteIdempotent(d.Handle, v)
+	}
+	return b.ac.Write(d.Handle, v)
 }
 
 // ReadRSSI retrieves the current RSSI value of remote peripheral. [Vol 2, Part E, 7.5.4]
 func (p *Client) ReadRSSI() int {
-	p.Lock()
-	defer p.Unlock()
-	// TODO:
+	if c, ok := p.conn.(interface{ ReadRSSI() (int, error) }); ok {
+		if rssi, err := c.ReadRSSI(); err == nil {
+			return rssi
+		}
+	}
 	return 0
 }
 
+// Security returns the security of the link, or SecurityUnknown if the
+// connection doesn't tell.
+func (p *Client) Security() ble.Security {
+	if c, ok := p.conn.(interface{ Security() ble.Security }); ok {
+		return c.Security()
+	}
+	return ble.Security{}
+}
+
+// Encrypted reports whether the link is encrypted.
+func (p *Client) Encrypted() bool {
+	return p.Security().Encrypted()
+}
+
+// SecurityLevel returns the security level of the link.
+func (p *Client) SecurityLevel() ble.SecurityLevel {
+	return p.Security().Level
+}
+
+// RemoteInfo returns the version and the features of the remote controller, as
+// far as they have been read on connect.
+func (p *Client) RemoteInfo() ble.RemoteInfo {
+	if c, ok := p.conn.(interface{ RemoteInfo() ble.RemoteInfo }); ok {
+		return c.RemoteInfo()
+	}
+	return ble.RemoteInfo{}
+}
+
 // ExchangeMTU informs the server of the client’s maximum receive MTU size and
 // request the server to respond with its maximum receive MTU size. [Vol 3, Part F, 3.4.2.1]
 func (p *Client) ExchangeMTU(mtu int) (int, error) {
 	p.Lock()
 	defer p.Unlock()
-	return p.ac.ExchangeMTU(mtu)
+	return p.bound(p.ac).ExchangeMTU(mtu)
 }
 
 // Subscribe subscribes to indication (if ind is set true), or notification of a
 // characteristic value. [Vol 3, Part G, 4.10 & 4.11]
+// It may be called again to add consumers of the same characteristic, which
+// get every value, each through its own queue. The CCCD is written for the
+// first consumer only. The consumers, which unsubscribe on their own, are
+// added with SubscribeConsumer.
 func (p *Client) Subscribe(c *ble.Characteristic, ind bool, h ble.NotificationHandler) error {
-	p.Lock()
-	defer p.Unlock()
+	return p.SubscribeWithQueue(c, ind, h, DefaultQueueConfig)
+}
+
+// SubscribeWithQueue is like Subscribe, but configures the size and overflow
+// policy of the queue the notifications are delivered through.
+func (p *Client) SubscribeWithQueue(c *ble.Characteristic, ind bool, h ble.NotificationHandler, cfg QueueConfig) error {
+	_, err := p.SubscribeConsumer(c, ind, h, cfg)
+	return err
+}
+
+// SubscribeConsumer is like SubscribeWithQueue, but returns a function, which
+// removes the consumer it added only, and clears the CCCD, if it was the
+// last one, so that the consumers of a characteristic come and go
+// independently.
+func (p *Client) SubscribeConsumer(c *ble.Characteristic, ind bool, h ble.NotificationHandler, cfg QueueConfig) (func() error, error) {
+	return p.subscribe(c, ind, func() *queue {
+		return newQueue(h, cfg, p.conn.Disconnected())
+	})
+}
+
+// SubscribeSeq is like SubscribeWithQueue, but passes the Notifications,
+// with their sequence numbers, to h.
+func (p *Client) SubscribeSeq(c *ble.Characteristic, ind bool, h func(n Notification), cfg QueueConfig) error {
+	_, err := p.subscribe(c, ind, func() *queue {
+		return newSeqQueue(h, cfg, p.conn.Disconnected())
+	})
+	return err
+}
+
+// subscribe adds the queue returned by newq to the consumers of c, and
+// returns the function removing it.
+func (p *Client) subscribe(c *ble.Characteristic, ind bool, newq func() *queue) (func() error, error) {
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
 	if c.CCCD == nil {
-		return fmt.Errorf("CCCD not found")
+		return nil, fmt.Errorf("CCCD not found")
+	}
+	flag := uint16(cccNotify)
+	if ind {
+		flag = cccIndicate
+	}
+	q, err := p.addQueue(c.CCCD.Handle, c.ValueHandle, flag, newq)
+	if err != nil {
+		return nil, err
 	}
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			p.subMu.Lock()
+			defer p.subMu.Unlock()
+			err = p.removeQueues(c.ValueHandle, flag, q)
+		})
+		return err
+	}, nil
+}
+
+// SubscriptionStats returns the counters of the subscription to indication
+// (if ind is set true), or notification of a characteristic value.
+func (p *Client) SubscriptionStats(c *ble.Characteristic, ind bool) (SubscriptionStats, error) {
+	p.subsMu.Lock()
+	defer p.subsMu.Unlock()
+	s, ok := p.subs[c.ValueHandle]
+	if !ok {
+		return SubscriptionStats{}, fmt.Errorf("not subscribed")
+	}
+	qs := s.nQueues
 	if ind {
-		return p.setHandlers(c.CCCD.Handle, c.ValueHandle, cccIndicate, h)
+		qs = s.iQueues
+	}
+	if len(qs) == 0 {
+		return SubscriptionStats{}, fmt.Errorf("not subscribed")
 	}
-	return p.setHandlers(c.CCCD.Handle, c.ValueHandle, cccNotify, h)
+	// Consumers added later have received fewer values.
+	var st SubscriptionStats
+	for _, q := range qs {
+		qst := q.stats()
+		if qst.Received > st.Received {
+			st.Received = qst.Received
+		}
+		st.Delivered += qst.Delivered
+		st.Dropped += qst.Dropped
+		st.Queued += qst.Queued
+	}
+	return st, nil
 }
 
 // Unsubscribe unsubscribes to indication (if ind is set true), or notification
 // of a specified characteristic value. [Vol 3, Part G, 4.10 & 4.11]
+// It removes every consumer, and clears the CCCD. A consumer added with
+// SubscribeConsumer is removed alone with the function it returned.
 func (p *Client) Unsubscribe(c *ble.Characteristic, ind bool) error {
-	p.Lock()
-	defer p.Unlock()
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
 	if c.CCCD == nil {
 		return fmt.Errorf("CCCD not found")
 	}
 	if ind {
-		return p.setHandlers(c.CCCD.Handle, c.ValueHandle, cccIndicate, nil)
+		return p.removeQueues(c.ValueHandle, cccIndicate, nil)
 	}
-	return p.setHandlers(c.CCCD.Handle, c.ValueHandle, cccNotify, nil)
+	return p.removeQueues(c.ValueHandle, cccNotify, nil)
 }
 
-func (p *Client) setHandlers(cccdh, vh, flag uint16, h ble.NotificationHandler) error {
+// addQueue adds the queue returned by newq to the consumers of the
+// characteristic, and returns it. The CCCD is written for the first one,
+// once the queue is in place, so that the notifications received before the
+// response are queued to it. Must be called with p.subMu held.
+func (p *Client) addQueue(cccdh, vh, flag uint16, newq func() *queue) (*queue, error) {
+	p.subsMu.Lock()
 	s, ok := p.subs[vh]
 	if !ok {
-		s = &sub{cccdh, 0x0000, nil, nil}
+		s = &sub{cccdh: cccdh}
 		p.subs[vh] = s
 	}
-	switch {
-	case h == nil && (s.ccc&flag) == 0:
+	qs := s.queues(flag)
+	q := newq()
+	// The slices are replaced rather than modified, as HandleNotification
+	// reads them after releasing the lock.
+	*qs = append((*qs)[:len(*qs):len(*qs)], q)
+	first := len(*qs) == 1
+	if first {
+		s.ccc |= flag
+	}
+	ccc := s.ccc
+	p.subsMu.Unlock()
+	if !first {
+		return q, nil
+	}
+
+	if err := p.bound(p.ac).WriteIdempotent(cccdh, cccValue(ccc)); err != nil {
+		p.subsMu.Lock()
+		q.closeIfSet()
+		*qs = nil
+		s.ccc &^= flag
+		p.subsMu.Unlock()
+		return nil, err
+	}
+	return q, nil
+}
+
+// removeQueues removes the consumer q of the characteristic, or every one,
+// if q is nil, and clears the CCCD once none is left. Must be called with
+// p.subMu held.
+func (p *Client) removeQueues(vh, flag uint16, q *queue) error {
+	p.subsMu.Lock()
+	s, ok := p.subs[vh]
+	if !ok {
+		p.subsMu.Unlock()
 		return nil
-	case h != nil && (s.ccc&flag) != 0:
+	}
+	qs := s.queues(flag)
+	var left []*queue
+	removed := false
+	for _, x := range *qs {
+		if q == nil || x == q {
+			x.closeIfSet()
+			removed = true
+			continue
+		}
+		left = append(left, x)
+	}
+	if !removed || len(left) > 0 {
+		*qs = left
+		p.subsMu.Unlock()
 		return nil
-	case h == nil && (s.ccc&flag) != 0:
-		s.ccc &= ^uint16(flag)
-	case h != nil && (s.ccc&flag) == 0:
-		s.ccc |= flag
 	}
+	*qs = nil
+	s.ccc &^= flag
+	ccc := s.ccc
+	p.subsMu.Unlock()
+	return p.bound(p.ac).WriteIdempotent(s.cccdh, cccValue(ccc))
+}
 
+// cccValue returns the value of a CCCD.
+func cccValue(ccc uint16) []byte {
 	v := make([]byte, 2)
-	binary.LittleEndian.PutUint16(v, s.ccc)
-	if flag == cccNotify {
-		s.nHandler = h
-	} else {
-		s.iHandler = h
-	}
-	return p.ac.Write(s.cccdh, v)
+	binary.LittleEndian.PutUint16(v, ccc)
+	return v
 }
 
 // ClearSubscriptions clears all subscriptions to notifications and indications.
 func (p *Client) ClearSubscriptions() error {
-	p.Lock()
-	defer p.Unlock()
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+	p.subsMu.Lock()
+	subs := p.subs
+	p.subs = make(map[uint16]*sub)
+	p.subsMu.Unlock()
+
 	zero := make([]byte, 2)
-	for vh, s := range p.subs {
-		if err := p.ac.Write(s.cccdh, zero); err != nil {
+	for _, s := range subs {
+		for _, q := range append(s.nQueues, s.iQueues...) {
+			q.closeIfSet()
+		}
+		if err := p.bound(p.ac).WriteIdempotent(s.cccdh, zero); err != nil {
 			return err
 		}
-		delete(p.subs, vh)
 	}
 	return nil
 }
@@ -377,28 +844,51 @@ func (p *Client) Conn() ble.Conn {
 }
 
 // HandleNotification ...
+// The notification is queued on its subscription; the handler is called from
+// the subscription's own goroutine.
 func (p *Client) HandleNotification(req []byte) {
-	p.Lock()
-	defer p.Unlock()
+	p.HandleSeqNotification(req, 0)
+}
+
+// HandleSeqNotification is HandleNotification, of the notification numbered
+// seq among the PDUs received.
+func (p *Client) HandleSeqNotification(req []byte, seq uint64) {
+	p.subsMu.Lock()
 	vh := att.HandleValueIndication(req).AttributeHandle()
 	sub, ok := p.subs[vh]
 	if !ok {
+		p.subsMu.Unlock()
 		// FIXME: disconnects and propagate an error to the user.
-		log.Printf("Got an unregistered notification")
+		ble.SubsystemLogger{Logger: ble.LoggerFromContext(p.conn.Context()), Subsystem: ble.LogGATT}.Warn("unregistered notification", "handle", fmt.Sprintf("0x%04X", vh))
 		return
 	}
-	fn := sub.nHandler
+	qs := sub.nQueues
 	if req[0] == att.HandleValueIndicationCode {
-		fn = sub.iHandler
+		qs = sub.iQueues
 	}
-	if fn != nil {
-		fn(req[3:])
+	p.subsMu.Unlock()
+	for i, q := range qs {
+		v := req[3:]
+		if i > 0 {
+			// Each consumer gets its own copy, which it may keep or modify.
+			v = append([]byte(nil), v...)
+		}
+		q.push(v, seq)
 	}
 }
 
 type sub struct {
-	cccdh    uint16
-	ccc      uint16
-	nHandler ble.NotificationHandler
-	iHandler ble.NotificationHandler
+	cccdh   uint16
+	ccc     uint16
+	nQueues []*queue // Consumers of notifications, in the order they subscribed.
+	iQueues []*queue // Consumers of indications, in the order they subscribed.
+}
+
+// queues returns the consumers of the notifications, or the indications, of
+// flag.
+func (s *sub) queues(flag uint16) *[]*queue {
+	if flag == cccIndicate {
+		return &s.iQueues
+	}
+	return &s.nQueues
 }
@@ -0,0 +1,281 @@
+package gatt_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+	"github.com/kirbo/ble/linux/att"
+	"github.com/kirbo/ble/linux/gatt"
+)
+
+func TestTransactionTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stall := make(chan struct{})
+	defer close(stall)
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		<-stall
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	clock := ble.NewFakeClock(time.Unix(0, 0))
+	cln := f.Connect(ble.WithClock(ctx, clock))
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := cln.ReadCharacteristic(rc)
+		errc <- err
+	}()
+
+	// The 30s ATT transaction timeout expires at once.
+	clock.BlockUntil(1)
+	clock.Advance(29 * time.Second)
+	select {
+	case err := <-errc:
+		t.Fatalf("read returned %v before the transaction timeout", err)
+	default:
+	}
+	clock.Advance(time.Second)
+	select {
+	case err := <-errc:
+		if !errors.Is(err, att.ErrSeqProtoTimeout) {
+			t.Errorf("read failed with %v, want a transaction timeout", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("read didn't time out")
+	}
+}
+
+func TestSubscribeConsumers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The notifier runs while the CCCD is set, and sends each value of
+	// send once the consumers are in place.
+	send := make(chan string)
+	stopped := make(chan struct{})
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		defer close(stopped)
+		for {
+			select {
+			case v := <-send:
+				n.Write([]byte(v))
+			case <-n.Context().Done():
+				return
+			}
+		}
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	nc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+	gc := cln.(*gatt.Client)
+	got1, got2 := make(chan []byte, 1), make(chan []byte, 1)
+	unsub1, err := gc.SubscribeConsumer(nc, false, func(b []byte) { got1 <- b }, gatt.DefaultQueueConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsub2, err := gc.SubscribeConsumer(nc, false, func(b []byte) { got2 <- b }, gatt.DefaultQueueConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The notifier is served in the background, once the CCCD is written.
+	select {
+	case send <- "tick":
+	case <-ctx.Done():
+		t.Fatal("notifier not started")
+	}
+	for _, got := range []chan []byte{got1, got2} {
+		select {
+		case b := <-got:
+			if string(b) != "tick" {
+				t.Fatalf("notified %q, want %q", b, "tick")
+			}
+		case <-ctx.Done():
+			t.Fatal("notification not received by every consumer")
+		}
+	}
+
+	// The first consumer leaves, and the second one keeps receiving.
+	if err := unsub1(); err != nil {
+		t.Fatal(err)
+	}
+	send <- "tock"
+	select {
+	case b := <-got2:
+		if string(b) != "tock" {
+			t.Fatalf("notified %q, want %q", b, "tock")
+		}
+	case <-stopped:
+		t.Fatal("CCCD cleared while a consumer is left")
+	case <-ctx.Done():
+		t.Fatal("notification not received by the consumer left")
+	}
+	select {
+	case b := <-got1:
+		t.Fatalf("notified %q to the consumer removed", b)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := unsub2(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		t.Fatal("CCCD not cleared after the last consumer")
+	}
+}
+
+func TestReadCharacteristicByUUID(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Two services of the same characteristic, of distinct values.
+	other := ble.NewService(ble.BatteryUUID)
+	other.NewCharacteristic(gatttest.ReadCharUUID).SetValue([]byte("other"))
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).SetValue(bytes.Repeat([]byte("long"), 20))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, other, svc)
+	defer f.Stop()
+
+	cln := f.Dial(ctx)
+	v, err := cln.ReadCharacteristicByUUID(gatttest.ServiceUUID, gatttest.ReadCharUUID)
+	if err != nil || !bytes.Equal(v, bytes.Repeat([]byte("long"), 20)) {
+		t.Errorf("ReadCharacteristicByUUID(svc) = %q, %v, want the long value", v, err)
+	}
+	if v, err := cln.ReadCharacteristicByUUID(nil, gatttest.ReadCharUUID); err != nil || string(v) != "other" {
+		t.Errorf("ReadCharacteristicByUUID(nil) = %q, %v, want other", v, err)
+	}
+	if _, err := cln.ReadCharacteristicByUUID(gatttest.ServiceUUID, gatttest.NotifyCharUUID); !errors.Is(err, ble.ErrAttrNotFound) {
+		t.Errorf("ReadCharacteristicByUUID(missing) = %v, want ErrAttrNotFound", err)
+	} else if e := (*ble.ErrATT)(nil); !errors.As(err, &e) || e.Opcode != att.ReadByTypeRequestCode {
+		t.Errorf("ReadCharacteristicByUUID(missing) = %v, want the ErrATT of a Read By Type Request", err)
+	}
+
+	ss, err := cln.DiscoverServices([]ble.UUID{gatttest.ServiceUUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ss) != 1 || !ss[0].UUID.Equal(gatttest.ServiceUUID) || ss[0].EndHandle != 0xFFFF {
+		t.Errorf("DiscoverServices(filter) = %v, want %s up to the last handle", ss, gatttest.ServiceUUID)
+	}
+
+	cln.CancelConnection()
+	<-cln.Disconnected()
+	if _, err := cln.ReadCharacteristicByUUID(gatttest.ServiceUUID, gatttest.ReadCharUUID); !errors.Is(err, ble.ErrDisconnected) {
+		t.Errorf("ReadCharacteristicByUUID(disconnected) = %v, want ErrDisconnected", err)
+	}
+}
+
+func TestWriteCommandPipelined(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	release := make(chan struct{})
+	written := make(chan []byte, 1)
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		<-release
+		rsp.Write([]byte("hello"))
+	}))
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		written <- append([]byte(nil), req.Data()...)
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	wc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := cln.ReadCharacteristic(rc)
+		read <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// The write without response doesn't wait for the read outstanding.
+	done := make(chan error, 1)
+	go func() { done <- cln.WriteCharacteristic(wc, []byte("go"), true) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("write without response waited for the read")
+	}
+	close(release)
+	if err := <-read; err != nil {
+		t.Fatal(err)
+	}
+	if err := cln.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-written:
+		if string(b) != "go" {
+			t.Fatalf("written %q, want %q", b, "go")
+		}
+	case <-ctx.Done():
+		t.Fatal("write not received")
+	}
+}
+
+func TestNotificationSeq(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sent := make(chan struct{})
+	svc := ble.NewService(gatttest.ServiceUUID)
+	ch := svc.NewCharacteristic(gatttest.NotifyCharUUID)
+	ch.HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write([]byte("read"))
+	}))
+	ch.HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		for i := 0; i < 3; i++ {
+			n.Write([]byte{byte(i)})
+		}
+		close(sent)
+		<-n.Context().Done()
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	nc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+	gc := cln.(*gatt.Client)
+	got := make(chan gatt.Notification, 3)
+	if err := gc.SubscribeSeq(nc, false, func(n gatt.Notification) { got <- n }, gatt.DefaultQueueConfig); err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+	_, rseq, err := gc.ReadCharacteristicSeq(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Queued before the read returned.
+	if st, _ := gc.SubscriptionStats(nc, false); st.Received != 3 {
+		t.Fatalf("%d notifications queued before the read returned, want 3", st.Received)
+	}
+	var last uint64
+	for i := 0; i < 3; i++ {
+		n := <-got
+		if n.Value[0] != byte(i) || n.Seq <= last || n.Seq >= rseq {
+			t.Errorf("notification %d: value %d, seq %d, after %d, and before the read %d", i, n.Value[0], n.Seq, last, rseq)
+		}
+		last = n.Seq
+	}
+}
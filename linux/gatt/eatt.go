@@ -0,0 +1,86 @@
+package gatt
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/att"
+)
+
+// ErrEATTNotSupported means the connection can't carry EATT bearers.
+var ErrEATTNotSupported = errors.New("EATT not supported by the connection")
+
+// maxEATTBearers is the number of EATT bearers a client opens at most.
+const maxEATTBearers = 5
+
+// eattDialer is implemented by connections which can open EATT bearers.
+type eattDialer interface {
+	DialEATT(n int) ([]ble.Conn, error)
+}
+
+// A bearer is an ATT bearer of the connection, and the ATT client running on it.
+type bearer struct {
+	ac  *att.Client
+	l2c ble.Conn
+
+	// idle is the bearer of the pool, which acquire bound to a context.
+	idle *bearer
+}
+
+// EnableEATT opens up to n Enhanced ATT bearers, in addition to the existing
+// one. Reads and writes are then spread over the idle bearers, so a slow
+// operation no longer holds up the others. The server must support EATT.
+// It returns the number of bearers opened. This is linux specific.
+// [Vol 3, Part G, 5.4]
+func (p *Client) EnableEATT(n int) (int, error) {
+	p.Lock()
+	defer p.Unlock()
+	d, ok := p.conn.(eattDialer)
+	if !ok {
+		return 0, ErrEATTNotSupported
+	}
+	if open := int(atomic.LoadInt32(&p.nBearers)); n > maxEATTBearers-open {
+		n = maxEATTBearers - open
+	}
+	if n <= 0 {
+		return 0, nil
+	}
+	l2cs, err := d.DialEATT(n)
+	if err != nil {
+		return 0, err
+	}
+	for _, l2c := range l2cs {
+		ac := att.NewClient(l2c, p)
+		ac.SetSeq(&p.seq)
+		go ac.Loop()
+		p.bearers <- &bearer{ac: ac, l2c: l2c}
+	}
+	atomic.AddInt32(&p.nBearers, int32(len(l2cs)))
+	return len(l2cs), nil
+}
+
+// acquire takes an idle bearer from the pool, unless the ctx of p is done
+// first. Its ATT client is bound to the ctx.
+func (p *Client) acquire() (*bearer, error) {
+	select {
+	case b := <-p.bearers:
+		return &bearer{ac: p.bound(b.ac), l2c: b.l2c, idle: b}, nil
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
+
+// release returns a bearer to the pool, unless its channel has been closed.
+func (p *Client) release(b *bearer) {
+	b = b.idle
+	if b.ac != p.ac {
+		select {
+		case <-b.l2c.Disconnected():
+			atomic.AddInt32(&p.nBearers, -1)
+			return
+		default:
+		}
+	}
+	p.bearers <- b
+}
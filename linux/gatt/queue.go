@@ -0,0 +1,170 @@
+package gatt
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/kirbo/ble"
+)
+
+// ErrQueueOverflow is passed to QueueConfig.OnOverflow when a notification
+// is dropped because the subscription's queue is full.
+var ErrQueueOverflow = errors.New("notification queue overflow")
+
+// OverflowPolicy decides what happens to a notification that arrives while
+// the subscription's queue is full.
+type OverflowPolicy int
+
+// OverflowPolicies.
+const (
+	// DropOldest discards the oldest queued notification to make room.
+	DropOldest OverflowPolicy = iota
+
+	// Block waits for room in the queue. This stalls the delivery of all
+	// notifications on the connection until the handler catches up, and of
+	// the responses received after them, so a handler shouldn't wait on
+	// requests meanwhile.
+	Block
+
+	// DropNewest discards the incoming notification and reports it to
+	// OnOverflow, if set.
+	DropNewest
+)
+
+// QueueConfig configures the queue of a subscription.
+type QueueConfig struct {
+	Size       int // Maximum number of queued notifications.
+	Policy     OverflowPolicy
+	OnOverflow func(data []byte, err error) // Called for each dropped notification, if set.
+}
+
+// DefaultQueueConfig is used by Subscribe.
+var DefaultQueueConfig = QueueConfig{Size: 16, Policy: DropOldest}
+
+// SubscriptionStats reports the counters of a subscription.
+type SubscriptionStats struct {
+	Received  uint64 // Notifications received from the server.
+	Delivered uint64 // Notifications passed to the handler.
+	Dropped   uint64 // Notifications discarded by the overflow policy.
+	Queued    int    // Notifications currently waiting for the handler.
+}
+
+// A Notification is a value notified, or indicated, and its sequence number
+// among the PDUs received from the server on all the bearers, which tells the
+// order it was received in, relative to the other Notifications and the
+// responses, such as of ReadCharacteristicSeq.
+type Notification struct {
+	Value []byte
+	Seq   uint64
+}
+
+// queue delivers notifications of a single subscription to its handler
+// on a dedicated goroutine, so a slow handler doesn't hold up the others.
+type queue struct {
+	// Accessed atomically; kept first for 64-bit alignment on 32-bit platforms.
+	received  uint64
+	delivered uint64
+	dropped   uint64
+
+	cfg  QueueConfig
+	h    ble.NotificationHandler
+	hs   func(n Notification) // Instead of h, if set.
+	ch   chan Notification
+	done chan struct{}
+	disc <-chan struct{}
+}
+
+func newQueue(h ble.NotificationHandler, cfg QueueConfig, disconnected <-chan struct{}) *queue {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultQueueConfig.Size
+	}
+	q := &queue{
+		cfg:  cfg,
+		h:    h,
+		ch:   make(chan Notification, cfg.Size),
+		done: make(chan struct{}),
+		disc: disconnected,
+	}
+	go q.loop()
+	return q
+}
+
+// newSeqQueue is newQueue, of a handler of the Notifications.
+func newSeqQueue(h func(n Notification), cfg QueueConfig, disconnected <-chan struct{}) *queue {
+	q := newQueue(nil, cfg, disconnected)
+	q.hs = h
+	return q
+}
+
+func (q *queue) loop() {
+	for {
+		select {
+		case n := <-q.ch:
+			if q.hs != nil {
+				q.hs(n)
+			} else {
+				q.h(n.Value)
+			}
+			atomic.AddUint64(&q.delivered, 1)
+		case <-q.done:
+			return
+		case <-q.disc:
+			return
+		}
+	}
+}
+
+func (q *queue) push(b []byte, seq uint64) {
+	atomic.AddUint64(&q.received, 1)
+	n := Notification{Value: b, Seq: seq}
+	switch q.cfg.Policy {
+	case Block:
+		select {
+		case q.ch <- n:
+		case <-q.done:
+		case <-q.disc:
+		}
+	case DropNewest:
+		select {
+		case q.ch <- n:
+		default:
+			q.drop(b)
+		}
+	default:
+		for {
+			select {
+			case q.ch <- n:
+				return
+			default:
+			}
+			select {
+			case old := <-q.ch:
+				q.drop(old.Value)
+			default:
+			}
+		}
+	}
+}
+
+func (q *queue) drop(b []byte) {
+	atomic.AddUint64(&q.dropped, 1)
+	if q.cfg.OnOverflow != nil {
+		q.cfg.OnOverflow(b, ErrQueueOverflow)
+	}
+}
+
+// closeIfSet stops the delivery goroutine of q, if q isn't nil.
+func (q *queue) closeIfSet() {
+	if q != nil {
+		close(q.done)
+	}
+}
+
+func (q *queue) stats() SubscriptionStats {
+	return SubscriptionStats{
+		Received:  atomic.LoadUint64(&q.received),
+		Delivered: atomic.LoadUint64(&q.delivered),
+		Dropped:   atomic.LoadUint64(&q.dropped),
+		Queued:    len(q.ch),
+	}
+}
@@ -0,0 +1,101 @@
+package gatt
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/att"
+)
+
+// A ReadByTypeIterator steps through the values of all the characteristics of
+// a type, in handle order, fetching them from the server a response at a time.
+// Values longer than ATT_MTU-4 bytes are truncated; use ReadLongCharacteristic
+// on the instance to get the rest.
+type ReadByTypeIterator struct {
+	p *Client
+	u ble.UUID
+
+	start  uint16
+	length int
+	data   []byte
+	done   bool
+
+	h   uint16
+	v   []byte
+	err error
+}
+
+// ReadByType returns an iterator over the values of the characteristics with
+// UUID u, which reads them using Read Using Characteristic UUID, without
+// requiring discovery first. [Vol 3, Part G, 4.8.2]
+func (p *Client) ReadByType(u ble.UUID) *ReadByTypeIterator {
+	return &ReadByTypeIterator{p: p, u: u, start: 0x0001}
+}
+
+// Next advances the iterator to the next value. It returns false when there
+// are no more values, or an error occurred.
+func (it *ReadByTypeIterator) Next() bool {
+	for len(it.data) == 0 {
+		if it.done {
+			return false
+		}
+		length, b, err := it.read()
+		switch {
+		case errors.Is(err, ble.ErrAttrNotFound):
+			it.done = true
+			return false
+		case err != nil:
+			it.err, it.done = err, true
+			return false
+		case length < 2:
+			it.err, it.done = att.ErrInvalidResponse, true
+			return false
+		}
+		it.length, it.data = length, b
+	}
+	it.h = binary.LittleEndian.Uint16(it.data)
+	it.v = it.data[2:it.length]
+	it.data = it.data[it.length:]
+	if it.h == 0xFFFF {
+		it.done = true
+	}
+	it.start = it.h + 1
+	return true
+}
+
+func (it *ReadByTypeIterator) read() (int, []byte, error) {
+	it.p.RLock()
+	defer it.p.RUnlock()
+	b, err := it.p.acquire()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer it.p.release(b)
+	return b.ac.ReadByType(it.start, 0xFFFF, it.u)
+}
+
+// Handle returns the value handle of the current characteristic.
+func (it *ReadByTypeIterator) Handle() uint16 { return it.h }
+
+// Value returns the value of the current characteristic.
+func (it *ReadByTypeIterator) Value() []byte { return it.v }
+
+// Characteristic returns the discovered characteristic of the current value,
+// or nil if it hasn't been discovered.
+func (it *ReadByTypeIterator) Characteristic() *ble.Characteristic {
+	it.p.RLock()
+	defer it.p.RUnlock()
+	if it.p.profile == nil {
+		return nil
+	}
+	for _, c := range it.p.profile.FindCharacteristics(it.u) {
+		if c.ValueHandle == it.h {
+			return c
+		}
+	}
+	return nil
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ReadByTypeIterator) Err() error { return it.err }
@@ -1,7 +1,6 @@
 package gatt
 
 import (
-	"log"
 	"sync"
 
 	"github.com/kirbo/ble"
@@ -32,12 +31,19 @@ type Server struct {
 	sync.Mutex
 	name string
 
+	// ppcp is the value of the Peripheral Preferred Connection Parameters
+	// characteristic, if set.
+	ppcp []byte
+
 	svcs []*ble.Service
 	db   *att.DB
 }
 
 // AddService ...
 func (s *Server) AddService(svc *ble.Service) error {
+	if err := svc.Validate(); err != nil {
+		return err
+	}
 	s.Lock()
 	defer s.Unlock()
 	s.svcs = append(s.svcs, svc)
@@ -50,24 +56,64 @@ func (s *Server) RemoveAllServices() error {
 	s.Lock()
 	defer s.Unlock()
 	s.svcs = defaultServices(s.name)
+	s.preferParams()
 	s.db = att.NewDB(s.svcs, uint16(1)) // ble attrs start at 1
 	return nil
 }
 
 // SetServices ...
 func (s *Server) SetServices(svcs []*ble.Service) error {
+	for _, svc := range svcs {
+		if err := svc.Validate(); err != nil {
+			return err
+		}
+	}
 	s.Lock()
 	defer s.Unlock()
 	s.svcs = append(defaultServices(s.name), svcs...)
+	s.preferParams()
 	s.db = att.NewDB(s.svcs, uint16(1)) // ble attrs start at 1
 	return nil
 }
 
+// SetPreferredConnParams sets the value of the Peripheral Preferred
+// Connection Parameters characteristic of the GAP service to p.
+func (s *Server) SetPreferredConnParams(p ble.PreferredConnParams) {
+	s.Lock()
+	defer s.Unlock()
+	s.ppcp = p.Marshal()
+	s.preferParams()
+	s.db = att.NewDB(s.svcs, uint16(1))
+}
+
+// preferParams sets the Peripheral Preferred Connection Parameters of the
+// default services, if set.
+func (s *Server) preferParams() {
+	if s.ppcp == nil {
+		return
+	}
+	for _, c := range s.svcs[0].Characteristics {
+		if c.UUID.Equal(ble.PeferredParamsUUID) {
+			c.SetValue(s.ppcp)
+		}
+	}
+}
+
+// GATTDump returns the attribute table of the server.
+func (s *Server) GATTDump() []ble.Attribute {
+	s.Lock()
+	defer s.Unlock()
+	return s.db.Attributes()
+}
+
 // DB ...
 func (s *Server) DB() *att.DB {
 	return s.db
 }
 
+// serverSupportsEATT is the EATT Supported bit of the Server Supported Features. [Vol 3, Part G, 7.4]
+const serverSupportsEATT = 0x01
+
 func defaultServices(name string) []*ble.Service {
 	return defaultServicesWithHandler(name, nil)
 }
@@ -89,16 +135,13 @@ func defaultServicesWithHandler(name string, handler ble.NotifyHandler) []*ble.S
 		indicationHandler = handler.ServeNotify
 	}
 	gattSvc.NewCharacteristic(ble.ServiceChangedUUID).HandleIndicate(indicationHandler)
+	gattSvc.NewCharacteristic(ble.ServerSupportedFeaturesUUID).SetValue([]byte{serverSupportsEATT})
 	return []*ble.Service{gapSvc, gattSvc}
 }
 
 func defaultHanderFunc(r ble.Request, n ble.Notifier) {
-	log.Printf("TODO: indicate client when the services are changed")
-	for {
-		select {
-		case <-n.Context().Done():
-			log.Printf("count: Notification unsubscribed")
-			return
-		}
-	}
+	logger := ble.SubsystemLogger{Logger: ble.LoggerFromContext(r.Conn().Context()), Subsystem: ble.LogGATT}
+	logger.Debug("service changed indications subscribed", "addr", r.Conn().RemoteAddr())
+	<-n.Context().Done()
+	logger.Debug("service changed indications unsubscribed", "addr", r.Conn().RemoteAddr())
 }
@@ -0,0 +1,115 @@
+package gatt
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/att"
+)
+
+// pipeConn is the ble.Conn of one end of a net.Pipe.
+type pipeConn struct {
+	net.Conn
+	done chan struct{}
+	once sync.Once
+}
+
+func newPipeConns() (*pipeConn, *pipeConn) {
+	a, b := net.Pipe()
+	return &pipeConn{Conn: a, done: make(chan struct{})}, &pipeConn{Conn: b, done: make(chan struct{})}
+}
+
+func (c *pipeConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
+
+func (c *pipeConn) Context() context.Context       { return context.Background() }
+func (c *pipeConn) SetContext(ctx context.Context) {}
+func (c *pipeConn) LocalAddr() ble.Addr            { return ble.NewAddr("66:55:44:33:22:11") }
+func (c *pipeConn) RemoteAddr() ble.Addr           { return ble.NewAddr("11:22:33:44:55:66") }
+func (c *pipeConn) RxMTU() int                     { return ble.DefaultMTU }
+func (c *pipeConn) SetRxMTU(mtu int)               {}
+func (c *pipeConn) TxMTU() int                     { return ble.DefaultMTU }
+func (c *pipeConn) SetTxMTU(mtu int)               {}
+func (c *pipeConn) Disconnected() <-chan struct{}  { return c.done }
+
+// notifyingChar returns a characteristic of the value handle vh, whose CCCD
+// follows it.
+func notifyingChar(vh uint16) *ble.Characteristic {
+	c := ble.NewCharacteristic(ble.UUID16(0x2a37))
+	c.Property = ble.CharNotify
+	c.ValueHandle = vh
+	c.CCCD = &ble.Descriptor{UUID: ble.ClientCharacteristicConfigUUID, Handle: vh + 1}
+	return c
+}
+
+func TestNotificationBeforeCCCDResponse(t *testing.T) {
+	cc, sc := newPipeConns()
+	defer sc.Close()
+	p, err := NewClient(cc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	// The server answers the writes of the CCCDs, and notifies the value of
+	// the first characteristic before answering the second write.
+	srv := make(chan error, 1)
+	go func() {
+		b := make([]byte, ble.DefaultMTU)
+		for i := 0; i < 2; i++ {
+			n, err := sc.Read(b)
+			if err != nil {
+				srv <- err
+				return
+			}
+			if n < 3 || b[0] != att.WriteRequestCode {
+				srv <- fmt.Errorf("received % X, want a Write Request", b[:n])
+				return
+			}
+			if i == 1 {
+				if _, err := sc.Write([]byte{att.HandleValueNotificationCode, 0x03, 0x00, 'x'}); err != nil {
+					srv <- err
+					return
+				}
+			}
+			if _, err := sc.Write([]byte{att.WriteResponseCode}); err != nil {
+				srv <- err
+				return
+			}
+		}
+		srv <- nil
+	}()
+
+	got := make(chan []byte, 1)
+	if err := p.Subscribe(notifyingChar(0x0003), false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- p.Subscribe(notifyingChar(0x0006), false, func([]byte) {}) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription blocked by the notification received before its response")
+	}
+	select {
+	case b := <-got:
+		if string(b) != "x" {
+			t.Errorf("notified %q, want %q", b, "x")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not delivered")
+	}
+	if err := <-srv; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package hci
 
 import (
 	"net"
+	"time"
 
 	"github.com/kirbo/ble"
 	"github.com/kirbo/ble/linux/adv"
@@ -13,6 +14,28 @@ type RandomAddress struct {
 	ble.Addr
 }
 
+// Type returns ble.AddrTypeRandom.
+func (a RandomAddress) Type() ble.AddrType { return ble.AddrTypeRandom }
+
+// PublicAddress is a Public Device Address.
+type PublicAddress struct {
+	ble.Addr
+}
+
+// Type returns ble.AddrTypePublic.
+func (a PublicAddress) Type() ble.AddrType { return ble.AddrTypePublic }
+
+// typedAddr returns the address of the HCI parameters b, in little endian,
+// of the Address_Type t, which tells the resolved identity addresses as 0x02
+// and 0x03.
+func typedAddr(b [6]byte, t uint8) ble.TypedAddr {
+	addr := net.HardwareAddr([]byte{b[5], b[4], b[3], b[2], b[1], b[0]})
+	if t == 0x01 || t == 0x03 {
+		return RandomAddress{addr}
+	}
+	return PublicAddress{addr}
+}
+
 // [Vol 6, Part B, 4.4.2] [Vol 3, Part C, 11]
 const (
 	evtTypAdvInd        = 0x00 // Connectable undirected advertising (ADV_IND).
@@ -22,8 +45,26 @@ const (
 	evtTypScanRsp       = 0x04 // Scan Response (SCAN_RSP).
 )
 
-func newAdvertisement(e evt.LEAdvertisingReport, i int) *Advertisement {
-	return &Advertisement{e: e, i: i}
+// Event Type bits of extended advertising reports. [Vol 2, Part E, 7.7.65.13]
+const (
+	extEvtConnectable = 0x0001
+	extEvtScannable   = 0x0002
+	extEvtDirected    = 0x0004
+	extEvtScanRsp     = 0x0008
+	extEvtLegacy      = 0x0010
+)
+
+// noSID is the Advertising SID of the reports of PDUs without an ADI.
+const noSID = 0xFF
+
+func newAdvertisement(e evt.LEAdvertisingReport, i int, t time.Time) *Advertisement {
+	return &Advertisement{e: e, i: i, t: t}
+}
+
+// newExtAdvertisement returns the advertisement of the extended report r,
+// whose data, reassembled from the fragments of the chain, is data.
+func newExtAdvertisement(r evt.ExtendedAdvertisingReport, data []byte, t time.Time) *Advertisement {
+	return &Advertisement{x: r, xdata: data, t: t}
 }
 
 // Advertisement implements ble.Advertisement and other functions that are only
@@ -31,8 +72,13 @@ func newAdvertisement(e evt.LEAdvertisingReport, i int) *Advertisement {
 type Advertisement struct {
 	e  evt.LEAdvertisingReport
 	i  int
+	t  time.Time
 	sr *Advertisement
 
+	// The last report, and the data, of an extended advertising report.
+	x     evt.ExtendedAdvertisingReport
+	xdata []byte
+
 	// cached packets.
 	p *adv.Packet
 }
@@ -87,6 +133,41 @@ func (a *Advertisement) SolicitedService() []ble.UUID {
 	return a.packets().ServiceSol()
 }
 
+// URI returns the URI of the advertisement, if its scheme is known.
+func (a *Advertisement) URI() string {
+	return a.packets().URI()
+}
+
+// Appearance returns the appearance of the advertisement, or 0, the unknown
+// appearance, if it has none.
+func (a *Advertisement) Appearance() uint16 {
+	v, _ := a.packets().Appearance()
+	return v
+}
+
+// AdvInterval returns the advertising interval of the advertisement, or 0 if
+// it has none.
+func (a *Advertisement) AdvInterval() time.Duration {
+	d, _ := a.packets().AdvInterval()
+	return d
+}
+
+// LERole returns the LE Role of the advertisement.
+func (a *Advertisement) LERole() ble.LERole {
+	return a.packets().LERole()
+}
+
+// PublicTargetAddrs returns the public target addresses of the advertisement.
+func (a *Advertisement) PublicTargetAddrs() []ble.Addr {
+	return a.packets().PublicTargetAddrs()
+}
+
+// Records returns the AD structures of the advertising data and the scan
+// response.
+func (a *Advertisement) Records() []ble.ADStructure {
+	return a.packets().Records()
+}
+
 // Connectable indicates weather the remote peripheral is connectable.
 func (a *Advertisement) Connectable() bool {
 	return a.EventType() == evtTypAdvDirectInd || a.EventType() == evtTypAdvInd
@@ -94,37 +175,110 @@ func (a *Advertisement) Connectable() bool {
 
 // RSSI returns RSSI signal strength.
 func (a *Advertisement) RSSI() int {
+	if a.x != nil {
+		return int(a.x.RSSI())
+	}
 	return int(a.e.RSSI(a.i))
 }
 
 // Addr returns the address of the remote peripheral.
 func (a *Advertisement) Addr() ble.Addr {
-	b := a.e.Address(a.i)
-	addr := net.HardwareAddr([]byte{b[5], b[4], b[3], b[2], b[1], b[0]})
-	if a.e.AddressType(a.i) == 1 {
-		return RandomAddress{addr}
+	var b [6]byte
+	if a.x != nil {
+		b = a.x.Address()
+	} else {
+		b = a.e.Address(a.i)
+	}
+	return typedAddr(b, a.AddressType())
+}
+
+// PublicIdentity returns the public address of the advertiser, which a
+// dual-mode device is known by over BR/EDR as well, or false if it advertises
+// with a random address, which the controller didn't resolve to a public
+// identity address.
+// This is linux sepcific.
+func (a *Advertisement) PublicIdentity() (ble.Addr, bool) {
+	if t := a.AddressType(); t != 0x00 && t != 0x02 {
+		return nil, false
 	}
-	return addr
+	return a.Addr(), true
 }
 
-// EventType returns the event type of Advertisement.
+// EventType returns the event type of Advertisement. The event types of the
+// extended reports are mapped to those of the legacy PDUs alike.
 // This is linux sepcific.
 func (a *Advertisement) EventType() uint8 {
-	return a.e.EventType(a.i)
+	if a.x == nil {
+		return a.e.EventType(a.i)
+	}
+	t := a.x.EventType()
+	switch {
+	case t&extEvtScanRsp != 0:
+		return evtTypScanRsp
+	case t&extEvtConnectable != 0 && t&extEvtDirected != 0:
+		return evtTypAdvDirectInd
+	case t&extEvtConnectable != 0:
+		return evtTypAdvInd
+	case t&extEvtScannable != 0:
+		return evtTypAdvScanInd
+	}
+	return evtTypAdvNonconnInd
 }
 
 // AddressType returns the address type of the Advertisement.
 // This is linux sepcific.
 func (a *Advertisement) AddressType() uint8 {
+	if a.x != nil {
+		return a.x.AddressType()
+	}
 	return a.e.AddressType(a.i)
 }
 
 // Data returns the advertising data of the packet.
 // This is linux sepcific.
 func (a *Advertisement) Data() []byte {
+	if a.x != nil {
+		return a.xdata
+	}
 	return a.e.Data(a.i)
 }
 
+// Extended reports whether the advertisement was received in extended
+// advertising PDUs, rather than legacy ones.
+// This is linux sepcific.
+func (a *Advertisement) Extended() bool {
+	return a.x != nil && a.x.EventType()&extEvtLegacy == 0
+}
+
+// SID returns the Advertising SID, which, along with the address, identifies
+// the advertising set of an extended advertisement, or -1 for the legacy
+// ones. The controllers don't report the Advertising Data ID of the ADI, but
+// reassemble the data of the chains it identifies.
+// This is linux sepcific.
+func (a *Advertisement) SID() int {
+	if a.x == nil || a.x.AdvertisingSID() == noSID {
+		return -1
+	}
+	return int(a.x.AdvertisingSID())
+}
+
+// PeriodicInterval returns the interval of the periodic advertising of the
+// advertising set, or 0 if it has none.
+// This is linux sepcific.
+func (a *Advertisement) PeriodicInterval() time.Duration {
+	if a.x == nil {
+		return 0
+	}
+	return time.Duration(a.x.PeriodicAdvertisingInterval()) * 1250 * time.Microsecond
+}
+
+// Truncated reports whether the controller couldn't receive the rest of the
+// data of the chain.
+// This is linux sepcific.
+func (a *Advertisement) Truncated() bool {
+	return a.x != nil && a.x.DataStatus() == evt.DataStatusTruncated
+}
+
 // ScanResponse returns the scan response of the packet, if it presents.
 // This is linux sepcific.
 func (a *Advertisement) ScanResponse() []byte {
@@ -133,3 +287,9 @@ func (a *Advertisement) ScanResponse() []byte {
 	}
 	return a.sr.Data()
 }
+
+// Timestamp returns the time the advertising report was received from the controller.
+// This is linux sepcific.
+func (a *Advertisement) Timestamp() time.Time {
+	return a.t
+}
@@ -0,0 +1,22 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble/internal/advtest"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+func TestAdvConformance(t *testing.T) {
+	for _, c := range advtest.Cases {
+		typ := byte(evtTypAdvNonconnInd)
+		if c.Connectable {
+			typ = evtTypAdvInd
+		}
+		d := c.Data()
+		b := []byte{evt.LEAdvertisingReportSubCode, 0x01, typ, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, byte(len(d))}
+		b = append(append(b, d...), byte(int8(c.RSSI)))
+		advtest.Check(t, c, newAdvertisement(evt.LEAdvertisingReport(b), 0, time.Time{}))
+	}
+}
@@ -0,0 +1,49 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestAdvRestartPolicy(t *testing.T) {
+	for _, restart := range []bool{true, false} {
+		recs := initRecords()
+		recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+		// A central connects, and disconnects. Advertising isn't re-enabled
+		// while it's connected.
+		e := []byte{pktTypeEvent, 0x3E, 19, 0x01, 0x00, 0x40, 0x00, roleSlave}
+		recs = append(recs, monitor.Record{Dir: monitor.Received, H4: append(e, make([]byte, 14)...)})
+		recs = append(recs, monitor.Record{Dir: monitor.Received, H4: []byte{pktTypeEvent, 0x05, 4, 0x00, 0x40, 0x00, 0x13}})
+		if restart {
+			recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+		}
+
+		s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+		h, err := NewHCI(ble.OptTransport(s), ble.OptAdvRestartOnDisconnect(restart), ble.OptAdvWhileConnected(false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Init(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Advertise(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 100 && s.Remaining() != 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		// Nor is advertising restarted late.
+		time.Sleep(50 * time.Millisecond)
+		if err := s.Err(); err != nil {
+			t.Errorf("restart %v: %v", restart, err)
+		}
+		if n := s.Remaining(); n != 0 {
+			t.Errorf("restart %v: %d records not played back", restart, n)
+		}
+		h.Close()
+	}
+}
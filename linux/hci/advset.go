@@ -0,0 +1,373 @@
+package hci
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+// AdvertisingSetParams are the parameters of an advertising set.
+type AdvertisingSetParams struct {
+	Connectable bool          // Accepts connections, which are returned by Accept.
+	Scannable   bool          // Sends the scan response to active scanners.
+	Legacy      bool          // Advertises with legacy PDUs, seen by any scanner, which carry up to 31 bytes.
+	Interval    time.Duration // Advertising interval, from 20ms; 100ms if 0.
+	Addr        ble.Addr      // Random static address of the set; the address of the device if nil.
+	Duration    time.Duration // The set stops after Duration, in 10ms steps up to 655.35s; 0 for no limit.
+	MaxEvents   int           // The set stops after MaxEvents advertising events, up to 255; 0 for no limit.
+}
+
+// Errors of the advertising set parameters.
+var (
+	errLegacyConnNonScan = errors.New("legacy connectable advertising must be scannable")
+	errAdvSetRemoved     = errors.New("advertising set removed")
+	errAdvSetParams      = errors.New("invalid advertising set parameters")
+)
+
+// properties returns the event properties of the set. [Vol 2, Part E, 7.8.53]
+func (p AdvertisingSetParams) properties() (uint16, error) {
+	var props uint16
+	if p.Connectable {
+		props |= advPropConnectable
+	}
+	if p.Scannable {
+		props |= advPropScannable
+	}
+	if p.Legacy {
+		if p.Connectable && !p.Scannable {
+			return 0, errLegacyConnNonScan
+		}
+		return props | advPropLegacy, nil
+	}
+	if p.Connectable && p.Scannable {
+		return 0, errExtConnScanResp
+	}
+	return props, nil
+}
+
+// An AdvertisingSet advertises along with the advertising of the device, and
+// with the other sets, with its own data, parameters and address, such as to
+// broadcast an iBeacon while advertising a connectable GATT server. The sets
+// need extended advertising, which the advertising of the device is switched
+// to, and keep advertising while dialing.
+type AdvertisingSet struct {
+	h      *HCI
+	handle uint8
+	p      AdvertisingSetParams
+	props  uint16
+
+	// mu serializes the commands of the set. state guards the flags, which
+	// the event loop changes, so it's never held while sending.
+	mu      sync.Mutex
+	state   sync.Mutex
+	enabled bool
+	removed bool
+}
+
+// NewAdvertisingSet creates an advertising set, which advertises once its data
+// are set, and it's started.
+func (h *HCI) NewAdvertisingSet(p AdvertisingSetParams) (*AdvertisingSet, error) {
+	if err := h.requirePeripheral("NewAdvertisingSet"); err != nil {
+		return nil, err
+	}
+	if !h.extAdvSupported() {
+		return nil, ErrExtAdvNotSupported
+	}
+	props, err := p.properties()
+	if err != nil {
+		return nil, err
+	}
+	var addr []byte
+	if p.Addr != nil {
+		b, err := parseAddr(p.Addr)
+		if err != nil {
+			return nil, err
+		}
+		if b[5]&0xC0 != 0xC0 {
+			return nil, errRandomStaticAddr
+		}
+		addr = b[:]
+	}
+	interval := p.Interval
+	if interval == 0 {
+		interval = 100 * time.Millisecond
+	}
+	if interval < 20*time.Millisecond || p.Duration < 0 || p.Duration > 65535*10*time.Millisecond || p.MaxEvents < 0 || p.MaxEvents > 255 {
+		return nil, errAdvSetParams
+	}
+
+	handle, err := h.allocAdvSet()
+	if err != nil {
+		return nil, err
+	}
+	s := &AdvertisingSet{h: h, handle: handle, p: p, props: props}
+
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	h.params.RLock()
+	ownAddrType := h.params.advParams.OwnAddressType
+	h.params.RUnlock()
+	if addr != nil {
+		ownAddrType = 0x01
+	} else if ownAddrType&0x01 != 0 {
+		addr = h.randomAddr
+	}
+	// In units of 0.625ms. [Vol 2, Part E, 7.8.53]
+	units := uint32(interval / (625 * time.Microsecond))
+	c := cmd.LESetExtendedAdvertisingParameters{
+		AdvertisingHandle:             handle,
+		AdvertisingEventProperties:    props,
+		PrimaryAdvertisingIntervalMin: [3]byte{uint8(units), uint8(units >> 8), uint8(units >> 16)},
+		PrimaryAdvertisingIntervalMax: [3]byte{uint8(units), uint8(units >> 8), uint8(units >> 16)},
+		PrimaryAdvertisingChannelMap:  0x07,
+		OwnAddressType:                ownAddrType,
+		AdvertisingTxPower:            0x7F, // No preference
+		PrimaryAdvertisingPHY:         0x01, // LE 1M
+		SecondaryAdvertisingPHY:       0x01, // LE 1M
+	}
+	if err := h.Send(&c, nil); err != nil {
+		h.freeAdvSet(handle)
+		return nil, err
+	}
+	if ownAddrType == 0x01 && addr != nil {
+		ra := cmd.LESetAdvertisingSetRandomAddress{AdvertisingHandle: handle}
+		copy(ra.RandomAddress[:], addr)
+		if err := h.Send(&ra, nil); err != nil {
+			h.Send(&cmd.LERemoveAdvertisingSet{AdvertisingHandle: handle}, nil)
+			h.freeAdvSet(handle)
+			return nil, err
+		}
+	}
+
+	h.advSetsMu.Lock()
+	h.advSets[handle] = s
+	h.advSetsMu.Unlock()
+	// The controller may reject the legacy advertising commands from now
+	// on. [Vol 4, Part E, 3.1.1]
+	h.advSetsUsed = true
+	return s, nil
+}
+
+// numAdvSets returns the number of advertising sets the controller supports,
+// which is read the first time.
+func (h *HCI) numAdvSets() (int, error) {
+	if h.maxAdvSets == 0 {
+		rp := cmd.LEReadNumberOfSupportedAdvertisingSetsRP{}
+		if err := h.Send(&cmd.LEReadNumberOfSupportedAdvertisingSets{}, &rp); err != nil {
+			return 0, err
+		}
+		h.maxAdvSets = int(rp.NumSupportedAdvertisingSets)
+	}
+	return h.maxAdvSets, nil
+}
+
+// allocAdvSet returns a free handle of an advertising set.
+func (h *HCI) allocAdvSet() (uint8, error) {
+	if _, err := h.numAdvSets(); err != nil {
+		return 0, err
+	}
+	h.advSetsMu.Lock()
+	defer h.advSetsMu.Unlock()
+	// The handles are from 0x00 to 0xEF, the first being extAdvHandle.
+	for i := 1; i < h.maxAdvSets && i <= 0xEF; i++ {
+		if _, ok := h.advSets[uint8(i)]; !ok {
+			// Reserved until the set is created, or freed.
+			h.advSets[uint8(i)] = nil
+			return uint8(i), nil
+		}
+	}
+	return 0, ErrNoAdvertisingSets
+}
+
+func (h *HCI) freeAdvSet(handle uint8) {
+	h.advSetsMu.Lock()
+	delete(h.advSets, handle)
+	h.advSetsMu.Unlock()
+}
+
+// resetAdvSets marks the advertising sets removed, as the controller is reset.
+func (h *HCI) resetAdvSets() {
+	h.advSetsMu.Lock()
+	defer h.advSetsMu.Unlock()
+	for _, s := range h.advSets {
+		if s != nil {
+			s.state.Lock()
+			s.enabled, s.removed = false, true
+			s.state.Unlock()
+		}
+	}
+	h.advSets = make(map[uint8]*AdvertisingSet)
+	h.maxAdvSets = 0
+	h.advSetsUsed = false
+}
+
+// Handle returns the handle of the set, which identifies it in the HCI
+// commands and events.
+func (s *AdvertisingSet) Handle() uint8 {
+	return s.handle
+}
+
+// SetData sets the advertising data, and the scan response of scannable sets.
+// Extended sets carry either of them, while legacy sets carry both, of up to
+// 31 bytes each. It may be set while advertising.
+func (s *AdvertisingSet) SetData(ad, sr []byte) error {
+	max := s.h.MaxAdvertisingDataLength()
+	if s.p.Legacy {
+		max = adv.MaxEIRPacketLength
+	}
+	switch {
+	case len(ad) > max || len(sr) > max:
+		return tooLong(ad, sr, max, nil)
+	case !s.p.Scannable && len(sr) > 0:
+		return errExtNonConnScanResp
+	case !s.p.Legacy && s.p.Scannable && len(ad) > 0:
+		return errExtScanAdvData
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	enabled, removed := s.flags()
+	if removed {
+		return errAdvSetRemoved
+	}
+	// Fragmented data may only be set while the set is disabled.
+	// [Vol 2, Part E, 7.8.54]
+	restart := enabled && (len(ad) > cmd.MaxExtAdvFragmentLength || len(sr) > cmd.MaxExtAdvFragmentLength)
+	if restart {
+		if err := s.h.Send(s.enableCmd(0), nil); err != nil {
+			return err
+		}
+	}
+	if !s.p.Scannable || s.p.Legacy {
+		if err := s.h.sendExtFragments(s.handle, ad, false); err != nil {
+			return err
+		}
+	}
+	if s.p.Scannable {
+		if err := s.h.sendExtFragments(s.handle, sr, true); err != nil {
+			return err
+		}
+	}
+	if restart {
+		return s.h.Send(s.enableCmd(1), nil)
+	}
+	return nil
+}
+
+// SetPacket sets the advertising data, and scan response, from packets, either
+// of which may be nil.
+func (s *AdvertisingSet) SetPacket(ad, sr *adv.Packet) error {
+	var a, r []byte
+	if ad != nil {
+		a = ad.Bytes()
+	}
+	if sr != nil {
+		r = sr.Bytes()
+	}
+	return s.SetData(a, r)
+}
+
+// Start starts advertising the set. It stops by itself after the duration,
+// or the number of events, of its parameters, or once connected.
+func (s *AdvertisingSet) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, removed := s.flags(); removed {
+		return errAdvSetRemoved
+	}
+	// Enabled before sending, as the set may terminate before Send returns.
+	s.setEnabled(true)
+	if err := s.h.Send(s.enableCmd(1), nil); err != nil {
+		s.setEnabled(false)
+		return err
+	}
+	return nil
+}
+
+// Stop stops advertising the set.
+func (s *AdvertisingSet) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled, removed := s.flags(); removed || !enabled {
+		return nil
+	}
+	if err := s.h.Send(s.enableCmd(0), nil); err != nil {
+		return err
+	}
+	s.setEnabled(false)
+	return nil
+}
+
+// Enabled reports whether the set is advertising.
+func (s *AdvertisingSet) Enabled() bool {
+	enabled, _ := s.flags()
+	return enabled
+}
+
+func (s *AdvertisingSet) flags() (enabled, removed bool) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	return s.enabled, s.removed
+}
+
+func (s *AdvertisingSet) setEnabled(enabled bool) {
+	s.state.Lock()
+	s.enabled = enabled
+	s.state.Unlock()
+}
+
+// Remove stops advertising the set, and removes it from the controller.
+func (s *AdvertisingSet) Remove() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, removed := s.flags(); removed {
+		return nil
+	}
+	if err := s.h.Send(&cmd.LERemoveAdvertisingSet{AdvertisingHandle: s.handle}, nil); err != nil {
+		return err
+	}
+	s.state.Lock()
+	s.removed = true
+	s.state.Unlock()
+	s.h.freeAdvSet(s.handle)
+	return nil
+}
+
+func (s *AdvertisingSet) enableCmd(enable uint8) Command {
+	c := &cmd.LESetExtendedAdvertisingEnable{Enable: enable, NumberOfSets: 1, AdvertisingHandle: s.handle}
+	if enable == 1 {
+		c.Duration = uint16(s.p.Duration / (10 * time.Millisecond))
+		c.MaxExtendedAdvertisingEvents = uint8(s.p.MaxEvents)
+	}
+	return c
+}
+
+// handleLEAdvertisingSetTerminated marks the set disabled, when it's
+// connected, or its duration or number of events have elapsed.
+func (h *HCI) handleLEAdvertisingSetTerminated(b []byte) error {
+	e := evt.LEAdvertisingSetTerminated(b)
+	if e.AdvertisingHandle() == extAdvHandle && h.extAdv {
+		reason := ble.StopConnected
+		if e.Status() != 0x00 {
+			reason = ble.StopTimeout
+		}
+		h.radioAdv(false, reason)
+	}
+	h.advSetsMu.Lock()
+	s := h.advSets[e.AdvertisingHandle()]
+	h.advSetsMu.Unlock()
+	if s == nil {
+		return nil
+	}
+	h.log(ble.LogHCI).Debug("advertising set terminated", "handle", e.AdvertisingHandle(), "status", e.Status(), "conn", e.ConnectionHandle())
+	s.setEnabled(false)
+	return nil
+}
@@ -0,0 +1,72 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestAdvertisingSet(t *testing.T) {
+	recs := initRecords()
+	var r []monitor.Record
+	r = append(r, recs[:14]...)
+	r = append(r, exchange(&cmd.LEReadLocalSupportedFeatures{}, 0x00, 0x00, 1<<(leFeatureExtAdv-8))...)
+	r = append(r, exchange(&cmd.LEReadMaximumAdvertisingDataLength{}, 0x00, 0x00, 0x01)...)
+	r = append(r, recs[16:]...)
+	r = append(r, exchange(&cmd.LEReadNumberOfSupportedAdvertisingSets{}, 0x00, 4)...)
+	r = append(r, exchange(&cmd.LESetExtendedAdvertisingParameters{}, 0x00, 0x00)...)
+	r = append(r, exchange(&cmd.LESetAdvertisingSetRandomAddress{}, 0x00)...)
+	r = append(r, exchange(&cmd.LESetExtendedAdvertisingData{}, 0x00)...)
+	r = append(r, exchange(&cmd.LESetExtendedAdvertisingEnable{}, 0x00)...)
+	// LE Advertising Set Terminated of the set, after 5 events.
+	r = append(r, event(0x3E, 0x12, 0x00, 0x01, 0x00, 0x00, 0x05))
+
+	s := monitor.NewReplaySocket(r, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err := h.NewAdvertisingSet(AdvertisingSetParams{Connectable: true, Legacy: true}); err != errLegacyConnNonScan {
+		t.Errorf("NewAdvertisingSet(legacy connectable, not scannable) = %v, want errLegacyConnNonScan", err)
+	}
+	as, err := h.NewAdvertisingSet(AdvertisingSetParams{
+		Legacy:    true,
+		Interval:  200 * time.Millisecond,
+		Addr:      ble.NewAddr("c0:ff:ee:00:00:03"),
+		MaxEvents: 5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if as.Handle() == extAdvHandle {
+		t.Errorf("set of handle %d, which is the advertising of the device", as.Handle())
+	}
+	ad, _ := adv.NewPacket(adv.IBeacon(ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb"), 1, 2, -59))
+	if err := as.SetPacket(ad, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := as.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; as.Enabled(); i++ {
+		if i == 100 {
+			t.Fatal("set not terminated after its events")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+}
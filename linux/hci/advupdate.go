@@ -0,0 +1,78 @@
+package hci
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+)
+
+// UpdateAdvertisement replaces the advertising data ad, and the scan response
+// sr, unless it's nil, of the advertising in progress without stopping it, so
+// they may be refreshed as often as every second, such as the service data of
+// a sensor, without the scanners, or the centrals connecting, noticing. The
+// data, which didn't change, aren't sent. If the controller disallows
+// setting the data while advertising, advertising is disabled, the data set,
+// and advertising enabled again. A scan response set by SetScanResponse
+// prevails over sr.
+func (h *HCI) UpdateAdvertisement(ad, sr []byte) error {
+	if err := h.requirePeripheral("UpdateAdvertisement"); err != nil {
+		return err
+	}
+	if h.fixedSR != nil {
+		sr = nil
+	}
+	if h.extAdv || h.advSetsUsed || len(ad) > adv.MaxEIRPacketLength || len(sr) > adv.MaxEIRPacketLength {
+		// The data of an extended set are replaced in place, if they fit in
+		// a fragment.
+		if sr == nil {
+			sr = append([]byte{}, h.scanResponseData()...)
+		}
+		return h.setExtAdvertisement(ad, sr)
+	}
+	err := h.updateAdvData(ad, sr)
+	if !errors.Is(err, ErrDisallowed) {
+		return err
+	}
+
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	if h.params.advEnable.AdvertisingEnable != 1 || h.advPaused {
+		return err
+	}
+	h.log(ble.LogHCI).Debug("can't update advertising data while advertising, restarting", "err", err)
+	if err := h.Send(h.advEnableCmd(0), nil); err != nil {
+		return err
+	}
+	err = h.updateAdvData(ad, sr)
+	if err2 := h.Send(h.advEnableCmd(1), nil); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// updateAdvData sets the legacy advertising data ad, and the scan response
+// sr, unless it's nil, which differ from those set.
+func (h *HCI) updateAdvData(ad, sr []byte) error {
+	// The data are kept once they're set, so they're sent again otherwise.
+	if !bytes.Equal(ad, h.advertisingData()) {
+		c := h.params.advData
+		c.AdvertisingDataLength = uint8(len(ad))
+		copy(c.AdvertisingData[:], ad)
+		if err := h.Send(&c, nil); err != nil {
+			return err
+		}
+		h.params.advData = c
+	}
+	if sr != nil && !bytes.Equal(sr, h.scanResponseData()) {
+		c := h.params.scanResp
+		c.ScanResponseDataLength = uint8(len(sr))
+		copy(c.ScanResponseData[:], sr)
+		if err := h.Send(&c, nil); err != nil {
+			return err
+		}
+		h.params.scanResp = c
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package hci
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"net"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// bdaddrOCF are the OCFs of the vendor commands, which set the public
+// address, by manufacturer. They take the address in little endian.
+var bdaddrOCF = map[uint16]uint16{
+	2:      0x0031, // Intel Write BD_ADDR
+	13:     0x0006, // Texas Instruments Write BD_ADDR
+	15:     0x0001, // Broadcom Write BD_ADDR
+	305:    0x0001, // Cypress, as Broadcom
+	0x05F1: 0x0006, // Zephyr Write BD_ADDR
+}
+
+// errRandomStaticAddr is returned for random addresses, which aren't static.
+var errRandomStaticAddr = errors.New("random static address must have the two most significant bits set")
+
+// parseAddr returns the address in the order of the HCI commands.
+func parseAddr(a ble.Addr) ([6]byte, error) {
+	b, err := net.ParseMAC(a.String())
+	if err != nil || len(b) != 6 {
+		return [6]byte{}, ErrInvalidAddr
+	}
+	return [6]byte{b[5], b[4], b[3], b[2], b[1], b[0]}, nil
+}
+
+// SetRandomAddress sets the random static address, which the device uses
+// for advertising, scanning and dialing. A nil address is generated.
+func (h *HCI) SetRandomAddress(a ble.Addr) error {
+	if a == nil {
+		h.useRandom, h.randomAddr = true, nil
+		return nil
+	}
+	b, err := parseAddr(a)
+	if err != nil {
+		return err
+	}
+	if b[5]&0xC0 != 0xC0 {
+		return errRandomStaticAddr
+	}
+	h.useRandom, h.randomAddr = true, b[:]
+	return nil
+}
+
+// SetPublicAddress sets the public address of the controller, with the
+// vendor command of its manufacturer, in place of the one it came with.
+func (h *HCI) SetPublicAddress(a ble.Addr) error {
+	b, err := parseAddr(a)
+	if err != nil {
+		return err
+	}
+	h.publicAddr = b[:]
+	return nil
+}
+
+// setPublicAddress sets the public address, which a reset doesn't restore.
+// The controller is identified by the version of the quirks.
+func (h *HCI) setPublicAddress() error {
+	ocf, ok := bdaddrOCF[h.version.Manufacturer]
+	if !ok {
+		return ErrPublicAddressNotSupported
+	}
+	_, err := h.SendVendorCommand(ocf, h.publicAddr)
+	return err
+}
+
+// setRandomAddress sets the random static address, generating it once, and
+// the parameters which advertise, scan and dial with it.
+func (h *HCI) setRandomAddress() error {
+	if h.randomAddr == nil {
+		b := make([]byte, 6)
+		for {
+			if _, err := rand.Read(b); err != nil {
+				return err
+			}
+			b[5] |= 0xC0
+			// The random part shall be neither all zeros nor all ones. [Vol 6, Part B, 1.3.2.1]
+			if !bytes.Equal(b, []byte{0, 0, 0, 0, 0, 0xC0}) && !bytes.Equal(b, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}) {
+				break
+			}
+		}
+		h.randomAddr = b
+	}
+	c := cmd.LESetRandomAddress{}
+	copy(c.RandomAddress[:], h.randomAddr)
+	if err := h.Send(&c, nil); err != nil {
+		return err
+	}
+	h.params.Lock()
+	h.params.advParams.OwnAddressType = h.advOwnAddrType(0x01)
+	h.params.scanParams.OwnAddressType = 0x01
+	h.params.connParams.OwnAddressType = 0x01
+	h.params.Unlock()
+	return nil
+}
+
+// advOwnAddrType returns the Own_Address_Type of the advertising, as set by
+// SetAdvOwnAddressType, or def if unset. A resolvable private address falls
+// back to the random address, if the device uses one, or the public one.
+// [Vol 2, Part E, 7.8.5]
+func (h *HCI) advOwnAddrType(def uint8) uint8 {
+	switch h.advOwnAddr {
+	case ble.OwnAddressPublic:
+		return 0x00
+	case ble.OwnAddressRandom:
+		return 0x01
+	case ble.OwnAddressRPA:
+		if h.useRandom {
+			return 0x03
+		}
+		return 0x02
+	}
+	return def
+}
+
+// ownAddr returns the address the device advertises, scans and dials with.
+func (h *HCI) ownAddr() ble.Addr {
+	if h.useRandom && h.randomAddr != nil {
+		b := h.randomAddr
+		return RandomAddress{net.HardwareAddr([]byte{b[5], b[4], b[3], b[2], b[1], b[0]})}
+	}
+	return PublicAddress{h.addr}
+}
@@ -0,0 +1,95 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestAddressOverride(t *testing.T) {
+	recs := initRecords()
+	var r []monitor.Record
+	r = append(r, recs[:2]...) // Reset
+	r = append(r, exchange(&cmd.ReadLocalVersionInformation{}, 0x00, 0x09, 0x00, 0x00, 0x09, 15, 0x00)...)
+	r = append(r, exchange(&rawCommand{op: ogfVendor<<10 | 0x0001, params: make([]byte, 6)}, 0x00)...)
+	r = append(r, exchange(&cmd.ReadBDADDR{}, 0x00, 0x01, 0x00, 0x00, 0xEE, 0xFF, 0x00)...)
+	r = append(r, recs[6:22]...)
+	r = append(r, exchange(&cmd.LESetRandomAddress{}, 0x00)...)
+	r = append(r, recs[22:]...)
+
+	s := monitor.NewReplaySocket(r, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s),
+		ble.OptPublicAddressOverride(ble.NewAddr("00:ff:ee:00:00:01")),
+		ble.OptRandomAddress(ble.NewAddr("c0:ff:ee:00:00:02")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+	if a := h.addr.String(); a != "00:ff:ee:00:00:01" {
+		t.Errorf("public address = %s, want 00:ff:ee:00:00:01", a)
+	}
+	if a, ok := h.Addr().(RandomAddress); !ok || a.String() != "c0:ff:ee:00:00:02" {
+		t.Errorf("Addr() = %v, want random address c0:ff:ee:00:00:02", h.Addr())
+	}
+	if h.params.advParams.OwnAddressType != 0x01 {
+		t.Error("advertising without the random address")
+	}
+}
+
+func TestSetRandomAddress(t *testing.T) {
+	h := &HCI{}
+	if err := h.SetRandomAddress(ble.NewAddr("40:ff:ee:00:00:02")); err != errRandomStaticAddr {
+		t.Errorf("SetRandomAddress(non-static) = %v, want errRandomStaticAddr", err)
+	}
+	if err := h.SetRandomAddress(nil); err != nil || !h.useRandom {
+		t.Errorf("SetRandomAddress(nil) = %v, want a generated address", err)
+	}
+}
+
+func TestAdvOwnAddressType(t *testing.T) {
+	h := &HCI{}
+	if err := h.SetAdvOwnAddressType(ble.OwnAddressRPA); err != nil || h.params.advParams.OwnAddressType != 0x02 {
+		t.Errorf("RPA without a random address: Own_Address_Type = %d, %v, want 2", h.params.advParams.OwnAddressType, err)
+	}
+	// Init advertises with an RPA falling back to the random address, once it's set.
+	h.useRandom = true
+	if typ := h.advOwnAddrType(0x01); typ != 0x03 {
+		t.Errorf("RPA with a random address: Own_Address_Type = %d, want 3", typ)
+	}
+	h = &HCI{}
+	if err := h.SetAdvOwnAddressType(ble.OwnAddressRandom); err != nil || !h.useRandom || h.params.advParams.OwnAddressType != 0x01 {
+		t.Errorf("random: Own_Address_Type = %d, %v, want 1 of a generated address", h.params.advParams.OwnAddressType, err)
+	}
+	if err := h.SetAdvIntervalMin(10 * time.Millisecond); err == nil {
+		t.Error("accepted an advertising interval below 20 ms")
+	}
+	if err := h.SetAdvIntervalMax(time.Second); err != nil || h.params.advParams.AdvertisingIntervalMax != 1600 {
+		t.Errorf("SetAdvIntervalMax(1s) = %v, interval %d, want 1600", err, h.params.advParams.AdvertisingIntervalMax)
+	}
+	if err := h.SetAdvChannelMap(ble.AdvChannel37); err != nil || h.params.advParams.AdvertisingChannelMap != 0x01 {
+		t.Errorf("SetAdvChannelMap(37) = %v, map 0x%02X, want 0x01", err, h.params.advParams.AdvertisingChannelMap)
+	}
+	if err := h.SetAdvChannelMap(0x08); err == nil {
+		t.Error("accepted an invalid channel map")
+	}
+	// The options fail with the errors of the setters.
+	if _, err := NewHCI(ble.OptAdvChannelMap(0x08)); err == nil {
+		t.Error("OptAdvChannelMap accepted an invalid channel map")
+	}
+	if _, err := NewHCI(ble.OptAdvIntervalMin(10 * time.Millisecond)); err == nil {
+		t.Error("OptAdvIntervalMin accepted an interval below 20 ms")
+	}
+}
@@ -2,73 +2,202 @@ package hci
 
 import (
 	"bytes"
+	"context"
 	"sync"
 )
 
-// Pool ...
+// Pool accounts for the ACL data buffers of the controller, which are shared
+// by all the connections. [Vol 2, Part E, 4.1.1]
+//
+// Each Client takes a buffer for every packet it sends, and gives it back
+// when the controller reports the packet completed. The free buffers are
+// handed to the waiting clients in turn, one packet at a time, so a
+// connection sending a long PDU doesn't hold up the others. A client may
+// hold up to all the buffers but one for each other client, so a connection
+// whose peer stops acknowledging can't starve the rest.
 type Pool struct {
-	sync.Mutex
+	mu sync.Mutex
 
-	sz  int
-	cnt int
-	ch  chan *bytes.Buffer
+	sz      int
+	cnt     int
+	free    int
+	clients int
+	waiters []*Client
 }
 
-// NewPool ...
+// NewPool returns a pool of cnt buffers of sz bytes.
 func NewPool(sz int, cnt int) *Pool {
-	ch := make(chan *bytes.Buffer, cnt)
-	for len(ch) < cnt {
-		ch <- bytes.NewBuffer(make([]byte, sz))
+	return &Pool{sz: sz, cnt: cnt, free: cnt}
+}
+
+// quota returns the number of buffers a client may hold. Must be called
+// with p.mu held.
+func (p *Pool) quota() int {
+	q := p.cnt - (p.clients - 1)
+	if q < 1 {
+		q = 1
 	}
-	return &Pool{sz: sz, cnt: cnt, ch: ch}
+	return q
 }
 
-// Client ...
+// dispatch hands the free buffers to the waiting clients, in the order they
+// asked, skipping those which hold their quota. Must be called with p.mu held.
+func (p *Pool) dispatch() {
+	q := p.quota()
+	for i := 0; i < len(p.waiters) && p.free > 0; {
+		c := p.waiters[i]
+		if c.sent >= q {
+			i++
+			continue
+		}
+		p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+		p.free--
+		c.sent++
+		c.ready <- true
+	}
+}
+
+// Client is the share of a connection in a Pool.
 type Client struct {
-	p    *Pool
-	sent chan *bytes.Buffer
+	mu sync.Mutex
+
+	p      *Pool
+	buf    *bytes.Buffer
+	sent   int
+	closed bool
+	ready  chan bool
+	idle   []chan struct{} // Closed once no packets are sent.
 }
 
-// NewClient ...
+// NewClient returns a client of the pool p.
 func NewClient(p *Pool) *Client {
-	return &Client{p: p, sent: make(chan *bytes.Buffer, p.cnt)}
+	p.mu.Lock()
+	p.clients++
+	p.mu.Unlock()
+	return &Client{
+		p:     p,
+		buf:   bytes.NewBuffer(make([]byte, p.sz)),
+		ready: make(chan bool, 1),
+	}
 }
 
-// LockPool ...
+// LockPool serializes the packets of the client, so the fragments of a PDU
+// aren't interleaved with those of another PDU of the same connection. The
+// other clients keep sending meanwhile.
 func (c *Client) LockPool() {
-	c.p.Lock()
+	c.mu.Lock()
 }
 
 // UnlockPool ...
 func (c *Client) UnlockPool() {
-	c.p.Unlock()
+	c.mu.Unlock()
 }
 
-// Get returns a buffer from the shared buffer pool.
+// Get waits for a buffer from the shared buffer pool. The packet must be
+// sent before the next Get, which reuses the buffer. It returns nil if the
+// client is closed.
 func (c *Client) Get() *bytes.Buffer {
-	b := <-c.p.ch
-	b.Reset()
-	c.sent <- b
-	return b
+	p := c.p
+	p.mu.Lock()
+	if c.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.waiters = append(p.waiters, c)
+	p.dispatch()
+	p.mu.Unlock()
+	if !<-c.ready {
+		return nil
+	}
+	c.buf.Reset()
+	return c.buf
 }
 
 // Put puts the oldest sent buffer back to the shared pool.
 func (c *Client) Put() {
-	select {
-	case b := <-c.sent:
-		c.p.ch <- b
-	default:
+	p := c.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c.sent == 0 {
+		return
 	}
+	c.sent--
+	p.free++
+	c.drained()
+	p.dispatch()
 }
 
 // PutAll puts all the sent buffers back to the shared pool.
 func (c *Client) PutAll() {
-	for {
-		select {
-		case b := <-c.sent:
-			c.p.ch <- b
-		default:
-			return
+	p := c.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c.putAll()
+}
+
+func (c *Client) putAll() {
+	c.p.free += c.sent
+	c.sent = 0
+	c.drained()
+	c.p.dispatch()
+}
+
+// drained wakes the Drains up, once no packets are sent. Must be called with
+// c.p.mu held.
+func (c *Client) drained() {
+	if c.sent != 0 {
+		return
+	}
+	for _, ch := range c.idle {
+		close(ch)
+	}
+	c.idle = nil
+}
+
+// Drain waits until the controller has reported all the packets sent by the
+// client completed, or ctx is done.
+func (c *Client) Drain(ctx context.Context) error {
+	c.p.mu.Lock()
+	if c.sent == 0 {
+		c.p.mu.Unlock()
+		return nil
+	}
+	ch := make(chan struct{})
+	c.idle = append(c.idle, ch)
+	c.p.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight returns the number of packets sent by the client, which the
+// controller hasn't reported completed yet.
+func (c *Client) InFlight() int {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	return c.sent
+}
+
+// Close puts all the sent buffers back to the shared pool, and leaves it.
+// A pending Get returns nil.
+func (c *Client) Close() {
+	p := c.p
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	for i, w := range p.waiters {
+		if w == c {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			c.ready <- false
+			break
 		}
 	}
+	p.clients--
+	c.putAll()
 }
@@ -0,0 +1,107 @@
+package hci
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolRoundRobin(t *testing.T) {
+	p := NewPool(32, 2)
+	a, b := NewClient(p), NewClient(p)
+
+	// a takes the buffers it's allowed, and waits for the next one.
+	a.Get()
+	if n := a.InFlight(); n != 1 {
+		t.Fatalf("a in flight = %d, want 1", n)
+	}
+	got := make(chan string, 2)
+	go func() { a.Get(); got <- "a" }()
+	time.Sleep(10 * time.Millisecond)
+
+	// b is served from the free buffer, even though a asked first, since a
+	// holds its quota.
+	done := make(chan struct{})
+	go func() { b.Get(); got <- "b"; close(done) }()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("b starved by a")
+	}
+	if s := <-got; s != "b" {
+		t.Fatalf("served %s first, want b", s)
+	}
+
+	// The completed packet of a frees a buffer for a.
+	a.Put()
+	select {
+	case s := <-got:
+		if s != "a" {
+			t.Fatalf("served %s, want a", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a not served after its packet completed")
+	}
+}
+
+func TestPoolClose(t *testing.T) {
+	p := NewPool(32, 1)
+	a, b := NewClient(p), NewClient(p)
+	a.Get()
+
+	got := make(chan bool)
+	go func() { got <- b.Get() == nil }()
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+	if !<-got {
+		t.Fatal("Get of a closed client returned a buffer")
+	}
+
+	// Closing a returns its buffers to the pool.
+	a.Close()
+	c := NewClient(p)
+	if c.Get() == nil || c.InFlight() != 1 {
+		t.Fatal("buffers of a closed client not returned to the pool")
+	}
+
+	// Completions beyond the sent packets are ignored.
+	c.Put()
+	c.Put()
+	if p.free != 1 {
+		t.Fatalf("free = %d, want 1", p.free)
+	}
+}
+
+func TestPoolDrain(t *testing.T) {
+	p := NewPool(32, 4)
+	a := NewClient(p)
+	if err := a.Drain(context.Background()); err != nil {
+		t.Fatalf("idle drain: %v", err)
+	}
+	a.Get()
+	a.Get()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := a.Drain(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("drain with packets in flight = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- a.Drain(context.Background()) }()
+	a.Put()
+	select {
+	case err := <-done:
+		t.Fatalf("drained with a packet in flight: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	a.Put()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not drained after the packets completed")
+	}
+}
@@ -0,0 +1,29 @@
+package hci
+
+import (
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// SetHostChannelClassification classifies the data channels, which m doesn't
+// have, as bad, so that the controller leaves them out of the channel maps
+// of the connections. It's sent on init, and again on every reset, and at
+// once, if the device is open. The maps of the connections are updated by
+// the controller later, as the ChannelMapMonitor tells. [Vol 2, Part E,
+// 7.8.19]
+func (h *HCI) SetHostChannelClassification(m ble.ChannelMap) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	h.hostChannels = &m
+	if h.skt == nil {
+		// Sent on init.
+		return nil
+	}
+	return h.sendHostChannels(m)
+}
+
+func (h *HCI) sendHostChannels(m ble.ChannelMap) error {
+	m[4] &= 0x1F // The bits of channels 37 to 39 are reserved.
+	return h.Send(&cmd.LESetHostChannelClassification{ChannelMap: m}, nil)
+}
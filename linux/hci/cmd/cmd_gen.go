@@ -586,6 +586,38 @@ func (c *ReadRSSIRP) Unmarshal(b []byte) error {
 	return unmarshal(c, b)
 }
 
+// ReadEncryptionKeySize implements Read Encryption Key Size (0x05|0x0008) [Vol 2, Part E, 7.5.7]
+type ReadEncryptionKeySize struct {
+	ConnectionHandle uint16
+}
+
+func (c *ReadEncryptionKeySize) String() string {
+	return "Read Encryption Key Size (0x05|0x0008)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *ReadEncryptionKeySize) OpCode() int { return 0x05<<10 | 0x0008 }
+
+// Len returns the length of the command.
+func (c *ReadEncryptionKeySize) Len() int { return 2 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *ReadEncryptionKeySize) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// ReadEncryptionKeySizeRP returns the return parameter of Read Encryption Key Size
+type ReadEncryptionKeySizeRP struct {
+	Status           uint8
+	ConnectionHandle uint16
+	KeySize          uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *ReadEncryptionKeySizeRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
 // LESetEventMask implements LE Set Event Mask (0x08|0x0001) [Vol 2, Part E, 7.8.1]
 type LESetEventMask struct {
 	LEEventMask uint64
@@ -1560,3 +1592,203 @@ type LERemoteConnectionParameterRequestNegativeReplyRP struct {
 func (c *LERemoteConnectionParameterRequestNegativeReplyRP) Unmarshal(b []byte) error {
 	return unmarshal(c, b)
 }
+
+// LESetExtendedAdvertisingParameters implements LE Set Extended Advertising Parameters (0x08|0x0036) [Vol 2, Part E, 7.8.53]
+type LESetExtendedAdvertisingParameters struct {
+	AdvertisingHandle             uint8
+	AdvertisingEventProperties    uint16
+	PrimaryAdvertisingIntervalMin [3]byte
+	PrimaryAdvertisingIntervalMax [3]byte
+	PrimaryAdvertisingChannelMap  uint8
+	OwnAddressType                uint8
+	PeerAddressType               uint8
+	PeerAddress                   [6]byte
+	AdvertisingFilterPolicy       uint8
+	AdvertisingTxPower            int8
+	PrimaryAdvertisingPHY         uint8
+	SecondaryAdvertisingMaxSkip   uint8
+	SecondaryAdvertisingPHY       uint8
+	AdvertisingSID                uint8
+	ScanRequestNotificationEnable uint8
+}
+
+func (c *LESetExtendedAdvertisingParameters) String() string {
+	return "LE Set Extended Advertising Parameters (0x08|0x0036)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetExtendedAdvertisingParameters) OpCode() int { return 0x08<<10 | 0x0036 }
+
+// Len returns the length of the command.
+func (c *LESetExtendedAdvertisingParameters) Len() int { return 25 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetExtendedAdvertisingParameters) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetExtendedAdvertisingParametersRP returns the return parameter of LE Set Extended Advertising Parameters
+type LESetExtendedAdvertisingParametersRP struct {
+	Status          uint8
+	SelectedTxPower int8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetExtendedAdvertisingParametersRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetExtendedAdvertisingEnable implements LE Set Extended Advertising Enable (0x08|0x0039) [Vol 2, Part E, 7.8.56]
+type LESetExtendedAdvertisingEnable struct {
+	Enable                       uint8
+	NumberOfSets                 uint8
+	AdvertisingHandle            uint8
+	Duration                     uint16
+	MaxExtendedAdvertisingEvents uint8
+}
+
+func (c *LESetExtendedAdvertisingEnable) String() string {
+	return "LE Set Extended Advertising Enable (0x08|0x0039)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetExtendedAdvertisingEnable) OpCode() int { return 0x08<<10 | 0x0039 }
+
+// Len returns the length of the command.
+func (c *LESetExtendedAdvertisingEnable) Len() int { return 6 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetExtendedAdvertisingEnable) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetExtendedAdvertisingEnableRP returns the return parameter of LE Set Extended Advertising Enable
+type LESetExtendedAdvertisingEnableRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetExtendedAdvertisingEnableRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LEReadMaximumAdvertisingDataLength implements LE Read Maximum Advertising Data Length (0x08|0x003A) [Vol 2, Part E, 7.8.57]
+type LEReadMaximumAdvertisingDataLength struct {
+}
+
+func (c *LEReadMaximumAdvertisingDataLength) String() string {
+	return "LE Read Maximum Advertising Data Length (0x08|0x003A)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEReadMaximumAdvertisingDataLength) OpCode() int { return 0x08<<10 | 0x003A }
+
+// Len returns the length of the command.
+func (c *LEReadMaximumAdvertisingDataLength) Len() int { return 0 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEReadMaximumAdvertisingDataLength) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEReadMaximumAdvertisingDataLengthRP returns the return parameter of LE Read Maximum Advertising Data Length
+type LEReadMaximumAdvertisingDataLengthRP struct {
+	Status                       uint8
+	MaximumAdvertisingDataLength uint16
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LEReadMaximumAdvertisingDataLengthRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetAdvertisingSetRandomAddress implements LE Set Advertising Set Random Address (0x08|0x0035) [Vol 2, Part E, 7.8.52]
+type LESetAdvertisingSetRandomAddress struct {
+	AdvertisingHandle uint8
+	RandomAddress     [6]byte
+}
+
+func (c *LESetAdvertisingSetRandomAddress) String() string {
+	return "LE Set Advertising Set Random Address (0x08|0x0035)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetAdvertisingSetRandomAddress) OpCode() int { return 0x08<<10 | 0x0035 }
+
+// Len returns the length of the command.
+func (c *LESetAdvertisingSetRandomAddress) Len() int { return 7 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetAdvertisingSetRandomAddress) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetAdvertisingSetRandomAddressRP returns the return parameter of LE Set Advertising Set Random Address
+type LESetAdvertisingSetRandomAddressRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetAdvertisingSetRandomAddressRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LEReadNumberOfSupportedAdvertisingSets implements LE Read Number Of Supported Advertising Sets (0x08|0x003B) [Vol 2, Part E, 7.8.58]
+type LEReadNumberOfSupportedAdvertisingSets struct {
+}
+
+func (c *LEReadNumberOfSupportedAdvertisingSets) String() string {
+	return "LE Read Number Of Supported Advertising Sets (0x08|0x003B)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEReadNumberOfSupportedAdvertisingSets) OpCode() int { return 0x08<<10 | 0x003B }
+
+// Len returns the length of the command.
+func (c *LEReadNumberOfSupportedAdvertisingSets) Len() int { return 0 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEReadNumberOfSupportedAdvertisingSets) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEReadNumberOfSupportedAdvertisingSetsRP returns the return parameter of LE Read Number Of Supported Advertising Sets
+type LEReadNumberOfSupportedAdvertisingSetsRP struct {
+	Status                      uint8
+	NumSupportedAdvertisingSets uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LEReadNumberOfSupportedAdvertisingSetsRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LERemoveAdvertisingSet implements LE Remove Advertising Set (0x08|0x003C) [Vol 2, Part E, 7.8.59]
+type LERemoveAdvertisingSet struct {
+	AdvertisingHandle uint8
+}
+
+func (c *LERemoveAdvertisingSet) String() string {
+	return "LE Remove Advertising Set (0x08|0x003C)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LERemoveAdvertisingSet) OpCode() int { return 0x08<<10 | 0x003C }
+
+// Len returns the length of the command.
+func (c *LERemoveAdvertisingSet) Len() int { return 1 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LERemoveAdvertisingSet) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LERemoveAdvertisingSetRP returns the return parameter of LE Remove Advertising Set
+type LERemoveAdvertisingSetRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LERemoveAdvertisingSetRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalFixtures(t *testing.T) {
+	tests := []struct {
+		c    command
+		want []byte
+	}{
+		{
+			&LESetAdvertisingParameters{
+				AdvertisingIntervalMin:  0x0020,
+				AdvertisingIntervalMax:  0x4000,
+				AdvertisingType:         0x00,
+				OwnAddressType:          0x01,
+				DirectAddressType:       0x00,
+				DirectAddress:           [6]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+				AdvertisingChannelMap:   0x07,
+				AdvertisingFilterPolicy: 0x00,
+			},
+			[]byte{0x20, 0x00, 0x00, 0x40, 0x00, 0x01, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x00},
+		},
+		{
+			&SetEventMask{EventMask: 0x3dbff807fffbffff},
+			[]byte{0xff, 0xff, 0xfb, 0xff, 0x07, 0xf8, 0xbf, 0x3d},
+		},
+		{
+			&Disconnect{ConnectionHandle: 0x0040, Reason: 0x13},
+			[]byte{0x40, 0x00, 0x13},
+		},
+		{
+			&LESetExtendedAdvertisingData{AdvertisingHandle: 0x00, Operation: ExtAdvOpComplete, FragmentPreference: 0x01, AdvertisingDataLength: 3, AdvertisingData: [MaxExtAdvFragmentLength]byte{0x02, 0x01, 0x06}},
+			[]byte{0x00, 0x03, 0x01, 0x03, 0x02, 0x01, 0x06},
+		},
+		{
+			&LESetExtendedScanParameters{OwnAddressType: 0x01, ScanningPHYs: ScanningPHY1M | ScanningPHYCoded,
+				PHY: []ScanPHYParams{{0x01, 0x0010, 0x0010}, {0x00, 0x0030, 0x0020}}},
+			[]byte{0x01, 0x00, 0x05, 0x01, 0x10, 0x00, 0x10, 0x00, 0x00, 0x30, 0x00, 0x20, 0x00},
+		},
+		{
+			&LESetExtendedAdvertisingEnable{Enable: 1, NumberOfSets: 1, AdvertisingHandle: 0x00},
+			[]byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			&LESetCIGParameters{CIGID: 0x01, SDUIntervalCToP: [3]byte{0x10, 0x27}, SDUIntervalPToC: [3]byte{0x10, 0x27}, MaxTransportLatencyCToP: 10, MaxTransportLatencyPToC: 10,
+				CIS: []CISConfig{{CISID: 0x00, MaxSDUCToP: 40, MaxSDUPToC: 40, PHYCToP: 0x02, PHYPToC: 0x02, RTNCToP: 2, RTNPToC: 2}}},
+			[]byte{0x01, 0x10, 0x27, 0x00, 0x10, 0x27, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x0a, 0x00, 0x01,
+				0x00, 0x28, 0x00, 0x28, 0x00, 0x02, 0x02, 0x02, 0x02},
+		},
+		{
+			&LECreateCIS{CIS: []CISConnection{{CISConnectionHandle: 0x0060, ACLConnectionHandle: 0x0040}}},
+			[]byte{0x01, 0x60, 0x00, 0x40, 0x00},
+		},
+		{
+			&LEBIGCreateSync{BIGHandle: 0x00, SyncHandle: 0x0001, BIGSyncTimeout: 100, BIS: []uint8{1, 2}},
+			[]byte{0x00, 0x01, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x64, 0x00, 0x02, 0x01, 0x02},
+		},
+		{
+			&LESetPHY{ConnectionHandle: 0x0040, AllPHYs: AllPHYsNoRxPreference, TxPHYs: 0x02},
+			[]byte{0x40, 0x00, 0x02, 0x02, 0x00, 0x00, 0x00},
+		},
+	}
+	for _, tt := range tests {
+		b := make([]byte, tt.c.Len())
+		if err := tt.c.Marshal(b); err != nil {
+			t.Fatalf("%T: %s", tt.c, err)
+		}
+		if !bytes.Equal(b, tt.want) {
+			t.Errorf("%T: got % x, want % x", tt.c, b, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalFixtures(t *testing.T) {
+	var rp LEReadBufferSizeRP
+	if err := rp.Unmarshal([]byte{0x00, 0xfb, 0x00, 0x0f}); err != nil {
+		t.Fatal(err)
+	}
+	if rp.HCLEDataPacketLength != 0x00fb || rp.HCTotalNumLEDataPackets != 0x0f {
+		t.Errorf("got %+v", rp)
+	}
+}
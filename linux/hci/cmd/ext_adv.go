@@ -0,0 +1,99 @@
+package cmd
+
+import "io"
+
+// The commands of the extended advertising and scan response data are of
+// variable length, so they're not generated along with cmd_gen.go.
+
+// MaxExtAdvFragmentLength is the maximum length of the data carried by one
+// LE Set Extended Advertising Data, or Scan Response Data, command.
+const MaxExtAdvFragmentLength = 251
+
+// Operations of the extended advertising and scan response data.
+const (
+	ExtAdvOpIntermediate = 0x00 // Intermediate fragment of fragmented data.
+	ExtAdvOpFirst        = 0x01 // First fragment of fragmented data.
+	ExtAdvOpLast         = 0x02 // Last fragment of fragmented data.
+	ExtAdvOpComplete     = 0x03 // Complete data.
+)
+
+// LESetExtendedAdvertisingData implements LE Set Extended Advertising Data (0x08|0x0037) [Vol 2, Part E, 7.8.54]
+type LESetExtendedAdvertisingData struct {
+	AdvertisingHandle     uint8
+	Operation             uint8
+	FragmentPreference    uint8
+	AdvertisingDataLength uint8
+	AdvertisingData       [MaxExtAdvFragmentLength]byte
+}
+
+func (c *LESetExtendedAdvertisingData) String() string {
+	return "LE Set Extended Advertising Data (0x08|0x0037)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetExtendedAdvertisingData) OpCode() int { return 0x08<<10 | 0x0037 }
+
+// Len returns the length of the command, which carries only the used part
+// of the data.
+func (c *LESetExtendedAdvertisingData) Len() int { return 4 + int(c.AdvertisingDataLength) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetExtendedAdvertisingData) Marshal(b []byte) error {
+	return marshalExtData(b, c.AdvertisingHandle, c.Operation, c.FragmentPreference, c.AdvertisingData[:c.AdvertisingDataLength])
+}
+
+// LESetExtendedAdvertisingDataRP returns the return parameter of LE Set Extended Advertising Data
+type LESetExtendedAdvertisingDataRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetExtendedAdvertisingDataRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetExtendedScanResponseData implements LE Set Extended Scan Response Data (0x08|0x0038) [Vol 2, Part E, 7.8.55]
+type LESetExtendedScanResponseData struct {
+	AdvertisingHandle      uint8
+	Operation              uint8
+	FragmentPreference     uint8
+	ScanResponseDataLength uint8
+	ScanResponseData       [MaxExtAdvFragmentLength]byte
+}
+
+func (c *LESetExtendedScanResponseData) String() string {
+	return "LE Set Extended Scan Response Data (0x08|0x0038)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetExtendedScanResponseData) OpCode() int { return 0x08<<10 | 0x0038 }
+
+// Len returns the length of the command, which carries only the used part
+// of the data.
+func (c *LESetExtendedScanResponseData) Len() int { return 4 + int(c.ScanResponseDataLength) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetExtendedScanResponseData) Marshal(b []byte) error {
+	return marshalExtData(b, c.AdvertisingHandle, c.Operation, c.FragmentPreference, c.ScanResponseData[:c.ScanResponseDataLength])
+}
+
+// LESetExtendedScanResponseDataRP returns the return parameter of LE Set Extended Scan Response Data
+type LESetExtendedScanResponseDataRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetExtendedScanResponseDataRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// marshalExtData serializes the variable length parameters of the extended
+// advertising and scan response data.
+func marshalExtData(b []byte, handle, op, pref uint8, data []byte) error {
+	if len(b) < 4+len(data) {
+		return io.ErrShortBuffer
+	}
+	b[0], b[1], b[2], b[3] = handle, op, pref, uint8(len(data))
+	copy(b[4:], data)
+	return nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// The parameters of extended scanning are of each PHY which is scanned, in
+// the order of the bits of the Scanning PHYs.
+
+// Bits of the PHYs in the Scanning PHYs.
+const (
+	ScanningPHY1M    = 0x01
+	ScanningPHYCoded = 0x04
+)
+
+// ScanPHYParams is the parameters of a PHY, of LE Set Extended Scan
+// Parameters.
+type ScanPHYParams struct {
+	ScanType     uint8
+	ScanInterval uint16
+	ScanWindow   uint16
+}
+
+// LESetExtendedScanParameters implements LE Set Extended Scan Parameters (0x08|0x0041) [Vol 2, Part E, 7.8.64]
+type LESetExtendedScanParameters struct {
+	OwnAddressType       uint8
+	ScanningFilterPolicy uint8
+	ScanningPHYs         uint8
+	PHY                  []ScanPHYParams // One for each bit of ScanningPHYs.
+}
+
+func (c *LESetExtendedScanParameters) String() string {
+	return "LE Set Extended Scan Parameters (0x08|0x0041)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetExtendedScanParameters) OpCode() int { return 0x08<<10 | 0x0041 }
+
+// Len returns the length of the command.
+func (c *LESetExtendedScanParameters) Len() int { return 3 + 5*bits.OnesCount8(c.ScanningPHYs) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetExtendedScanParameters) Marshal(b []byte) error {
+	if len(c.PHY) != bits.OnesCount8(c.ScanningPHYs) {
+		return errors.New("parameters not of each of the scanning PHYs")
+	}
+	p := struct {
+		OwnAddressType       uint8
+		ScanningFilterPolicy uint8
+		ScanningPHYs         uint8
+	}{c.OwnAddressType, c.ScanningFilterPolicy, c.ScanningPHYs}
+	return marshalParams(b, &p, c.PHY)
+}
+
+// LESetExtendedScanParametersRP returns the return parameter of LE Set Extended Scan Parameters
+type LESetExtendedScanParametersRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetExtendedScanParametersRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetExtendedScanEnable implements LE Set Extended Scan Enable (0x08|0x0042) [Vol 2, Part E, 7.8.65]
+type LESetExtendedScanEnable struct {
+	Enable           uint8
+	FilterDuplicates uint8
+	Duration         uint16
+	Period           uint16
+}
+
+func (c *LESetExtendedScanEnable) String() string {
+	return "LE Set Extended Scan Enable (0x08|0x0042)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetExtendedScanEnable) OpCode() int { return 0x08<<10 | 0x0042 }
+
+// Len returns the length of the command.
+func (c *LESetExtendedScanEnable) Len() int { return 6 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetExtendedScanEnable) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetExtendedScanEnableRP returns the return parameter of LE Set Extended Scan Enable
+type LESetExtendedScanEnableRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetExtendedScanEnableRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
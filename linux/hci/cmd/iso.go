@@ -0,0 +1,488 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// The commands of the isochronous channels, and of the periodic advertising
+// which carries the BIGInfo of the broadcasts. The CIG parameters, and the
+// BISes synchronized to, are of variable length, so they're not generated
+// along with cmd_gen.go.
+
+// marshalParams serializes the fixed parameters p, followed by the variable
+// ones, such as an array of structures.
+func marshalParams(b []byte, p, rest interface{}) error {
+	buf := bytes.NewBuffer(b)
+	buf.Reset()
+	if buf.Cap() < binary.Size(p)+binary.Size(rest) {
+		return io.ErrShortBuffer
+	}
+	if err := binary.Write(buf, binary.LittleEndian, p); err != nil {
+		return err
+	}
+	return binary.Write(buf, binary.LittleEndian, rest)
+}
+
+// LEReadBufferSizeV2 implements LE Read Buffer Size [v2] (0x08|0x0060) [Vol 4, Part E, 7.8.2]
+type LEReadBufferSizeV2 struct{}
+
+func (c *LEReadBufferSizeV2) String() string {
+	return "LE Read Buffer Size [v2] (0x08|0x0060)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEReadBufferSizeV2) OpCode() int { return 0x08<<10 | 0x0060 }
+
+// Len returns the length of the command.
+func (c *LEReadBufferSizeV2) Len() int { return 0 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEReadBufferSizeV2) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEReadBufferSizeV2RP returns the return parameter of LE Read Buffer Size [v2]
+type LEReadBufferSizeV2RP struct {
+	Status                   uint8
+	LEACLDataPacketLength    uint16
+	TotalNumLEACLDataPackets uint8
+	ISODataPacketLength      uint16
+	TotalNumISODataPackets   uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LEReadBufferSizeV2RP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetHostFeature implements LE Set Host Feature (0x08|0x0074) [Vol 4, Part E, 7.8.115]
+type LESetHostFeature struct {
+	BitNumber uint8
+	BitValue  uint8
+}
+
+func (c *LESetHostFeature) String() string {
+	return "LE Set Host Feature (0x08|0x0074)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetHostFeature) OpCode() int { return 0x08<<10 | 0x0074 }
+
+// Len returns the length of the command.
+func (c *LESetHostFeature) Len() int { return 2 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetHostFeature) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetHostFeatureRP returns the return parameter of LE Set Host Feature
+type LESetHostFeatureRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetHostFeatureRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// CISConfig is the configuration of a CIS of LE Set CIG Parameters.
+type CISConfig struct {
+	CISID      uint8
+	MaxSDUCToP uint16
+	MaxSDUPToC uint16
+	PHYCToP    uint8
+	PHYPToC    uint8
+	RTNCToP    uint8
+	RTNPToC    uint8
+}
+
+// LESetCIGParameters implements LE Set CIG Parameters (0x08|0x0062) [Vol 4, Part E, 7.8.97]
+type LESetCIGParameters struct {
+	CIGID                   uint8
+	SDUIntervalCToP         [3]byte
+	SDUIntervalPToC         [3]byte
+	WorstCaseSCA            uint8
+	Packing                 uint8
+	Framing                 uint8
+	MaxTransportLatencyCToP uint16
+	MaxTransportLatencyPToC uint16
+	CIS                     []CISConfig
+}
+
+func (c *LESetCIGParameters) String() string {
+	return "LE Set CIG Parameters (0x08|0x0062)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetCIGParameters) OpCode() int { return 0x08<<10 | 0x0062 }
+
+// Len returns the length of the command.
+func (c *LESetCIGParameters) Len() int { return 15 + 9*len(c.CIS) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetCIGParameters) Marshal(b []byte) error {
+	p := struct {
+		CIGID                   uint8
+		SDUIntervalCToP         [3]byte
+		SDUIntervalPToC         [3]byte
+		WorstCaseSCA            uint8
+		Packing                 uint8
+		Framing                 uint8
+		MaxTransportLatencyCToP uint16
+		MaxTransportLatencyPToC uint16
+		CISCount                uint8
+	}{c.CIGID, c.SDUIntervalCToP, c.SDUIntervalPToC, c.WorstCaseSCA, c.Packing, c.Framing,
+		c.MaxTransportLatencyCToP, c.MaxTransportLatencyPToC, uint8(len(c.CIS))}
+	return marshalParams(b, &p, c.CIS)
+}
+
+// LESetCIGParametersRP returns the return parameter of LE Set CIG Parameters
+type LESetCIGParametersRP struct {
+	Status           uint8
+	CIGID            uint8
+	ConnectionHandle []uint16 // Of the CISes, in the order of the configurations.
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetCIGParametersRP) Unmarshal(b []byte) error {
+	if len(b) < 3 || len(b) < 3+2*int(b[2]) {
+		return io.ErrUnexpectedEOF
+	}
+	c.Status, c.CIGID = b[0], b[1]
+	c.ConnectionHandle = make([]uint16, b[2])
+	for i := range c.ConnectionHandle {
+		c.ConnectionHandle[i] = binary.LittleEndian.Uint16(b[3+2*i:])
+	}
+	return nil
+}
+
+// CISConnection pairs a CIS with the ACL connection, it's created on, of LE
+// Create CIS.
+type CISConnection struct {
+	CISConnectionHandle uint16
+	ACLConnectionHandle uint16
+}
+
+// LECreateCIS implements LE Create CIS (0x08|0x0064) [Vol 4, Part E, 7.8.99]
+type LECreateCIS struct {
+	CIS []CISConnection
+}
+
+func (c *LECreateCIS) String() string {
+	return "LE Create CIS (0x08|0x0064)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LECreateCIS) OpCode() int { return 0x08<<10 | 0x0064 }
+
+// Len returns the length of the command.
+func (c *LECreateCIS) Len() int { return 1 + 4*len(c.CIS) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LECreateCIS) Marshal(b []byte) error {
+	return marshalParams(b, uint8(len(c.CIS)), c.CIS)
+}
+
+// LERemoveCIG implements LE Remove CIG (0x08|0x0065) [Vol 4, Part E, 7.8.100]
+type LERemoveCIG struct {
+	CIGID uint8
+}
+
+func (c *LERemoveCIG) String() string {
+	return "LE Remove CIG (0x08|0x0065)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LERemoveCIG) OpCode() int { return 0x08<<10 | 0x0065 }
+
+// Len returns the length of the command.
+func (c *LERemoveCIG) Len() int { return 1 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LERemoveCIG) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LERemoveCIGRP returns the return parameter of LE Remove CIG
+type LERemoveCIGRP struct {
+	Status uint8
+	CIGID  uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LERemoveCIGRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LECreateBIG implements LE Create BIG (0x08|0x0068) [Vol 4, Part E, 7.8.103]
+type LECreateBIG struct {
+	BIGHandle           uint8
+	AdvertisingHandle   uint8
+	NumBIS              uint8
+	SDUInterval         [3]byte
+	MaxSDU              uint16
+	MaxTransportLatency uint16
+	RTN                 uint8
+	PHY                 uint8
+	Packing             uint8
+	Framing             uint8
+	Encryption          uint8
+	BroadcastCode       [16]byte
+}
+
+func (c *LECreateBIG) String() string {
+	return "LE Create BIG (0x08|0x0068)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LECreateBIG) OpCode() int { return 0x08<<10 | 0x0068 }
+
+// Len returns the length of the command.
+func (c *LECreateBIG) Len() int { return 31 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LECreateBIG) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LETerminateBIG implements LE Terminate BIG (0x08|0x006A) [Vol 4, Part E, 7.8.105]
+type LETerminateBIG struct {
+	BIGHandle uint8
+	Reason    uint8
+}
+
+func (c *LETerminateBIG) String() string {
+	return "LE Terminate BIG (0x08|0x006A)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LETerminateBIG) OpCode() int { return 0x08<<10 | 0x006A }
+
+// Len returns the length of the command.
+func (c *LETerminateBIG) Len() int { return 2 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LETerminateBIG) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEBIGCreateSync implements LE BIG Create Sync (0x08|0x006B) [Vol 4, Part E, 7.8.106]
+type LEBIGCreateSync struct {
+	BIGHandle      uint8
+	SyncHandle     uint16
+	Encryption     uint8
+	BroadcastCode  [16]byte
+	MSE            uint8
+	BIGSyncTimeout uint16
+	BIS            []uint8 // Indices of the BISes, from 1.
+}
+
+func (c *LEBIGCreateSync) String() string {
+	return "LE BIG Create Sync (0x08|0x006B)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEBIGCreateSync) OpCode() int { return 0x08<<10 | 0x006B }
+
+// Len returns the length of the command.
+func (c *LEBIGCreateSync) Len() int { return 24 + len(c.BIS) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEBIGCreateSync) Marshal(b []byte) error {
+	p := struct {
+		BIGHandle      uint8
+		SyncHandle     uint16
+		Encryption     uint8
+		BroadcastCode  [16]byte
+		MSE            uint8
+		BIGSyncTimeout uint16
+		NumBIS         uint8
+	}{c.BIGHandle, c.SyncHandle, c.Encryption, c.BroadcastCode, c.MSE, c.BIGSyncTimeout, uint8(len(c.BIS))}
+	return marshalParams(b, &p, c.BIS)
+}
+
+// LEBIGTerminateSync implements LE BIG Terminate Sync (0x08|0x006C) [Vol 4, Part E, 7.8.107]
+type LEBIGTerminateSync struct {
+	BIGHandle uint8
+}
+
+func (c *LEBIGTerminateSync) String() string {
+	return "LE BIG Terminate Sync (0x08|0x006C)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEBIGTerminateSync) OpCode() int { return 0x08<<10 | 0x006C }
+
+// Len returns the length of the command.
+func (c *LEBIGTerminateSync) Len() int { return 1 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEBIGTerminateSync) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEBIGTerminateSyncRP returns the return parameter of LE BIG Terminate Sync
+type LEBIGTerminateSyncRP struct {
+	Status    uint8
+	BIGHandle uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LEBIGTerminateSyncRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// Directions of the ISO data paths.
+const (
+	ISODataPathInput  = 0x00 // Host to Controller
+	ISODataPathOutput = 0x01 // Controller to Host
+)
+
+// LESetupISODataPath implements LE Setup ISO Data Path (0x08|0x006E) [Vol 4, Part E, 7.8.109]
+// The data path of the HCI carries the SDUs as they are, so the codec
+// configuration is always empty.
+type LESetupISODataPath struct {
+	ConnectionHandle         uint16
+	DataPathDirection        uint8
+	DataPathID               uint8
+	CodecID                  [5]byte
+	ControllerDelay          [3]byte
+	CodecConfigurationLength uint8
+}
+
+func (c *LESetupISODataPath) String() string {
+	return "LE Setup ISO Data Path (0x08|0x006E)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetupISODataPath) OpCode() int { return 0x08<<10 | 0x006E }
+
+// Len returns the length of the command.
+func (c *LESetupISODataPath) Len() int { return 13 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetupISODataPath) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetupISODataPathRP returns the return parameter of LE Setup ISO Data Path
+type LESetupISODataPathRP struct {
+	Status           uint8
+	ConnectionHandle uint16
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetupISODataPathRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetPeriodicAdvertisingParameters implements LE Set Periodic Advertising Parameters (0x08|0x003E) [Vol 4, Part E, 7.8.61]
+type LESetPeriodicAdvertisingParameters struct {
+	AdvertisingHandle              uint8
+	PeriodicAdvertisingIntervalMin uint16
+	PeriodicAdvertisingIntervalMax uint16
+	PeriodicAdvertisingProperties  uint16
+}
+
+func (c *LESetPeriodicAdvertisingParameters) String() string {
+	return "LE Set Periodic Advertising Parameters (0x08|0x003E)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetPeriodicAdvertisingParameters) OpCode() int { return 0x08<<10 | 0x003E }
+
+// Len returns the length of the command.
+func (c *LESetPeriodicAdvertisingParameters) Len() int { return 7 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetPeriodicAdvertisingParameters) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetPeriodicAdvertisingEnable implements LE Set Periodic Advertising Enable (0x08|0x0040) [Vol 4, Part E, 7.8.63]
+type LESetPeriodicAdvertisingEnable struct {
+	Enable            uint8
+	AdvertisingHandle uint8
+}
+
+func (c *LESetPeriodicAdvertisingEnable) String() string {
+	return "LE Set Periodic Advertising Enable (0x08|0x0040)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetPeriodicAdvertisingEnable) OpCode() int { return 0x08<<10 | 0x0040 }
+
+// Len returns the length of the command.
+func (c *LESetPeriodicAdvertisingEnable) Len() int { return 2 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetPeriodicAdvertisingEnable) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEPeriodicAdvertisingCreateSync implements LE Periodic Advertising Create Sync (0x08|0x0044) [Vol 4, Part E, 7.8.67]
+type LEPeriodicAdvertisingCreateSync struct {
+	Options               uint8
+	AdvertisingSID        uint8
+	AdvertiserAddressType uint8
+	AdvertiserAddress     [6]byte
+	Skip                  uint16
+	SyncTimeout           uint16
+	SyncCTEType           uint8
+}
+
+func (c *LEPeriodicAdvertisingCreateSync) String() string {
+	return "LE Periodic Advertising Create Sync (0x08|0x0044)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEPeriodicAdvertisingCreateSync) OpCode() int { return 0x08<<10 | 0x0044 }
+
+// Len returns the length of the command.
+func (c *LEPeriodicAdvertisingCreateSync) Len() int { return 14 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEPeriodicAdvertisingCreateSync) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEPeriodicAdvertisingCreateSyncCancel implements LE Periodic Advertising Create Sync Cancel (0x08|0x0045) [Vol 4, Part E, 7.8.68]
+type LEPeriodicAdvertisingCreateSyncCancel struct{}
+
+func (c *LEPeriodicAdvertisingCreateSyncCancel) String() string {
+	return "LE Periodic Advertising Create Sync Cancel (0x08|0x0045)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEPeriodicAdvertisingCreateSyncCancel) OpCode() int { return 0x08<<10 | 0x0045 }
+
+// Len returns the length of the command.
+func (c *LEPeriodicAdvertisingCreateSyncCancel) Len() int { return 0 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEPeriodicAdvertisingCreateSyncCancel) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEPeriodicAdvertisingTerminateSync implements LE Periodic Advertising Terminate Sync (0x08|0x0046) [Vol 4, Part E, 7.8.69]
+type LEPeriodicAdvertisingTerminateSync struct {
+	SyncHandle uint16
+}
+
+func (c *LEPeriodicAdvertisingTerminateSync) String() string {
+	return "LE Periodic Advertising Terminate Sync (0x08|0x0046)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEPeriodicAdvertisingTerminateSync) OpCode() int { return 0x08<<10 | 0x0046 }
+
+// Len returns the length of the command.
+func (c *LEPeriodicAdvertisingTerminateSync) Len() int { return 2 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEPeriodicAdvertisingTerminateSync) Marshal(b []byte) error {
+	return marshal(c, b)
+}
@@ -0,0 +1,65 @@
+package cmd
+
+// The PHY commands of Bluetooth 5 aren't generated along with cmd_gen.go.
+
+// Bits of the All_PHYs, which leave the PHYs to the controller.
+const (
+	AllPHYsNoTxPreference = 0x01
+	AllPHYsNoRxPreference = 0x02
+)
+
+// LESetDefaultPHY implements LE Set Default PHY (0x08|0x0031) [Vol 2, Part E, 7.8.48]
+type LESetDefaultPHY struct {
+	AllPHYs uint8
+	TxPHYs  uint8
+	RxPHYs  uint8
+}
+
+func (c *LESetDefaultPHY) String() string {
+	return "LE Set Default PHY (0x08|0x0031)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetDefaultPHY) OpCode() int { return 0x08<<10 | 0x0031 }
+
+// Len returns the length of the command.
+func (c *LESetDefaultPHY) Len() int { return 3 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetDefaultPHY) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LESetDefaultPHYRP returns the return parameter of LE Set Default PHY
+type LESetDefaultPHYRP struct {
+	Status uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LESetDefaultPHYRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// LESetPHY implements LE Set PHY (0x08|0x0032) [Vol 2, Part E, 7.8.49]
+type LESetPHY struct {
+	ConnectionHandle uint16
+	AllPHYs          uint8
+	TxPHYs           uint8
+	RxPHYs           uint8
+	PHYOptions       uint16
+}
+
+func (c *LESetPHY) String() string {
+	return "LE Set PHY (0x08|0x0032)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LESetPHY) OpCode() int { return 0x08<<10 | 0x0032 }
+
+// Len returns the length of the command.
+func (c *LESetPHY) Len() int { return 7 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LESetPHY) Marshal(b []byte) error {
+	return marshal(c, b)
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// The Android vendor specific commands, which some controllers implement,
+// e.g. to filter advertisements with the Advertising Packet Content Filter
+// (APCF). The APCF commands share an opcode, and are told apart by a sub
+// opcode, so they're not generated along with cmd_gen.go.
+
+// LEGetVendorCapabilities implements LE Get Vendor Capabilities (0x3F|0x0153)
+type LEGetVendorCapabilities struct{}
+
+func (c *LEGetVendorCapabilities) String() string {
+	return "LE Get Vendor Capabilities (0x3F|0x0153)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEGetVendorCapabilities) OpCode() int { return 0x3F<<10 | 0x0153 }
+
+// Len returns the length of the command.
+func (c *LEGetVendorCapabilities) Len() int { return 0 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEGetVendorCapabilities) Marshal(b []byte) error {
+	return marshal(c, b)
+}
+
+// LEGetVendorCapabilitiesRP returns the return parameter of LE Get Vendor
+// Capabilities. Later versions append fields, which are ignored.
+type LEGetVendorCapabilitiesRP struct {
+	Status                  uint8
+	MaxAdvtInstances        uint8
+	OffloadedRPA            uint8
+	TotalScanResultsStorage uint16
+	MaxIRKListSize          uint8
+	FilteringSupport        uint8
+	MaxFilter               uint8
+	EnergyInfoSupport       uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LEGetVendorCapabilitiesRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
+
+// Sub opcodes of the APCF commands.
+const (
+	APCFEnableOp              = 0x00
+	APCFFilteringParametersOp = 0x01
+	APCFBroadcasterAddressOp  = 0x02
+	APCFServiceUUIDOp         = 0x03
+)
+
+// Actions of the APCF commands, which set filters.
+const (
+	APCFAdd    = 0x00
+	APCFDelete = 0x01
+	APCFClear  = 0x02
+)
+
+// Features of APCF filters.
+const (
+	APCFFeatureBroadcasterAddress = 0x0001
+	APCFFeatureServiceUUID        = 0x0004
+)
+
+// apcfOpCode is the opcode of the APCF commands.
+const apcfOpCode = 0x3F<<10 | 0x0157
+
+// marshalAPCF serializes the sub opcode, followed by the parameters p.
+func marshalAPCF(b []byte, sub uint8, p interface{}) error {
+	buf := bytes.NewBuffer(b)
+	buf.Reset()
+	if buf.Cap() < 1+binary.Size(p) {
+		return io.ErrShortBuffer
+	}
+	buf.WriteByte(sub)
+	return binary.Write(buf, binary.LittleEndian, p)
+}
+
+// LEAPCFEnable implements LE APCF Enable (0x3F|0x0157)
+type LEAPCFEnable struct {
+	Enable uint8
+}
+
+func (c *LEAPCFEnable) String() string {
+	return "LE APCF Enable (0x3F|0x0157)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEAPCFEnable) OpCode() int { return apcfOpCode }
+
+// Len returns the length of the command.
+func (c *LEAPCFEnable) Len() int { return 2 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEAPCFEnable) Marshal(b []byte) error {
+	return marshalAPCF(b, APCFEnableOp, c)
+}
+
+// LEAPCFSetFilteringParameters implements LE APCF Set Filtering Parameters (0x3F|0x0157)
+type LEAPCFSetFilteringParameters struct {
+	Action                  uint8
+	FilterIndex             uint8
+	FeatureSelection        uint16
+	ListLogicType           uint16
+	FilterLogicType         uint8
+	RSSIHighThreshold       uint8
+	DeliveryMode            uint8
+	OnFoundTimeout          uint16
+	OnFoundTimeoutCount     uint8
+	RSSILowThreshold        uint8
+	OnLostTimeout           uint16
+	NumberOfTrackingEntries uint16
+}
+
+func (c *LEAPCFSetFilteringParameters) String() string {
+	return "LE APCF Set Filtering Parameters (0x3F|0x0157)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEAPCFSetFilteringParameters) OpCode() int { return apcfOpCode }
+
+// Len returns the length of the command.
+func (c *LEAPCFSetFilteringParameters) Len() int { return 18 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEAPCFSetFilteringParameters) Marshal(b []byte) error {
+	return marshalAPCF(b, APCFFilteringParametersOp, c)
+}
+
+// LEAPCFBroadcasterAddress implements LE APCF Broadcaster Address (0x3F|0x0157)
+type LEAPCFBroadcasterAddress struct {
+	Action      uint8
+	FilterIndex uint8
+	Address     [6]byte
+	AddressType uint8
+}
+
+func (c *LEAPCFBroadcasterAddress) String() string {
+	return "LE APCF Broadcaster Address (0x3F|0x0157)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEAPCFBroadcasterAddress) OpCode() int { return apcfOpCode }
+
+// Len returns the length of the command.
+func (c *LEAPCFBroadcasterAddress) Len() int { return 10 }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEAPCFBroadcasterAddress) Marshal(b []byte) error {
+	return marshalAPCF(b, APCFBroadcasterAddressOp, c)
+}
+
+// LEAPCFServiceUUID implements LE APCF Service UUID (0x3F|0x0157)
+type LEAPCFServiceUUID struct {
+	Action      uint8
+	FilterIndex uint8
+	UUID        []byte // 2, 4 or 16 bytes, in little endian.
+}
+
+func (c *LEAPCFServiceUUID) String() string {
+	return "LE APCF Service UUID (0x3F|0x0157)"
+}
+
+// OpCode returns the opcode of the command.
+func (c *LEAPCFServiceUUID) OpCode() int { return apcfOpCode }
+
+// Len returns the length of the command.
+func (c *LEAPCFServiceUUID) Len() int { return 3 + len(c.UUID) }
+
+// Marshal serializes the command parameters into binary form.
+func (c *LEAPCFServiceUUID) Marshal(b []byte) error {
+	if len(b) < c.Len() {
+		return io.ErrShortBuffer
+	}
+	b[0], b[1], b[2] = APCFServiceUUIDOp, c.Action, c.FilterIndex
+	copy(b[3:], c.UUID)
+	return nil
+}
+
+// LEAPCFRP returns the return parameter of the APCF commands.
+type LEAPCFRP struct {
+	Status          uint8
+	SubOpcode       uint8
+	AvailableSpaces uint8
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (c *LEAPCFRP) Unmarshal(b []byte) error {
+	return unmarshal(c, b)
+}
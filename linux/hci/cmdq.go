@@ -0,0 +1,217 @@
+package hci
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// defaultCmdTimeout is the time a command is given to complete, including
+// the time it's queued, unless SetCommandTimeout sets another for its opcode.
+// Responses are normally fast, so a timeout indicates a major problem with
+// the controller.
+const defaultCmdTimeout = 10 * time.Second
+
+// maxCmdCredits caps the Num_HCI_Command_Packets the controller reports.
+const maxCmdCredits = 16
+
+// cmdBufSize fits the packet type, the header, and the maximum length of
+// the parameters of a command packet. [Vol 2, Part E, 5.4.1]
+const cmdBufSize = 1 + 3 + 255
+
+// CommandTimeoutError is returned by Send when the controller doesn't
+// complete a command within its timeout.
+type CommandTimeoutError struct {
+	OpCode   int
+	Duration time.Duration
+
+	// Queued is set if the command wasn't even sent, as the controller gave
+	// no credit for it.
+	Queued bool
+}
+
+func (e *CommandTimeoutError) Error() string {
+	if e.Queued {
+		return fmt.Sprintf("hci: command 0x%04X not accepted by the controller in %s", e.OpCode, e.Duration)
+	}
+	return fmt.Sprintf("hci: no response to command 0x%04X in %s", e.OpCode, e.Duration)
+}
+
+// Timeout reports true, as net.Error.
+func (e *CommandTimeoutError) Timeout() bool { return true }
+
+// cmdQueue is the Host to Controller command flow control. Commands are sent
+// as long as the controller has credits for them, and queued otherwise, in
+// the order of Send. The commands sent are matched with their responses by
+// the opcode, in the order they were sent. [Vol 2, Part E, 4.4]
+type cmdQueue struct {
+	mu       sync.Mutex
+	credits  int
+	pending  []*pkt
+	sent     map[int][]*pkt
+	timeouts map[int]time.Duration
+}
+
+// SetCommandTimeout sets the time commands of the opcode are given to
+// complete. Zero restores the default of 10 seconds.
+func (h *HCI) SetCommandTimeout(opcode int, d time.Duration) {
+	h.cmdq.mu.Lock()
+	defer h.cmdq.mu.Unlock()
+	if d == 0 {
+		delete(h.cmdq.timeouts, opcode)
+		return
+	}
+	if h.cmdq.timeouts == nil {
+		h.cmdq.timeouts = make(map[int]time.Duration)
+	}
+	h.cmdq.timeouts[opcode] = d
+}
+
+// cmdTimeout returns the timeout of the commands of the opcode.
+func (h *HCI) cmdTimeout(opcode int) time.Duration {
+	h.cmdq.mu.Lock()
+	defer h.cmdq.mu.Unlock()
+	if d, ok := h.cmdq.timeouts[opcode]; ok {
+		return d
+	}
+	return defaultCmdTimeout
+}
+
+// send sends the command c, and returns the parameters of its response. It
+// gives up on the response once ctx is done, as it does once timed out.
+func (h *HCI) send(ctx context.Context, c Command) ([]byte, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	b := make([]byte, cmdBufSize)
+	b[0] = byte(pktTypeCommand) // HCI header
+	b[1] = byte(c.OpCode())
+	b[2] = byte(c.OpCode() >> 8)
+	b[3] = byte(c.Len())
+	if err := c.Marshal(b[4:]); err != nil {
+		return nil, fmt.Errorf("hci: can't marshal cmd 0x%04X: %s", c.OpCode(), err)
+	}
+	// done is buffered, so that the response of a command timed out doesn't
+	// block the event loop.
+	p := &pkt{c, b[:4+c.Len()], make(chan []byte, 1)}
+	d := h.cmdTimeout(c.OpCode())
+
+	h.cmdq.mu.Lock()
+	h.cmdq.pending = append(h.cmdq.pending, p)
+	h.flushCmds()
+	h.cmdq.mu.Unlock()
+
+	timeout := h.clock.NewTimer(d)
+	defer timeout.Stop()
+	select {
+	case b := <-p.done:
+		return b, nil
+	case <-h.done:
+		return nil, h.err
+	case <-timeout.C():
+		h.metrics.Add(ble.MetricCmdTimeouts, 1)
+	case <-ctx.Done():
+	}
+
+	h.cmdq.mu.Lock()
+	defer h.cmdq.mu.Unlock()
+	select {
+	case b := <-p.done:
+		// Completed while the timer fired, or ctx was done.
+		return b, nil
+	default:
+	}
+	if removePkt(&h.cmdq.pending, p) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, &CommandTimeoutError{OpCode: c.OpCode(), Duration: d, Queued: true}
+	}
+	// The late response, if any, is dropped as not matching any command.
+	ps := h.cmdq.sent[c.OpCode()]
+	removePkt(&ps, p)
+	h.cmdq.sent[c.OpCode()] = ps
+	if len(ps) == 0 {
+		delete(h.cmdq.sent, c.OpCode())
+	}
+	// The credit of the command may never come back. With no commands in
+	// flight, assume the controller takes one, rather than stall the queue.
+	if h.cmdq.credits == 0 && len(h.cmdq.sent) == 0 {
+		h.cmdq.credits = 1
+		h.flushCmds()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, &CommandTimeoutError{OpCode: c.OpCode(), Duration: d}
+}
+
+// flushCmds sends the commands queued, which the controller has credits for.
+// The caller holds cmdq.mu.
+func (h *HCI) flushCmds() {
+	q := &h.cmdq
+	for q.credits > 0 && len(q.pending) > 0 {
+		p := q.pending[0]
+		q.pending[0] = nil
+		q.pending = q.pending[1:]
+		q.credits--
+
+		op := p.cmd.OpCode()
+		if q.sent == nil {
+			q.sent = make(map[int][]*pkt)
+		}
+		q.sent[op] = append(q.sent[op], p)
+		h.log(ble.LogHCI).Debug("send", "cmd", p.cmd, "pkt", fmt.Sprintf("[% X]", p.b))
+		if n, err := h.skt.Write(p.b); err != nil {
+			h.close(fmt.Errorf("hci: failed to send cmd"))
+		} else if n != len(p.b) {
+			h.close(fmt.Errorf("hci: failed to send whole cmd pkt to hci socket"))
+		}
+	}
+}
+
+// setAllowedCommands sets the credits of the controller to n, as reported
+// by the Num_HCI_Command_Packets of an event, and sends the commands queued
+// for them.
+func (h *HCI) setAllowedCommands(n int) {
+	if n > maxCmdCredits {
+		n = maxCmdCredits
+	}
+	h.cmdq.mu.Lock()
+	defer h.cmdq.mu.Unlock()
+	h.cmdq.credits = n
+	h.flushCmds()
+}
+
+// completeCmd passes the response b to the oldest command of the opcode
+// sent. It reports false if there's none.
+func (h *HCI) completeCmd(opcode int, b []byte) bool {
+	h.cmdq.mu.Lock()
+	defer h.cmdq.mu.Unlock()
+	ps := h.cmdq.sent[opcode]
+	if len(ps) == 0 {
+		return false
+	}
+	p := ps[0]
+	if len(ps) == 1 {
+		delete(h.cmdq.sent, opcode)
+	} else {
+		h.cmdq.sent[opcode] = ps[1:]
+	}
+	p.done <- b
+	return true
+}
+
+// removePkt removes p from ps, and reports whether it was there.
+func removePkt(ps *[]*pkt, p *pkt) bool {
+	for i, x := range *ps {
+		if x == p {
+			*ps = append((*ps)[:i], (*ps)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// cmdSocket passes the command packets written to it to the test.
+type cmdSocket struct{ ch chan []byte }
+
+func (s cmdSocket) Read(p []byte) (int, error) { select {} }
+func (s cmdSocket) Write(p []byte) (int, error) {
+	s.ch <- append([]byte(nil), p...)
+	return len(p), nil
+}
+func (s cmdSocket) Close() error { return nil }
+
+func newCmdHCI(clk ble.Clock) (*HCI, cmdSocket) {
+	s := cmdSocket{make(chan []byte, 8)}
+	h := &HCI{
+		skt:     s,
+		done:    make(chan bool),
+		clock:   clk,
+		logger:  ble.DefaultLogger,
+		metrics: ble.NopMetrics,
+	}
+	return h, s
+}
+
+// complete returns the Command Complete of the opcode, with the credits n.
+func complete(op int, n uint8) []byte {
+	return []byte{n, byte(op), byte(op >> 8), 0x00}
+}
+
+func TestCommandCredits(t *testing.T) {
+	h, s := newCmdHCI(ble.SystemClock)
+	h.setAllowedCommands(1)
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { errs <- h.Send(&cmd.ReadBDADDR{}, nil) }()
+	}
+	for i := 0; i < 3; i++ {
+		<-s.ch
+		select {
+		case <-s.ch:
+			t.Fatal("command sent without credits")
+		case <-time.After(10 * time.Millisecond):
+		}
+		if err := h.handleCommandComplete(complete((&cmd.ReadBDADDR{}).OpCode(), 1)); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.handleCommandComplete(complete((&cmd.ReadBDADDR{}).OpCode(), 1)); err == nil {
+		t.Error("completed a command not sent")
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	clk := ble.NewFakeClock(time.Unix(0, 0))
+	h, s := newCmdHCI(clk)
+	h.SetCommandTimeout((&cmd.Reset{}).OpCode(), time.Second)
+
+	errs := make(chan error, 2)
+	go func() { errs <- h.Send(&cmd.Reset{}, nil) }()
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	if e, ok := (<-errs).(*CommandTimeoutError); !ok || !e.Queued || e.Duration != time.Second {
+		t.Fatalf("got %v, want the timeout of a queued command", e)
+	}
+
+	h.setAllowedCommands(1)
+	go func() { errs <- h.Send(&cmd.ReadBDADDR{}, nil) }()
+	<-s.ch
+	clk.BlockUntil(1)
+	clk.Advance(defaultCmdTimeout)
+	if e, ok := (<-errs).(*CommandTimeoutError); !ok || e.Queued {
+		t.Fatalf("got %v, want the timeout of a sent command", e)
+	}
+	// The credit of the command timed out is assumed back.
+	go func() { errs <- h.Send(&cmd.Reset{}, nil) }()
+	<-s.ch
+	h.handleCommandComplete(complete((&cmd.Reset{}).OpCode(), 1))
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
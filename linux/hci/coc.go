@@ -0,0 +1,502 @@
+package hci
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/kirbo/ble"
+	"github.com/pkg/errors"
+)
+
+// PSMEATT is the SPSM of Enhanced ATT bearers. [Assigned Numbers, 2.2]
+const PSMEATT uint16 = 0x0027
+
+// Parameters of the channels we accept. EATT requires both the MTU and the
+// MPS to be at least 64 bytes. [Vol 3, Part G, 5.3.1]
+const (
+	cocMTU     = ble.MaxMTU
+	cocMPS     = 247 // Fits in a single LE ACL packet of maximum data length.
+	cocCredits = 16
+
+	cocMinMTU = 64
+
+	// Dynamically allocated CIDs on LE-U. [Vol 3, Part A, 2.1]
+	cidDynamicFirst uint16 = 0x0040
+	cidDynamicLast  uint16 = 0x007F
+
+	// Maximum number of channels of a single Credit Based Connection Request.
+	maxECFCChannels = 5
+)
+
+// Results of the LE Credit Based and Credit Based Connection Response. [Vol 3, Part A, 4.23 & 4.26]
+const (
+	cocSuccess            = 0x0000
+	cocPSMNotSupported    = 0x0002
+	cocNoResources        = 0x0004
+	cocInvalidSourceCID   = 0x0009
+	cocSourceCIDAllocated = 0x000A
+	cocUnacceptableParams = 0x000B
+	cocInvalidParams      = 0x000C
+)
+
+// A CoC is an L2CAP connection-oriented channel using credit based flow
+// control. It implements ble.Conn, so that ATT can run over it as an EATT
+// bearer. [Vol 3, Part A, 10.2]
+type CoC struct {
+	conn *Conn
+	ctx  context.Context
+	psm  uint16
+
+	scid uint16 // Local CID.
+	dcid uint16 // Remote CID.
+
+	rxMTU int
+	rxMPS int
+	txMTU int
+	txMPS int
+
+	mu        sync.Mutex
+	txCredits int
+	chCredit  chan struct{}
+
+	// wmu keeps the K-frames of an SDU from interleaving with another one.
+	wmu sync.Mutex
+
+	chIn     chan []byte
+	chDone   chan struct{}
+	doneOnce sync.Once
+}
+
+// newCoC allocates a channel with a free local CID.
+func (c *Conn) newCoC(psm uint16) (*CoC, error) {
+	c.cocMu.Lock()
+	defer c.cocMu.Unlock()
+	for cid := cidDynamicFirst; cid <= cidDynamicLast; cid++ {
+		if _, ok := c.cocs[cid]; ok {
+			continue
+		}
+		ch := &CoC{
+			conn:     c,
+			ctx:      c.ctx,
+			psm:      psm,
+			scid:     cid,
+			rxMTU:    cocMTU,
+			rxMPS:    cocMPS,
+			chCredit: make(chan struct{}, 1),
+			chIn:     make(chan []byte, cocCredits),
+			chDone:   make(chan struct{}),
+		}
+		c.cocs[cid] = ch
+		return ch, nil
+	}
+	return nil, errors.New("no free CID")
+}
+
+// cocByRemoteCID returns the channel connected to the remote CID, if any.
+func (c *Conn) cocByRemoteCID(cid uint16) *CoC {
+	c.cocMu.Lock()
+	defer c.cocMu.Unlock()
+	for _, ch := range c.cocs {
+		if ch.dcid == cid {
+			return ch
+		}
+	}
+	return nil
+}
+
+func (c *Conn) coc(cid uint16) *CoC {
+	c.cocMu.Lock()
+	defer c.cocMu.Unlock()
+	return c.cocs[cid]
+}
+
+// open completes the setup of the channel with the parameters of the remote device.
+func (ch *CoC) open(dcid, mtu, mps, credits uint16) {
+	ch.conn.cocMu.Lock()
+	ch.dcid = dcid
+	ch.txMTU = int(mtu)
+	ch.txMPS = int(mps)
+	ch.conn.cocMu.Unlock()
+	ch.addCredits(int(credits))
+	go func() {
+		select {
+		case <-ch.conn.chDone:
+			ch.closeLocal()
+		case <-ch.chDone:
+		}
+	}()
+}
+
+// closeLocal releases the channel without signaling the remote device.
+func (ch *CoC) closeLocal() {
+	ch.doneOnce.Do(func() {
+		ch.conn.cocMu.Lock()
+		delete(ch.conn.cocs, ch.scid)
+		ch.conn.cocMu.Unlock()
+		close(ch.chDone)
+	})
+}
+
+// DialCoC opens a channel to the LE_PSM of the remote device, using LE Credit
+// Based Flow Control Mode. [Vol 3, Part A, 4.22]
+func (c *Conn) DialCoC(psm uint16) (*CoC, error) {
+	return c.DialCoCContext(context.Background(), psm)
+}
+
+// DialCoCContext is DialCoC, which gives up waiting for the remote device to
+// accept the channel once ctx is done.
+func (c *Conn) DialCoCContext(ctx context.Context, psm uint16) (*CoC, error) {
+	ch, err := c.newCoC(psm)
+	if err != nil {
+		return nil, err
+	}
+	var rsp LECreditBasedConnectionResponse
+	err = c.SignalContext(ctx, &LECreditBasedConnectionRequest{
+		LEPSM:          psm,
+		SourceCID:      ch.scid,
+		MTU:            uint16(ch.rxMTU),
+		MPS:            uint16(ch.rxMPS),
+		InitialCredits: cocCredits,
+	}, &rsp)
+	switch {
+	case err != nil:
+	case rsp.Result != cocSuccess:
+		err = fmt.Errorf("connection refused (result 0x%04X)", rsp.Result)
+	case rsp.DestinationCID < cidDynamicFirst:
+		err = fmt.Errorf("invalid destination CID 0x%04X", rsp.DestinationCID)
+	}
+	if err != nil {
+		ch.closeLocal()
+		return nil, errors.Wrap(err, "can't dial CoC")
+	}
+	ch.open(rsp.DestinationCID, rsp.MTU, rsp.MPS, rsp.InitialCreditsCID)
+	return ch, nil
+}
+
+// dialECFC opens up to n channels to the SPSM of the remote device, using
+// Enhanced Credit Based Flow Control Mode. The remote device may accept fewer
+// channels than requested. [Vol 3, Part A, 4.25]
+func (c *Conn) dialECFC(psm uint16, n int) ([]*CoC, error) {
+	if n < 1 || n > maxECFCChannels {
+		return nil, fmt.Errorf("can open 1 to %d channels at a time", maxECFCChannels)
+	}
+	req := &CreditBasedConnectionRequest{
+		SPSM:           psm,
+		MTU:            cocMTU,
+		MPS:            cocMPS,
+		InitialCredits: cocCredits,
+	}
+	var chs []*CoC
+	for i := 0; i < n; i++ {
+		ch, err := c.newCoC(psm)
+		if err != nil {
+			break
+		}
+		chs = append(chs, ch)
+		req.SourceCIDs = append(req.SourceCIDs, ch.scid)
+	}
+	if len(chs) == 0 {
+		return nil, errors.New("no free CID")
+	}
+
+	var rsp CreditBasedConnectionResponse
+	if err := c.Signal(req, &rsp); err != nil {
+		for _, ch := range chs {
+			ch.closeLocal()
+		}
+		return nil, errors.Wrap(err, "can't dial CoC")
+	}
+	var opened []*CoC
+	for i, ch := range chs {
+		if i >= len(rsp.DestinationCIDs) || rsp.DestinationCIDs[i] < cidDynamicFirst {
+			ch.closeLocal()
+			continue
+		}
+		ch.open(rsp.DestinationCIDs[i], rsp.MTU, rsp.MPS, rsp.InitialCredits)
+		opened = append(opened, ch)
+	}
+	if len(opened) == 0 {
+		return nil, fmt.Errorf("connection refused (result 0x%04X)", rsp.Result)
+	}
+	return opened, nil
+}
+
+// DialEATT opens up to n EATT bearers to the remote device, which must support
+// EATT as a server. It returns the bearers the remote device accepted.
+// [Vol 3, Part G, 5.4]
+func (c *Conn) DialEATT(n int) ([]ble.Conn, error) {
+	chs, err := c.dialECFC(PSMEATT, n)
+	if err != nil {
+		return nil, err
+	}
+	bearers := make([]ble.Conn, len(chs))
+	for i, ch := range chs {
+		bearers[i] = ch
+	}
+	return bearers, nil
+}
+
+// AcceptEATT returns the next EATT bearer opened by the remote device.
+func (c *Conn) AcceptEATT() (ble.Conn, error) {
+	select {
+	case ch := <-c.chEATT:
+		return ch, nil
+	case <-c.chDone:
+		return nil, io.EOF
+	}
+}
+
+// deliver queues an incoming K-frame. The remote device may only send as many
+// frames as the credits we granted, so a full queue is a protocol violation.
+func (ch *CoC) deliver(f []byte) {
+	if len(f) > ch.rxMPS {
+		ch.conn.hci.log(ble.LogL2CAP).Warn("K-frame exceeds MPS", "cid", fmt.Sprintf("0x%04X", ch.scid))
+		go ch.Close()
+		return
+	}
+	select {
+	case ch.chIn <- append([]byte(nil), f...):
+	default:
+		ch.conn.hci.log(ble.LogL2CAP).Warn("K-frame without credit", "cid", fmt.Sprintf("0x%04X", ch.scid))
+		go ch.Close()
+	}
+}
+
+func (ch *CoC) readFrame() ([]byte, error) {
+	select {
+	case f := <-ch.chIn:
+		return f, nil
+	case <-ch.chDone:
+		return nil, errors.Wrap(io.ErrClosedPipe, "channel closed")
+	}
+}
+
+// Read re-assembles an SDU from K-frames into sdu, and grants the consumed
+// credits back to the remote device. [Vol 3, Part A, 3.4.3]
+func (ch *CoC) Read(sdu []byte) (int, error) {
+	f, err := ch.readFrame()
+	if err != nil {
+		return 0, err
+	}
+	if len(f) < 2 {
+		return 0, errors.Wrap(io.ErrUnexpectedEOF, "received short K-frame")
+	}
+	slen := int(binary.LittleEndian.Uint16(f))
+	if slen > ch.rxMTU || cap(sdu) < slen {
+		return 0, errors.Wrapf(io.ErrShortBuffer, "payload received exceeds sdu buffer")
+	}
+	buf := bytes.NewBuffer(sdu)
+	buf.Reset()
+	buf.Write(f[2:])
+	frames := 1
+	for buf.Len() < slen {
+		if f, err = ch.readFrame(); err != nil {
+			return 0, err
+		}
+		if buf.Len()+len(f) > slen {
+			return 0, errors.New("K-frames exceed the SDU length")
+		}
+		buf.Write(f)
+		frames++
+	}
+	ch.conn.sendSignal(SignalLEFlowControlCredit, &LEFlowControlCredit{
+		CID:     ch.scid,
+		Credits: uint16(frames),
+	})
+	return slen, nil
+}
+
+// Write segments an SDU into K-frames, each of which consumes a credit. [Vol 3, Part A, 7.3.2]
+func (ch *CoC) Write(sdu []byte) (int, error) {
+	if len(sdu) > ch.txMTU {
+		return 0, errors.Wrap(io.ErrShortWrite, "payload exceeds mtu")
+	}
+	ch.wmu.Lock()
+	defer ch.wmu.Unlock()
+
+	seg := make([]byte, 2+len(sdu))
+	binary.LittleEndian.PutUint16(seg, uint16(len(sdu)))
+	copy(seg[2:], sdu)
+	for len(seg) > 0 {
+		flen := len(seg)
+		if flen > ch.txMPS {
+			flen = ch.txMPS
+		}
+		if err := ch.waitCredit(); err != nil {
+			return 0, err
+		}
+		b := make([]byte, 4+flen)
+		binary.LittleEndian.PutUint16(b[0:2], uint16(flen))
+		binary.LittleEndian.PutUint16(b[2:4], ch.dcid)
+		copy(b[4:], seg[:flen])
+		if _, err := ch.conn.writePDU(b); err != nil {
+			return 0, err
+		}
+		seg = seg[flen:]
+	}
+	return len(sdu), nil
+}
+
+func (ch *CoC) waitCredit() error {
+	for {
+		ch.mu.Lock()
+		if ch.txCredits > 0 {
+			ch.txCredits--
+			ch.mu.Unlock()
+			return nil
+		}
+		ch.mu.Unlock()
+		select {
+		case <-ch.chCredit:
+		case <-ch.chDone:
+			return io.ErrClosedPipe
+		}
+	}
+}
+
+func (ch *CoC) addCredits(n int) {
+	ch.mu.Lock()
+	ch.txCredits += n
+	ch.mu.Unlock()
+	select {
+	case ch.chCredit <- struct{}{}:
+	default:
+	}
+}
+
+// Close disconnects the channel. [Vol 3, Part A, 4.6]
+func (ch *CoC) Close() error {
+	select {
+	case <-ch.chDone:
+		return nil
+	default:
+	}
+	err := ch.conn.Signal(&DisconnectRequest{
+		DestinationCID: ch.dcid,
+		SourceCID:      ch.scid,
+	}, &DisconnectResponse{})
+	ch.closeLocal()
+	return err
+}
+
+// PSM returns the PSM the channel is connected to.
+func (ch *CoC) PSM() uint16 { return ch.psm }
+
+// Context returns the context that is used by this channel.
+func (ch *CoC) Context() context.Context { return ch.ctx }
+
+// SetContext sets the context that is used by this channel.
+func (ch *CoC) SetContext(ctx context.Context) { ch.ctx = ctx }
+
+// LocalAddr returns local device's MAC address.
+func (ch *CoC) LocalAddr() ble.Addr { return ch.conn.LocalAddr() }
+
+// RemoteAddr returns remote device's MAC address.
+func (ch *CoC) RemoteAddr() ble.Addr { return ch.conn.RemoteAddr() }
+
+// RxMTU returns the MTU which the local device is capable of accepting.
+func (ch *CoC) RxMTU() int { return ch.rxMTU }
+
+// SetRxMTU is a no-op, since the MTUs of a channel are set when it's opened.
+func (ch *CoC) SetRxMTU(mtu int) {}
+
+// TxMTU returns the MTU which the remote device is capable of accepting.
+func (ch *CoC) TxMTU() int { return ch.txMTU }
+
+// SetTxMTU is a no-op, since the MTUs of a channel are set when it's opened.
+func (ch *CoC) SetTxMTU(mtu int) {}
+
+// Disconnected returns a receiving channel, which is closed when the channel disconnects.
+func (ch *CoC) Disconnected() <-chan struct{} { return ch.chDone }
+
+// SignalCreditBasedConnectionRequest is the code of Credit Based Connection Request signaling packet.
+const SignalCreditBasedConnectionRequest = 0x17
+
+// CreditBasedConnectionRequest implements Credit Based Connection Request (0x17) [Vol 3, Part A, 4.25].
+type CreditBasedConnectionRequest struct {
+	SPSM           uint16
+	MTU            uint16
+	MPS            uint16
+	InitialCredits uint16
+	SourceCIDs     []uint16
+}
+
+// Code returns the event code of the command.
+func (s CreditBasedConnectionRequest) Code() int { return 0x17 }
+
+// Marshal serializes the command parameters into binary form.
+func (s *CreditBasedConnectionRequest) Marshal() ([]byte, error) {
+	b := make([]byte, 8+2*len(s.SourceCIDs))
+	binary.LittleEndian.PutUint16(b[0:], s.SPSM)
+	binary.LittleEndian.PutUint16(b[2:], s.MTU)
+	binary.LittleEndian.PutUint16(b[4:], s.MPS)
+	binary.LittleEndian.PutUint16(b[6:], s.InitialCredits)
+	for i, cid := range s.SourceCIDs {
+		binary.LittleEndian.PutUint16(b[8+2*i:], cid)
+	}
+	return b, nil
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (s *CreditBasedConnectionRequest) Unmarshal(b []byte) error {
+	if len(b) < 10 || len(b)%2 != 0 || len(b) > 8+2*maxECFCChannels {
+		return errors.New("invalid credit based connection request")
+	}
+	s.SPSM = binary.LittleEndian.Uint16(b[0:])
+	s.MTU = binary.LittleEndian.Uint16(b[2:])
+	s.MPS = binary.LittleEndian.Uint16(b[4:])
+	s.InitialCredits = binary.LittleEndian.Uint16(b[6:])
+	s.SourceCIDs = nil
+	for b = b[8:]; len(b) > 0; b = b[2:] {
+		s.SourceCIDs = append(s.SourceCIDs, binary.LittleEndian.Uint16(b))
+	}
+	return nil
+}
+
+// SignalCreditBasedConnectionResponse is the code of Credit Based Connection Response signaling packet.
+const SignalCreditBasedConnectionResponse = 0x18
+
+// CreditBasedConnectionResponse implements Credit Based Connection Response (0x18) [Vol 3, Part A, 4.26].
+type CreditBasedConnectionResponse struct {
+	MTU             uint16
+	MPS             uint16
+	InitialCredits  uint16
+	Result          uint16
+	DestinationCIDs []uint16
+}
+
+// Code returns the event code of the command.
+func (s CreditBasedConnectionResponse) Code() int { return 0x18 }
+
+// Marshal serializes the command parameters into binary form.
+func (s *CreditBasedConnectionResponse) Marshal() ([]byte, error) {
+	b := make([]byte, 8+2*len(s.DestinationCIDs))
+	binary.LittleEndian.PutUint16(b[0:], s.MTU)
+	binary.LittleEndian.PutUint16(b[2:], s.MPS)
+	binary.LittleEndian.PutUint16(b[4:], s.InitialCredits)
+	binary.LittleEndian.PutUint16(b[6:], s.Result)
+	for i, cid := range s.DestinationCIDs {
+		binary.LittleEndian.PutUint16(b[8+2*i:], cid)
+	}
+	return b, nil
+}
+
+// Unmarshal de-serializes the binary data and stores the result in the receiver.
+func (s *CreditBasedConnectionResponse) Unmarshal(b []byte) error {
+	if len(b) < 8 || len(b)%2 != 0 {
+		return errors.New("invalid credit based connection response")
+	}
+	s.MTU = binary.LittleEndian.Uint16(b[0:])
+	s.MPS = binary.LittleEndian.Uint16(b[2:])
+	s.InitialCredits = binary.LittleEndian.Uint16(b[4:])
+	s.Result = binary.LittleEndian.Uint16(b[6:])
+	s.DestinationCIDs = nil
+	for b = b[8:]; len(b) > 0; b = b[2:] {
+		s.DestinationCIDs = append(s.DestinationCIDs, binary.LittleEndian.Uint16(b))
+	}
+	return nil
+}
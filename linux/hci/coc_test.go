@@ -0,0 +1,38 @@
+package hci
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCreditBasedConnectionRequest(t *testing.T) {
+	req := &CreditBasedConnectionRequest{
+		SPSM:           PSMEATT,
+		MTU:            0x0203,
+		MPS:            0x00F7,
+		InitialCredits: 0x0010,
+		SourceCIDs:     []uint16{0x0040, 0x0041},
+	}
+	b, err := req.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x27, 0x00, 0x03, 0x02, 0xF7, 0x00, 0x10, 0x00, 0x40, 0x00, 0x41, 0x00}
+	if !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = [% X], want [% X]", b, want)
+	}
+
+	var got CreditBasedConnectionRequest
+	if err := got.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, req) {
+		t.Errorf("Unmarshal() = %+v, want %+v", got, *req)
+	}
+
+	// A request carries 1 to 5 source CIDs.
+	if err := got.Unmarshal(want[:8]); err == nil {
+		t.Error("Unmarshal() accepted a request without source CIDs")
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
-	"net"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/att"
 	"github.com/kirbo/ble/linux/hci/cmd"
 	"github.com/kirbo/ble/linux/hci/evt"
 	"github.com/pkg/errors"
@@ -44,11 +47,15 @@ type Conn struct {
 	sigRxMTU int
 	sigTxMTU int
 
+	// sigMu serializes signaling requests, whose responses are passed
+	// through sigSent.
+	sigMu   sync.Mutex
 	sigSent chan []byte
 	// smpSent chan []byte
 
-	chInPkt chan packet
-	chInPDU chan pdu
+	chInPkt chan rxPacket
+	chInPDU chan rxPDU
+	held    rxPacket // Start fragment which ended the PDU before it, which was dropped.
 
 	chDone chan struct{}
 	// Host to Controller Data Flow Control pkt-based Data flow control for LE-U [Vol 2, Part E, 4.1.1]
@@ -59,16 +66,77 @@ type Conn struct {
 	// The requesting device sets this field and the responding device uses the
 	// same value in its response. Within each signalling channel a different
 	// Identifier shall be used for each successive command. [Vol 3, Part A, 4]
-	sigID uint8
+	sigID uint32
+
+	// Credit based connection-oriented channels, keyed by local CID.
+	cocMu sync.Mutex
+	cocs  map[uint16]*CoC
+
+	// chEATT passes the EATT bearers opened by the remote device to AcceptEATT.
+	chEATT chan *CoC
 
 	// leFrame is set to be true when the LE Credit based flow control is used.
 	leFrame bool
+
+	// sec is the security of the link, as of the last encryption event,
+	// which is counted by secGen.
+	secMu  sync.Mutex
+	sec    ble.Security
+	secGen int
+
+	// keyLevel is the security level of the LTK Elevate encrypted the link
+	// with, and encWait is told once the encryption it started changes.
+	keyLevel ble.SecurityLevel
+	encWait  chan error
+
+	// params are the connection parameters, as they are updated.
+	paramsMu sync.Mutex
+	params   ble.ConnParams
+
+	// info is the version and the features of the remote controller, as
+	// they are read.
+	infoMu sync.Mutex
+	info   ble.RemoteInfo
+
+	// txStamps are the packets sent, which the controller hasn't completed,
+	// in order, if the metrics observe latencies or the links are monitored.
+	txMu     sync.Mutex
+	txStamps []txStamp
+
+	// traffic counts the PDUs sent and received, as the interval tuning
+	// samples it.
+	traffic uint32
+}
+
+// A txStamp is the time a packet was sent, and whether it completes a
+// notification or an indication.
+type txStamp struct {
+	t      time.Time
+	notify bool
 }
 
+// ATT opcodes of the PDUs, whose lag is observed.
+const (
+	attHandleValueNotification = 0x1B
+	attHandleValueIndication   = 0x1D
+)
+
 func newConn(h *HCI, param evt.LEConnectionComplete) *Conn {
+	ctx := context.WithValue(context.Background(), ble.ContextKeyLogger, h.logger)
+	ctx = context.WithValue(ctx, ble.ContextKeyMetrics, h.metrics)
+	ctx = context.WithValue(ctx, ble.ContextKeyClock, h.clock)
+	if h.autoSec > ble.SecurityNone {
+		ctx = context.WithValue(ctx, ble.ContextKeyAutoSecurity, h.autoSec)
+	}
+	if h.bondStore != nil {
+		ctx = context.WithValue(ctx, ble.ContextKeyBondStore, h.bondStore)
+		if h.gattCache && !h.memLimits.NoGATTCache {
+			ctx = context.WithValue(ctx, ble.ContextKeyGATTCache, h.bondStore)
+		}
+	}
 	c := &Conn{
 		hci:   h,
-		ctx:   context.Background(),
+		ctx:   ctx,
 		param: param,
 
 		rxMTU: ble.DefaultMTU,
@@ -79,12 +147,21 @@ func newConn(h *HCI, param evt.LEConnectionComplete) *Conn {
 		sigRxMTU: ble.MaxMTU,
 		sigTxMTU: ble.DefaultMTU,
 
-		chInPkt: make(chan packet, 16),
-		chInPDU: make(chan pdu, 16),
+		chInPkt: make(chan rxPacket, capped(connQueue, h.memLimits.ConnQueue)),
+		chInPDU: make(chan rxPDU, capped(connQueue, h.memLimits.ConnQueue)),
+
+		sigSent: make(chan []byte, 1),
+
+		cocs:   make(map[uint16]*CoC),
+		chEATT: make(chan *CoC, maxECFCChannels),
 
 		txBuffer: NewClient(h.pool),
 
 		chDone: make(chan struct{}),
+
+		sec: ble.Security{Level: ble.SecurityNone},
+
+		params: connParams(param.ConnInterval(), param.ConnLatency(), param.SupervisionTimeout()),
 	}
 
 	go func() {
@@ -93,7 +170,7 @@ func newConn(h *HCI, param evt.LEConnectionComplete) *Conn {
 				if err != io.EOF {
 					// TODO: wrap and pass the error up.
 					// err := errors.Wrap(err, "recombine failed")
-					_ = logger.Error("recombine failed: ", "err", err)
+					c.hci.log(ble.LogL2CAP).Error("recombine failed", "handle", c.param.ConnectionHandle(), "err", err)
 				}
 				close(c.chInPDU)
 				return
@@ -119,7 +196,8 @@ func (c *Conn) Read(sdu []byte) (n int, err error) {
 	if !ok {
 		return 0, errors.Wrap(io.ErrClosedPipe, "input channel closed")
 	}
-	if len(p) == 0 {
+	defer p.buf.Release()
+	if len(p.pdu) == 0 {
 		return 0, errors.Wrap(io.ErrUnexpectedEOF, "received empty packet")
 	}
 
@@ -128,8 +206,8 @@ func (c *Conn) Read(sdu []byte) (n int, err error) {
 	data := p.payload()
 	if c.leFrame {
 		// LE-Frame.
-		slen = leFrameHdr(p).slen()
-		data = leFrameHdr(p).payload()
+		slen = leFrameHdr(p.pdu).slen()
+		data = leFrameHdr(p.pdu).payload()
 	}
 	if cap(sdu) < slen {
 		return 0, errors.Wrapf(io.ErrShortBuffer, "payload received exceeds sdu buffer")
@@ -140,6 +218,7 @@ func (c *Conn) Read(sdu []byte) (n int, err error) {
 	for buf.Len() < slen {
 		p := <-c.chInPDU
 		buf.Write(p.payload())
+		p.buf.Release()
 	}
 	return slen, nil
 }
@@ -191,23 +270,29 @@ func (c *Conn) writePDU(pdu []byte) (int, error) {
 
 	// All L2CAP fragments associated with an L2CAP PDU shall be processed for
 	// transmission by the Controller before any other L2CAP PDU for the same
-	// logical transport shall be processed.
+	// logical transport shall be processed. The other connections share the
+	// buffers of the controller meanwhile.
 	c.txBuffer.LockPool()
 	defer c.txBuffer.UnlockPool()
 
-	// Fail immediately if the connection is already closed
-	// Check this with the pool locked to avoid race conditions
-	// with handleDisconnectionComplete
+	notify := len(pdu) > 4 && binary.LittleEndian.Uint16(pdu[2:4]) == cidLEAtt &&
+		(pdu[4] == attHandleValueNotification || pdu[4] == attHandleValueIndication)
+
+	// Fail immediately if the connection is already closed.
 	select {
 	case <-c.chDone:
 		return 0, io.ErrClosedPipe
 	default:
 	}
+	atomic.AddUint32(&c.traffic, 1)
 
 	for len(pdu) > 0 {
 		// Get a buffer from our pre-allocated and flow-controlled pool.
 		pkt := c.txBuffer.Get() // ACL pkt
-		flen := len(pdu)        // fragment length
+		if pkt == nil {
+			return sent, io.ErrClosedPipe
+		}
+		flen := len(pdu) // fragment length
 		if flen > pkt.Cap()-1-4 {
 			flen = pkt.Cap() - 1 - 4
 		}
@@ -241,6 +326,10 @@ func (c *Conn) writePDU(pdu []byte) (int, error) {
 		if _, err := c.hci.skt.Write(pkt.Bytes()); err != nil {
 			return sent, err
 		}
+		c.hci.metrics.Add(ble.MetricACLTxBytes, int64(flen))
+		if c.hci.latency != nil || c.hci.connMonitor != nil {
+			c.txSent(notify && flen == len(pdu))
+		}
 		sent += flen
 
 		flags = (pbfContinuing << 4) // Set "continuing" in the boundary flags for the rest of fragments, if any.
@@ -249,46 +338,126 @@ func (c *Conn) writePDU(pdu []byte) (int, error) {
 	return sent, nil
 }
 
+// txSent stamps a packet sent. notify is set for the last fragment of a
+// notification or an indication.
+func (c *Conn) txSent(notify bool) {
+	c.txMu.Lock()
+	c.txStamps = append(c.txStamps, txStamp{t: c.hci.clock.Now(), notify: notify})
+	c.txMu.Unlock()
+}
+
+// txCompleted observes the lag of the oldest packet sent, which the
+// controller has completed, if it completes a notification.
+func (c *Conn) txCompleted() {
+	c.txMu.Lock()
+	if len(c.txStamps) == 0 {
+		c.txMu.Unlock()
+		return
+	}
+	s := c.txStamps[0]
+	c.txStamps = c.txStamps[1:]
+	c.txMu.Unlock()
+	if s.notify && c.hci.latency != nil {
+		c.hci.latency.ObserveLatency(ble.MetricNotificationLag, c.hci.clock.Now().Sub(s.t))
+	}
+}
+
 // Recombines fragments into a L2CAP PDU. [Vol 3, Part A, 7.2.2]
 func (c *Conn) recombine() error {
-	pkt, ok := <-c.chInPkt
+	in, ok := c.held, true
+	c.held = rxPacket{}
+	if in.packet == nil {
+		in, ok = <-c.chInPkt
+	}
 	if !ok {
 		return io.EOF
 	}
+	drop := c.hci.dropMalformed
+	if drop && (in.pbf()&pbfContinuing != 0 || len(in.data()) < 4) {
+		// A continuation of a PDU dropped, or a start which doesn't tell its
+		// length.
+		in.buf.Release()
+		c.hci.dropped(ble.LogL2CAP, "fragment", in.packet, "no PDU header")
+		return nil
+	}
 
-	p := pdu(pkt.data())
+	p := rxPDU{pdu(in.data()), in.buf}
 
 	// Currently, check for LE-U only. For channels that we don't recognizes,
 	// re-combine them anyway, and discard them later when we dispatch the PDU
 	// according to CID.
 	if p.cid() == cidLEAtt && p.dlen() > c.rxMPS {
-		return fmt.Errorf("fragment size (%d) larger than rxMPS (%d)", p.dlen(), c.rxMPS)
+		p.buf.Release()
+		err := fmt.Errorf("fragment size (%d) larger than rxMPS (%d)", p.dlen(), c.rxMPS)
+		if drop {
+			c.hci.dropped(ble.LogL2CAP, "PDU", p.pdu, err)
+			return nil
+		}
+		return err
 	}
 
 	// If this pkt is not a complete PDU, and we'll be receiving more
-	// fragments, re-allocate the whole PDU (including Header).
+	// fragments, re-allocate the whole PDU (including Header), and copy the
+	// fragments into it as they come.
 	if len(p.payload()) < p.dlen() {
-		p = make([]byte, 0, 4+p.dlen())
-		p = append(p, pdu(pkt.data())...)
+		p = newRxPDU(4 + p.dlen())
+		p.pdu = append(p.pdu, in.data()...)
+		in.buf.Release()
 	}
-	for len(p) < 4+p.dlen() {
-		if pkt, ok = <-c.chInPkt; !ok || (pkt.pbf()&pbfContinuing) == 0 {
+	for len(p.pdu) < 4+p.dlen() {
+		if in, ok = <-c.chInPkt; !ok || (in.pbf()&pbfContinuing) == 0 {
+			if ok && drop {
+				// The start of the next PDU, which is recombined next.
+				c.held = in
+				c.hci.dropped(ble.LogL2CAP, "PDU", p.pdu, "incomplete")
+				p.buf.Release()
+				return nil
+			}
+			if ok {
+				in.buf.Release()
+			}
+			p.buf.Release()
 			return io.ErrUnexpectedEOF
 		}
-		p = append(p, pdu(pkt.data())...)
+		p.pdu = append(p.pdu, in.data()...)
+		in.buf.Release()
+	}
+	atomic.AddUint32(&c.traffic, 1)
+	if drop {
+		var err error
+		switch {
+		case len(p.pdu) > 4+p.dlen():
+			err = fmt.Errorf("%d bytes over the length", len(p.pdu)-4-p.dlen())
+		case p.cid() == cidLEAtt:
+			err = att.Validate(p.payload())
+		}
+		if err != nil {
+			p.buf.Release()
+			c.hci.dropped(ble.LogL2CAP, "PDU", p.pdu, err)
+			return nil
+		}
+		defer c.hci.recoverMalformed(ble.LogL2CAP, "PDU", p.pdu)
 	}
 
-	// TODO: support dynamic or assigned channels for LE-Frames.
 	switch p.cid() {
 	case cidLEAtt:
+		// Released by Read, once copied out.
 		c.chInPDU <- p
+		return nil
 	case cidLESignal:
-		c.handleSignal(p)
+		// Not released, as the commands may be held on to.
+		c.handleSignal(p.pdu)
+		return nil
 	case cidSMP:
-		c.handleSMP(p)
+		c.handleSMP(p.pdu)
 	default:
-		logger.Info("recombine()", "unrecognized CID", fmt.Sprintf("%04X, [%X]", p.cid(), p))
+		if ch := c.coc(p.cid()); ch != nil {
+			ch.deliver(p.payload())
+			break
+		}
+		c.hci.log(ble.LogL2CAP).Info("unrecognized CID", "cid", fmt.Sprintf("0x%04X", p.cid()), "pdu", fmt.Sprintf("[% X]", p.pdu))
 	}
+	p.buf.Release()
 	return nil
 }
 
@@ -297,6 +466,38 @@ func (c *Conn) Disconnected() <-chan struct{} {
 	return c.chDone
 }
 
+// release ends the connection once the controller has disconnected it.
+func (c *Conn) release() {
+	close(c.chInPkt)
+	close(c.chDone)
+
+	// When a connection disconnects, all the sent packets and weren't acked yet
+	// will be recycled. [Vol2, Part E 4.1.1]
+	// A writePDU waiting for a buffer fails with io.ErrClosedPipe.
+	c.txBuffer.Close()
+}
+
+// Flush waits until the controller has sent the packets written so far, as
+// told by the Number Of Completed Packets events, or ctx is done.
+// [Vol 2, Part E, 7.7.19]
+func (c *Conn) Flush(ctx context.Context) error {
+	select {
+	case <-c.chDone:
+		return io.ErrClosedPipe
+	default:
+	}
+	if err := c.txBuffer.Drain(ctx); err != nil {
+		return err
+	}
+	// The buffers of a connection are put back, as it ends.
+	select {
+	case <-c.chDone:
+		return io.ErrClosedPipe
+	default:
+	}
+	return nil
+}
+
 // Close disconnects the connection by sending hci disconnect command to the device.
 func (c *Conn) Close() error {
 	select {
@@ -315,10 +516,72 @@ func (c *Conn) Close() error {
 // LocalAddr returns local device's MAC address.
 func (c *Conn) LocalAddr() ble.Addr { return c.hci.Addr() }
 
-// RemoteAddr returns remote device's MAC address.
+// RemoteAddr returns remote device's MAC address, a PublicAddress or a
+// RandomAddress.
 func (c *Conn) RemoteAddr() ble.Addr {
-	a := c.param.PeerAddress()
-	return net.HardwareAddr([]byte{a[5], a[4], a[3], a[2], a[1], a[0]})
+	return typedAddr(c.param.PeerAddress(), c.param.PeerAddressType())
+}
+
+// Security returns the security of the link. An encrypted link is reported
+// as SecurityEncrypted, since the pairing which produced the key isn't known.
+func (c *Conn) Security() ble.Security {
+	c.secMu.Lock()
+	defer c.secMu.Unlock()
+	return c.sec
+}
+
+// RemoteInfo returns the version and the features of the remote controller, as
+// far as they have been read.
+func (c *Conn) RemoteInfo() ble.RemoteInfo {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	return c.info
+}
+
+// readRemoteInfo requests the version and the features of the remote
+// controller, which are completed by events.
+func (c *Conn) readRemoteInfo() {
+	h := c.param.ConnectionHandle()
+	if err := c.hci.Send(&cmd.ReadRemoteVersionInformation{ConnectionHandle: h}, nil); err != nil {
+		c.hci.log(ble.LogConn).Warn("can't read remote version", "handle", h, "err", err)
+	}
+	if err := c.hci.Send(&cmd.LEReadRemoteUsedFeatures{ConnectionHandle: h}, nil); err != nil {
+		c.hci.log(ble.LogConn).Warn("can't read remote features", "handle", h, "err", err)
+	}
+}
+
+// updateSecurity updates the security, after the encryption event gen
+// enabled, disabled or refreshed the encryption.
+func (c *Conn) updateSecurity(gen int, encrypted bool) {
+	sec := ble.Security{Level: ble.SecurityNone}
+	if encrypted {
+		rp := cmd.ReadEncryptionKeySizeRP{}
+		if err := c.hci.Send(&cmd.ReadEncryptionKeySize{ConnectionHandle: c.param.ConnectionHandle()}, &rp); err != nil {
+			c.hci.log(ble.LogConn).Warn("can't read encryption key size", "handle", c.param.ConnectionHandle(), "err", err)
+		}
+		sec = ble.Security{Level: ble.SecurityEncrypted, KeySize: int(rp.KeySize)}
+	}
+	c.secMu.Lock()
+	defer c.secMu.Unlock()
+	if gen != c.secGen {
+		return // Superseded by a later event.
+	}
+	if encrypted && c.keyLevel > sec.Level {
+		sec.Level = c.keyLevel
+	}
+	c.sec = sec
+	if c.encWait != nil {
+		c.encWait <- nil
+		c.encWait = nil
+	}
+	c.hci.log(ble.LogConn).Info("security changed", "handle", c.param.ConnectionHandle(), "security", sec)
+	if !encrypted {
+		return
+	}
+	if err := c.hci.secPolicy.Check(sec); err != nil {
+		c.hci.log(ble.LogConn).Warn("disconnecting insecure link", "handle", c.param.ConnectionHandle(), "security", sec, "err", err)
+		go c.hci.Send(&cmd.Disconnect{ConnectionHandle: c.param.ConnectionHandle(), Reason: uint8(ErrAuth)}, nil)
+	}
 }
 
 // RxMTU returns the MTU which the upper layer is capable of accepting.
@@ -0,0 +1,144 @@
+package hci
+
+import (
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+// connParams returns the connection parameters of the units of the HCI
+// events. [Vol 2, Part E, 7.7.65.1]
+func connParams(interval, latency, timeout uint16) ble.ConnParams {
+	return ble.ConnParams{
+		Interval:           time.Duration(interval) * 1250 * time.Microsecond,
+		Latency:            int(latency),
+		SupervisionTimeout: time.Duration(timeout) * 10 * time.Millisecond,
+	}
+}
+
+// ConnParams returns the current parameters of the connection.
+func (c *Conn) ConnParams() ble.ConnParams {
+	c.paramsMu.Lock()
+	defer c.paramsMu.Unlock()
+	return c.params
+}
+
+// ReadRSSI reads the RSSI of the connection, in dBm. [Vol 2, Part E, 7.5.4]
+func (c *Conn) ReadRSSI() (int, error) {
+	rp := cmd.ReadRSSIRP{}
+	if err := c.hci.Send(&cmd.ReadRSSI{Handle: c.param.ConnectionHandle()}, &rp); err != nil {
+		return 0, err
+	}
+	return int(rp.RSSI), nil
+}
+
+// LinkQuality reads the RSSI and the channel map of the connection.
+func (c *Conn) LinkQuality() (ble.LinkQuality, error) {
+	var q ble.LinkQuality
+	var err error
+	if q.RSSI, err = c.ReadRSSI(); err != nil {
+		return q, err
+	}
+	if q.ChannelMap, err = c.ReadChannelMap(); err != nil {
+		return q, err
+	}
+	q.Channels = q.ChannelMap.Count()
+	return q, nil
+}
+
+// ReadChannelMap reads the data channels in use of the connection.
+// [Vol 2, Part E, 7.8.20]
+func (c *Conn) ReadChannelMap() (ble.ChannelMap, error) {
+	rp := cmd.LEReadChannelMapRP{}
+	if err := c.hci.Send(&cmd.LEReadChannelMap{ConnectionHandle: c.param.ConnectionHandle()}, &rp); err != nil {
+		return ble.ChannelMap{}, err
+	}
+	rp.ChannelMap[4] &= 0x1F // Channels 37 to 39 are the advertising channels.
+	return rp.ChannelMap, nil
+}
+
+// monitor polls the link quality every poll, unless it's 0, and warns of the
+// packets, which the peer hasn't acknowledged for half the supervision
+// timeout, until the connection is closed.
+func (c *Conn) monitor(m ble.ConnMonitor, poll time.Duration) {
+	tmo := c.ConnParams().SupervisionTimeout
+	if tmo < ble.MinSupervisionTimeout {
+		tmo = ble.MinSupervisionTimeout
+	}
+	check := c.hci.clock.NewTicker(tmo / 4)
+	defer check.Stop()
+	var chPoll <-chan time.Time
+	if poll > 0 {
+		t := c.hci.clock.NewTicker(poll)
+		defer t.Stop()
+		chPoll = t.C()
+	}
+
+	cm, _ := m.(ble.ChannelMapMonitor)
+	var chans ble.ChannelMap // Channel map last told to cm.
+	var warned time.Time     // Time of the packet warned of.
+	for {
+		select {
+		case <-c.chDone:
+			return
+		case <-chPoll:
+			q, err := c.LinkQuality()
+			if err != nil {
+				c.hci.log(ble.LogConn).Debug("can't read link quality", "handle", c.param.ConnectionHandle(), "err", err)
+				continue
+			}
+			m.LinkQuality(c, q)
+			if cm != nil && q.ChannelMap != chans {
+				chans = q.ChannelMap
+				cm.ChannelMapChanged(c, chans)
+			}
+		case <-check.C():
+			c.txMu.Lock()
+			var oldest time.Time
+			if len(c.txStamps) > 0 {
+				oldest = c.txStamps[0].t
+			}
+			c.txMu.Unlock()
+			if oldest.IsZero() || oldest.Equal(warned) {
+				continue
+			}
+			if pending := c.hci.clock.Now().Sub(oldest); pending > c.ConnParams().SupervisionTimeout/2 {
+				warned = oldest
+				m.SupervisionWarning(c, pending)
+			}
+		}
+	}
+}
+
+func (h *HCI) handleLEConnectionUpdateComplete(b []byte) error {
+	e := evt.LEConnectionUpdateComplete(b)
+	h.muConns.Lock()
+	c, ok := h.conns[e.ConnectionHandle()]
+	h.muConns.Unlock()
+	if !ok || e.Status() != 0x00 {
+		return nil
+	}
+	p := connParams(e.ConnInterval(), e.ConnLatency(), e.SupervisionTimeout())
+	c.paramsMu.Lock()
+	c.params = p
+	c.paramsMu.Unlock()
+	h.log(ble.LogConn).Info("connection updated", "handle", e.ConnectionHandle(), "interval", p.Interval, "latency", p.Latency, "timeout", p.SupervisionTimeout)
+	if h.connMonitor != nil {
+		go h.connMonitor.ConnParamsUpdated(c, p)
+	}
+	return nil
+}
+
+func (h *HCI) handleLEChannelSelectionAlgorithm(b []byte) error {
+	e := evt.LEChannelSelectionAlgorithm(b)
+	h.muConns.Lock()
+	c, ok := h.conns[e.ConnectionHandle()]
+	h.muConns.Unlock()
+	if !ok || h.connMonitor == nil {
+		return nil
+	}
+	go h.connMonitor.ChannelSelection(c, int(e.ChannelSelectionAlgorithm())+1)
+	return nil
+}
@@ -6,6 +6,7 @@ const (
 	pktTypeACLData uint8 = 0x02
 	pktTypeSCOData uint8 = 0x03
 	pktTypeEvent   uint8 = 0x04
+	pktTypeISOData uint8 = 0x05
 	pktTypeVendor  uint8 = 0xFF
 )
 
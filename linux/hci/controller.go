@@ -0,0 +1,23 @@
+package hci
+
+import "github.com/kirbo/ble"
+
+// ControllerInfo returns what the controller supports, as read by Init. The
+// number of advertising sets, and the size of the accept list, are read the
+// first time they're asked for.
+func (h *HCI) ControllerInfo() ble.ControllerInfo {
+	info := ble.ControllerInfo{
+		HCIVersion:       h.version.HCIVersion,
+		LMPVersion:       h.version.LMPVersion,
+		Manufacturer:     h.version.Manufacturer,
+		LEFeatures:       ble.LEFeatures(h.leFeatures),
+		MaxAdvDataLength: h.MaxAdvertisingDataLength(),
+		ACLBufferSize:    h.bufSize,
+		ACLBufferCount:   h.bufCnt,
+		AcceptListSize:   h.acceptListSize(),
+	}
+	if h.extAdvSupported() {
+		info.AdvSets, _ = h.numAdvSets()
+	}
+	return info
+}
@@ -0,0 +1,69 @@
+package hci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestControllerInfo(t *testing.T) {
+	recs := initRecords()
+	// Encryption, Data Length Extension and LE 2M PHY.
+	recs[15] = exchange(&cmd.LEReadLocalSupportedFeatures{}, 0x00, 0x21, 0x01, 0, 0, 0, 0, 0, 0)[1]
+	recs = append(recs, exchange(&cmd.LEReadWhiteListSize{}, 0x00, 16)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	info := h.ControllerInfo()
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := ble.ControllerInfo{
+		HCIVersion:       0x09,
+		LMPVersion:       0x09,
+		Manufacturer:     0x0002,
+		LEFeatures:       ble.LEFeatureEncryption | ble.LEFeatureDataLengthExtension | ble.LEFeature2MPHY,
+		MaxAdvDataLength: adv.MaxEIRPacketLength,
+		ACLBufferSize:    27,
+		ACLBufferCount:   8,
+		AcceptListSize:   16,
+	}
+	if info != want {
+		t.Errorf("ControllerInfo() = %+v, want %+v", info, want)
+	}
+	if !info.LEFeatures.Has(ble.LEFeature2MPHY) || info.LEFeatures.Has(ble.LEFeature2MPHY|ble.LEFeatureCodedPHY) {
+		t.Errorf("features %s", info.LEFeatures)
+	}
+	if got := info.LEFeatures.String(); got != "Encryption|DataLengthExtension|2MPHY" {
+		t.Errorf("features %q", got)
+	}
+}
+
+func TestErrCommandNamed(t *testing.T) {
+	err := error(ErrDisallowed)
+	if !errors.Is(err, ble.ErrHCICommandDisallowed) || errors.Is(err, ble.ErrHCIControllerBusy) {
+		t.Errorf("%v tells as the wrong named error", err)
+	}
+	var e *ble.ErrHCI
+	if !errors.As(err, &e) || e.Error() != "HCI error 0x0C: Command Disallowed" {
+		t.Errorf("As: %v", e)
+	}
+	if got := ErrCommand(0x45).Error(); got != "Packet Too Long" {
+		t.Errorf("0x45: %q", got)
+	}
+	if got := ErrCommand(0x7F).Error(); got != "Unspecified Error" {
+		t.Errorf("0x7F: %q", got)
+	}
+}
@@ -0,0 +1,64 @@
+package hci
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// defaultDedupTTL is used when duplicate filtering is enabled without
+// configuring the host-side cache explicitly.
+const defaultDedupTTL = 10 * time.Second
+
+// dedupCache suppresses advertisements that have already been delivered
+// within ttl, remembering at most max of them, unless max is 0. It is only
+// accessed from the event loop.
+type dedupCache struct {
+	key  ble.DedupKey
+	ttl  time.Duration
+	max  int
+	seen map[string]time.Time
+}
+
+func newDedupCache(key ble.DedupKey, ttl time.Duration, max int) *dedupCache {
+	return &dedupCache{key: key, ttl: ttl, max: max, seen: make(map[string]time.Time)}
+}
+
+// dup reports whether a has been seen within ttl, and records it if not.
+// The sets of extended advertising are told apart by their SID, so that the
+// periodic reports of a set, repeating its data, are duplicates.
+func (c *dedupCache) dup(a *Advertisement, now time.Time) bool {
+	k := a.Addr().String() + string([]byte{a.EventType(), byte(a.SID())})
+	if c.key == ble.DedupByAddressAndData {
+		h := fnv.New64a()
+		h.Write(a.Data())
+		h.Write(a.ScanResponse())
+		k += string(h.Sum(nil))
+	}
+	if t, ok := c.seen[k]; ok && now.Sub(t) < c.ttl {
+		return true
+	}
+	if len(c.seen) >= capped(1024, c.max) {
+		c.expire(now)
+	}
+	if c.max > 0 {
+		// Still full of the fresh ones: forget any.
+		for k := range c.seen {
+			if len(c.seen) < c.max {
+				break
+			}
+			delete(c.seen, k)
+		}
+	}
+	c.seen[k] = now
+	return false
+}
+
+func (c *dedupCache) expire(now time.Time) {
+	for k, t := range c.seen {
+		if now.Sub(t) >= c.ttl {
+			delete(c.seen, k)
+		}
+	}
+}
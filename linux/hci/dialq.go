@@ -0,0 +1,97 @@
+package hci
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// dialQueue serializes the Dials, as only one LE Create Connection may be
+// outstanding. The Dials take their turns in the order they're queued, and
+// leave the queue, as their contexts are done.
+type dialQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiting []*dialTurn
+}
+
+// dialTurn is a Dial waiting in a dialQueue.
+type dialTurn struct {
+	peer ble.Addr
+	ch   chan struct{} // Closed on its turn.
+}
+
+// wait waits for the turn of a Dial of peer, or for ctx to be done, and
+// returns the func ending the turn.
+func (q *dialQueue) wait(ctx context.Context, peer ble.Addr) (func(), error) {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return q.next, nil
+	}
+	t := &dialTurn{peer: peer, ch: make(chan struct{})}
+	q.waiting = append(q.waiting, t)
+	q.mu.Unlock()
+
+	select {
+	case <-t.ch:
+		return q.next, nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	for i, w := range q.waiting {
+		if w == t {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.mu.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+	// The turn came meanwhile, so it's passed on.
+	q.next()
+	return nil, ctx.Err()
+}
+
+// next passes the turn to the next Dial waiting, if any.
+func (q *dialQueue) next() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) == 0 {
+		q.busy = false
+		return
+	}
+	t := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	close(t.ch)
+}
+
+// peers returns the peers of the Dials waiting, in order.
+func (q *dialQueue) peers() []ble.Addr {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var as []ble.Addr
+	for _, t := range q.waiting {
+		as = append(as, t.peer)
+	}
+	return as
+}
+
+// QueuedDials returns the peers of the Dials waiting for their turns, in
+// the order they'll be dialed.
+func (h *HCI) QueuedDials() []ble.Addr {
+	return h.dials.peers()
+}
+
+// connected reports whether the peer a is connected.
+func (h *HCI) connected(a ble.Addr) bool {
+	h.muConns.Lock()
+	defer h.muConns.Unlock()
+	for _, c := range h.conns {
+		if ble.SameAddr(c.RemoteAddr(), a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package hci
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+func TestDialQueue(t *testing.T) {
+	var q dialQueue
+	done, err := q.wait(context.Background(), ble.NewAddr("11:22:33:44:55:01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second Dial gives up, and the third takes the turn after the first.
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := q.wait(ctx, ble.NewAddr("11:22:33:44:55:02"))
+		errs <- err
+	}()
+	turns := make(chan func(), 1)
+	go func() {
+		for len(q.peers()) < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		done, err := q.wait(context.Background(), ble.NewAddr("11:22:33:44:55:03"))
+		if err != nil {
+			t.Error(err)
+		}
+		turns <- done
+	}()
+	for len(q.peers()) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	if as := q.peers(); as[0].String() != "11:22:33:44:55:02" || as[1].String() != "11:22:33:44:55:03" {
+		t.Errorf("queued %v, want in order", as)
+	}
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("wait() = %v, want context.Canceled", err)
+	}
+	select {
+	case <-turns:
+		t.Fatal("turn taken before the first Dial ended")
+	case <-time.After(10 * time.Millisecond):
+	}
+	done()
+	(<-turns)()
+	if q.busy {
+		t.Error("queue busy after all the turns ended")
+	}
+}
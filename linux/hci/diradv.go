@@ -0,0 +1,121 @@
+package hci
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kirbo/ble"
+)
+
+// Errors of AdvertiseDirected.
+var (
+	errDirAdvExt        = errors.New("directed advertising only uses legacy advertising, and extended advertising is in use")
+	errDirAdvInProgress = errors.New("directed advertising in progress")
+)
+
+// dirAdv is the wait of AdvertiseDirected for the peer to connect.
+type dirAdv struct {
+	peer [6]byte
+	ch   chan *Conn
+}
+
+// AdvertiseDirected advertises connectable, to the peer only, such as a
+// bonded central for it to reconnect quickly, until it connects, and returns
+// the connection, which is served as any accepted. The peer is addressed as a
+// random device, if it's a RandomAddress. High duty cycle advertising lasts
+// 1.28 s at most, and fails with ErrDirAdvTimeout if the peer doesn't
+// connect; low duty cycle advertising lasts until ctx is done. Advertising,
+// if any, is replaced, and not restored afterwards.
+func (h *HCI) AdvertiseDirected(ctx context.Context, peer ble.Addr, highDuty bool) (ble.Conn, error) {
+	if err := h.requirePeripheral("AdvertiseDirected"); err != nil {
+		return nil, err
+	}
+	if h.extAdv || h.advSetsUsed {
+		return nil, errDirAdvExt
+	}
+	a, err := parseAddr(peer)
+	if err != nil {
+		return nil, err
+	}
+	typ, addrType := uint8(advTypeConnDirectedLow), uint8(0x00)
+	if highDuty {
+		typ = advTypeConnDirectedHigh
+	}
+	if ble.AddrTypeOf(peer) == ble.AddrTypeRandom {
+		addrType = 0x01
+	}
+
+	w := &dirAdv{peer: a, ch: make(chan *Conn, 1)}
+	h.dirAdvMu.Lock()
+	if h.dirAdv != nil {
+		h.dirAdvMu.Unlock()
+		return nil, errDirAdvInProgress
+	}
+	h.dirAdv = w
+	h.dirAdvMu.Unlock()
+	defer func() {
+		h.dirAdvMu.Lock()
+		h.dirAdv = nil
+		h.dirAdvMu.Unlock()
+	}()
+
+	if err := h.StopAdvertising(); err != nil {
+		return nil, err
+	}
+	h.params.Lock()
+	saved := h.params.advParams
+	h.params.advParams.AdvertisingType = typ
+	h.params.advParams.DirectAddressType = addrType
+	h.params.advParams.DirectAddress = a
+	h.params.Unlock()
+	defer func() {
+		// Directed advertising ends with the connection, or the timeout,
+		// unlike the advertising restarted once a central connects.
+		h.StopAdvertising()
+		h.roleMu.Lock()
+		h.params.Lock()
+		h.params.advParams = saved
+		h.params.Unlock()
+		h.sendAdvParams()
+		h.roleMu.Unlock()
+	}()
+	h.roleMu.Lock()
+	err = h.sendAdvParams()
+	h.roleMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Advertise(); err != nil {
+		return nil, err
+	}
+
+	select {
+	case c := <-w.ch:
+		if s := c.param.Status(); s != 0x00 {
+			return nil, ErrCommand(s)
+		}
+		return c, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-h.done:
+		return nil, h.err
+	}
+}
+
+// directedConn passes the connection c, or the failure of directed
+// advertising, to AdvertiseDirected, if it's waiting for the peer of c.
+func (h *HCI) directedConn(c *Conn) {
+	h.dirAdvMu.Lock()
+	defer h.dirAdvMu.Unlock()
+	w := h.dirAdv
+	if w == nil {
+		return
+	}
+	if ErrCommand(c.param.Status()) != ErrDirAdvTimeout && (c.param.Status() != 0x00 || c.param.PeerAddress() != w.peer) {
+		return
+	}
+	select {
+	case w.ch <- c:
+	default:
+	}
+}
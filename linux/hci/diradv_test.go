@@ -0,0 +1,46 @@
+package hci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestAdvertiseDirectedTimeout(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertisingParameters{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	// The central doesn't connect within 1.28 s.
+	e := []byte{pktTypeEvent, 0x3E, 19, 0x01, byte(ErrDirAdvTimeout), 0, 0, roleSlave}
+	recs = append(recs, monitor.Record{Dir: monitor.Received, H4: append(e, make([]byte, 14)...)})
+	// The parameters are restored.
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertisingParameters{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err := h.AdvertiseDirected(context.Background(), ble.NewAddr("66:55:44:33:22:11"), true); err != ErrDirAdvTimeout {
+		t.Errorf("AdvertiseDirected() = %v, want ErrDirAdvTimeout", err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+	if h.advDirected() || h.params.advEnable.AdvertisingEnable != 0 {
+		t.Error("directed advertising not ended")
+	}
+}
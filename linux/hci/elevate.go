@@ -0,0 +1,101 @@
+package hci
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// elevateTimeout bounds the wait for the encryption, which Elevate starts, to
+// change.
+const elevateTimeout = 10 * time.Second
+
+// Elevate raises the security of the link to at least l, encrypting it with
+// the LTK bonded with the peer, in the BondStore entry ble.BondLTK, and
+// returns once the encryption has changed. Only a central starts the
+// encryption; a peripheral would need pairing, which isn't implemented.
+func (c *Conn) Elevate(l ble.SecurityLevel) (err error) {
+	if c.Security().Level >= l {
+		return nil
+	}
+	if c.param.Role() != roleMaster {
+		return ble.ErrNotImplemented
+	}
+	s := c.hci.bondStore
+	if s == nil {
+		return ErrNoLTK
+	}
+	b, lerr := s.Load(c.RemoteAddr(), ble.BondLTK)
+	if lerr != nil || len(b) < 26 {
+		return ErrNoLTK
+	}
+	level := ble.SecurityEncrypted
+	if len(b) > 26 {
+		level = ble.SecurityLevel(b[26])
+	}
+	if level < l {
+		return ErrNoLTK
+	}
+
+	ch := make(chan error, 1)
+	c.secMu.Lock()
+	prev := c.keyLevel
+	c.keyLevel, c.encWait = level, ch
+	c.secMu.Unlock()
+	defer func() {
+		c.secMu.Lock()
+		if c.encWait == ch {
+			c.encWait = nil
+		}
+		if err != nil {
+			c.keyLevel = prev
+		}
+		c.secMu.Unlock()
+	}()
+
+	se := &cmd.LEStartEncryption{
+		ConnectionHandle:     c.param.ConnectionHandle(),
+		RandomNumber:         binary.LittleEndian.Uint64(b[18:26]),
+		EncryptedDiversifier: binary.LittleEndian.Uint16(b[16:18]),
+	}
+	copy(se.LongTermKey[:], b[:16])
+	if err := c.hci.Send(se, nil); err != nil {
+		return err
+	}
+	t := c.hci.clock.NewTimer(elevateTimeout)
+	defer t.Stop()
+	select {
+	case err = <-ch:
+		if err != nil {
+			return err
+		}
+	case <-t.C():
+		return fmt.Errorf("encryption not changed in %s", elevateTimeout)
+	case <-c.Disconnected():
+		return ble.ErrDisconnected
+	}
+	if sec := c.Security(); sec.Level < l {
+		return fmt.Errorf("security %s, below %s", sec, l)
+	}
+	return nil
+}
+
+// encryptionFailed tells Elevate, waiting on the connection of handle, that
+// the encryption started failed with err.
+func (h *HCI) encryptionFailed(handle uint16, err error) {
+	h.muConns.Lock()
+	c, ok := h.conns[handle]
+	h.muConns.Unlock()
+	if !ok {
+		return
+	}
+	c.secMu.Lock()
+	defer c.secMu.Unlock()
+	if c.encWait != nil {
+		c.encWait <- err
+		c.encWait = nil
+	}
+}
@@ -0,0 +1,56 @@
+package hci
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/evt"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestElevate(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LEStartEncryption{}, 0x00)...)
+	recs = append(recs, monitor.Record{Dir: monitor.Received, H4: []byte{pktTypeEvent, 0x08, 4, 0x00, 0x40, 0x00, 0x01}})
+	recs = append(recs, exchange(&cmd.ReadEncryptionKeySize{}, 0x00, 0x40, 0x00, 16)...)
+
+	bonds := ble.NewMemoryBondStore()
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptBondStore(bonds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	param := make(evt.LEConnectionComplete, 19)
+	param[2], param[4] = 0x40, roleMaster
+	c := newConn(h, param)
+	h.muConns.Lock()
+	h.conns[0x0040] = c
+	h.muConns.Unlock()
+	cln, err := newClient(context.Background(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Elevate(ble.SecurityEncrypted); err != ErrNoLTK {
+		t.Errorf("Elevate() = %v without a bond, want ErrNoLTK", err)
+	}
+	bonds.Save(c.RemoteAddr(), ble.BondLTK, make([]byte, 26))
+	if err := c.Elevate(ble.SecurityAuthenticated); err != ErrNoLTK {
+		t.Errorf("Elevate() = %v beyond the level of the LTK, want ErrNoLTK", err)
+	}
+	if err := c.Elevate(ble.SecurityEncrypted); err != nil {
+		t.Fatal(err)
+	}
+	if sec := cln.Security(); !cln.Encrypted() || cln.SecurityLevel() != ble.SecurityEncrypted || sec.KeySize != 16 {
+		t.Errorf("security %s, want encrypted with a 16 byte key", sec)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
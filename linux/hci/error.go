@@ -1,6 +1,10 @@
 package hci
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/kirbo/ble"
+)
 
 // errors
 var (
@@ -9,6 +13,28 @@ var (
 	ErrBusyDialing     = errors.New("busy dialing")
 	ErrBusyListening   = errors.New("busy listening")
 	ErrInvalidAddr     = errors.New("invalid address")
+	ErrMaxConnections  = errors.New("maximum number of connections reached")
+
+	// ErrExtAdvNotSupported is returned when the advertising data or scan
+	// response exceed legacy advertising, and the controller doesn't
+	// support extended advertising.
+	ErrExtAdvNotSupported = errors.New("advertising data exceed legacy advertising, and the controller doesn't support extended advertising")
+
+	// ErrPublicAddressNotSupported is returned by Init when the public
+	// address is set, and there's no known vendor command to set it with.
+	ErrPublicAddressNotSupported = errors.New("no vendor command to set the public address of the controller")
+
+	// ErrNoAdvertisingSets is returned by NewAdvertisingSet when the
+	// controller supports no more advertising sets.
+	ErrNoAdvertisingSets = errors.New("no more advertising sets supported by the controller")
+
+	// ErrISONotSupported is returned when the controller doesn't support
+	// the isochronous channels asked for.
+	ErrISONotSupported = errors.New("isochronous channels not supported by the controller")
+
+	// ErrNoLTK is returned by Conn.Elevate when no LTK of the security level
+	// is bonded with the peer, as pairing isn't implemented.
+	ErrNoLTK = errors.New("no LTK of the security level bonded with the peer")
 )
 
 // HCI Command Errors  [Vol2, Part D, 1.3 ]
@@ -75,6 +101,13 @@ const (
 	ErrEstablished          ErrCommand = 0x3E // Connection Failed to be Established
 	ErrMACConn              ErrCommand = 0x3F // MAC Connection Failed
 	ErrCoarseClock          ErrCommand = 0x40 // Coarse Clock Adjustment Rejected but Will Try to Adjust Using Clock Dragging
+	ErrType0Submap          ErrCommand = 0x41 // Type0 Submap Not Defined
+	ErrUnknownAdvID         ErrCommand = 0x42 // Unknown Advertising Identifier
+	ErrLimitReached         ErrCommand = 0x43 // Limit Reached
+	ErrCancelledByHost      ErrCommand = 0x44 // Operation Cancelled by Host
+	ErrPacketTooLong        ErrCommand = 0x45 // Packet Too Long
+	ErrTooLate              ErrCommand = 0x46 // Too Late
+	ErrTooEarly             ErrCommand = 0x47 // Too Early
 	// 0x2B // Reserved
 	// 0x31 // Reserved
 	// 0x33 // Reserved
@@ -84,78 +117,35 @@ const (
 type ErrCommand byte
 
 func (e ErrCommand) Error() string {
-	if s, ok := errCmd[e]; ok {
+	if s := ble.HCIStatusText(uint8(e)); s != "" {
 		return s
 	}
 	// A Host shall consider any error code that it does not explicitly
 	// understand equivalent to the “Unspecified Error (0x1F).”
-	return errCmd[0x1F]
+	return ble.HCIStatusText(0x1F)
 }
 
-var errCmd = map[ErrCommand]string{
-	0x00: "Success",
-	0x01: "Unknown HCI Command",
-	0x02: "Unknown Connection Identifier",
-	0x03: "Hardware Failure",
-	0x04: "Page Timeou",
-	0x05: "Authentication Failure",
-	0x06: "PIN or Key Missing",
-	0x07: "Memory Capacity Exceeded",
-	0x08: "Connection Timeout",
-	0x09: "Connection Limit Exceeded",
-	0x0A: "Synchronous Connection Limit To A Device Exceeded",
-	0x0B: "ACL Connection Already Exists",
-	0x0C: "Command Disallowed",
-	0x0D: "Connection Rejected due to Limited Resources",
-	0x0E: "Connection Rejected Due To Security Reasons",
-	0x0F: "Connection Rejected due to Unacceptable BD_ADDR",
-	0x10: "Connection Accept Timeout Exceeded",
-	0x11: "Unsupported Feature or Parameter Value",
-	0x12: "Invalid HCI Command Parameters",
-	0x13: "Remote User Terminated Connection",
-	0x14: "Remote Device Terminated Connection due to Low Resources",
-	0x15: "Remote Device Terminated Connection due to Power Off",
-	0x16: "Connection Terminated By Local Host",
-	0x17: "Repeated Attempts",
-	0x18: "Pairing Not Allowed",
-	0x19: "Unknown LMP PDU",
-	0x1A: "Unsupported Remote Feature / Unsupported LMP Feature",
-	0x1B: "SCO Offset Rejected",
-	0x1C: "SCO Interval Rejected",
-	0x1D: "SCO Air Mode Rejected",
-	0x1E: "Invalid LMP Parameters / Invalid LL Parameters",
-	0x1F: "Unspecified Error",
-	0x20: "Unsupported LMP Parameter Value / Unsupported LL Parameter Value",
-	0x21: "Role Change Not Allowed",
-	0x22: "LMP Response Timeout / LL Response Timeout",
-	0x23: "LMP Error Transaction Collision",
-	0x24: "LMP PDU Not Allowed",
-	0x25: "Encryption Mode Not Acceptable",
-	0x26: "Link Key cannot be Changed",
-	0x27: "Requested QoS Not Supported",
-	0x28: "Instant Passed",
-	0x29: "Pairing With Unit Key Not Supported",
-	0x2A: "Different Transaction Collision",
-	0x2B: "Reserved",
-	0x2C: "QoS Unacceptable Parameter",
-	0x2D: "QoS Rejected",
-	0x2E: "Channel Classification Not Supported",
-	0x2F: "Insufficient Security",
-	0x30: "Parameter Out Of Mandatory Range",
-	0x31: "Reserved",
-	0x32: "Role Switch Pending",
-	0x33: "Reserved",
-	0x34: "Reserved Slot Violation",
-	0x35: "Role Switch Failed",
-	0x36: "Extended Inquiry Response Too Large",
-	0x37: "Secure Simple Pairing Not Supported By Host",
-	0x38: "Host Busy - Pairing",
-	0x39: "Connection Rejected due to No Suitable Channel Found",
-	0x3A: "Controller Busy",
-	0x3B: "Unacceptable Connection Parameters",
-	0x3C: "Directed Advertising Timeout",
-	0x3D: "Connection Terminated due to MIC Failure",
-	0x3E: "Connection Failed to be Established",
-	0x3F: "MAC Connection Failed",
-	0x40: "Coarse Clock Adjustment Rejected but Will Try to Adjust Using Clock Dragging",
+// Is tells the ble.ErrHCI of the same status.
+func (e ErrCommand) Is(target error) bool {
+	t, ok := target.(*ble.ErrHCI)
+	return ok && t.Status == uint8(e)
 }
+
+// As sets a *ble.ErrHCI target to the status.
+func (e ErrCommand) As(target interface{}) bool {
+	p, ok := target.(**ble.ErrHCI)
+	if ok {
+		*p = &ble.ErrHCI{Status: uint8(e)}
+	}
+	return ok
+}
+
+// socketError is the failure of the HCI socket, which ends the connections,
+// so it tells as ble.ErrDisconnected.
+type socketError struct {
+	err error
+}
+
+func (e *socketError) Error() string        { return "skt: " + e.err.Error() }
+func (e *socketError) Unwrap() error        { return e.err }
+func (e *socketError) Is(target error) bool { return target == ble.ErrDisconnected }
@@ -53,3 +53,68 @@ func (e LEAdvertisingReport) RSSI(i int) int8 {
 	}
 	return int8(e[2+int(e.NumReports())*9+l+i])
 }
+
+const LEExtendedAdvertisingReportCode = 0x3E
+
+const LEExtendedAdvertisingReportSubCode = 0x0D
+
+// LEExtendedAdvertisingReport implements LE Extended Advertising Report (0x3E:0x0D) [Vol 2, Part E, 7.7.65.13].
+// Unlike the legacy one, its reports are of variable length, and laid out
+// one after another.
+type LEExtendedAdvertisingReport []byte
+
+func (e LEExtendedAdvertisingReport) SubeventCode() uint8 { return e[0] }
+func (e LEExtendedAdvertisingReport) NumReports() uint8   { return e[1] }
+
+// Reports returns the reports of the event, or nil if it is malformed.
+func (e LEExtendedAdvertisingReport) Reports() []ExtendedAdvertisingReport {
+	if len(e) < 2 {
+		return nil
+	}
+	rs := make([]ExtendedAdvertisingReport, 0, e.NumReports())
+	b := e[2:]
+	for i := 0; i < int(e.NumReports()); i++ {
+		if len(b) < 24 || len(b) < 24+int(b[23]) {
+			return nil
+		}
+		n := 24 + int(b[23])
+		rs = append(rs, ExtendedAdvertisingReport(b[:n:n]))
+		b = b[n:]
+	}
+	return rs
+}
+
+// ExtendedAdvertisingReport is a report of LE Extended Advertising Report.
+type ExtendedAdvertisingReport []byte
+
+// Data Status of the Event Type of extended advertising reports.
+const (
+	DataStatusComplete   = 0x00 // The data is complete.
+	DataStatusIncomplete = 0x01 // The data is incomplete, and more is to come.
+	DataStatusTruncated  = 0x02 // The data is incomplete, and no more is to come.
+)
+
+func (r ExtendedAdvertisingReport) EventType() uint16  { return binary.LittleEndian.Uint16(r) }
+func (r ExtendedAdvertisingReport) DataStatus() uint8  { return uint8(r.EventType()>>5) & 0x03 }
+func (r ExtendedAdvertisingReport) AddressType() uint8 { return r[2] }
+func (r ExtendedAdvertisingReport) Address() [6]byte {
+	b := [6]byte{}
+	copy(b[:], r[3:])
+	return b
+}
+func (r ExtendedAdvertisingReport) PrimaryPHY() uint8     { return r[9] }
+func (r ExtendedAdvertisingReport) SecondaryPHY() uint8   { return r[10] }
+func (r ExtendedAdvertisingReport) AdvertisingSID() uint8 { return r[11] }
+func (r ExtendedAdvertisingReport) TxPower() int8         { return int8(r[12]) }
+func (r ExtendedAdvertisingReport) RSSI() int8            { return int8(r[13]) }
+func (r ExtendedAdvertisingReport) PeriodicAdvertisingInterval() uint16 {
+	return binary.LittleEndian.Uint16(r[14:])
+}
+func (r ExtendedAdvertisingReport) DirectAddressType() uint8 { return r[16] }
+func (r ExtendedAdvertisingReport) DirectAddress() [6]byte {
+	b := [6]byte{}
+	copy(b[:], r[17:])
+	return b
+}
+func (r ExtendedAdvertisingReport) DataLength() uint8 { return r[23] }
+func (r ExtendedAdvertisingReport) Data() []byte      { return r[24 : 24+int(r[23])] }
@@ -217,6 +217,40 @@ func (r LERemoteConnectionParameterRequest) Timeout() uint16 {
 	return binary.LittleEndian.Uint16(r[9:])
 }
 
+const LEAdvertisingSetTerminatedCode = 0x3E
+
+const LEAdvertisingSetTerminatedSubCode = 0x12
+
+// LEAdvertisingSetTerminated implements LE Advertising Set Terminated (0x3E:0x12) [Vol 2, Part E, 7.7.65.18].
+type LEAdvertisingSetTerminated []byte
+
+func (r LEAdvertisingSetTerminated) SubeventCode() uint8 { return r[0] }
+
+func (r LEAdvertisingSetTerminated) Status() uint8 { return r[1] }
+
+func (r LEAdvertisingSetTerminated) AdvertisingHandle() uint8 { return r[2] }
+
+func (r LEAdvertisingSetTerminated) ConnectionHandle() uint16 {
+	return binary.LittleEndian.Uint16(r[3:])
+}
+
+func (r LEAdvertisingSetTerminated) NumCompletedExtendedAdvertisingEvents() uint8 { return r[5] }
+
+const LEChannelSelectionAlgorithmCode = 0x3E
+
+const LEChannelSelectionAlgorithmSubCode = 0x14
+
+// LEChannelSelectionAlgorithm implements LE Channel Selection Algorithm (0x3E:0x14) [Vol 2, Part E, 7.7.65.20].
+type LEChannelSelectionAlgorithm []byte
+
+func (r LEChannelSelectionAlgorithm) SubeventCode() uint8 { return r[0] }
+
+func (r LEChannelSelectionAlgorithm) ConnectionHandle() uint16 {
+	return binary.LittleEndian.Uint16(r[1:])
+}
+
+func (r LEChannelSelectionAlgorithm) ChannelSelectionAlgorithm() uint8 { return r[3] }
+
 const AuthenticatedPayloadTimeoutExpiredCode = 0x57
 
 // AuthenticatedPayloadTimeoutExpired implements Authenticated Payload Timeout Expired (0x57) [Vol 2, Part E, 7.7.75].
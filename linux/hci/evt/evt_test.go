@@ -0,0 +1,41 @@
+package evt
+
+import "testing"
+
+func TestLEConnectionComplete(t *testing.T) {
+	e := LEConnectionComplete{
+		0x01, 0x00, 0x40, 0x00, 0x00, 0x01,
+		0x11, 0x22, 0x33, 0x44, 0x55, 0x66,
+		0x18, 0x00, 0x00, 0x00, 0xc8, 0x00, 0x00,
+	}
+	if e.ConnectionHandle() != 0x0040 {
+		t.Errorf("ConnectionHandle: got 0x%04x, want 0x0040", e.ConnectionHandle())
+	}
+	if e.PeerAddress() != [6]byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66} {
+		t.Errorf("PeerAddress: got % x", e.PeerAddress())
+	}
+	if e.ConnInterval() != 0x0018 || e.SupervisionTimeout() != 0x00c8 {
+		t.Errorf("ConnInterval/SupervisionTimeout: got 0x%04x/0x%04x", e.ConnInterval(), e.SupervisionTimeout())
+	}
+}
+
+func TestLEAdvertisingReport(t *testing.T) {
+	e := LEAdvertisingReport{
+		0x02, 0x01, // subevent, num reports
+		0x00,                               // event type
+		0x01,                               // address type
+		0x66, 0x55, 0x44, 0x33, 0x22, 0x11, // address
+		0x03,             // length
+		0x02, 0x01, 0x06, // data
+		0xc4, // rssi
+	}
+	if e.Address(0) != [6]byte{0x66, 0x55, 0x44, 0x33, 0x22, 0x11} {
+		t.Errorf("Address: got % x", e.Address(0))
+	}
+	if string(e.Data(0)) != "\x02\x01\x06" {
+		t.Errorf("Data: got % x", e.Data(0))
+	}
+	if e.RSSI(0) != -60 {
+		t.Errorf("RSSI: got %d, want -60", e.RSSI(0))
+	}
+}
@@ -0,0 +1,124 @@
+package evt
+
+import "encoding/binary"
+
+// The events of the isochronous channels, and of the periodic advertising
+// synchronization, which the BIGs are synchronized to.
+
+const LEPeriodicAdvertisingSyncEstablishedCode = 0x3E
+
+const LEPeriodicAdvertisingSyncEstablishedSubCode = 0x0E
+
+// LEPeriodicAdvertisingSyncEstablished implements LE Periodic Advertising Sync Established (0x3E:0x0E) [Vol 4, Part E, 7.7.65.14].
+type LEPeriodicAdvertisingSyncEstablished []byte
+
+func (r LEPeriodicAdvertisingSyncEstablished) SubeventCode() uint8 { return r[0] }
+
+func (r LEPeriodicAdvertisingSyncEstablished) Status() uint8 { return r[1] }
+
+func (r LEPeriodicAdvertisingSyncEstablished) SyncHandle() uint16 {
+	return binary.LittleEndian.Uint16(r[2:])
+}
+
+func (r LEPeriodicAdvertisingSyncEstablished) AdvertisingSID() uint8 { return r[4] }
+
+const LECISEstablishedCode = 0x3E
+
+const LECISEstablishedSubCode = 0x19
+
+// LECISEstablished implements LE CIS Established (0x3E:0x19) [Vol 4, Part E, 7.7.65.25].
+type LECISEstablished []byte
+
+func (r LECISEstablished) SubeventCode() uint8 { return r[0] }
+
+func (r LECISEstablished) Status() uint8 { return r[1] }
+
+func (r LECISEstablished) ConnectionHandle() uint16 {
+	return binary.LittleEndian.Uint16(r[2:])
+}
+
+func (r LECISEstablished) MaxPDUCToP() uint16 { return binary.LittleEndian.Uint16(r[23:]) }
+
+func (r LECISEstablished) MaxPDUPToC() uint16 { return binary.LittleEndian.Uint16(r[25:]) }
+
+func (r LECISEstablished) ISOInterval() uint16 { return binary.LittleEndian.Uint16(r[27:]) }
+
+const LECreateBIGCompleteCode = 0x3E
+
+const LECreateBIGCompleteSubCode = 0x1B
+
+// LECreateBIGComplete implements LE Create BIG Complete (0x3E:0x1B) [Vol 4, Part E, 7.7.65.27].
+type LECreateBIGComplete []byte
+
+func (r LECreateBIGComplete) SubeventCode() uint8 { return r[0] }
+
+func (r LECreateBIGComplete) Status() uint8 { return r[1] }
+
+func (r LECreateBIGComplete) BIGHandle() uint8 { return r[2] }
+
+func (r LECreateBIGComplete) MaxPDU() uint16 { return binary.LittleEndian.Uint16(r[14:]) }
+
+func (r LECreateBIGComplete) ISOInterval() uint16 { return binary.LittleEndian.Uint16(r[16:]) }
+
+// ConnectionHandles returns the handles of the BISes, or nil if the event
+// is malformed, or failed.
+func (r LECreateBIGComplete) ConnectionHandles() []uint16 { return handles(r, 18) }
+
+const LETerminateBIGCompleteCode = 0x3E
+
+const LETerminateBIGCompleteSubCode = 0x1C
+
+// LETerminateBIGComplete implements LE Terminate BIG Complete (0x3E:0x1C) [Vol 4, Part E, 7.7.65.28].
+type LETerminateBIGComplete []byte
+
+func (r LETerminateBIGComplete) SubeventCode() uint8 { return r[0] }
+
+func (r LETerminateBIGComplete) BIGHandle() uint8 { return r[1] }
+
+func (r LETerminateBIGComplete) Reason() uint8 { return r[2] }
+
+const LEBIGSyncEstablishedCode = 0x3E
+
+const LEBIGSyncEstablishedSubCode = 0x1D
+
+// LEBIGSyncEstablished implements LE BIG Sync Established (0x3E:0x1D) [Vol 4, Part E, 7.7.65.29].
+type LEBIGSyncEstablished []byte
+
+func (r LEBIGSyncEstablished) SubeventCode() uint8 { return r[0] }
+
+func (r LEBIGSyncEstablished) Status() uint8 { return r[1] }
+
+func (r LEBIGSyncEstablished) BIGHandle() uint8 { return r[2] }
+
+func (r LEBIGSyncEstablished) MaxPDU() uint16 { return binary.LittleEndian.Uint16(r[10:]) }
+
+func (r LEBIGSyncEstablished) ISOInterval() uint16 { return binary.LittleEndian.Uint16(r[12:]) }
+
+// ConnectionHandles returns the handles of the BISes, or nil if the event
+// is malformed, or failed.
+func (r LEBIGSyncEstablished) ConnectionHandles() []uint16 { return handles(r, 14) }
+
+const LEBIGSyncLostCode = 0x3E
+
+const LEBIGSyncLostSubCode = 0x1E
+
+// LEBIGSyncLost implements LE BIG Sync Lost (0x3E:0x1E) [Vol 4, Part E, 7.7.65.30].
+type LEBIGSyncLost []byte
+
+func (r LEBIGSyncLost) SubeventCode() uint8 { return r[0] }
+
+func (r LEBIGSyncLost) BIGHandle() uint8 { return r[1] }
+
+func (r LEBIGSyncLost) Reason() uint8 { return r[2] }
+
+// handles returns the count prefixed connection handles of b, from i.
+func handles(b []byte, i int) []uint16 {
+	if len(b) <= i || len(b) < i+1+2*int(b[i]) {
+		return nil
+	}
+	hs := make([]uint16, b[i])
+	for j := range hs {
+		hs[j] = binary.LittleEndian.Uint16(b[i+1+2*j:])
+	}
+	return hs
+}
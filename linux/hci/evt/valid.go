@@ -0,0 +1,114 @@
+package evt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMalformed is returned by Validate, for the events whose parameters are
+// too short for their fields.
+var ErrMalformed = errors.New("malformed event")
+
+// minLen is the length of the parameters of the events, up to the last field
+// their accessors read.
+var minLen = map[int]int{
+	DisconnectionCompleteCode:                4,
+	EncryptionChangeCode:                     4,
+	ReadRemoteVersionInformationCompleteCode: 8,
+	CommandCompleteCode:                      3,
+	CommandStatusCode:                        4,
+	HardwareErrorCode:                        1,
+	NumberOfCompletedPacketsCode:             1,
+	DataBufferOverflowCode:                   1,
+	EncryptionKeyRefreshCompleteCode:         3,
+	AuthenticatedPayloadTimeoutExpiredCode:   2,
+	leMetaCode:                               1,
+}
+
+// leMetaCode is the code of the LE Meta events, whose first parameter is the
+// subevent code.
+const leMetaCode = 0x3E
+
+// leMinLen is the length of the parameters of the LE Meta subevents, with
+// their subevent code.
+var leMinLen = map[int]int{
+	LEConnectionCompleteSubCode:                 19,
+	LEAdvertisingReportSubCode:                  2,
+	LEConnectionUpdateCompleteSubCode:           10,
+	LEReadRemoteUsedFeaturesCompleteSubCode:     12,
+	LELongTermKeyRequestSubCode:                 13,
+	LERemoteConnectionParameterRequestSubCode:   11,
+	LEExtendedAdvertisingReportSubCode:          2,
+	LEPeriodicAdvertisingSyncEstablishedSubCode: 5,
+	LEAdvertisingSetTerminatedSubCode:           6,
+	LEChannelSelectionAlgorithmSubCode:          4,
+	LECISEstablishedSubCode:                     29,
+	LECreateBIGCompleteSubCode:                  19,
+	LETerminateBIGCompleteSubCode:               3,
+	LEBIGSyncEstablishedSubCode:                 15,
+	LEBIGSyncLostSubCode:                        3,
+}
+
+// Validate returns an error wrapping ErrMalformed, if the parameters b of the
+// event code are too short for the fields the accessors of its type read,
+// including the variable ones of the reports. The events of other codes are
+// valid.
+func Validate(code int, b []byte) error {
+	n, ok := minLen[code]
+	if !ok {
+		return nil
+	}
+	if len(b) < n {
+		return malformed(code, b)
+	}
+	switch code {
+	case NumberOfCompletedPacketsCode:
+		if len(b) < 1+4*int(NumberOfCompletedPackets(b).NumberOfHandles()) {
+			return malformed(code, b)
+		}
+	case leMetaCode:
+		return validateLE(b)
+	}
+	return nil
+}
+
+func validateLE(b []byte) error {
+	sub := int(b[0])
+	if n, ok := leMinLen[sub]; ok && len(b) < n {
+		return malformed(leMetaCode, b)
+	}
+	switch sub {
+	case LEAdvertisingReportSubCode:
+		// Per report, the event type, address type, address, data length,
+		// data and RSSI.
+		e := LEAdvertisingReport(b)
+		n := int(e.NumReports())
+		if len(b) < 2+9*n {
+			return malformed(leMetaCode, b)
+		}
+		l := 2 + 10*n
+		for i := 0; i < n; i++ {
+			l += int(e.LengthData(i))
+		}
+		if len(b) < l {
+			return malformed(leMetaCode, b)
+		}
+	case LEExtendedAdvertisingReportSubCode:
+		if e := LEExtendedAdvertisingReport(b); e.NumReports() != 0 && e.Reports() == nil {
+			return malformed(leMetaCode, b)
+		}
+	case LECreateBIGCompleteSubCode:
+		if handles(b, 18) == nil {
+			return malformed(leMetaCode, b)
+		}
+	case LEBIGSyncEstablishedSubCode:
+		if handles(b, 14) == nil {
+			return malformed(leMetaCode, b)
+		}
+	}
+	return nil
+}
+
+func malformed(code int, b []byte) error {
+	return fmt.Errorf("%w: 0x%02X [% X]", ErrMalformed, code, b)
+}
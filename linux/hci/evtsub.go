@@ -0,0 +1,92 @@
+package hci
+
+import (
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// AnySubevent subscribes to the LE Meta events of all the subevent codes.
+const AnySubevent = -1
+
+// evtSubQueue is the number of events queued for each subscription. The
+// events beyond it are dropped, rather than holding up the event loop.
+const evtSubQueue = 16
+
+// evtSub is a subscription to the events of code, and subcode.
+type evtSub struct {
+	code    int
+	subcode int
+	ch      chan []byte
+	once    sync.Once
+}
+
+func (s *evtSub) close() { s.once.Do(func() { close(s.ch) }) }
+
+// match reports whether the event b, starting with its event code, is
+// subscribed to.
+func (s *evtSub) match(code int, b []byte) bool {
+	if s.code != code {
+		return false
+	}
+	return code != 0x3E || s.subcode == AnySubevent || len(b) > 2 && int(b[2]) == s.subcode
+}
+
+// Subscribe subscribes to the HCI events of the event code, and, of the LE
+// Meta events, of the subevent code, or AnySubevent, such as the vendor
+// events, or the events the package doesn't handle. Each event is passed as
+// received, starting with its event code, in a copy of its own. The
+// channel is closed once unsubscribed, or the device is closed.
+func (h *HCI) Subscribe(code, subcode int) (<-chan []byte, func()) {
+	s := &evtSub{code: code, subcode: subcode, ch: make(chan []byte, evtSubQueue)}
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	select {
+	case <-h.done:
+		s.close()
+		return s.ch, func() {}
+	default:
+	}
+	h.subs = append(h.subs, s)
+	return s.ch, func() {
+		h.subsMu.Lock()
+		defer h.subsMu.Unlock()
+		for i, x := range h.subs {
+			if x == s {
+				h.subs = append(h.subs[:i], h.subs[i+1:]...)
+				break
+			}
+		}
+		s.close()
+	}
+}
+
+// publish passes the event b to the subscriptions matching it, and reports
+// whether there are any.
+func (h *HCI) publish(code int, b []byte) bool {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	found := false
+	for _, s := range h.subs {
+		if !s.match(code, b) {
+			continue
+		}
+		found = true
+		select {
+		case s.ch <- append([]byte(nil), b...):
+		default:
+			h.log(ble.LogHCI).Warn("subscription queue full", "evt", code)
+		}
+	}
+	return found
+}
+
+// closeSubs ends the subscriptions, once the device is closed.
+func (h *HCI) closeSubs() {
+	h.subsMu.Lock()
+	defer h.subsMu.Unlock()
+	for _, s := range h.subs {
+		s.close()
+	}
+	h.subs = nil
+}
@@ -0,0 +1,51 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestSubscribe(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs, monitor.Record{Dir: monitor.Received, H4: []byte{pktTypeEvent, 0xFF, 3, 0x01, 0x02, 0x03}})
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	vendor, _ := h.Subscribe(0xFF, AnySubevent)
+	enc, unsubscribe := h.Subscribe(0x08, AnySubevent)
+	unsubscribe()
+	if _, ok := <-enc; ok {
+		t.Error("unsubscribed channel not closed")
+	}
+	if err := h.Advertise(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-vendor:
+		if string(b) != "\xFF\x03\x01\x02\x03" {
+			t.Errorf("got [% X], want [FF 03 01 02 03]", b)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("vendor event not received")
+	}
+	h.Close()
+	select {
+	case _, ok := <-vendor:
+		if ok {
+			t.Error("got an event after close")
+		}
+	case <-time.After(time.Second):
+		t.Error("channel not closed with the device")
+	}
+}
@@ -0,0 +1,255 @@
+package hci
+
+import (
+	"errors"
+
+	"github.com/kirbo/ble/linux/adv"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// leFeatureExtAdv is the bit of LE Extended Advertising in the LE Supported
+// Features. [Vol 6, Part B, 4.6]
+const leFeatureExtAdv = 12
+
+// Advertising Event Properties of LE Set Extended Advertising Parameters.
+// [Vol 2, Part E, 7.8.53]
+const (
+	advPropConnectable      = 0x0001
+	advPropScannable        = 0x0002
+	advPropDirected         = 0x0004
+	advPropHighDutyDirected = 0x0008
+	advPropLegacy           = 0x0010
+)
+
+// extAdvHandle is the advertising set of extended advertising.
+const extAdvHandle = 0x00
+
+// Errors of the advertising data which need extended advertising.
+var (
+	errExtConnScanResp    = errors.New("connectable extended advertising can't carry a scan response")
+	errExtScanAdvData     = errors.New("scannable extended advertising can't carry advertising data")
+	errExtNonConnScanResp = errors.New("non-scannable extended advertising can't carry a scan response")
+	errExtHighDuty        = errors.New("high duty cycle directed advertising can't carry extended data")
+)
+
+// extAdvSupported reports whether the controller supports extended
+// advertising.
+func (h *HCI) extAdvSupported() bool {
+	return h.leFeatures&(1<<leFeatureExtAdv) != 0
+}
+
+// MaxAdvertisingDataLength returns the maximum length of the advertising
+// data, and of the scan response data, which the controller supports. It
+// exceeds adv.MaxEIRPacketLength if the controller supports extended
+// advertising, which SetAdvertisement falls back to for the data that
+// doesn't fit in legacy advertising.
+func (h *HCI) MaxAdvertisingDataLength() int {
+	if !h.extAdvSupported() {
+		return adv.MaxEIRPacketLength
+	}
+	return h.maxAdvDataLen
+}
+
+// tooLong returns the adv.LengthError of ad, or else of sr, if longer than
+// max, or else err.
+func tooLong(ad, sr []byte, max int, err error) error {
+	b := ad
+	if len(b) <= max {
+		b = sr
+	}
+	if len(b) <= max {
+		return err
+	}
+	e := adv.NewLengthError(b, max)
+	e.Err = err
+	return e
+}
+
+// extAdvProperties returns the event properties of extended advertising,
+// for the advertising type of the parameters. Legacy PDUs are used if the
+// data fit in them.
+func (h *HCI) extAdvProperties(ad, sr []byte) (uint16, error) {
+	typ := h.params.advParams.AdvertisingType
+	if len(ad) <= adv.MaxEIRPacketLength && len(sr) <= adv.MaxEIRPacketLength {
+		switch typ {
+		case advTypeConnDirectedHigh:
+			return advPropLegacy | advPropHighDutyDirected | advPropDirected | advPropConnectable, nil
+		case advTypeConnDirectedLow:
+			return advPropLegacy | advPropDirected | advPropConnectable, nil
+		case advTypeScanUndirected:
+			return advPropLegacy | advPropScannable, nil
+		case advTypeNonConnUndirected:
+			return advPropLegacy, nil
+		}
+		return advPropLegacy | advPropScannable | advPropConnectable, nil
+	}
+
+	switch typ {
+	case advTypeConnDirectedHigh:
+		return 0, errExtHighDuty
+	case advTypeConnDirectedLow:
+		if len(sr) > 0 {
+			return 0, errExtConnScanResp
+		}
+		return advPropDirected | advPropConnectable, nil
+	case advTypeScanUndirected:
+		if len(ad) > 0 {
+			return 0, errExtScanAdvData
+		}
+		return advPropScannable, nil
+	case advTypeNonConnUndirected:
+		if len(sr) > 0 {
+			return 0, errExtNonConnScanResp
+		}
+		return 0, nil
+	}
+	if len(sr) > 0 {
+		return 0, errExtConnScanResp
+	}
+	return advPropConnectable, nil
+}
+
+// setExtAdvertisement sets the advertising data and scan response of the
+// extended advertising set. Once used, extended advertising is kept until
+// the controller is reset, since it may reject the legacy advertising
+// commands afterwards. [Vol 4, Part E, 3.1.1]
+func (h *HCI) setExtAdvertisement(ad, sr []byte) error {
+	if !h.extAdvSupported() {
+		return tooLong(ad, sr, adv.MaxEIRPacketLength, ErrExtAdvNotSupported)
+	}
+	if max := h.MaxAdvertisingDataLength(); len(ad) > max || len(sr) > max {
+		return tooLong(ad, sr, max, nil)
+	}
+	props, err := h.extAdvProperties(ad, sr)
+	if err != nil {
+		return err
+	}
+
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+
+	// The data of an enabled set may only be replaced as a whole, and its
+	// parameters not at all, so it's disabled meanwhile.
+	enabled := h.extAdv && h.params.advEnable.AdvertisingEnable == 1 && !h.advPaused
+	fit := len(ad) <= cmd.MaxExtAdvFragmentLength && len(sr) <= cmd.MaxExtAdvFragmentLength
+	restart := enabled && (props != h.extAdvProps || !fit)
+	if restart {
+		if err := h.Send(h.advEnableCmd(0), nil); err != nil {
+			return err
+		}
+	}
+	if !h.extAdv || props != h.extAdvProps {
+		p := h.params.advParams
+		rp := cmd.LESetExtendedAdvertisingParametersRP{}
+		if err := h.Send(h.extAdvParamsCmd(props), &rp); err != nil {
+			return err
+		}
+		h.extTxPwrLv = int(rp.SelectedTxPower)
+		if p.OwnAddressType&0x01 != 0 && h.randomAddr != nil {
+			// A set advertises with its own random address.
+			ra := cmd.LESetAdvertisingSetRandomAddress{AdvertisingHandle: extAdvHandle}
+			copy(ra.RandomAddress[:], h.randomAddr)
+			if err := h.Send(&ra, nil); err != nil {
+				return err
+			}
+		}
+		h.extAdv = true
+		h.extAdvProps = props
+	}
+
+	if h.advTxPower {
+		ad = setTxPower(ad, int8(h.extTxPwrLv))
+		sr = setTxPower(sr, int8(h.extTxPwrLv))
+	}
+	if err := h.sendExtFragments(extAdvHandle, ad, false); err != nil {
+		return err
+	}
+	if err := h.sendExtFragments(extAdvHandle, sr, true); err != nil {
+		return err
+	}
+	h.extAD = append(h.extAD[:0], ad...)
+	h.extSR = append(h.extSR[:0], sr...)
+
+	if restart {
+		return h.Send(h.advEnableCmd(1), nil)
+	}
+	return nil
+}
+
+// extAdvParamsCmd returns the command which sets the parameters of the
+// extended advertising set, from the advertising parameters.
+func (h *HCI) extAdvParamsCmd(props uint16) *cmd.LESetExtendedAdvertisingParameters {
+	p := h.params.advParams
+	return &cmd.LESetExtendedAdvertisingParameters{
+		AdvertisingHandle:             extAdvHandle,
+		AdvertisingEventProperties:    props,
+		PrimaryAdvertisingIntervalMin: [3]byte{uint8(p.AdvertisingIntervalMin), uint8(p.AdvertisingIntervalMin >> 8)},
+		PrimaryAdvertisingIntervalMax: [3]byte{uint8(p.AdvertisingIntervalMax), uint8(p.AdvertisingIntervalMax >> 8)},
+		PrimaryAdvertisingChannelMap:  p.AdvertisingChannelMap,
+		OwnAddressType:                p.OwnAddressType,
+		PeerAddressType:               p.DirectAddressType,
+		PeerAddress:                   p.DirectAddress,
+		AdvertisingFilterPolicy:       p.AdvertisingFilterPolicy,
+		AdvertisingTxPower:            0x7F, // No preference
+		PrimaryAdvertisingPHY:         0x01, // LE 1M
+		SecondaryAdvertisingPHY:       0x01, // LE 1M
+	}
+}
+
+// sendExtFragments sends the advertising data, or scan response if sr is set,
+// of an advertising set, in as many fragments as it takes. Empty data discard
+// the previous data.
+func (h *HCI) sendExtFragments(handle uint8, b []byte, sr bool) error {
+	op := uint8(cmd.ExtAdvOpComplete)
+	if len(b) > cmd.MaxExtAdvFragmentLength {
+		op = cmd.ExtAdvOpFirst
+	}
+	for {
+		n := len(b)
+		if n > cmd.MaxExtAdvFragmentLength {
+			n = cmd.MaxExtAdvFragmentLength
+		} else if op != cmd.ExtAdvOpComplete {
+			op = cmd.ExtAdvOpLast
+		}
+		var c Command
+		if sr {
+			d := &cmd.LESetExtendedScanResponseData{AdvertisingHandle: handle, Operation: op, FragmentPreference: 0x01, ScanResponseDataLength: uint8(n)}
+			copy(d.ScanResponseData[:], b[:n])
+			c = d
+		} else {
+			d := &cmd.LESetExtendedAdvertisingData{AdvertisingHandle: handle, Operation: op, FragmentPreference: 0x01, AdvertisingDataLength: uint8(n)}
+			copy(d.AdvertisingData[:], b[:n])
+			c = d
+		}
+		if err := h.Send(c, nil); err != nil {
+			return err
+		}
+		b = b[n:]
+		if len(b) == 0 {
+			return nil
+		}
+		op = cmd.ExtAdvOpIntermediate
+	}
+}
+
+// advEnableCmd returns the command which enables, or disables, advertising,
+// with the legacy or extended commands, whichever are in use.
+func (h *HCI) advEnableCmd(enable uint8) Command {
+	if h.extAdv {
+		return &cmd.LESetExtendedAdvertisingEnable{Enable: enable, NumberOfSets: 1, AdvertisingHandle: extAdvHandle}
+	}
+	return &cmd.LESetAdvertiseEnable{AdvertisingEnable: enable}
+}
+
+// setTxPower returns a copy of the data b, whose Tx Power Level, if any, is
+// set to pwr.
+func setTxPower(b []byte, pwr int8) []byte {
+	for i := 0; i+1 < len(b) && b[i] != 0; i += int(b[i]) + 1 {
+		if b[i] == 2 && i+2 < len(b) && b[i+1] == 0x0A { // Tx Power Level
+			b = append([]byte{}, b...)
+			b[i+2] = uint8(pwr)
+			break
+		}
+	}
+	return b
+}
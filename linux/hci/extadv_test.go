@@ -0,0 +1,42 @@
+package hci
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kirbo/ble/linux/adv"
+)
+
+func TestExtAdvProperties(t *testing.T) {
+	long := make([]byte, adv.MaxEIRPacketLength+1)
+	short := []byte{0x02, 0x01, 0x06}
+	for _, c := range []struct {
+		typ    uint8
+		ad, sr []byte
+		want   uint16
+		err    error
+	}{
+		{advTypeConnUndirected, short, short, advPropLegacy | advPropScannable | advPropConnectable, nil},
+		{advTypeConnUndirected, long, nil, advPropConnectable, nil},
+		{advTypeConnUndirected, long, short, 0, errExtConnScanResp},
+		{advTypeScanUndirected, nil, long, advPropScannable, nil},
+		{advTypeScanUndirected, short, long, 0, errExtScanAdvData},
+		{advTypeNonConnUndirected, long, nil, 0, nil},
+		{advTypeConnDirectedHigh, long, nil, 0, errExtHighDuty},
+	} {
+		h := &HCI{}
+		h.params.advParams.AdvertisingType = c.typ
+		got, err := h.extAdvProperties(c.ad, c.sr)
+		if got != c.want || err != c.err {
+			t.Errorf("type %d, %d bytes of data, %d of scan response: got 0x%04X, %v, want 0x%04X, %v",
+				c.typ, len(c.ad), len(c.sr), got, err, c.want, c.err)
+		}
+	}
+}
+
+func TestSetAdvertisementWithoutExtAdv(t *testing.T) {
+	h := &HCI{}
+	if err := h.SetAdvertisement(make([]byte, adv.MaxEIRPacketLength+1), nil); !errors.Is(err, ErrExtAdvNotSupported) {
+		t.Errorf("SetAdvertisement() = %v, want ErrExtAdvNotSupported", err)
+	}
+}
@@ -0,0 +1,111 @@
+package hci
+
+import (
+	"fmt"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+// leFeatureCodedPHY is the bit of LE Coded PHY in the LE Supported Features.
+// [Vol 6, Part B, 4.6]
+const leFeatureCodedPHY = 11
+
+// maxExtChains is the number of chains of extended advertising reports,
+// which are reassembled at a time. The fragments beyond it are dropped.
+const maxExtChains = 32
+
+// extScanning reports whether scanning uses the extended commands, which
+// receive the extended advertising PDUs as well as the legacy ones.
+func (h *HCI) extScanning() bool {
+	return h.extScan && h.extAdvSupported()
+}
+
+// scanParamsCmd returns the command which sets the scan parameters, with the
+// legacy or extended commands, whichever are in use.
+func (h *HCI) scanParamsCmd() Command {
+	if !h.extScanning() {
+		return &h.params.scanParams
+	}
+	p := h.params.scanParams
+	c := &cmd.LESetExtendedScanParameters{
+		OwnAddressType:       p.OwnAddressType,
+		ScanningFilterPolicy: p.ScanningFilterPolicy,
+		ScanningPHYs:         h.scanningPHYs(),
+	}
+	// Each PHY is scanned with the same parameters.
+	for m := c.ScanningPHYs; m != 0; m &= m - 1 {
+		c.PHY = append(c.PHY, cmd.ScanPHYParams{ScanType: p.LEScanType, ScanInterval: p.LEScanInterval, ScanWindow: p.LEScanWindow})
+	}
+	return c
+}
+
+// scanningPHYs returns the PHYs set by SetScanPHYs, which the controller
+// supports, or LE 1M.
+func (h *HCI) scanningPHYs() uint8 {
+	m := uint8(h.scanPHYs)
+	if h.leFeatures&(1<<leFeatureCodedPHY) == 0 {
+		m &^= cmd.ScanningPHYCoded
+	}
+	if m == 0 {
+		return cmd.ScanningPHY1M
+	}
+	return m
+}
+
+// scanEnableCmd returns the command which enables, or disables, scanning,
+// with the legacy or extended commands, whichever are in use.
+func (h *HCI) scanEnableCmd(enable uint8) Command {
+	dup := h.params.scanEnable.FilterDuplicates
+	if h.extScanning() {
+		return &cmd.LESetExtendedScanEnable{Enable: enable, FilterDuplicates: dup}
+	}
+	return &cmd.LESetScanEnable{LEScanEnable: enable, FilterDuplicates: dup}
+}
+
+func (h *HCI) handleLEExtendedAdvertisingReport(b []byte) error {
+	if h.advHandler == nil {
+		return nil
+	}
+
+	rs := evt.LEExtendedAdvertisingReport(b).Reports()
+	if rs == nil {
+		return fmt.Errorf("malformed extended advertising report: % X", b)
+	}
+	h.metrics.Add(ble.MetricAdvReports, int64(len(rs)))
+	for _, r := range rs {
+		data, ok := h.reassemble(r)
+		if !ok {
+			continue
+		}
+		if err := h.handleAdv(newExtAdvertisement(r, data, h.clock.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reassemble returns the data of the chain, which r completes, or false if
+// more is to come. The chains are told apart by the address and the
+// Advertising SID of the reports, and whether they are scan responses.
+func (h *HCI) reassemble(r evt.ExtendedAdvertisingReport) ([]byte, bool) {
+	if r.DataStatus() == evt.DataStatusComplete && len(h.extChains) == 0 {
+		return r.Data(), true
+	}
+	k := string(append([]byte{r.AddressType(), r.AdvertisingSID(), byte(r.EventType() & extEvtScanRsp)}, r[3:9]...))
+	prev, ok := h.extChains[k]
+	data := append(prev, r.Data()...)
+	if r.DataStatus() != evt.DataStatusIncomplete {
+		delete(h.extChains, k)
+		return data, true
+	}
+	if !ok && len(h.extChains) >= capped(maxExtChains, h.memLimits.ExtChains) {
+		return nil, false
+	}
+	if h.extChains == nil {
+		h.extChains = make(map[string][]byte)
+	}
+	h.extChains[k] = data
+	return nil, false
+}
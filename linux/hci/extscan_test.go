@@ -0,0 +1,137 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+// extReport returns an LE Extended Advertising Report event of a
+// non-connectable extended advertisement from 11:22:33:44:55:66.
+func extReport(typ uint16, sid uint8, data ...byte) []byte {
+	r := []byte{evt.LEExtendedAdvertisingReportSubCode, 0x01}
+	r = append(r, byte(typ), byte(typ>>8), 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11)
+	r = append(r, 0x01, 0x02, sid, 0x7F, 0xC4, 0x00, 0x00, 0x00, 0, 0, 0, 0, 0, 0)
+	r = append(r, byte(len(data)))
+	return append(r, data...)
+}
+
+func TestExtendedAdvertisingReport(t *testing.T) {
+	h := &HCI{
+		advHandler: func(ble.Advertisement) {},
+		chAdv:      make(chan *Advertisement, 8),
+		adHist:     make([]*Advertisement, 128),
+		metrics:    ble.NopMetrics,
+		clock:      ble.SystemClock,
+		dedup:      newDedupCache(ble.DedupByAddress, time.Minute, 0),
+	}
+	incomplete := uint16(evt.DataStatusIncomplete << 5)
+	for _, b := range [][]byte{
+		extReport(incomplete, 3, 0x02, 0x01),
+		extReport(0, 3, 0x06),
+		// The periodic reports of the set repeat it.
+		extReport(0, 3, 0x02, 0x01, 0x06),
+		extReport(0, 4, 0x02, 0x01, 0x06),
+	} {
+		if err := h.handleLEExtendedAdvertisingReport(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(h.chAdv); n != 2 {
+		t.Fatalf("%d advertisements, want 2", n)
+	}
+	for _, sid := range []int{3, 4} {
+		a := <-h.chAdv
+		if a.SID() != sid || !a.Extended() || a.Connectable() || string(a.Data()) != "\x02\x01\x06" {
+			t.Errorf("got SID %d, extended %v, connectable %v, data [% X], want SID %d of [02 01 06]",
+				a.SID(), a.Extended(), a.Connectable(), a.Data(), sid)
+		}
+		if a.Addr().String() != "11:22:33:44:55:66" || a.RSSI() != -60 {
+			t.Errorf("got %s at %d dBm, want 11:22:33:44:55:66 at -60 dBm", a.Addr(), a.RSSI())
+		}
+	}
+}
+
+func TestDualModeAdvertisement(t *testing.T) {
+	for _, tt := range []struct {
+		data  []byte
+		bredr bool
+	}{
+		{[]byte{0x02, 0x01, 0x06}, false},
+		{[]byte{0x02, 0x01, 0x1A}, true},
+		{[]byte{0x03, 0x03, 0x0F, 0x18}, false},
+	} {
+		rs := evt.LEExtendedAdvertisingReport(extReport(0, 3, tt.data...)).Reports()
+		a := newExtAdvertisement(rs[0], rs[0].Data(), time.Now())
+		if got := ble.BREDRSupported(a); got != tt.bredr {
+			t.Errorf("[% X]: BREDRSupported() = %v, want %v", tt.data, got, tt.bredr)
+		}
+		if id, ok := a.PublicIdentity(); !ok || id.String() != "11:22:33:44:55:66" {
+			t.Errorf("PublicIdentity() = %v, %v, want 11:22:33:44:55:66", id, ok)
+		}
+	}
+}
+
+func TestScanningPHYs(t *testing.T) {
+	h := &HCI{extScan: true, leFeatures: 1 << leFeatureExtAdv}
+	h.params.init()
+	if err := h.SetScanPHYs(ble.ScanPHY1M | ble.ScanPHYCoded); err != nil {
+		t.Fatal(err)
+	}
+	if c := h.scanParamsCmd().(*cmd.LESetExtendedScanParameters); c.ScanningPHYs != cmd.ScanningPHY1M || len(c.PHY) != 1 {
+		t.Errorf("scanning PHYs 0x%02X of %d parameters, want LE 1M only, as LE Coded isn't supported", c.ScanningPHYs, len(c.PHY))
+	}
+	h.leFeatures |= 1 << leFeatureCodedPHY
+	if c := h.scanParamsCmd().(*cmd.LESetExtendedScanParameters); c.ScanningPHYs != 0x05 || len(c.PHY) != 2 {
+		t.Errorf("scanning PHYs 0x%02X of %d parameters, want LE 1M and LE Coded", c.ScanningPHYs, len(c.PHY))
+	}
+}
+
+func TestScanOptions(t *testing.T) {
+	h, err := NewHCI(ble.OptScanInterval(100*time.Millisecond), ble.OptScanWindow(50*time.Millisecond), ble.OptActiveScan(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := h.params.scanParams; p.LEScanInterval != 160 || p.LEScanWindow != 80 || p.LEScanType != scanTypeActive {
+		t.Errorf("scan parameters = %+v, want an active scan of 50 ms every 100 ms", p)
+	}
+	// The options fail with the errors of the setters.
+	if _, err := NewHCI(ble.OptScanInterval(time.Millisecond)); err == nil {
+		t.Error("OptScanInterval accepted an interval below 2.5 ms")
+	}
+	if _, err := NewHCI(ble.OptScanWindow(20 * time.Second)); err == nil {
+		t.Error("OptScanWindow accepted a window over 10.24 s")
+	}
+}
+
+func TestBoundedMemory(t *testing.T) {
+	h := &HCI{
+		advHandler: func(ble.Advertisement) {},
+		chAdv:      make(chan *Advertisement, 8),
+		adHist:     make([]*Advertisement, 128),
+		metrics:    ble.NopMetrics,
+		clock:      ble.SystemClock,
+		memLimits:  ble.BoundedMemory,
+		dedup:      newDedupCache(ble.DedupByAddress, time.Minute, 2),
+	}
+	incomplete := uint16(evt.DataStatusIncomplete << 5)
+	for sid := uint8(0); sid < 8; sid++ {
+		if err := h.handleLEExtendedAdvertisingReport(extReport(incomplete, sid, 0x02)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(h.extChains); n != ble.BoundedMemory.ExtChains {
+		t.Errorf("%d chains reassembled, want %d", n, ble.BoundedMemory.ExtChains)
+	}
+	for sid := uint8(0); sid < 8; sid++ {
+		if err := h.handleLEExtendedAdvertisingReport(extReport(0, sid+8, 0x02, 0x01, 0x06)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(h.dedup.seen); n != 2 {
+		t.Errorf("%d advertisements remembered, want 2", n)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build go1.18
+// +build go1.18
+
+package hci
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+// fuzzHCI returns an initialized device, which drops the malformed packets,
+// without logging them.
+func fuzzHCI(f *testing.F) *HCI {
+	s := monitor.NewReplaySocket(initRecords(), monitor.MatchOpcode)
+	quiet := ble.LoggerFunc(func(ble.Level, string, string, ...interface{}) {})
+	h, err := NewHCI(ble.OptTransport(s), ble.OptDropMalformed(true), ble.OptLogger(quiet))
+	if err != nil {
+		f.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		f.Fatal(err)
+	}
+	f.Cleanup(func() { h.Close() })
+	return h
+}
+
+// connComplete is the LE Connection Complete of handle 0x0001, as the
+// peripheral.
+var connComplete = []byte{0x3E, 19, 0x01, 0x00, 0x01, 0x00, roleSlave, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00}
+
+func FuzzEvent(f *testing.F) {
+	h := fuzzHCI(f)
+	f.Add([]byte{0x05, 0x04, 0x00, 0x01, 0x00, 0x13})
+	f.Add([]byte{0x13, 0x05, 0x01, 0x01, 0x00, 0x01, 0x00})
+	f.Add(connComplete)
+	f.Add([]byte{0x3E, 0x0C, 0x02, 0x01, 0x00, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x01, 0x06, 0xC4})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		h.handleEvt(b)
+	})
+}
+
+func FuzzL2CAP(f *testing.F) {
+	h := fuzzHCI(f)
+	// Fragments of the ACL data packets, each prefixed with its length.
+	f.Add([]byte{9, 0x01, 0x20, 0x05, 0x00, 0x01, 0x00, 0x04, 0x00, 0x0A})
+	f.Add([]byte{8, 0x01, 0x20, 0x04, 0x00, 0x05, 0x00, 0x04, 0x00, 5, 0x01, 0x10, 0x01, 0x00, 0x0A})
+	f.Add([]byte{12, 0x01, 0x20, 0x08, 0x00, 0x04, 0x00, 0x05, 0x00, 0x12, 0x01, 0x02, 0x00})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		c := newConn(h, connComplete[2:])
+		h.muConns.Lock()
+		h.conns[0x0001] = c
+		h.muConns.Unlock()
+		read := make(chan struct{})
+		go func() {
+			defer close(read)
+			buf := make([]byte, ble.MaxMTU)
+			for {
+				if _, err := c.Read(buf); err != nil {
+					return
+				}
+			}
+		}()
+		// Up to 4 fragments, as the responses sent take the 8 buffers of
+		// the controller, which don't complete.
+		for i := 0; i < 4 && len(b) > 0; i++ {
+			n := int(b[0])
+			if n > len(b)-1 {
+				n = len(b) - 1
+			}
+			p := append([]byte{}, b[1:1+n]...)
+			b = b[1+n:]
+			if len(p) >= 2 {
+				p[0], p[1] = 0x01, p[1]&0xF0
+			}
+			h.deliverACL(p, nil)
+		}
+		h.muConns.Lock()
+		delete(h.conns, 0x0001)
+		h.muConns.Unlock()
+		c.release()
+		<-read
+	})
+}
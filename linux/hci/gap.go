@@ -13,7 +13,7 @@ import (
 )
 
 // Addr ...
-func (h *HCI) Addr() ble.Addr { return h.addr }
+func (h *HCI) Addr() ble.Addr { return h.ownAddr() }
 
 // SetAdvHandler ...
 func (h *HCI) SetAdvHandler(ah ble.AdvHandler) error {
@@ -21,22 +21,58 @@ func (h *HCI) SetAdvHandler(ah ble.AdvHandler) error {
 	return nil
 }
 
-// Scan starts scanning.
+// Scan starts scanning. It may scan while advertising or dialing, if the
+// controller supports it, or else scanning is deferred until dialed.
 func (h *HCI) Scan(allowDup bool) error {
-	h.params.scanEnable.FilterDuplicates = 1
-	if allowDup {
-		h.params.scanEnable.FilterDuplicates = 0
+	if err := h.requireCentral("Scan"); err != nil {
+		return err
+	}
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	if h.params.advEnable.AdvertisingEnable == 1 && !h.canScanWhileAdvertising() {
+		return errStateNotSupported
+	}
+	filter := !allowDup
+	if h.filterDupSet {
+		filter = h.filterDup
+	}
+	h.scanPlan = h.planScan(filter)
+	h.params.scanEnable.FilterDuplicates = 0
+	if h.scanPlan.ControllerDuplicates {
+		h.params.scanEnable.FilterDuplicates = 1
 	}
+	h.dedup = nil
+	if h.scanPlan.DedupTTL > 0 {
+		h.dedup = newDedupCache(h.scanPlan.DedupKey, h.scanPlan.DedupTTL, h.memLimits.DedupEntries)
+	}
+	h.setScanFilters()
+	h.setAcceptList()
+	h.log(ble.LogHCI).Debug("scan plan", "plan", h.scanPlan)
 	h.params.scanEnable.LEScanEnable = 1
 	h.adHist = make([]*Advertisement, 128)
 	h.adLast = 0
-	return h.Send(&h.params.scanEnable, nil)
+	h.extChains = nil
+	if h.initiating && !h.canScanWhileInitiating() {
+		h.scanPaused = true
+		return nil
+	}
+	// Told before the command completes, as the events which stop it may
+	// be handled before Send returns.
+	h.radioScan(true, 0)
+	if err := h.Send(h.scanEnableCmd(1), nil); err != nil {
+		h.radioScan(false, ble.StopFailed)
+		return err
+	}
+	return nil
 }
 
 // StopScanning stops scanning.
 func (h *HCI) StopScanning() error {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
 	h.params.scanEnable.LEScanEnable = 0
-	return h.Send(&h.params.scanEnable, nil)
+	h.radioScan(false, ble.StopRequested)
+	return h.Send(h.scanEnableCmd(0), nil)
 }
 
 // AdvertiseAdv advertises a given Advertisement
@@ -64,10 +100,8 @@ func (h *HCI) AdvertiseAdv(a ble.Advertisement) error {
 		}
 	}
 	sr, _ := adv.NewPacket()
-	switch {
-	case ad.Append(adv.CompleteName(a.LocalName())) == nil:
-	case sr.Append(adv.CompleteName(a.LocalName())) == nil:
-	case sr.Append(adv.ShortName(a.LocalName())) == nil:
+	if err := h.appendName(ad, sr, a.LocalName()); err != nil {
+		return err
 	}
 
 	if a.ManufacturerData() != nil {
@@ -77,8 +111,21 @@ func (h *HCI) AdvertiseAdv(a ble.Advertisement) error {
 		case sr.Append(manufacuturerData) == nil:
 		}
 	}
+	for _, u := range a.SolicitedService() {
+		switch {
+		case ad.Append(adv.SolicitedUUID(u)) == nil:
+		case sr.Append(adv.SolicitedUUID(u)) == nil:
+		}
+	}
+	if u := a.URI(); u != "" {
+		switch {
+		case ad.Append(adv.URI(u)) == nil:
+		case sr.Append(adv.URI(u)) == nil:
+		}
+	}
+	h.appendTxPower(ad, sr)
 	if err := h.SetAdvertisement(ad.Bytes(), sr.Bytes()); err != nil {
-		return nil
+		return err
 	}
 	return h.Advertise()
 
@@ -111,25 +158,39 @@ func (h *HCI) AdvertiseNameAndServices(name string, uuids ...ble.UUID) error {
 		}
 	}
 	sr, _ := adv.NewPacket()
-	switch {
-	case ad.Append(adv.CompleteName(name)) == nil:
-	case sr.Append(adv.CompleteName(name)) == nil:
-	case sr.Append(adv.ShortName(name)) == nil:
+	if err := h.appendName(ad, sr, name); err != nil {
+		return err
 	}
+	h.appendTxPower(ad, sr)
 	if err := h.SetAdvertisement(ad.Bytes(), sr.Bytes()); err != nil {
-		return nil
+		return err
 	}
 	return h.Advertise()
 }
 
+// appendName appends the Complete Local Name n to ad, or else to sr, or else,
+// if SetShortenName is set, the Shortened Local Name, which fits in sr. It
+// returns the adv.LengthError of sr with the complete name otherwise.
+func (h *HCI) appendName(ad, sr *adv.Packet, n string) error {
+	switch {
+	case ad.Append(adv.CompleteName(n)) == nil, sr.Append(adv.CompleteName(n)) == nil:
+		return nil
+	case h.shortenName && sr.Append(adv.FitName(n)) == nil:
+		return nil
+	}
+	_, err := adv.NewPacket(adv.Raw(sr.Bytes()), adv.CompleteName(n))
+	return fmt.Errorf("name %q doesn't fit, shorten it, or set ble.OptShortenName: %w", n, err)
+}
+
 // AdvertiseMfgData avertises the given manufacturer data.
 func (h *HCI) AdvertiseMfgData(id uint16, md []byte) error {
 	ad, err := adv.NewPacket(adv.ManufacturerData(id, md))
 	if err != nil {
 		return err
 	}
+	h.appendTxPower(ad, nil)
 	if err := h.SetAdvertisement(ad.Bytes(), nil); err != nil {
-		return nil
+		return err
 	}
 	return h.Advertise()
 }
@@ -140,8 +201,9 @@ func (h *HCI) AdvertiseServiceData16(id uint16, b []byte) error {
 	if err != nil {
 		return err
 	}
+	h.appendTxPower(ad, nil)
 	if err := h.SetAdvertisement(ad.Bytes(), nil); err != nil {
-		return nil
+		return err
 	}
 	return h.Advertise()
 }
@@ -152,8 +214,9 @@ func (h *HCI) AdvertiseIBeaconData(md []byte) error {
 	if err != nil {
 		return err
 	}
+	h.appendTxPower(ad, nil)
 	if err := h.SetAdvertisement(ad.Bytes(), nil); err != nil {
-		return nil
+		return err
 	}
 	return h.Advertise()
 }
@@ -164,23 +227,54 @@ func (h *HCI) AdvertiseIBeacon(u ble.UUID, major, minor uint16, pwr int8) error
 	if err != nil {
 		return err
 	}
+	h.appendTxPower(ad, nil)
 	if err := h.SetAdvertisement(ad.Bytes(), nil); err != nil {
-		return nil
+		return err
 	}
 	return h.Advertise()
 }
 
+// TxPowerLevel returns the Tx power of the advertising, in dBm, as selected by
+// the controller for extended advertising, or else as read from it.
+func (h *HCI) TxPowerLevel() int {
+	if h.extAdv {
+		return h.extTxPwrLv
+	}
+	return h.txPwrLv
+}
+
+// appendTxPower appends the Tx Power Level, if set by SetAdvTxPower, to ad,
+// or else to sr, if it fits in either. The level is set again to the one the
+// controller selects for extended advertising.
+func (h *HCI) appendTxPower(ad, sr *adv.Packet) {
+	if !h.advTxPower {
+		return
+	}
+	f := adv.TxPower(int8(h.txPwrLv))
+	if ad.Append(f) != nil && sr != nil {
+		sr.Append(f)
+	}
+}
+
 // StopAdvertising stops advertising.
 func (h *HCI) StopAdvertising() error {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
 	h.params.advEnable.AdvertisingEnable = 0
-	return h.Send(&h.params.advEnable, nil)
+	h.radioAdv(false, ble.StopRequested)
+	return h.Send(h.advEnableCmd(0), nil)
 }
 
 // Accept starts advertising and accepts connection.
 func (h *HCI) Accept() (ble.Conn, error) {
+	if err := h.requirePeripheral("Accept"); err != nil {
+		return nil, err
+	}
 	var tmo <-chan time.Time
 	if h.listenerTmo != time.Duration(0) {
-		tmo = time.After(h.listenerTmo)
+		t := h.clock.NewTimer(h.listenerTmo)
+		defer t.Stop()
+		tmo = t.C()
 	}
 	select {
 	case <-h.done:
@@ -192,74 +286,243 @@ func (h *HCI) Accept() (ble.Conn, error) {
 	}
 }
 
-// Dial ...
+// Dial connects to the peer a. Only one connection can be initiated at a
+// time, so the Dials are queued, and take their turns in order; ctx cancels
+// a Dial as it waits as well. A peer which is connected already isn't
+// dialed again, but fails with ErrACLConnExists. [Vol 6, Part B, 4.5]
 func (h *HCI) Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
+	if err := h.requireCentral("Dial"); err != nil {
+		return nil, err
+	}
 	b, err := net.ParseMAC(a.String())
 	if err != nil {
 		return nil, ErrInvalidAddr
 	}
-	h.params.connParams.PeerAddress = [6]byte{b[5], b[4], b[3], b[2], b[1], b[0]}
-	if _, ok := a.(RandomAddress); ok {
-		h.params.connParams.PeerAddressType = 1
+	done, err := h.dials.wait(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if h.connected(a) {
+		return nil, ErrACLConnExists
+	}
+	if h.maxConns > 0 {
+		h.muConns.Lock()
+		n := len(h.conns)
+		h.muConns.Unlock()
+		if n >= h.maxConns {
+			return nil, ErrMaxConnections
+		}
 	}
+	defer h.beginInitiating()()
+
+	h.params.connParams.PeerAddress = [6]byte{b[5], b[4], b[3], b[2], b[1], b[0]}
+	h.params.connParams.PeerAddressType = peerAddressType(ctx, a)
 	if err = h.Send(&h.params.connParams, nil); err != nil {
 		return nil, err
 	}
 	var tmo <-chan time.Time
-	if h.dialerTmo != time.Duration(0) {
-		tmo = time.After(h.dialerTmo)
+	d := h.dialerTmo
+	if cd, ok := ble.ConnectTimeoutFromContext(ctx); ok && (d == 0 || cd < d) {
+		d = cd
+	}
+	if d != time.Duration(0) {
+		t := h.clock.NewTimer(d)
+		defer t.Stop()
+		tmo = t.C()
 	}
 
 	select {
 	case <-ctx.Done():
-		return h.cancelDial()
+		return h.cancelDial(ctx)
 	case <-tmo:
-		return h.cancelDial()
+		return h.cancelDial(ctx)
 	case <-h.done:
 		return nil, h.err
 	case c := <-h.chMasterConn:
-		return gatt.NewClient(c)
+		return newClient(ctx, c)
+	}
+}
+
+// newClient returns the GATT client of c, which inherits the client timeout
+// and the retry policy of ctx, if any.
+func newClient(ctx context.Context, c *Conn) (ble.Client, error) {
+	if d, ok := ble.ClientTimeoutFromContext(ctx); ok {
+		c.SetContext(ble.WithClientTimeout(c.Context(), d))
+	}
+	if p, ok := ble.RetryPolicyFromContext(ctx); ok {
+		c.SetContext(ble.WithRetry(c.Context(), p))
+	}
+	return gatt.NewClient(c)
+}
 
+// peerAddressType returns 1 if a is a random address, either by its type or
+// by the advertisement attached to ctx with ble.WithAdvertisement.
+func peerAddressType(ctx context.Context, a ble.Addr) uint8 {
+	switch ble.AddrTypeOf(a) {
+	case ble.AddrTypeRandom:
+		return 1
+	case ble.AddrTypePublic:
+		return 0
+	}
+	if adv, ok := ble.AdvertisementFromContext(ctx); ok && ble.SameAddr(adv.Addr(), a) {
+		if ble.AddrTypeOf(adv.Addr()) == ble.AddrTypeRandom {
+			return 1
+		}
 	}
+	return 0
 }
 
 // cancelDial cancels the Dialing
-func (h *HCI) cancelDial() (ble.Client, error) {
+func (h *HCI) cancelDial(ctx context.Context) (ble.Client, error) {
 	err := h.Send(&h.params.connCancel, nil)
 	if err == nil {
 		// The pending connection was canceled successfully.
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("connection canceled: %w", err)
+		}
 		return nil, fmt.Errorf("connection canceled")
 	}
 	// The connection has been established, the cancel command
 	// failed with ErrDisallowed.
 	if err == ErrDisallowed {
-		return gatt.NewClient(<-h.chMasterConn)
+		return newClient(ctx, <-h.chMasterConn)
 	}
 	return nil, errors.Wrap(err, "cancel connection failed")
 }
 
-// Advertise starts advertising.
+// Advertise starts advertising. It may advertise while scanning or dialing,
+// if the controller supports it, or else advertising is deferred until
+// dialed.
 func (h *HCI) Advertise() error {
+	if err := h.requirePeripheral("Advertise"); err != nil {
+		return err
+	}
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	if h.params.scanEnable.LEScanEnable == 1 && !h.canScanWhileAdvertising() {
+		return errStateNotSupported
+	}
 	h.params.advEnable.AdvertisingEnable = 1
-	return h.Send(&h.params.advEnable, nil)
+	if h.initiating && !h.canAdvertiseWhileInitiating() {
+		h.advPaused = true
+		return nil
+	}
+	h.radioAdv(true, 0)
+	if err := h.Send(h.advEnableCmd(1), nil); err != nil {
+		h.radioAdv(false, ble.StopFailed)
+		return err
+	}
+	return nil
 }
 
-// SetAdvertisement sets advertising data and scanResp.
+// StartAdvertising advertises again what was advertised, once stopped by
+// StopAdvertising. Both are safe to call as centrals connect and disconnect.
+func (h *HCI) StartAdvertising() error {
+	return h.Advertise()
+}
+
+// resumeAdvertising re-enables the advertising, which the controller stopped
+// as a central connected, unless the advertising was stopped meanwhile.
+func (h *HCI) resumeAdvertising() {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	if h.params.advEnable.AdvertisingEnable != 1 || h.advPaused {
+		return
+	}
+	h.radioAdv(true, 0)
+	if err := h.Send(h.advEnableCmd(1), nil); err != nil {
+		h.radioAdv(false, ble.StopFailed)
+	}
+}
+
+// SetAdvertisement sets advertising data and scanResp. If either exceeds
+// legacy advertising, extended advertising is used, if the controller
+// supports it, or else ErrExtAdvNotSupported is returned.
 func (h *HCI) SetAdvertisement(ad []byte, sr []byte) error {
-	if len(ad) > adv.MaxEIRPacketLength || len(sr) > adv.MaxEIRPacketLength {
-		return ble.ErrEIRPacketTooLong
+	if err := h.requirePeripheral("SetAdvertisement"); err != nil {
+		return err
+	}
+	if h.fixedSR != nil {
+		sr = h.fixedSR
 	}
+	if h.extAdv || h.advSetsUsed || len(ad) > adv.MaxEIRPacketLength || len(sr) > adv.MaxEIRPacketLength {
+		return h.setExtAdvertisement(ad, sr)
+	}
+	if err := h.SetAdvertisingData(ad); err != nil {
+		return err
+	}
+	return h.SetScanResponseData(sr)
+}
 
+// SetAdvertisingData sets the advertising data. It may be set while
+// advertising, and takes effect from the next advertising event.
+func (h *HCI) SetAdvertisingData(ad []byte) error {
+	if err := h.requirePeripheral("SetAdvertisingData"); err != nil {
+		return err
+	}
+	if h.extAdv || h.advSetsUsed || len(ad) > adv.MaxEIRPacketLength {
+		return h.setExtAdvertisement(ad, h.scanResponseData())
+	}
 	h.params.advData.AdvertisingDataLength = uint8(len(ad))
 	copy(h.params.advData.AdvertisingData[:], ad)
-	if err := h.Send(&h.params.advData, nil); err != nil {
+	return h.Send(&h.params.advData, nil)
+}
+
+// SetScanResponseData sets the scan response data. It may be set while
+// advertising, and takes effect from the next scan request.
+func (h *HCI) SetScanResponseData(sr []byte) error {
+	if err := h.requirePeripheral("SetScanResponseData"); err != nil {
 		return err
 	}
-
+	if h.extAdv || h.advSetsUsed || len(sr) > adv.MaxEIRPacketLength {
+		return h.setExtAdvertisement(h.advertisingData(), sr)
+	}
 	h.params.scanResp.ScanResponseDataLength = uint8(len(sr))
 	copy(h.params.scanResp.ScanResponseData[:], sr)
-	if err := h.Send(&h.params.scanResp, nil); err != nil {
+	return h.Send(&h.params.scanResp, nil)
+}
+
+// SetScanResponse sets the scan response, which the Advertise methods send in
+// place of the ones they compose, so the application decides what goes in the
+// advertising data, and what is only sent to active scanners. No fields set an
+// empty scan response. It takes effect at once, if advertising.
+func (h *HCI) SetScanResponse(fields ...adv.Field) error {
+	if err := h.requirePeripheral("SetScanResponse"); err != nil {
 		return err
 	}
+	p, err := adv.NewExtendedPacket(fields...)
+	if err != nil {
+		return err
+	}
+	sr := append([]byte{}, p.Bytes()...)
+	if h.params.advEnable.AdvertisingEnable == 1 {
+		if err := h.SetScanResponseData(sr); err != nil {
+			return err
+		}
+	}
+	h.fixedSR = sr
 	return nil
 }
+
+// ClearScanResponse restores the scan responses composed by the Advertise
+// methods, from the next time they are called.
+func (h *HCI) ClearScanResponse() {
+	h.fixedSR = nil
+}
+
+// advertisingData returns the advertising data last set.
+func (h *HCI) advertisingData() []byte {
+	if h.extAdv {
+		return h.extAD
+	}
+	return h.params.advData.AdvertisingData[:h.params.advData.AdvertisingDataLength]
+}
+
+// scanResponseData returns the scan response data last set.
+func (h *HCI) scanResponseData() []byte {
+	if h.extAdv {
+		return h.extSR
+	}
+	return h.params.scanResp.ScanResponseData[:h.params.scanResp.ScanResponseDataLength]
+}
@@ -0,0 +1,430 @@
+package hci
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestSetScanResponse(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanResponseData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := h.SetScanResponse(adv.CompleteName("Gopher"), adv.ServiceData16(0x180F, []byte{100})); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AdvertiseMfgData(0xFFFF, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := adv.NewPacket(adv.CompleteName("Gopher"), adv.ServiceData16(0x180F, []byte{100}))
+	if sr := h.scanResponseData(); !bytes.Equal(sr, want.Bytes()) {
+		t.Errorf("scan response = [% X], want [% X]", sr, want.Bytes())
+	}
+}
+
+func TestAdvTxPower(t *testing.T) {
+	recs := initRecords()
+	recs[11] = exchange(&cmd.LEReadAdvertisingChannelTxPower{}, 0x00, 0xFC)[1] // -4 dBm
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanResponseData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptAdvTxPower(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := h.AdvertiseMfgData(0xFFFF, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if h.TxPowerLevel() != -4 {
+		t.Errorf("TxPowerLevel() = %d, want -4", h.TxPowerLevel())
+	}
+	want, _ := adv.NewPacket(adv.ManufacturerData(0xFFFF, []byte{1, 2, 3}), adv.TxPower(-4))
+	if ad := h.advertisingData(); !bytes.Equal(ad, want.Bytes()) {
+		t.Errorf("advertising data = [% X], want [% X]", ad, want.Bytes())
+	}
+}
+
+func TestAdvertiseWhileConnected(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs,
+		// LE Connection Complete of handle 0x0040, as the peripheral.
+		event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00),
+	)
+	// Advertising is enabled again, for another central.
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := h.Advertise(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; s.Remaining() != 0; i++ {
+		if i == 100 {
+			t.Fatal("advertising not enabled again after a central connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCentralRole(t *testing.T) {
+	recs := initRecords()
+	// No advertising parameters are set up for a central-only device.
+	recs = append(recs[:22:22], recs[24:]...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptRole(ble.RoleCentral))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+	if err, ok := h.Advertise().(*ble.RoleError); !ok || err.Op != "Advertise" {
+		t.Errorf("Advertise() = %v, want a RoleError", err)
+	}
+	if _, err := h.Accept(); err == nil {
+		t.Error("Accept() of a central-only device succeeded")
+	}
+	if m := h.leEventMask(); m&leEvtAdvertisingReport == 0 || m&leEvtLongTermKeyRequest != 0 {
+		t.Errorf("LE event mask = %#x, want advertising reports and no LTK requests", m)
+	}
+}
+
+func TestRemoteInfo(t *testing.T) {
+	status := func(c Command) []monitor.Record {
+		return []monitor.Record{exchange(c)[0], event(0x0F, 0x00, 0x01, byte(c.OpCode()), byte(c.OpCode()>>8))}
+	}
+	recs := initRecords()
+	recs = append(recs,
+		event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00),
+	)
+	recs = append(recs, status(&cmd.ReadRemoteVersionInformation{})...)
+	// Version 5.0 of a controller of manufacturer 15, subversion 0x1234.
+	recs = append(recs, event(0x0C, 0x00, 0x40, 0x00, 0x09, 0x0F, 0x00, 0x34, 0x12))
+	recs = append(recs, status(&cmd.LEReadRemoteUsedFeatures{})...)
+	recs = append(recs, event(0x3E, 0x04, 0x00, 0x40, 0x00, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00))
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptRemoteInfo(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	l, err := h.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := l.(*Conn)
+	for i := 0; !c.RemoteInfo().FeaturesKnown; i++ {
+		if i == 100 {
+			t.Fatal("remote features not read")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	want := ble.RemoteInfo{VersionKnown: true, Version: 0x09, Manufacturer: 15, Subversion: 0x1234, FeaturesKnown: true, Features: 0x0101}
+	if info := c.RemoteInfo(); info != want {
+		t.Errorf("RemoteInfo() = %+v, want %+v", info, want)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chanMonitor passes the telemetry of the connections to channels.
+type chanMonitor struct {
+	params chan ble.ConnParams
+	csa    chan int
+}
+
+func (m *chanMonitor) ConnParamsUpdated(c ble.Conn, p ble.ConnParams) { m.params <- p }
+func (m *chanMonitor) ChannelSelection(c ble.Conn, algorithm int)     { m.csa <- algorithm }
+func (m *chanMonitor) LinkQuality(c ble.Conn, q ble.LinkQuality)      {}
+func (m *chanMonitor) SupervisionWarning(c ble.Conn, d time.Duration) {}
+
+func TestConnMonitor(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs,
+		event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00),
+		// LE Channel Selection Algorithm #2.
+		event(0x3E, 0x14, 0x40, 0x00, 0x01),
+		// LE Connection Update Complete, to 50ms, latency 2 and 4s.
+		event(0x3E, 0x03, 0x00, 0x40, 0x00, 0x28, 0x00, 0x02, 0x00, 0x90, 0x01),
+	)
+
+	m := &chanMonitor{params: make(chan ble.ConnParams, 1), csa: make(chan int, 1)}
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptConnMonitor(m, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	l, err := h.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-m.csa:
+		if a != 2 {
+			t.Errorf("channel selection algorithm = %d, want 2", a)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel selection not reported")
+	}
+	want := ble.ConnParams{Interval: 50 * time.Millisecond, Latency: 2, SupervisionTimeout: 4 * time.Second}
+	select {
+	case p := <-m.params:
+		if p != want {
+			t.Errorf("updated params = %+v, want %+v", p, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("connection update not reported")
+	}
+	if p := l.(*Conn).ConnParams(); p != want {
+		t.Errorf("ConnParams() = %+v, want %+v", p, want)
+	}
+}
+
+func TestUpdateAdvertisement(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanResponseData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	// Updated in place.
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, 0x00)...)
+	// Disallowed while advertising, so restarted.
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, uint8(ErrDisallowed))...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanResponseData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := h.AdvertiseServiceData16(0x181A, []byte{20}); err != nil {
+		t.Fatal(err)
+	}
+	packet := func(v byte) []byte {
+		p, _ := adv.NewPacket(adv.Flags(adv.FlagGeneralDiscoverable|adv.FlagLEOnly), adv.ServiceData16(0x181A, []byte{v}))
+		return p.Bytes()
+	}
+	if err := h.UpdateAdvertisement(packet(21), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Unchanged, nothing is sent.
+	if err := h.UpdateAdvertisement(packet(21), nil); err != nil {
+		t.Fatal(err)
+	}
+	sr, _ := adv.NewPacket(adv.CompleteName("Sensor"))
+	if err := h.UpdateAdvertisement(packet(22), sr.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if ad := h.advertisingData(); !bytes.Equal(ad, packet(22)) {
+		t.Errorf("advertising data = [% X], want [% X]", ad, packet(22))
+	}
+	if b := h.scanResponseData(); !bytes.Equal(b, sr.Bytes()) {
+		t.Errorf("scan response = [% X], want [% X]", b, sr.Bytes())
+	}
+	if h.params.advEnable.AdvertisingEnable != 1 {
+		t.Error("advertising not enabled again")
+	}
+}
+
+func TestAdvertiseLongName(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertisingData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanResponseData{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	u := ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+	name := "A name far too long for a scan response"
+	err = h.AdvertiseNameAndServices(name, u)
+	var le *adv.LengthError
+	if !errors.As(err, &le) || !errors.Is(err, ble.ErrEIRPacketTooLong) {
+		t.Fatalf("AdvertiseNameAndServices() = %v, want a LengthError", err)
+	}
+	if le.Max != adv.MaxEIRPacketLength || len(le.Structures) != 1 || le.Structures[0].Size != 2+len(name) {
+		t.Errorf("LengthError = %+v", le)
+	}
+
+	h.SetShortenName(true)
+	if err := h.AdvertiseNameAndServices(name, u); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sr := adv.NewRawPacket(h.scanResponseData())
+	if n := sr.Field(0x08); string(n) != name[:adv.MaxEIRPacketLength-2] {
+		t.Errorf("shortened name %q", n)
+	}
+}
+
+// mapMonitor passes the link quality, and the channel maps, of the
+// connections to channels.
+type mapMonitor struct {
+	chanMonitor
+	quality chan ble.LinkQuality
+	maps    chan ble.ChannelMap
+}
+
+func (m *mapMonitor) LinkQuality(c ble.Conn, q ble.LinkQuality)       { m.quality <- q }
+func (m *mapMonitor) ChannelMapChanged(c ble.Conn, cm ble.ChannelMap) { m.maps <- cm }
+
+func TestChannelMap(t *testing.T) {
+	noWiFi1 := ble.AllDataChannels.WithoutWiFi(1)
+	noWiFi6 := ble.AllDataChannels.WithoutWiFi(6)
+	recs := initRecords()
+	// Sent at the end of init, before the parameters.
+	recs = append(recs[:len(recs)-4:len(recs)-4],
+		append(exchange(&cmd.LESetHostChannelClassification{ChannelMap: noWiFi1}, 0x00), recs[len(recs)-4:]...)...)
+	recs = append(recs,
+		event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00),
+	)
+	recs = append(recs, exchange(&cmd.ReadRSSI{}, 0x00, 0x40, 0x00, 0xC4)...)
+	recs = append(recs, exchange(&cmd.LEReadChannelMap{}, append([]byte{0x00, 0x40, 0x00}, noWiFi1[:]...)...)...)
+	recs = append(recs, exchange(&cmd.LESetHostChannelClassification{ChannelMap: noWiFi6}, 0x00)...)
+	// Not applied by the controller yet.
+	recs = append(recs, exchange(&cmd.ReadRSSI{}, 0x00, 0x40, 0x00, 0xC4)...)
+	recs = append(recs, exchange(&cmd.LEReadChannelMap{}, append([]byte{0x00, 0x40, 0x00}, noWiFi1[:]...)...)...)
+	recs = append(recs, exchange(&cmd.ReadRSSI{}, 0x00, 0x40, 0x00, 0xC4)...)
+	recs = append(recs, exchange(&cmd.LEReadChannelMap{}, append([]byte{0x00, 0x40, 0x00}, noWiFi6[:]...)...)...)
+
+	clk := ble.NewFakeClock(time.Unix(0, 0))
+	m := &mapMonitor{quality: make(chan ble.LinkQuality, 1), maps: make(chan ble.ChannelMap, 1)}
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptClock(clk), ble.OptConnMonitor(m, time.Second),
+		ble.OptHostChannelClassification(noWiFi1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if _, err := h.Accept(); err != nil {
+		t.Fatal(err)
+	}
+
+	poll := func(want ble.ChannelMap, changed bool) {
+		t.Helper()
+		clk.BlockUntil(2) // The tickers of the monitor.
+		clk.Advance(time.Second)
+		select {
+		case q := <-m.quality:
+			if q.RSSI != -60 || q.ChannelMap != want || q.Channels != want.Count() {
+				t.Errorf("link quality = %+v, want -60 dBm on %v", q, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("link quality not reported")
+		}
+		select {
+		case cm := <-m.maps:
+			if !changed || cm != want {
+				t.Errorf("channel map changed to %v, want %v, %t", cm, want, changed)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if changed {
+				t.Errorf("channel map change to %v not reported", want)
+			}
+		}
+	}
+	poll(noWiFi1, true)
+	if err := h.SetHostChannelClassification(noWiFi6); err != nil {
+		t.Fatal(err)
+	}
+	poll(noWiFi1, false)
+	poll(noWiFi6, true)
+
+	if err := h.SetHostChannelClassification(ble.ChannelMap{0x01}); err != ble.ErrChannelMap {
+		t.Errorf("set a single channel: %v, want %v", err, ble.ErrChannelMap)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Fatalf("%d records not replayed", n)
+	}
+}
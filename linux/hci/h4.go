@@ -0,0 +1,102 @@
+package hci
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/socket"
+)
+
+// openSocket opens the HCI socket of the device ID, or, if the platform has
+// no Bluetooth support, the H4 stream of the fallback address, if it's set.
+func (h *HCI) openSocket() (io.ReadWriteCloser, error) {
+	if h.unblockRFKill {
+		h.unblock()
+	}
+	skt, err := socket.NewSocket(h.id)
+	if err == nil || h.fallbackTCP == "" || !errors.Is(err, ble.ErrNoBluetoothSupport) {
+		return skt, err
+	}
+	h.log(ble.LogHCI).Info("falling back to TCP", "addr", h.fallbackTCP, "err", err)
+	c, err2 := net.Dial("tcp", h.fallbackTCP)
+	if err2 != nil {
+		return nil, fmt.Errorf("%v, and can't dial %s: %w", err, h.fallbackTCP, err2)
+	}
+	return newH4Stream(c), nil
+}
+
+// unblock soft-unblocks the adapter of the device ID, or all of them, if it's
+// -1, as blocked by rfkill.
+func (h *HCI) unblock() {
+	if h.id != -1 {
+		if s, err := socket.ReadRFKill(h.id); err != nil || !s.Soft {
+			return
+		}
+	}
+	if err := socket.UnblockRFKill(h.id); err != nil {
+		h.log(ble.LogHCI).Warn("can't unblock rfkill", "id", h.id, "err", err)
+		return
+	}
+	h.log(ble.LogHCI).Info("unblocked rfkill", "id", h.id)
+}
+
+// h4Stream frames the H4 packets of a byte stream, such as a TCP connection
+// to an HCI bridge, so that each read returns one packet, as the reads of
+// the HCI socket do. The writes pass whole packets already.
+type h4Stream struct {
+	io.ReadWriteCloser
+	r *bufio.Reader
+}
+
+func newH4Stream(c io.ReadWriteCloser) *h4Stream {
+	return &h4Stream{ReadWriteCloser: c, r: bufio.NewReader(c)}
+}
+
+// Read reads a packet into p. The packets which don't fit are skipped.
+func (s *h4Stream) Read(p []byte) (int, error) {
+	t, err := s.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	// The header, which ends with the length of the parameters, or the data.
+	var hdr [4]byte
+	var hl, dl int
+	switch t {
+	case pktTypeEvent:
+		hl = 2
+	case pktTypeCommand, pktTypeSCOData:
+		hl = 3
+	case pktTypeACLData, pktTypeISOData:
+		hl = 4
+	default:
+		return 0, fmt.Errorf("h4: unsupported packet type 0x%02X", t)
+	}
+	if _, err := io.ReadFull(s.r, hdr[:hl]); err != nil {
+		return 0, err
+	}
+	switch t {
+	case pktTypeACLData:
+		dl = int(binary.LittleEndian.Uint16(hdr[2:]))
+	case pktTypeISOData:
+		dl = int(binary.LittleEndian.Uint16(hdr[2:]) & 0x3FFF)
+	default:
+		dl = int(hdr[hl-1])
+	}
+	if 1+hl+dl > len(p) {
+		if _, err := s.r.Discard(dl); err != nil {
+			return 0, err
+		}
+		return s.Read(p)
+	}
+	p[0] = t
+	copy(p[1:], hdr[:hl])
+	if _, err := io.ReadFull(s.r, p[1+hl:1+hl+dl]); err != nil {
+		return 0, err
+	}
+	return 1 + hl + dl, nil
+}
@@ -0,0 +1,41 @@
+package hci
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// nopCloser is a ReadWriteCloser reading r, and discarding the writes.
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Write(p []byte) (int, error) { return len(p), nil }
+func (nopCloser) Close() error                { return nil }
+
+func TestH4Stream(t *testing.T) {
+	evt := []byte{pktTypeEvent, 0x0E, 0x04, 0x01, 0x03, 0x0C, 0x00}
+	acl := []byte{pktTypeACLData, 0x40, 0x00, 0x05, 0x00, 0x01, 0x00, 0x04, 0x00, 0x0A}
+	big := append([]byte{pktTypeEvent, 0x3E, 0x20}, make([]byte, 0x20)...)
+	iso := []byte{pktTypeISOData, 0x60, 0x00, 0x02, 0xC0, 0xAA, 0xBB} // Flags in the length.
+
+	var stream []byte
+	for _, p := range [][]byte{evt, acl, big, iso} {
+		stream = append(stream, p...)
+	}
+	s := newH4Stream(nopCloser{bytes.NewReader(stream)})
+	b := make([]byte, 16)
+	for _, want := range [][]byte{evt, acl, iso} { // big doesn't fit, and is skipped.
+		n, err := s.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b[:n], want) {
+			t.Fatalf("read [% X], want [% X]", b[:n], want)
+		}
+	}
+	if _, err := s.Read(b); err == nil {
+		t.Fatal("read past the end of the stream")
+	}
+}
@@ -1,18 +1,20 @@
 package hci
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kirbo/ble"
 	"github.com/kirbo/ble/linux/hci/cmd"
 	"github.com/kirbo/ble/linux/hci/evt"
-	"github.com/kirbo/ble/linux/hci/socket"
+	"github.com/kirbo/ble/linux/hci/monitor"
 	"github.com/pkg/errors"
 )
 
@@ -32,6 +34,7 @@ type handlerFn func(b []byte) error
 
 type pkt struct {
 	cmd  Command
+	b    []byte
 	done chan []byte
 }
 
@@ -40,18 +43,19 @@ func NewHCI(opts ...ble.Option) (*HCI, error) {
 	h := &HCI{
 		id: -1,
 
-		chCmdPkt:  make(chan *pkt),
-		chCmdBufs: make(chan []byte, 16),
-		sent:      make(map[int]*pkt),
-		muSent:    &sync.Mutex{},
-
 		evth: map[int]handlerFn{},
 		subh: map[int]handlerFn{},
 
 		muConns:      &sync.Mutex{},
 		conns:        make(map[uint16]*Conn),
 		chMasterConn: make(chan *Conn),
-		chSlaveConn:  make(chan *Conn),
+		chSlaveConn:  make(chan *Conn, acceptBacklog),
+
+		scanBufSize: defaultScanBufSize,
+		scanOffload: ble.DefaultScanOffload,
+		clock:       ble.SystemClock,
+		logger:      ble.DefaultLogger,
+		metrics:     ble.NopMetrics,
 
 		done: make(chan bool),
 	}
@@ -69,14 +73,12 @@ type HCI struct {
 
 	params params
 
-	skt io.ReadWriteCloser
-	id  int
+	skt       io.ReadWriteCloser
+	id        int
+	transport io.ReadWriteCloser // Used instead of the socket of id, if set.
 
 	// Host to Controller command flow control [Vol 2, Part E, 4.4]
-	chCmdPkt  chan *pkt
-	chCmdBufs chan []byte
-	muSent    *sync.Mutex
-	sent      map[int]*pkt
+	cmdq cmdQueue
 
 	// evtHub
 	evth map[int]handlerFn
@@ -89,6 +91,17 @@ type HCI struct {
 	// Device information or status.
 	addr    net.HardwareAddr
 	txPwrLv int
+	version Version
+	quirks  Quirks // Applied by Init, for the version.
+
+	// Addresses set by SetPublicAddress and SetRandomAddress, in the order
+	// of the HCI commands. randomAddr is generated by Init, if not set.
+	publicAddr []byte
+	randomAddr []byte
+	useRandom  bool
+
+	// advOwnAddr is the address advertised with, set by SetAdvOwnAddressType.
+	advOwnAddr ble.OwnAddressType
 
 	// adHist and adLast track the history of past scannable advertising packets.
 	// Controller delivers AD(Advertising Data) and SR(Scan Response) separately
@@ -101,6 +114,53 @@ type HCI struct {
 	adHist     []*Advertisement
 	adLast     int
 
+	// Duplicate filtering. filterDup overrides the allowDup argument of Scan
+	// if filterDupSet. dedup is the host-side cache, allocated in the Scan()
+	// when duplicates are filtered and dedupTTL is non-zero.
+	filterDupSet bool
+	filterDup    bool
+	dedupKey     ble.DedupKey
+	dedupTTL     time.Duration
+	dedup        *dedupCache
+
+	// Advertising reports are queued in chAdv, and delivered to advHandler
+	// by advLoop. Reports that don't fit are counted in advDropped.
+	scanBufSize  int
+	scanOverflow func(dropped int)
+	chAdv        chan *Advertisement
+	advDropped   int32
+
+	// Scan filters, set by SetScanFilters, are offloaded to the controller
+	// with the Android vendor commands (APCF) or the accept list, as planned
+	// by planScan, and applied to the reports by the host, as hostFilters,
+	// regardless. scanPlan is the plan of the last scan.
+	scanFilters      []ble.ScanFilter
+	scanFiltersSet   bool // The controller has the scanFilters.
+	hostFilters      []ble.ScanFilter
+	apcfProbed       bool
+	apcfMax          int
+	apcfEnabled      bool
+	scanOffload      ble.ScanOffload
+	scanPlan         ble.ScanPlan
+	acceptList       []ble.Addr // Addresses of the accept list, if the scanning uses it.
+	acceptListProbed bool
+	acceptListMax    int
+
+	stateHandler func(ble.AdapterState)
+	clock        ble.Clock
+
+	// lastStats is the previous sample of Stats, which the rates are of.
+	statsMu   sync.Mutex
+	lastStats ble.AdapterStats
+
+	// The adapter may be removed, and is reopened once back if reopen is
+	// set. reopened is closed once it's reopened, or h is closed.
+	removedHandler func()
+	reopen         bool
+	reopenMu       sync.Mutex
+	reopened       chan struct{}
+	closed         bool
+
 	// Host to Controller Data Flow Control Packet-based Data flow control for LE-U [Vol 2, Part E, 4.1.1]
 	// Minimum 27 bytes. 4 bytes of L2CAP Header, and 23 bytes Payload from upper layer (ATT)
 	pool *Pool
@@ -110,6 +170,7 @@ type HCI struct {
 	conns        map[uint16]*Conn
 	chMasterConn chan *Conn // Dial returns master connections.
 	chSlaveConn  chan *Conn // Peripheral accept slave connections.
+	maxConns     int        // Limit of concurrent connections; 0 for the controller's.
 
 	connectedHandler    func(evt.LEConnectionComplete)
 	disconnectedHandler func(evt.DisconnectionComplete)
@@ -117,6 +178,132 @@ type HCI struct {
 	dialerTmo   time.Duration
 	listenerTmo time.Duration
 
+	capture io.Writer
+
+	// roleMu serializes the changes of the advertising, scanning and
+	// initiating states. Scanning and advertising are paused while
+	// initiating, unless the controller supports them together.
+	roleMu     sync.Mutex
+	dials      dialQueue
+	leStates   uint64 // LE Supported States of the controller.
+	initiating bool
+	scanPaused bool
+	advPaused  bool
+
+	powerProfile ble.PowerProfile // Set by SetPowerProfile, if any.
+	hostChannels *ble.ChannelMap  // Set by SetHostChannelClassification, if any.
+
+	// Extended advertising, which is used once the advertising data or scan
+	// response exceed legacy advertising, until the controller is reset.
+	leFeatures    uint64 // LE Supported Features of the controller.
+	maxAdvDataLen int
+	extAdv        bool
+	extAdvProps   uint16
+	extTxPwrLv    int // Tx power selected by the controller for extended advertising.
+	extAD         []byte
+	extSR         []byte
+
+	// The wait of AdvertiseDirected, if any.
+	dirAdvMu sync.Mutex
+	dirAdv   *dirAdv
+
+	// Extended scanning, if enabled by SetExtendedScan, on the PHYs of
+	// SetScanPHYs, and the chains of extended advertising reports being
+	// reassembled.
+	extScan   bool
+	scanPHYs  ble.ScanPHY
+	extChains map[string][]byte
+
+	// Isochronous channels, by handle, and their CIGs and BIGs. isoWaits
+	// are the events, which complete the creations of them, by isoKey.
+	// The buffers are those of LE Read Buffer Size [v2].
+	isoMu      sync.Mutex
+	isoSyncMu  sync.Mutex
+	isoChans   map[uint16]*ISOChannel
+	isoWaits   map[int]chan []byte
+	cigs       map[uint8]int // Number of CISes of the CIGs.
+	bigs       map[uint8]*BIG
+	isoBufSize int
+	isoCredits chan struct{}
+
+	// LE Secure Connections OOB data of LocalOOBData and SetPeerOOBData.
+	oobMu sync.Mutex
+	oob   oobState
+
+	// Scan response set by SetScanResponse, which replaces the ones composed
+	// by the Advertise methods, unless it's nil.
+	fixedSR []byte
+
+	// Advertising sets of NewAdvertisingSet, by handle, which are removed
+	// by a reset. The set of extAdvHandle is the advertising of the device.
+	advSetsMu   sync.Mutex
+	advSets     map[uint8]*AdvertisingSet
+	maxAdvSets  int // Number of sets the controller supports, or 0 if not read yet.
+	advSetsUsed bool
+
+	logger      ble.Logger
+	metrics     ble.MetricsCollector
+	latency     ble.LatencyObserver // metrics, if it observes latencies.
+	bondStore   ble.BondStore
+	gattCache   bool
+	remoteInfo  bool
+	advTxPower  bool // The Advertise methods include the Tx Power Level.
+	shortenName bool // The Advertise methods shorten the name, which doesn't fit.
+	indPolicy   ble.IndicationPolicy
+	authorizer  ble.Authorizer
+	auditHook   ble.AuditHook
+	srvLimits   ble.ServerLimits
+	secPolicy   ble.SecurityPolicy
+	role        ble.Role
+
+	// connMonitor receives the telemetry of the connections, whose link
+	// quality is polled every monitorPoll.
+	connMonitor ble.ConnMonitor
+	monitorPoll time.Duration
+
+	// tuning tunes the parameters of the connections to their traffic, if
+	// set.
+	tuning *ble.IntervalTuning
+
+	// prefParams are the connection parameters the peripheral requests, and
+	// prefPHY the PHYs of the connections, if set.
+	prefParams *ble.PreferredConnParams
+	prefPHY    ble.PreferredPHY
+
+	// radio passes the transitions of the scanning and advertising.
+	radio radio
+
+	// dropMalformed drops the malformed events and PDUs received, rather
+	// than failing on them.
+	dropMalformed bool
+
+	// fallbackTCP is the address of the H4 stream used, if the platform has
+	// no Bluetooth support.
+	fallbackTCP string
+
+	// unblockRFKill soft-unblocks the adapter, before opening it.
+	unblockRFKill bool
+
+	// The advertising policy, as centrals connect and disconnect: by default
+	// advertising is re-enabled while connected, if the controller can, and
+	// restarted on disconnection.
+	advNoRestart        bool
+	advNoWhileConnected bool
+
+	// pktHooks intercept the packets passing through the socket.
+	pktHooks []ble.PacketHook
+
+	// memLimits cap the buffers, caches, and queues.
+	memLimits ble.MemoryLimits
+
+	// autoSec is the security level the GATT operations elevate the links
+	// to, once refused for their security, if set.
+	autoSec ble.SecurityLevel
+
+	// subs are the subscriptions to the events.
+	subs   []*evtSub
+	subsMu sync.Mutex
+
 	err  error
 	done chan bool
 }
@@ -128,28 +315,53 @@ func (h *HCI) Init() error {
 	h.evth[evt.CommandStatusCode] = h.handleCommandStatus
 	h.evth[evt.DisconnectionCompleteCode] = h.handleDisconnectionComplete
 	h.evth[evt.NumberOfCompletedPacketsCode] = h.handleNumberOfCompletedPackets
+	h.evth[evt.EncryptionChangeCode] = h.handleEncryptionChange
+	h.evth[evt.EncryptionKeyRefreshCompleteCode] = h.handleEncryptionKeyRefreshComplete
+	h.evth[evt.ReadRemoteVersionInformationCompleteCode] = h.handleReadRemoteVersionInformationComplete
 
 	h.subh[evt.LEAdvertisingReportSubCode] = h.handleLEAdvertisingReport
+	h.subh[evt.LEExtendedAdvertisingReportSubCode] = h.handleLEExtendedAdvertisingReport
 	h.subh[evt.LEConnectionCompleteSubCode] = h.handleLEConnectionComplete
 	h.subh[evt.LEConnectionUpdateCompleteSubCode] = h.handleLEConnectionUpdateComplete
 	h.subh[evt.LELongTermKeyRequestSubCode] = h.handleLELongTermKeyRequest
-	// evt.EncryptionChangeCode:                     todo),
-	// evt.ReadRemoteVersionInformationCompleteCode: todo),
+	h.subh[evt.LEAdvertisingSetTerminatedSubCode] = h.handleLEAdvertisingSetTerminated
+	h.subh[evt.LEReadRemoteUsedFeaturesCompleteSubCode] = h.handleLEReadRemoteUsedFeaturesComplete
+	h.subh[evt.LEChannelSelectionAlgorithmSubCode] = h.handleLEChannelSelectionAlgorithm
+	h.subh[evt.LEPeriodicAdvertisingSyncEstablishedSubCode] = h.isoEvent(-1, false)
+	h.subh[evt.LECISEstablishedSubCode] = h.isoEvent(2, true)
+	h.subh[evt.LECreateBIGCompleteSubCode] = h.isoEvent(2, false)
+	h.subh[evt.LETerminateBIGCompleteSubCode] = h.isoEvent(1, false)
+	h.subh[evt.LEBIGSyncEstablishedSubCode] = h.isoEvent(2, false)
+	h.subh[evt.LEBIGSyncLostSubCode] = h.handleLEBIGSyncLost
 	// evt.HardwareErrorCode:                        todo),
 	// evt.DataBufferOverflowCode:                   todo),
-	// evt.EncryptionKeyRefreshCompleteCode:         todo),
 	// evt.AuthenticatedPayloadTimeoutExpiredCode:   todo),
-	// evt.LEReadRemoteUsedFeaturesCompleteSubCode:   todo),
 	// evt.LERemoteConnectionParameterRequestSubCode: todo),
 
-	skt, err := socket.NewSocket(h.id)
-	if err != nil {
-		return err
+	skt := h.transport
+	if skt == nil {
+		var err error
+		if skt, err = h.openSocket(); err != nil {
+			return err
+		}
 	}
 	h.skt = skt
+	if h.capture != nil {
+		w, err := monitor.NewBtsnoopWriter(h.capture)
+		if err != nil {
+			return errors.Wrap(err, "can't start capture")
+		}
+		h.skt = monitor.NewCaptureSocket(skt, w)
+	}
+	if len(h.pktHooks) != 0 {
+		// Inside the capture, which has the packets as they're on the wire.
+		h.skt = &hookSocket{ReadWriteCloser: h.skt, hooks: h.pktHooks}
+	}
 
 	h.setAllowedCommands(1)
 
+	h.chAdv = make(chan *Advertisement, capped(h.scanBufSize, h.memLimits.ScanBuffer))
+	go h.advLoop()
 	go h.sktLoop()
 	if err := h.init(); err != nil {
 		return err
@@ -159,13 +371,99 @@ func (h *HCI) Init() error {
 	// HCI header (1 Byte) + ACL Data Header (4 bytes) + L2CAP PDU (or fragment)
 	h.pool = NewPool(1+4+h.bufSize, h.bufCnt-1)
 
-	h.Send(&h.params.advParams, nil)
-	h.Send(&h.params.scanParams, nil)
+	if h.role.Peripheral() {
+		h.Send(&h.params.advParams, nil)
+	}
+	if h.role.Central() {
+		h.Send(h.scanParamsCmd(), nil)
+	}
+	h.setState(ble.AdapterPoweredOn)
+	return nil
+}
+
+// setState reports a state of the adapter to the state handler. It is never
+// called while an event is handled, so the handler may use h.
+func (h *HCI) setState(s ble.AdapterState) {
+	if h.stateHandler != nil {
+		h.stateHandler(s)
+	}
+}
+
+// Clock returns the Clock of the device, set by SetClock.
+func (h *HCI) Clock() ble.Clock {
+	return h.clock
+}
+
+// Role returns the role, or roles, the device is set up for.
+func (h *HCI) Role() ble.Role {
+	return h.role
+}
+
+// Bits of the LE Event Mask. [Vol 2, Part E, 7.8.1]
+const (
+	leEvtConnectionComplete        = 1 << 0
+	leEvtAdvertisingReport         = 1 << 1
+	leEvtConnectionUpdateComplete  = 1 << 2
+	leEvtReadRemoteFeatures        = 1 << 3
+	leEvtLongTermKeyRequest        = 1 << 4
+	leEvtExtendedAdvertisingReport = 1 << 12
+	leEvtAdvertisingSetTerminated  = 1 << 17
+	leEvtChannelSelection          = 1 << 19
+	leEvtPeriodicSyncEstablished   = 1 << 13
+	leEvtCISEstablished            = 1 << 24
+	leEvtCreateBIGComplete         = 1 << 26
+	leEvtTerminateBIGComplete      = 1 << 27
+	leEvtBIGSyncEstablished        = 1 << 28
+	leEvtBIGSyncLost               = 1 << 29
+)
+
+// leEventMask returns the LE events of the roles of the device.
+func (h *HCI) leEventMask() uint64 {
+	m := uint64(leEvtConnectionComplete | leEvtConnectionUpdateComplete | leEvtReadRemoteFeatures)
+	if h.role.Central() {
+		m |= leEvtAdvertisingReport
+		if h.extScan {
+			m |= leEvtExtendedAdvertisingReport
+		}
+	}
+	if h.role.Peripheral() {
+		// The central starts the encryption, which the peripheral is
+		// requested the key for.
+		m |= leEvtLongTermKeyRequest | leEvtAdvertisingSetTerminated
+	}
+	if h.connMonitor != nil {
+		m |= leEvtChannelSelection
+	}
+	if h.isoSupported() {
+		m |= leEvtPeriodicSyncEstablished | leEvtCISEstablished | leEvtCreateBIGComplete |
+			leEvtTerminateBIGComplete | leEvtBIGSyncEstablished | leEvtBIGSyncLost
+	}
+	return m
+}
+
+// requireCentral returns a RoleError for op, unless the device is set up for
+// the central role.
+func (h *HCI) requireCentral(op string) error {
+	if !h.role.Central() {
+		return &ble.RoleError{Op: op, Role: h.role}
+	}
+	return nil
+}
+
+// requirePeripheral returns a RoleError for op, unless the device is set up
+// for the peripheral role.
+func (h *HCI) requirePeripheral(op string) error {
+	if !h.role.Peripheral() {
+		return &ble.RoleError{Op: op, Role: h.role}
+	}
 	return nil
 }
 
 // Close ...
 func (h *HCI) Close() error {
+	h.reopenMu.Lock()
+	h.closed = true
+	h.reopenMu.Unlock()
 	return h.close(nil)
 }
 
@@ -185,12 +483,21 @@ func (h *HCI) Option(opts ...ble.Option) error {
 
 func (h *HCI) init() error {
 	h.Send(&cmd.Reset{}, nil)
+	h.applyQuirks()
+	if h.publicAddr != nil {
+		if err := h.setPublicAddress(); err != nil {
+			return errors.Wrap(err, "can't set public address")
+		}
+	}
 
 	ReadBDADDRRP := cmd.ReadBDADDRRP{}
 	h.Send(&cmd.ReadBDADDR{}, &ReadBDADDRRP)
 
 	a := ReadBDADDRRP.BDADDR
 	h.addr = net.HardwareAddr([]byte{a[5], a[4], a[3], a[2], a[1], a[0]})
+	if h.publicAddr != nil && !bytes.Equal(a[:], h.publicAddr) {
+		return errors.Errorf("can't set public address: controller kept %s", h.addr)
+	}
 
 	ReadBufferSizeRP := cmd.ReadBufferSizeRP{}
 	h.Send(&cmd.ReadBufferSize{}, &ReadBufferSizeRP)
@@ -211,10 +518,43 @@ func (h *HCI) init() error {
 	LEReadAdvertisingChannelTxPowerRP := cmd.LEReadAdvertisingChannelTxPowerRP{}
 	h.Send(&cmd.LEReadAdvertisingChannelTxPower{}, &LEReadAdvertisingChannelTxPowerRP)
 
-	h.txPwrLv = int(LEReadAdvertisingChannelTxPowerRP.TransmitPowerLevel)
+	// The level is signed, in dBm, from -127 to 20.
+	h.txPwrLv = int(int8(LEReadAdvertisingChannelTxPowerRP.TransmitPowerLevel))
+
+	LEReadSupportedStatesRP := cmd.LEReadSupportedStatesRP{}
+	h.Send(&cmd.LEReadSupportedStates{}, &LEReadSupportedStatesRP)
+	h.leStates = LEReadSupportedStatesRP.LEStates
+	if h.quirks&QuirkNoLEStates != 0 {
+		h.leStates = 0
+	}
+
+	LEReadLocalSupportedFeaturesRP := cmd.LEReadLocalSupportedFeaturesRP{}
+	h.Send(&cmd.LEReadLocalSupportedFeatures{}, &LEReadLocalSupportedFeaturesRP)
+	h.leFeatures = LEReadLocalSupportedFeaturesRP.LEFeatures
+	if h.quirks&QuirkNoExtAdv != 0 {
+		h.leFeatures &^= 1 << leFeatureExtAdv
+	}
+	h.extAdv = false
+	h.resetAdvSets()
+	h.initISO()
+
+	// A reset clears the vendor scan filters.
+	h.apcfProbed, h.apcfMax, h.apcfEnabled = false, 0, false
+	h.scanFiltersSet = false
+	// And the accept list, which the scanning refills before using it.
+	h.acceptListProbed, h.acceptListMax = false, 0
+	if h.acceptList != nil {
+		h.acceptList = nil
+		h.params.scanParams.ScanningFilterPolicy = 0x00
+	}
+	if h.extAdvSupported() {
+		LEReadMaximumAdvertisingDataLengthRP := cmd.LEReadMaximumAdvertisingDataLengthRP{}
+		h.Send(&cmd.LEReadMaximumAdvertisingDataLength{}, &LEReadMaximumAdvertisingDataLengthRP)
+		h.maxAdvDataLen = int(LEReadMaximumAdvertisingDataLengthRP.MaximumAdvertisingDataLength)
+	}
 
 	LESetEventMaskRP := cmd.LESetEventMaskRP{}
-	h.Send(&cmd.LESetEventMask{LEEventMask: 0x000000000000001F}, &LESetEventMaskRP)
+	h.Send(&cmd.LESetEventMask{LEEventMask: h.leEventMask()}, &LESetEventMaskRP)
 
 	SetEventMaskRP := cmd.SetEventMaskRP{}
 	h.Send(&cmd.SetEventMask{EventMask: 0x3dbff807fffbffff}, &SetEventMaskRP)
@@ -222,16 +562,38 @@ func (h *HCI) init() error {
 	WriteLEHostSupportRP := cmd.WriteLEHostSupportRP{}
 	h.Send(&cmd.WriteLEHostSupport{LESupportedHost: 1, SimultaneousLEHost: 0}, &WriteLEHostSupportRP)
 
+	if h.useRandom {
+		if err := h.setRandomAddress(); err != nil {
+			return errors.Wrap(err, "can't set random address")
+		}
+	}
+	if h.prefPHY != (ble.PreferredPHY{}) {
+		h.setDefaultPHY()
+	}
+	if h.hostChannels != nil {
+		if err := h.sendHostChannels(*h.hostChannels); err != nil {
+			return errors.Wrap(err, "can't set host channel classification")
+		}
+	}
+
 	return h.err
 }
 
 // Send ...
 func (h *HCI) Send(c Command, r CommandRP) error {
-	b, err := h.send(c)
+	return h.SendContext(context.Background(), c, r)
+}
+
+// SendContext is Send, which gives up once ctx is done, returning its error.
+// A command still queued isn't sent, and the response of one sent is
+// dropped, as once the command times out.
+func (h *HCI) SendContext(ctx context.Context, c Command, r CommandRP) error {
+	b, err := h.send(ctx, c)
 	if err != nil {
 		return err
 	}
 	if len(b) > 0 && b[0] != 0x00 {
+		h.log(ble.LogHCI).Warn("command failed", "opcode", fmt.Sprintf("0x%04X", c.OpCode()), "err", ErrCommand(b[0]))
 		return ErrCommand(b[0])
 	}
 	if r != nil {
@@ -240,81 +602,47 @@ func (h *HCI) Send(c Command, r CommandRP) error {
 	return nil
 }
 
-func (h *HCI) send(c Command) ([]byte, error) {
-	if h.err != nil {
-		return nil, h.err
-	}
-	p := &pkt{c, make(chan []byte)}
-	b := <-h.chCmdBufs
-	b[0] = byte(pktTypeCommand) // HCI header
-	b[1] = byte(c.OpCode())
-	b[2] = byte(c.OpCode() >> 8)
-	b[3] = byte(c.Len())
-	if err := c.Marshal(b[4:]); err != nil {
-		h.close(fmt.Errorf("hci: failed to marshal cmd"))
-	}
-
-	h.muSent.Lock()
-	h.sent[c.OpCode()] = p
-	h.muSent.Unlock()
-	if n, err := h.skt.Write(b[:4+c.Len()]); err != nil {
-		h.close(fmt.Errorf("hci: failed to send cmd"))
-	} else if n != 4+c.Len() {
-		h.close(fmt.Errorf("hci: failed to send whole cmd pkt to hci socket"))
-	}
-
-	var ret []byte
-	var err error
-
-	// emergency timeout to prevent calls from locking up if the HCI
-	// interface doesn't respond.  Responsed here should normally be fast
-	// a timeout indicates a major problem with HCI.
-	timeout := time.NewTimer(10 * time.Second)
-	select {
-	case <-timeout.C:
-		err = fmt.Errorf("hci: no response to command, hci connection failed")
-		ret = nil
-	case <-h.done:
-		err = h.err
-		ret = nil
-	case b := <-p.done:
-		err = nil
-		ret = b
-	}
-	timeout.Stop()
-
-	// clear sent table when done, we sometimes get command complete or
-	// command status messages with no matching send, which can attempt to
-	// access stale packets in sent and fail or lock up.
-	h.muSent.Lock()
-	delete(h.sent, c.OpCode())
-	h.muSent.Unlock()
-
-	return ret, err
-}
-
 func (h *HCI) sktLoop() {
-	b := make([]byte, 4096)
+	removed := false
+	var reopened chan struct{}
+	defer func() {
+		if removed {
+			h.adapterRemoved(reopened)
+		}
+	}()
+	defer h.setState(ble.AdapterPoweredOff)
+	defer h.radioOff()
+	defer h.closeSubs() // Once done, so that no subscription is left open.
 	defer close(h.done)
 	for {
-		n, err := h.skt.Read(b)
+		buf := getRxBuf()
+		n, err := h.skt.Read(buf.b)
 		if n == 0 || err != nil {
+			buf.Release()
+			if err == nil {
+				err = io.ErrUnexpectedEOF
+			}
 			if err == io.EOF {
 				h.err = err //callers depend on detecting io.EOF, don't wrap it.
 			} else {
-				h.err = fmt.Errorf("skt: %s", err)
+				h.err = &socketError{err}
+			}
+			if removed = isRemoved(err); removed && h.reopen && h.transport == nil {
+				// Set before done, for WaitReopened to wait on.
+				reopened = make(chan struct{})
+				h.reopenMu.Lock()
+				h.reopened = reopened
+				h.reopenMu.Unlock()
 			}
 			return
 		}
-		p := make([]byte, n)
-		copy(p, b)
-		if err := h.handlePkt(p); err != nil {
+		if err := h.handleRxBuf(buf, n); err != nil {
 			// Some bluetooth devices may append vendor specific packets at the last,
 			// in this case, simply ignore them.
 			if strings.HasPrefix(err.Error(), "unsupported vendor packet:") {
-				_ = logger.Error("skt: %v", err)
+				h.log(ble.LogHCI).Debug("ignored packet", "err", err)
 			} else {
-				log.Printf("skt: %v", err)
+				h.log(ble.LogHCI).Error("can't handle packet", "err", err)
 				continue
 			}
 		}
@@ -329,6 +657,20 @@ func (h *HCI) close(err error) error {
 	return err
 }
 
+// handleRxBuf handles the packet of n bytes read into buf. ACL data is
+// passed on in buf, which the connection releases once done with it. Other
+// packets are handled in a copy, as events are held on to, e.g. as the
+// advertisements or the returned parameters of commands.
+func (h *HCI) handleRxBuf(buf *rxBuf, n int) error {
+	if buf.b[0] == pktTypeACLData {
+		return h.deliverACL(buf.b[1:n], buf)
+	}
+	p := make([]byte, n)
+	copy(p, buf.b)
+	buf.Release()
+	return h.handlePkt(p)
+}
+
 func (h *HCI) handlePkt(b []byte) error {
 	// Strip the 1-byte HCI header and pass down the rest of the packet.
 	t, b := b[0], b[1:]
@@ -339,6 +681,8 @@ func (h *HCI) handlePkt(b []byte) error {
 		return h.handleACL(b)
 	case pktTypeSCOData:
 		return fmt.Errorf("unsupported sco packet: % X", b)
+	case pktTypeISOData:
+		return h.handleISO(b)
 	case pktTypeEvent:
 		return h.handleEvt(b)
 	case pktTypeVendor:
@@ -349,23 +693,50 @@ func (h *HCI) handlePkt(b []byte) error {
 }
 
 func (h *HCI) handleACL(b []byte) error {
+	return h.deliverACL(b, nil)
+}
+
+// deliverACL passes the ACL data packet b to its connection, along with the
+// buffer b is in, if pooled.
+func (h *HCI) deliverACL(b []byte, buf *rxBuf) error {
+	if h.dropMalformed && (len(b) < 4 || packet(b).dlen() != len(b)-4) {
+		buf.Release()
+		h.dropped(ble.LogHCI, "ACL packet", b, "length mismatch")
+		return nil
+	}
 	handle := packet(b).handle()
 	h.muConns.Lock()
 	c, ok := h.conns[handle]
 	h.muConns.Unlock()
 	if !ok {
-		_ = logger.Warn("invalid connection handle on ACL packet", "handle", handle)
+		buf.Release()
+		h.log(ble.LogHCI).Warn("invalid connection handle on ACL packet", "handle", handle)
 		return nil
 	}
-	c.chInPkt <- b
+	h.metrics.Add(ble.MetricACLRxBytes, int64(len(b)-4))
+	c.chInPkt <- rxPacket{packet(b), buf}
 	return nil
 }
 
 func (h *HCI) handleEvt(b []byte) error {
+	if h.dropMalformed {
+		if err := validEvent(b); err != nil {
+			h.dropped(ble.LogHCI, "event", b, err)
+			return nil
+		}
+		defer h.recoverMalformed(ble.LogHCI, "event", b)
+	}
 	code, plen := int(b[0]), int(b[1])
 	if plen != len(b[2:]) {
 		return fmt.Errorf("invalid event packet: % X", b)
 	}
+	h.log(ble.LogHCI).Debug("recv", "evt", fmt.Sprintf("0x%02X", code), "pkt", fmt.Sprintf("[% X]", b))
+	if h.publish(code, b) {
+		// The events subscribed to aren't unsupported.
+		if h.evth[code] == nil || code == 0x3E && len(b) > 2 && h.subh[int(b[2])] == nil {
+			return nil
+		}
+	}
 	if code == evt.CommandCompleteCode || code == evt.CommandStatusCode {
 		if f := h.evth[code]; f != nil {
 			return f(b[2:])
@@ -375,7 +746,10 @@ func (h *HCI) handleEvt(b []byte) error {
 		h.err = fmt.Errorf("invalid event packet: % X", b)
 	}
 	if f := h.evth[code]; f != nil {
-		h.err = f(b[2:])
+		// Only set on failure, as the commands sent meanwhile read it.
+		if err := f(b[2:]); err != nil {
+			h.err = err
+		}
 		return nil
 	}
 	if code == 0xff { // Ignore vendor events
@@ -398,46 +772,102 @@ func (h *HCI) handleLEAdvertisingReport(b []byte) error {
 	}
 
 	e := evt.LEAdvertisingReport(b)
+	h.metrics.Add(ble.MetricAdvReports, int64(e.NumReports()))
 	for i := 0; i < int(e.NumReports()); i++ {
-		var a *Advertisement
-		switch e.EventType(i) {
-		case evtTypAdvInd:
-			fallthrough
-		case evtTypAdvScanInd:
-			a = newAdvertisement(e, i)
-			h.adHist[h.adLast] = a
-			h.adLast++
-			if h.adLast == len(h.adHist) {
-				h.adLast = 0
+		if err := h.handleAdv(newAdvertisement(e, i, h.clock.Now())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleAdv delivers the advertisement a, or a scan response along with the
+// advertisement it belongs to.
+func (h *HCI) handleAdv(a *Advertisement) error {
+	switch a.EventType() {
+	case evtTypAdvInd:
+		fallthrough
+	case evtTypAdvScanInd:
+		h.adHist[h.adLast] = a
+		h.adLast++
+		if h.adLast == len(h.adHist) {
+			h.adLast = 0
+		}
+	case evtTypScanRsp:
+		sr := a
+		a = nil
+		for idx := h.adLast - 1; idx != h.adLast; idx-- {
+			if idx == -1 {
+				idx = len(h.adHist) - 1
 			}
-		case evtTypScanRsp:
-			sr := newAdvertisement(e, i)
-			for idx := h.adLast - 1; idx != h.adLast; idx-- {
-				if idx == -1 {
-					idx = len(h.adHist) - 1
-				}
-				if h.adHist[idx] == nil {
-					break
-				}
-				if h.adHist[idx].Addr().String() == sr.Addr().String() {
-					h.adHist[idx].setScanResponse(sr)
-					a = h.adHist[idx]
-					break
-				}
+			if h.adHist[idx] == nil {
+				break
 			}
-			// Got a SR without having received an associated AD before?
-			if a == nil {
-				return fmt.Errorf("received scan response %s with no associated Advertising Data packet", sr.Addr())
+			if ble.SameAddr(h.adHist[idx].Addr(), sr.Addr()) {
+				h.adHist[idx].setScanResponse(sr)
+				a = h.adHist[idx]
+				break
 			}
-		default:
-			a = newAdvertisement(e, i)
 		}
-		go h.advHandler(a)
+		// Got a SR without having received an associated AD before?
+		if a == nil {
+			return fmt.Errorf("received scan response %s with no associated Advertising Data packet", sr.Addr())
+		}
+	}
+	if !ble.MatchScanFilters(h.hostFilters, a) {
+		return nil
+	}
+	if h.dedup != nil && h.dedup.dup(a, h.clock.Now()) {
+		return nil
+	}
+	select {
+	case h.chAdv <- a:
+	default:
+		atomic.AddInt32(&h.advDropped, 1)
 	}
 
 	return nil
 }
 
+// defaultScanBufSize is the number of queued advertising reports, unless set
+// by OptScanBuffer.
+const defaultScanBufSize = 64
+
+// connQueue is the number of packets, and of PDUs, queued for each
+// connection, unless capped by the MemoryLimits.
+const connQueue = 16
+
+// capped returns n, capped by the limit l, unless l is 0.
+func capped(n, l int) int {
+	if l > 0 && l < n {
+		return l
+	}
+	return n
+}
+
+// acceptBacklog is the number of incoming connections queued for Accept.
+// Connections beyond it are disconnected, rather than holding up the events
+// of the other connections.
+const acceptBacklog = 8
+
+// advLoop delivers the queued advertising reports, so that a slow AdvHandler
+// holds up neither the HCI reader nor the order of reports.
+func (h *HCI) advLoop() {
+	for {
+		select {
+		case a := <-h.chAdv:
+			if n := atomic.SwapInt32(&h.advDropped, 0); n > 0 && h.scanOverflow != nil {
+				h.scanOverflow(int(n))
+			}
+			if h.advHandler != nil {
+				h.advHandler(a)
+			}
+		case <-h.done:
+			return
+		}
+	}
+}
+
 func (h *HCI) handleCommandComplete(b []byte) error {
 	e := evt.CommandComplete(b)
 	h.setAllowedCommands(int(e.NumHCICommandPackets()))
@@ -447,13 +877,9 @@ func (h *HCI) handleCommandComplete(b []byte) error {
 	if e.CommandOpcode() == 0x0000 {
 		return nil
 	}
-	h.muSent.Lock()
-	p, found := h.sent[int(e.CommandOpcode())]
-	h.muSent.Unlock()
-	if !found {
+	if !h.completeCmd(int(e.CommandOpcode()), e.ReturnParameters()) {
 		return fmt.Errorf("can't find the cmd for CommandCompleteEP: % X", e)
 	}
-	p.done <- e.ReturnParameters()
 	return nil
 }
 
@@ -461,22 +887,56 @@ func (h *HCI) handleCommandStatus(b []byte) error {
 	e := evt.CommandStatus(b)
 	h.setAllowedCommands(int(e.NumHCICommandPackets()))
 
-	h.muSent.Lock()
-	p, found := h.sent[int(e.CommandOpcode())]
-	h.muSent.Unlock()
-	if !found {
+	if !h.completeCmd(int(e.CommandOpcode()), []byte{e.Status()}) {
 		return fmt.Errorf("can't find the cmd for CommandStatusEP: % X", e)
 	}
-	p.done <- []byte{e.Status()}
 	return nil
 }
 
 func (h *HCI) handleLEConnectionComplete(b []byte) error {
 	e := evt.LEConnectionComplete(b)
 	c := newConn(h, e)
+	h.log(ble.LogConn).Info("connection complete", "handle", e.ConnectionHandle(), "addr", c.RemoteAddr(), "role", e.Role(), "status", e.Status())
+	switch {
+	case e.Status() == 0x00:
+		h.metrics.Add(ble.MetricConnsOpened, 1)
+		h.metrics.Add(ble.MetricConns, 1)
+	case ErrCommand(e.Status()) != ErrConnID: // Not canceled by cancelDial.
+		h.metrics.Add(ble.MetricConnsFailed, 1)
+	}
+	// Dial checks the limit before initiating, so only the connections
+	// accepted as a peripheral may exceed it.
 	h.muConns.Lock()
-	h.conns[e.ConnectionHandle()] = c
+	over := e.Role() == roleSlave && h.maxConns > 0 && len(h.conns) >= h.maxConns
+	if e.Status() == 0x00 {
+		h.conns[e.ConnectionHandle()] = c
+	}
+	full := h.maxConns > 0 && len(h.conns) >= h.maxConns
 	h.muConns.Unlock()
+	if e.Status() != 0x00 {
+		// No disconnection follows a failed connection.
+		c.release()
+	}
+	if e.Status() == 0x00 && over {
+		h.log(ble.LogConn).Warn("too many connections", "handle", e.ConnectionHandle(), "addr", c.RemoteAddr(), "max", h.maxConns)
+		go h.Send(&cmd.Disconnect{
+			ConnectionHandle: e.ConnectionHandle(),
+			Reason:           uint8(ErrRemoteLowResources),
+		}, nil)
+		return nil
+	}
+	if e.Status() == 0x00 && h.remoteInfo {
+		go c.readRemoteInfo()
+	}
+	if e.Status() == 0x00 && h.connMonitor != nil {
+		go c.monitor(h.connMonitor, h.monitorPoll)
+	}
+	if e.Status() == 0x00 && h.tuning != nil {
+		go c.tune(*h.tuning)
+	}
+	if e.Status() == 0x00 && e.Role() == roleSlave && (h.prefParams != nil || h.prefPHY != (ble.PreferredPHY{})) {
+		go c.prefer(h.prefParams, h.prefPHY)
+	}
 	if e.Role() == roleMaster {
 		if e.Status() == 0x00 {
 			select {
@@ -492,8 +952,24 @@ func (h *HCI) handleLEConnectionComplete(b []byte) error {
 		}
 		return nil
 	}
+	// Legacy advertising stops, as a central connects, or directed
+	// advertising times out. The sets of extended advertising tell so with
+	// their own event.
+	switch {
+	case h.extAdv:
+	case e.Status() == 0x00:
+		h.radioAdv(false, ble.StopConnected)
+	case ErrCommand(e.Status()) == ErrDirAdvTimeout:
+		h.radioAdv(false, ble.StopTimeout)
+	}
+	h.directedConn(c)
 	if e.Status() == 0x00 {
-		h.chSlaveConn <- c
+		select {
+		case h.chSlaveConn <- c:
+		default:
+			h.log(ble.LogConn).Warn("accept backlog full", "handle", e.ConnectionHandle(), "addr", c.RemoteAddr())
+			go c.Close()
+		}
 		// When a controller accepts a connection, it moves from advertising
 		// state to idle/ready state. Host needs to explicitly ask the
 		// controller to re-enable advertising. Note that the host was most
@@ -503,9 +979,12 @@ func (h *HCI) handleLEConnectionComplete(b []byte) error {
 		// The re-enabling might failed or ignored by the controller, if
 		// it had reached the maximum number of concurrent connections.
 		// So we also re-enable the advertising when a connection disconnected
+		// It's only re-enabled if the controller can advertise while
+		// connected, so further centrals can connect.
 		h.params.RLock()
-		if h.params.advEnable.AdvertisingEnable == 1 {
-			go h.Send(&cmd.LESetAdvertiseEnable{0}, nil)
+		if h.params.advEnable.AdvertisingEnable == 1 && !full && !h.advNoWhileConnected &&
+			h.canAdvertiseWhileSlave() && !h.advDirected() {
+			go h.resumeAdvertising()
 		}
 		h.params.RUnlock()
 	}
@@ -515,12 +994,11 @@ func (h *HCI) handleLEConnectionComplete(b []byte) error {
 	return nil
 }
 
-func (h *HCI) handleLEConnectionUpdateComplete(b []byte) error {
-	return nil
-}
-
 func (h *HCI) handleDisconnectionComplete(b []byte) error {
 	e := evt.DisconnectionComplete(b)
+	if h.handleISODisconnect(e.ConnectionHandle()) {
+		return nil
+	}
 	h.muConns.Lock()
 	c, found := h.conns[e.ConnectionHandle()]
 	delete(h.conns, e.ConnectionHandle())
@@ -528,31 +1006,24 @@ func (h *HCI) handleDisconnectionComplete(b []byte) error {
 	if !found {
 		return fmt.Errorf("disconnecting an invalid handle %04X", e.ConnectionHandle())
 	}
-	close(c.chInPkt)
+	h.log(ble.LogConn).Info("disconnected", "handle", e.ConnectionHandle(), "addr", c.RemoteAddr(), "reason", ErrCommand(e.Reason()))
+	h.metrics.Add(ble.MetricConns, -1)
+	c.release()
 
 	if c.param.Role() == roleSlave {
 		// Re-enable advertising, if it was advertising. Refer to the
 		// handleLEConnectionComplete() for details.
 		// This may failed with ErrCommandDisallowed, if the controller
 		// was actually in advertising state. It does no harm though.
+		// If advertising isn't allowed while connected, it waits for the
+		// last central to go.
 		h.params.RLock()
-		if h.params.advEnable.AdvertisingEnable == 1 {
-			go h.Send(&h.params.advEnable, nil)
+		if h.params.advEnable.AdvertisingEnable == 1 && !h.advNoRestart &&
+			(!h.advNoWhileConnected || h.slaveConns() == 0) {
+			go h.resumeAdvertising()
 		}
 		h.params.RUnlock()
-	} else {
-		// remote peripheral disconnected
-		close(c.chDone)
-	}
-	// When a connection disconnects, all the sent packets and weren't acked yet
-	// will be recycled. [Vol2, Part E 4.1.1]
-	//
-	// must be done with the pool locked to avoid race conditions where
-	// writePDU is in progress and does a Get from the pool after this completes,
-	// leaking a buffer from the main pool.
-	c.txBuffer.LockPool()
-	c.txBuffer.PutAll()
-	c.txBuffer.UnlockPool()
+	}
 	if h.disconnectedHandler != nil {
 		h.disconnectedHandler(e)
 	}
@@ -566,34 +1037,89 @@ func (h *HCI) handleNumberOfCompletedPackets(b []byte) error {
 	for i := 0; i < int(e.NumberOfHandles()); i++ {
 		c, found := h.conns[e.ConnectionHandle(i)]
 		if !found {
+			h.isoCompleted(e.ConnectionHandle(i), int(e.HCNumOfCompletedPackets(i)))
 			continue
 		}
 
 		// Put the delivered buffers back to the pool.
 		for j := 0; j < int(e.HCNumOfCompletedPackets(i)); j++ {
 			c.txBuffer.Put()
+			c.txCompleted()
 		}
 	}
 	return nil
 }
 
-func (h *HCI) handleLELongTermKeyRequest(b []byte) error {
-	e := evt.LELongTermKeyRequest(b)
-	return h.Send(&cmd.LELongTermKeyRequestNegativeReply{
-		ConnectionHandle: e.ConnectionHandle(),
-	}, nil)
+func (h *HCI) handleEncryptionChange(b []byte) error {
+	e := evt.EncryptionChange(b)
+	if e.Status() != 0x00 {
+		h.encryptionFailed(e.ConnectionHandle(), ErrCommand(e.Status()))
+		return nil
+	}
+	return h.securityChanged(e.ConnectionHandle(), e.EncryptionEnabled() != 0x00)
+}
+
+func (h *HCI) handleEncryptionKeyRefreshComplete(b []byte) error {
+	e := evt.EncryptionKeyRefreshComplete(b)
+	if e.Status() != 0x00 {
+		return nil
+	}
+	return h.securityChanged(e.ConnectionHandle(), true)
 }
 
-func (h *HCI) setAllowedCommands(n int) {
+// securityChanged updates the security of the connection of handle. The key
+// size is read off the event loop, which the response has to pass through.
+func (h *HCI) securityChanged(handle uint16, encrypted bool) error {
+	h.muConns.Lock()
+	c, ok := h.conns[handle]
+	h.muConns.Unlock()
+	if !ok {
+		h.log(ble.LogConn).Warn("security of an invalid handle", "handle", handle)
+		return nil
+	}
+	c.secMu.Lock()
+	c.secGen++
+	gen := c.secGen
+	c.secMu.Unlock()
+	go c.updateSecurity(gen, encrypted)
+	return nil
+}
 
-	//hard-coded limit to command queue depth
-	//matches make(chan []byte, 16) in NewHCI
-	// TODO make this a constant, decide correct size
-	if n > 16 {
-		n = 16
+func (h *HCI) handleReadRemoteVersionInformationComplete(b []byte) error {
+	e := evt.ReadRemoteVersionInformationComplete(b)
+	h.muConns.Lock()
+	c, ok := h.conns[e.ConnectionHandle()]
+	h.muConns.Unlock()
+	if !ok || e.Status() != 0x00 {
+		return nil
 	}
+	c.infoMu.Lock()
+	c.info.VersionKnown = true
+	c.info.Version = e.Version()
+	c.info.Manufacturer = e.ManufacturerName()
+	c.info.Subversion = e.Subversion()
+	c.infoMu.Unlock()
+	return nil
+}
 
-	for len(h.chCmdBufs) < n {
-		h.chCmdBufs <- make([]byte, 64) // TODO make buffer size a constant
+func (h *HCI) handleLEReadRemoteUsedFeaturesComplete(b []byte) error {
+	e := evt.LEReadRemoteUsedFeaturesComplete(b)
+	h.muConns.Lock()
+	c, ok := h.conns[e.ConnectionHandle()]
+	h.muConns.Unlock()
+	if !ok || e.Status() != 0x00 {
+		return nil
 	}
+	c.infoMu.Lock()
+	c.info.FeaturesKnown = true
+	c.info.Features = e.LEFeatures()
+	c.infoMu.Unlock()
+	return nil
+}
+
+func (h *HCI) handleLELongTermKeyRequest(b []byte) error {
+	e := evt.LELongTermKeyRequest(b)
+	return h.Send(&cmd.LELongTermKeyRequestNegativeReply{
+		ConnectionHandle: e.ConnectionHandle(),
+	}, nil)
 }
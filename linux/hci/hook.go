@@ -0,0 +1,48 @@
+package hci
+
+import (
+	"io"
+
+	"github.com/kirbo/ble"
+)
+
+// hookSocket passes the packets read from and written to an HCI socket
+// through a chain of PacketHooks.
+type hookSocket struct {
+	io.ReadWriteCloser
+	hooks []ble.PacketHook
+}
+
+// run returns p, as passed on by the hooks, or nil if one drops it.
+func (s *hookSocket) run(dir ble.PacketDir, p []byte) []byte {
+	for _, f := range s.hooks {
+		if p = f(dir, p); len(p) == 0 {
+			return nil
+		}
+	}
+	return p
+}
+
+func (s *hookSocket) Read(p []byte) (int, error) {
+	for {
+		n, err := s.ReadWriteCloser.Read(p)
+		if n == 0 || err != nil {
+			return n, err
+		}
+		if b := s.run(ble.PacketReceived, p[:n]); b != nil {
+			return copy(p, b), nil
+		}
+	}
+}
+
+func (s *hookSocket) Write(p []byte) (int, error) {
+	b := s.run(ble.PacketSent, p)
+	if b == nil {
+		// Dropped, as if sent.
+		return len(p), nil
+	}
+	if _, err := s.ReadWriteCloser.Write(b); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,42 @@
+package hci
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestPacketHooks(t *testing.T) {
+	sent := 0
+	count := func(dir ble.PacketDir, p []byte) []byte {
+		if dir == ble.PacketSent && p[0] == pktTypeCommand {
+			sent++
+		}
+		return p
+	}
+	// Rewrites the address the controller reads.
+	spoof := func(dir ble.PacketDir, p []byte) []byte {
+		if dir == ble.PacketReceived && p[0] == pktTypeEvent && p[1] == 0x0E && p[4] == 0x09 && p[5] == 0x10 {
+			copy(p[7:], []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+		}
+		return p
+	}
+
+	s := monitor.NewReplaySocket(initRecords(), monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptPacketHooks(count, spoof))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if a := h.Addr().String(); a != "06:05:04:03:02:01" {
+		t.Errorf("Addr() = %s, want 06:05:04:03:02:01", a)
+	}
+	if n := len(initRecords()) / 2; sent != n {
+		t.Errorf("%d commands hooked, want %d", sent, n)
+	}
+}
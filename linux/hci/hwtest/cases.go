@@ -0,0 +1,132 @@
+package hwtest
+
+import (
+	"time"
+
+	"github.com/kirbo/ble/linux/hci"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// leFeatureExtAdv is the bit of LE Extended Advertising in the LE Supported
+// Features. [Vol 6, Part B, 4.6]
+const leFeatureExtAdv = 12
+
+// stateNonConnAdvActiveScan is the bit of the LE Supported States of
+// non-connectable advertising and active scanning. [Vol 4, Part E, 7.8.27]
+const stateNonConnAdvActiveScan = 12
+
+// Suite is the standard suite, which the controllers are recorded with.
+var Suite = []Case{
+	{Name: "scan", Run: scan},
+	{Name: "advertise", Run: advertise},
+	{Name: "scan while advertising", Quirks: hci.QuirkNoLEStates, Run: scanWhileAdvertising},
+	{Name: "extended advertising", Quirks: hci.QuirkNoExtAdv, Run: extendedAdvertising},
+	{Name: "vendor capabilities", Quirks: hci.QuirkNoVendorCommands, Run: vendorCapabilities},
+}
+
+// dwell is how long the cases keep the controller scanning, or advertising.
+const dwell = time.Second
+
+func send(h *hci.HCI, cs ...hci.Command) error {
+	for _, c := range cs {
+		if err := h.Send(c, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanParams() *cmd.LESetScanParameters {
+	return &cmd.LESetScanParameters{LEScanType: 0x01, LEScanInterval: 0x0010, LEScanWindow: 0x0010}
+}
+
+func advParams() *cmd.LESetAdvertisingParameters {
+	return &cmd.LESetAdvertisingParameters{
+		AdvertisingIntervalMin: 0x00A0,
+		AdvertisingIntervalMax: 0x00A0,
+		AdvertisingType:        0x03, // Non-connectable undirected
+		AdvertisingChannelMap:  0x07,
+	}
+}
+
+func scan(h *hci.HCI) error {
+	if err := send(h, scanParams(), &cmd.LESetScanEnable{LEScanEnable: 1}); err != nil {
+		return err
+	}
+	time.Sleep(dwell)
+	return send(h, &cmd.LESetScanEnable{LEScanEnable: 0})
+}
+
+func advertise(h *hci.HCI) error {
+	if err := send(h, advParams(), &cmd.LESetAdvertiseEnable{AdvertisingEnable: 1}); err != nil {
+		return err
+	}
+	time.Sleep(dwell)
+	return send(h, &cmd.LESetAdvertiseEnable{AdvertisingEnable: 0})
+}
+
+// scanWhileAdvertising combines the roles, which the controllers of broken
+// LE Supported States reject, or stall on.
+func scanWhileAdvertising(h *hci.HCI) error {
+	rp := cmd.LEReadSupportedStatesRP{}
+	if err := h.Send(&cmd.LEReadSupportedStates{}, &rp); err != nil {
+		return err
+	}
+	if rp.LEStates&(1<<stateNonConnAdvActiveScan) == 0 {
+		return ErrSkipped
+	}
+	if err := send(h, advParams(), &cmd.LESetAdvertiseEnable{AdvertisingEnable: 1}); err != nil {
+		return err
+	}
+	defer send(h, &cmd.LESetAdvertiseEnable{AdvertisingEnable: 0})
+	if err := send(h, scanParams(), &cmd.LESetScanEnable{LEScanEnable: 1}); err != nil {
+		return err
+	}
+	time.Sleep(dwell)
+	return send(h, &cmd.LESetScanEnable{LEScanEnable: 0})
+}
+
+// extendedAdvertising advertises an extended advertising set, on the
+// controllers announcing the feature.
+func extendedAdvertising(h *hci.HCI) error {
+	rp := cmd.LEReadLocalSupportedFeaturesRP{}
+	if err := h.Send(&cmd.LEReadLocalSupportedFeatures{}, &rp); err != nil {
+		return err
+	}
+	if rp.LEFeatures&(1<<leFeatureExtAdv) == 0 {
+		return ErrSkipped
+	}
+	const handle = 0x00
+	units := [3]byte{0xA0, 0x00, 0x00}
+	err := send(h,
+		&cmd.LESetExtendedAdvertisingParameters{
+			AdvertisingHandle:             handle,
+			PrimaryAdvertisingIntervalMin: units,
+			PrimaryAdvertisingIntervalMax: units,
+			PrimaryAdvertisingChannelMap:  0x07,
+			AdvertisingTxPower:            0x7F, // No preference
+			PrimaryAdvertisingPHY:         0x01, // LE 1M
+			SecondaryAdvertisingPHY:       0x01, // LE 1M
+		},
+		&cmd.LESetExtendedAdvertisingEnable{Enable: 1, NumberOfSets: 1, AdvertisingHandle: handle},
+	)
+	if err != nil {
+		send(h, &cmd.LERemoveAdvertisingSet{AdvertisingHandle: handle})
+		return err
+	}
+	time.Sleep(dwell)
+	return send(h,
+		&cmd.LESetExtendedAdvertisingEnable{Enable: 0, NumberOfSets: 1, AdvertisingHandle: handle},
+		&cmd.LERemoveAdvertisingSet{AdvertisingHandle: handle},
+	)
+}
+
+// vendorCapabilities probes the Android vendor commands, which controllers
+// without them are to reject as unknown, rather than stall on.
+func vendorCapabilities(h *hci.HCI) error {
+	err := h.Send(&cmd.LEGetVendorCapabilities{}, nil)
+	if err == hci.ErrUnknownCommand {
+		return ErrSkipped
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+//go:build hwtest
+// +build hwtest
+
+package hwtest
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci"
+)
+
+var (
+	dev    = flag.Int("hwtest.dev", 0, "the hciN device to run the suite against")
+	name   = flag.String("hwtest.name", "", "the model of the controller, e.g. \"CSR8510 A10\"")
+	record = flag.String("hwtest.record", "", "the directory to record the result to, e.g. results")
+)
+
+func TestHardware(t *testing.T) {
+	if *name == "" {
+		t.Fatal("-hwtest.name is required")
+	}
+	h, err := hci.NewHCI(ble.OptDeviceID(*dev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	r := Run(h, *name, Suite)
+	t.Logf("%s: %+v, quirks %b", r.Name, r.Version, h.Quirks())
+	for _, c := range r.Cases {
+		switch c.Status {
+		case Fail:
+			t.Errorf("%s: %s", c.Name, c.Err)
+		default:
+			t.Logf("%s: %s in %v", c.Name, c.Status, c.Duration)
+		}
+	}
+	if qs := r.Needs(Suite); qs&^h.Quirks() != 0 {
+		t.Logf("needs quirks %b, which none of the quirks matching the controller applies", qs&^h.Quirks())
+	}
+	if *record != "" {
+		if err := WriteResult(*record, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
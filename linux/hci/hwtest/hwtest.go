@@ -0,0 +1,156 @@
+// Package hwtest runs a standard suite of tests against a controller, and
+// keeps the results recorded of the controllers, which back the quirks the
+// hci package applies to them.
+//
+// The suite runs against the controller of hciN with
+//
+//	go test -tags hwtest ./linux/hci/hwtest -hwtest.dev N -hwtest.name "Intel AX200"
+//
+// and -hwtest.record results writes the Result to the results directory,
+// which is checked, along with the quirks, into the repository. The tests of
+// the package fail if a recorded controller fails a case, which a quirk
+// works around, while none of the quirks matching it does.
+package hwtest
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kirbo/ble/linux/hci"
+)
+
+// ErrSkipped is returned by the cases, which don't apply to the controller,
+// e.g. of the features it doesn't support.
+var ErrSkipped = errors.New("not supported by the controller")
+
+// Status is the outcome of a case.
+type Status string
+
+// Statuses.
+const (
+	Pass Status = "pass"
+	Fail Status = "fail"
+	Skip Status = "skip"
+)
+
+// A Case is a test of the suite.
+type Case struct {
+	Name string
+
+	// Quirks are the quirks, which work the controllers failing the case
+	// around.
+	Quirks hci.Quirks
+
+	// Run runs the case against the initialized controller, bypassing the
+	// quirks, and leaves it idle.
+	Run func(h *hci.HCI) error
+}
+
+// CaseResult is the outcome of a case.
+type CaseResult struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Err      string        `json:"err,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Result is the outcome of the suite against a controller.
+type Result struct {
+	// Name is the model of the controller, or of the dongle, e.g. "CSR8510 A10".
+	Name     string       `json:"name"`
+	Version  hci.Version  `json:"version"`
+	Recorded time.Time    `json:"recorded"`
+	Cases    []CaseResult `json:"cases"`
+}
+
+// Run runs the cases against h, which is initialized, and returns the result
+// of the controller of the name.
+func Run(h *hci.HCI, name string, cases []Case) Result {
+	r := Result{Name: name, Version: h.Version(), Recorded: time.Now().UTC()}
+	for _, c := range cases {
+		cr := CaseResult{Name: c.Name, Status: Pass}
+		start := time.Now()
+		err := c.Run(h)
+		cr.Duration = time.Since(start)
+		switch {
+		case err == ErrSkipped:
+			cr.Status = Skip
+		case err != nil:
+			cr.Status, cr.Err = Fail, err.Error()
+		}
+		r.Cases = append(r.Cases, cr)
+	}
+	return r
+}
+
+// Needs returns the quirks, which the cases failed by the controller call for.
+// The cases are looked up by their name.
+func (r Result) Needs(cases []Case) hci.Quirks {
+	var qs hci.Quirks
+	for _, cr := range r.Cases {
+		if cr.Status != Fail {
+			continue
+		}
+		for _, c := range cases {
+			if c.Name == cr.Name {
+				qs |= c.Quirks
+			}
+		}
+	}
+	return qs
+}
+
+// WriteResult writes r to the directory, as a JSON file named after the
+// controller. It replaces the result recorded before for the same name.
+func WriteResult(dir string, r Result) error {
+	b, err := json.MarshalIndent(r, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, fileName(r.Name)), append(b, '\n'), 0644)
+}
+
+// LoadResults reads the results of the JSON files of the directory. A missing
+// directory holds no results.
+func LoadResults(dir string) ([]Result, error) {
+	fs, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var rs []Result
+	for _, f := range fs {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var r Result
+		if err := json.Unmarshal(b, &r); err != nil {
+			return nil, &os.PathError{Op: "parse", Path: f, Err: err}
+		}
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+// fileName returns the file name of the result of the controller, e.g.
+// csr8510-a10.json for "CSR8510 A10".
+func fileName(name string) string {
+	f := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return '-'
+	}, name)
+	return f + ".json"
+}
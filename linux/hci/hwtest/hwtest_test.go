@@ -0,0 +1,58 @@
+package hwtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kirbo/ble/linux/hci"
+)
+
+func TestRun(t *testing.T) {
+	cases := []Case{
+		{Name: "pass", Run: func(*hci.HCI) error { return nil }},
+		{Name: "skip", Quirks: hci.QuirkNoExtAdv, Run: func(*hci.HCI) error { return ErrSkipped }},
+		{Name: "fail", Quirks: hci.QuirkNoLEStates, Run: func(*hci.HCI) error { return errors.New("stalled") }},
+	}
+	r := Run(&hci.HCI{}, "Test Dongle", cases)
+	for i, want := range []Status{Pass, Skip, Fail} {
+		if r.Cases[i].Status != want {
+			t.Errorf("%s: got %s, want %s", r.Cases[i].Name, r.Cases[i].Status, want)
+		}
+	}
+	if qs := r.Needs(cases); qs != hci.QuirkNoLEStates {
+		t.Errorf("Needs() = %b, want QuirkNoLEStates", qs)
+	}
+
+	dir, err := ioutil.TempDir("", "hwtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := WriteResult(dir, r); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := LoadResults(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || rs[0].Name != r.Name || len(rs[0].Cases) != 3 || rs[0].Cases[2].Err != "stalled" {
+		t.Errorf("LoadResults() = %+v, want [%+v]", rs, r)
+	}
+}
+
+// TestRecordedQuirks checks that the quirks, which the recorded controllers
+// call for, are applied to them.
+func TestRecordedQuirks(t *testing.T) {
+	rs, err := LoadResults("results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rs {
+		need, got := r.Needs(Suite), hci.QuirksFor(r.Version)
+		if need&^got != 0 {
+			t.Errorf("%s (%+v) needs quirks %b, which none of the quirks matching it applies", r.Name, r.Version, need&^got)
+		}
+	}
+}
@@ -0,0 +1,1016 @@
+package hci
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+// Bits of the LE Supported Features of the isochronous channels, and of the
+// host feature, which the CISes need. [Vol 6, Part B, 4.6]
+const (
+	leFeatureCISCentral     = 28
+	leFeatureISOBroadcaster = 30
+	leFeatureSyncReceiver   = 31
+	hostFeatureCIS          = 32
+)
+
+// Packet boundary flags of HCI ISO Data Packets. [Vol 4, Part E, 5.4.5]
+const (
+	isoPBFirst    = 0x00
+	isoPBContinue = 0x01
+	isoPBComplete = 0x02
+	isoPBLast     = 0x03
+)
+
+// Packet status flags of the SDUs received. [Vol 4, Part E, 5.4.5]
+const isoStatusLost = 0x02
+
+// Limits of the isochronous channel parameters. [Vol 4, Part E, 7.8.97 & 7.8.103]
+const (
+	isoMaxSDU         = 0x0FFF
+	isoMinSDUInterval = 0x0000FF * time.Microsecond
+	isoMaxSDUInterval = 0x0FFFFF * time.Microsecond
+	isoMinLatency     = 5 * time.Millisecond
+	isoMaxLatency     = 4000 * time.Millisecond
+	isoMaxCIS         = 0x1F
+	isoMaxBIS         = 0x1F
+	isoMaxHandle      = 0xEF // Of the CIGs and BIGs.
+)
+
+// isoDefaultLatency is the maximum transport latency, unless it's set.
+const isoDefaultLatency = 20 * time.Millisecond
+
+// isoCancelTimeout is how long the channels, which are being created when
+// ctx is done, are waited for to be canceled.
+const isoCancelTimeout = time.Second
+
+// isoRxQueue is the number of SDUs received, which are queued until read.
+// Those which don't fit are dropped, as they'd be late anyway.
+const isoRxQueue = 16
+
+// Errors of the isochronous channels.
+var (
+	errISOParams  = errors.New("invalid isochronous channel parameters")
+	errISOConn    = errors.New("CISes are created on the connections of the device, as the central")
+	errISOSDU     = errors.New("SDU exceeds the maximum SDU of the channel")
+	errISOAdvSet  = errors.New("BIGs are broadcast by extended, non-connectable and non-scannable advertising sets")
+	errISOHandles = errors.New("no more isochronous groups")
+	errISOClosed  = errors.New("isochronous channel closed")
+)
+
+// isoSupported reports whether the controller supports any kind of the
+// isochronous channels.
+func (h *HCI) isoSupported() bool {
+	return h.leFeatures&(1<<leFeatureCISCentral|1<<leFeatureISOBroadcaster|1<<leFeatureSyncReceiver) != 0
+}
+
+// initISO reads the ISO buffers of the controller, and sets the host feature
+// of the CISes, once the controller is reset.
+func (h *HCI) initISO() {
+	h.resetISO()
+	if !h.isoSupported() {
+		return
+	}
+	rp := cmd.LEReadBufferSizeV2RP{}
+	if err := h.Send(&cmd.LEReadBufferSizeV2{}, &rp); err != nil {
+		h.log(ble.LogHCI).Warn("can't read ISO buffer size", "err", err)
+		return
+	}
+	h.isoBufSize = int(rp.ISODataPacketLength)
+	h.isoCredits = make(chan struct{}, rp.TotalNumISODataPackets)
+	for i := 0; i < int(rp.TotalNumISODataPackets); i++ {
+		h.isoCredits <- struct{}{}
+	}
+	if h.leFeatures&(1<<leFeatureCISCentral) != 0 {
+		h.Send(&cmd.LESetHostFeature{BitNumber: hostFeatureCIS, BitValue: 1}, nil)
+	}
+}
+
+// resetISO closes the isochronous channels, as the controller is reset.
+func (h *HCI) resetISO() {
+	h.isoMu.Lock()
+	chans := h.isoChans
+	h.isoChans = make(map[uint16]*ISOChannel)
+	h.isoWaits = make(map[int]chan []byte)
+	h.cigs = make(map[uint8]int)
+	h.bigs = make(map[uint8]*BIG)
+	h.isoBufSize, h.isoCredits = 0, nil
+	h.isoMu.Unlock()
+	for _, c := range chans {
+		c.release()
+	}
+}
+
+// isoKey identifies the event of the subevent code, which completes the
+// creation of the channel or group of the handle.
+func isoKey(subcode int, handle uint16) int {
+	return subcode<<16 | int(handle)
+}
+
+// isoExpect returns the channel, which the event of k is sent to.
+func (h *HCI) isoExpect(k int) chan []byte {
+	ch := make(chan []byte, 1)
+	h.isoMu.Lock()
+	h.isoWaits[k] = ch
+	h.isoMu.Unlock()
+	return ch
+}
+
+func (h *HCI) isoForget(k int) {
+	h.isoMu.Lock()
+	delete(h.isoWaits, k)
+	h.isoMu.Unlock()
+}
+
+// isoWait waits for the event of k, until ctx is done.
+func (h *HCI) isoWait(ctx context.Context, k int, ch chan []byte) ([]byte, error) {
+	select {
+	case b := <-ch:
+		return b, nil
+	case <-ctx.Done():
+		h.isoForget(k)
+		return nil, ctx.Err()
+	case <-h.done:
+		return nil, h.err
+	}
+}
+
+// isoWaitCanceled waits, for a while, for the event of k, which the creation
+// being canceled completes with.
+func (h *HCI) isoWaitCanceled(k int, ch chan []byte) []byte {
+	ctx, cancel := context.WithTimeout(context.Background(), isoCancelTimeout)
+	defer cancel()
+	b, _ := h.isoWait(ctx, k, ch)
+	return b
+}
+
+// isoEvent returns a handler of the events, which complete the creations of
+// the channels and groups, of the 8 or 16 bit handle at b[at:]. The events
+// of a negative at are of no handle.
+func (h *HCI) isoEvent(at int, handle16 bool) handlerFn {
+	return func(b []byte) error {
+		var handle uint16
+		switch {
+		case at < 0:
+		case handle16 && len(b) >= at+2:
+			handle = binary.LittleEndian.Uint16(b[at:])
+		case !handle16 && len(b) > at:
+			handle = uint16(b[at])
+		default:
+			return nil
+		}
+		k := isoKey(int(b[0]), handle)
+		h.isoMu.Lock()
+		ch, ok := h.isoWaits[k]
+		delete(h.isoWaits, k)
+		h.isoMu.Unlock()
+		if ok {
+			ch <- b
+		}
+		return nil
+	}
+}
+
+// An ISOChannel is a CIS or a BIS, which carries SDUs over the data path of
+// the HCI. Each Write sends an SDU, and each Read receives one.
+type ISOChannel struct {
+	h      *HCI
+	handle uint16
+	maxSDU int // Largest SDU sent; 0 if the channel isn't sent on.
+
+	cig uint8 // CIG of the CIS.
+	big *BIG  // BIG of the BIS, or nil for a CIS.
+
+	wmu      sync.Mutex
+	seq      uint16
+	inflight int // Packets not completed yet; guarded by h.isoMu.
+
+	rx     []byte // SDU being reassembled, which the event loop owns.
+	rxLen  int
+	rxSkip bool
+	chIn   chan []byte
+
+	closeOnce sync.Once
+	chDone    chan struct{}
+}
+
+// newISOChannel adds the channel of the handle, which the HCI ISO Data
+// Packets are delivered to.
+func (h *HCI) newISOChannel(handle uint16, maxSDU int) *ISOChannel {
+	c := &ISOChannel{
+		h:      h,
+		handle: handle,
+		maxSDU: maxSDU,
+		chIn:   make(chan []byte, isoRxQueue),
+		chDone: make(chan struct{}),
+	}
+	h.isoMu.Lock()
+	h.isoChans[handle] = c
+	h.isoMu.Unlock()
+	return c
+}
+
+// Handle returns the connection handle of the channel.
+func (c *ISOChannel) Handle() uint16 {
+	return c.handle
+}
+
+// MaxSDU returns the largest SDU, which is written to the channel, or 0 if
+// the channel only receives.
+func (c *ISOChannel) MaxSDU() int {
+	return c.maxSDU
+}
+
+// Read reads the next SDU received into b, or returns io.EOF once the channel
+// is closed.
+func (c *ISOChannel) Read(b []byte) (int, error) {
+	select {
+	case sdu := <-c.chIn:
+		if len(b) < len(sdu) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(b, sdu), nil
+	case <-c.chDone:
+		return 0, io.EOF
+	}
+}
+
+// Write sends b as an SDU, which is fragmented into the ISO buffers of the
+// controller. The SDUs are sent in the intervals of the channel, by the
+// order they are written.
+func (c *ISOChannel) Write(b []byte) (int, error) {
+	if len(b) > c.maxSDU {
+		return 0, errISOSDU
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	// The first fragment carries the sequence number and the length of
+	// the SDU. [Vol 4, Part E, 5.4.5]
+	sdu := make([]byte, 4+len(b))
+	binary.LittleEndian.PutUint16(sdu, c.seq)
+	binary.LittleEndian.PutUint16(sdu[2:], uint16(len(b)))
+	copy(sdu[4:], b)
+	c.seq++
+
+	pb := uint16(isoPBFirst)
+	for first := true; first || len(sdu) > 0; first = false {
+		n := len(sdu)
+		if n > c.h.isoBufSize {
+			n = c.h.isoBufSize
+		}
+		switch {
+		case first && n == len(sdu):
+			pb = isoPBComplete
+		case !first && n == len(sdu):
+			pb = isoPBLast
+		case !first:
+			pb = isoPBContinue
+		}
+		select {
+		case <-c.h.isoCredits:
+		case <-c.chDone:
+			return 0, errISOClosed
+		case <-c.h.done:
+			return 0, c.h.err
+		}
+		c.h.isoMu.Lock()
+		c.inflight++
+		c.h.isoMu.Unlock()
+
+		p := make([]byte, 5+n)
+		p[0] = pktTypeISOData
+		binary.LittleEndian.PutUint16(p[1:], c.handle|pb<<12)
+		binary.LittleEndian.PutUint16(p[3:], uint16(n))
+		copy(p[5:], sdu[:n])
+		if _, err := c.h.skt.Write(p); err != nil {
+			return 0, err
+		}
+		sdu = sdu[n:]
+	}
+	return len(b), nil
+}
+
+// Close disconnects the CIS, or terminates the BIG of the BIS, along with
+// its other BISes.
+func (c *ISOChannel) Close() error {
+	if c.big != nil {
+		return c.big.Close()
+	}
+	select {
+	case <-c.chDone:
+		return nil
+	default:
+	}
+	err := c.h.Send(&cmd.Disconnect{ConnectionHandle: c.handle, Reason: 0x13}, nil)
+	if err == ErrConnID {
+		return nil
+	}
+	return err
+}
+
+// Disconnected returns a channel, which is closed when the channel is.
+func (c *ISOChannel) Disconnected() <-chan struct{} {
+	return c.chDone
+}
+
+// release closes the channel, and returns the buffers it had in flight, as
+// the controller flushes them.
+func (c *ISOChannel) release() {
+	c.closeOnce.Do(func() {
+		c.h.isoMu.Lock()
+		n := c.inflight
+		c.inflight = 0
+		credits := c.h.isoCredits
+		c.h.isoMu.Unlock()
+		for i := 0; i < n && credits != nil; i++ {
+			select {
+			case credits <- struct{}{}:
+			default:
+			}
+		}
+		close(c.chDone)
+	})
+}
+
+// removeISOChannel closes the channel of the handle. It reports whether the
+// handle was of a channel.
+func (h *HCI) removeISOChannel(handle uint16) (*ISOChannel, bool) {
+	h.isoMu.Lock()
+	c, ok := h.isoChans[handle]
+	delete(h.isoChans, handle)
+	h.isoMu.Unlock()
+	if ok {
+		c.release()
+	}
+	return c, ok
+}
+
+// handleISODisconnect closes the CIS of the disconnected handle, and removes
+// its CIG along with the last CIS of it. It reports whether the handle was of
+// a CIS.
+func (h *HCI) handleISODisconnect(handle uint16) bool {
+	c, ok := h.removeISOChannel(handle)
+	if !ok {
+		return false
+	}
+	if c.big != nil {
+		return true
+	}
+	h.isoMu.Lock()
+	h.cigs[c.cig]--
+	last := h.cigs[c.cig] == 0
+	if last {
+		delete(h.cigs, c.cig)
+	}
+	h.isoMu.Unlock()
+	if last {
+		// Commands are not sent from the event loop, which handles their
+		// completion.
+		go h.Send(&cmd.LERemoveCIG{CIGID: c.cig}, nil)
+	}
+	return true
+}
+
+// isoCompleted returns the buffers of the packets the controller completed,
+// if the handle is of a channel.
+func (h *HCI) isoCompleted(handle uint16, n int) {
+	h.isoMu.Lock()
+	c, ok := h.isoChans[handle]
+	if ok {
+		if n > c.inflight {
+			n = c.inflight
+		}
+		c.inflight -= n
+	}
+	credits := h.isoCredits
+	h.isoMu.Unlock()
+	for i := 0; ok && i < n; i++ {
+		select {
+		case credits <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// handleISO reassembles the SDUs of the HCI ISO Data Packets, and queues
+// them to the channel of the handle. The lost SDUs are skipped.
+func (h *HCI) handleISO(b []byte) error {
+	if len(b) < 4 {
+		return nil
+	}
+	v := binary.LittleEndian.Uint16(b)
+	handle, pb, ts := v&0x0FFF, (v>>12)&0x03, v&(1<<14) != 0
+	data := b[4:]
+	if n := int(binary.LittleEndian.Uint16(b[2:]) & 0x3FFF); n < len(data) {
+		data = data[:n]
+	}
+	h.isoMu.Lock()
+	c, ok := h.isoChans[handle]
+	h.isoMu.Unlock()
+	if !ok {
+		h.log(ble.LogHCI).Warn("invalid connection handle on ISO packet", "handle", handle)
+		return nil
+	}
+
+	if pb == isoPBFirst || pb == isoPBComplete {
+		if ts {
+			if len(data) < 4 {
+				return nil
+			}
+			data = data[4:]
+		}
+		if len(data) < 4 {
+			return nil
+		}
+		l := binary.LittleEndian.Uint16(data[2:])
+		c.rx = append([]byte(nil), data[4:]...)
+		c.rxLen = int(l & 0x0FFF)
+		c.rxSkip = l>>14 == isoStatusLost
+	} else {
+		c.rx = append(c.rx, data...)
+	}
+	if pb == isoPBFirst || pb == isoPBContinue {
+		return nil
+	}
+	sdu := c.rx
+	c.rx = nil
+	if c.rxSkip || len(sdu) != c.rxLen || len(sdu) == 0 {
+		return nil
+	}
+	select {
+	case c.chIn <- sdu:
+	default:
+	}
+	return nil
+}
+
+// setupISODataPath sets up the data path of the HCI, which carries the SDUs of
+// the channel as they are, in the direction.
+func (h *HCI) setupISODataPath(handle uint16, dir uint8) error {
+	return h.Send(&cmd.LESetupISODataPath{
+		ConnectionHandle:  handle,
+		DataPathDirection: dir,
+		DataPathID:        0x00,                      // HCI
+		CodecID:           [5]byte{0x03, 0, 0, 0, 0}, // Transparent
+	}, nil)
+}
+
+// isoPHY returns the PHYs p, or LE 2M if 0.
+func isoPHY(p uint8) uint8 {
+	if p == 0 {
+		return 0x02
+	}
+	return p
+}
+
+// isoInterval returns the 3 bytes of the SDU interval in microseconds.
+func isoInterval(d time.Duration) [3]byte {
+	us := uint32(d / time.Microsecond)
+	return [3]byte{uint8(us), uint8(us >> 8), uint8(us >> 16)}
+}
+
+// isoLatency returns the maximum transport latency in milliseconds, or that of
+// isoDefaultLatency if d is 0.
+func isoLatency(d time.Duration) uint16 {
+	if d == 0 {
+		d = isoDefaultLatency
+	}
+	return uint16(d / time.Millisecond)
+}
+
+func validISOTiming(interval, latency time.Duration, retransmissions int) bool {
+	return interval >= isoMinSDUInterval && interval <= isoMaxSDUInterval &&
+		(latency == 0 || latency >= isoMinLatency && latency <= isoMaxLatency) &&
+		retransmissions >= 0 && retransmissions <= 0xFF
+}
+
+// CISParams are the parameters of the CISes, which the central creates with
+// CreateCIS. Out are the SDUs to the peripheral, and In those from it.
+type CISParams struct {
+	SDUInterval     time.Duration // Interval of the SDUs, from 255µs to 1.048575s.
+	Framed          bool          // The SDUs are framed, e.g. as they're not of the ISO interval.
+	MaxLatency      time.Duration // Maximum transport latency, from 5ms to 4s; 20ms if 0.
+	MaxSDUOut       int           // Largest SDU sent, up to 4095 bytes; 0 if none are.
+	MaxSDUIn        int           // Largest SDU received, up to 4095 bytes; 0 if none are.
+	PHY             uint8         // Bit mask of LE 1M (0x01), LE 2M (0x02) and LE Coded (0x04); LE 2M if 0.
+	Retransmissions int           // Retransmissions of each packet, up to 255.
+}
+
+// CreateCIS creates a CIG of a CIS on each of the connections, which the
+// device is the central of, and returns the channels of the CISes, in the
+// order of the connections. The CIG is removed along with the last CIS of it.
+func (h *HCI) CreateCIS(ctx context.Context, p CISParams, conns ...ble.Conn) ([]*ISOChannel, error) {
+	if err := h.requireCentral("CreateCIS"); err != nil {
+		return nil, err
+	}
+	if h.leFeatures&(1<<leFeatureCISCentral) == 0 || h.isoCredits == nil {
+		return nil, ErrISONotSupported
+	}
+	if len(conns) == 0 || len(conns) > isoMaxCIS || !validISOTiming(p.SDUInterval, p.MaxLatency, p.Retransmissions) ||
+		p.MaxSDUOut < 0 || p.MaxSDUOut > isoMaxSDU || p.MaxSDUIn < 0 || p.MaxSDUIn > isoMaxSDU || p.MaxSDUOut+p.MaxSDUIn == 0 {
+		return nil, errISOParams
+	}
+	acls := make([]uint16, len(conns))
+	for i, conn := range conns {
+		c, ok := conn.(*Conn)
+		if !ok || c.hci != h || c.param.Role() != roleMaster {
+			return nil, errISOConn
+		}
+		acls[i] = c.param.ConnectionHandle()
+	}
+
+	id, err := h.allocCIG()
+	if err != nil {
+		return nil, err
+	}
+	sp := &cmd.LESetCIGParameters{
+		CIGID:                   id,
+		SDUIntervalCToP:         isoInterval(p.SDUInterval),
+		SDUIntervalPToC:         isoInterval(p.SDUInterval),
+		MaxTransportLatencyCToP: isoLatency(p.MaxLatency),
+		MaxTransportLatencyPToC: isoLatency(p.MaxLatency),
+	}
+	if p.Framed {
+		sp.Framing = 0x01
+	}
+	for i := range conns {
+		sp.CIS = append(sp.CIS, cmd.CISConfig{
+			CISID:      uint8(i),
+			MaxSDUCToP: uint16(p.MaxSDUOut),
+			MaxSDUPToC: uint16(p.MaxSDUIn),
+			PHYCToP:    isoPHY(p.PHY),
+			PHYPToC:    isoPHY(p.PHY),
+			RTNCToP:    uint8(p.Retransmissions),
+			RTNPToC:    uint8(p.Retransmissions),
+		})
+	}
+	rp := cmd.LESetCIGParametersRP{}
+	if err := h.Send(sp, &rp); err != nil {
+		h.freeCIG(id)
+		return nil, err
+	}
+	if len(rp.ConnectionHandle) != len(conns) {
+		h.Send(&cmd.LERemoveCIG{CIGID: id}, nil)
+		h.freeCIG(id)
+		return nil, errISOParams
+	}
+
+	cc := &cmd.LECreateCIS{}
+	keys := make([]int, len(conns))
+	waits := make([]chan []byte, len(conns))
+	for i, cis := range rp.ConnectionHandle {
+		cc.CIS = append(cc.CIS, cmd.CISConnection{CISConnectionHandle: cis, ACLConnectionHandle: acls[i]})
+		keys[i] = isoKey(evt.LECISEstablishedSubCode, cis)
+		waits[i] = h.isoExpect(keys[i])
+	}
+	if err := h.Send(cc, nil); err != nil {
+		for _, k := range keys {
+			h.isoForget(k)
+		}
+		h.Send(&cmd.LERemoveCIG{CIGID: id}, nil)
+		h.freeCIG(id)
+		return nil, err
+	}
+
+	// The CISes established are channels from now on, which are closed on
+	// failure, and remove the CIG along with the last of them.
+	h.isoMu.Lock()
+	h.cigs[id] = len(conns)
+	h.isoMu.Unlock()
+	chans := make([]*ISOChannel, len(conns))
+	fail := func(i int, err error) ([]*ISOChannel, error) {
+		for j := i; j < len(conns); j++ {
+			if chans[j] != nil {
+				continue
+			}
+			// Disconnecting a CIS being established cancels it.
+			h.Send(&cmd.Disconnect{ConnectionHandle: rp.ConnectionHandle[j], Reason: 0x13}, nil)
+			if e := h.isoWaitCanceled(keys[j], waits[j]); e != nil && evt.LECISEstablished(e).Status() == 0x00 {
+				chans[j] = h.newISOChannel(rp.ConnectionHandle[j], p.MaxSDUOut)
+				chans[j].cig = id
+				continue
+			}
+			h.handleISODisconnectPending(id)
+		}
+		for _, c := range chans {
+			if c != nil {
+				c.Close()
+			}
+		}
+		return nil, err
+	}
+	for i := range conns {
+		b, err := h.isoWait(ctx, keys[i], waits[i])
+		if err != nil {
+			return fail(i, err)
+		}
+		if s := evt.LECISEstablished(b).Status(); s != 0x00 {
+			h.handleISODisconnectPending(id)
+			return fail(i+1, ErrCommand(s))
+		}
+		chans[i] = h.newISOChannel(rp.ConnectionHandle[i], p.MaxSDUOut)
+		chans[i].cig = id
+	}
+	for _, c := range chans {
+		if p.MaxSDUOut > 0 {
+			if err := h.setupISODataPath(c.handle, cmd.ISODataPathInput); err != nil {
+				return fail(len(conns), err)
+			}
+		}
+		if p.MaxSDUIn > 0 {
+			if err := h.setupISODataPath(c.handle, cmd.ISODataPathOutput); err != nil {
+				return fail(len(conns), err)
+			}
+		}
+	}
+	return chans, nil
+}
+
+// handleISODisconnectPending accounts for a CIS of the CIG, which failed to be
+// established, as it's disconnected.
+func (h *HCI) handleISODisconnectPending(id uint8) {
+	h.isoMu.Lock()
+	h.cigs[id]--
+	last := h.cigs[id] == 0
+	if last {
+		delete(h.cigs, id)
+	}
+	h.isoMu.Unlock()
+	if last {
+		h.Send(&cmd.LERemoveCIG{CIGID: id}, nil)
+	}
+}
+
+// allocCIG reserves a CIG ID, until the CIG is created, or freed.
+func (h *HCI) allocCIG() (uint8, error) {
+	h.isoMu.Lock()
+	defer h.isoMu.Unlock()
+	for i := 0; i <= isoMaxHandle; i++ {
+		if _, ok := h.cigs[uint8(i)]; !ok {
+			h.cigs[uint8(i)] = 0
+			return uint8(i), nil
+		}
+	}
+	return 0, errISOHandles
+}
+
+func (h *HCI) freeCIG(id uint8) {
+	h.isoMu.Lock()
+	delete(h.cigs, id)
+	h.isoMu.Unlock()
+}
+
+// A BIG is a group of BISes, which an advertising set broadcasts, or which the
+// device is synchronized to.
+type BIG struct {
+	h      *HCI
+	handle uint8
+	chans  []*ISOChannel
+
+	set        *AdvertisingSet // Of the BIG broadcast.
+	syncHandle uint16          // Of the periodic advertising of the BIG synchronized to.
+
+	closeOnce sync.Once
+	err       error
+}
+
+// Handle returns the handle of the BIG.
+func (b *BIG) Handle() uint8 {
+	return b.handle
+}
+
+// Channels returns the channels of the BISes, in their order: those which are
+// written of a broadcast, and those which are read of a BIG synchronized to.
+func (b *BIG) Channels() []*ISOChannel {
+	return b.chans
+}
+
+// Close terminates the BIG broadcast, along with the periodic advertising of
+// its set, or the synchronization to it.
+func (b *BIG) Close() error {
+	b.closeOnce.Do(func() {
+		h := b.h
+		if b.set != nil {
+			k := isoKey(evt.LETerminateBIGCompleteSubCode, uint16(b.handle))
+			ch := h.isoExpect(k)
+			if b.err = h.Send(&cmd.LETerminateBIG{BIGHandle: b.handle, Reason: 0x16}, nil); b.err == nil {
+				h.isoWaitCanceled(k, ch)
+			} else {
+				h.isoForget(k)
+			}
+			h.Send(&cmd.LESetPeriodicAdvertisingEnable{Enable: 0, AdvertisingHandle: b.set.handle}, nil)
+		} else {
+			b.err = h.Send(&cmd.LEBIGTerminateSync{BIGHandle: b.handle}, &cmd.LEBIGTerminateSyncRP{})
+			h.Send(&cmd.LEPeriodicAdvertisingTerminateSync{SyncHandle: b.syncHandle}, nil)
+		}
+		b.release()
+	})
+	return b.err
+}
+
+// release closes the channels of the BIG, and frees its handle.
+func (b *BIG) release() {
+	for _, c := range b.chans {
+		b.h.removeISOChannel(c.handle)
+	}
+	b.h.freeBIG(b.handle)
+}
+
+// handleLEBIGSyncLost closes the BIG, which the device lost the
+// synchronization to.
+func (h *HCI) handleLEBIGSyncLost(b []byte) error {
+	e := evt.LEBIGSyncLost(b)
+	h.isoMu.Lock()
+	big := h.bigs[e.BIGHandle()]
+	h.isoMu.Unlock()
+	if big == nil {
+		return nil
+	}
+	h.log(ble.LogHCI).Info("BIG sync lost", "handle", e.BIGHandle(), "reason", ErrCommand(e.Reason()))
+	big.closeOnce.Do(func() {
+		big.err = ErrCommand(e.Reason())
+		big.release()
+		go h.Send(&cmd.LEPeriodicAdvertisingTerminateSync{SyncHandle: big.syncHandle}, nil)
+	})
+	return nil
+}
+
+// allocBIG reserves a BIG handle, until the BIG is created, or freed.
+func (h *HCI) allocBIG() (uint8, error) {
+	h.isoMu.Lock()
+	defer h.isoMu.Unlock()
+	for i := 0; i <= isoMaxHandle; i++ {
+		if _, ok := h.bigs[uint8(i)]; !ok {
+			h.bigs[uint8(i)] = nil
+			return uint8(i), nil
+		}
+	}
+	return 0, errISOHandles
+}
+
+func (h *HCI) freeBIG(handle uint8) {
+	h.isoMu.Lock()
+	delete(h.bigs, handle)
+	h.isoMu.Unlock()
+}
+
+// newBIG adds the BIG of the handle, with the channels of the BISes.
+func (h *HCI) newBIG(handle uint8, bises []uint16, maxSDU int) *BIG {
+	big := &BIG{h: h, handle: handle}
+	for _, bis := range bises {
+		c := h.newISOChannel(bis, maxSDU)
+		c.big = big
+		big.chans = append(big.chans, c)
+	}
+	h.isoMu.Lock()
+	h.bigs[handle] = big
+	h.isoMu.Unlock()
+	return big
+}
+
+// BIGParams are the parameters of a BIG, which an advertising set broadcasts.
+type BIGParams struct {
+	NumBIS           int           // Number of BISes, up to 31.
+	SDUInterval      time.Duration // Interval of the SDUs, from 255µs to 1.048575s.
+	Framed           bool          // The SDUs are framed, e.g. as they're not of the ISO interval.
+	MaxLatency       time.Duration // Maximum transport latency, from 5ms to 4s; 20ms if 0.
+	MaxSDU           int           // Largest SDU, up to 4095 bytes.
+	PHY              uint8         // Bit mask of LE 1M (0x01), LE 2M (0x02) and LE Coded (0x04); LE 2M if 0.
+	Retransmissions  int           // Retransmissions of each packet, up to 255.
+	BroadcastCode    []byte        // The 16 bytes, which encrypt the BIG; unencrypted if nil.
+	PeriodicInterval time.Duration // Of the periodic advertising, which carries the BIGInfo, from 7.5ms; 100ms if 0.
+}
+
+// CreateBIG broadcasts a BIG, along with the periodic advertising of the set,
+// which carries the BIGInfo the receivers synchronize with. The set is to be
+// extended, non-connectable and non-scannable, and started.
+func (s *AdvertisingSet) CreateBIG(ctx context.Context, p BIGParams) (*BIG, error) {
+	h := s.h
+	if h.leFeatures&(1<<leFeatureISOBroadcaster) == 0 || h.isoCredits == nil {
+		return nil, ErrISONotSupported
+	}
+	if s.props != 0 {
+		return nil, errISOAdvSet
+	}
+	interval := p.PeriodicInterval
+	if interval == 0 {
+		interval = 100 * time.Millisecond
+	}
+	// In units of 1.25ms. [Vol 4, Part E, 7.8.61]
+	units := interval / (1250 * time.Microsecond)
+	if p.NumBIS < 1 || p.NumBIS > isoMaxBIS || p.MaxSDU < 1 || p.MaxSDU > isoMaxSDU ||
+		!validISOTiming(p.SDUInterval, p.MaxLatency, p.Retransmissions) ||
+		units < 0x0006 || units > 0xFFFF || p.BroadcastCode != nil && len(p.BroadcastCode) != 16 {
+		return nil, errISOParams
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := h.Send(&cmd.LESetPeriodicAdvertisingParameters{
+		AdvertisingHandle:              s.handle,
+		PeriodicAdvertisingIntervalMin: uint16(units),
+		PeriodicAdvertisingIntervalMax: uint16(units),
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Send(&cmd.LESetPeriodicAdvertisingEnable{Enable: 1, AdvertisingHandle: s.handle}, nil); err != nil {
+		return nil, err
+	}
+	stopPeriodic := func() {
+		h.Send(&cmd.LESetPeriodicAdvertisingEnable{Enable: 0, AdvertisingHandle: s.handle}, nil)
+	}
+
+	handle, err := h.allocBIG()
+	if err != nil {
+		stopPeriodic()
+		return nil, err
+	}
+	c := &cmd.LECreateBIG{
+		BIGHandle:           handle,
+		AdvertisingHandle:   s.handle,
+		NumBIS:              uint8(p.NumBIS),
+		SDUInterval:         isoInterval(p.SDUInterval),
+		MaxSDU:              uint16(p.MaxSDU),
+		MaxTransportLatency: isoLatency(p.MaxLatency),
+		RTN:                 uint8(p.Retransmissions),
+		PHY:                 isoPHY(p.PHY),
+	}
+	if p.Framed {
+		c.Framing = 0x01
+	}
+	if p.BroadcastCode != nil {
+		c.Encryption = 0x01
+		copy(c.BroadcastCode[:], p.BroadcastCode)
+	}
+	k := isoKey(evt.LECreateBIGCompleteSubCode, uint16(handle))
+	ch := h.isoExpect(k)
+	if err := h.Send(c, nil); err != nil {
+		h.isoForget(k)
+		h.freeBIG(handle)
+		stopPeriodic()
+		return nil, err
+	}
+	b, err := h.isoWait(ctx, k, ch)
+	if err != nil {
+		// Terminating the BIG being created cancels it.
+		ch = h.isoExpect(k)
+		h.Send(&cmd.LETerminateBIG{BIGHandle: handle, Reason: 0x16}, nil)
+		h.isoWaitCanceled(k, ch)
+		h.freeBIG(handle)
+		stopPeriodic()
+		return nil, err
+	}
+	e := evt.LECreateBIGComplete(b)
+	if e.Status() != 0x00 {
+		h.freeBIG(handle)
+		stopPeriodic()
+		return nil, ErrCommand(e.Status())
+	}
+	big := h.newBIG(handle, e.ConnectionHandles(), p.MaxSDU)
+	big.set = s
+	for _, c := range big.chans {
+		if err := h.setupISODataPath(c.handle, cmd.ISODataPathInput); err != nil {
+			big.Close()
+			return nil, err
+		}
+	}
+	return big, nil
+}
+
+// BIGSyncParams are the parameters of the synchronization to a BIG.
+type BIGSyncParams struct {
+	BIS           []int         // Indices of the BISes received, from 1.
+	BroadcastCode []byte        // The 16 bytes, which decrypt an encrypted BIG.
+	Timeout       time.Duration // Of the synchronization, from 100ms to 163.84s; 2s if 0.
+}
+
+// SyncBIG synchronizes to the periodic advertising of the set of the SID,
+// which the advertiser of the address broadcasts a BIG along with, and to the
+// BISes of the BIG. The device is to be scanning, with extended scanning, so
+// that the controller receives the periodic advertising.
+func (h *HCI) SyncBIG(ctx context.Context, a ble.Addr, sid int, p BIGSyncParams) (*BIG, error) {
+	if h.leFeatures&(1<<leFeatureSyncReceiver) == 0 || h.isoCredits == nil {
+		return nil, ErrISONotSupported
+	}
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 2 * time.Second
+	}
+	// In units of 10ms. [Vol 4, Part E, 7.8.67 & 7.8.106]
+	units := timeout / (10 * time.Millisecond)
+	if len(p.BIS) == 0 || len(p.BIS) > isoMaxBIS || sid < 0 || sid > 0x0F ||
+		units < 0x000A || units > 0x4000 || p.BroadcastCode != nil && len(p.BroadcastCode) != 16 {
+		return nil, errISOParams
+	}
+	bis := make([]uint8, len(p.BIS))
+	for i, n := range p.BIS {
+		if n < 1 || n > isoMaxBIS {
+			return nil, errISOParams
+		}
+		bis[i] = uint8(n)
+	}
+	addr, err := parseAddr(a)
+	if err != nil {
+		return nil, err
+	}
+
+	// The controller creates a periodic advertising sync at a time.
+	h.isoSyncMu.Lock()
+	cs := &cmd.LEPeriodicAdvertisingCreateSync{
+		Options:           0x02, // Reporting initially disabled
+		AdvertisingSID:    uint8(sid),
+		AdvertiserAddress: addr,
+		SyncTimeout:       uint16(units),
+	}
+	if ble.AddrTypeOf(a) == ble.AddrTypeRandom {
+		cs.AdvertiserAddressType = 0x01
+	}
+	k := isoKey(evt.LEPeriodicAdvertisingSyncEstablishedSubCode, 0)
+	ch := h.isoExpect(k)
+	if err := h.Send(cs, nil); err != nil {
+		h.isoForget(k)
+		h.isoSyncMu.Unlock()
+		return nil, err
+	}
+	b, err := h.isoWait(ctx, k, ch)
+	if err != nil {
+		ch = h.isoExpect(k)
+		if h.Send(&cmd.LEPeriodicAdvertisingCreateSyncCancel{}, nil) == nil {
+			b = h.isoWaitCanceled(k, ch)
+		}
+		h.isoForget(k)
+		h.isoSyncMu.Unlock()
+		// The sync may have been established before it was canceled.
+		if b != nil && evt.LEPeriodicAdvertisingSyncEstablished(b).Status() == 0x00 {
+			h.Send(&cmd.LEPeriodicAdvertisingTerminateSync{SyncHandle: evt.LEPeriodicAdvertisingSyncEstablished(b).SyncHandle()}, nil)
+		}
+		return nil, err
+	}
+	h.isoSyncMu.Unlock()
+	se := evt.LEPeriodicAdvertisingSyncEstablished(b)
+	if se.Status() != 0x00 {
+		return nil, ErrCommand(se.Status())
+	}
+	syncHandle := se.SyncHandle()
+	terminateSync := func() {
+		h.Send(&cmd.LEPeriodicAdvertisingTerminateSync{SyncHandle: syncHandle}, nil)
+	}
+
+	handle, err := h.allocBIG()
+	if err != nil {
+		terminateSync()
+		return nil, err
+	}
+	bs := &cmd.LEBIGCreateSync{
+		BIGHandle:      handle,
+		SyncHandle:     syncHandle,
+		BIGSyncTimeout: uint16(units),
+		BIS:            bis,
+	}
+	if p.BroadcastCode != nil {
+		bs.Encryption = 0x01
+		copy(bs.BroadcastCode[:], p.BroadcastCode)
+	}
+	k = isoKey(evt.LEBIGSyncEstablishedSubCode, uint16(handle))
+	ch = h.isoExpect(k)
+	if err := h.Send(bs, nil); err != nil {
+		h.isoForget(k)
+		h.freeBIG(handle)
+		terminateSync()
+		return nil, err
+	}
+	if b, err = h.isoWait(ctx, k, ch); err != nil {
+		// Terminating the sync being created cancels it.
+		ch = h.isoExpect(k)
+		h.Send(&cmd.LEBIGTerminateSync{BIGHandle: handle}, &cmd.LEBIGTerminateSyncRP{})
+		h.isoWaitCanceled(k, ch)
+		h.freeBIG(handle)
+		terminateSync()
+		return nil, err
+	}
+	e := evt.LEBIGSyncEstablished(b)
+	if e.Status() != 0x00 {
+		h.freeBIG(handle)
+		terminateSync()
+		return nil, ErrCommand(e.Status())
+	}
+	big := h.newBIG(handle, e.ConnectionHandles(), 0)
+	big.syncHandle = syncHandle
+	for _, c := range big.chans {
+		if err := h.setupISODataPath(c.handle, cmd.ISODataPathOutput); err != nil {
+			big.Close()
+			return nil, err
+		}
+	}
+	return big, nil
+}
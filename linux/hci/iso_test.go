@@ -0,0 +1,77 @@
+package hci
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kirbo/ble"
+)
+
+// isoSocket records the packets written to it.
+type isoSocket struct {
+	bytes.Buffer
+	pkts [][]byte
+}
+
+func (s *isoSocket) Write(b []byte) (int, error) {
+	s.pkts = append(s.pkts, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (s *isoSocket) Close() error { return nil }
+
+func TestISOChannel(t *testing.T) {
+	skt := &isoSocket{}
+	h := &HCI{skt: skt, logger: ble.DefaultLogger, done: make(chan bool)}
+	h.resetISO()
+	h.isoBufSize = 8
+	h.isoCredits = make(chan struct{}, 4)
+	for i := 0; i < 4; i++ {
+		h.isoCredits <- struct{}{}
+	}
+	c := h.newISOChannel(0x0060, 10)
+
+	// An SDU of 10 bytes, and its header, in fragments of 8 bytes.
+	if _, err := c.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]byte{
+		append([]byte{pktTypeISOData, 0x60, 0x00, 0x08, 0x00, 0x00, 0x00, 0x0A, 0x00}, "0123"...),
+		append([]byte{pktTypeISOData, 0x60, 0x30, 0x06, 0x00}, "456789"...),
+	}
+	if len(skt.pkts) != 2 || !bytes.Equal(skt.pkts[0], want[0]) || !bytes.Equal(skt.pkts[1], want[1]) {
+		t.Errorf("got packets [% X], want [% X]", skt.pkts, want)
+	}
+	if _, err := c.Write(make([]byte, 11)); err != errISOSDU {
+		t.Errorf("Write() of 11 bytes = %v, want errISOSDU", err)
+	}
+	h.isoCompleted(0x0060, 2)
+	if n := len(h.isoCredits); n != 4 {
+		t.Errorf("%d credits after the packets completed, want 4", n)
+	}
+
+	// An SDU in two fragments, with a time stamp, and a lost one.
+	for _, p := range [][]byte{
+		{0x60, 0x40, 0x0A, 0x00, 1, 2, 3, 4, 0x00, 0x00, 0x05, 0x00, 'h', 'e'},
+		{0x60, 0x30, 0x03, 0x00, 'l', 'l', 'o'},
+		{0x60, 0x20, 0x04, 0x00, 0x01, 0x00, 0x00, 0x80},
+	} {
+		if err := h.handleISO(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := make([]byte, 16)
+	if n, err := c.Read(b); err != nil || string(b[:n]) != "hello" {
+		t.Errorf("Read() = %q, %v, want hello", b[:n], err)
+	}
+	if len(c.chIn) != 0 {
+		t.Errorf("%d SDUs queued, want the lost one skipped", len(c.chIn))
+	}
+
+	if !h.handleISODisconnect(0x0060) {
+		t.Fatal("handleISODisconnect() = false, want the channel closed")
+	}
+	if _, err := c.Read(b); err == nil {
+		t.Error("Read() of a closed channel succeeded")
+	}
+}
@@ -1,7 +1,8 @@
 package hci
 
-import (
-	"github.com/mgutz/logxi/v1"
-)
+import "github.com/kirbo/ble"
 
-var logger = log.New("hci")
+// log returns the logger of subsystem sub.
+func (h *HCI) log(sub string) ble.SubsystemLogger {
+	return ble.SubsystemLogger{Logger: h.logger, Subsystem: sub}
+}
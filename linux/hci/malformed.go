@@ -0,0 +1,33 @@
+package hci
+
+import (
+	"fmt"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+// validEvent returns an error, if the event packet b is shorter than its
+// header tells, or its parameters are too short for their fields.
+func validEvent(b []byte) error {
+	if len(b) < 2 || int(b[1]) != len(b)-2 {
+		return fmt.Errorf("%w: length mismatch", evt.ErrMalformed)
+	}
+	return evt.Validate(int(b[0]), b[2:])
+}
+
+// dropped logs, and counts, the malformed packet b of the subsystem sub,
+// which is dropped for reason.
+func (h *HCI) dropped(sub, what string, b []byte, reason interface{}) {
+	h.log(sub).Warn("malformed "+what+" dropped", "reason", reason, "pkt", fmt.Sprintf("[% X]", b))
+	h.metrics.Add(ble.MetricMalformedDropped, 1)
+}
+
+// recoverMalformed drops the packet b, whose handling panicked, as it's
+// malformed in a way the validation doesn't catch. It's deferred by the
+// handlers, if malformed packets are dropped.
+func (h *HCI) recoverMalformed(sub, what string, b []byte) {
+	if r := recover(); r != nil {
+		h.dropped(sub, what, b, r)
+	}
+}
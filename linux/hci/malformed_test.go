@@ -0,0 +1,48 @@
+package hci
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestDropMalformed(t *testing.T) {
+	s := monitor.NewReplaySocket(initRecords(), monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptDropMalformed(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// Dropped, rather than failing on them.
+	for _, b := range [][]byte{{0x3E}, {0x05, 0x01, 0x00}, {0x3E, 0x02, 0x01, 0x00}, {0x3E, 0x03, 0x02, 0x01, 0x00}} {
+		if err := h.handleEvt(b); err != nil {
+			t.Errorf("event [% X]: %v", b, err)
+		}
+	}
+
+	e := []byte{0x01, 0x00, 0x01, 0x00, roleSlave, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00}
+	c := newConn(h, e)
+	h.muConns.Lock()
+	h.conns[0x0001] = c
+	h.muConns.Unlock()
+	for _, p := range [][]byte{
+		{0x01, 0x10, 0x02, 0x00, 0x0A, 0x00},                         // A continuation, without a start.
+		{0x01, 0x20, 0x06, 0x00, 0x05, 0x00, 0x04, 0x00, 0x0A, 0x03}, // A PDU, which the next one cuts short.
+		{0x01, 0x20, 0x07, 0x00, 0x03, 0x00, 0x04, 0x00, 0x0A, 0x03, 0x00},
+	} {
+		if err := h.deliverACL(p, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := make([]byte, ble.DefaultMTU)
+	n, err := c.Read(b)
+	if err != nil || !bytes.Equal(b[:n], []byte{0x0A, 0x03, 0x00}) {
+		t.Errorf("read [% X], %v, want the Read Request of the last PDU", b[:n], err)
+	}
+}
@@ -0,0 +1,198 @@
+// Package monitor captures HCI traffic, either from the kernel's HCI monitor
+// channel, which sees all the controllers of the host, or from the library's
+// own socket, and writes it in the btsnoop or pcap formats read by Wireshark.
+// Btsnoop captures can be played back to the host with a ReplaySocket.
+package monitor
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction of a packet, relative to the host.
+type Direction int
+
+// Directions.
+const (
+	Sent Direction = iota
+	Received
+)
+
+// H4 packet types, which prefix the packets passing through an HCI socket. [Vol 4, Part A, 2]
+const (
+	pktTypeCommand = 0x01
+	pktTypeACLData = 0x02
+	pktTypeSCOData = 0x03
+	pktTypeEvent   = 0x04
+	pktTypeISOData = 0x05
+)
+
+// A Writer writes H4 packets, including the packet type, to a capture file.
+type Writer interface {
+	WritePacket(t time.Time, dir Direction, h4 []byte) error
+}
+
+// btsnoopEpoch is the offset of the Unix epoch in btsnoop timestamps, which
+// count microseconds since midnight, January 1st, 0 AD.
+const btsnoopEpoch = 0x00dcddb30f2f8000
+
+// btsnoopH4 is the btsnoop datalink type of packets with the H4 packet type.
+const btsnoopH4 = 1002
+
+// BtsnoopWriter writes packets in the btsnoop format.
+type BtsnoopWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewBtsnoopWriter writes the btsnoop file header to w, and returns a
+// Writer for the packets.
+func NewBtsnoopWriter(w io.Writer) (*BtsnoopWriter, error) {
+	hdr := make([]byte, 16)
+	copy(hdr, "btsnoop\x00")
+	binary.BigEndian.PutUint32(hdr[8:], 1) // Version
+	binary.BigEndian.PutUint32(hdr[12:], btsnoopH4)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &BtsnoopWriter{w: w}, nil
+}
+
+// WritePacket writes a packet record.
+func (b *BtsnoopWriter) WritePacket(t time.Time, dir Direction, h4 []byte) error {
+	var flags uint32
+	if dir == Received {
+		flags |= 0x01
+	}
+	if len(h4) > 0 && (h4[0] == pktTypeCommand || h4[0] == pktTypeEvent) {
+		flags |= 0x02
+	}
+	rec := make([]byte, 24+len(h4))
+	binary.BigEndian.PutUint32(rec[0:], uint32(len(h4))) // Original length
+	binary.BigEndian.PutUint32(rec[4:], uint32(len(h4))) // Included length
+	binary.BigEndian.PutUint32(rec[8:], flags)
+	binary.BigEndian.PutUint32(rec[12:], 0) // Cumulative drops
+	binary.BigEndian.PutUint64(rec[16:], uint64(t.UnixNano()/1000+btsnoopEpoch))
+	copy(rec[24:], h4)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, err := b.w.Write(rec)
+	return err
+}
+
+// ErrNotBtsnoop is returned by NewBtsnoopReader for files which aren't
+// btsnoop files of H4 packets.
+var ErrNotBtsnoop = errors.New("not a btsnoop file of H4 packets")
+
+// BtsnoopReader reads packets in the btsnoop format, as written by
+// BtsnoopWriter.
+type BtsnoopReader struct {
+	r io.Reader
+}
+
+// NewBtsnoopReader reads the btsnoop file header from r, and returns a
+// reader of the packets.
+func NewBtsnoopReader(r io.Reader) (*BtsnoopReader, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	if string(hdr[:8]) != "btsnoop\x00" || binary.BigEndian.Uint32(hdr[12:]) != btsnoopH4 {
+		return nil, ErrNotBtsnoop
+	}
+	return &BtsnoopReader{r: r}, nil
+}
+
+// ReadPacket reads the next packet record. It returns io.EOF at the end of
+// the file.
+func (b *BtsnoopReader) ReadPacket() (t time.Time, dir Direction, h4 []byte, err error) {
+	rec := make([]byte, 24)
+	if _, err := io.ReadFull(b.r, rec); err != nil {
+		return time.Time{}, 0, nil, err
+	}
+	h4 = make([]byte, binary.BigEndian.Uint32(rec[4:]))
+	if _, err := io.ReadFull(b.r, h4); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return time.Time{}, 0, nil, err
+	}
+	if binary.BigEndian.Uint32(rec[8:])&0x01 != 0 {
+		dir = Received
+	}
+	us := int64(binary.BigEndian.Uint64(rec[16:]) - btsnoopEpoch)
+	return time.Unix(0, us*1000), dir, h4, nil
+}
+
+// linkTypeH4WithPHDR is LINKTYPE_BLUETOOTH_HCI_H4_WITH_PHDR, whose packets
+// are prefixed with a 4 byte direction.
+const linkTypeH4WithPHDR = 201
+
+// PcapWriter writes packets in the pcap format.
+type PcapWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewPcapWriter writes the pcap file header to w, and returns a Writer for
+// the packets.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], 0xa1b2c3d4) // Magic
+	binary.LittleEndian.PutUint16(hdr[4:], 2)          // Major version
+	binary.LittleEndian.PutUint16(hdr[6:], 4)          // Minor version
+	binary.LittleEndian.PutUint32(hdr[16:], 65535)     // Snapshot length
+	binary.LittleEndian.PutUint32(hdr[20:], linkTypeH4WithPHDR)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// WritePacket writes a packet record.
+func (p *PcapWriter) WritePacket(t time.Time, dir Direction, h4 []byte) error {
+	rec := make([]byte, 16+4+len(h4))
+	binary.LittleEndian.PutUint32(rec[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(rec[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(rec[8:], uint32(4+len(h4)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(4+len(h4)))
+	binary.BigEndian.PutUint32(rec[16:], uint32(dir)) // The pseudo-header is big endian.
+	copy(rec[20:], h4)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.w.Write(rec)
+	return err
+}
+
+// CaptureSocket passes the packets read from and written to an HCI socket
+// through to a Writer.
+type CaptureSocket struct {
+	io.ReadWriteCloser
+	w Writer
+}
+
+// NewCaptureSocket returns s, with its traffic captured by w.
+func NewCaptureSocket(s io.ReadWriteCloser, w Writer) *CaptureSocket {
+	return &CaptureSocket{ReadWriteCloser: s, w: w}
+}
+
+func (s *CaptureSocket) Read(p []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Read(p)
+	if n > 0 {
+		s.w.WritePacket(time.Now(), Received, p[:n])
+	}
+	return n, err
+}
+
+func (s *CaptureSocket) Write(p []byte) (int, error) {
+	n, err := s.ReadWriteCloser.Write(p)
+	if n > 0 {
+		s.w.WritePacket(time.Now(), Sent, p[:n])
+	}
+	return n, err
+}
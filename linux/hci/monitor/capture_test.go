@@ -0,0 +1,46 @@
+package monitor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBtsnoopWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewBtsnoopWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(time.Unix(0, 0), Received, []byte{pktTypeEvent, 0x0e, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		'b', 't', 's', 'n', 'o', 'o', 'p', 0, 0, 0, 0, 1, 0, 0, 0x03, 0xea,
+		0, 0, 0, 3, 0, 0, 0, 3, 0, 0, 0, 3, 0, 0, 0, 0,
+		0x00, 0xdc, 0xdd, 0xb3, 0x0f, 0x2f, 0x80, 0x00,
+		0x04, 0x0e, 0x00,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got % x, want % x", buf.Bytes(), want)
+	}
+}
+
+func TestPcapWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewPcapWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WritePacket(time.Unix(1, 2000), Received, []byte{pktTypeCommand}); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xd4, 0xc3, 0xb2, 0xa1, 2, 0, 4, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0, 0, 201, 0, 0, 0,
+		1, 0, 0, 0, 2, 0, 0, 0, 5, 0, 0, 0, 5, 0, 0, 0,
+		0, 0, 0, 1, 0x01,
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("got % x, want % x", buf.Bytes(), want)
+	}
+}
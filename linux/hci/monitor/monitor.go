@@ -0,0 +1,143 @@
+//go:build linux
+// +build linux
+
+package monitor
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// hciDevNone binds a socket to no device in particular.
+const hciDevNone = 0xffff
+
+// monitorHdrSize is the size of the opcode, index and length of a frame.
+const monitorHdrSize = 6
+
+// Opcodes of the monitor channel frames.
+const (
+	OpNewIndex    = 0
+	OpDelIndex    = 1
+	OpCommandPkt  = 2
+	OpEventPkt    = 3
+	OpACLTxPkt    = 4
+	OpACLRxPkt    = 5
+	OpSCOTxPkt    = 6
+	OpSCORxPkt    = 7
+	OpOpenIndex   = 8
+	OpCloseIndex  = 9
+	OpIndexInfo   = 10
+	OpVendorDiag  = 11
+	OpSystemNote  = 12
+	OpUserLogging = 13
+	OpISOTxPkt    = 18
+	OpISORxPkt    = 19
+)
+
+// A Frame is a frame of the monitor channel.
+type Frame struct {
+	Opcode uint16
+	Index  uint16 // Controller index, e.g. 0 for hci0.
+	Time   time.Time
+	Data   []byte
+}
+
+// H4 returns the HCI packet of the frame, prefixed with its H4 packet type,
+// and its direction. It returns false for frames which aren't HCI packets.
+func (f Frame) H4() (Direction, []byte, bool) {
+	var dir Direction
+	var typ byte
+	switch f.Opcode {
+	case OpCommandPkt:
+		dir, typ = Sent, pktTypeCommand
+	case OpEventPkt:
+		dir, typ = Received, pktTypeEvent
+	case OpACLTxPkt:
+		dir, typ = Sent, pktTypeACLData
+	case OpACLRxPkt:
+		dir, typ = Received, pktTypeACLData
+	case OpSCOTxPkt:
+		dir, typ = Sent, pktTypeSCOData
+	case OpSCORxPkt:
+		dir, typ = Received, pktTypeSCOData
+	case OpISOTxPkt:
+		dir, typ = Sent, pktTypeISOData
+	case OpISORxPkt:
+		dir, typ = Received, pktTypeISOData
+	default:
+		return 0, nil, false
+	}
+	return dir, append([]byte{typ}, f.Data...), true
+}
+
+// Monitor is a socket bound to the HCI monitor channel, which receives a
+// copy of the traffic of all the controllers. It requires CAP_NET_RAW.
+type Monitor struct {
+	fd  int
+	buf []byte
+}
+
+// Open opens the HCI monitor channel.
+func Open() (*Monitor, error) {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_HCI)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create socket")
+	}
+	sa := unix.SockaddrHCI{Dev: hciDevNone, Channel: unix.HCI_CHANNEL_MONITOR}
+	if err := unix.Bind(fd, &sa); err != nil {
+		unix.Close(fd)
+		return nil, errors.Wrap(err, "can't bind socket to hci monitor channel")
+	}
+	return &Monitor{fd: fd, buf: make([]byte, monitorHdrSize+65535)}, nil
+}
+
+// ReadFrame reads the next frame.
+func (m *Monitor) ReadFrame() (Frame, error) {
+	n, err := unix.Read(m.fd, m.buf)
+	if err != nil {
+		return Frame{}, errors.Wrap(err, "can't read hci monitor channel")
+	}
+	if n < monitorHdrSize {
+		return Frame{}, errors.New("short hci monitor frame")
+	}
+	b := m.buf[:n]
+	f := Frame{
+		Opcode: binary.LittleEndian.Uint16(b[0:]),
+		Index:  binary.LittleEndian.Uint16(b[2:]),
+		Time:   time.Now(),
+	}
+	l := int(binary.LittleEndian.Uint16(b[4:]))
+	if l > n-monitorHdrSize {
+		return Frame{}, errors.New("truncated hci monitor frame")
+	}
+	f.Data = append([]byte(nil), b[monitorHdrSize:monitorHdrSize+l]...)
+	return f, nil
+}
+
+// Capture writes the HCI packets of the controller with index to w, or of
+// all the controllers if index is negative, until reading fails, e.g.
+// because the Monitor is closed.
+func (m *Monitor) Capture(w Writer, index int) error {
+	for {
+		f, err := m.ReadFrame()
+		if err != nil {
+			return err
+		}
+		if index >= 0 && int(f.Index) != index {
+			continue
+		}
+		if dir, h4, ok := f.H4(); ok {
+			if err := w.WritePacket(f.Time, dir, h4); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Close closes the monitor channel.
+func (m *Monitor) Close() error {
+	return unix.Close(m.fd)
+}
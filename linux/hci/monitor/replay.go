@@ -0,0 +1,194 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// A Record is a packet of a capture.
+type Record struct {
+	Time time.Time
+	Dir  Direction
+	H4   []byte
+}
+
+// ReadBtsnoop reads the records of a btsnoop file, such as one captured
+// with ble.WithHCICapture.
+func ReadBtsnoop(r io.Reader) ([]Record, error) {
+	br, err := NewBtsnoopReader(r)
+	if err != nil {
+		return nil, err
+	}
+	var recs []Record
+	for {
+		t, dir, h4, err := br.ReadPacket()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, Record{Time: t, Dir: dir, H4: h4})
+	}
+}
+
+// ErrReplayEnded is returned by the writes to a ReplaySocket past the end
+// of its records.
+var ErrReplayEnded = errors.New("replay: no more packets expected")
+
+// A MismatchError is returned by the write to a ReplaySocket of a packet
+// which doesn't match the recorded one.
+type MismatchError struct {
+	Index int // Index of the record.
+	Got   []byte
+	Want  []byte
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("replay: record %d: sent [% X], want [% X]", e.Index, e.Got, e.Want)
+}
+
+// A MatchFunc reports whether a packet sent by the host matches the recorded
+// one. Both include the H4 packet type.
+type MatchFunc func(got, want []byte) bool
+
+// MatchExact matches identical packets.
+func MatchExact(got, want []byte) bool {
+	return string(got) == string(want)
+}
+
+// MatchOpcode matches commands of the same opcode, and ACL data of the same
+// connection handle, regardless of their parameters and payload, which may
+// vary from run to run, e.g. random addresses or keys.
+func MatchOpcode(got, want []byte) bool {
+	if len(got) < 3 || len(want) < 3 || got[0] != want[0] {
+		return MatchExact(got, want)
+	}
+	switch got[0] {
+	case pktTypeCommand:
+		return got[1] == want[1] && got[2] == want[2]
+	case pktTypeACLData:
+		return got[1] == want[1] && got[2]&0x0F == want[2]&0x0F
+	}
+	return MatchExact(got, want)
+}
+
+// ReplaySocket is an HCI socket, which plays the controller of a capture
+// back to the host, e.g. with ble.OptTransport, for regression testing.
+//
+// The packets sent by the host are matched against the recorded ones, in
+// order. A received packet is read once the host has sent the packets
+// recorded before it, so the responses and events follow the commands as
+// they did in the capture, regardless of timing.
+type ReplaySocket struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	recs  []Record
+	match MatchFunc
+
+	tx     int // Index of the next record sent by the host.
+	rx     int // Index of the next record received by the host.
+	err    error
+	closed bool
+}
+
+// NewReplaySocket returns a ReplaySocket which plays recs back, and matches
+// the packets sent with match, or MatchExact if it is nil.
+func NewReplaySocket(recs []Record, match MatchFunc) *ReplaySocket {
+	if match == nil {
+		match = MatchExact
+	}
+	s := &ReplaySocket{recs: recs, match: match}
+	s.cond = sync.NewCond(&s.mu)
+	s.skip()
+	return s
+}
+
+// skip moves the cursors to the next records of their directions.
+func (s *ReplaySocket) skip() {
+	for s.tx < len(s.recs) && s.recs[s.tx].Dir != Sent {
+		s.tx++
+	}
+	for s.rx < len(s.recs) && s.recs[s.rx].Dir != Received {
+		s.rx++
+	}
+}
+
+// Read reads the next received packet, once the host has sent the packets
+// which precede it. At the end of the records, it blocks until the socket
+// is closed, and then returns io.EOF.
+func (s *ReplaySocket) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.closed && (s.rx >= len(s.recs) || s.tx < s.rx) {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return 0, io.EOF
+	}
+	h4 := s.recs[s.rx].H4
+	if len(p) < len(h4) {
+		return 0, io.ErrShortBuffer
+	}
+	s.rx++
+	s.skip()
+	return copy(p, h4), nil
+}
+
+// Write matches the packet against the next one sent in the records.
+func (s *ReplaySocket) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.tx >= len(s.recs) {
+		s.err = ErrReplayEnded
+		return 0, s.err
+	}
+	if want := s.recs[s.tx].H4; !s.match(p, want) {
+		s.err = &MismatchError{Index: s.tx, Got: append([]byte(nil), p...), Want: want}
+		return 0, s.err
+	}
+	s.tx++
+	s.skip()
+	s.cond.Broadcast()
+	return len(p), nil
+}
+
+// Close closes the socket, and unblocks the reads.
+func (s *ReplaySocket) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.cond.Broadcast()
+	return nil
+}
+
+// Err returns the error of the first write which didn't match the records,
+// if any, or ErrReplayEnded if the host sent more packets than recorded.
+func (s *ReplaySocket) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Remaining returns the number of records which haven't been played back
+// yet, in either direction.
+func (s *ReplaySocket) Remaining() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for i, r := range s.recs {
+		if (r.Dir == Sent && i >= s.tx) || (r.Dir == Received && i >= s.rx) {
+			n++
+		}
+	}
+	return n
+}
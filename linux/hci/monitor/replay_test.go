@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBtsnoopRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewBtsnoopWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Record{
+		{Time: time.Unix(1, 0), Dir: Sent, H4: []byte{pktTypeCommand, 0x03, 0x0c, 0x00}},
+		{Time: time.Unix(2, 0), Dir: Received, H4: []byte{pktTypeEvent, 0x0e, 0x04, 0x01, 0x03, 0x0c, 0x00}},
+	}
+	for _, r := range want {
+		if err := w.WritePacket(r.Time, r.Dir, r.H4); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := ReadBtsnoop(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Time.Equal(want[i].Time) || got[i].Dir != want[i].Dir || !bytes.Equal(got[i].H4, want[i].H4) {
+			t.Errorf("record %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReplaySocket(t *testing.T) {
+	reset := []byte{pktTypeCommand, 0x03, 0x0c, 0x00}
+	complete := []byte{pktTypeEvent, 0x0e, 0x04, 0x01, 0x03, 0x0c, 0x00}
+	s := NewReplaySocket([]Record{
+		{Dir: Sent, H4: reset},
+		{Dir: Received, H4: complete},
+	}, nil)
+
+	rd := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, 16)
+		n, err := s.Read(b)
+		if err != nil {
+			rd <- nil
+			return
+		}
+		rd <- b[:n]
+	}()
+	select {
+	case <-rd:
+		t.Fatal("read before the command was sent")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	if _, err := s.Write(reset); err != nil {
+		t.Fatal(err)
+	}
+	if b := <-rd; !bytes.Equal(b, complete) {
+		t.Fatalf("read [% X], want [% X]", b, complete)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("Remaining() = %d, want 0", n)
+	}
+	if _, err := s.Write(reset); err != ErrReplayEnded {
+		t.Errorf("Write() past the end = %v, want ErrReplayEnded", err)
+	}
+
+	s.Close()
+	if _, err := s.Read(make([]byte, 16)); err != io.EOF {
+		t.Errorf("Read() after Close = %v, want io.EOF", err)
+	}
+}
+
+func TestReplaySocketMismatch(t *testing.T) {
+	s := NewReplaySocket([]Record{{Dir: Sent, H4: []byte{pktTypeCommand, 0x03, 0x0c, 0x00}}}, nil)
+	if _, err := s.Write([]byte{pktTypeCommand, 0x09, 0x10, 0x00}); err == nil {
+		t.Fatal("mismatched write succeeded")
+	}
+	if _, ok := s.Err().(*MismatchError); !ok {
+		t.Errorf("Err() = %v, want a MismatchError", s.Err())
+	}
+
+	s = NewReplaySocket([]Record{{Dir: Sent, H4: []byte{pktTypeCommand, 0x05, 0x20, 0x06, 1, 2, 3, 4, 5, 6}}}, MatchOpcode)
+	if _, err := s.Write([]byte{pktTypeCommand, 0x05, 0x20, 0x06, 6, 5, 4, 3, 2, 1}); err != nil {
+		t.Errorf("Write() with other parameters = %v, want nil with MatchOpcode", err)
+	}
+}
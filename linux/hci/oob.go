@@ -0,0 +1,77 @@
+package hci
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/smp"
+)
+
+// oobState is the LE Secure Connections OOB data of the device, along with
+// the P-256 key pair it was generated with, and the OOB data of the peers.
+type oobState struct {
+	key   *ecdsa.PrivateKey
+	local ble.OOBData
+	peers map[string]ble.OOBData
+}
+
+// LocalOOBData generates the P-256 key pair, which the LE Secure Connections
+// pairing is to use, along with a random value, and returns the OOB data to
+// pass to the peer, e.g. over NFC or a QR code. Each call replaces the key
+// pair, which invalidates the OOB data returned before.
+func (h *HCI) LocalOOBData() (ble.OOBData, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return ble.OOBData{}, err
+	}
+	d := ble.OOBData{Addr: h.Addr()}
+	_, d.Random = d.Addr.(RandomAddress)
+	if _, err := rand.Read(d.Rand[:]); err != nil {
+		return ble.OOBData{}, err
+	}
+	pkx := key.X.Bytes()
+	pkx = append(make([]byte, 32-len(pkx)), pkx...)
+	c := smp.F4(pkx, pkx, reverse(d.Rand[:]), 0x00)
+	copy(d.Confirm[:], reverse(c[:]))
+
+	h.oobMu.Lock()
+	defer h.oobMu.Unlock()
+	h.oob.key = key
+	h.oob.local = d
+	return d, nil
+}
+
+// SetPeerOOBData sets the OOB data, which the peer of its address passed out
+// of band, for the pairing with the peer. It replaces the data set before
+// for the address.
+func (h *HCI) SetPeerOOBData(d ble.OOBData) error {
+	if d.Addr == nil {
+		return ble.ErrInvalidOOBData
+	}
+	h.oobMu.Lock()
+	defer h.oobMu.Unlock()
+	if h.oob.peers == nil {
+		h.oob.peers = make(map[string]ble.OOBData)
+	}
+	h.oob.peers[strings.ToLower(d.Addr.String())] = d
+	return nil
+}
+
+// PeerOOBData returns the OOB data set by SetPeerOOBData for the peer a.
+func (h *HCI) PeerOOBData(a ble.Addr) (ble.OOBData, bool) {
+	h.oobMu.Lock()
+	defer h.oobMu.Unlock()
+	d, ok := h.oob.peers[strings.ToLower(a.String())]
+	return d, ok
+}
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i, v := range b {
+		r[len(b)-1-i] = v
+	}
+	return r
+}
@@ -0,0 +1,37 @@
+package hci
+
+import (
+	"net"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/smp"
+)
+
+func TestLocalOOBData(t *testing.T) {
+	h := &HCI{addr: net.HardwareAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}}
+	d, err := h.LocalOOBData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Addr.String() != "11:22:33:44:55:66" || d.Random {
+		t.Errorf("got address %s, random %v, want 11:22:33:44:55:66, public", d.Addr, d.Random)
+	}
+	pkx := h.oob.key.X.Bytes()
+	pkx = append(make([]byte, 32-len(pkx)), pkx...)
+	c := smp.F4(pkx, pkx, reverse(d.Rand[:]), 0x00)
+	if string(reverse(c[:])) != string(d.Confirm[:]) {
+		t.Errorf("confirm [% X], want f4(PKx, PKx, r, 0) = [% X]", d.Confirm, reverse(c[:]))
+	}
+
+	if err := h.SetPeerOOBData(ble.OOBData{}); err != ble.ErrInvalidOOBData {
+		t.Errorf("SetPeerOOBData() without the address = %v, want ErrInvalidOOBData", err)
+	}
+	p := ble.OOBData{Addr: ble.NewAddr("AA:BB:CC:DD:EE:FF"), Random: true}
+	if err := h.SetPeerOOBData(p); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := h.PeerOOBData(ble.NewAddr("aa:bb:cc:dd:ee:ff")); !ok || !got.Random {
+		t.Errorf("PeerOOBData() = %+v, %v, want %+v", got, ok, p)
+	}
+}
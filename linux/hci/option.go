@@ -3,8 +3,10 @@ package hci
 import (
 	"errors"
 	"github.com/kirbo/ble/linux/hci/evt"
+	"io"
 	"time"
 
+	"github.com/kirbo/ble"
 	"github.com/kirbo/ble/linux/hci/cmd"
 )
 
@@ -56,12 +58,434 @@ func (h *HCI) SetAdvParams(param cmd.LESetAdvertisingParameters) error {
 	return nil
 }
 
-// SetPeripheralRole is not supported
+// scanInterval returns the scan interval, or window, d in units of 0.625 ms.
+// [Vol 2, Part E, 7.8.10]
+func scanInterval(d time.Duration) (uint16, error) {
+	n := d / (625 * time.Microsecond)
+	if n < 0x0004 || n > 0x4000 {
+		return 0, errors.New("scan interval out of range")
+	}
+	return uint16(n), nil
+}
+
+// SetScanInterval sets the interval of scanning.
+func (h *HCI) SetScanInterval(d time.Duration) error {
+	n, err := scanInterval(d)
+	if err != nil {
+		return err
+	}
+	h.params.scanParams.LEScanInterval = n
+	return nil
+}
+
+// SetScanWindow sets the time scanned of each interval.
+func (h *HCI) SetScanWindow(d time.Duration) error {
+	n, err := scanInterval(d)
+	if err != nil {
+		return err
+	}
+	h.params.scanParams.LEScanWindow = n
+	return nil
+}
+
+// SetActiveScan sets active, or passive, scanning.
+func (h *HCI) SetActiveScan(active bool) error {
+	h.params.scanParams.LEScanType = 0x00
+	if active {
+		h.params.scanParams.LEScanType = scanTypeActive
+	}
+	return nil
+}
+
+// SetScanPHYs sets the PHYs of the extended scanning.
+func (h *HCI) SetScanPHYs(m ble.ScanPHY) error {
+	if m == 0 || m&^(ble.ScanPHY1M|ble.ScanPHYCoded) != 0 {
+		return errors.New("invalid scanning PHYs")
+	}
+	h.scanPHYs = m
+	return nil
+}
+
+// advInterval returns the advertising interval d in units of 0.625 ms.
+// [Vol 2, Part E, 7.8.5]
+func advInterval(d time.Duration) (uint16, error) {
+	n := d / (625 * time.Microsecond)
+	if n < 0x0020 || n > 0x4000 {
+		return 0, errors.New("advertising interval out of range")
+	}
+	return uint16(n), nil
+}
+
+// SetAdvIntervalMin sets the minimum advertising interval.
+func (h *HCI) SetAdvIntervalMin(d time.Duration) error {
+	n, err := advInterval(d)
+	if err != nil {
+		return err
+	}
+	h.params.advParams.AdvertisingIntervalMin = n
+	return nil
+}
+
+// SetAdvIntervalMax sets the maximum advertising interval.
+func (h *HCI) SetAdvIntervalMax(d time.Duration) error {
+	n, err := advInterval(d)
+	if err != nil {
+		return err
+	}
+	h.params.advParams.AdvertisingIntervalMax = n
+	return nil
+}
+
+// SetAdvChannelMap sets the primary channels to advertise on.
+func (h *HCI) SetAdvChannelMap(m ble.AdvChannel) error {
+	if m == 0 || m&^ble.AdvChannelAll != 0 {
+		return errors.New("invalid advertising channel map")
+	}
+	h.params.advParams.AdvertisingChannelMap = uint8(m)
+	return nil
+}
+
+// SetAdvOwnAddressType sets the address the device advertises with. A random
+// address is generated, unless set by SetRandomAddress.
+func (h *HCI) SetAdvOwnAddressType(t ble.OwnAddressType) error {
+	switch t {
+	case ble.OwnAddressPublic, ble.OwnAddressRPA:
+	case ble.OwnAddressRandom:
+		if !h.useRandom {
+			h.useRandom, h.randomAddr = true, nil
+		}
+	default:
+		return errors.New("invalid own address type")
+	}
+	h.advOwnAddr = t
+	h.params.advParams.OwnAddressType = h.advOwnAddrType(h.params.advParams.OwnAddressType)
+	return nil
+}
+
+// SetDedupCache configures host-side suppression of duplicate advertisements.
+func (h *HCI) SetDedupCache(key ble.DedupKey, ttl time.Duration) error {
+	h.dedupKey = key
+	h.dedupTTL = ttl
+	return nil
+}
+
+// SetFilterDuplicates enables or disables duplicate filtering, both in the
+// controller and on the host, regardless of the allowDup argument of Scan.
+func (h *HCI) SetFilterDuplicates(enable bool) error {
+	h.filterDupSet = true
+	h.filterDup = enable
+	if enable && h.dedupTTL == 0 {
+		h.dedupTTL = defaultDedupTTL
+	}
+	return nil
+}
+
+// SetBondStore sets the BondStore, which is attached to the context of each connection.
+func (h *HCI) SetBondStore(s ble.BondStore) error {
+	h.bondStore = s
+	return nil
+}
+
+// SetRemoteInfo enables reading the version and the features of the remote
+// controller on connect.
+func (h *HCI) SetRemoteInfo(enable bool) error {
+	h.remoteInfo = enable
+	return nil
+}
+
+// SetConnMonitor sets the ConnMonitor of the connections, whose link quality
+// is polled every poll, unless it's 0.
+func (h *HCI) SetConnMonitor(m ble.ConnMonitor, poll time.Duration) error {
+	h.connMonitor, h.monitorPoll = m, poll
+	return nil
+}
+
+// SetIntervalTuning tunes the parameters of the connections to their
+// traffic, as t tells.
+func (h *HCI) SetIntervalTuning(t ble.IntervalTuning) error {
+	t, err := t.WithDefaults()
+	if err != nil {
+		return err
+	}
+	h.tuning = &t
+	return nil
+}
+
+// SetPreferredConnParams sets the connection parameters, which the
+// peripheral requests of the centrals, and tells in the Peripheral Preferred
+// Connection Parameters characteristic of the Device.
+func (h *HCI) SetPreferredConnParams(p ble.PreferredConnParams) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	h.prefParams = &p
+	return nil
+}
+
+// SetPreferredPHY sets the PHYs the connections prefer.
+func (h *HCI) SetPreferredPHY(p ble.PreferredPHY) error {
+	if (p.Tx|p.Rx)&^(ble.PHY1M|ble.PHY2M|ble.PHYCoded) != 0 {
+		return errors.New("invalid PHYs")
+	}
+	h.prefPHY = p
+	return nil
+}
+
+// SetGATTCache enables the GATT discovery cache of the connections.
+func (h *HCI) SetGATTCache(enable bool) error {
+	h.gattCache = enable
+	return nil
+}
+
+// SetScanBuffer sets the size of the advertising report queue, and the
+// callback for dropped reports.
+func (h *HCI) SetScanBuffer(size int, onOverflow func(dropped int)) error {
+	if size <= 0 {
+		return errors.New("invalid scan buffer size")
+	}
+	h.scanBufSize = size
+	h.scanOverflow = onOverflow
+	return nil
+}
+
+// SetHCICapture captures the traffic of the HCI socket to w, in the btsnoop format.
+func (h *HCI) SetHCICapture(w io.Writer) error {
+	h.capture = w
+	return nil
+}
+
+// SetTransport sets the transport, which is used instead of the HCI socket.
+func (h *HCI) SetTransport(t io.ReadWriteCloser) error {
+	h.transport = t
+	return nil
+}
+
+// SetSecurityPolicy sets the minimum security of the links.
+func (h *HCI) SetSecurityPolicy(p ble.SecurityPolicy) error {
+	if p.MinKeySize < 0 || p.MinKeySize > ble.MaxEncKeySize {
+		return errors.New("invalid minimum key size")
+	}
+	h.secPolicy = p
+	return nil
+}
+
+// SetIndicationPolicy sets the policy of the ATT servers of the connections.
+func (h *HCI) SetIndicationPolicy(p ble.IndicationPolicy) error {
+	h.indPolicy = p
+	return nil
+}
+
+// SetLogger sets the Logger of the device and its connections.
+func (h *HCI) SetLogger(l ble.Logger) error {
+	if l == nil {
+		l = ble.DefaultLogger
+	}
+	h.logger = l
+	return nil
+}
+
+// Logger returns the Logger set by SetLogger, or ble.DefaultLogger.
+func (h *HCI) Logger() ble.Logger {
+	return h.logger
+}
+
+// SetMetrics sets the MetricsCollector of the device and its connections.
+func (h *HCI) SetMetrics(m ble.MetricsCollector) error {
+	if m == nil {
+		m = ble.NopMetrics
+	}
+	h.metrics = m
+	h.latency, _ = m.(ble.LatencyObserver)
+	return nil
+}
+
+// SetMaxConnections limits the number of concurrent connections. 0 leaves
+// the limit to the controller.
+func (h *HCI) SetMaxConnections(n int) error {
+	if n < 0 {
+		return errors.New("invalid maximum number of connections")
+	}
+	h.maxConns = n
+	return nil
+}
+
+// SetScanFilters sets the filters of the advertisements, which are applied
+// once scanning starts.
+func (h *HCI) SetScanFilters(fs []ble.ScanFilter) error {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	h.scanFilters = append([]ble.ScanFilter(nil), fs...)
+	h.scanFiltersSet = false
+	return nil
+}
+
+// SetScanOffload selects the filtering which Scan may push to the controller.
+func (h *HCI) SetScanOffload(o ble.ScanOffload) error {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	h.scanOffload = o
+	h.scanFiltersSet = false
+	return nil
+}
+
+// SetAuthorizer sets the Authorizer of the ATT servers of the connections.
+func (h *HCI) SetAuthorizer(a ble.Authorizer) error {
+	h.authorizer = a
+	return nil
+}
+
+// SetExtendedScan scans with the extended commands, if the controller
+// supports extended advertising. It takes effect once the scan parameters
+// are set, on init or reset.
+func (h *HCI) SetExtendedScan(enable bool) error {
+	h.extScan = enable
+	return nil
+}
+
+// SetAuditHook sets the AuditHook of the ATT servers of the connections.
+func (h *HCI) SetAuditHook(f ble.AuditHook) error {
+	h.auditHook = f
+	return nil
+}
+
+// SetRadioHandler sets the RadioHandler of the scanning and advertising.
+func (h *HCI) SetRadioHandler(f ble.RadioHandler) error {
+	h.radio.f = f
+	return nil
+}
+
+// SetDropMalformed sets whether the malformed events and PDUs received are
+// logged and dropped.
+func (h *HCI) SetDropMalformed(enable bool) error {
+	h.dropMalformed = enable
+	return nil
+}
+
+// SetFallbackTCP sets the TCP address of the H4 stream, which is used if the
+// platform has no Bluetooth support.
+func (h *HCI) SetFallbackTCP(addr string) error {
+	h.fallbackTCP = addr
+	return nil
+}
+
+// SetAdvRestartOnDisconnect sets whether advertising is restarted, once a
+// central disconnects.
+func (h *HCI) SetAdvRestartOnDisconnect(on bool) error {
+	h.advNoRestart = !on
+	return nil
+}
+
+// SetAdvWhileConnected sets whether advertising is re-enabled while a central
+// is connected, if the controller supports it.
+func (h *HCI) SetAdvWhileConnected(on bool) error {
+	h.advNoWhileConnected = !on
+	return nil
+}
+
+// SetPacketHooks adds hooks to the chain of the PacketHooks of the packets
+// passing through the HCI socket.
+func (h *HCI) SetPacketHooks(hooks ...ble.PacketHook) error {
+	h.pktHooks = append(h.pktHooks, hooks...)
+	return nil
+}
+
+// SetMemoryLimits sets the MemoryLimits of the buffers, caches, and queues.
+func (h *HCI) SetMemoryLimits(l ble.MemoryLimits) error {
+	h.memLimits = l
+	return nil
+}
+
+// SetAutoSecurity sets the security level, which the GATT operations elevate
+// the links to, once refused for their security.
+func (h *HCI) SetAutoSecurity(l ble.SecurityLevel) error {
+	h.autoSec = l
+	return nil
+}
+
+// SetServerLimits sets the ServerLimits of the ATT servers of the connections.
+func (h *HCI) SetServerLimits(l ble.ServerLimits) error {
+	h.srvLimits = l
+	return nil
+}
+
+// ServerLimits returns the limits set by SetServerLimits.
+func (h *HCI) ServerLimits() ble.ServerLimits {
+	return h.srvLimits
+}
+
+// AuditHook returns the AuditHook set by SetAuditHook.
+func (h *HCI) AuditHook() ble.AuditHook {
+	return h.auditHook
+}
+
+// Authorizer returns the Authorizer set by SetAuthorizer.
+func (h *HCI) Authorizer() ble.Authorizer {
+	return h.authorizer
+}
+
+// IndicationPolicy returns the policy set by SetIndicationPolicy.
+func (h *HCI) IndicationPolicy() ble.IndicationPolicy {
+	return h.indPolicy
+}
+
+// SetPeripheralRole sets up the device for the peripheral role only.
 func (h *HCI) SetPeripheralRole() error {
-	return errors.New("Not supported")
+	return h.SetRole(ble.RolePeripheral)
 }
 
-// SetCentralRole is not supported
+// SetCentralRole sets up the device for the central role only.
 func (h *HCI) SetCentralRole() error {
-	return errors.New("Not supported")
+	return h.SetRole(ble.RoleCentral)
+}
+
+// SetRole sets up the device for a role, or both.
+func (h *HCI) SetRole(r ble.Role) error {
+	switch r {
+	case ble.RoleBoth, ble.RoleCentral, ble.RolePeripheral:
+		h.role = r
+		return nil
+	}
+	return errors.New("invalid role")
+}
+
+// SetStateHandler sets the handler, which is told when the adapter is up,
+// resetting, and gone.
+func (h *HCI) SetStateHandler(f func(ble.AdapterState)) error {
+	h.stateHandler = f
+	return nil
+}
+
+// SetStateRestoration is not supported; the state is CoreBluetooth's.
+func (h *HCI) SetStateRestoration(id string, f func(ble.RestoredState)) error {
+	return errors.New("state restoration not supported")
+}
+
+// SetClock sets the Clock of the device and its connections.
+func (h *HCI) SetClock(c ble.Clock) error {
+	if c == nil {
+		return errors.New("nil clock")
+	}
+	h.clock = c
+	return nil
+}
+
+// SetAdvTxPower includes the Tx Power Level of the advertising in the
+// advertising data of the Advertise methods.
+func (h *HCI) SetAdvTxPower(enable bool) error {
+	h.advTxPower = enable
+	return nil
+}
+
+// SetRFKillUnblock sets whether the adapter is soft-unblocked, as blocked by
+// rfkill, before it's opened.
+func (h *HCI) SetRFKillUnblock(on bool) error {
+	h.unblockRFKill = on
+	return nil
+}
+
+// SetShortenName sets whether the Advertise methods shorten the name, which
+// doesn't fit.
+func (h *HCI) SetShortenName(on bool) error {
+	h.shortenName = on
+	return nil
 }
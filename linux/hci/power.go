@@ -0,0 +1,103 @@
+package hci
+
+import (
+	"errors"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// dutyCycles are the scan interval and window, and the advertising interval,
+// of a power profile, in units of 0.625 ms.
+type dutyCycles struct {
+	scanInterval uint16
+	scanWindow   uint16
+	advInterval  uint16
+}
+
+var powerProfiles = map[ble.PowerProfile]dutyCycles{
+	ble.PowerAggressive: {scanInterval: 0x0060, scanWindow: 0x0060, advInterval: 0x00A0}, // 60/60 ms, 100 ms
+	ble.PowerBalanced:   {scanInterval: 0x0640, scanWindow: 0x0190, advInterval: 0x0190}, // 1000/250 ms, 250 ms
+	ble.PowerLowPower:   {scanInterval: 0x0C80, scanWindow: 0x0140, advInterval: 0x0640}, // 2000/200 ms, 1000 ms
+}
+
+// PowerProfile returns the profile set by SetPowerProfile, or 0 if none.
+func (h *HCI) PowerProfile() ble.PowerProfile {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	return h.powerProfile
+}
+
+// SetPowerProfile sets the scan and advertising parameters to the duty
+// cycles of the power profile. Once initialized, they are sent to the
+// controller right away, with scanning and advertising disabled meanwhile,
+// as their parameters may not change while enabled.
+func (h *HCI) SetPowerProfile(p ble.PowerProfile) error {
+	dc, ok := powerProfiles[p]
+	if !ok {
+		return errors.New("invalid power profile")
+	}
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	h.powerProfile = p
+	h.params.scanParams.LEScanInterval = dc.scanInterval
+	h.params.scanParams.LEScanWindow = dc.scanWindow
+	h.params.advParams.AdvertisingIntervalMin = dc.advInterval
+	h.params.advParams.AdvertisingIntervalMax = dc.advInterval
+	if h.skt == nil {
+		// Sent on init.
+		return nil
+	}
+	if h.role.Central() {
+		if err := h.sendScanParams(); err != nil {
+			return err
+		}
+	}
+	if h.role.Peripheral() {
+		return h.sendAdvParams()
+	}
+	return nil
+}
+
+// sendScanParams sends the scan parameters, pausing scanning meanwhile.
+// Must be called with roleMu held.
+func (h *HCI) sendScanParams() error {
+	scanning := h.params.scanEnable.LEScanEnable == 1 && !h.scanPaused
+	if scanning {
+		if err := h.Send(h.scanEnableCmd(0), nil); err != nil {
+			return err
+		}
+	}
+	if err := h.Send(h.scanParamsCmd(), nil); err != nil {
+		return err
+	}
+	if scanning {
+		return h.Send(h.scanEnableCmd(1), nil)
+	}
+	return nil
+}
+
+// sendAdvParams sends the advertising parameters, of the extended advertising
+// set if in use, pausing advertising meanwhile. Must be called with roleMu
+// held.
+func (h *HCI) sendAdvParams() error {
+	advertising := h.params.advEnable.AdvertisingEnable == 1 && !h.advPaused
+	if advertising {
+		if err := h.Send(h.advEnableCmd(0), nil); err != nil {
+			return err
+		}
+	}
+	if h.extAdv {
+		rp := cmd.LESetExtendedAdvertisingParametersRP{}
+		if err := h.Send(h.extAdvParamsCmd(h.extAdvProps), &rp); err != nil {
+			return err
+		}
+		h.extTxPwrLv = int(rp.SelectedTxPower)
+	} else if err := h.Send(&h.params.advParams, nil); err != nil {
+		return err
+	}
+	if advertising {
+		return h.Send(h.advEnableCmd(1), nil)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package hci
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestPowerProfile(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+	// Scanning is paused while its parameters are switched.
+	recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanParameters{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertisingParameters{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptPowerProfile(ble.PowerBalanced))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := h.params.scanParams; p.LEScanInterval != 0x0640 || p.LEScanWindow != 0x0190 {
+		t.Errorf("scan interval/window = %#x/%#x, want 0x640/0x190", p.LEScanInterval, p.LEScanWindow)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Scan(true); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.SetPowerProfile(ble.PowerLowPower); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+	if p := h.params.advParams; p.AdvertisingIntervalMin != 0x0640 || p.AdvertisingIntervalMax != 0x0640 {
+		t.Errorf("advertising interval = %#x-%#x, want 0x640", p.AdvertisingIntervalMin, p.AdvertisingIntervalMax)
+	}
+	if h.PowerProfile() != ble.PowerLowPower {
+		t.Errorf("PowerProfile() = %s, want %s", h.PowerProfile(), ble.PowerLowPower)
+	}
+	if err := h.SetPowerProfile(0); err == nil {
+		t.Error("SetPowerProfile(0) succeeded")
+	}
+}
@@ -0,0 +1,96 @@
+package hci
+
+import (
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// leFeature2MPHY is the bit of LE 2M PHY in the LE Supported Features.
+// [Vol 6, Part B, 4.6]
+const leFeature2MPHY = 8
+
+// connPauseSlave is the time the peripheral leaves the central to finish
+// its procedures, before requesting the preferred parameters.
+// [Vol 3, Part C, Appendix A, TGAP(conn_pause_peripheral)]
+const connPauseSlave = 5 * time.Second
+
+// PreferredConnParams returns the connection parameters set by
+// SetPreferredConnParams, if any.
+func (h *HCI) PreferredConnParams() (ble.PreferredConnParams, bool) {
+	if h.prefParams == nil {
+		return ble.PreferredConnParams{}, false
+	}
+	return *h.prefParams, true
+}
+
+// phys returns the PHYs of the mask m, which the controller supports, and
+// whether any is left, or else no preference.
+func (h *HCI) phys(m ble.PHY) (uint8, bool) {
+	if h.leFeatures&(1<<leFeature2MPHY) == 0 {
+		m &^= ble.PHY2M
+	}
+	if h.leFeatures&(1<<leFeatureCodedPHY) == 0 {
+		m &^= ble.PHYCoded
+	}
+	return uint8(m), m != 0
+}
+
+// phyParams returns the All_PHYs, TX_PHYs and RX_PHYs of the PHYs p.
+func (h *HCI) phyParams(p ble.PreferredPHY) (all, tx, rx uint8) {
+	tx, okTx := h.phys(p.Tx)
+	rx, okRx := h.phys(p.Rx)
+	if !okTx {
+		all |= cmd.AllPHYsNoTxPreference
+	}
+	if !okRx {
+		all |= cmd.AllPHYsNoRxPreference
+	}
+	return all, tx, rx
+}
+
+// setDefaultPHY sets the PHYs of SetPreferredPHY as the default of the
+// connections. [Vol 2, Part E, 7.8.48]
+func (h *HCI) setDefaultPHY() {
+	all, tx, rx := h.phyParams(h.prefPHY)
+	if err := h.Send(&cmd.LESetDefaultPHY{AllPHYs: all, TxPHYs: tx, RxPHYs: rx}, nil); err != nil {
+		h.log(ble.LogHCI).Warn("can't set default PHY", "err", err)
+	}
+}
+
+// SetPHY requests the PHYs p of the connection, which the controllers
+// change to, as far as they both support them. [Vol 2, Part E, 7.8.49]
+func (c *Conn) SetPHY(p ble.PreferredPHY) error {
+	all, tx, rx := c.hci.phyParams(p)
+	return c.hci.Send(&cmd.LESetPHY{
+		ConnectionHandle: c.param.ConnectionHandle(),
+		AllPHYs:          all,
+		TxPHYs:           tx,
+		RxPHYs:           rx,
+	}, nil)
+}
+
+// prefer requests the preferred parameters p, if set and not as preferred
+// already, and the PHYs phy, if any, of the connection, accepted by the
+// peripheral, once the central has had the time to finish its procedures.
+func (c *Conn) prefer(p *ble.PreferredConnParams, phy ble.PreferredPHY) {
+	t := c.hci.clock.NewTimer(connPauseSlave)
+	defer t.Stop()
+	select {
+	case <-c.chDone:
+		return
+	case <-t.C():
+	}
+	if phy != (ble.PreferredPHY{}) {
+		if err := c.SetPHY(phy); err != nil {
+			c.hci.log(ble.LogConn).Debug("can't set PHY", "handle", c.param.ConnectionHandle(), "err", err)
+		}
+	}
+	if p == nil || p.Contains(c.ConnParams()) {
+		return
+	}
+	if err := c.requestConnParams(p.IntervalMin, p.IntervalMax, p.Latency, p.SupervisionTimeout); err != nil {
+		c.hci.log(ble.LogConn).Debug("can't request preferred connection parameters", "handle", c.param.ConnectionHandle(), "err", err)
+	}
+}
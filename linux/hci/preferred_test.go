@@ -0,0 +1,25 @@
+package hci
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+func TestPHYParams(t *testing.T) {
+	for _, tc := range []struct {
+		features    uint64
+		p           ble.PreferredPHY
+		all, tx, rx uint8
+	}{
+		{0, ble.PreferredPHY{Tx: ble.PHY2M, Rx: ble.PHY2M}, cmd.AllPHYsNoTxPreference | cmd.AllPHYsNoRxPreference, 0, 0},
+		{1 << leFeature2MPHY, ble.PreferredPHY{Tx: ble.PHY2M, Rx: ble.PHY2M}, 0, 0x02, 0x02},
+		{1 << leFeature2MPHY, ble.PreferredPHY{Tx: ble.PHY1M | ble.PHYCoded}, cmd.AllPHYsNoRxPreference, 0x01, 0},
+	} {
+		h := &HCI{leFeatures: tc.features}
+		if all, tx, rx := h.phyParams(tc.p); all != tc.all || tx != tc.tx || rx != tc.rx {
+			t.Errorf("phyParams(%+v) = %#x, %#x, %#x, want %#x, %#x, %#x", tc.p, all, tx, rx, tc.all, tc.tx, tc.rx)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package hci
+
+import (
+	"sync"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// Version is the version information of a controller. [Vol 2, Part E, 7.4.1]
+type Version struct {
+	HCIVersion    uint8
+	HCIRevision   uint16
+	LMPVersion    uint8
+	Manufacturer  uint16 // Company identifier, e.g. 10 for CSR, 15 for Broadcom.
+	LMPSubversion uint16
+}
+
+// Quirks are the workarounds, which the stack applies to a controller.
+type Quirks uint32
+
+// Quirks.
+const (
+	// QuirkNoLEStates ignores the LE Supported States, so that the roles
+	// are never combined, e.g. scanning is paused while dialing.
+	QuirkNoLEStates Quirks = 1 << iota
+
+	// QuirkNoExtAdv ignores the extended advertising feature, so that only
+	// the legacy advertising commands are used.
+	QuirkNoExtAdv
+
+	// QuirkNoVendorCommands keeps the stack from probing the Android vendor
+	// commands, e.g. to offload the scan filters.
+	QuirkNoVendorCommands
+)
+
+// A Quirk is a workaround for the controllers it matches.
+type Quirk struct {
+	Name   string
+	Match  func(v Version) bool
+	Quirks Quirks
+
+	// Apply, if set, is called by Init right after the controller is reset,
+	// e.g. to configure the controller with vendor commands.
+	Apply func(h *HCI) error
+}
+
+var (
+	quirksMu sync.Mutex
+	quirks   = []Quirk{
+		{
+			// CSR controllers have no Android vendor commands, and the
+			// clones of the CSR8510 tend to stall on the commands they
+			// don't know.
+			Name:   "CSR",
+			Match:  func(v Version) bool { return v.Manufacturer == 10 },
+			Quirks: QuirkNoVendorCommands,
+		},
+	}
+)
+
+// RegisterQuirk adds q to the quirks, which Init applies to the controllers
+// they match.
+func RegisterQuirk(q Quirk) {
+	quirksMu.Lock()
+	defer quirksMu.Unlock()
+	quirks = append(quirks, q)
+}
+
+// UnregisterQuirk removes the quirks of the name, so that Init no longer
+// applies them.
+func UnregisterQuirk(name string) {
+	quirksMu.Lock()
+	defer quirksMu.Unlock()
+	var qs []Quirk
+	for _, q := range quirks {
+		if q.Name != name {
+			qs = append(qs, q)
+		}
+	}
+	quirks = qs
+}
+
+// QuirksFor returns the quirks, of the registered ones, which match v.
+func QuirksFor(v Version) Quirks {
+	var qs Quirks
+	for _, q := range matchQuirks(v) {
+		qs |= q.Quirks
+	}
+	return qs
+}
+
+// matchQuirks returns the registered quirks, which match v.
+func matchQuirks(v Version) []Quirk {
+	quirksMu.Lock()
+	defer quirksMu.Unlock()
+	var qs []Quirk
+	for _, q := range quirks {
+		if q.Match != nil && q.Match(v) {
+			qs = append(qs, q)
+		}
+	}
+	return qs
+}
+
+// Version returns the version information of the controller.
+func (h *HCI) Version() Version {
+	return h.version
+}
+
+// Quirks returns the quirks applied to the controller.
+func (h *HCI) Quirks() Quirks {
+	return h.quirks
+}
+
+// applyQuirks reads the version information, and applies the quirks which
+// match it.
+func (h *HCI) applyQuirks() {
+	rp := cmd.ReadLocalVersionInformationRP{}
+	h.Send(&cmd.ReadLocalVersionInformation{}, &rp)
+	h.version = Version{
+		HCIVersion:    rp.HCIVersion,
+		HCIRevision:   rp.HCIRevision,
+		LMPVersion:    rp.LMPPAMVersion,
+		Manufacturer:  rp.ManufacturerName,
+		LMPSubversion: rp.LMPPAMSubversion,
+	}
+
+	h.quirks = 0
+	for _, q := range matchQuirks(h.version) {
+		h.log(ble.LogHCI).Info("applying quirk", "quirk", q.Name)
+		h.quirks |= q.Quirks
+		if q.Apply != nil {
+			if err := q.Apply(h); err != nil {
+				h.log(ble.LogHCI).Warn("can't apply quirk", "quirk", q.Name, "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package hci
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestQuirk(t *testing.T) {
+	const manufacturer = 0xFFFE // Matches none of the built-in quirks.
+	setAddr := &rawCommand{op: ogfVendor<<10 | 0x0001, params: []byte{1, 2, 3, 4, 5, 6}}
+	RegisterQuirk(Quirk{
+		Name:   "test",
+		Match:  func(v Version) bool { return v.Manufacturer == manufacturer },
+		Quirks: QuirkNoExtAdv,
+		Apply: func(h *HCI) error {
+			_, err := h.SendVendorCommand(0x0001, setAddr.params)
+			return err
+		},
+	})
+	defer UnregisterQuirk("test")
+
+	recs := initRecords()
+	var r []monitor.Record
+	r = append(r, recs[:2]...)
+	r = append(r, exchange(&cmd.ReadLocalVersionInformation{}, 0x00, 0x09, 0x00, 0x00, 0x09, 0xFE, 0xFF)...)
+	r = append(r, exchange(setAddr, 0x00)...)
+	r = append(r, recs[4:]...)
+
+	s := monitor.NewReplaySocket(r, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+	if v := h.Version(); v.Manufacturer != manufacturer || v.HCIVersion != 0x09 {
+		t.Errorf("Version() = %+v", v)
+	}
+	if h.Quirks()&QuirkNoExtAdv == 0 {
+		t.Errorf("Quirks() = %b, want QuirkNoExtAdv", h.Quirks())
+	}
+}
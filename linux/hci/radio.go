@@ -0,0 +1,110 @@
+package hci
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// radio tracks whether the controller is asked to scan and to advertise, and
+// passes the transitions to the RadioHandler in order.
+type radio struct {
+	f ble.RadioHandler
+
+	mu          sync.Mutex
+	scanning    bool
+	advertising bool
+	q           []ble.RadioEvent
+	delivering  bool
+}
+
+// radioScan tells that the controller started, or stopped for reason,
+// scanning.
+func (h *HCI) radioScan(on bool, reason ble.StopReason) {
+	h.radioSet(ble.RadioScanning, on, reason)
+}
+
+// radioAdv tells that the controller started, or stopped for reason,
+// advertising.
+func (h *HCI) radioAdv(on bool, reason ble.StopReason) {
+	h.radioSet(ble.RadioAdvertising, on, reason)
+}
+
+// radioOff tells that the controller stopped both, as it's gone.
+func (h *HCI) radioOff() {
+	h.radioScan(false, ble.StopPoweredOff)
+	h.radioAdv(false, ble.StopPoweredOff)
+}
+
+func (h *HCI) radioSet(a ble.RadioActivity, on bool, reason ble.StopReason) {
+	r := &h.radio
+	if r.f == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := &r.scanning
+	if a == ble.RadioAdvertising {
+		state = &r.advertising
+	}
+	if *state == on {
+		return
+	}
+	*state = on
+	e := h.radioEvent(a)
+	e.Started = on
+	if !on {
+		e.Reason = reason
+	}
+	r.q = append(r.q, e)
+	if !r.delivering {
+		r.delivering = true
+		go r.deliver()
+	}
+}
+
+// deliver passes the queued events, until there are none.
+func (r *radio) deliver() {
+	for {
+		r.mu.Lock()
+		if len(r.q) == 0 {
+			r.delivering = false
+			r.mu.Unlock()
+			return
+		}
+		e := r.q[0]
+		r.q = r.q[1:]
+		r.mu.Unlock()
+		r.f(e)
+	}
+}
+
+// radioEvent returns the event of a, with the parameters in use.
+func (h *HCI) radioEvent(a ble.RadioActivity) ble.RadioEvent {
+	h.params.RLock()
+	defer h.params.RUnlock()
+	e := ble.RadioEvent{Time: h.clock.Now(), Activity: a}
+	if a == ble.RadioScanning {
+		p := h.params.scanParams
+		e.Extended = h.extScanning()
+		e.Interval = slots(p.LEScanInterval)
+		e.Window = slots(p.LEScanWindow)
+		e.Active = p.LEScanType == 0x01
+		e.FilterDup = h.params.scanEnable.FilterDuplicates == 1
+		return e
+	}
+	p := h.params.advParams
+	e.Extended = h.extAdv
+	e.IntervalMin = slots(p.AdvertisingIntervalMin)
+	e.IntervalMax = slots(p.AdvertisingIntervalMax)
+	e.Directed = h.advDirected()
+	e.Connectable = e.Directed || p.AdvertisingType == advTypeConnUndirected
+	e.Channels = ble.AdvChannel(p.AdvertisingChannelMap)
+	return e
+}
+
+// slots returns the duration of n units of 0.625 ms.
+func slots(n uint16) time.Duration {
+	return time.Duration(n) * 625 * time.Microsecond
+}
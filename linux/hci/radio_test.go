@@ -0,0 +1,55 @@
+package hci
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestRadioEvents(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertisingParameters{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	e := []byte{pktTypeEvent, 0x3E, 19, 0x01, byte(ErrDirAdvTimeout), 0, 0, roleSlave}
+	recs = append(recs, monitor.Record{Dir: monitor.Received, H4: append(e, make([]byte, 14)...)})
+	recs = append(recs, exchange(&cmd.LESetAdvertiseEnable{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetAdvertisingParameters{}, 0x00)...)
+
+	evts := make(chan ble.RadioEvent, 4)
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptRadioHandler(func(e ble.RadioEvent) { evts <- e }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// Stopping what isn't started passes no event.
+	h.AdvertiseDirected(context.Background(), ble.NewAddr("66:55:44:33:22:11"), true)
+	for _, want := range []ble.RadioEvent{
+		{Activity: ble.RadioAdvertising, Started: true},
+		{Activity: ble.RadioAdvertising, Reason: ble.StopTimeout},
+	} {
+		select {
+		case e := <-evts:
+			if e.Activity != want.Activity || e.Started != want.Started || e.Reason != want.Reason || !e.Directed || !e.Connectable {
+				t.Errorf("got %v started %v, %v, directed %v, want %v started %v, %v, directed",
+					e.Activity, e.Started, e.Reason, e.Directed, want.Activity, want.Started, want.Reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no event, want %v started %v", want.Activity, want.Started)
+		}
+	}
+	select {
+	case e := <-evts:
+		t.Errorf("unexpected event %v started %v, %v", e.Activity, e.Started, e.Reason)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
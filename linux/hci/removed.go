@@ -0,0 +1,112 @@
+package hci
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/socket"
+)
+
+// reopenPoll is the period the adapters are looked up at, for the one
+// removed to be back.
+const reopenPoll = time.Second
+
+// SetAdapterRemovedHandler sets the handler, which is called once the adapter
+// is removed, and whether the adapter is reopened once it's back.
+func (h *HCI) SetAdapterRemovedHandler(f func(), reopen bool) error {
+	h.removedHandler = f
+	h.reopen = reopen
+	return nil
+}
+
+// WaitReopened waits until the adapter, whose removal err tells, such as
+// the error of Accept, has been opened and initialized again, and reports
+// whether it has. It reports false right away if err doesn't tell a removal,
+// or reopening isn't enabled.
+func (h *HCI) WaitReopened(err error) bool {
+	if !isRemoved(err) {
+		return false
+	}
+	h.reopenMu.Lock()
+	ch := h.reopened
+	h.reopenMu.Unlock()
+	if ch == nil {
+		return false
+	}
+	<-ch
+	h.reopenMu.Lock()
+	defer h.reopenMu.Unlock()
+	return !h.closed && h.err == nil
+}
+
+// isRemoved reports whether err, of the socket, tells the adapter removed.
+func isRemoved(err error) bool {
+	return errors.Is(err, ble.ErrAdapterRemoved)
+}
+
+// adapterRemoved drops the connections of the adapter removed, and calls the
+// handler. It's called by sktLoop once done, so the handler may use h.
+func (h *HCI) adapterRemoved(reopened chan struct{}) {
+	h.log(ble.LogHCI).Warn("adapter removed", "addr", h.Addr(), "err", h.err)
+	h.skt.Close()
+
+	// No Disconnection Complete follows.
+	h.muConns.Lock()
+	conns := h.conns
+	h.conns = make(map[uint16]*Conn)
+	h.muConns.Unlock()
+	for _, c := range conns {
+		h.metrics.Add(ble.MetricConns, -1)
+		c.release()
+	}
+
+	if h.removedHandler != nil {
+		h.removedHandler()
+	}
+	if reopened != nil {
+		go h.reopenLoop(reopened)
+	}
+}
+
+// reopenLoop looks up the adapter of the address of h, until it's back, and
+// initializes h with it, or h is closed.
+func (h *HCI) reopenLoop(reopened chan struct{}) {
+	defer close(reopened)
+	addr := net.HardwareAddr(h.addr).String()
+	t := h.clock.NewTicker(reopenPoll)
+	defer t.Stop()
+	for range t.C() {
+		h.reopenMu.Lock()
+		closed := h.closed
+		h.reopenMu.Unlock()
+		if closed {
+			return
+		}
+		id, ok := socket.Find(addr)
+		if !ok {
+			continue
+		}
+		h.log(ble.LogHCI).Info("adapter back", "addr", addr, "id", id)
+		h.id = id
+		h.err = nil
+		h.done = make(chan bool)
+		h.cmdq.mu.Lock()
+		h.cmdq.credits, h.cmdq.pending, h.cmdq.sent = 0, nil, nil
+		h.cmdq.mu.Unlock()
+		h.skt = nil
+		if err := h.Init(); err != nil {
+			h.log(ble.LogHCI).Warn("can't reopen adapter", "addr", addr, "err", err)
+			if h.skt != nil {
+				h.close(err)
+				select {
+				case <-h.done:
+				case <-h.clock.After(reopenPoll):
+				}
+			}
+			continue
+		}
+		return
+	}
+}
@@ -0,0 +1,69 @@
+package hci
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+// unplugSocket fails its reads with the error of the socket of an adapter
+// removed, once unplugged.
+type unplugSocket struct {
+	*monitor.ReplaySocket
+	gone chan struct{}
+}
+
+func (s *unplugSocket) Read(p []byte) (int, error) {
+	n, err := s.ReplaySocket.Read(p)
+	select {
+	case <-s.gone:
+		return 0, fmt.Errorf("can't read hci socket: %w", ble.ErrAdapterRemoved)
+	default:
+	}
+	return n, err
+}
+
+func (s *unplugSocket) unplug() {
+	close(s.gone)
+	s.ReplaySocket.Close()
+}
+
+func TestAdapterRemoved(t *testing.T) {
+	s := &unplugSocket{monitor.NewReplaySocket(initRecords(), monitor.MatchOpcode), make(chan struct{})}
+	events := make(chan string, 4)
+	h, err := NewHCI(ble.OptTransport(s),
+		ble.OptStateHandler(func(s ble.AdapterState) { events <- s.String() }),
+		ble.OptAdapterRemovedHandler(func() { events <- "removed" }, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	s.unplug()
+	for _, want := range []string{"powered on", "powered off", "removed"} {
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s", want)
+		}
+	}
+	err = h.Send(&cmd.Reset{}, nil)
+	if !errors.Is(err, ble.ErrAdapterRemoved) || !errors.Is(err, ble.ErrDisconnected) {
+		t.Errorf("Send() error = %v, want ErrAdapterRemoved", err)
+	}
+	// A transport isn't reopened.
+	if h.WaitReopened(err) {
+		t.Error("WaitReopened() = true for a transport")
+	}
+}
@@ -0,0 +1,116 @@
+package hci
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+// exchange returns the records of a command, and of its Command Complete
+// with the return parameters rp.
+func exchange(c Command, rp ...byte) []monitor.Record {
+	op := c.OpCode()
+	b := make([]byte, 4+c.Len())
+	b[0], b[1], b[2], b[3] = pktTypeCommand, byte(op), byte(op>>8), byte(c.Len())
+	c.Marshal(b[4:])
+
+	// Pad the return parameters with zeros, as the fields aren't checked.
+	params := append([]byte{0x01, byte(op), byte(op >> 8)}, rp...)
+	params = append(params, make([]byte, 16)...)
+	e := append([]byte{pktTypeEvent, 0x0E, byte(len(params))}, params...)
+	return []monitor.Record{{Dir: monitor.Sent, H4: b}, {Dir: monitor.Received, H4: e}}
+}
+
+// initRecords returns the records of Init with a controller, which has the
+// address 11:22:33:44:55:66, and 8 LE buffers of 27 bytes.
+func initRecords() []monitor.Record {
+	var recs []monitor.Record
+	for _, x := range [][]monitor.Record{
+		exchange(&cmd.Reset{}, 0x00),
+		exchange(&cmd.ReadLocalVersionInformation{}, 0x00, 0x09, 0x00, 0x00, 0x09, 0x02, 0x00),
+		exchange(&cmd.ReadBDADDR{}, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11),
+		exchange(&cmd.ReadBufferSize{}, 0x00),
+		exchange(&cmd.LEReadBufferSize{}, 0x00, 27, 0, 8),
+		exchange(&cmd.LEReadAdvertisingChannelTxPower{}, 0x00),
+		exchange(&cmd.LEReadSupportedStates{}, 0x00),
+		exchange(&cmd.LEReadLocalSupportedFeatures{}, 0x00),
+		exchange(&cmd.LESetEventMask{}, 0x00),
+		exchange(&cmd.SetEventMask{}, 0x00),
+		exchange(&cmd.WriteLEHostSupport{}, 0x00),
+		exchange(&cmd.LESetAdvertisingParameters{}, 0x00),
+		exchange(&cmd.LESetScanParameters{}, 0x00),
+	} {
+		recs = append(recs, x...)
+	}
+	return recs
+}
+
+func TestReplayInit(t *testing.T) {
+	s := monitor.NewReplaySocket(initRecords(), monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.Remaining(); n != 0 {
+		t.Errorf("%d records not played back", n)
+	}
+	if want := (net.HardwareAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66}); !bytes.Equal(h.addr, want) {
+		t.Errorf("addr = %s, want %s", h.addr, want)
+	}
+	if h.bufCnt != 8 || h.bufSize != 27 {
+		t.Errorf("buffers = %d of %d bytes, want 8 of 27 bytes", h.bufCnt, h.bufSize)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	recs := initRecords()
+	recs[0] = exchange(&cmd.ReadBDADDR{})[0]
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Init(); err == nil {
+		t.Fatal("Init() succeeded against a mismatched capture")
+	}
+	if _, ok := s.Err().(*monitor.MismatchError); !ok {
+		t.Errorf("Err() = %v, want a MismatchError", s.Err())
+	}
+}
+
+func TestStateHandler(t *testing.T) {
+	states := make(chan ble.AdapterState, 2)
+	s := monitor.NewReplaySocket(initRecords(), monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptStateHandler(func(s ble.AdapterState) { states <- s }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	h.Close()
+	for _, want := range []ble.AdapterState{ble.AdapterPoweredOn, ble.AdapterPoweredOff} {
+		select {
+		case got := <-states:
+			if got != want {
+				t.Errorf("state = %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no %s state", want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package hci
+
+import (
+	"context"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/pkg/errors"
+)
+
+// DisconnectAll terminates every connection, and waits until the controller
+// reports them disconnected, or ctx is done.
+func (h *HCI) DisconnectAll(ctx context.Context) error {
+	return h.disconnectAll(ctx, 0x13) // Remote User Terminated Connection
+}
+
+// disconnectAll terminates every connection for the reason, and waits until
+// they're disconnected, or ctx is done.
+func (h *HCI) disconnectAll(ctx context.Context, reason uint8) error {
+	h.muConns.Lock()
+	conns := make([]*Conn, 0, len(h.conns))
+	for _, c := range h.conns {
+		conns = append(conns, c)
+	}
+	h.muConns.Unlock()
+
+	var err error
+	for _, c := range conns {
+		e := h.Send(&cmd.Disconnect{ConnectionHandle: c.param.ConnectionHandle(), Reason: reason}, nil)
+		// The connection may have been disconnected in the meantime.
+		if e != nil && e != ErrConnID && err == nil {
+			err = errors.Wrapf(e, "can't disconnect %s", c.RemoteAddr())
+		}
+	}
+	for _, c := range conns {
+		select {
+		case <-c.chDone:
+		case <-h.done:
+			return h.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// Reset terminates every connection, stops advertising and scanning, and
+// resets the controller. The connections which don't disconnect before ctx
+// is done are dropped by the reset. The options set on h are applied to the
+// controller again.
+func (h *HCI) Reset(ctx context.Context) error {
+	if h.err != nil {
+		return h.err
+	}
+	err := h.DisconnectAll(ctx)
+	if err != nil && err != ctx.Err() {
+		h.log(ble.LogHCI).Warn("can't disconnect all connections", "err", err)
+	}
+	h.StopAdvertising()
+	h.StopScanning()
+
+	h.setState(ble.AdapterResetting)
+	if err := h.init(); err != nil {
+		return errors.Wrap(err, "can't reset controller")
+	}
+
+	// The controller forgets its connections on reset, without reporting it.
+	h.muConns.Lock()
+	conns := h.conns
+	h.conns = make(map[uint16]*Conn)
+	h.muConns.Unlock()
+	for _, c := range conns {
+		h.log(ble.LogConn).Info("dropped by reset", "handle", c.param.ConnectionHandle(), "addr", c.RemoteAddr())
+		h.metrics.Add(ble.MetricConns, -1)
+		c.release()
+	}
+
+	h.adHist = make([]*Advertisement, 128)
+	h.adLast = 0
+	h.dedup = nil
+	h.extChains = nil
+	h.pool = NewPool(1+4+h.bufSize, h.bufCnt-1)
+
+	if h.role.Peripheral() {
+		if err := h.Send(&h.params.advParams, nil); err != nil {
+			return errors.Wrap(err, "can't set advertising parameters")
+		}
+	}
+	if h.role.Central() {
+		if err := h.Send(h.scanParamsCmd(), nil); err != nil {
+			return errors.Wrap(err, "can't set scan parameters")
+		}
+	}
+	h.setState(ble.AdapterPoweredOn)
+	return nil
+}
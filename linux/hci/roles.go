@@ -0,0 +1,174 @@
+package hci
+
+import (
+	"errors"
+
+	"github.com/kirbo/ble"
+)
+
+// Bits of the LE Supported States for the combinations of advertising,
+// scanning and initiating. Refer to [Vol 6, Part B, 1.1.1] for the states,
+// and [Vol 4, Part E, 7.8.27] for the bits.
+const (
+	stateNonConnAdvPassiveScan = 8
+	stateScanAdvPassiveScan    = 9
+	stateConnAdvPassiveScan    = 10
+	stateHDCDirAdvPassiveScan  = 11
+	stateNonConnAdvActiveScan  = 12
+	stateScanAdvActiveScan     = 13
+	stateConnAdvActiveScan     = 14
+	stateHDCDirAdvActiveScan   = 15
+	stateNonConnAdvInitiating  = 16
+	stateScanAdvInitiating     = 17
+	stateNonConnAdvSlave       = 20
+	stateScanAdvSlave          = 21
+	statePassiveScanInitiating = 22
+	stateActiveScanInitiating  = 23
+	stateLDCDirAdvPassiveScan  = 30
+	stateLDCDirAdvActiveScan   = 31
+	stateConnAdvInitiating     = 32
+	stateHDCDirAdvInitiating   = 33
+	stateLDCDirAdvInitiating   = 34
+	stateConnAdvSlave          = 38
+	stateHDCDirAdvSlave        = 39
+	stateLDCDirAdvSlave        = 40
+)
+
+// Advertising types of LE Set Advertising Parameters. [Vol 2, Part E, 7.8.5]
+const (
+	advTypeConnUndirected    = 0x00
+	advTypeConnDirectedHigh  = 0x01
+	advTypeScanUndirected    = 0x02
+	advTypeNonConnUndirected = 0x03
+	advTypeConnDirectedLow   = 0x04
+)
+
+// scanTypeActive is the active LE Scan Type of LE Set Scan Parameters.
+const scanTypeActive = 0x01
+
+// errStateNotSupported is returned when the controller doesn't support
+// scanning and advertising at the same time.
+var errStateNotSupported = errors.New("controller doesn't support scanning while advertising")
+
+// supportsState reports whether the controller supports the combination of
+// states. If the controller didn't report its states, it's assumed to
+// support all of them, and to reject the commands otherwise.
+func (h *HCI) supportsState(bit uint) bool {
+	return h.leStates == 0 || h.leStates&(1<<bit) != 0
+}
+
+// advState returns the state of advertising with the current parameters,
+// combined with the states for each type of advertising.
+func (h *HCI) advState(nonConn, scan, conn, hdcDir, ldcDir uint) uint {
+	switch h.params.advParams.AdvertisingType {
+	case advTypeConnDirectedHigh:
+		return hdcDir
+	case advTypeScanUndirected:
+		return scan
+	case advTypeNonConnUndirected:
+		return nonConn
+	case advTypeConnDirectedLow:
+		return ldcDir
+	}
+	return conn
+}
+
+// advDirected reports whether the advertising parameters are of directed
+// advertising, which ends once the peer connects.
+func (h *HCI) advDirected() bool {
+	t := h.params.advParams.AdvertisingType
+	return t == advTypeConnDirectedHigh || t == advTypeConnDirectedLow
+}
+
+// scanState returns the state of scanning with the current parameters,
+// combined with the states for passive and active scanning.
+func (h *HCI) scanState(passive, active uint) uint {
+	if h.params.scanParams.LEScanType == scanTypeActive {
+		return active
+	}
+	return passive
+}
+
+// canScanWhileInitiating reports whether scanning can go on while a
+// connection is being initiated.
+func (h *HCI) canScanWhileInitiating() bool {
+	return h.supportsState(h.scanState(statePassiveScanInitiating, stateActiveScanInitiating))
+}
+
+// canAdvertiseWhileInitiating reports whether advertising can go on while a
+// connection is being initiated.
+func (h *HCI) canAdvertiseWhileInitiating() bool {
+	return h.supportsState(h.advState(stateNonConnAdvInitiating, stateScanAdvInitiating,
+		stateConnAdvInitiating, stateHDCDirAdvInitiating, stateLDCDirAdvInitiating))
+}
+
+// canAdvertiseWhileSlave reports whether advertising can go on while
+// connected as a peripheral.
+func (h *HCI) canAdvertiseWhileSlave() bool {
+	return h.supportsState(h.advState(stateNonConnAdvSlave, stateScanAdvSlave,
+		stateConnAdvSlave, stateHDCDirAdvSlave, stateLDCDirAdvSlave))
+}
+
+// canScanWhileAdvertising reports whether the controller can scan and
+// advertise at the same time.
+func (h *HCI) canScanWhileAdvertising() bool {
+	if h.params.scanParams.LEScanType == scanTypeActive {
+		return h.supportsState(h.advState(stateNonConnAdvActiveScan, stateScanAdvActiveScan,
+			stateConnAdvActiveScan, stateHDCDirAdvActiveScan, stateLDCDirAdvActiveScan))
+	}
+	return h.supportsState(h.advState(stateNonConnAdvPassiveScan, stateScanAdvPassiveScan,
+		stateConnAdvPassiveScan, stateHDCDirAdvPassiveScan, stateLDCDirAdvPassiveScan))
+}
+
+// beginInitiating pauses the scanning and advertising which the controller
+// can't keep on while initiating a connection. While initiating, Scan and
+// Advertise are deferred likewise. The returned function ends initiating,
+// and resumes the scanning and advertising that are still enabled.
+func (h *HCI) beginInitiating() func() {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	h.initiating = true
+	if h.params.scanEnable.LEScanEnable == 1 && !h.canScanWhileInitiating() {
+		h.Send(h.scanEnableCmd(0), nil)
+		h.scanPaused = true
+		h.radioScan(false, ble.StopPaused)
+	}
+	if h.params.advEnable.AdvertisingEnable == 1 && !h.canAdvertiseWhileInitiating() {
+		h.Send(h.advEnableCmd(0), nil)
+		h.advPaused = true
+		h.radioAdv(false, ble.StopPaused)
+	}
+	return h.endInitiating
+}
+
+func (h *HCI) endInitiating() {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	h.initiating = false
+	if h.scanPaused && h.params.scanEnable.LEScanEnable == 1 {
+		h.radioScan(true, 0)
+		if h.Send(h.scanEnableCmd(1), nil) != nil {
+			h.radioScan(false, ble.StopFailed)
+		}
+	}
+	if h.advPaused && h.params.advEnable.AdvertisingEnable == 1 {
+		h.radioAdv(true, 0)
+		if h.Send(h.advEnableCmd(1), nil) != nil {
+			h.radioAdv(false, ble.StopFailed)
+		}
+	}
+	h.scanPaused, h.advPaused = false, false
+}
+
+// slaveConns returns the number of connections as a peripheral.
+func (h *HCI) slaveConns() int {
+	h.muConns.Lock()
+	defer h.muConns.Unlock()
+	n := 0
+	for _, c := range h.conns {
+		if c.param.Role() == roleSlave {
+			n++
+		}
+	}
+	return n
+}
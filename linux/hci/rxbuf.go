@@ -0,0 +1,50 @@
+package hci
+
+import "sync"
+
+// rxBufSize fits the largest packet read from the socket.
+const rxBufSize = 4096
+
+// rxBuf is a buffer the packets are read into, taken from rxBufs, so that
+// receiving at high rates, e.g. 1000s of notifications a second, doesn't
+// allocate a buffer for each packet.
+type rxBuf struct {
+	b []byte
+}
+
+var rxBufs = sync.Pool{
+	New: func() interface{} { return &rxBuf{b: make([]byte, rxBufSize)} },
+}
+
+func getRxBuf() *rxBuf { return rxBufs.Get().(*rxBuf) }
+
+// Release puts the buffer back to the pool. Neither the packet delivered in
+// it, nor any slice of it, may be used after. Releasing a nil buffer, of a
+// packet not pooled, does nothing.
+func (r *rxBuf) Release() {
+	if r != nil {
+		rxBufs.Put(r)
+	}
+}
+
+// rxPacket is an ACL data packet, delivered in the buffer it was read into.
+type rxPacket struct {
+	packet
+	buf *rxBuf
+}
+
+// rxPDU is a re-assembled L2CAP PDU. A PDU of a single fragment is passed on
+// in the buffer of the packet, without copying it.
+type rxPDU struct {
+	pdu
+	buf *rxBuf
+}
+
+// newRxPDU returns an empty PDU of capacity n, pooled if it fits a buffer.
+func newRxPDU(n int) rxPDU {
+	if n > rxBufSize {
+		return rxPDU{make(pdu, 0, n), nil}
+	}
+	buf := getRxBuf()
+	return rxPDU{pdu(buf.b[:0]), buf}
+}
@@ -0,0 +1,55 @@
+package hci
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/evt"
+)
+
+func TestRecombinePooled(t *testing.T) {
+	h := &HCI{
+		pool:    NewPool(27, 4),
+		conns:   make(map[uint16]*Conn),
+		muConns: &sync.Mutex{},
+		logger:  ble.DefaultLogger,
+		metrics: ble.NopMetrics,
+		clock:   ble.SystemClock,
+	}
+	param := make(evt.LEConnectionComplete, 19)
+	param[2] = 0x40
+	c := newConn(h, param)
+	h.conns[0x0040] = c
+	defer c.release()
+
+	// A notification in a single fragment, and one split in two.
+	for _, pkts := range [][][]byte{
+		{{0x02, 0x40, 0x20, 0x08, 0x00, 0x04, 0x00, 0x04, 0x00, 0x1B, 0x03, 0x00, 0x01}},
+		{
+			{0x02, 0x40, 0x20, 0x06, 0x00, 0x05, 0x00, 0x04, 0x00, 0x1B, 0x03},
+			{0x02, 0x40, 0x10, 0x03, 0x00, 0x00, 0x02, 0x03},
+		},
+	} {
+		var want []byte
+		for i, p := range pkts {
+			buf := getRxBuf()
+			if err := h.handleRxBuf(buf, copy(buf.b, p)); err != nil {
+				t.Fatal(err)
+			}
+			if i == 0 {
+				want = append(want, p[9:]...)
+			} else {
+				want = append(want, p[5:]...)
+			}
+		}
+		sdu := make([]byte, ble.DefaultMTU)
+		n, err := c.Read(sdu)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(sdu[:n]) != string(want) {
+			t.Errorf("Read() = [% X], want [% X]", sdu[:n], want)
+		}
+	}
+}
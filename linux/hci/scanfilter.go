@@ -0,0 +1,120 @@
+package hci
+
+import (
+	"context"
+	"net"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// apcfFilters returns the number of the APCF filters of the controller, or
+// 0 if it doesn't support the vendor command. The controller is asked once,
+// since controllers without vendor commands reject it.
+func (h *HCI) apcfFilters() int {
+	if h.quirks&QuirkNoVendorCommands != 0 {
+		return 0
+	}
+	if !h.apcfProbed {
+		h.apcfProbed = true
+		rp := cmd.LEGetVendorCapabilitiesRP{}
+		if err := h.Send(&cmd.LEGetVendorCapabilities{}, &rp); err == nil && rp.FilteringSupport != 0 {
+			h.apcfMax = int(rp.MaxFilter)
+		}
+	}
+	return h.apcfMax
+}
+
+// offloadable reports whether the controller can apply the filters itself.
+func (h *HCI) offloadable(fs []ble.ScanFilter) bool {
+	if len(fs) == 0 || len(fs) > h.apcfFilters() {
+		return false
+	}
+	for _, f := range fs {
+		if f.Addr == nil && f.Service == nil {
+			return false
+		}
+		if f.Addr != nil {
+			if _, err := net.ParseMAC(f.Addr.String()); err != nil {
+				return false
+			}
+		}
+		if l := f.Service.Len(); f.Service != nil && l != 2 && l != 4 && l != 16 {
+			return false
+		}
+	}
+	return true
+}
+
+// setScanFilters offloads the scan filters to the vendor filters of the
+// controller, if the scanPlan has them, before scanning. The host applies the
+// filters to the reports either way, so a controller which doesn't, or fails
+// to, only costs wakeups. Must be called with roleMu held.
+func (h *HCI) setScanFilters() {
+	h.hostFilters = h.scanFilters
+	if h.scanFiltersSet {
+		if !h.apcfEnabled {
+			h.scanPlan.VendorFilters = 0
+		}
+		return
+	}
+	h.scanFiltersSet = true
+	offload := h.scanPlan.VendorFilters > 0
+	h.scanPlan.VendorFilters = 0
+	if h.apcfEnabled {
+		h.Send(&cmd.LEAPCFSetFilteringParameters{Action: cmd.APCFClear}, &cmd.LEAPCFRP{})
+		h.Send(&cmd.LEAPCFEnable{Enable: 0}, &cmd.LEAPCFRP{})
+		h.apcfEnabled = false
+	}
+	if !offload {
+		return
+	}
+	for i, f := range h.scanFilters {
+		if err := h.sendScanFilter(uint8(i), f); err != nil {
+			h.log(ble.LogHCI).Warn("can't offload scan filters", "err", err)
+			h.Send(&cmd.LEAPCFSetFilteringParameters{Action: cmd.APCFClear}, &cmd.LEAPCFRP{})
+			return
+		}
+	}
+	if err := h.Send(&cmd.LEAPCFEnable{Enable: 1}, &cmd.LEAPCFRP{}); err != nil {
+		h.log(ble.LogHCI).Warn("can't enable scan filters", "err", err)
+		return
+	}
+	h.apcfEnabled = true
+	h.scanPlan.VendorFilters = len(h.scanFilters)
+}
+
+// sendScanFilter adds the APCF filter of index i, which matches all of the
+// features set in f.
+func (h *HCI) sendScanFilter(i uint8, f ble.ScanFilter) error {
+	var features uint16
+	if f.Addr != nil {
+		b, _ := net.ParseMAC(f.Addr.String())
+		c := cmd.LEAPCFBroadcasterAddress{
+			Action:      cmd.APCFAdd,
+			FilterIndex: i,
+			Address:     [6]byte{b[5], b[4], b[3], b[2], b[1], b[0]},
+			AddressType: peerAddressType(context.Background(), f.Addr),
+		}
+		if err := h.Send(&c, &cmd.LEAPCFRP{}); err != nil {
+			return err
+		}
+		features |= cmd.APCFFeatureBroadcasterAddress
+	}
+	if f.Service != nil {
+		c := cmd.LEAPCFServiceUUID{Action: cmd.APCFAdd, FilterIndex: i, UUID: f.Service}
+		if err := h.Send(&c, &cmd.LEAPCFRP{}); err != nil {
+			return err
+		}
+		features |= cmd.APCFFeatureServiceUUID
+	}
+	c := cmd.LEAPCFSetFilteringParameters{
+		Action:            cmd.APCFAdd,
+		FilterIndex:       i,
+		FeatureSelection:  features,
+		FilterLogicType:   0x01, // All the features match.
+		RSSIHighThreshold: 0x80, // Any RSSI, i.e. -128 dBm.
+		DeliveryMode:      0x00, // Immediate.
+	}
+	return h.Send(&c, &cmd.LEAPCFRP{})
+}
@@ -0,0 +1,110 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+// report returns the record of an advertising report from the public
+// address a, in little endian.
+func report(a ...byte) monitor.Record {
+	p := append([]byte{0x02, 0x01, evtTypAdvInd, 0x00}, a...)
+	p = append(p, 0x00, 0xC4) // No data, -60 dBm.
+	return monitor.Record{Dir: monitor.Received, H4: append([]byte{pktTypeEvent, 0x3E, byte(len(p))}, p...)}
+}
+
+func TestScanFilters(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		caps []byte // Return parameters of LE Get Vendor Capabilities
+		apcf bool
+	}{
+		{"offloaded", []byte{0x00, 0, 0, 0, 0, 0, 1, 16}, true},
+		{"host", []byte{0x01}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			recs := initRecords()
+			recs = append(recs, exchange(&cmd.LEGetVendorCapabilities{}, c.caps...)...)
+			if c.apcf {
+				recs = append(recs, exchange(&cmd.LEAPCFBroadcasterAddress{}, 0x00)...)
+				recs = append(recs, exchange(&cmd.LEAPCFSetFilteringParameters{}, 0x00)...)
+				recs = append(recs, exchange(&cmd.LEAPCFEnable{}, 0x00)...)
+			}
+			recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+			recs = append(recs, report(0x01, 0x00, 0x00, 0x00, 0x00, 0x00), report(0x66, 0x55, 0x44, 0x33, 0x22, 0x11))
+
+			s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+			h, err := NewHCI(ble.OptTransport(s), ble.OptScanFilters(ble.ScanFilter{Addr: ble.NewAddr("11:22:33:44:55:66")}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Init(); err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			got := make(chan ble.Addr, 2)
+			h.SetAdvHandler(func(a ble.Advertisement) { got <- a.Addr() })
+			if err := h.Scan(true); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case a := <-got:
+				if a.String() != "11:22:33:44:55:66" {
+					t.Errorf("got an advertisement from %s", a)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("no advertisement")
+			}
+			if err := s.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if h.apcfEnabled != c.apcf {
+				t.Errorf("apcfEnabled = %v, want %v", h.apcfEnabled, c.apcf)
+			}
+		})
+	}
+}
+
+func TestScanPlanAcceptList(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs, exchange(&cmd.LEReadWhiteListSize{}, 0x00, 0x08)...)
+	recs = append(recs, exchange(&cmd.LEClearWhiteList{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LEAddDeviceToWhiteList{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanParameters{}, 0x00)...)
+	recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s),
+		ble.OptScanFilters(ble.ScanFilter{Addr: ble.NewAddr("11:22:33:44:55:66")}),
+		ble.OptScanOffload(ble.OffloadDuplicates|ble.OffloadAcceptList),
+		ble.OptDedupCache(ble.DedupByAddressAndData, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := h.Scan(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	p := h.ScanPlan()
+	if p.ControllerDuplicates {
+		t.Error("controller filters duplicates, which hides the data changes")
+	}
+	if p.DedupTTL != time.Second || len(p.AcceptList) != 1 || p.VendorFilters != 0 {
+		t.Errorf("ScanPlan() = %s, want an accept list of 1 and host duplicates", p)
+	}
+	if h.params.scanParams.ScanningFilterPolicy != 0x01 {
+		t.Error("scanning without the accept list")
+	}
+}
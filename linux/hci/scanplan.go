@@ -0,0 +1,131 @@
+package hci
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// ScanPlan returns the plan of the last scan, which tells the filtering the
+// controller does, and what is left to the host.
+func (h *HCI) ScanPlan() ble.ScanPlan {
+	h.roleMu.Lock()
+	defer h.roleMu.Unlock()
+	return h.scanPlan
+}
+
+// planScan decides the filtering of a scan, which filters duplicates if
+// dup, within the offloads of scanOffload. The vendor filters are preferred
+// to the accept list, which only takes addresses. Must be called with roleMu
+// held.
+func (h *HCI) planScan(dup bool) ble.ScanPlan {
+	p := ble.ScanPlan{Filters: len(h.scanFilters)}
+	if dup {
+		p.DedupKey, p.DedupTTL = h.dedupKey, h.dedupTTL
+		// The controller may filter by address alone, which would hide the
+		// changes of the data from the host's filter.
+		byData := h.dedupKey == ble.DedupByAddressAndData && h.dedupTTL > 0
+		p.ControllerDuplicates = h.scanOffload&ble.OffloadDuplicates != 0 && !byData
+	}
+	switch {
+	case h.scanOffload&ble.OffloadVendorFilters != 0 && h.offloadable(h.scanFilters):
+		p.VendorFilters = len(h.scanFilters)
+	case h.scanOffload&ble.OffloadAcceptList != 0 && h.acceptable(h.scanFilters):
+		for _, f := range h.scanFilters {
+			p.AcceptList = append(p.AcceptList, f.Addr)
+		}
+	}
+	return p
+}
+
+// acceptListSize returns the size of the accept list, which the controller
+// is asked once.
+func (h *HCI) acceptListSize() int {
+	if !h.acceptListProbed {
+		h.acceptListProbed = true
+		rp := cmd.LEReadWhiteListSizeRP{}
+		if err := h.Send(&cmd.LEReadWhiteListSize{}, &rp); err == nil {
+			h.acceptListMax = int(rp.WhiteListSize)
+		}
+	}
+	return h.acceptListMax
+}
+
+// acceptable reports whether the filters are addresses, which fit in the
+// accept list.
+func (h *HCI) acceptable(fs []ble.ScanFilter) bool {
+	if len(fs) == 0 {
+		return false
+	}
+	for _, f := range fs {
+		if f.Addr == nil || f.Service != nil {
+			return false
+		}
+		if _, err := parseAddr(f.Addr); err != nil {
+			return false
+		}
+	}
+	return len(fs) <= h.acceptListSize()
+}
+
+// setAcceptList fills the accept list with the addresses of the scanPlan, and
+// sets the scanning filter policy, which limits the reports to them, or
+// restores the policy, which doesn't, if the plan has none. Neither may
+// change while scanning, which is disabled first. Must be called with roleMu
+// held.
+func (h *HCI) setAcceptList() {
+	as := h.scanPlan.AcceptList
+	if sameAddrs(as, h.acceptList) {
+		return
+	}
+	if h.params.scanEnable.LEScanEnable == 1 {
+		h.Send(h.scanEnableCmd(0), nil)
+	}
+	policy := uint8(0x00)
+	if len(as) > 0 {
+		if err := h.fillAcceptList(as); err != nil {
+			h.log(ble.LogHCI).Warn("can't fill accept list", "err", err)
+			h.scanPlan.AcceptList = nil
+			as = nil
+		} else {
+			policy = 0x01
+		}
+	}
+	if h.acceptList == nil && as == nil {
+		return
+	}
+	h.params.scanParams.ScanningFilterPolicy = policy
+	if err := h.Send(h.scanParamsCmd(), nil); err != nil {
+		h.log(ble.LogHCI).Warn("can't set scanning filter policy", "err", err)
+	}
+	h.acceptList = as
+}
+
+// fillAcceptList replaces the addresses of the accept list with as.
+func (h *HCI) fillAcceptList(as []ble.Addr) error {
+	if err := h.Send(&cmd.LEClearWhiteList{}, nil); err != nil {
+		return err
+	}
+	for _, a := range as {
+		b, _ := parseAddr(a)
+		c := cmd.LEAddDeviceToWhiteList{AddressType: peerAddressType(context.Background(), a), Address: b}
+		if err := h.Send(&c, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameAddrs(a, b []ble.Addr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i].String(), b[i].String()) || ble.AddrTypeOf(a[i]) != ble.AddrTypeOf(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+// event returns the record of an event received by the host.
+func event(code byte, params ...byte) monitor.Record {
+	return monitor.Record{Dir: monitor.Received, H4: append([]byte{pktTypeEvent, code, byte(len(params))}, params...)}
+}
+
+func TestConnSecurity(t *testing.T) {
+	recs := initRecords()
+	recs = append(recs,
+		// LE Connection Complete of handle 0x0040, as the peripheral.
+		event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00),
+		// Encryption Change, enabled.
+		event(0x08, 0x00, 0x40, 0x00, 0x01),
+	)
+	recs = append(recs, exchange(&cmd.ReadEncryptionKeySize{}, 0x00, 0x40, 0x00, 0x07)...)
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	l, err := h.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := l.(*Conn)
+	for i := 0; !c.Security().Encrypted(); i++ {
+		if i == 100 {
+			t.Fatal("link not encrypted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sec := c.Security()
+	if sec.Level != ble.SecurityEncrypted || sec.KeySize != 7 {
+		t.Errorf("Security() = %s, want encrypted with a 7 byte key", sec)
+	}
+	if err := sec.RequireKeySize(ble.MaxEncKeySize); err != ble.ErrInsuffEncrKeySize {
+		t.Errorf("RequireKeySize(16) = %v, want ErrInsuffEncrKeySize", err)
+	}
+}
+
+func TestSecurityPolicy(t *testing.T) {
+	disc := &cmd.Disconnect{ConnectionHandle: 0x0040, Reason: uint8(ErrAuth)}
+	recs := initRecords()
+	recs = append(recs,
+		event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00),
+		event(0x08, 0x00, 0x40, 0x00, 0x01),
+	)
+	recs = append(recs, exchange(&cmd.ReadEncryptionKeySize{}, 0x00, 0x40, 0x00, 0x07)...)
+	recs = append(recs, exchange(disc)[0], event(0x0F, 0x00, 0x01, byte(disc.OpCode()), byte(disc.OpCode()>>8)))
+
+	s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+	h, err := NewHCI(ble.OptTransport(s), ble.OptSecurityPolicy(ble.SecurityPolicy{MinKeySize: ble.MaxEncKeySize}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err := h.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; s.Remaining() != 0; i++ {
+		if i == 100 {
+			t.Fatal("link of a 7 byte key not disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package hci
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reasonPowerOff is the reason of the disconnections of Shutdown: Remote
+// Device Terminated Connection due to Power Off. [Vol 1, Part F, 2.23]
+const reasonPowerOff = 0x15
+
+// A ShutdownError tells the steps of Shutdown, which failed. The others were
+// done, and the socket is closed regardless.
+type ShutdownError struct {
+	Errs []error // Errors of the steps, in order.
+}
+
+func (e *ShutdownError) Error() string {
+	ss := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		ss[i] = err.Error()
+	}
+	return "hci: shutdown: " + strings.Join(ss, "; ")
+}
+
+// Is reports whether the error of any step is target, as errors.Is.
+func (e *ShutdownError) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown closes h in order, so that the controller is left idle, rather
+// than scanning, advertising or connected, for the next to open it: it stops
+// scanning and advertising, cancels the pending connection, disconnects the
+// links with the reason of a power off, waits for the commands in flight,
+// and then closes the socket, and waits for the event loop to end. The
+// waits end once ctx is done, and the steps, which failed, are told by a
+// ShutdownError.
+func (h *HCI) Shutdown(ctx context.Context) error {
+	if h.err != nil {
+		// The socket is gone already.
+		return h.Close()
+	}
+	var errs []error
+	fail := func(err error, what string) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't %s: %w", what, err))
+		}
+	}
+
+	h.roleMu.Lock()
+	scanning := h.params.scanEnable.LEScanEnable == 1
+	advertising := h.params.advEnable.AdvertisingEnable == 1
+	initiating := h.initiating
+	h.roleMu.Unlock()
+	if scanning {
+		fail(h.StopScanning(), "stop scanning")
+	}
+	if advertising {
+		fail(h.StopAdvertising(), "stop advertising")
+	}
+	h.advSetsMu.Lock()
+	sets := make([]*AdvertisingSet, 0, len(h.advSets))
+	for _, s := range h.advSets {
+		if s != nil {
+			sets = append(sets, s)
+		}
+	}
+	h.advSetsMu.Unlock()
+	for _, s := range sets {
+		if s.Enabled() {
+			fail(s.Stop(), fmt.Sprintf("stop advertising set %d", s.Handle()))
+		}
+	}
+	if initiating {
+		// The connection may have been established meanwhile, and is
+		// disconnected with the others.
+		if err := h.Send(&h.params.connCancel, nil); err != ErrDisallowed {
+			fail(err, "cancel the pending connection")
+		}
+	}
+	fail(h.disconnectAll(ctx, reasonPowerOff), "disconnect")
+	fail(h.flushCommands(ctx), "complete the commands in flight")
+
+	fail(h.Close(), "close the socket")
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		fail(ctx.Err(), "end the event loop")
+	}
+	if errs != nil {
+		return &ShutdownError{Errs: errs}
+	}
+	return nil
+}
+
+// flushCommands waits until no command is queued, or awaits its response,
+// or ctx is done.
+func (h *HCI) flushCommands(ctx context.Context) error {
+	t := time.NewTicker(10 * time.Millisecond)
+	defer t.Stop()
+	for {
+		h.cmdq.mu.Lock()
+		n := len(h.cmdq.pending)
+		for _, ps := range h.cmdq.sent {
+			n += len(ps)
+		}
+		h.cmdq.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-t.C:
+		case <-h.done:
+			return h.err
+		case <-ctx.Done():
+			return fmt.Errorf("%d commands in flight: %w", n, ctx.Err())
+		}
+	}
+}
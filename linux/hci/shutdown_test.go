@@ -0,0 +1,81 @@
+package hci
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/kirbo/ble/linux/hci/monitor"
+)
+
+func TestShutdown(t *testing.T) {
+	disc := &cmd.Disconnect{ConnectionHandle: 0x0040, Reason: reasonPowerOff}
+	for _, tc := range []struct {
+		name     string
+		complete bool // Whether the controller completes the disconnection.
+	}{
+		{"disconnected", true},
+		{"stuck", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recs := initRecords()
+			recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+			// LE Connection Complete of handle 0x0040, as the peripheral.
+			recs = append(recs, event(0x3E, 0x01, 0x00, 0x40, 0x00, 0x01, 0x00, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x18, 0x00, 0x00, 0x00, 0xC8, 0x00, 0x00))
+			recs = append(recs, exchange(&cmd.LESetScanEnable{}, 0x00)...)
+			recs = append(recs, exchange(disc)[0], event(0x0F, 0x00, 0x01, byte(disc.OpCode()), byte(disc.OpCode()>>8)))
+			if tc.complete {
+				recs = append(recs, event(0x05, 0x00, 0x40, 0x00, reasonPowerOff))
+			}
+
+			s := monitor.NewReplaySocket(recs, monitor.MatchOpcode)
+			h, err := NewHCI(ble.OptTransport(s))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Init(); err != nil {
+				t.Fatal(err)
+			}
+			if err := h.Scan(false); err != nil {
+				t.Fatal(err)
+			}
+			c, err := h.Accept()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			err = h.Shutdown(ctx)
+			if tc.complete {
+				if err != nil {
+					t.Fatal(err)
+				}
+				select {
+				case <-c.Disconnected():
+				default:
+					t.Error("connection not disconnected")
+				}
+			} else {
+				var se *ShutdownError
+				if !errors.As(err, &se) || !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("got %v, want a ShutdownError of the deadline", err)
+				}
+			}
+			select {
+			case <-h.done:
+			case <-time.After(time.Second):
+				t.Error("event loop not ended")
+			}
+			if err := s.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if n := s.Remaining(); n != 0 {
+				t.Fatalf("%d records not played back", n)
+			}
+		})
+	}
+}
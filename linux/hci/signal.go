@@ -2,11 +2,14 @@ package hci
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
+	"github.com/kirbo/ble"
 	"github.com/kirbo/ble/linux/hci/cmd"
 )
 
@@ -24,59 +27,67 @@ func (s sigCmd) id() uint8    { return s[1] }
 func (s sigCmd) len() int     { return int(binary.LittleEndian.Uint16(s[2:4])) }
 func (s sigCmd) data() []byte { return s[4 : 4+s.len()] }
 
-// Signal ...
+// Signal sends a signaling request, and waits for its response.
 func (c *Conn) Signal(req Signal, rsp Signal) error {
-	data, err := req.Marshal()
-	if err != nil {
-		return err
-	}
-	buf := bytes.NewBuffer(make([]byte, 0))
-	if err := binary.Write(buf, binary.LittleEndian, uint16(4+len(data))); err != nil {
-		return err
-	}
-	if err := binary.Write(buf, binary.LittleEndian, cidLESignal); err != nil {
-		return err
-	}
+	return c.SignalContext(context.Background(), req, rsp)
+}
 
-	if err := binary.Write(buf, binary.LittleEndian, uint8(req.Code())); err != nil {
-		return err
-	}
-	if err := binary.Write(buf, binary.LittleEndian, uint8(c.sigID)); err != nil {
-		return err
-	}
-	if err := binary.Write(buf, binary.LittleEndian, uint16(len(data))); err != nil {
-		return err
-	}
-	if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
-		return err
+// SignalContext is Signal, which gives up waiting for the response once ctx
+// is done, returning its error.
+func (c *Conn) SignalContext(ctx context.Context, req Signal, rsp Signal) error {
+	c.sigMu.Lock()
+	defer c.sigMu.Unlock()
+
+	// Drop any response that arrived after its request timed out.
+	select {
+	case <-c.sigSent:
+	default:
 	}
 
-	c.sigSent = make(chan []byte)
-	defer close(c.sigSent)
-	if _, err := c.writePDU(buf.Bytes()); err != nil {
+	id := c.nextSigID()
+	if _, err := c.sendResponse(uint8(req.Code()), id, req); err != nil {
 		return err
 	}
 	var s sigCmd
 	select {
 	case s = <-c.sigSent:
-	case <-time.After(time.Second):
+	case <-c.hci.clock.After(time.Second):
 		// TODO: Find the proper timed out defined in spec, if any.
 		return errors.New("signaling request timed out")
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 
-	if s.code() != req.Code() {
-		return errors.New("mismatched signaling response")
-	}
-	if s.id() != c.sigID {
+	if s.id() != id {
 		return errors.New("mismatched signaling id")
 	}
-	c.sigID++
+	if s.code() == SignalCommandReject {
+		return errors.New("signaling request rejected")
+	}
+	// The code of a response follows that of its request.
+	if s.code() != req.Code()+1 {
+		return errors.New("mismatched signaling response")
+	}
 	if rsp == nil {
 		return nil
 	}
 	return rsp.Unmarshal(s.data())
 }
 
+// nextSigID returns a new non-zero identifier for a signaling command. [Vol 3, Part A, 4]
+func (c *Conn) nextSigID() uint8 {
+	for {
+		if id := uint8(atomic.AddUint32(&c.sigID, 1)); id != 0 {
+			return id
+		}
+	}
+}
+
+// sendSignal sends a signaling command, which expects no response.
+func (c *Conn) sendSignal(code uint8, s Signal) (int, error) {
+	return c.sendResponse(code, c.nextSigID(), s)
+}
+
 func (c *Conn) sendResponse(code uint8, id uint8, r Signal) (int, error) {
 	data, err := r.Marshal()
 	if err != nil {
@@ -101,12 +112,12 @@ func (c *Conn) sendResponse(code uint8, id uint8, r Signal) (int, error) {
 	if err := binary.Write(buf, binary.LittleEndian, data); err != nil {
 		return 0, err
 	}
-	logger.Debug("sig", "send", fmt.Sprintf("[%X]", buf.Bytes()))
+	c.hci.log(ble.LogL2CAP).Debug("send", "handle", c.param.ConnectionHandle(), "pdu", fmt.Sprintf("[% X]", buf.Bytes()))
 	return c.writePDU(buf.Bytes())
 }
 
 func (c *Conn) handleSignal(p pdu) error {
-	logger.Debug("sig", "recv", fmt.Sprintf("[%X]", p))
+	c.hci.log(ble.LogL2CAP).Debug("recv", "handle", c.param.ConnectionHandle(), "pdu", fmt.Sprintf("[% X]", p))
 	// When multiple commands are included in an L2CAP packet and the packet
 	// exceeds the signaling MTU (MTUsig) of the receiver, a single Command Reject
 	// packet shall be sent in response. The identifier shall match the first Request
@@ -121,7 +132,7 @@ func (c *Conn) handleSignal(p pdu) error {
 				Data:   []byte{uint8(c.sigRxMTU), uint8(c.sigRxMTU >> 8)}, // Actual MTUsig.
 			})
 		if err != nil {
-			_ = logger.Error("send repsonse", fmt.Sprintf("%v", err))
+			c.hci.log(ble.LogL2CAP).Error("can't send response", "err", err)
 		}
 		return nil
 	}
@@ -138,20 +149,20 @@ func (c *Conn) handleSignal(p pdu) error {
 			c.LECreditBasedConnectionRequest(s)
 		case SignalLEFlowControlCredit:
 			c.LEFlowControlCredit(s)
+		case SignalCreditBasedConnectionRequest:
+			c.handleCreditBasedConnectionRequest(s)
 		default:
 			// Check if it's a response to a sent command.
 			select {
-			case c.sigSent <- s:
-				continue
+			case c.sigSent <- append(sigCmd(nil), s[:4+s.len()]...):
 			default:
+				c.sendResponse(
+					SignalCommandReject,
+					s.id(),
+					&CommandReject{
+						Reason: 0x0000, // Command not understood.
+					})
 			}
-
-			c.sendResponse(
-				SignalCommandReject,
-				s.id(),
-				&CommandReject{
-					Reason: 0x0000, // Command not understood.
-				})
 		}
 		s = s[4+s.len():] // advance to next the packet.
 
@@ -166,6 +177,22 @@ func (c *Conn) handleDisconnectRequest(s sigCmd) {
 		return
 	}
 
+	if ch := c.coc(req.DestinationCID); ch != nil {
+		// Silently discard the request if SCID failed to find the same match.
+		if ch.dcid != req.SourceCID {
+			return
+		}
+		c.sendResponse(
+			SignalDisconnectResponse,
+			s.id(),
+			&DisconnectResponse{
+				DestinationCID: req.DestinationCID,
+				SourceCID:      req.SourceCID,
+			})
+		ch.closeLocal()
+		return
+	}
+
 	// Send Command Reject when the DCID is unrecognized.
 	if req.DestinationCID != cidLEAtt {
 		endpoints := make([]byte, 4)
@@ -243,12 +270,86 @@ func (c *Conn) handleConnectionParameterUpdateRequest(s sigCmd) {
 		})
 }
 
-// LECreditBasedConnectionRequest ...
+// LECreditBasedConnectionRequest implements LE Credit Based Connection Request (0x14) [Vol 3, Part A, 4.22].
+// EATT is the only protocol we accept channels for, and it requires the
+// Enhanced Credit Based Flow Control Mode, so all requests are refused.
 func (c *Conn) LECreditBasedConnectionRequest(s sigCmd) {
-	// TODO:
+	c.sendResponse(
+		SignalLECreditBasedConnectionResponse,
+		s.id(),
+		&LECreditBasedConnectionResponse{
+			Result: cocPSMNotSupported,
+		})
 }
 
-// LEFlowControlCredit ...
+// LEFlowControlCredit implements LE Flow Control Credit (0x16) [Vol 3, Part A, 4.24].
 func (c *Conn) LEFlowControlCredit(s sigCmd) {
-	// TODO:
+	var req LEFlowControlCredit
+	if err := req.Unmarshal(s.data()); err != nil {
+		return
+	}
+	if ch := c.cocByRemoteCID(req.CID); ch != nil {
+		ch.addCredits(int(req.Credits))
+	}
+}
+
+// handleCreditBasedConnectionRequest implements Credit Based Connection Request (0x17) [Vol 3, Part A, 4.25].
+func (c *Conn) handleCreditBasedConnectionRequest(s sigCmd) {
+	rsp := &CreditBasedConnectionResponse{
+		MTU:            cocMTU,
+		MPS:            cocMPS,
+		InitialCredits: cocCredits,
+	}
+	var req CreditBasedConnectionRequest
+	err := req.Unmarshal(s.data())
+	switch {
+	case err != nil:
+		rsp.Result = cocInvalidParams
+	case req.SPSM != PSMEATT:
+		rsp.Result = cocPSMNotSupported
+	case req.MTU < cocMinMTU || req.MPS < cocMinMTU:
+		rsp.Result = cocUnacceptableParams
+	}
+	if rsp.Result != cocSuccess {
+		rsp.DestinationCIDs = make([]uint16, len(req.SourceCIDs))
+		c.sendResponse(SignalCreditBasedConnectionResponse, s.id(), rsp)
+		return
+	}
+
+	var opened []*CoC
+	for _, scid := range req.SourceCIDs {
+		var ch *CoC
+		switch {
+		case scid < cidDynamicFirst || scid > cidDynamicLast:
+			rsp.Result = cocInvalidSourceCID
+		case c.cocByRemoteCID(scid) != nil:
+			rsp.Result = cocSourceCIDAllocated
+		case len(c.chEATT)+len(opened) >= cap(c.chEATT):
+			rsp.Result = cocNoResources
+		default:
+			if ch, err = c.newCoC(req.SPSM); err != nil {
+				rsp.Result = cocNoResources
+			}
+		}
+		if ch == nil {
+			rsp.DestinationCIDs = append(rsp.DestinationCIDs, 0)
+			continue
+		}
+		ch.open(scid, req.MTU, req.MPS, req.InitialCredits)
+		opened = append(opened, ch)
+		rsp.DestinationCIDs = append(rsp.DestinationCIDs, ch.scid)
+	}
+	if _, err := c.sendResponse(SignalCreditBasedConnectionResponse, s.id(), rsp); err != nil {
+		for _, ch := range opened {
+			ch.closeLocal()
+		}
+		return
+	}
+	for _, ch := range opened {
+		select {
+		case c.chEATT <- ch:
+		default:
+			go ch.Close()
+		}
+	}
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+
+	"github.com/kirbo/ble"
 )
 
 const (
@@ -23,6 +25,30 @@ const (
 	pairingKeypress          = 0x0E // Pairing Keypress Notification LE-U
 )
 
+// Reasons of Pairing Failed. [Vol 3, Part H, 3.5.5]
+const (
+	pairingFailedAuthReq      = 0x03 // Authentication Requirements
+	pairingFailedNotSupported = 0x05 // Pairing Not Supported
+	pairingFailedEncKeySize   = 0x06 // Encryption Key Size
+)
+
+// authReqSC is the SC flag of the AuthReq field. [Vol 3, Part H, 3.5.1]
+const authReqSC = 0x08
+
+// policyReason returns the reason of Pairing Failed, if pairing with the
+// AuthReq and maximum key size of the peer can't meet the security policy,
+// or 0.
+func (c *Conn) policyReason(authReq uint8, maxKeySize int) uint8 {
+	p := c.hci.secPolicy
+	if p.SCOnly && authReq&authReqSC == 0 {
+		return pairingFailedAuthReq
+	}
+	if maxKeySize < p.MinKeySize {
+		return pairingFailedEncKeySize
+	}
+	return 0
+}
+
 func (c *Conn) sendSMP(p pdu) error {
 	buf := bytes.NewBuffer(make([]byte, 0))
 	if err := binary.Write(buf, binary.LittleEndian, uint16(4+len(p))); err != nil {
@@ -35,15 +61,21 @@ func (c *Conn) sendSMP(p pdu) error {
 		return err
 	}
 	_, err := c.writePDU(buf.Bytes())
-	logger.Debug("smp", "send", fmt.Sprintf("[%X]", buf.Bytes()))
+	c.hci.log(ble.LogSMP).Debug("send", "handle", c.param.ConnectionHandle(), "pdu", fmt.Sprintf("[% X]", buf.Bytes()))
 	return err
 }
 
 func (c *Conn) handleSMP(p pdu) error {
-	logger.Debug("smp", "recv", fmt.Sprintf("[%X]", p))
+	c.hci.log(ble.LogSMP).Debug("recv", "handle", c.param.ConnectionHandle(), "pdu", fmt.Sprintf("[% X]", p))
 	code := p[0]
 	switch code {
 	case pairingRequest:
+		if len(p) >= 7 {
+			if r := c.policyReason(p[3], int(p[4])); r != 0 {
+				c.hci.log(ble.LogSMP).Warn("pairing refused by policy", "handle", c.param.ConnectionHandle(), "reason", r)
+				return c.sendSMP([]byte{pairingFailed, r})
+			}
+		}
 	case pairingResponse:
 	case pairingConfirm:
 	case pairingRandom:
@@ -54,6 +86,12 @@ func (c *Conn) handleSMP(p pdu) error {
 	case identityAddreInformation:
 	case signingInformation:
 	case securityRequest:
+		if len(p) >= 2 {
+			if r := c.policyReason(p[1], ble.MaxEncKeySize); r != 0 {
+				c.hci.log(ble.LogSMP).Warn("pairing refused by policy", "handle", c.param.ConnectionHandle(), "reason", r)
+				return c.sendSMP([]byte{pairingFailed, r})
+			}
+		}
 	case pairingPublicKey:
 	case pairingDHKeyCheck:
 	case pairingKeypress:
@@ -63,5 +101,5 @@ func (c *Conn) handleSMP(p pdu) error {
 	}
 	// FIXME: work aound to the lack of SMP implementation - always return non-supported.
 	// C.5.1 Pairing Not Supported by Slave
-	return c.sendSMP([]byte{pairingFailed, 0x05})
+	return c.sendSMP([]byte{pairingFailed, pairingFailedNotSupported})
 }
@@ -0,0 +1,215 @@
+//go:build linux
+// +build linux
+
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kirbo/ble"
+	"golang.org/x/sys/unix"
+)
+
+// The problems, which Check finds, as told by errors.Is of its CheckError.
+var (
+	ErrMissingCapability = errors.New("missing capability")
+	ErrRFKillBlocked     = errors.New("adapter blocked by rfkill")
+	ErrAdapterBusy       = errors.New("adapter busy")
+	ErrNoAdapter         = errors.New("no adapter")
+)
+
+// A CheckError is a problem, which keeps the process from using a HCI device,
+// with what remedies it. Those of ErrMissingCapability tell as
+// ble.ErrPermissionDenied too.
+type CheckError struct {
+	Problem error  // One of ErrMissingCapability, ErrRFKillBlocked, ErrAdapterBusy and ErrNoAdapter.
+	Device  string // Name of the device, such as "hci0", or "" of the process.
+	Detail  string // What is wrong, such as "bluetoothd holds hci0".
+	Remedy  string // What solves it.
+	Err     error  // The error of opening the device, if any.
+}
+
+func (e *CheckError) Error() string {
+	return e.Detail + " — " + e.Remedy
+}
+
+// Unwrap returns the error of opening the device, if any.
+func (e *CheckError) Unwrap() error { return e.Err }
+
+// Is tells e as its Problem.
+func (e *CheckError) Is(target error) bool {
+	return target == e.Problem || e.Problem == ErrMissingCapability && target == ble.ErrPermissionDenied
+}
+
+// The roots of procfs, sysfs and devtmpfs, which the tests replace.
+var (
+	procRoot = "/proc"
+	sysRoot  = "/sys"
+	devRoot  = "/dev"
+)
+
+// capNetAdmin and capNetRaw are the bits of the capabilities, which the HCI
+// User Channel requires, in the capability sets of a process.
+const (
+	capNetAdmin = 12
+	capNetRaw   = 13
+)
+
+// Check checks, before opening a HCI device, that the process has the
+// capabilities of the HCI User Channel, and that a device is usable: neither
+// blocked by rfkill, nor held by bluetoothd. It returns the CheckError of the
+// first problem, if no device is usable.
+func Check() error {
+	if err := checkCaps(); err != nil {
+		return err
+	}
+	ids, err := List()
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return &CheckError{
+			Problem: ErrNoAdapter,
+			Detail:  "no HCI devices",
+			Remedy:  "plug in an adapter, or load the driver of its controller",
+		}
+	}
+	var first error
+	for _, id := range ids {
+		err := CheckDevice(id)
+		if err == nil {
+			return nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// CheckDevice checks, as Check does, the HCI device of the ID id.
+func CheckDevice(id int) error {
+	if err := checkCaps(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("hci%d", id)
+	if soft, hard := rfkillState(id); hard {
+		return &CheckError{
+			Problem: ErrRFKillBlocked,
+			Device:  name,
+			Detail:  fmt.Sprintf("%s blocked by rfkill, with a hardware switch", name),
+			Remedy:  "turn on the radio with its switch",
+		}
+	} else if soft {
+		return &CheckError{
+			Problem: ErrRFKillBlocked,
+			Device:  name,
+			Detail:  fmt.Sprintf("%s blocked by rfkill", name),
+			Remedy:  "unblock it with rfkill unblock bluetooth",
+		}
+	}
+	di, err := Info(id)
+	if err != nil {
+		return err
+	}
+	if di.IsUp() && processRunning("bluetoothd") {
+		return &CheckError{
+			Problem: ErrAdapterBusy,
+			Device:  name,
+			Detail:  fmt.Sprintf("bluetoothd holds %s", name),
+			Remedy:  "stop it, with systemctl stop bluetooth, or leave the adapter to it, and use the bled backend",
+		}
+	}
+	return nil
+}
+
+// diagnose returns the CheckError of the device id, which failed to open
+// with err, if CheckDevice finds the problem, or else err.
+func diagnose(id int, err error) error {
+	switch {
+	case errors.Is(err, unix.EPERM), errors.Is(err, unix.EACCES), errors.Is(err, unix.EBUSY), errors.Is(err, unix.ERFKILL):
+	default:
+		return err
+	}
+	var ce *CheckError
+	if errors.As(CheckDevice(id), &ce) {
+		ce.Err = err
+		return ce
+	}
+	return err
+}
+
+// checkCaps returns the CheckError of the capabilities of the HCI User
+// Channel, which the process lacks, if any.
+func checkCaps() error {
+	b, err := ioutil.ReadFile(filepath.Join(procRoot, "self/status"))
+	if err != nil {
+		// Without procfs, the device tells once it's opened.
+		return nil
+	}
+	missing := missingCaps(string(b))
+	if len(missing) == 0 {
+		return nil
+	}
+	remedy := "run as root, or grant them with setcap cap_net_admin,cap_net_raw+eip"
+	if exe, err := os.Executable(); err == nil {
+		remedy += " " + exe
+	}
+	if c := container(); c != "" {
+		remedy = "run the container with --cap-add=NET_ADMIN --cap-add=NET_RAW"
+	}
+	return &CheckError{
+		Problem: ErrMissingCapability,
+		Detail:  "missing " + strings.Join(missing, " and "),
+		Remedy:  remedy,
+	}
+}
+
+// missingCaps returns the capabilities of the HCI User Channel, missing of
+// the effective set of the /proc/<pid>/status status.
+func missingCaps(status string) []string {
+	var eff uint64
+	for _, l := range strings.Split(status, "\n") {
+		if v := strings.TrimPrefix(l, "CapEff:"); v != l {
+			eff, _ = strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+			break
+		}
+	}
+	var missing []string
+	if eff&(1<<capNetAdmin) == 0 {
+		missing = append(missing, "CAP_NET_ADMIN")
+	}
+	if eff&(1<<capNetRaw) == 0 {
+		missing = append(missing, "CAP_NET_RAW")
+	}
+	return missing
+}
+
+// rfkillState returns whether the device id is blocked by rfkill, by
+// software, or with a hardware switch.
+func rfkillState(id int) (soft, hard bool) {
+	s, _ := ReadRFKill(id)
+	return s.Soft, s.Hard
+}
+
+func readFlag(path string) bool {
+	b, err := ioutil.ReadFile(path)
+	return err == nil && strings.TrimSpace(string(b)) == "1"
+}
+
+// processRunning reports whether a process of the command comm runs.
+func processRunning(comm string) bool {
+	comms, _ := filepath.Glob(filepath.Join(procRoot, "[0-9]*", "comm"))
+	for _, f := range comms {
+		if b, err := ioutil.ReadFile(f); err == nil && strings.TrimSpace(string(b)) == comm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+//go:build linux
+// +build linux
+
+package socket
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kirbo/ble"
+	"golang.org/x/sys/unix"
+)
+
+func TestMissingCaps(t *testing.T) {
+	for _, tt := range []struct {
+		eff  string
+		want []string
+	}{
+		{"000001ffffffffff", nil},
+		{"0000000000003000", nil},
+		{"0000000000001000", []string{"CAP_NET_RAW"}},
+		{"0000000000000000", []string{"CAP_NET_ADMIN", "CAP_NET_RAW"}},
+	} {
+		status := "Name:\tble\nCapInh:\t0000000000000000\nCapEff:\t" + tt.eff + "\nCapBnd:\t000001ffffffffff\n"
+		if got := missingCaps(status); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CapEff %s: missing %v, want %v", tt.eff, got, tt.want)
+		}
+	}
+}
+
+// fakeRoots replaces procfs, sysfs and devtmpfs with the files of fs, under a
+// temporary directory, until the returned func restores them.
+func fakeRoots(t *testing.T, fs map[string]string) func() {
+	dir, err := ioutil.TempDir("", "socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range fs {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(v), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proc, sys, dev := procRoot, sysRoot, devRoot
+	procRoot, sysRoot, devRoot = filepath.Join(dir, "proc"), filepath.Join(dir, "sys"), filepath.Join(dir, "dev")
+	return func() {
+		procRoot, sysRoot, devRoot = proc, sys, dev
+		os.RemoveAll(dir)
+	}
+}
+
+func TestCheckDevice(t *testing.T) {
+	const caps = "CapEff:\t0000000000003000\n"
+	for _, tt := range []struct {
+		name    string
+		fs      map[string]string
+		problem error
+	}{
+		{"capabilities", map[string]string{"proc/self/status": "CapEff:\t0000000000000000\n"}, ErrMissingCapability},
+		{"soft blocked", map[string]string{
+			"proc/self/status":                      caps,
+			"sys/class/bluetooth/hci0/rfkill3/soft": "1\n",
+			"sys/class/bluetooth/hci0/rfkill3/hard": "0\n",
+		}, ErrRFKillBlocked},
+		{"hard blocked", map[string]string{
+			"proc/self/status":                      caps,
+			"sys/class/bluetooth/hci0/rfkill3/soft": "0\n",
+			"sys/class/bluetooth/hci0/rfkill3/hard": "1\n",
+		}, ErrRFKillBlocked},
+	} {
+		restore := fakeRoots(t, tt.fs)
+		err := CheckDevice(0)
+		restore()
+		var ce *CheckError
+		if !errors.As(err, &ce) || !errors.Is(err, tt.problem) {
+			t.Errorf("%s: err %v, want %v", tt.name, err, tt.problem)
+			continue
+		}
+		if got := errors.Is(err, ble.ErrPermissionDenied); got != (tt.problem == ErrMissingCapability) {
+			t.Errorf("%s: permission denied %v", tt.name, got)
+		}
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	defer fakeRoots(t, map[string]string{
+		"proc/self/status":                      "CapEff:\t0000000000003000\n",
+		"sys/class/bluetooth/hci0/rfkill0/soft": "1\n",
+	})()
+	err := diagnose(0, wrap(unix.ERFKILL, "can't up device"))
+	if !errors.Is(err, ErrRFKillBlocked) || !errors.Is(err, unix.ERFKILL) {
+		t.Errorf("err %v, want %v of %v", err, ErrRFKillBlocked, unix.ERFKILL)
+	}
+	if got := err.Error(); got != "hci0 blocked by rfkill — unblock it with rfkill unblock bluetooth" {
+		t.Errorf("err %q", got)
+	}
+	// The other errors are left alone.
+	orig := wrap(unix.ENODEV, "can't up device")
+	if err := diagnose(0, orig); err != orig {
+		t.Errorf("err %v, want %v", err, orig)
+	}
+}
+
+func TestProcessRunning(t *testing.T) {
+	defer fakeRoots(t, map[string]string{
+		"proc/1/comm":    "systemd\n",
+		"proc/812/comm":  "bluetoothd\n",
+		"proc/self/comm": "ble\n",
+	})()
+	if !processRunning("bluetoothd") {
+		t.Error("bluetoothd not found")
+	}
+	if processRunning("ble") {
+		t.Error("self found as a process")
+	}
+}
@@ -0,0 +1,57 @@
+//go:build linux
+// +build linux
+
+package socket
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kirbo/ble"
+)
+
+// noSupport returns the error of a platform, on which the sockets of
+// AF_BLUETOOTH can't be created, as the kernel has no Bluetooth, or the
+// process runs in a network namespace other than that of the host.
+func noSupport(err error) error {
+	c := container()
+	e := &ble.NoBluetoothError{Err: err, Container: c}
+	switch c {
+	case "":
+		e.Guidance = "load the bluetooth kernel module, or use a kernel built with it"
+	case "kubernetes":
+		e.Guidance = "run the pod with hostNetwork: true and the NET_ADMIN and NET_RAW capabilities, or fall back to TCP with ble.OptFallbackTCP"
+	default:
+		e.Guidance = "run the container with --net=host --cap-add=NET_ADMIN --cap-add=NET_RAW, or fall back to TCP with ble.OptFallbackTCP"
+	}
+	return e
+}
+
+// container returns the container runtime the process runs in, or "".
+func container() string {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	// Set by systemd-nspawn, LXC, and podman.
+	if c := os.Getenv("container"); c != "" {
+		return c
+	}
+	b, _ := ioutil.ReadFile("/proc/1/cgroup")
+	cg := string(b)
+	if strings.Contains(cg, "kubepods") {
+		return "kubernetes"
+	}
+	for _, c := range []string{"docker", "containerd", "lxc"} {
+		if strings.Contains(cg, c) {
+			return c
+		}
+	}
+	return ""
+}
@@ -0,0 +1,124 @@
+package socket
+
+import (
+	"bytes"
+	"net"
+)
+
+// HciDevStats mirrors the kernel's struct hci_dev_stats.
+type HciDevStats struct {
+	ErrRx  uint32
+	ErrTx  uint32
+	CmdTx  uint32
+	EvtRx  uint32
+	ACLTx  uint32
+	ACLRx  uint32
+	SCOTx  uint32
+	SCORx  uint32
+	ByteRx uint32
+	ByteTx uint32
+}
+
+// HciDevInfo mirrors the kernel's struct hci_dev_info as returned by the
+// HCIGETDEVINFO ioctl. All fields are fixed size and in host byte order, so
+// the Go layout matches the C one (92 bytes) on every architecture.
+type HciDevInfo struct {
+	DevID      uint16
+	DevName    [8]byte
+	BDAddr     [6]byte // little endian, as on the wire
+	DevFlags   uint32
+	DevType    uint8 // bus in the low nibble, controller type in bits 4-5
+	Features   [8]byte
+	PktType    uint32
+	LinkPolicy uint32
+	LinkMode   uint32
+	ACLMTU     uint16
+	ACLPkts    uint16
+	SCOMTU     uint16
+	SCOPkts    uint16
+	Stat       HciDevStats
+}
+
+// Name returns the device name, e.g. "hci0".
+func (d *HciDevInfo) Name() string {
+	if i := bytes.IndexByte(d.DevName[:], 0); i >= 0 {
+		return string(d.DevName[:i])
+	}
+	return string(d.DevName[:])
+}
+
+// Addr returns the BD_ADDR of the controller in the usual display order.
+func (d *HciDevInfo) Addr() string {
+	a := d.BDAddr
+	return net.HardwareAddr([]byte{a[5], a[4], a[3], a[2], a[1], a[0]}).String()
+}
+
+// Bus returns the HCI_* bus the controller is attached to.
+func (d *HciDevInfo) Bus() uint8 { return d.DevType & 0x0f }
+
+// Type returns the controller type, HCI_PRIMARY (0) or HCI_AMP (1).
+func (d *HciDevInfo) Type() uint8 { return (d.DevType >> 4) & 0x03 }
+
+var busNames = []string{"VIRTUAL", "USB", "PCCARD", "UART", "RS232", "PCI", "SDIO", "SPI", "I2C", "SMD", "VIRTIO"}
+
+// BusName returns the bus as hciconfig prints it.
+func (d *HciDevInfo) BusName() string {
+	if int(d.Bus()) < len(busNames) {
+		return busNames[d.Bus()]
+	}
+	return "UNKNOWN"
+}
+
+// TypeName returns the controller type as hciconfig prints it.
+func (d *HciDevInfo) TypeName() string {
+	switch d.Type() {
+	case 0:
+		return "Primary"
+	case 1:
+		return "AMP"
+	}
+	return "UNKNOWN"
+}
+
+var flagNames = []string{"UP", "INIT", "RUNNING", "PSCAN", "ISCAN", "AUTH", "ENCRYPT", "INQUIRY", "RAW"}
+
+// IsUp reports whether the HCI_UP flag is set.
+func (d *HciDevInfo) IsUp() bool { return d.DevFlags&1 != 0 }
+
+// Flags returns the names of the device flags that are set.
+func (d *HciDevInfo) Flags() []string {
+	var s []string
+	for i, n := range flagNames {
+		if d.DevFlags&(1<<uint(i)) != 0 {
+			s = append(s, n)
+		}
+	}
+	return s
+}
+
+// RFKillState is the rfkill state of a HCI device.
+type RFKillState struct {
+	Index uint32 // Index of the rfkill switch of the device.
+	Soft  bool   // Blocked by software, such as with rfkill block.
+	Hard  bool   // Blocked with a hardware switch, which software can't unblock.
+}
+
+// Blocked reports whether the device is blocked, either way.
+func (s RFKillState) Blocked() bool { return s.Soft || s.Hard }
+
+func (s RFKillState) String() string {
+	switch {
+	case s.Hard:
+		return "hard blocked"
+	case s.Soft:
+		return "soft blocked"
+	}
+	return "unblocked"
+}
+
+// RFKill returns the rfkill state of the device, as ReadRFKill does, and
+// whether it has an rfkill switch.
+func (d *HciDevInfo) RFKill() (RFKillState, bool) {
+	s, err := ReadRFKill(int(d.DevID))
+	return s, err == nil
+}
@@ -0,0 +1,52 @@
+package socket
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestHciDevInfoLayout(t *testing.T) {
+	var d HciDevInfo
+	if got := unsafe.Sizeof(d); got != 92 {
+		t.Errorf("sizeof(hci_dev_info): got %d, want 92", got)
+	}
+	offsets := []struct {
+		name string
+		got  uintptr
+		want uintptr
+	}{
+		{"bdaddr", unsafe.Offsetof(d.BDAddr), 10},
+		{"flags", unsafe.Offsetof(d.DevFlags), 16},
+		{"type", unsafe.Offsetof(d.DevType), 20},
+		{"pkt_type", unsafe.Offsetof(d.PktType), 32},
+		{"acl_mtu", unsafe.Offsetof(d.ACLMTU), 44},
+		{"stat", unsafe.Offsetof(d.Stat), 52},
+	}
+	for _, o := range offsets {
+		if o.got != o.want {
+			t.Errorf("offsetof(%s): got %d, want %d", o.name, o.got, o.want)
+		}
+	}
+}
+
+func TestHciDevInfoAccessors(t *testing.T) {
+	d := HciDevInfo{
+		DevName:  [8]byte{'h', 'c', 'i', '0'},
+		BDAddr:   [6]byte{0x66, 0x55, 0x44, 0x33, 0x22, 0x11},
+		DevFlags: 0x05,
+		DevType:  0x01,
+	}
+	if d.Name() != "hci0" {
+		t.Errorf("Name: got %q", d.Name())
+	}
+	if d.Addr() != "11:22:33:44:55:66" {
+		t.Errorf("Addr: got %q", d.Addr())
+	}
+	if d.BusName() != "USB" || d.TypeName() != "Primary" {
+		t.Errorf("Bus/Type: got %s/%s", d.BusName(), d.TypeName())
+	}
+	if !d.IsUp() || !reflect.DeepEqual(d.Flags(), []string{"UP", "RUNNING"}) {
+		t.Errorf("Flags: got %v", d.Flags())
+	}
+}
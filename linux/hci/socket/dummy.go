@@ -11,3 +11,23 @@ import (
 func NewSocket(id int) (io.ReadWriteCloser, error) {
 	return nil, fmt.Errorf("only available on linux")
 }
+
+// Find is a dummy function for non-Linux platform.
+func Find(a string) (int, bool) {
+	return 0, false
+}
+
+// ReadRFKill is a dummy function for non-Linux platform.
+func ReadRFKill(id int) (RFKillState, error) {
+	return RFKillState{}, fmt.Errorf("only available on linux")
+}
+
+// UnblockRFKill is a dummy function for non-Linux platform.
+func UnblockRFKill(id int) error {
+	return fmt.Errorf("only available on linux")
+}
+
+// Info is a dummy function for non-Linux platform.
+func Info(id int) (*HciDevInfo, error) {
+	return nil, fmt.Errorf("only available on linux")
+}
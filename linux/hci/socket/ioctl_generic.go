@@ -0,0 +1,12 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le && !ppc && !ppc64 && !ppc64le && !sparc64
+// +build linux,!mips,!mipsle,!mips64,!mips64le,!ppc,!ppc64,!ppc64le,!sparc64
+
+package socket
+
+// asm-generic ioctl encoding, used by x86, arm, arm64, riscv64 and s390x.
+const (
+	iocWrite     = 1
+	iocRead      = 2
+	iocSizeShift = 16
+	iocDirShift  = 30
+)
@@ -0,0 +1,13 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le || ppc || ppc64 || ppc64le || sparc64)
+// +build linux
+// +build mips mipsle mips64 mips64le ppc ppc64 ppc64le sparc64
+
+package socket
+
+// MIPS, PowerPC and SPARC use three direction bits and a 13-bit size field.
+const (
+	iocWrite     = 4
+	iocRead      = 2
+	iocSizeShift = 16
+	iocDirShift  = 29
+)
@@ -0,0 +1,38 @@
+//go:build linux
+// +build linux
+
+package socket
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+func TestIoctlNumbers(t *testing.T) {
+	// Values of HCIDEVUP and HCIGETDEVLIST as expanded by the kernel headers.
+	want := [2]uintptr{0x400448c9, 0x800448d2}
+	switch runtime.GOARCH {
+	case "mips", "mipsle", "mips64", "mips64le", "ppc", "ppc64", "ppc64le", "sparc64":
+		want = [2]uintptr{0x800448c9, 0x400448d2}
+	}
+	if hciUpDevice != want[0] {
+		t.Errorf("HCIDEVUP: got 0x%08x, want 0x%08x", hciUpDevice, want[0])
+	}
+	if hciGetDeviceList != want[1] {
+		t.Errorf("HCIGETDEVLIST: got 0x%08x, want 0x%08x", hciGetDeviceList, want[1])
+	}
+}
+
+func TestDevListRequestLayout(t *testing.T) {
+	var req devListRequest
+	if got := unsafe.Sizeof(req); got != 4+hciMaxDevices*8 {
+		t.Errorf("sizeof(devListRequest): got %d, want %d", got, 4+hciMaxDevices*8)
+	}
+	if got := unsafe.Offsetof(req.devRequest); got != 4 {
+		t.Errorf("offsetof(devRequest): got %d, want 4", got)
+	}
+	if got := unsafe.Offsetof(req.devRequest[0].opt); got != 4 {
+		t.Errorf("offsetof(hci_dev_req.dev_opt): got %d, want 4", got)
+	}
+}
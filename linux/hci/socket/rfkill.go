@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package socket
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// The types and operations of the rfkill events. [linux/rfkill.h]
+const (
+	rfkillTypeBluetooth = 2
+	rfkillOpChange      = 2
+	rfkillOpChangeAll   = 3
+)
+
+// rfkillEvent mirrors struct rfkill_event, without the hard_block_reasons of
+// the later kernels, which take the events of the size too. It's in host
+// byte order, with no padding.
+type rfkillEvent struct {
+	Idx  uint32
+	Type uint8
+	Op   uint8
+	Soft uint8
+	Hard uint8
+}
+
+const rfkillEventSize = int(unsafe.Sizeof(rfkillEvent{}))
+
+// rfkillDir returns the sysfs directory of the rfkill switch of the device
+// id, and its index.
+func rfkillDir(id int) (string, uint32, error) {
+	dirs, _ := filepath.Glob(filepath.Join(sysRoot, "class/bluetooth", fmt.Sprintf("hci%d", id), "rfkill*"))
+	for _, d := range dirs {
+		if i, err := strconv.ParseUint(strings.TrimPrefix(filepath.Base(d), "rfkill"), 10, 32); err == nil {
+			return d, uint32(i), nil
+		}
+	}
+	return "", 0, fmt.Errorf("no rfkill switch of hci%d", id)
+}
+
+// ReadRFKill returns the rfkill state of the device id, as told by the events
+// of /dev/rfkill, or by sysfs, if it can't be read.
+func ReadRFKill(id int) (RFKillState, error) {
+	dir, idx, err := rfkillDir(id)
+	if err != nil {
+		return RFKillState{}, err
+	}
+	s := RFKillState{Index: idx}
+	fd, err := unix.Open(filepath.Join(devRoot, "rfkill"), unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		s.Soft, s.Hard = readFlag(filepath.Join(dir, "soft")), readFlag(filepath.Join(dir, "hard"))
+		return s, nil
+	}
+	defer unix.Close(fd)
+	// The device tells the state of each switch, as it's opened, and then
+	// blocks, until one changes.
+	var ev rfkillEvent
+	b := (*[rfkillEventSize]byte)(unsafe.Pointer(&ev))[:]
+	for {
+		n, err := unix.Read(fd, b)
+		if err == unix.EAGAIN || err == nil && n < rfkillEventSize {
+			return RFKillState{}, fmt.Errorf("no rfkill event of hci%d", id)
+		}
+		if err != nil {
+			return RFKillState{}, wrap(err, "can't read rfkill")
+		}
+		if ev.Idx == idx {
+			s.Soft, s.Hard = ev.Soft != 0, ev.Hard != 0
+			return s, nil
+		}
+	}
+}
+
+// UnblockRFKill soft-unblocks the device id, or all the Bluetooth devices if
+// id is -1, with /dev/rfkill. Those blocked with a hardware switch remain
+// blocked.
+func UnblockRFKill(id int) error {
+	ev := rfkillEvent{Type: rfkillTypeBluetooth, Op: rfkillOpChangeAll}
+	if id != -1 {
+		_, idx, err := rfkillDir(id)
+		if err != nil {
+			return err
+		}
+		ev = rfkillEvent{Idx: idx, Op: rfkillOpChange}
+	}
+	fd, err := unix.Open(filepath.Join(devRoot, "rfkill"), unix.O_WRONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return wrap(err, "can't open rfkill")
+	}
+	defer unix.Close(fd)
+	if _, err := unix.Write(fd, (*[rfkillEventSize]byte)(unsafe.Pointer(&ev))[:]); err != nil {
+		return wrap(err, "can't unblock rfkill")
+	}
+	return nil
+}
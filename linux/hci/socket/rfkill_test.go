@@ -0,0 +1,87 @@
+//go:build linux
+// +build linux
+
+package socket
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func rfkillEvents(evs ...rfkillEvent) string {
+	var b []byte
+	for i := range evs {
+		b = append(b, (*[rfkillEventSize]byte)(unsafe.Pointer(&evs[i]))[:]...)
+	}
+	return string(b)
+}
+
+func TestReadRFKill(t *testing.T) {
+	if rfkillEventSize != 8 {
+		t.Fatalf("sizeof(rfkill_event): got %d, want 8", rfkillEventSize)
+	}
+	defer fakeRoots(t, map[string]string{
+		"sys/class/bluetooth/hci0/rfkill4/soft": "0\n",
+		"sys/class/bluetooth/hci1/rfkill7/soft": "1\n",
+		"dev/rfkill": rfkillEvents(
+			rfkillEvent{Idx: 0, Type: 1},
+			rfkillEvent{Idx: 4, Type: rfkillTypeBluetooth, Soft: 1},
+		),
+	})()
+
+	// The events of /dev/rfkill prevail over sysfs.
+	s, err := ReadRFKill(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (RFKillState{Index: 4, Soft: true}) || s.String() != "soft blocked" {
+		t.Errorf("hci0: %+v", s)
+	}
+	if _, err := ReadRFKill(1); err == nil {
+		t.Error("hci1 read without its event")
+	}
+	if _, err := ReadRFKill(2); err == nil {
+		t.Error("hci2 read without its switch")
+	}
+}
+
+func TestReadRFKillSysfs(t *testing.T) {
+	defer fakeRoots(t, map[string]string{
+		"sys/class/bluetooth/hci0/rfkill4/soft": "0\n",
+		"sys/class/bluetooth/hci0/rfkill4/hard": "1\n",
+	})()
+	s, err := ReadRFKill(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != (RFKillState{Index: 4, Hard: true}) || !s.Blocked() {
+		t.Errorf("hci0: %+v", s)
+	}
+}
+
+func TestUnblockRFKill(t *testing.T) {
+	defer fakeRoots(t, map[string]string{
+		"sys/class/bluetooth/hci0/rfkill4/soft": "1\n",
+		"dev/rfkill":                            "",
+	})()
+	for _, tt := range []struct {
+		id   int
+		want rfkillEvent
+	}{
+		{0, rfkillEvent{Idx: 4, Op: rfkillOpChange}},
+		{-1, rfkillEvent{Type: rfkillTypeBluetooth, Op: rfkillOpChangeAll}},
+	} {
+		if err := UnblockRFKill(tt.id); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(devRoot, "rfkill"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != rfkillEvents(tt.want) {
+			t.Errorf("id %d: wrote % X, want % X", tt.id, b, rfkillEvents(tt.want))
+		}
+	}
+}
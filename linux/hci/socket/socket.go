@@ -1,25 +1,64 @@
+//go:build linux
 // +build linux
 
 package socket
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"unsafe"
 
-	"github.com/pkg/errors"
+	"github.com/kirbo/ble"
 	"golang.org/x/sys/unix"
 )
 
+// ioR and ioW encode ioctl request numbers the way the kernel's _IOR and _IOW
+// macros do. The direction bits and the width of the size field differ between
+// architectures; the constants are defined in ioctl_generic.go and
+// ioctl_mipsppc.go.
 func ioR(t, nr, size uintptr) uintptr {
-	return (2 << 30) | (t << 8) | nr | (size << 16)
+	return (iocRead << iocDirShift) | (size << iocSizeShift) | (t << 8) | nr
 }
 
 func ioW(t, nr, size uintptr) uintptr {
-	return (1 << 30) | (t << 8) | nr | (size << 16)
+	return (iocWrite << iocDirShift) | (size << iocSizeShift) | (t << 8) | nr
 }
 
+// wrap annotates err with msg. The errors of a process, which lacks the
+// capabilities for the HCI User Channel, tell as ble.ErrPermissionDenied,
+// and those of a device, which is gone, as ble.ErrAdapterRemoved.
+func wrap(err error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	switch err {
+	case unix.EPERM, unix.EACCES:
+		err = &permissionError{err}
+	case unix.ENODEV, unix.EBADF:
+		err = &removedError{err}
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+type permissionError struct {
+	err error
+}
+
+func (e *permissionError) Error() string        { return e.err.Error() }
+func (e *permissionError) Unwrap() error        { return e.err }
+func (e *permissionError) Is(target error) bool { return target == ble.ErrPermissionDenied }
+
+type removedError struct {
+	err error
+}
+
+func (e *removedError) Error() string        { return e.err.Error() }
+func (e *removedError) Unwrap() error        { return e.err }
+func (e *removedError) Is(target error) bool { return target == ble.ErrAdapterRemoved }
+
 func ioctl(fd, op, arg uintptr) error {
 	if _, _, ep := unix.Syscall(unix.SYS_IOCTL, fd, op, arg); ep != 0 {
 		return ep
@@ -41,6 +80,10 @@ var (
 	hciGetDeviceInfo = ioR(typHCI, 211, ioctlSize) // HCIGETDEVINFO
 )
 
+// devListRequest mirrors struct hci_dev_list_req followed by hciMaxDevices
+// entries of struct hci_dev_req. The kernel copies it in host byte order with
+// natural alignment, which Go's layout matches on every supported GOARCH
+// (2 bytes of padding after devNum, 8 bytes per entry).
 type devListRequest struct {
 	devNum     uint16
 	devRequest [hciMaxDevices]struct {
@@ -58,53 +101,68 @@ type Socket struct {
 }
 
 // NewSocket returns a HCI User Channel of specified device id.
-// If id is -1, the first available HCI device is returned.
+// If id is -1, the first available HCI device is returned. The devices,
+// which fail to open for a problem Check finds, fail with its CheckError.
 func NewSocket(id int) (*Socket, error) {
 	var err error
 	// Create RAW HCI Socket.
 	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW, unix.BTPROTO_HCI)
+	if err == unix.EAFNOSUPPORT {
+		return nil, noSupport(wrap(err, "can't create socket"))
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "can't create socket")
+		return nil, wrap(err, "can't create socket")
 	}
 
 	if id != -1 {
-		return open(fd, id)
+		s, err := open(fd, id)
+		if err != nil {
+			return nil, diagnose(id, err)
+		}
+		return s, nil
 	}
 
 	req := devListRequest{devNum: hciMaxDevices}
 	if err = ioctl(uintptr(fd), hciGetDeviceList, uintptr(unsafe.Pointer(&req))); err != nil {
-		return nil, errors.Wrap(err, "can't get device list")
+		return nil, wrap(err, "can't get device list")
 	}
 	var msg string
-	for id := 0; id < int(req.devNum); id++ {
+	denied := req.devNum > 0
+	for i := 0; i < int(req.devNum); i++ {
+		id := int(req.devRequest[i].id)
 		s, err := open(fd, id)
 		if err == nil {
 			return s, nil
 		}
+		err = diagnose(id, err)
 		msg = msg + fmt.Sprintf("(hci%d: %s)", id, err)
+		denied = denied && errors.Is(err, ble.ErrPermissionDenied)
+	}
+	if denied {
+		return nil, fmt.Errorf("no devices available: %s: %w", msg, ble.ErrPermissionDenied)
 	}
-	return nil, errors.Errorf("no devices available: %s", msg)
+	return nil, fmt.Errorf("no devices available: %s", msg)
 }
 
 func open(fd, id int) (*Socket, error) {
 	// Reset the device in case previous session didn't cleanup properly.
 	if err := ioctl(uintptr(fd), hciDownDevice, uintptr(id)); err != nil {
-		return nil, errors.Wrap(err, "can't down device")
+		return nil, wrap(err, "can't down device")
 	}
 	if err := ioctl(uintptr(fd), hciUpDevice, uintptr(id)); err != nil {
-		return nil, errors.Wrap(err, "can't up device")
+		return nil, wrap(err, "can't up device")
 	}
 
 	// HCI User Channel requires exclusive access to the device.
 	// The device has to be down at the time of binding.
 	if err := ioctl(uintptr(fd), hciDownDevice, uintptr(id)); err != nil {
-		return nil, errors.Wrap(err, "can't down device")
+		return nil, wrap(err, "can't down device")
 	}
 
 	// Bind the RAW socket to HCI User Channel
 	sa := unix.SockaddrHCI{Dev: uint16(id), Channel: unix.HCI_CHANNEL_USER}
 	if err := unix.Bind(fd, &sa); err != nil {
-		return nil, errors.Wrap(err, "can't bind socket to hci user channel")
+		return nil, wrap(err, "can't bind socket to hci user channel")
 	}
 
 	// poll for 20ms to see if any data becomes available, then clear it
@@ -134,14 +192,14 @@ func (s *Socket) Read(p []byte) (int, error) {
 		return 0, io.EOF
 	default:
 	}
-	return n, errors.Wrap(err, "can't read hci socket")
+	return n, wrap(err, "can't read hci socket")
 }
 
 func (s *Socket) Write(p []byte) (int, error) {
 	s.wmu.Lock()
 	defer s.wmu.Unlock()
 	n, err := unix.Write(s.fd, p)
-	return n, errors.Wrap(err, "can't write hci socket")
+	return n, wrap(err, "can't write hci socket")
 }
 
 func (s *Socket) Close() error {
@@ -149,36 +207,36 @@ func (s *Socket) Close() error {
 	s.Write([]byte{0x01, 0x09, 0x10, 0x00}) // no-op command to wake up the Read call if it's blocked
 	s.rmu.Lock()
 	defer s.rmu.Unlock()
-	return errors.Wrap(unix.Close(s.fd), "can't close hci socket")
+	return wrap(unix.Close(s.fd), "can't close hci socket")
 }
 
-//Up turn up a HCI device by ID
+// Up turn up a HCI device by ID
 func Up(id int) error {
 	// Create RAW HCI Socket.
 	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW, unix.BTPROTO_HCI)
 	if err != nil {
-		return errors.Wrap(err, "can't create socket")
+		return wrap(err, "can't create socket")
 	}
 	if err := ioctl(uintptr(fd), hciUpDevice, uintptr(id)); err != nil {
-		return errors.Wrap(err, "can't down device")
+		return wrap(err, "can't down device")
 	}
 	return unix.Close(fd)
 }
 
-//Down turn down a HCI device by ID
+// Down turn down a HCI device by ID
 func Down(id int) error {
 	// Create RAW HCI Socket.
 	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW, unix.BTPROTO_HCI)
 	if err != nil {
-		return errors.Wrap(err, "can't create socket")
+		return wrap(err, "can't create socket")
 	}
 	if err := ioctl(uintptr(fd), hciDownDevice, uintptr(id)); err != nil {
-		return errors.Wrap(err, "can't down device")
+		return wrap(err, "can't down device")
 	}
 	return unix.Close(fd)
 }
 
-//List List HCI devices
+// List List HCI devices
 func List() ([]int, error) {
 
 	var err error
@@ -186,18 +244,48 @@ func List() ([]int, error) {
 	// Create RAW HCI Socket.
 	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW, unix.BTPROTO_HCI)
 	if err != nil {
-		return nil, errors.Wrap(err, "can't create socket")
+		return nil, wrap(err, "can't create socket")
 	}
 
 	req := devListRequest{devNum: hciMaxDevices}
 	if err = ioctl(uintptr(fd), hciGetDeviceList, uintptr(unsafe.Pointer(&req))); err != nil {
-		return nil, errors.Wrap(err, "can't get device list")
+		return nil, wrap(err, "can't get device list")
 	}
 
 	list := make([]int, 0)
-	for id := 0; id < int(req.devNum); id++ {
-		list = append(list, id)
+	for i := 0; i < int(req.devNum); i++ {
+		list = append(list, int(req.devRequest[i].id))
 	}
 
 	return list, nil
 }
+
+// Find returns the ID of the HCI device of the address a, as told by Info.
+func Find(a string) (int, bool) {
+	ids, err := List()
+	if err != nil {
+		return 0, false
+	}
+	for _, id := range ids {
+		if di, err := Info(id); err == nil && strings.EqualFold(di.Addr(), a) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Info returns the kernel's information about a HCI device by ID.
+func Info(id int) (*HciDevInfo, error) {
+	// Create RAW HCI Socket.
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW, unix.BTPROTO_HCI)
+	if err != nil {
+		return nil, wrap(err, "can't create socket")
+	}
+	defer unix.Close(fd)
+
+	di := &HciDevInfo{DevID: uint16(id)}
+	if err := ioctl(uintptr(fd), hciGetDeviceInfo, uintptr(unsafe.Pointer(di))); err != nil {
+		return nil, wrap(err, "can't get device info")
+	}
+	return di, nil
+}
@@ -0,0 +1,52 @@
+package hci
+
+import (
+	"fmt"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/socket"
+)
+
+// devInfo returns the kernel's information about a HCI device; it's replaced
+// by the tests.
+var devInfo = socket.Info
+
+// Stats samples the counters of the adapter, as the kernel keeps them, with
+// their rates since the previous sample, as hciconfig prints them. It may be
+// polled, such as every few seconds, to watch the health of the adapter.
+// The adapters of OptTransport have no counters, and fail with
+// ble.ErrNotImplemented.
+func (h *HCI) Stats() (ble.AdapterStats, error) {
+	if h.transport != nil {
+		return ble.AdapterStats{}, fmt.Errorf("adapter of a transport has no stats: %w", ble.ErrNotImplemented)
+	}
+	di, err := devInfo(h.id)
+	if err != nil {
+		return ble.AdapterStats{}, err
+	}
+	st := di.Stat
+	s := ble.AdapterStats{
+		Time: h.clock.Now(),
+		Counters: ble.AdapterCounters{
+			ErrRx:  st.ErrRx,
+			ErrTx:  st.ErrTx,
+			CmdTx:  st.CmdTx,
+			EvtRx:  st.EvtRx,
+			ACLTx:  st.ACLTx,
+			ACLRx:  st.ACLRx,
+			SCOTx:  st.SCOTx,
+			SCORx:  st.SCORx,
+			ByteTx: st.ByteTx,
+			ByteRx: st.ByteRx,
+		},
+	}
+
+	h.statsMu.Lock()
+	defer h.statsMu.Unlock()
+	if prev := h.lastStats; !prev.Time.IsZero() {
+		s.Interval = s.Time.Sub(prev.Time)
+		s.Rates = s.Counters.Rates(prev.Counters, s.Interval)
+	}
+	h.lastStats = s
+	return s, nil
+}
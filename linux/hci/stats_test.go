@@ -0,0 +1,53 @@
+package hci
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/monitor"
+	"github.com/kirbo/ble/linux/hci/socket"
+)
+
+func TestStats(t *testing.T) {
+	st := socket.HciDevStats{EvtRx: 100, ByteRx: 0xFFFFFF00, ErrRx: 1}
+	defer func(f func(int) (*socket.HciDevInfo, error)) { devInfo = f }(devInfo)
+	devInfo = func(id int) (*socket.HciDevInfo, error) {
+		return &socket.HciDevInfo{DevID: uint16(id), Stat: st}, nil
+	}
+
+	clk := ble.NewFakeClock(time.Unix(0, 0))
+	h, err := NewHCI(ble.OptClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := h.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Counters.EvtRx != 100 || s.Interval != 0 || s.Rates != (ble.AdapterRates{}) {
+		t.Errorf("first sample = %+v, want no rates", s)
+	}
+
+	// A babbling controller, with the bytes received wrapping.
+	st.EvtRx += 5000
+	st.ByteRx += 0x200
+	st.ErrRx += 20
+	clk.Advance(2 * time.Second)
+	if s, err = h.Stats(); err != nil {
+		t.Fatal(err)
+	}
+	want := ble.AdapterRates{EvtRx: 2500, ByteRx: 256, ErrRx: 10}
+	if s.Interval != 2*time.Second || s.Rates != want {
+		t.Errorf("rates = %+v over %v, want %+v over 2s", s.Rates, s.Interval, want)
+	}
+
+	t2, err := NewHCI(ble.OptTransport(monitor.NewReplaySocket(nil, monitor.MatchOpcode)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := t2.Stats(); !errors.Is(err, ble.ErrNotImplemented) {
+		t.Errorf("stats of a transport: %v, want %v", err, ble.ErrNotImplemented)
+	}
+}
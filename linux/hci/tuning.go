@@ -0,0 +1,118 @@
+package hci
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// leFeatureConnParamsReq is the LE Supported Feature of the Connection
+// Parameters Request procedure. [Vol 6, Part B, 4.6]
+const leFeatureConnParamsReq = 1
+
+// UpdateConnParams requests the parameters p for the connection. As the
+// central, the controller updates them. As the peripheral, the central is
+// asked, over the Connection Parameters Request procedure, or the L2CAP
+// Connection Parameter Update Request, if either controller doesn't support
+// the procedure. The update is done once ConnParams returns the new ones.
+// [Vol 2, Part E, 7.8.18] [Vol 3, Part A, 4.20]
+func (c *Conn) UpdateConnParams(p ble.ConnParams) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	return c.requestConnParams(p.Interval, p.Interval, p.Latency, p.SupervisionTimeout)
+}
+
+// requestConnParams requests the parameters of an interval from min to max,
+// as UpdateConnParams does.
+func (c *Conn) requestConnParams(min, max time.Duration, latency int, timeout time.Duration) error {
+	intervalMin := uint16(min / (1250 * time.Microsecond))
+	intervalMax := uint16(max / (1250 * time.Microsecond))
+	tmo := uint16(timeout / (10 * time.Millisecond))
+	if c.param.Role() == roleSlave && !c.connParamsReq() {
+		var rsp ConnectionParameterUpdateResponse
+		if err := c.Signal(&ConnectionParameterUpdateRequest{
+			IntervalMin:       intervalMin,
+			IntervalMax:       intervalMax,
+			SlaveLatency:      uint16(latency),
+			TimeoutMultiplier: tmo,
+		}, &rsp); err != nil {
+			return err
+		}
+		if rsp.Result != 0 {
+			return errors.New("connection parameters rejected")
+		}
+		return nil
+	}
+	return c.hci.Send(&cmd.LEConnectionUpdate{
+		ConnectionHandle:   c.param.ConnectionHandle(),
+		ConnIntervalMin:    intervalMin,
+		ConnIntervalMax:    intervalMax,
+		ConnLatency:        uint16(latency),
+		SupervisionTimeout: tmo,
+	}, nil)
+}
+
+// connParamsReq reports whether both controllers support the Connection
+// Parameters Request procedure, as far as the features of the remote one
+// have been read.
+func (c *Conn) connParamsReq() bool {
+	if c.hci.leFeatures&(1<<leFeatureConnParamsReq) == 0 {
+		return false
+	}
+	info := c.RemoteInfo()
+	return !info.FeaturesKnown || info.Features&(1<<leFeatureConnParamsReq) != 0
+}
+
+// tuner decides the parameters of a connection, as its traffic is sampled.
+type tuner struct {
+	t    ble.IntervalTuning
+	fast bool      // The fast parameters are requested.
+	last time.Time // Time of the last burst.
+}
+
+// sample counts n PDUs within the window ended at now, and returns the
+// parameters to request, if they change.
+func (tn *tuner) sample(n int, now time.Time) (ble.ConnParams, bool) {
+	switch {
+	case n >= tn.t.Burst:
+		tn.last = now
+		if !tn.fast {
+			tn.fast = true
+			return tn.t.Fast, true
+		}
+	case tn.fast && now.Sub(tn.last) >= tn.t.Idle:
+		tn.fast = false
+		return tn.t.Slow, true
+	}
+	return ble.ConnParams{}, false
+}
+
+// tune switches the connection between the fast and the slow parameters of
+// t, as its traffic comes and goes, until it's closed. The connection is
+// taken as busy as it's established, if its interval is as short as the
+// fast one.
+func (c *Conn) tune(t ble.IntervalTuning) {
+	tn := &tuner{t: t, fast: c.ConnParams().Interval <= t.Fast.Interval, last: c.hci.clock.Now()}
+	tick := c.hci.clock.NewTicker(t.Window)
+	defer tick.Stop()
+	for {
+		select {
+		case <-c.chDone:
+			return
+		case <-tick.C():
+		}
+		n := int(atomic.SwapUint32(&c.traffic, 0))
+		p, ok := tn.sample(n, c.hci.clock.Now())
+		if !ok {
+			continue
+		}
+		// A failed request isn't repeated until the traffic changes.
+		if err := c.UpdateConnParams(p); err != nil {
+			c.hci.log(ble.LogConn).Debug("can't tune connection", "handle", c.param.ConnectionHandle(), "interval", p.Interval, "err", err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package hci
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+func TestTuner(t *testing.T) {
+	it, err := ble.IntervalTuning{
+		Fast: ble.RecommendConnParams(15*time.Millisecond, 0, 0),
+		Slow: ble.RecommendConnParams(500*time.Millisecond, 2, 0),
+	}.WithDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Unix(0, 0)
+	tn := &tuner{t: it, last: t0}
+	for _, tt := range []struct {
+		at   time.Duration
+		n    int
+		want time.Duration // Interval requested, if any.
+	}{
+		{time.Second, 1, 0},
+		{2 * time.Second, 4, 15 * time.Millisecond},
+		{3 * time.Second, 9, 0},
+		{4 * time.Second, 0, 0},
+		// Idle for 5s since the last burst.
+		{8 * time.Second, 0, 500 * time.Millisecond},
+		{9 * time.Second, 0, 0},
+		{10 * time.Second, 5, 15 * time.Millisecond},
+	} {
+		p, ok := tn.sample(tt.n, t0.Add(tt.at))
+		if ok != (tt.want != 0) || ok && p.Interval != tt.want {
+			t.Errorf("%s: %d PDUs requested %v (%v), want %v", tt.at, tt.n, p.Interval, ok, tt.want)
+		}
+	}
+
+	if _, err := (ble.IntervalTuning{Fast: it.Slow, Slow: it.Fast}).WithDefaults(); err == nil {
+		t.Error("fast parameters slower than the slow ones accepted")
+	}
+}
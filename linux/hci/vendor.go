@@ -0,0 +1,61 @@
+package hci
+
+import (
+	"errors"
+)
+
+// ogfVendor is the OGF of the vendor specific commands. [Vol 4, Part E, 5.4.1]
+const ogfVendor = 0x3F
+
+// errInvalidOpcode is returned for commands beyond the 6 bit OGF, 10 bit OCF,
+// or the 255 bytes of parameters.
+var errInvalidOpcode = errors.New("invalid command opcode or parameters")
+
+// rawCommand is a command of arbitrary opcode and parameters.
+type rawCommand struct {
+	op     int
+	params []byte
+}
+
+func (c *rawCommand) OpCode() int { return c.op }
+func (c *rawCommand) Len() int    { return len(c.params) }
+
+func (c *rawCommand) Marshal(b []byte) error {
+	copy(b, c.params)
+	return nil
+}
+
+// rawRP keeps the return parameters of a rawCommand.
+type rawRP struct {
+	b []byte
+}
+
+func (r *rawRP) Unmarshal(b []byte) error {
+	r.b = append([]byte(nil), b...)
+	return nil
+}
+
+// SendCommand sends the command of ogf and ocf, with the parameters, and
+// returns the return parameters of the Command Complete event, after the
+// status, which is returned as an ErrCommand if not 0. Nothing follows the
+// status of the commands answered by Command Status events.
+func (h *HCI) SendCommand(ogf uint8, ocf uint16, params []byte) ([]byte, error) {
+	if ogf > 0x3F || ocf > 0x3FF || len(params) > 255 {
+		return nil, errInvalidOpcode
+	}
+	rp := &rawRP{}
+	if err := h.Send(&rawCommand{op: int(ogf)<<10 | int(ocf), params: params}, rp); err != nil {
+		return nil, err
+	}
+	if len(rp.b) == 0 {
+		return nil, nil
+	}
+	return rp.b[1:], nil
+}
+
+// SendVendorCommand sends the vendor specific command of ocf, such as the
+// Set BD_ADDR of Broadcom controllers, and returns the return parameters, as
+// SendCommand does.
+func (h *HCI) SendVendorCommand(ocf uint16, params []byte) ([]byte, error) {
+	return h.SendCommand(ogfVendor, ocf, params)
+}
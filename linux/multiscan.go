@@ -0,0 +1,127 @@
+package linux
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+	"github.com/pkg/errors"
+)
+
+// AdapterAdvertisement is an advertisement received by one of the adapters of
+// a MultiScanner.
+type AdapterAdvertisement struct {
+	ble.Advertisement
+
+	// Adapter is the index of the receiving device in MultiScanner.Devices.
+	Adapter int
+
+	// Timestamp is the time the report was received, less the offset of
+	// the adapter.
+	Timestamp time.Time
+}
+
+// AdapterAdvHandler handles advertisements from a MultiScanner.
+type AdapterAdvHandler func(a AdapterAdvertisement)
+
+// MultiScanner scans on several adapters at once and merges their reports
+// into a single stream, suitable as input for RSSI triangulation or
+// fingerprinting. Reports may be delivered out of order; use Timestamp to
+// order them.
+type MultiScanner struct {
+	Devices []*Device
+
+	mu      sync.Mutex
+	offsets []time.Duration
+}
+
+// NewMultiScanner returns a MultiScanner over the given devices.
+func NewMultiScanner(devs ...*Device) *MultiScanner {
+	return &MultiScanner{Devices: devs, offsets: make([]time.Duration, len(devs))}
+}
+
+// SetOffset sets the clock offset of an adapter, which is subtracted from the
+// receive time of its reports. Use it for adapters whose timestamps are taken
+// against a different clock, e.g. on another host.
+func (m *MultiScanner) SetOffset(adapter int, d time.Duration) {
+	m.mu.Lock()
+	m.offsets[adapter] = d
+	m.mu.Unlock()
+}
+
+// Offset returns the clock offset of an adapter.
+func (m *MultiScanner) Offset(adapter int) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offsets[adapter]
+}
+
+// Calibrate measures the latency of the transport between the host and the
+// controller of each adapter, and sets it as the offset of the adapter, so
+// that the timestamps of its reports, taken by the host once they're read,
+// are moved back to about the time the controller sent them. It issues
+// samples round trips of Read BD_ADDR, a command without side effects, on
+// the clock of the adapter, and takes half of the shortest one, which is the
+// least delayed by queuing.
+//
+// Calibrate doesn't estimate the offset between the clocks of the adapters,
+// as the adapters of a host are timestamped on its clock. Set the offsets of
+// the adapters timestamped on other clocks with SetOffset instead.
+func (m *MultiScanner) Calibrate(samples int) error {
+	if samples < 1 {
+		samples = 1
+	}
+	for i, d := range m.Devices {
+		clk := d.HCI.Clock()
+		var min time.Duration
+		for n := 0; n < samples; n++ {
+			start := clk.Now()
+			if err := d.HCI.Send(&cmd.ReadBDADDR{}, &cmd.ReadBDADDRRP{}); err != nil {
+				return errors.Wrapf(err, "can't calibrate adapter %d", i)
+			}
+			if rtt := clk.Now().Sub(start); n == 0 || rtt < min {
+				min = rtt
+			}
+		}
+		m.SetOffset(i, min/2)
+	}
+	return nil
+}
+
+// Scan starts scanning on all adapters and delivers their reports to h.
+// Duplicated advertisements will be filtered out if allowDup is set to false.
+// It blocks until ctx is done.
+func (m *MultiScanner) Scan(ctx context.Context, allowDup bool, h AdapterAdvHandler) error {
+	if len(m.Devices) == 0 {
+		return errors.New("no adapters to scan")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(m.Devices))
+	for i, d := range m.Devices {
+		go func(i int, d *Device) {
+			errs <- d.Scan(ctx, allowDup, func(a ble.Advertisement) {
+				t := d.HCI.Clock().Now()
+				if ta, ok := a.(interface{ Timestamp() time.Time }); ok {
+					t = ta.Timestamp()
+				}
+				h(AdapterAdvertisement{
+					Advertisement: a,
+					Adapter:       i,
+					Timestamp:     t.Add(-m.Offset(i)),
+				})
+			})
+		}(i, d)
+	}
+
+	// Stop all adapters as soon as one of them returns.
+	err := <-errs
+	cancel()
+	for i := 1; i < len(m.Devices); i++ {
+		<-errs
+	}
+	return err
+}
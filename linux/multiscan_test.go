@@ -0,0 +1,180 @@
+package linux
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/hci/cmd"
+)
+
+// fakeController completes every command of the host. It answers the reads
+// of BD_ADDR once clk is advanced by the next of delays, and reports an
+// advertisement of adv, once scanning is enabled.
+type fakeController struct {
+	clk    *ble.FakeClock
+	adv    [6]byte
+	delays []time.Duration
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	rx     [][]byte
+	closed bool
+}
+
+func newFakeController(clk *ble.FakeClock, adv [6]byte, delays ...time.Duration) *fakeController {
+	c := &fakeController{clk: clk, adv: adv, delays: delays}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *fakeController) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !c.closed && len(c.rx) == 0 {
+		c.cond.Wait()
+	}
+	if c.closed {
+		return 0, io.EOF
+	}
+	n := copy(p, c.rx[0])
+	c.rx = c.rx[1:]
+	return n, nil
+}
+
+func (c *fakeController) Write(p []byte) (int, error) {
+	if len(p) < 4 || p[0] != 0x01 {
+		return len(p), nil
+	}
+	op := int(p[1]) | int(p[2])<<8
+
+	// Pad the return parameters with zeros, as most fields aren't checked.
+	var rp []byte
+	switch op {
+	case (&cmd.ReadBDADDR{}).OpCode():
+		rp = []byte{0x66, 0x55, 0x44, 0x33, 0x22, 0x11}
+		c.mu.Lock()
+		if len(c.delays) > 0 {
+			c.clk.Advance(c.delays[0])
+			c.delays = c.delays[1:]
+		}
+		c.mu.Unlock()
+	case (&cmd.LEReadBufferSize{}).OpCode():
+		rp = []byte{27, 0, 8}
+	}
+	params := append([]byte{0x01, p[1], p[2], 0x00}, rp...)
+	params = append(params, make([]byte, 16)...)
+	c.send(append([]byte{0x04, 0x0E, byte(len(params))}, params...))
+
+	if op == (&cmd.LESetScanEnable{}).OpCode() && p[4] == 1 {
+		// LE Advertising Report of an ADV_IND without data, at -60 dBm.
+		a := c.adv
+		c.send([]byte{0x04, 0x3E, 12, 0x02, 0x01, 0x00, 0x00, a[0], a[1], a[2], a[3], a[4], a[5], 0x00, 0xC4})
+	}
+	return len(p), nil
+}
+
+func (c *fakeController) send(b []byte) {
+	c.mu.Lock()
+	c.rx = append(c.rx, b)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+}
+
+func (c *fakeController) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return nil
+}
+
+func newFakeDevice(t *testing.T, c *fakeController) *Device {
+	d, err := NewDevice(ble.OptTransport(c), ble.OptClock(c.clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCalibrate(t *testing.T) {
+	// The first read of BD_ADDR is that of Init. The round trips of the
+	// second adapter are slower.
+	d0 := newFakeDevice(t, newFakeController(ble.NewFakeClock(time.Unix(0, 0)), [6]byte{}, 0, 30*time.Millisecond, 20*time.Millisecond, 40*time.Millisecond))
+	defer d0.Stop()
+	d1 := newFakeDevice(t, newFakeController(ble.NewFakeClock(time.Unix(0, 0)), [6]byte{}, 0, 80*time.Millisecond, 60*time.Millisecond, 70*time.Millisecond))
+	defer d1.Stop()
+
+	m := NewMultiScanner(d0, d1)
+	if err := m.Calibrate(3); err != nil {
+		t.Fatal(err)
+	}
+	// Half of the shortest round trip.
+	for i, want := range []time.Duration{10 * time.Millisecond, 30 * time.Millisecond} {
+		if got := m.Offset(i); got != want {
+			t.Errorf("Offset(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestMultiScanner(t *testing.T) {
+	clk := ble.NewFakeClock(time.Unix(100, 0))
+	addrs := [][6]byte{
+		{0x01, 0x00, 0x00, 0x00, 0x00, 0xAA},
+		{0x02, 0x00, 0x00, 0x00, 0x00, 0xBB},
+	}
+	d0 := newFakeDevice(t, newFakeController(clk, addrs[0]))
+	defer d0.Stop()
+	d1 := newFakeDevice(t, newFakeController(clk, addrs[1]))
+	defer d1.Stop()
+
+	m := NewMultiScanner(d0, d1)
+	m.SetOffset(1, 20*time.Millisecond)
+	if d := m.Offset(1); d != 20*time.Millisecond {
+		t.Fatalf("Offset(1) = %s, want 20ms", d)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got := make(chan AdapterAdvertisement, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- m.Scan(ctx, false, func(a AdapterAdvertisement) { got <- a })
+	}()
+
+	// The reports of both adapters are merged, each timestamped less the
+	// offset of its adapter.
+	want := map[int]string{0: "aa:00:00:00:00:01", 1: "bb:00:00:00:00:02"}
+	for len(want) > 0 {
+		select {
+		case a := <-got:
+			addr, ok := want[a.Adapter]
+			if !ok {
+				t.Fatalf("unexpected report of adapter %d", a.Adapter)
+			}
+			delete(want, a.Adapter)
+			if a.Addr().String() != addr {
+				t.Errorf("adapter %d reported %s, want %s", a.Adapter, a.Addr(), addr)
+			}
+			if ts := clk.Now().Add(-m.Offset(a.Adapter)); !a.Timestamp.Equal(ts) {
+				t.Errorf("adapter %d timestamped %s, want %s", a.Adapter, a.Timestamp, ts)
+			}
+		case <-ctx.Done():
+			t.Fatalf("no report of the adapters %v", want)
+		}
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Scan() = %v, want context.Canceled", err)
+	}
+}
+
+func TestMultiScannerNoDevices(t *testing.T) {
+	if err := NewMultiScanner().Scan(context.Background(), false, nil); err == nil {
+		t.Error("Scan() without adapters succeeded")
+	}
+}
@@ -0,0 +1,70 @@
+// Package smp implements the cryptographic toolbox of the Security Manager.
+// [Vol 3, Part H, 2.2]
+//
+// The octet strings of the functions are most significant octet first, as
+// the specification defines them, while the fields of the SMP PDUs and of
+// the OOB data are little endian.
+package smp
+
+import "crypto/aes"
+
+// AESCMAC implements AES-CMAC as specified in RFC 4493.
+func AESCMAC(key, msg []byte) [16]byte {
+	c, _ := aes.NewCipher(key) // key is always 16 bytes.
+
+	var k1, k2, l [16]byte
+	c.Encrypt(l[:], l[:])
+	shift(k1[:], l[:])
+	shift(k2[:], k1[:])
+
+	n := (len(msg) + 15) / 16
+	last := make([]byte, 16)
+	if n == 0 || len(msg)%16 != 0 {
+		if n == 0 {
+			n = 1
+		}
+		r := msg[(n-1)*16:]
+		copy(last, r)
+		last[len(r)] = 0x80
+		xor(last, k2[:])
+	} else {
+		copy(last, msg[(n-1)*16:])
+		xor(last, k1[:])
+	}
+
+	var x [16]byte
+	for i := 0; i < n-1; i++ {
+		xor(x[:], msg[i*16:(i+1)*16])
+		c.Encrypt(x[:], x[:])
+	}
+	xor(x[:], last)
+	c.Encrypt(x[:], x[:])
+	return x
+}
+
+// shift sets dst to src shifted left by one bit, xored with Rb on carry.
+func shift(dst, src []byte) {
+	var carry byte
+	for i := 15; i >= 0; i-- {
+		dst[i] = src[i]<<1 | carry
+		carry = src[i] >> 7
+	}
+	if carry != 0 {
+		dst[15] ^= 0x87
+	}
+}
+
+func xor(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
+
+// F4 implements the LE Secure Connections confirm value generation function
+// f4(U, V, X, Z) = AES-CMAC_X(U || V || Z). [Vol 3, Part H, 2.2.6]
+func F4(u, v, x []byte, z byte) [16]byte {
+	m := make([]byte, 0, len(u)+len(v)+1)
+	m = append(m, u...)
+	m = append(m, v...)
+	return AESCMAC(x, append(m, z))
+}
@@ -0,0 +1,37 @@
+package smp
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestAESCMAC(t *testing.T) {
+	// Test vectors of RFC 4493, 4.
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411")
+	tests := []struct {
+		len  int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+	}
+	for _, tt := range tests {
+		mac := AESCMAC(key, msg[:tt.len])
+		if got := hex.EncodeToString(mac[:]); got != tt.want {
+			t.Errorf("len %d: got %s, want %s", tt.len, got, tt.want)
+		}
+	}
+}
+
+func TestF4(t *testing.T) {
+	// Sample data of [Vol 3, Part H, D.2].
+	u, _ := hex.DecodeString("20b003d2f297be2c5e2c83a7e9f9a5b9eff49111acf4fddbcc0301480e359de6")
+	v, _ := hex.DecodeString("55188b3d32f6bb9a900afcfbeed4e72a59cb9ac2f19d7cfb6b4fdd49f47fc5fd")
+	x, _ := hex.DecodeString("d5cb8454d177733effffb2ec712baeab")
+	c := F4(u, v, x, 0x00)
+	if got, want := hex.EncodeToString(c[:]), "f2c916f107a9bd1cf1eda1bea974872d"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,133 @@
+package ble
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mgutz/logxi/v1"
+)
+
+// Level is the severity of a log event.
+type Level int
+
+// Levels.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Subsystems of the stack, which the log events are emitted by.
+const (
+	LogHCI   = "hci"   // HCI commands and events.
+	LogConn  = "conn"  // Connection lifecycle.
+	LogL2CAP = "l2cap" // L2CAP signaling and channels.
+	LogSMP   = "smp"   // Security Manager PDUs.
+	LogATT   = "att"   // ATT PDUs.
+	LogGATT  = "gatt"  // GATT client and server.
+)
+
+// A Logger receives the structured log events of the stack. The keyvals
+// alternate between keys, which are strings, and their values.
+type Logger interface {
+	Log(level Level, subsystem, msg string, keyvals ...interface{})
+}
+
+// LoggerFunc adapts a function to a Logger.
+type LoggerFunc func(level Level, subsystem, msg string, keyvals ...interface{})
+
+// Log calls f.
+func (f LoggerFunc) Log(level Level, subsystem, msg string, keyvals ...interface{}) {
+	f(level, subsystem, msg, keyvals...)
+}
+
+// FilterLogger returns a Logger which passes the events of at least min
+// level to l. If subsystems are given, the events of the other subsystems
+// are discarded.
+func FilterLogger(l Logger, min Level, subsystems ...string) Logger {
+	var enabled map[string]bool
+	if len(subsystems) > 0 {
+		enabled = make(map[string]bool, len(subsystems))
+		for _, s := range subsystems {
+			enabled[s] = true
+		}
+	}
+	return LoggerFunc(func(level Level, subsystem, msg string, keyvals ...interface{}) {
+		if level < min || (enabled != nil && !enabled[subsystem]) {
+			return
+		}
+		l.Log(level, subsystem, msg, keyvals...)
+	})
+}
+
+// DefaultLogger is used unless a Logger is set with OptLogger. It writes
+// to logxi loggers named after the subsystems, which are configured by the
+// LOGXI environment variable.
+var DefaultLogger Logger = &logxiLogger{m: make(map[string]log.Logger)}
+
+type logxiLogger struct {
+	sync.Mutex
+	m map[string]log.Logger
+}
+
+func (x *logxiLogger) Log(level Level, subsystem, msg string, keyvals ...interface{}) {
+	x.Lock()
+	l, ok := x.m[subsystem]
+	if !ok {
+		l = log.New(subsystem)
+		x.m[subsystem] = l
+	}
+	x.Unlock()
+
+	switch level {
+	case LevelDebug:
+		l.Debug(msg, keyvals...)
+	case LevelInfo:
+		l.Info(msg, keyvals...)
+	case LevelWarn:
+		_ = l.Warn(msg, keyvals...)
+	default:
+		_ = l.Error(msg, keyvals...)
+	}
+}
+
+// A SubsystemLogger emits the events of a subsystem to a Logger.
+type SubsystemLogger struct {
+	Logger    Logger
+	Subsystem string
+}
+
+// Debug emits a debug event.
+func (s SubsystemLogger) Debug(msg string, keyvals ...interface{}) {
+	s.Logger.Log(LevelDebug, s.Subsystem, msg, keyvals...)
+}
+
+// Info emits an info event.
+func (s SubsystemLogger) Info(msg string, keyvals ...interface{}) {
+	s.Logger.Log(LevelInfo, s.Subsystem, msg, keyvals...)
+}
+
+// Warn emits a warning event.
+func (s SubsystemLogger) Warn(msg string, keyvals ...interface{}) {
+	s.Logger.Log(LevelWarn, s.Subsystem, msg, keyvals...)
+}
+
+// Error emits an error event.
+func (s SubsystemLogger) Error(msg string, keyvals ...interface{}) {
+	s.Logger.Log(LevelError, s.Subsystem, msg, keyvals...)
+}
@@ -0,0 +1,25 @@
+package ble
+
+import "testing"
+
+func TestFilterLogger(t *testing.T) {
+	var got []string
+	l := FilterLogger(LoggerFunc(func(level Level, subsystem, msg string, keyvals ...interface{}) {
+		got = append(got, subsystem+":"+msg)
+	}), LevelInfo, LogATT, LogConn)
+
+	l.Log(LevelDebug, LogATT, "debug")
+	l.Log(LevelInfo, LogATT, "info")
+	l.Log(LevelError, LogHCI, "error")
+	l.Log(LevelWarn, LogConn, "warn")
+
+	want := []string{"att:info", "conn:warn"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
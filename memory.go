@@ -0,0 +1,35 @@
+package ble
+
+// MemoryLimits cap the buffers, caches, and queues a device allocates
+// internally, trading throughput under load for a bounded footprint. The
+// zero values keep the defaults.
+type MemoryLimits struct {
+	// ScanBuffer caps the advertising reports queued for the AdvHandler,
+	// even if OptScanBuffer sets more.
+	ScanBuffer int
+
+	// DedupEntries caps the advertisements remembered to filter the
+	// duplicates on the host. Beyond it, the ones remembered are forgotten,
+	// and their duplicates passed again.
+	DedupEntries int
+
+	// ExtChains caps the chains of extended advertising reports reassembled
+	// at a time. The fragments beyond it are dropped.
+	ExtChains int
+
+	// ConnQueue caps the packets, and the PDUs, queued for each connection.
+	ConnQueue int
+
+	// NoGATTCache disables the GATT cache, even if OptGATTCache enables it.
+	NoGATTCache bool
+}
+
+// BoundedMemory are the MemoryLimits for constrained devices, such as the
+// routers running OpenWrt with 64 to 128 MB of memory, scanning busy places.
+var BoundedMemory = MemoryLimits{
+	ScanBuffer:   16,
+	DedupEntries: 256,
+	ExtChains:    4,
+	ConnQueue:    4,
+	NoGATTCache:  true,
+}
@@ -0,0 +1,96 @@
+package mesh
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// A Client is the client bearer of PB-GATT, or of the GATT proxy, on the
+// connection to a node.
+type Client struct {
+	cln ble.Client
+	in  *ble.Characteristic
+	out *ble.Characteristic
+
+	mu sync.Mutex // Serializes the sends, so that their segments don't interleave.
+	r  Reassembler
+}
+
+// NewClient returns the client bearer of the service u, ProvisioningUUID or
+// ProxyUUID, of cln, which passes the messages the node sends to h. The
+// service is discovered, unless the profile of cln has it already. The
+// proxy PDUs, which don't follow the segmentation of a message, are dropped.
+func NewClient(cln ble.Client, u ble.UUID, h Handler) (*Client, error) {
+	inUUID, outUUID, err := dataUUIDs(u)
+	if err != nil {
+		return nil, err
+	}
+	var s *ble.Service
+	if p := cln.Profile(); p != nil {
+		if s = p.FindService(ble.NewService(u)); s != nil && len(s.Characteristics) == 0 {
+			s = nil
+		}
+	}
+	if s == nil {
+		p, err := ble.DiscoverPartialProfile(cln, []ble.UUID{u})
+		if err != nil {
+			return nil, err
+		}
+		if s = p.FindService(ble.NewService(u)); s == nil {
+			return nil, fmt.Errorf("service %s not found", u)
+		}
+	}
+	c := &Client{cln: cln}
+	for _, ch := range s.Characteristics {
+		switch {
+		case ch.UUID.Equal(inUUID):
+			c.in = ch
+		case ch.UUID.Equal(outUUID):
+			c.out = ch
+		}
+	}
+	if c.in == nil || c.out == nil {
+		return nil, fmt.Errorf("service %s lacks its data characteristics", u)
+	}
+	err = cln.Subscribe(c.out, false, func(p []byte) {
+		if typ, msg, err := c.r.Push(p); err == nil && msg != nil {
+			h(typ, msg)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Send sends the message typ to the node, segmented to the MTU of the
+// connection.
+func (c *Client) Send(typ MessageType, msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, p := range Segment(typ, msg, c.cln.Conn().TxMTU()-3) {
+		if err := c.cln.WriteCharacteristic(c.in, p, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close unsubscribes from the messages of the node. The connection remains.
+func (c *Client) Close() error {
+	return c.cln.Unsubscribe(c.out, false)
+}
+
+// dataUUIDs returns the Data In and the Data Out characteristics of the
+// service u.
+func dataUUIDs(u ble.UUID) (ble.UUID, ble.UUID, error) {
+	switch {
+	case u.Equal(ProvisioningUUID):
+		return ProvisioningDataInUUID, ProvisioningDataOutUUID, nil
+	case u.Equal(ProxyUUID):
+		return ProxyDataInUUID, ProxyDataOutUUID, nil
+	}
+	return nil, nil, fmt.Errorf("service %s isn't a mesh service", u)
+}
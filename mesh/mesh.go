@@ -0,0 +1,164 @@
+// Package mesh provides the GATT bearers of Bluetooth Mesh: PB-GATT, over the
+// Mesh Provisioning Service, which provisions a device, and the GATT proxy,
+// over the Mesh Proxy Service, which relays the network PDUs of a node. It
+// carries the proxy PDUs, segmenting them to the MTU of the connection, and
+// reassembling them, so that a mesh stack built in Go uses it as its bearer
+// layer: the stack itself, the provisioning protocol, and the network and
+// transport layers, are left out.
+//
+// The node serves the service with a Server, and the client, such as a
+// provisioner, reaches it with a Client:
+//
+//	srv := mesh.NewServer(mesh.ProvisioningUUID, func(conn ble.Conn, typ mesh.MessageType, pdu []byte) {
+//		// Process the provisioning PDU, and respond with srv.Send.
+//	})
+//	d.AddService(srv.Service)
+//
+// [MshPRT, 6.3, 7.1 and 7.2]
+package mesh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// The Mesh Provisioning Service, the Mesh Proxy Service, and their
+// characteristics. The clients write the Data In characteristic, and the
+// server notifies the Data Out characteristic.
+var (
+	ProvisioningUUID        = ble.UUID16(0x1827)
+	ProvisioningDataInUUID  = ble.UUID16(0x2ADB)
+	ProvisioningDataOutUUID = ble.UUID16(0x2ADC)
+
+	ProxyUUID        = ble.UUID16(0x1828)
+	ProxyDataInUUID  = ble.UUID16(0x2ADD)
+	ProxyDataOutUUID = ble.UUID16(0x2ADE)
+)
+
+// A MessageType is the type of the message a proxy PDU carries.
+type MessageType byte
+
+// Message types. [MshPRT, 6.3.1]
+const (
+	NetworkPDU         MessageType = 0x00
+	MeshBeacon         MessageType = 0x01
+	ProxyConfiguration MessageType = 0x02
+	ProvisioningPDU    MessageType = 0x03
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case NetworkPDU:
+		return "Network PDU"
+	case MeshBeacon:
+		return "Mesh Beacon"
+	case ProxyConfiguration:
+		return "Proxy Configuration"
+	case ProvisioningPDU:
+		return "Provisioning PDU"
+	}
+	return fmt.Sprintf("0x%02X", byte(t))
+}
+
+// A Handler handles the messages received on a bearer.
+type Handler func(typ MessageType, pdu []byte)
+
+// The SAR field of a proxy PDU, which tells how the message is segmented.
+const (
+	sarComplete = 0x00
+	sarFirst    = 0x01
+	sarContinue = 0x02
+	sarLast     = 0x03
+)
+
+// ReassemblyTimeout is how long the segments of a message may take to
+// arrive. [MshPRT, 6.6]
+const ReassemblyTimeout = 20 * time.Second
+
+// ErrSegmentation is the error, as told by errors.Is, of the proxy PDUs,
+// which don't follow the segmentation of a message. The server disconnects a
+// client, which sends them. [MshPRT, 6.3.2]
+var ErrSegmentation = errors.New("mesh: unexpected segmentation")
+
+// Segment returns the proxy PDUs carrying the message typ, of up to mtu
+// bytes, the ATT_MTU-3 of the connection, each. [MshPRT, 6.3.2]
+func Segment(typ MessageType, msg []byte, mtu int) [][]byte {
+	if mtu < 2 {
+		mtu = 2
+	}
+	if 1+len(msg) <= mtu {
+		return [][]byte{append([]byte{sarComplete<<6 | byte(typ)}, msg...)}
+	}
+	var pdus [][]byte
+	for sar := byte(sarFirst); len(msg) > 0; sar = sarContinue {
+		n := len(msg)
+		if n > mtu-1 {
+			n = mtu - 1
+		} else {
+			sar = sarLast
+		}
+		pdus = append(pdus, append([]byte{sar<<6 | byte(typ)}, msg[:n]...))
+		msg = msg[n:]
+	}
+	return pdus
+}
+
+// A Reassembler reassembles the messages of the proxy PDUs of a bearer.
+type Reassembler struct {
+	typ   MessageType
+	buf   []byte
+	busy  bool
+	start time.Time
+}
+
+// Push adds the proxy PDU p, and returns the message, once complete, or a nil
+// message, if more segments are due. A PDU, which doesn't follow the
+// segments pushed, fails with ErrSegmentation, and drops them.
+func (r *Reassembler) Push(p []byte) (MessageType, []byte, error) {
+	if len(p) < 1 {
+		r.busy, r.buf = false, nil
+		return 0, nil, fmt.Errorf("%w: empty PDU", ErrSegmentation)
+	}
+	sar, typ := p[0]>>6, MessageType(p[0]&0x3F)
+	if r.busy && time.Since(r.start) > ReassemblyTimeout {
+		r.busy, r.buf = false, nil
+	}
+	switch {
+	case sar == sarComplete && !r.busy:
+		return typ, append([]byte{}, p[1:]...), nil
+	case sar == sarFirst && !r.busy:
+		r.typ, r.buf, r.busy, r.start = typ, append([]byte{}, p[1:]...), true, time.Now()
+		return typ, nil, nil
+	case (sar == sarContinue || sar == sarLast) && r.busy && typ == r.typ:
+		r.buf = append(r.buf, p[1:]...)
+		if sar == sarContinue {
+			return typ, nil, nil
+		}
+		msg := r.buf
+		r.busy, r.buf = false, nil
+		return typ, msg, nil
+	}
+	r.busy, r.buf = false, nil
+	return 0, nil, fmt.Errorf("%w: SAR %d of %s", ErrSegmentation, sar, typ)
+}
+
+// ProvisioningServiceData returns the service data of ProvisioningUUID,
+// which an unprovisioned device advertises, connectable, while it may be
+// provisioned over PB-GATT. [MshPRT, 7.1.2.2.1]
+func ProvisioningServiceData(deviceUUID ble.UUID, oob uint16) []byte {
+	b := make([]byte, 18)
+	copy(b, ble.Reverse(deviceUUID)) // Big endian.
+	binary.BigEndian.PutUint16(b[16:], oob)
+	return b
+}
+
+// ProxyServiceData returns the service data of ProxyUUID, which a proxy node
+// advertises, connectable, with the network ID of a subnet. [MshPRT,
+// 7.2.2.2.2]
+func ProxyServiceData(networkID [8]byte) []byte {
+	return append([]byte{0x00}, networkID[:]...)
+}
@@ -0,0 +1,115 @@
+package mesh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/mock"
+)
+
+func TestSegment(t *testing.T) {
+	msg := make([]byte, 50)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	pdus := Segment(ProvisioningPDU, msg, 20)
+	if len(pdus) != 3 || pdus[0][0] != 0x43 || pdus[1][0] != 0x83 || pdus[2][0] != 0xC3 || len(pdus[2]) != 1+50-2*19 {
+		t.Fatalf("unexpected segments % X", pdus)
+	}
+	var r Reassembler
+	for i, p := range pdus {
+		typ, m, err := r.Push(p)
+		if err != nil || typ != ProvisioningPDU {
+			t.Fatalf("segment %d: %v, %v", i, typ, err)
+		}
+		if i < len(pdus)-1 && m != nil || i == len(pdus)-1 && !bytes.Equal(m, msg) {
+			t.Fatalf("segment %d: message % X", i, m)
+		}
+	}
+
+	if pdus := Segment(NetworkPDU, msg[:19], 20); len(pdus) != 1 || pdus[0][0] != 0x00 {
+		t.Fatalf("unexpected segments % X", pdus)
+	}
+	if _, _, err := r.Push(pdus[1]); !errors.Is(err, ErrSegmentation) {
+		t.Fatalf("continuation without a first segment: %v", err)
+	}
+	r.Push(pdus[0])
+	if _, _, err := r.Push([]byte{0x00, 0x01}); !errors.Is(err, ErrSegmentation) {
+		t.Fatalf("complete message amid segments: %v", err)
+	}
+}
+
+func TestBearer(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(0)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Node")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Provisioner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	invite := []byte{0x00, 0x05}
+	caps := bytes.Repeat([]byte{0x01}, 45)
+	var srv *Server
+	srv = NewServer(ProvisioningUUID, func(conn ble.Conn, typ MessageType, msg []byte) {
+		if typ == ProvisioningPDU && bytes.Equal(msg, invite) {
+			srv.Send(conn, ProvisioningPDU, caps)
+		}
+	})
+	if err := p.AddService(srv.Service); err != nil {
+		t.Fatal(err)
+	}
+	go p.AdvertiseServiceData16(ctx, 0x1827, ProvisioningServiceData(ble.MustParse("0123456789abcdef0123456789abcdef"), 0))
+
+	cln, err := c.Dial(ctx, p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+	got := make(chan []byte, 1)
+	bc, err := NewClient(cln, ProvisioningUUID, func(typ MessageType, msg []byte) { got <- msg })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.Close()
+	// The subscription is served in the background.
+	for len(srv.Conns()) == 0 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("client not subscribed")
+		}
+	}
+	if err := bc.Send(ProvisioningPDU, invite); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		if !bytes.Equal(msg, caps) {
+			t.Fatalf("received % X, want % X", msg, caps)
+		}
+	case <-ctx.Done():
+		t.Fatal("message not received")
+	}
+
+	// Out of sequence.
+	if err := cln.WriteCharacteristic(bc.in, []byte{0x83, 0x00}, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-cln.Disconnected():
+	case <-ctx.Done():
+		t.Fatal("client not disconnected")
+	}
+}
@@ -0,0 +1,137 @@
+package mesh
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// ErrNotSubscribed is the error of the sends to a client, which isn't
+// subscribed to the Data Out characteristic.
+var ErrNotSubscribed = errors.New("mesh: client not subscribed")
+
+// A ServerHandler handles the messages, which the client of conn sends.
+type ServerHandler func(conn ble.Conn, typ MessageType, msg []byte)
+
+// A Server is the server bearer of PB-GATT, or of the GATT proxy, serving
+// the clients connected. A client, which sends the proxy PDUs of a message
+// out of sequence, is disconnected. [MshPRT, 6.3.2]
+type Server struct {
+	Service *ble.Service
+
+	h     ServerHandler
+	mu    sync.Mutex
+	conns map[ble.Conn]*serverConn
+}
+
+// serverConn is the bearer of a client.
+type serverConn struct {
+	n  ble.Notifier // Set once subscribed.
+	wm sync.Mutex   // Serializes the sends, so that their segments don't interleave.
+	r  Reassembler
+}
+
+// NewServer returns the server bearer of the service u, ProvisioningUUID or
+// ProxyUUID, which passes the messages the clients send to h. It panics, if
+// u isn't either.
+func NewServer(u ble.UUID, h ServerHandler) *Server {
+	inUUID, outUUID, err := dataUUIDs(u)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{Service: ble.NewService(u), h: h, conns: map[ble.Conn]*serverConn{}}
+	s.Service.BuildCharacteristic(inUUID).
+		Properties(ble.CharWriteNR).
+		OnWrite(ble.WriteHandlerFunc(s.written))
+	s.Service.BuildCharacteristic(outUUID).
+		Properties(ble.CharNotify).
+		OnNotify(ble.NotifyHandlerFunc(s.notify))
+	return s
+}
+
+// Send sends the message typ to the client of conn, segmented to the MTU of
+// the connection.
+func (s *Server) Send(conn ble.Conn, typ MessageType, msg []byte) error {
+	s.mu.Lock()
+	sc := s.conns[conn]
+	var n ble.Notifier
+	if sc != nil {
+		n = sc.n
+	}
+	s.mu.Unlock()
+	if n == nil {
+		return ErrNotSubscribed
+	}
+	mtu := n.Cap()
+	if mtu <= 0 {
+		mtu = ble.DefaultMTU - 3
+	}
+	sc.wm.Lock()
+	defer sc.wm.Unlock()
+	for _, p := range Segment(typ, msg, mtu) {
+		if _, err := n.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Conns returns the connections of the clients subscribed.
+func (s *Server) Conns() []ble.Conn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cs []ble.Conn
+	for c, sc := range s.conns {
+		if sc.n != nil {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// conn returns the bearer of the client of c, which is dropped as it
+// disconnects.
+func (s *Server) conn(c ble.Conn) *serverConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc := s.conns[c]
+	if sc == nil {
+		sc = &serverConn{}
+		s.conns[c] = sc
+		go func() {
+			<-c.Disconnected()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+	return sc
+}
+
+func (s *Server) written(req ble.Request, rsp ble.ResponseWriter) {
+	sc := s.conn(req.Conn())
+	s.mu.Lock()
+	typ, msg, err := sc.r.Push(req.Data())
+	s.mu.Unlock()
+	if err != nil {
+		req.Conn().Close()
+		return
+	}
+	if msg != nil && s.h != nil {
+		s.h(req.Conn(), typ, msg)
+	}
+}
+
+func (s *Server) notify(req ble.Request, n ble.Notifier) {
+	sc := s.conn(req.Conn())
+	s.mu.Lock()
+	sc.n = n
+	s.mu.Unlock()
+	<-n.Context().Done()
+	s.mu.Lock()
+	if sc.n == n {
+		sc.n = nil
+	}
+	s.mu.Unlock()
+}
@@ -0,0 +1,232 @@
+package ble
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the metrics of the stack. The counters only increase, while the
+// gauges go up and down.
+const (
+	MetricAdvReports        = "ble_adv_reports_total"                 // Counter of advertising reports received.
+	MetricAdvDropped        = "ble_adv_dropped_total"                 // Counter of advertisements dropped by the full channels of ScanChan, and the full queues of ScanBatches.
+	MetricConnsOpened       = "ble_connections_opened_total"          // Counter of connections established.
+	MetricConnsFailed       = "ble_connections_failed_total"          // Counter of connections which failed to establish.
+	MetricConns             = "ble_connections"                       // Gauge of open connections.
+	MetricCmdTimeouts       = "ble_hci_command_timeouts_total"        // Counter of HCI commands without response.
+	MetricACLTxBytes        = "ble_acl_tx_bytes_total"                // Counter of ACL payload bytes sent.
+	MetricACLRxBytes        = "ble_acl_rx_bytes_total"                // Counter of ACL payload bytes received.
+	MetricNotificationsTx   = "ble_gatt_notifications_sent_total"     // Counter of notifications and indications sent.
+	MetricNotificationsRx   = "ble_gatt_notifications_received_total" // Counter of notifications and indications received.
+	MetricATTRequestsActive = "ble_att_requests_outstanding"          // Gauge of ATT requests waiting for a response.
+	MetricATTClientsLimited = "ble_att_clients_limited_total"         // Counter of clients disconnected for exceeding the ServerLimits.
+	MetricMalformedDropped  = "ble_malformed_dropped_total"           // Counter of malformed events and PDUs dropped, of OptDropMalformed.
+)
+
+// Names of the latency histograms of the stack, which are observed by the
+// collectors that are LatencyObservers.
+const (
+	MetricReadLatency     = "ble_gatt_read_duration_seconds"    // Round trips of read requests.
+	MetricWriteLatency    = "ble_gatt_write_duration_seconds"   // Round trips of write requests.
+	MetricNotificationLag = "ble_gatt_notification_lag_seconds" // Notifications and indications sent, until completed by the controller at a connection event.
+)
+
+// A MetricsCollector receives the metrics of the stack. Add is called with
+// a positive delta for counters, and with either sign for gauges. It must be
+// safe for concurrent use.
+type MetricsCollector interface {
+	Add(name string, delta int64)
+}
+
+// A LatencyObserver is a MetricsCollector which also receives latencies.
+// The stack measures them only for the collectors which implement it.
+type LatencyObserver interface {
+	ObserveLatency(name string, d time.Duration)
+}
+
+// ObserveLatency passes d to m, if m is a LatencyObserver.
+func ObserveLatency(m MetricsCollector, name string, d time.Duration) {
+	if o, ok := m.(LatencyObserver); ok {
+		o.ObserveLatency(name, d)
+	}
+}
+
+// DefaultLatencyBuckets are the upper bounds of the buckets of the latency
+// histograms, which span the range of connection intervals.
+var DefaultLatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 25 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2500 * time.Millisecond, 5 * time.Second,
+}
+
+// A Histogram is a snapshot of a latency histogram.
+type Histogram struct {
+	Buckets []time.Duration // Upper bounds of the buckets.
+	Counts  []uint64        // Observations in each bucket, and above the last one.
+	Count   uint64          // Observations.
+	Sum     time.Duration   // Sum of the observations.
+}
+
+// Quantile returns the upper bound of the bucket of the q quantile, such as
+// 0.99, or 0 without observations. The quantiles above the last bucket are
+// reported as its bound.
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Buckets) == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.Count))
+	var n uint64
+	for i, b := range h.Buckets {
+		n += h.Counts[i]
+		if n > rank {
+			return b
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1]
+}
+
+// nopMetrics discards the metrics.
+type nopMetrics struct{}
+
+func (nopMetrics) Add(name string, delta int64) {}
+
+// NopMetrics is used unless a MetricsCollector is set with OptMetrics.
+var NopMetrics MetricsCollector = nopMetrics{}
+
+// Metrics is a MetricsCollector which keeps the metrics in memory. It serves
+// them over HTTP in the Prometheus text exposition format, to be scraped by
+// Prometheus or any compatible agent.
+type Metrics struct {
+	mu      sync.Mutex
+	v       map[string]int64
+	hist    map[string]*Histogram
+	buckets []time.Duration
+	labels  string
+}
+
+// NewMetrics returns an empty Metrics. The labels, given as pairs of names
+// and values, are attached to every exported sample, such as the name of the
+// adapter when serving several of them.
+func NewMetrics(labels ...string) *Metrics {
+	var l []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		l = append(l, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+	m := &Metrics{
+		v:       make(map[string]int64),
+		hist:    make(map[string]*Histogram),
+		buckets: DefaultLatencyBuckets,
+	}
+	if len(l) > 0 {
+		m.labels = "{" + strings.Join(l, ",") + "}"
+	}
+	return m
+}
+
+// Add adds delta to the metric name.
+func (m *Metrics) Add(name string, delta int64) {
+	m.mu.Lock()
+	m.v[name] += delta
+	m.mu.Unlock()
+}
+
+// Value returns the current value of the metric name.
+func (m *Metrics) Value(name string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.v[name]
+}
+
+// SetLatencyBuckets sets the upper bounds of the buckets of the histograms,
+// in increasing order, in place of DefaultLatencyBuckets. It must be called
+// before the first observation.
+func (m *Metrics) SetLatencyBuckets(b ...time.Duration) {
+	m.mu.Lock()
+	m.buckets = append([]time.Duration(nil), b...)
+	m.mu.Unlock()
+}
+
+// ObserveLatency adds d to the histogram name.
+func (m *Metrics) ObserveLatency(name string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.hist[name]
+	if !ok {
+		h = &Histogram{Buckets: m.buckets, Counts: make([]uint64, len(m.buckets)+1)}
+		m.hist[name] = h
+	}
+	i := sort.Search(len(h.Buckets), func(i int) bool { return d <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// Histogram returns a snapshot of the histogram name.
+func (m *Metrics) Histogram(name string) Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.hist[name]
+	if !ok {
+		return Histogram{Buckets: m.buckets, Counts: make([]uint64, len(m.buckets)+1)}
+	}
+	s := *h
+	s.Counts = append([]uint64(nil), h.Counts...)
+	return s
+}
+
+// bucketLabels returns the labels of the bucket of upper bound le.
+func (m *Metrics) bucketLabels(le string) string {
+	if m.labels == "" {
+		return fmt.Sprintf("{le=%q}", le)
+	}
+	return fmt.Sprintf("%s,le=%q}", strings.TrimSuffix(m.labels, "}"), le)
+}
+
+// WriteTo writes the metrics to w in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	names := make([]string, 0, len(m.v))
+	for name := range m.v {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		typ := "gauge"
+		if strings.HasSuffix(name, "_total") {
+			typ = "counter"
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n%s%s %d\n", name, typ, name, m.labels, m.v[name])
+	}
+	names = names[:0]
+	for name := range m.hist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		h := m.hist[name]
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		var n uint64
+		for i, le := range h.Buckets {
+			n += h.Counts[i]
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, m.bucketLabels(fmt.Sprint(le.Seconds())), n)
+		}
+		fmt.Fprintf(&b, "%s_bucket%s %d\n", name, m.bucketLabels("+Inf"), h.Count)
+		fmt.Fprintf(&b, "%s_sum%s %g\n", name, m.labels, h.Sum.Seconds())
+		fmt.Fprintf(&b, "%s_count%s %d\n", name, m.labels, h.Count)
+	}
+	m.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WriteTo(w)
+}
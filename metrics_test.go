@@ -0,0 +1,55 @@
+package ble
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMetricsWriteTo(t *testing.T) {
+	m := NewMetrics("adapter", "hci0")
+	m.Add(MetricConns, 2)
+	m.Add(MetricConns, -1)
+	m.Add(MetricAdvReports, 3)
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# TYPE ble_adv_reports_total counter\nble_adv_reports_total{adapter=\"hci0\"} 3\n" +
+		"# TYPE ble_connections gauge\nble_connections{adapter=\"hci0\"} 1\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMetricsHistogram(t *testing.T) {
+	m := NewMetrics()
+	m.SetLatencyBuckets(10*time.Millisecond, 100*time.Millisecond)
+	ObserveLatency(m, MetricReadLatency, 5*time.Millisecond)
+	ObserveLatency(m, MetricReadLatency, 50*time.Millisecond)
+	ObserveLatency(m, MetricReadLatency, 70*time.Millisecond)
+	ObserveLatency(m, MetricReadLatency, time.Second)
+
+	h := m.Histogram(MetricReadLatency)
+	if h.Count != 4 || h.Sum != 1125*time.Millisecond {
+		t.Errorf("count %d, sum %s, want 4 and 1.125s", h.Count, h.Sum)
+	}
+	if q := h.Quantile(0.5); q != 100*time.Millisecond {
+		t.Errorf("median = %s, want 100ms", q)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "# TYPE ble_gatt_read_duration_seconds histogram\n" +
+		"ble_gatt_read_duration_seconds_bucket{le=\"0.01\"} 1\n" +
+		"ble_gatt_read_duration_seconds_bucket{le=\"0.1\"} 3\n" +
+		"ble_gatt_read_duration_seconds_bucket{le=\"+Inf\"} 4\n" +
+		"ble_gatt_read_duration_seconds_sum 1.125\n" +
+		"ble_gatt_read_duration_seconds_count 4\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,56 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+)
+
+// advertisement is an advertising packet, as received from a Device.
+type advertisement struct {
+	p           *adv.Packet
+	addr        ble.Addr
+	rssi        int
+	connectable bool
+}
+
+func (a *advertisement) LocalName() string              { return a.p.LocalName() }
+func (a *advertisement) ManufacturerData() []byte       { return a.p.ManufacturerData() }
+func (a *advertisement) ServiceData() []ble.ServiceData { return a.p.ServiceData() }
+func (a *advertisement) Services() []ble.UUID           { return a.p.UUIDs() }
+func (a *advertisement) OverflowService() []ble.UUID    { return nil }
+func (a *advertisement) SolicitedService() []ble.UUID   { return a.p.ServiceSol() }
+func (a *advertisement) URI() string                    { return a.p.URI() }
+func (a *advertisement) Connectable() bool              { return a.connectable }
+func (a *advertisement) RSSI() int                      { return a.rssi }
+func (a *advertisement) Addr() ble.Addr                 { return a.addr }
+func (a *advertisement) LERole() ble.LERole             { return a.p.LERole() }
+func (a *advertisement) PublicTargetAddrs() []ble.Addr  { return a.p.PublicTargetAddrs() }
+func (a *advertisement) Records() []ble.ADStructure     { return a.p.Records() }
+
+func (a *advertisement) Appearance() uint16 {
+	v, _ := a.p.Appearance()
+	return v
+}
+
+func (a *advertisement) AdvInterval() time.Duration {
+	d, _ := a.p.AdvInterval()
+	return d
+}
+
+func (a *advertisement) TxPowerLevel() int {
+	pwr, _ := a.p.TxPower()
+	return pwr
+}
+
+// relayed is an Advertisement given to Device.Advertise, as received from
+// the Device.
+type relayed struct {
+	ble.Advertisement
+	addr ble.Addr
+	rssi int
+}
+
+func (a *relayed) RSSI() int      { return a.rssi }
+func (a *relayed) Addr() ble.Addr { return a.addr }
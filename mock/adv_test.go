@@ -0,0 +1,14 @@
+package mock
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble/internal/advtest"
+	"github.com/kirbo/ble/linux/adv"
+)
+
+func TestAdvConformance(t *testing.T) {
+	for _, c := range advtest.Cases {
+		advtest.Check(t, c, &advertisement{p: adv.NewRawPacket(c.Data()), rssi: c.RSSI, connectable: c.Connectable})
+	}
+}
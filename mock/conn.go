@@ -0,0 +1,119 @@
+package mock
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// conn is one end of an in-memory L2CAP connection. Each Write is read by
+// the peer as a whole PDU.
+type conn struct {
+	sync.Mutex
+	ctx context.Context
+
+	local  ble.Addr
+	remote ble.Addr
+	rxMTU  int
+	txMTU  int
+
+	in   chan []byte
+	peer *conn
+
+	// done is shared by both ends, and closed when either closes.
+	done      chan struct{}
+	closeOnce *sync.Once
+}
+
+// newConnPair returns the two ends of a connection between a and b.
+func newConnPair(a, b ble.Addr) (*conn, *conn) {
+	done := make(chan struct{})
+	once := &sync.Once{}
+	ca := &conn{ctx: context.Background(), local: a, remote: b, rxMTU: ble.DefaultMTU, txMTU: ble.DefaultMTU,
+		in: make(chan []byte, 16), done: done, closeOnce: once}
+	cb := &conn{ctx: context.Background(), local: b, remote: a, rxMTU: ble.DefaultMTU, txMTU: ble.DefaultMTU,
+		in: make(chan []byte, 16), done: done, closeOnce: once}
+	ca.peer, cb.peer = cb, ca
+	return ca, cb
+}
+
+// Read reads a PDU sent by the peer.
+func (c *conn) Read(b []byte) (int, error) {
+	select {
+	case p := <-c.in:
+		if len(p) > len(b) {
+			return 0, io.ErrShortBuffer
+		}
+		return copy(b, p), nil
+	case <-c.done:
+		return 0, io.EOF
+	}
+}
+
+// Write sends a PDU to the peer.
+func (c *conn) Write(b []byte) (int, error) {
+	p := append([]byte(nil), b...)
+	select {
+	case <-c.done:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+	select {
+	case c.peer.in <- p:
+		return len(b), nil
+	case <-c.done:
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// Close disconnects both ends.
+func (c *conn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *conn) Context() context.Context {
+	c.Lock()
+	defer c.Unlock()
+	return c.ctx
+}
+
+func (c *conn) SetContext(ctx context.Context) {
+	c.Lock()
+	defer c.Unlock()
+	c.ctx = ctx
+}
+
+func (c *conn) LocalAddr() ble.Addr  { return c.local }
+func (c *conn) RemoteAddr() ble.Addr { return c.remote }
+
+func (c *conn) RxMTU() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.rxMTU
+}
+
+func (c *conn) SetRxMTU(mtu int) {
+	c.Lock()
+	defer c.Unlock()
+	c.rxMTU = mtu
+}
+
+func (c *conn) TxMTU() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.txMTU
+}
+
+func (c *conn) SetTxMTU(mtu int) {
+	c.Lock()
+	defer c.Unlock()
+	c.txMTU = mtu
+}
+
+func (c *conn) Disconnected() <-chan struct{} { return c.done }
+
+// Security returns SecurityNone, since the links aren't encrypted.
+func (c *conn) Security() ble.Security { return ble.Security{Level: ble.SecurityNone} }
@@ -0,0 +1,372 @@
+// Package mock provides in-memory devices, which implement ble.Device and
+// talk to each other over a simulated radio, so that applications using
+// this package can be tested deterministically, without hardware.
+//
+// A peripheral serves its services with the GATT server of the linux
+// backend, and a central connects to it with the GATT client of the linux
+// backend, so the handlers, notifications, and errors behave as they do
+// over the air.
+//
+// A Recorder records the session of a client with a real device, which
+// Replay then plays back as a ble.Client, without the device.
+package mock
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/linux/adv"
+	"github.com/kirbo/ble/linux/att"
+	"github.com/kirbo/ble/linux/gatt"
+)
+
+// DefaultRSSI is the RSSI at which the advertisements of a device are
+// received, unless set by SetRSSI.
+const DefaultRSSI = -50
+
+// A Network is the radio shared by its devices. Each device receives the
+// advertisements of the others, and may connect to them.
+type Network struct {
+	mu       sync.Mutex
+	devices  map[string]*Device
+	changed  chan struct{}
+	interval time.Duration
+}
+
+// NewNetwork returns an empty Network. Advertisements are received as soon
+// as they start, and again every interval if duplicates are allowed.
+func NewNetwork(interval time.Duration) *Network {
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	return &Network{
+		devices:  make(map[string]*Device),
+		changed:  make(chan struct{}),
+		interval: interval,
+	}
+}
+
+// NewDevice adds a device with the address addr, such as
+// "11:22:33:44:55:66", and the GAP device name to the network.
+func (n *Network) NewDevice(addr, name string) (*Device, error) {
+	srv, err := gatt.NewServerWithName(name)
+	if err != nil {
+		return nil, err
+	}
+	d := &Device{
+		n:     n,
+		addr:  ble.NewAddr(addr),
+		srv:   srv,
+		rssi:  DefaultRSSI,
+		conns: make(map[*conn]bool),
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	k := key(d.addr)
+	if _, ok := n.devices[k]; ok {
+		return nil, fmt.Errorf("address %s already in use", addr)
+	}
+	n.devices[k] = d
+	return d, nil
+}
+
+func key(a ble.Addr) string {
+	return strings.ToLower(a.String())
+}
+
+// notify wakes the scanners and dialers up, after a change of the
+// advertisements. Must be called with n.mu held.
+func (n *Network) notify() {
+	close(n.changed)
+	n.changed = make(chan struct{})
+}
+
+// A Device is an in-memory ble.Device of a Network.
+type Device struct {
+	n    *Network
+	addr ble.Addr
+	srv  *gatt.Server
+
+	// Guarded by n.mu.
+	adv        ble.Advertisement
+	rssi       int
+	conns      map[*conn]bool
+	authorizer ble.Authorizer
+	auditHook  ble.AuditHook
+	limits     ble.ServerLimits
+}
+
+// Addr returns the address of the device.
+func (d *Device) Addr() ble.Addr {
+	return d.addr
+}
+
+// SetRSSI sets the RSSI at which the advertisements of the device are
+// received.
+func (d *Device) SetRSSI(rssi int) {
+	d.n.mu.Lock()
+	d.rssi = rssi
+	d.n.mu.Unlock()
+}
+
+// SetAuthorizer sets the Authorizer of the characteristics, which require
+// ble.PermAuthorize, for the later connections to the device.
+func (d *Device) SetAuthorizer(a ble.Authorizer) {
+	d.n.mu.Lock()
+	d.authorizer = a
+	d.n.mu.Unlock()
+}
+
+// SetAuditHook sets the AuditHook of the GATT server, for the later
+// connections to the device.
+func (d *Device) SetAuditHook(f ble.AuditHook) {
+	d.n.mu.Lock()
+	d.auditHook = f
+	d.n.mu.Unlock()
+}
+
+// SetServerLimits sets the ServerLimits of the GATT server, for the later
+// connections to the device.
+func (d *Device) SetServerLimits(l ble.ServerLimits) {
+	d.n.mu.Lock()
+	d.limits = l
+	d.n.mu.Unlock()
+}
+
+// AddService adds a service to database.
+func (d *Device) AddService(svc *ble.Service) error {
+	return d.srv.AddService(svc)
+}
+
+// RemoveAllServices removes all services that are currently in the database.
+func (d *Device) RemoveAllServices() error {
+	return d.srv.RemoveAllServices()
+}
+
+// SetServices set the specified service to the database.
+// It removes all currently added services, if any.
+func (d *Device) SetServices(svcs []*ble.Service) error {
+	return d.srv.SetServices(svcs)
+}
+
+// GATTDump returns the attribute table of the GATT server.
+func (d *Device) GATTDump() ([]ble.Attribute, error) {
+	return d.srv.GATTDump(), nil
+}
+
+// Stop disconnects the device, and removes it from the network.
+func (d *Device) Stop() error {
+	d.DisconnectAll(context.Background())
+	d.n.mu.Lock()
+	defer d.n.mu.Unlock()
+	d.adv = nil
+	delete(d.n.devices, key(d.addr))
+	d.n.notify()
+	return nil
+}
+
+// Advertise advertises a given Advertisement, with the address of the device.
+func (d *Device) Advertise(ctx context.Context, a ble.Advertisement) error {
+	return d.advertise(ctx, &relayed{Advertisement: a, addr: d.addr})
+}
+
+// AdvertiseNameAndServices advertises device name, and specified service UUIDs.
+func (d *Device) AdvertiseNameAndServices(ctx context.Context, name string, uuids ...ble.UUID) error {
+	fields := []adv.Field{adv.Flags(adv.FlagGeneralDiscoverable | adv.FlagLEOnly), adv.CompleteName(name)}
+	for _, u := range uuids {
+		fields = append(fields, adv.AllUUID(u))
+	}
+	return d.advertisePacket(ctx, fields...)
+}
+
+// AdvertiseMfgData avertises the given manufacturer data.
+func (d *Device) AdvertiseMfgData(ctx context.Context, id uint16, b []byte) error {
+	return d.advertisePacket(ctx, adv.ManufacturerData(id, b))
+}
+
+// AdvertiseServiceData16 advertises data associated with a 16bit service uuid
+func (d *Device) AdvertiseServiceData16(ctx context.Context, id uint16, b []byte) error {
+	return d.advertisePacket(ctx, adv.ServiceData16(id, b))
+}
+
+// AdvertiseIBeaconData advertise iBeacon with given manufacturer data.
+func (d *Device) AdvertiseIBeaconData(ctx context.Context, b []byte) error {
+	return d.advertisePacket(ctx, adv.IBeaconData(b))
+}
+
+// AdvertiseIBeacon advertises iBeacon with specified parameters.
+func (d *Device) AdvertiseIBeacon(ctx context.Context, u ble.UUID, major, minor uint16, pwr int8) error {
+	return d.advertisePacket(ctx, adv.IBeacon(u, major, minor, pwr))
+}
+
+func (d *Device) advertisePacket(ctx context.Context, fields ...adv.Field) error {
+	p, err := adv.NewExtendedPacket(fields...)
+	if err != nil {
+		return err
+	}
+	return d.advertise(ctx, &advertisement{p: p, addr: d.addr, connectable: true})
+}
+
+// advertise advertises a until ctx is done, or another advertisement
+// replaces it.
+func (d *Device) advertise(ctx context.Context, a ble.Advertisement) error {
+	d.n.mu.Lock()
+	d.adv = a
+	d.n.notify()
+	d.n.mu.Unlock()
+
+	<-ctx.Done()
+
+	d.n.mu.Lock()
+	if d.adv == a {
+		d.adv = nil
+		d.n.notify()
+	}
+	d.n.mu.Unlock()
+	return ctx.Err()
+}
+
+// received returns the advertisements which d receives, and the channel
+// which is closed when they change.
+func (d *Device) received() ([]ble.Advertisement, <-chan struct{}) {
+	d.n.mu.Lock()
+	defer d.n.mu.Unlock()
+	var advs []ble.Advertisement
+	for _, p := range d.n.devices {
+		if p == d || p.adv == nil {
+			continue
+		}
+		a := p.adv
+		switch a := a.(type) {
+		case *advertisement:
+			c := *a
+			c.rssi = p.rssi
+			advs = append(advs, &c)
+		case *relayed:
+			c := *a
+			c.rssi = p.rssi
+			advs = append(advs, &c)
+		}
+	}
+	return advs, d.n.changed
+}
+
+// Scan starts scanning. Duplicated advertisements will be filtered out if allowDup is set to false.
+func (d *Device) Scan(ctx context.Context, allowDup bool, h ble.AdvHandler) error {
+	seen := make(map[string]bool)
+	t := ble.ClockFromContext(ctx).NewTicker(d.n.interval)
+	defer t.Stop()
+	for {
+		advs, changed := d.received()
+		for _, a := range advs {
+			k := key(a.Addr())
+			if !allowDup && seen[k] {
+				continue
+			}
+			seen[k] = true
+			h(a)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-t.C():
+		}
+	}
+}
+
+// Dial connects to the device of the address a, once it advertises as
+// connectable, or until ctx is done.
+// The connection is timed by the Clock of ctx, see ble.WithClock.
+func (d *Device) Dial(ctx context.Context, a ble.Addr) (ble.Client, error) {
+	for {
+		d.n.mu.Lock()
+		p := d.n.devices[key(a)]
+		ok := p != nil && p != d && p.adv != nil && p.adv.Connectable()
+		changed := d.n.changed
+		d.n.mu.Unlock()
+		if ok {
+			return d.connect(ctx, p)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// connect connects d, as a central, to the peripheral p.
+func (d *Device) connect(ctx context.Context, p *Device) (ble.Client, error) {
+	cc, pc := newConnPair(d.addr, p.addr)
+	if t, ok := ble.ClientTimeoutFromContext(ctx); ok {
+		cc.SetContext(ble.WithClientTimeout(cc.Context(), t))
+	}
+	if p, ok := ble.RetryPolicyFromContext(ctx); ok {
+		cc.SetContext(ble.WithRetry(cc.Context(), p))
+	}
+	// The connection is timed by the clock of the dialer, on both sides.
+	clock := ble.ClockFromContext(ctx)
+	cc.SetContext(ble.WithClock(cc.Context(), clock))
+	pc.SetContext(ble.WithClock(pc.Context(), clock))
+
+	// Serve the peripheral as the linux backend does.
+	pc.SetContext(context.WithValue(pc.Context(), ble.ContextKeyCCC, make(map[uint16]uint16)))
+	pc.SetRxMTU(ble.MaxMTU)
+	p.srv.Lock()
+	as, err := att.NewServer(p.srv.DB(), pc)
+	p.srv.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	d.n.mu.Lock()
+	as.SetAuthorizer(p.authorizer)
+	as.SetAuditHook(p.auditHook)
+	as.SetLimits(p.limits)
+	d.n.mu.Unlock()
+	go as.Loop()
+
+	d.n.mu.Lock()
+	d.conns[cc] = true
+	p.conns[pc] = true
+	d.n.mu.Unlock()
+	go func() {
+		<-cc.Disconnected()
+		d.n.mu.Lock()
+		delete(d.conns, cc)
+		delete(p.conns, pc)
+		d.n.mu.Unlock()
+	}()
+	return gatt.NewClient(cc)
+}
+
+// DisconnectAll terminates every connection of the device.
+func (d *Device) DisconnectAll(ctx context.Context) error {
+	d.n.mu.Lock()
+	conns := make([]*conn, 0, len(d.conns))
+	for c := range d.conns {
+		conns = append(conns, c)
+	}
+	d.n.mu.Unlock()
+	for _, c := range conns {
+		c.Close()
+	}
+	return nil
+}
+
+// Reset terminates every connection, and stops advertising. Scanning goes
+// on until its context is done.
+func (d *Device) Reset(ctx context.Context) error {
+	d.DisconnectAll(ctx)
+	d.n.mu.Lock()
+	defer d.n.mu.Unlock()
+	if d.adv != nil {
+		d.adv = nil
+		d.n.notify()
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package mock_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+	"github.com/kirbo/ble/linux/att"
+	"github.com/kirbo/ble/mock"
+)
+
+func TestCentralAndPeripheral(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write([]byte("hello"))
+	}))
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		n.Write([]byte("tick"))
+		<-n.Context().Done()
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	found := make(chan ble.Advertisement, 1)
+	scanCtx, stopScan := context.WithCancel(ctx)
+	go f.Central.Scan(scanCtx, false, func(a ble.Advertisement) {
+		if a.LocalName() == gatttest.PeripheralName {
+			select {
+			case found <- a:
+			default:
+			}
+		}
+	})
+	var a ble.Advertisement
+	select {
+	case a = <-found:
+	case <-ctx.Done():
+		t.Fatal("advertisement not received")
+	}
+	stopScan()
+	if a.RSSI() != mock.DefaultRSSI || !a.Connectable() || len(a.Services()) != 1 || !a.Services()[0].Equal(gatttest.ServiceUUID) {
+		t.Fatalf("unexpected advertisement: rssi %d, connectable %v, services %v", a.RSSI(), a.Connectable(), a.Services())
+	}
+
+	cln, err := f.Central.Dial(ctx, a.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+	if _, err := cln.DiscoverProfile(true); err != nil {
+		t.Fatal(err)
+	}
+
+	rc := f.Characteristic(cln, gatttest.ReadCharUUID)
+	v, err := cln.ReadCharacteristic(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte("hello")) {
+		t.Fatalf("read %q, want %q", v, "hello")
+	}
+
+	nc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+	got := make(chan []byte, 1)
+	if err := cln.Subscribe(nc, false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-got:
+		if !bytes.Equal(b, []byte("tick")) {
+			t.Fatalf("notified %q, want %q", b, "tick")
+		}
+	case <-ctx.Done():
+		t.Fatal("notification not received")
+	}
+
+	if err := f.Peripheral.DisconnectAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-cln.Disconnected():
+	case <-ctx.Done():
+		t.Fatal("central not disconnected")
+	}
+}
+
+func TestMultipleCentrals(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The read handler tells the central which one it is.
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write([]byte(req.Conn().RemoteAddr().String()))
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	other, err := f.Network.NewDevice("66:55:44:33:22:12", gatttest.CentralName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Stop()
+	for _, c := range []*mock.Device{f.Central, other} {
+		cln, err := c.Dial(ctx, f.Peripheral.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cln.CancelConnection()
+		if _, err := cln.DiscoverProfile(true); err != nil {
+			t.Fatal(err)
+		}
+		v, err := cln.ReadCharacteristic(f.Characteristic(cln, gatttest.ReadCharUUID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != c.Addr().String() {
+			t.Errorf("central %s read %q", c.Addr(), v)
+		}
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	rc := svc.NewCharacteristic(gatttest.ReadCharUUID)
+	rc.SetValue([]byte("secret"))
+	rc.ReadPermission = ble.PermEncrypt
+	ac := svc.NewCharacteristic(gatttest.NotifyCharUUID)
+	ac.SetValue([]byte("hello"))
+	ac.HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {}))
+	ac.ReadPermission = ble.PermAuthorize
+	ac.WritePermission = ble.PermAuthorize
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	// The links of the network aren't encrypted. Reads of the notify
+	// characteristic are authorized, and its writes aren't.
+	var peer ble.Addr
+	f.Peripheral.SetAuthorizer(func(conn ble.Conn, ch *ble.Characteristic, write bool) bool {
+		peer = conn.RemoteAddr()
+		return !write
+	})
+	cln := f.Connect(ctx)
+	prof := cln.Profile()
+	if _, err := cln.ReadCharacteristic(prof.FindCharacteristic(rc)); !errors.Is(err, ble.ErrInsuffEnc) {
+		t.Errorf("ReadCharacteristic() error = %v, want ErrInsuffEnc", err)
+	}
+	a := prof.FindCharacteristic(ac)
+	if v, err := cln.ReadCharacteristic(a); err != nil || string(v) != "hello" {
+		t.Errorf("ReadCharacteristic() = %q, %v, want hello", v, err)
+	}
+	if peer == nil || peer.String() != f.Central.Addr().String() {
+		t.Errorf("authorized peer %v, want %v", peer, f.Central.Addr())
+	}
+	if err := cln.WriteCharacteristic(a, []byte("x"), false); !errors.Is(err, ble.ErrAuthorization) {
+		t.Errorf("WriteCharacteristic() error = %v, want ErrAuthorization", err)
+	}
+}
+
+func TestAuditHook(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	rc := svc.NewCharacteristic(gatttest.ReadCharUUID)
+	rc.SetValue([]byte("secret"))
+	rc.ReadPermission = ble.PermEncrypt
+	wc := svc.NewCharacteristic(gatttest.NotifyCharUUID)
+	wc.HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	ops := make(chan ble.ATTOp, 64)
+	f.Peripheral.SetAuditHook(func(op ble.ATTOp) { ops <- op })
+	cln := f.Connect(ctx)
+	prof := cln.Profile()
+	r, w := prof.FindCharacteristic(rc), prof.FindCharacteristic(wc)
+	cln.ReadCharacteristic(r)
+	if err := cln.WriteCharacteristic(w, []byte("x"), true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ble.ATTOp{
+		{Opcode: att.ReadRequestCode, Handle: r.ValueHandle, Err: ble.ErrInsuffEnc},
+		{Opcode: att.WriteCommandCode, Handle: w.ValueHandle, Err: ble.ErrSuccess},
+	}
+	for len(want) > 0 {
+		var op ble.ATTOp
+		select {
+		case op = <-ops:
+		case <-ctx.Done():
+			t.Fatalf("%d operations not audited", len(want))
+		}
+		if op.Opcode != want[0].Opcode {
+			continue // Discovery.
+		}
+		if op.Addr.String() != f.Central.Addr().String() || op.Handle != want[0].Handle || op.Err != want[0].Err {
+			t.Errorf("audited %+v, want %+v from %s", op, want[0], f.Central.Addr())
+		}
+		want = want[1:]
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	notify := ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) { <-n.Context().Done() })
+	svc.NewCharacteristic(gatttest.ReadCharUUID).HandleNotify(notify)
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleNotify(notify)
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+	f.Peripheral.SetServerLimits(ble.ServerLimits{OpsPerSecond: 40, Subscriptions: 1})
+
+	// The tokens of the rate aren't refilled, as the clock stands still.
+	cln := f.Connect(ble.WithClock(ctx, ble.NewFakeClock(time.Unix(0, 0))))
+	h := func([]byte) {}
+	if err := cln.Subscribe(f.Characteristic(cln, gatttest.ReadCharUUID), false, h); err != nil {
+		t.Fatal(err)
+	}
+	nc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+	if err := cln.Subscribe(nc, false, h); !errors.Is(err, ble.ErrInsuffResources) {
+		t.Errorf("Subscribe() error = %v, want ErrInsuffResources", err)
+	}
+
+	go func() {
+		for i := 0; i < 40; i++ {
+			if _, err := cln.ReadDescriptor(nc.CCCD); err != nil {
+				return
+			}
+		}
+	}()
+	select {
+	case <-cln.Disconnected():
+	case <-ctx.Done():
+		t.Fatal("client exceeding the rate not disconnected")
+	}
+}
+
+func TestGATTDumpOfLoadedServices(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := gatttest.New(ctx, t, 10*time.Millisecond)
+	defer f.Stop()
+
+	svcs, err := ble.LoadServices(strings.NewReader(`[{
+		"uuid": "5e0a0001-0000-1000-8000-00805f9b34fb",
+		"characteristics": [{"uuid": "5e0a0002-0000-1000-8000-00805f9b34fb", "properties": ["read", "write", "notify"], "value": "01"}]
+	}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Peripheral.SetServices(svcs); err != nil {
+		t.Fatal(err)
+	}
+	as, err := f.Peripheral.GATTDump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The service, the declaration, value and CCCD of the characteristic
+	// follow the GAP and GATT services.
+	last := as[len(as)-4:]
+	if !last[0].Type.Equal(ble.PrimaryServiceUUID) || !ble.UUID(last[0].Value).Equal(gatttest.ServiceUUID) || last[0].EndHandle != 0xFFFF {
+		t.Errorf("service attribute %v", last[0])
+	}
+	if !last[1].Type.Equal(ble.CharacteristicUUID) || last[1].EndHandle != last[3].Handle {
+		t.Errorf("declaration attribute %v", last[1])
+	}
+	if !last[2].Type.Equal(gatttest.ReadCharUUID) || !last[2].Dynamic || last[2].Value != nil {
+		t.Errorf("value attribute %v", last[2])
+	}
+	if !last[3].Type.Equal(ble.ClientCharacteristicConfigUUID) {
+		t.Errorf("CCCD attribute %v", last[3])
+	}
+
+	cln := f.Connect(ctx)
+	ch := f.Characteristic(cln, gatttest.ReadCharUUID)
+	got := make(chan []byte, 1)
+	if err := cln.Subscribe(ch, false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	// The notify handler is served in the background, so the writes are
+	// repeated, until it's notified of one.
+	for notified := false; !notified; {
+		if err := cln.WriteCharacteristic(ch, []byte{0x02}, false); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case b := <-got:
+			if !bytes.Equal(b, []byte{0x02}) {
+				t.Fatalf("notified % X, want 02", b)
+			}
+			notified = true
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("notification not received")
+		}
+	}
+	v, err := cln.ReadCharacteristic(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, []byte{0x02}) {
+		t.Fatalf("read % X, want 02", v)
+	}
+}
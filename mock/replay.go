@@ -0,0 +1,341 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// ErrNotRecorded is the error of the operations, which a replayed session
+// has no record of.
+var ErrNotRecorded = errors.New("not recorded")
+
+// A ReplayClient is a ble.Client, which plays a recorded Session back, in
+// memory, as the peripheral did:
+//
+//   - Discovery finds the profile of the session.
+//   - Each read of an attribute returns its next recorded read, and once they
+//     are all returned, the last one again.
+//   - Each write of an attribute returns the error of its next recorded write,
+//     if any, and is kept for Writes.
+//   - A subscription returns its recorded error, and then delivers the
+//     notifications recorded after it, as far apart as they were received.
+//
+// It has no Conn.
+type ReplayClient struct {
+	s    *Session
+	prof *ble.Profile
+	evts map[evtKey][]*Event
+
+	mu         sync.Mutex
+	discovered bool
+	next       map[evtKey]int
+	writes     []Event
+	subs       map[uint16]chan struct{} // Closed to end the delivery of the notifications.
+	done       chan struct{}
+}
+
+type evtKey struct {
+	op Op
+	h  uint16
+}
+
+// Replay returns a ReplayClient of the session s.
+func Replay(s *Session) (*ReplayClient, error) {
+	p, err := s.profile()
+	if err != nil {
+		return nil, err
+	}
+	r := &ReplayClient{
+		s:    s,
+		prof: p,
+		evts: map[evtKey][]*Event{},
+		next: map[evtKey]int{},
+		subs: map[uint16]chan struct{}{},
+		done: make(chan struct{}),
+	}
+	for i := range s.Events {
+		e := &s.Events[i]
+		k := evtKey{e.Op, e.Handle}
+		r.evts[k] = append(r.evts[k], e)
+	}
+	return r, nil
+}
+
+// Writes returns the writes of the characteristics and the descriptors so
+// far, for the tests to check.
+func (r *ReplayClient) Writes() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.writes...)
+}
+
+// event returns the next recorded event of op on the handle h, or the last
+// one again, once they are all returned.
+func (r *ReplayClient) event(op Op, h uint16) *Event {
+	k := evtKey{op, h}
+	es := r.evts[k]
+	if len(es) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.next[k]
+	if i < len(es)-1 {
+		r.next[k] = i + 1
+	}
+	return es[i]
+}
+
+func (r *ReplayClient) read(op Op, h uint16) ([]byte, error) {
+	e := r.event(op, h)
+	if e == nil {
+		return nil, ErrNotRecorded
+	}
+	return append([]byte(nil), e.Value...), e.err()
+}
+
+func (r *ReplayClient) write(op Op, h uint16, v []byte, noRsp bool) error {
+	r.mu.Lock()
+	r.writes = append(r.writes, Event{Op: op, Handle: h, Value: append([]byte(nil), v...), NoRsp: noRsp})
+	r.mu.Unlock()
+	if e := r.event(op, h); e != nil {
+		return e.err()
+	}
+	return nil
+}
+
+// Addr returns the address of the recorded peripheral.
+func (r *ReplayClient) Addr() ble.Addr { return ble.NewAddr(r.s.Addr) }
+
+// Name returns the name of the recorded peripheral.
+func (r *ReplayClient) Name() string { return r.s.Name }
+
+// Profile returns the profile, once discovered.
+func (r *ReplayClient) Profile() *ble.Profile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.discovered {
+		return nil
+	}
+	return r.prof
+}
+
+// DiscoverProfile discovers the recorded profile.
+func (r *ReplayClient) DiscoverProfile(force bool) (*ble.Profile, error) {
+	r.mu.Lock()
+	r.discovered = true
+	r.mu.Unlock()
+	return r.prof, nil
+}
+
+// DiscoverServices discovers the recorded services.
+func (r *ReplayClient) DiscoverServices(filter []ble.UUID) ([]*ble.Service, error) {
+	r.DiscoverProfile(false)
+	var ss []*ble.Service
+	for _, s := range r.prof.Services {
+		if filter == nil || ble.Contains(filter, s.UUID) {
+			ss = append(ss, s)
+		}
+	}
+	return ss, nil
+}
+
+// DiscoverIncludedServices finds no included services, which aren't
+// recorded.
+func (r *ReplayClient) DiscoverIncludedServices(filter []ble.UUID, s *ble.Service) ([]*ble.Service, error) {
+	return nil, nil
+}
+
+// DiscoverCharacteristics discovers the recorded characteristics of s.
+func (r *ReplayClient) DiscoverCharacteristics(filter []ble.UUID, s *ble.Service) ([]*ble.Characteristic, error) {
+	var cs []*ble.Characteristic
+	for _, c := range s.Characteristics {
+		if filter == nil || ble.Contains(filter, c.UUID) {
+			cs = append(cs, c)
+		}
+	}
+	return cs, nil
+}
+
+// DiscoverDescriptors discovers the recorded descriptors of c.
+func (r *ReplayClient) DiscoverDescriptors(filter []ble.UUID, c *ble.Characteristic) ([]*ble.Descriptor, error) {
+	var ds []*ble.Descriptor
+	for _, d := range c.Descriptors {
+		if filter == nil || ble.Contains(filter, d.UUID) {
+			ds = append(ds, d)
+		}
+	}
+	return ds, nil
+}
+
+// Characteristics returns the discovered characteristics with the UUID u.
+func (r *ReplayClient) Characteristics(u ble.UUID) []*ble.Characteristic {
+	if p := r.Profile(); p != nil {
+		return p.FindCharacteristics(u)
+	}
+	return nil
+}
+
+// ReadCharacteristic returns the next recorded read of c.
+func (r *ReplayClient) ReadCharacteristic(c *ble.Characteristic) ([]byte, error) {
+	return r.read(OpRead, c.ValueHandle)
+}
+
+// ReadCharacteristicByUUID returns the next recorded read of the
+// characteristic chr, within the service svc, or any if svc is nil.
+func (r *ReplayClient) ReadCharacteristicByUUID(svc, chr ble.UUID) ([]byte, error) {
+	c := findChar(r.prof, svc, chr)
+	if c == nil {
+		return nil, ble.ErrAttrNotFound
+	}
+	return r.read(OpRead, c.ValueHandle)
+}
+
+// ReadLongCharacteristic returns the next recorded read of c.
+func (r *ReplayClient) ReadLongCharacteristic(c *ble.Characteristic) ([]byte, error) {
+	return r.read(OpRead, c.ValueHandle)
+}
+
+// WriteCharacteristic returns the error of the next recorded write of c.
+func (r *ReplayClient) WriteCharacteristic(c *ble.Characteristic, v []byte, noRsp bool) error {
+	return r.write(OpWrite, c.ValueHandle, v, noRsp)
+}
+
+// WriteLongCharacteristic returns the error of the next recorded write of c.
+func (r *ReplayClient) WriteLongCharacteristic(c *ble.Characteristic, v []byte, reliable bool) error {
+	return r.write(OpWrite, c.ValueHandle, v, false)
+}
+
+// Flush returns at once, as the writes are replayed as they are made.
+func (r *ReplayClient) Flush(ctx context.Context) error { return nil }
+
+// RawATT isn't recorded.
+func (r *ReplayClient) RawATT(ctx context.Context, pdu []byte) ([]byte, error) {
+	return nil, ErrNotRecorded
+}
+
+// ReadDescriptor returns the next recorded read of d.
+func (r *ReplayClient) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
+	return r.read(OpReadDesc, d.Handle)
+}
+
+// WriteDescriptor returns the error of the next recorded write of d.
+func (r *ReplayClient) WriteDescriptor(d *ble.Descriptor, v []byte) error {
+	return r.write(OpWriteDesc, d.Handle, v, false)
+}
+
+// ReadRSSI returns the recorded RSSI.
+func (r *ReplayClient) ReadRSSI() int { return r.s.RSSI }
+
+// Security returns the zero Security, which isn't recorded.
+func (r *ReplayClient) Security() ble.Security { return ble.Security{} }
+
+// Encrypted returns false, as the security isn't recorded.
+func (r *ReplayClient) Encrypted() bool { return false }
+
+// SecurityLevel returns SecurityUnknown, as the security isn't recorded.
+func (r *ReplayClient) SecurityLevel() ble.SecurityLevel { return ble.SecurityUnknown }
+
+// RemoteInfo returns the zero RemoteInfo, which isn't recorded.
+func (r *ReplayClient) RemoteInfo() ble.RemoteInfo { return ble.RemoteInfo{} }
+
+// ExchangeMTU returns the recorded ATT_MTU, or the default one.
+func (r *ReplayClient) ExchangeMTU(rxMTU int) (int, error) {
+	if r.s.MTU != 0 {
+		return r.s.MTU, nil
+	}
+	return ble.DefaultMTU, nil
+}
+
+// Subscribe returns the error of the next recorded subscription to c, and
+// then delivers the notifications recorded after it to h.
+func (r *ReplayClient) Subscribe(c *ble.Characteristic, ind bool, h ble.NotificationHandler) error {
+	vh := c.ValueHandle
+	var at time.Duration
+	if e := r.event(OpSubscribe, vh); e != nil {
+		if err := e.err(); err != nil {
+			return err
+		}
+		at = e.At
+	}
+	var ns []*Event
+	for _, e := range r.evts[evtKey{OpNotify, vh}] {
+		if e.At >= at {
+			ns = append(ns, e)
+		}
+	}
+	if len(ns) > 0 && at == 0 {
+		at = ns[0].At
+	}
+
+	stop := make(chan struct{})
+	r.mu.Lock()
+	if old, ok := r.subs[vh]; ok {
+		close(old)
+	}
+	r.subs[vh] = stop
+	r.mu.Unlock()
+
+	go func() {
+		start := time.Now()
+		for _, e := range ns {
+			t := time.NewTimer(e.At - at - time.Since(start))
+			select {
+			case <-t.C:
+				h(append([]byte(nil), e.Value...))
+			case <-stop:
+				t.Stop()
+				return
+			case <-r.done:
+				t.Stop()
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Unsubscribe ends the delivery of the notifications of c.
+func (r *ReplayClient) Unsubscribe(c *ble.Characteristic, ind bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stop, ok := r.subs[c.ValueHandle]; ok {
+		close(stop)
+		delete(r.subs, c.ValueHandle)
+	}
+	return nil
+}
+
+// ClearSubscriptions ends the delivery of all the notifications.
+func (r *ReplayClient) ClearSubscriptions() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for h, stop := range r.subs {
+		close(stop)
+		delete(r.subs, h)
+	}
+	return nil
+}
+
+// CancelConnection ends the session.
+func (r *ReplayClient) CancelConnection() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	return nil
+}
+
+// Disconnected returns a receiving channel, which is closed when the session
+// ends.
+func (r *ReplayClient) Disconnected() <-chan struct{} { return r.done }
+
+// Conn returns nil, as a replayed session has no connection.
+func (r *ReplayClient) Conn() ble.Conn { return nil }
@@ -0,0 +1,143 @@
+package mock_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+	"github.com/kirbo/ble/mock"
+)
+
+var _ ble.Client = (*mock.ReplayClient)(nil)
+
+// record records a session against a peripheral, which counts its reads,
+// rejects its writes, and notifies twice, 50ms apart.
+func record(t *testing.T, ctx context.Context) *mock.Session {
+	reads := 0
+	svc := ble.NewService(gatttest.ServiceUUID)
+	rc := svc.NewCharacteristic(gatttest.ReadCharUUID)
+	rc.HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		reads++
+		rsp.Write([]byte{byte(reads)})
+	}))
+	rc.HandleWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.SetStatus(ble.ErrWriteNotPerm)
+	}))
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		n.Write([]byte("tick"))
+		time.Sleep(50 * time.Millisecond)
+		n.Write([]byte("tock"))
+		<-n.Context().Done()
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	r := mock.Record(f.Dial(ctx))
+	prof, err := r.DiscoverProfile(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd := prof.FindCharacteristic(ble.NewCharacteristic(gatttest.ReadCharUUID))
+	for i := 0; i < 2; i++ {
+		if _, err := r.ReadCharacteristic(rd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.WriteCharacteristic(rd, []byte("x"), false); err == nil {
+		t.Fatal("write not rejected")
+	}
+	got := make(chan []byte, 2)
+	if err := r.Subscribe(prof.FindCharacteristic(ble.NewCharacteristic(gatttest.NotifyCharUUID)), false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-got:
+		case <-ctx.Done():
+			t.Fatal("notification not received")
+		}
+	}
+	return r.Session()
+}
+
+func TestReplay(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Replayed from JSON, as the sessions kept as test data are.
+	b, err := json.Marshal(record(t, ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s mock.Session
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	cln, err := mock.Replay(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+
+	if cln.Addr().String() != gatttest.PeripheralAddr {
+		t.Errorf("addr = %s", cln.Addr())
+	}
+	if cln.Profile() != nil {
+		t.Error("profile before discovery")
+	}
+	svcs, err := cln.DiscoverServices([]ble.UUID{gatttest.ServiceUUID})
+	if err != nil || len(svcs) != 1 {
+		t.Fatalf("services = %v, %v", svcs, err)
+	}
+	cs, err := cln.DiscoverCharacteristics(nil, svcs[0])
+	if err != nil || len(cs) != 2 {
+		t.Fatalf("characteristics = %v, %v", cs, err)
+	}
+	rd, nc := cs[0], cs[1]
+	if !rd.UUID.Equal(gatttest.ReadCharUUID) || !nc.UUID.Equal(gatttest.NotifyCharUUID) || nc.CCCD == nil {
+		t.Fatalf("characteristics %s, %s, CCCD %v", rd.UUID, nc.UUID, nc.CCCD)
+	}
+
+	// The reads in order, and then the last one again.
+	for _, want := range []byte{1, 2, 2} {
+		v, err := cln.ReadCharacteristic(rd)
+		if err != nil || !bytes.Equal(v, []byte{want}) {
+			t.Fatalf("read %v, %v, want %v", v, err, want)
+		}
+	}
+	err = cln.WriteCharacteristic(rd, []byte("y"), false)
+	if !errors.Is(err, ble.ErrWriteNotPerm) {
+		t.Errorf("write error = %v, want %v", err, ble.ErrWriteNotPerm)
+	}
+	if w := cln.Writes(); len(w) != 1 || w[0].Op != mock.OpWrite || !bytes.Equal(w[0].Value, []byte("y")) {
+		t.Errorf("writes = %+v", w)
+	}
+
+	got := make(chan []byte, 2)
+	start := time.Now()
+	if err := cln.Subscribe(nc, false, func(b []byte) { got <- b }); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"tick", "tock"} {
+		select {
+		case b := <-got:
+			if string(b) != want {
+				t.Fatalf("notified %q, want %q", b, want)
+			}
+		case <-ctx.Done():
+			t.Fatal("notification not replayed")
+		}
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Errorf("notifications replayed %v apart, want about 50ms", d)
+	}
+
+	if _, err := cln.ReadDescriptor(nc.CCCD); err != mock.ErrNotRecorded {
+		t.Errorf("unrecorded read error = %v, want %v", err, mock.ErrNotRecorded)
+	}
+}
@@ -0,0 +1,306 @@
+package mock
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// An Op is the operation of an Event of a Session.
+type Op string
+
+// Operations of the events.
+const (
+	OpRead       Op = "read"            // A read of a characteristic value.
+	OpWrite      Op = "write"           // A write of a characteristic value.
+	OpReadDesc   Op = "readDescriptor"  // A read of a descriptor.
+	OpWriteDesc  Op = "writeDescriptor" // A write of a descriptor.
+	OpSubscribe  Op = "subscribe"       // A subscription to a characteristic value.
+	OpNotify     Op = "notify"          // A notification, or indication, received.
+	OpDisconnect Op = "disconnect"      // The end of the connection.
+)
+
+// An Event is an operation of a recorded session, on the attribute of the
+// handle.
+type Event struct {
+	Op     Op            `json:"op"`
+	Handle uint16        `json:"handle"`
+	Value  []byte        `json:"value,omitempty"`
+	NoRsp  bool          `json:"noRsp,omitempty"` // Of the writes without response.
+	Ind    bool          `json:"ind,omitempty"`   // Of the subscriptions to indications.
+	At     time.Duration `json:"at"`              // Since the start of the session.
+
+	// The ATT error code, or else the message, of the operations which
+	// failed.
+	ErrCode ble.ATTError `json:"errCode,omitempty"`
+	Err     string       `json:"err,omitempty"`
+}
+
+func (e *Event) setErr(err error) {
+	var ae *ble.ErrATT
+	var code ble.ATTError
+	switch {
+	case err == nil:
+	case errors.As(err, &ae):
+		e.ErrCode = ae.ErrCode
+	case errors.As(err, &code):
+		e.ErrCode = code
+	default:
+		e.Err = err.Error()
+	}
+}
+
+func (e *Event) err() error {
+	switch {
+	case e.ErrCode != 0:
+		return &ble.ErrATT{Handle: e.Handle, ErrCode: e.ErrCode}
+	case e.Err != "":
+		return errors.New(e.Err)
+	}
+	return nil
+}
+
+// A Session is a recorded session of a GATT client: the profile it
+// discovered, and the reads, the writes and the notifications, in order. It
+// marshals to JSON, so that the sessions recorded against the devices can be
+// kept as test data, and replayed by Replay.
+type Session struct {
+	Addr     string           `json:"addr"`
+	Name     string           `json:"name,omitempty"`
+	RSSI     int              `json:"rssi,omitempty"`
+	MTU      int              `json:"mtu,omitempty"`
+	Services []SessionService `json:"services"`
+	Events   []Event          `json:"events"`
+}
+
+// A SessionService is a service discovered in a Session.
+type SessionService struct {
+	UUID            string        `json:"uuid"`
+	Handle          uint16        `json:"handle"`
+	EndHandle       uint16        `json:"endHandle"`
+	Characteristics []SessionChar `json:"characteristics,omitempty"`
+}
+
+// A SessionChar is a characteristic discovered in a Session.
+type SessionChar struct {
+	UUID        string        `json:"uuid"`
+	Property    ble.Property  `json:"property"`
+	Handle      uint16        `json:"handle"`
+	ValueHandle uint16        `json:"valueHandle"`
+	EndHandle   uint16        `json:"endHandle"`
+	Descriptors []SessionDesc `json:"descriptors,omitempty"`
+}
+
+// A SessionDesc is a descriptor discovered in a Session.
+type SessionDesc struct {
+	UUID   string `json:"uuid"`
+	Handle uint16 `json:"handle"`
+}
+
+// profile returns a new ble.Profile of the services of the session.
+func (s *Session) profile() (*ble.Profile, error) {
+	p := &ble.Profile{}
+	for _, ss := range s.Services {
+		u, err := ble.Parse(ss.UUID)
+		if err != nil {
+			return nil, err
+		}
+		svc := &ble.Service{UUID: u, Handle: ss.Handle, EndHandle: ss.EndHandle}
+		for _, sc := range ss.Characteristics {
+			if u, err = ble.Parse(sc.UUID); err != nil {
+				return nil, err
+			}
+			c := &ble.Characteristic{
+				UUID:        u,
+				Property:    sc.Property,
+				Handle:      sc.Handle,
+				ValueHandle: sc.ValueHandle,
+				EndHandle:   sc.EndHandle,
+			}
+			for _, sd := range sc.Descriptors {
+				if u, err = ble.Parse(sd.UUID); err != nil {
+					return nil, err
+				}
+				d := &ble.Descriptor{UUID: u, Handle: sd.Handle}
+				c.Descriptors = append(c.Descriptors, d)
+				if u.Equal(ble.ClientCharacteristicConfigUUID) {
+					c.CCCD = d
+				}
+			}
+			svc.Characteristics = append(svc.Characteristics, c)
+		}
+		p.Services = append(p.Services, svc)
+	}
+	return p, nil
+}
+
+func sessionServices(p *ble.Profile) []SessionService {
+	var ss []SessionService
+	if p == nil {
+		return ss
+	}
+	for _, s := range p.Services {
+		svc := SessionService{UUID: s.UUID.String(), Handle: s.Handle, EndHandle: s.EndHandle}
+		for _, c := range s.Characteristics {
+			sc := SessionChar{
+				UUID:        c.UUID.String(),
+				Property:    c.Property,
+				Handle:      c.Handle,
+				ValueHandle: c.ValueHandle,
+				EndHandle:   c.EndHandle,
+			}
+			for _, d := range c.Descriptors {
+				sc.Descriptors = append(sc.Descriptors, SessionDesc{UUID: d.UUID.String(), Handle: d.Handle})
+			}
+			svc.Characteristics = append(svc.Characteristics, sc)
+		}
+		ss = append(ss, svc)
+	}
+	return ss
+}
+
+// A Recorder is a ble.Client, which records the session of the client it
+// wraps.
+type Recorder struct {
+	ble.Client
+
+	start time.Time
+	mu    sync.Mutex
+	evts  []Event
+	mtu   int
+}
+
+// Record returns a Recorder of the client cln, the session of which starts
+// now.
+func Record(cln ble.Client) *Recorder {
+	return &Recorder{Client: cln, start: time.Now()}
+}
+
+// Session returns the session recorded so far, with the profile discovered
+// so far.
+func (r *Recorder) Session() *Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Session{
+		Addr:     r.Addr().String(),
+		Name:     r.Name(),
+		RSSI:     r.Client.ReadRSSI(),
+		MTU:      r.mtu,
+		Services: sessionServices(r.Profile()),
+		Events:   append([]Event(nil), r.evts...),
+	}
+}
+
+// add records the event e, of the error err, and returns its index.
+func (r *Recorder) add(e Event, err error) int {
+	e.setErr(err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.At = time.Since(r.start)
+	r.evts = append(r.evts, e)
+	return len(r.evts) - 1
+}
+
+// ReadCharacteristic reads, and records, a characteristic value.
+func (r *Recorder) ReadCharacteristic(c *ble.Characteristic) ([]byte, error) {
+	b, err := r.Client.ReadCharacteristic(c)
+	r.add(Event{Op: OpRead, Handle: c.ValueHandle, Value: b}, err)
+	return b, err
+}
+
+// ReadLongCharacteristic reads, and records, a characteristic value.
+func (r *Recorder) ReadLongCharacteristic(c *ble.Characteristic) ([]byte, error) {
+	b, err := r.Client.ReadLongCharacteristic(c)
+	r.add(Event{Op: OpRead, Handle: c.ValueHandle, Value: b}, err)
+	return b, err
+}
+
+// ReadCharacteristicByUUID reads a characteristic value, and records it,
+// if the characteristic has been discovered.
+func (r *Recorder) ReadCharacteristicByUUID(svc, chr ble.UUID) ([]byte, error) {
+	b, err := r.Client.ReadCharacteristicByUUID(svc, chr)
+	if c := findChar(r.Profile(), svc, chr); c != nil {
+		r.add(Event{Op: OpRead, Handle: c.ValueHandle, Value: b}, err)
+	}
+	return b, err
+}
+
+// WriteCharacteristic writes, and records, a characteristic value.
+func (r *Recorder) WriteCharacteristic(c *ble.Characteristic, v []byte, noRsp bool) error {
+	err := r.Client.WriteCharacteristic(c, v, noRsp)
+	r.add(Event{Op: OpWrite, Handle: c.ValueHandle, Value: v, NoRsp: noRsp}, err)
+	return err
+}
+
+// WriteLongCharacteristic writes, and records, a characteristic value.
+func (r *Recorder) WriteLongCharacteristic(c *ble.Characteristic, v []byte, reliable bool) error {
+	err := r.Client.WriteLongCharacteristic(c, v, reliable)
+	r.add(Event{Op: OpWrite, Handle: c.ValueHandle, Value: v}, err)
+	return err
+}
+
+// ReadDescriptor reads, and records, a descriptor.
+func (r *Recorder) ReadDescriptor(d *ble.Descriptor) ([]byte, error) {
+	b, err := r.Client.ReadDescriptor(d)
+	r.add(Event{Op: OpReadDesc, Handle: d.Handle, Value: b}, err)
+	return b, err
+}
+
+// WriteDescriptor writes, and records, a descriptor.
+func (r *Recorder) WriteDescriptor(d *ble.Descriptor, v []byte) error {
+	err := r.Client.WriteDescriptor(d, v)
+	r.add(Event{Op: OpWriteDesc, Handle: d.Handle, Value: v}, err)
+	return err
+}
+
+// ExchangeMTU exchanges, and records, the ATT_MTU.
+func (r *Recorder) ExchangeMTU(rxMTU int) (int, error) {
+	n, err := r.Client.ExchangeMTU(rxMTU)
+	if err == nil {
+		r.mu.Lock()
+		r.mtu = n
+		r.mu.Unlock()
+	}
+	return n, err
+}
+
+// Subscribe subscribes to a characteristic value, and records the
+// subscription, and the values received.
+func (r *Recorder) Subscribe(c *ble.Characteristic, ind bool, h ble.NotificationHandler) error {
+	vh := c.ValueHandle
+	// The subscription is recorded ahead of its request, as the
+	// notifications received before its response are delivered first.
+	i := r.add(Event{Op: OpSubscribe, Handle: vh, Ind: ind}, nil)
+	err := r.Client.Subscribe(c, ind, func(b []byte) {
+		r.add(Event{Op: OpNotify, Handle: vh, Value: append([]byte(nil), b...)}, nil)
+		h(b)
+	})
+	r.mu.Lock()
+	r.evts[i].setErr(err)
+	r.mu.Unlock()
+	return err
+}
+
+// findChar returns the first characteristic of p with the UUID chr, within
+// the first service with the UUID svc, or any if svc is nil.
+func findChar(p *ble.Profile, svc, chr ble.UUID) *ble.Characteristic {
+	if p == nil {
+		return nil
+	}
+	for _, s := range p.Services {
+		if svc != nil && !s.UUID.Equal(svc) {
+			continue
+		}
+		for _, c := range s.Characteristics {
+			if c.UUID.Equal(chr) {
+				return c
+			}
+		}
+		if svc != nil {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,325 @@
+package noblews
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/kirbo/ble"
+)
+
+// actDisconnected is queued, once the connection of a peripheral is lost,
+// rather than sent by the client.
+const actDisconnected = "\x00disconnected"
+
+// errNotFound is the error of the actions on the attributes not discovered.
+var errNotFound = errors.New("not found")
+
+// properties are the names of the properties of the characteristics, as
+// noble has them, by bit.
+var properties = []struct {
+	p    ble.Property
+	name string
+}{
+	{ble.CharBroadcast, "broadcast"},
+	{ble.CharRead, "read"},
+	{ble.CharWriteNR, "writeWithoutResponse"},
+	{ble.CharWrite, "write"},
+	{ble.CharNotify, "notify"},
+	{ble.CharIndicate, "indicate"},
+	{ble.CharSignedWrite, "authenticatedSignedWrites"},
+	{ble.CharExtended, "extendedProperties"},
+}
+
+func (p *peripheral) handle(s *session, a action) {
+	e := event{"peripheralUuid": p.uuid}
+	if a.Action == "connect" {
+		p.connect(s)
+		return
+	}
+	if a.Action == actDisconnected {
+		p.cln, p.svcs = nil, nil
+		e["type"] = "disconnect"
+		s.send(e)
+		return
+	}
+	if p.cln == nil {
+		return
+	}
+	switch a.Action {
+	case "disconnect":
+		// Told by actDisconnected, once the connection ends.
+		p.cln.CancelConnection()
+
+	case "updateRssi":
+		e["type"] = "rssiUpdate"
+		e["rssi"] = p.cln.ReadRSSI()
+		s.send(e)
+
+	case "discoverServices":
+		e["type"] = "servicesDiscover"
+		svcs, err := p.cln.DiscoverServices(parseUUIDs(a.UUIDs))
+		if err == nil {
+			p.svcs = svcs
+		}
+		e["serviceUuids"] = uuidStrings(serviceUUIDs(svcs))
+		s.sendErr(e, err)
+
+	case "discoverIncludedServices":
+		e["type"] = "includedServicesDiscover"
+		e["serviceUuid"] = a.ServiceUUID
+		var incs []*ble.Service
+		svc, err := p.service(a.ServiceUUID)
+		if err == nil {
+			incs, err = p.cln.DiscoverIncludedServices(parseUUIDs(a.ServiceUUIDs), svc)
+		}
+		e["includedServiceUuids"] = uuidStrings(serviceUUIDs(incs))
+		s.sendErr(e, err)
+
+	case "discoverCharacteristics":
+		e["type"] = "characteristicsDiscover"
+		e["serviceUuid"] = a.ServiceUUID
+		var cs []*ble.Characteristic
+		svc, err := p.service(a.ServiceUUID)
+		if err == nil {
+			cs, err = p.cln.DiscoverCharacteristics(parseUUIDs(a.CharacteristicUUIDs), svc)
+		}
+		chars := []event{}
+		for _, c := range cs {
+			props := []string{}
+			for _, pr := range properties {
+				if c.Property&pr.p != 0 {
+					props = append(props, pr.name)
+				}
+			}
+			chars = append(chars, event{"uuid": c.UUID.String(), "properties": props})
+		}
+		e["characteristics"] = chars
+		s.sendErr(e, err)
+
+	case "read":
+		p.charEvent(e, a, "read")
+		e["isNotification"] = false
+		var v []byte
+		c, err := p.char(a.ServiceUUID, a.CharacteristicUUID)
+		if err == nil {
+			v, err = p.cln.ReadLongCharacteristic(c)
+		}
+		e["data"] = hex.EncodeToString(v)
+		s.sendErr(e, err)
+
+	case "write":
+		p.charEvent(e, a, "write")
+		c, err := p.char(a.ServiceUUID, a.CharacteristicUUID)
+		var v []byte
+		if err == nil {
+			v, err = hex.DecodeString(a.Data)
+		}
+		if err == nil {
+			err = p.cln.WriteCharacteristic(c, v, a.WithoutResponse)
+		}
+		s.sendErr(e, err)
+
+	case "notify":
+		p.charEvent(e, a, "notify")
+		e["state"] = a.Notify
+		c, err := p.char(a.ServiceUUID, a.CharacteristicUUID)
+		if err == nil && c.CCCD == nil {
+			// Noble doesn't discover the descriptors first.
+			_, err = p.cln.DiscoverDescriptors(nil, c)
+		}
+		if err == nil {
+			ind := c.Property&ble.CharNotify == 0
+			if a.Notify {
+				ne := event{}
+				p.charEvent(ne, a, "read")
+				err = p.cln.Subscribe(c, ind, func(b []byte) {
+					n := event{"isNotification": true, "data": hex.EncodeToString(b)}
+					for k, v := range ne {
+						n[k] = v
+					}
+					s.send(n)
+				})
+			} else {
+				err = p.cln.Unsubscribe(c, ind)
+			}
+		}
+		s.sendErr(e, err)
+
+	case "discoverDescriptors":
+		p.charEvent(e, a, "descriptorsDiscover")
+		var ds []*ble.Descriptor
+		c, err := p.char(a.ServiceUUID, a.CharacteristicUUID)
+		if err == nil {
+			ds, err = p.cln.DiscoverDescriptors(nil, c)
+		}
+		us := []string{}
+		for _, d := range ds {
+			us = append(us, d.UUID.String())
+		}
+		e["descriptors"] = us
+		s.sendErr(e, err)
+
+	case "readValue":
+		p.charEvent(e, a, "valueRead")
+		e["descriptorUuid"] = a.DescriptorUUID
+		var v []byte
+		d, err := p.desc(a.ServiceUUID, a.CharacteristicUUID, a.DescriptorUUID)
+		if err == nil {
+			v, err = p.cln.ReadDescriptor(d)
+		}
+		e["data"] = hex.EncodeToString(v)
+		s.sendErr(e, err)
+
+	case "writeValue":
+		p.charEvent(e, a, "valueWrite")
+		e["descriptorUuid"] = a.DescriptorUUID
+		d, err := p.desc(a.ServiceUUID, a.CharacteristicUUID, a.DescriptorUUID)
+		var v []byte
+		if err == nil {
+			v, err = hex.DecodeString(a.Data)
+		}
+		if err == nil {
+			err = p.cln.WriteDescriptor(d, v)
+		}
+		s.sendErr(e, err)
+
+	case "readHandle":
+		e["type"] = "handleRead"
+		e["handle"] = a.Handle
+		var v []byte
+		c, err := p.byHandle(a.Handle)
+		if err == nil {
+			v, err = p.cln.ReadLongCharacteristic(c)
+		}
+		e["data"] = hex.EncodeToString(v)
+		s.sendErr(e, err)
+
+	case "writeHandle":
+		e["type"] = "handleWrite"
+		e["handle"] = a.Handle
+		c, err := p.byHandle(a.Handle)
+		var v []byte
+		if err == nil {
+			v, err = hex.DecodeString(a.Data)
+		}
+		if err == nil {
+			err = p.cln.WriteCharacteristic(c, v, a.WithoutResponse)
+		}
+		s.sendErr(e, err)
+	}
+}
+
+// connect connects to the peripheral, and queues actDisconnected once the
+// connection ends.
+func (p *peripheral) connect(s *session) {
+	e := event{"peripheralUuid": p.uuid}
+	if p.cln != nil {
+		e["type"] = "connect"
+		s.send(e)
+		return
+	}
+	cln, err := s.d.Dial(s.ctx, s.addr(p.uuid))
+	if err != nil {
+		e["type"] = "disconnect"
+		s.sendErr(e, err)
+		return
+	}
+	p.cln = cln
+	e["type"] = "connect"
+	s.send(e)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		select {
+		case <-cln.Disconnected():
+			p.do(s, action{Action: actDisconnected})
+		case <-s.ctx.Done():
+		}
+	}()
+}
+
+// charEvent sets the type, and the UUIDs of the characteristic of a, of e.
+func (p *peripheral) charEvent(e event, a action, typ string) {
+	e["type"] = typ
+	e["peripheralUuid"] = p.uuid
+	e["serviceUuid"] = a.ServiceUUID
+	e["characteristicUuid"] = a.CharacteristicUUID
+}
+
+func (p *peripheral) service(u string) (*ble.Service, error) {
+	id, err := ble.Parse(u)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range p.svcs {
+		if s.UUID.Equal(id) {
+			return s, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (p *peripheral) char(su, cu string) (*ble.Characteristic, error) {
+	s, err := p.service(su)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ble.Parse(cu)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range s.Characteristics {
+		if c.UUID.Equal(id) {
+			return c, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+func (p *peripheral) desc(su, cu, du string) (*ble.Descriptor, error) {
+	c, err := p.char(su, cu)
+	if err != nil {
+		return nil, err
+	}
+	id, err := ble.Parse(du)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range c.Descriptors {
+		if d.UUID.Equal(id) {
+			return d, nil
+		}
+	}
+	return nil, errNotFound
+}
+
+// byHandle returns the characteristic discovered, whose value has the handle
+// h.
+func (p *peripheral) byHandle(h uint16) (*ble.Characteristic, error) {
+	for _, s := range p.svcs {
+		for _, c := range s.Characteristics {
+			if c.ValueHandle == h {
+				return c, nil
+			}
+		}
+	}
+	return nil, errNotFound
+}
+
+func parseUUIDs(ss []string) []ble.UUID {
+	var us []ble.UUID
+	for _, s := range ss {
+		if u, err := ble.Parse(s); err == nil {
+			us = append(us, u)
+		}
+	}
+	return us
+}
+
+func serviceUUIDs(svcs []*ble.Service) []ble.UUID {
+	var us []ble.UUID
+	for _, s := range svcs {
+		us = append(us, s.UUID)
+	}
+	return us
+}
@@ -0,0 +1,45 @@
+// Package noblews bridges the WebSocket bindings of noble, the BLE central
+// module of Node.js, to a ble.Device, so that the JavaScript tooling built on
+// noble drives the HCI implementation of this module.
+//
+// A Bridge serves the protocol of the ws-slave of noble: the clients send
+// the actions as JSON objects, and receive the events alike. The UUIDs are
+// in lowercase hex, without dashes, as noble has them; the data are in hex.
+// The peripherals are named by their addresses, without colons. An action,
+// which fails, is answered by its event with an "error" field, which the
+// bindings of noble ignore, and a connection which fails by a disconnect.
+//
+// Only the central side is bridged; bleno, the peripheral module, has no
+// WebSocket bindings to bridge.
+package noblews
+
+import (
+	"net/http"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/ws"
+)
+
+// DefaultPort is the port the WebSocket bindings of noble connect to on
+// localhost.
+const DefaultPort = 0xB1E
+
+// A Bridge serves a ble.Device to the WebSocket bindings of noble.
+type Bridge struct {
+	d ble.Device
+}
+
+// NewBridge returns the Bridge of the device d.
+func NewBridge(d ble.Device) *Bridge {
+	return &Bridge{d: d}
+}
+
+// ServeHTTP serves the client of the WebSocket request r, until it
+// disconnects. The scan, and the connections, of the client end with it.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := ws.Accept(w, r)
+	if err != nil {
+		return
+	}
+	newSession(b.d, c).serve()
+}
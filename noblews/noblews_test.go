@@ -0,0 +1,125 @@
+package noblews
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/ws"
+	"github.com/kirbo/ble/mock"
+)
+
+var (
+	testSvcUUID    = ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+	testReadUUID   = ble.MustParse("5e0a0002-0000-1000-8000-00805f9b34fb")
+	testNotifyUUID = ble.MustParse("5e0a0003-0000-1000-8000-00805f9b34fb")
+)
+
+// client is a client of the noble protocol.
+type client struct {
+	t *testing.T
+	c *ws.Conn
+}
+
+func (c *client) act(a map[string]interface{}) {
+	b, _ := json.Marshal(a)
+	if err := c.c.WriteMessage(ws.OpText, b); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// until returns the next event of the type typ, skipping the others, none
+// of which may be an error.
+func (c *client) until(typ string) map[string]interface{} {
+	for {
+		_, b, err := c.c.ReadMessage()
+		if err != nil {
+			c.t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal(b, &e); err != nil {
+			c.t.Fatal(err)
+		}
+		if e["error"] != nil {
+			c.t.Fatalf("%s: %v", e["type"], e["error"])
+		}
+		if e["type"] == typ {
+			return e
+		}
+	}
+}
+
+func TestBridge(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	d, err := n.NewDevice("66:55:44:33:22:11", "Bridge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Stop()
+
+	svc := ble.NewService(testSvcUUID)
+	svc.NewCharacteristic(testReadUUID).HandleRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+		rsp.Write([]byte("hello"))
+	}))
+	svc.NewCharacteristic(testNotifyUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		n.Write([]byte("tick"))
+		<-n.Context().Done()
+	}))
+	if err := p.AddService(svc); err != nil {
+		t.Fatal(err)
+	}
+	go p.AdvertiseNameAndServices(ctx, "Peripheral", testSvcUUID)
+
+	hs := httptest.NewServer(NewBridge(d))
+	defer hs.Close()
+	wc, err := ws.Dial("ws"+strings.TrimPrefix(hs.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wc.Close()
+	c := &client{t: t, c: wc}
+
+	if e := c.until("stateChange"); e["state"] != "poweredOn" {
+		t.Fatalf("state %v", e["state"])
+	}
+	c.act(map[string]interface{}{"action": "startScanning", "serviceUuids": []string{testSvcUUID.String()}})
+	e := c.until("discover")
+	id := e["peripheralUuid"]
+	if id != "112233445566" || e["advertisement"].(map[string]interface{})["localName"] != "Peripheral" {
+		t.Fatalf("discovered %v", e)
+	}
+	c.act(map[string]interface{}{"action": "stopScanning"})
+
+	c.act(map[string]interface{}{"action": "connect", "peripheralUuid": id})
+	c.until("connect")
+	c.act(map[string]interface{}{"action": "discoverServices", "peripheralUuid": id})
+	if e := c.until("servicesDiscover"); len(e["serviceUuids"].([]interface{})) == 0 {
+		t.Fatalf("no services: %v", e)
+	}
+	c.act(map[string]interface{}{"action": "discoverCharacteristics", "peripheralUuid": id, "serviceUuid": testSvcUUID.String()})
+	if e := c.until("characteristicsDiscover"); len(e["characteristics"].([]interface{})) != 2 {
+		t.Fatalf("characteristics: %v", e)
+	}
+	c.act(map[string]interface{}{"action": "read", "peripheralUuid": id, "serviceUuid": testSvcUUID.String(), "characteristicUuid": testReadUUID.String()})
+	if e := c.until("read"); e["data"] != "68656c6c6f" || e["isNotification"] != false {
+		t.Fatalf("read %v", e)
+	}
+	c.act(map[string]interface{}{"action": "notify", "peripheralUuid": id, "serviceUuid": testSvcUUID.String(), "characteristicUuid": testNotifyUUID.String(), "notify": true})
+	if e := c.until("read"); e["data"] != "7469636b" || e["isNotification"] != true {
+		t.Fatalf("notified %v", e)
+	}
+	c.act(map[string]interface{}{"action": "disconnect", "peripheralUuid": id})
+	c.until("disconnect")
+}
@@ -0,0 +1,267 @@
+package noblews
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/ws"
+)
+
+// maxQueued bounds the actions queued for a peripheral.
+const maxQueued = 64
+
+// action is an action of a client, with the fields of all of them.
+type action struct {
+	Action              string   `json:"action"`
+	PeripheralUUID      string   `json:"peripheralUuid"`
+	ServiceUUIDs        []string `json:"serviceUuids"`
+	AllowDuplicates     bool     `json:"allowDuplicates"`
+	UUIDs               []string `json:"uuids"`
+	ServiceUUID         string   `json:"serviceUuid"`
+	CharacteristicUUIDs []string `json:"characteristicUuids"`
+	CharacteristicUUID  string   `json:"characteristicUuid"`
+	DescriptorUUID      string   `json:"descriptorUuid"`
+	Handle              uint16   `json:"handle"`
+	Data                string   `json:"data"`
+	WithoutResponse     bool     `json:"withoutResponse"`
+	Notify              bool     `json:"notify"`
+}
+
+// event is an event sent to a client.
+type event map[string]interface{}
+
+// session serves a client.
+type session struct {
+	d ble.Device
+	c *ws.Conn
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	seen     map[string]ble.Addr // Addresses of the peripherals scanned.
+	periphs  map[string]*peripheral
+	stopScan context.CancelFunc
+}
+
+// peripheral is a peripheral the client acts on. Its actions are done in
+// order, on a goroutine of its own.
+type peripheral struct {
+	uuid string
+	q    chan action
+
+	// Set while connected, and accessed by the goroutine only.
+	cln  ble.Client
+	svcs []*ble.Service
+}
+
+func newSession(d ble.Device, c *ws.Conn) *session {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &session{
+		d:       d,
+		c:       c,
+		ctx:     ctx,
+		cancel:  cancel,
+		seen:    map[string]ble.Addr{},
+		periphs: map[string]*peripheral{},
+	}
+}
+
+func (s *session) serve() {
+	defer s.close()
+	s.send(event{"type": "stateChange", "state": "poweredOn"})
+	for {
+		_, b, err := s.c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var a action
+		if err := json.Unmarshal(b, &a); err != nil {
+			continue
+		}
+		switch a.Action {
+		case "startScanning":
+			s.startScanning(a)
+		case "stopScanning":
+			s.stopScanning()
+		case "":
+		default:
+			s.peripheral(a.PeripheralUUID).do(s, a)
+		}
+	}
+}
+
+// close ends the scan, and the connections, of the client.
+func (s *session) close() {
+	s.stopScanning()
+	s.cancel()
+	s.wg.Wait()
+	s.c.Close()
+}
+
+func (s *session) send(e event) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	s.c.WriteMessage(ws.OpText, b)
+}
+
+// sendErr sends e, with the error err, if any.
+func (s *session) sendErr(e event, err error) {
+	if err != nil {
+		e["error"] = err.Error()
+	}
+	s.send(e)
+}
+
+func (s *session) startScanning(a action) {
+	s.stopScanning()
+	var filter []ble.UUID
+	for _, u := range a.ServiceUUIDs {
+		if id, err := ble.Parse(u); err == nil {
+			filter = append(filter, id)
+		}
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	s.stopScan = cancel
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.d.Scan(ctx, a.AllowDuplicates, func(adv ble.Advertisement) {
+			if len(filter) != 0 && !hasAny(adv.Services(), filter) {
+				return
+			}
+			s.discovered(adv)
+		})
+	}()
+}
+
+func (s *session) stopScanning() {
+	s.mu.Lock()
+	stop := s.stopScan
+	s.stopScan = nil
+	s.mu.Unlock()
+	if stop != nil {
+		stop()
+	}
+}
+
+func hasAny(us, filter []ble.UUID) bool {
+	for _, u := range us {
+		if ble.Contains(filter, u) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) discovered(a ble.Advertisement) {
+	id := peripheralUUID(a.Addr())
+	s.mu.Lock()
+	s.seen[id] = a.Addr()
+	s.mu.Unlock()
+
+	sd := []event{}
+	for _, d := range a.ServiceData() {
+		sd = append(sd, event{"uuid": d.UUID.String(), "data": hex.EncodeToString(d.Data)})
+	}
+	ad := event{
+		"localName":        a.LocalName(),
+		"txPowerLevel":     a.TxPowerLevel(),
+		"serviceUuids":     uuidStrings(a.Services()),
+		"manufacturerData": hex.EncodeToString(a.ManufacturerData()),
+		"serviceData":      sd,
+	}
+	s.send(event{
+		"type":           "discover",
+		"peripheralUuid": id,
+		"address":        strings.ToLower(a.Addr().String()),
+		"addressType":    ble.AddrTypeOf(a.Addr()).String(),
+		"connectable":    a.Connectable(),
+		"advertisement":  ad,
+		"rssi":           a.RSSI(),
+	})
+}
+
+// peripheralUUID returns the name of the peripheral of a, as noble has it.
+func peripheralUUID(a ble.Addr) string {
+	return strings.ToLower(strings.Replace(a.String(), ":", "", -1))
+}
+
+// addr returns the address of the peripheral id.
+func (s *session) addr(id string) ble.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.seen[id]; ok {
+		return a
+	}
+	// Not scanned by the client: the address, with its colons back.
+	var b strings.Builder
+	for i := 0; i < len(id); i += 2 {
+		if i > 0 {
+			b.WriteByte(':')
+		}
+		b.WriteString(id[i:min(i+2, len(id))])
+	}
+	return ble.NewAddr(b.String())
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func uuidStrings(us []ble.UUID) []string {
+	ss := []string{}
+	for _, u := range us {
+		ss = append(ss, u.String())
+	}
+	return ss
+}
+
+// peripheral returns the peripheral id, starting it if it's new.
+func (s *session) peripheral(id string) *peripheral {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.periphs[id]
+	if !ok {
+		p = &peripheral{uuid: id, q: make(chan action, maxQueued)}
+		s.periphs[id] = p
+		s.wg.Add(1)
+		go p.loop(s)
+	}
+	return p
+}
+
+// do queues the action a, unless the client is gone.
+func (p *peripheral) do(s *session, a action) {
+	select {
+	case p.q <- a:
+	case <-s.ctx.Done():
+	}
+}
+
+func (p *peripheral) loop(s *session) {
+	defer s.wg.Done()
+	for {
+		select {
+		case a := <-p.q:
+			p.handle(s, a)
+		case <-s.ctx.Done():
+			if p.cln != nil {
+				p.cln.CancelConnection()
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ble
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrInvalidOOBData is returned by OOBData.UnmarshalBinary, for data lacking
+// the address, or of malformed fields.
+var ErrInvalidOOBData = errors.New("invalid OOB data")
+
+// AD types of the OOB data. [Core Specification Supplement, Part A, 1.16 & 1.18]
+const (
+	adLEDeviceAddr = 0x1B // LE Bluetooth Device Address
+	adLESCConfirm  = 0x22 // LE Secure Connections Confirmation Value
+	adLESCRandom   = 0x23 // LE Secure Connections Random Value
+)
+
+// OOBData is the LE Secure Connections Out-of-Band data of a device, passed
+// to the peer over NFC, or a QR code, so that the pairing is authenticated
+// by it. The values are little endian, as they are carried.
+// [Vol 3, Part H, 2.3.5.6.4]
+type OOBData struct {
+	Addr    Addr
+	Random  bool     // Addr is a random address, rather than a public one.
+	Rand    [16]byte // LE Secure Connections Random Value, r.
+	Confirm [16]byte // LE Secure Connections Confirmation Value, f4(PKx, PKx, r, 0).
+}
+
+// MarshalBinary encodes d as the AD structures of the OOB blocks of NFC and
+// QR codes: the LE Bluetooth Device Address, the Confirmation Value and the
+// Random Value.
+func (d OOBData) MarshalBinary() ([]byte, error) {
+	a, err := net.ParseMAC(d.Addr.String())
+	if err != nil || len(a) != 6 {
+		return nil, ErrInvalidOOBData
+	}
+	b := []byte{8, adLEDeviceAddr, a[5], a[4], a[3], a[2], a[1], a[0], 0x00}
+	if d.Random {
+		b[8] = 0x01
+	}
+	b = append(b, 17, adLESCConfirm)
+	b = append(b, d.Confirm[:]...)
+	b = append(b, 17, adLESCRandom)
+	return append(b, d.Rand[:]...), nil
+}
+
+// UnmarshalBinary parses the AD structures of b, ignoring those other than
+// the ones of MarshalBinary. The values absent are left zero, which the
+// pairing takes as no OOB data of the peer. [Vol 3, Part H, 2.3.5.6.4]
+func (d *OOBData) UnmarshalBinary(b []byte) error {
+	var od OOBData
+	for len(b) > 0 {
+		l := int(b[0])
+		if l == 0 {
+			break
+		}
+		if len(b) < 1+l {
+			return ErrInvalidOOBData
+		}
+		typ, v := b[1], b[2:1+l]
+		b = b[1+l:]
+		switch {
+		case typ == adLEDeviceAddr && len(v) == 7:
+			od.Addr = NewAddr(net.HardwareAddr{v[5], v[4], v[3], v[2], v[1], v[0]}.String())
+			od.Random = v[6]&0x01 != 0
+		case typ == adLESCConfirm && len(v) == 16:
+			copy(od.Confirm[:], v)
+		case typ == adLESCRandom && len(v) == 16:
+			copy(od.Rand[:], v)
+		case typ == adLEDeviceAddr || typ == adLESCConfirm || typ == adLESCRandom:
+			return ErrInvalidOOBData
+		}
+	}
+	if od.Addr == nil {
+		return ErrInvalidOOBData
+	}
+	*d = od
+	return nil
+}
@@ -0,0 +1,24 @@
+package ble
+
+import "testing"
+
+func TestOOBDataBinary(t *testing.T) {
+	d := OOBData{Addr: NewAddr("c0:11:22:33:44:55"), Random: true}
+	for i := range d.Rand {
+		d.Rand[i], d.Confirm[i] = byte(i), byte(0xF0+i)
+	}
+	b, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got OOBData
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.Addr.String() != d.Addr.String() || got.Random != d.Random || got.Rand != d.Rand || got.Confirm != d.Confirm {
+		t.Errorf("got %+v, want %+v", got, d)
+	}
+	if err := got.UnmarshalBinary(b[9:]); err != ErrInvalidOOBData {
+		t.Errorf("UnmarshalBinary() without the address = %v, want ErrInvalidOOBData", err)
+	}
+}
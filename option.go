@@ -1,6 +1,7 @@
 package ble
 
 import (
+	"io"
 	"time"
 
 	"github.com/kirbo/ble/linux/hci/evt"
@@ -20,8 +21,99 @@ type DeviceOption interface {
 	SetDisconnectedHandler(f func(evt.DisconnectionComplete)) error
 	SetPeripheralRole() error
 	SetCentralRole() error
+	SetRole(r Role) error
+	SetDedupCache(key DedupKey, ttl time.Duration) error
+	SetFilterDuplicates(enable bool) error
+	SetBondStore(s BondStore) error
+	SetIndicationPolicy(p IndicationPolicy) error
+	SetGATTCache(enable bool) error
+	SetRemoteInfo(enable bool) error
+	SetConnMonitor(m ConnMonitor, poll time.Duration) error
+	SetIntervalTuning(t IntervalTuning) error
+	SetScanBuffer(size int, onOverflow func(dropped int)) error
+	SetHCICapture(w io.Writer) error
+	SetTransport(t io.ReadWriteCloser) error
+	SetLogger(l Logger) error
+	SetMetrics(m MetricsCollector) error
+	SetMaxConnections(n int) error
+	SetScanFilters(fs []ScanFilter) error
+	SetScanOffload(o ScanOffload) error
+	SetRandomAddress(a Addr) error
+	SetPublicAddress(a Addr) error
+	SetSecurityPolicy(p SecurityPolicy) error
+	SetStateHandler(f func(AdapterState)) error
+	SetClock(c Clock) error
+	SetStateRestoration(id string, f func(RestoredState)) error
+	SetAdvTxPower(enable bool) error
+	SetAuthorizer(a Authorizer) error
+	SetExtendedScan(enable bool) error
+	SetPowerProfile(p PowerProfile) error
+	SetAuditHook(f AuditHook) error
+	SetRadioHandler(f RadioHandler) error
+	SetDropMalformed(enable bool) error
+	SetFallbackTCP(addr string) error
+	SetAdapterRemovedHandler(f func(), reopen bool) error
+	SetServerLimits(l ServerLimits) error
+	SetAdvRestartOnDisconnect(on bool) error
+	SetAdvWhileConnected(on bool) error
+	SetPacketHooks(hooks ...PacketHook) error
+	SetMemoryLimits(l MemoryLimits) error
+	SetAutoSecurity(l SecurityLevel) error
+	SetAdvIntervalMin(d time.Duration) error
+	SetAdvIntervalMax(d time.Duration) error
+	SetAdvChannelMap(m AdvChannel) error
+	SetAdvOwnAddressType(t OwnAddressType) error
+	SetScanInterval(d time.Duration) error
+	SetScanWindow(d time.Duration) error
+	SetActiveScan(active bool) error
+	SetScanPHYs(m ScanPHY) error
+	SetPreferredConnParams(p PreferredConnParams) error
+	SetPreferredPHY(p PreferredPHY) error
+	SetRFKillUnblock(on bool) error
+	SetShortenName(on bool) error
+	SetHostChannelClassification(m ChannelMap) error
 }
 
+// DedupKey selects what identifies a duplicate advertisement for host-side filtering.
+type DedupKey int
+
+// DedupKeys.
+const (
+	DedupByAddress        DedupKey = iota // address and PDU type
+	DedupByAddressAndData                 // address, PDU type and payload
+)
+
+// AdvChannel is a primary advertising channel, of the mask of OptAdvChannelMap.
+type AdvChannel uint8
+
+// Primary advertising channels.
+const (
+	AdvChannel37 AdvChannel = 1 << iota
+	AdvChannel38
+	AdvChannel39
+
+	AdvChannelAll = AdvChannel37 | AdvChannel38 | AdvChannel39
+)
+
+// ScanPHY is a PHY to scan on, of the mask of OptScanPHYs.
+type ScanPHY uint8
+
+// PHYs to scan on.
+const (
+	ScanPHY1M    ScanPHY = 0x01 // LE 1M, of the legacy advertising.
+	ScanPHYCoded ScanPHY = 0x04 // LE Coded, of the long range extended advertising.
+)
+
+// OwnAddressType selects the address the device advertises with.
+type OwnAddressType int
+
+// Own address types.
+const (
+	OwnAddressPublic OwnAddressType = iota + 1 // The public address.
+	OwnAddressRandom                           // The random static address of OptRandomAddress, generated if unset.
+	OwnAddressRPA                              // A resolvable private address, generated by the controller from its local IRK, or the public or random address without one.
+)
+
 // An Option is a configuration function, which configures the device.
 type Option func(DeviceOption) error
 
@@ -65,6 +157,42 @@ func OptScanParams(param cmd.LESetScanParameters) Option {
 	}
 }
 
+// OptScanInterval sets the interval of scanning, from 2.5 ms to 10.24 s, in
+// steps of 0.625 ms. This is linux specific.
+func OptScanInterval(d time.Duration) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetScanInterval(d)
+	}
+}
+
+// OptScanWindow sets the time scanned of each interval, up to the interval.
+// The lower the ratio of the window to the interval, the less radio time
+// scanning takes, but the later the advertisements are discovered. This is
+// linux specific.
+func OptScanWindow(d time.Duration) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetScanWindow(d)
+	}
+}
+
+// OptActiveScan sets active scanning, which requests the scan responses of
+// the advertisers, or passive scanning, which only listens. This is linux
+// specific.
+func OptActiveScan(active bool) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetActiveScan(active)
+	}
+}
+
+// OptScanPHYs sets the PHYs to scan on, with the extended scanning of
+// OptExtendedScan. The PHYs the controller doesn't support are left out.
+// This is linux specific.
+func OptScanPHYs(m ScanPHY) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetScanPHYs(m)
+	}
+}
+
 // OptAdvParams overrides default advertising parameters.
 func OptAdvParams(param cmd.LESetAdvertisingParameters) Option {
 	return func(opt DeviceOption) error {
@@ -73,6 +201,39 @@ func OptAdvParams(param cmd.LESetAdvertisingParameters) Option {
 	}
 }
 
+// OptAdvIntervalMin sets the minimum advertising interval, from 20 ms to
+// 10.24 s, in steps of 0.625 ms. Slow intervals save the power of the
+// broadcasters. This is linux specific.
+func OptAdvIntervalMin(d time.Duration) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetAdvIntervalMin(d)
+	}
+}
+
+// OptAdvIntervalMax sets the maximum advertising interval, from 20 ms to
+// 10.24 s, in steps of 0.625 ms. This is linux specific.
+func OptAdvIntervalMax(d time.Duration) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetAdvIntervalMax(d)
+	}
+}
+
+// OptAdvChannelMap sets the primary channels to advertise on, e.g. a single
+// one for test rigs. This is linux specific.
+func OptAdvChannelMap(m AdvChannel) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetAdvChannelMap(m)
+	}
+}
+
+// OptAdvOwnAddressType sets the address the device advertises with. This is
+// linux specific.
+func OptAdvOwnAddressType(t OwnAddressType) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetAdvOwnAddressType(t)
+	}
+}
+
 func OptConnectHandler(f func(evt.LEConnectionComplete)) Option {
 	return func(opt DeviceOption) error {
 		opt.SetConnectedHandler(f)
@@ -102,3 +263,447 @@ func OptCentralRole() Option {
 		return nil
 	}
 }
+
+// OptRole sets up the device for a role, or both. A single role skips the
+// setup of the other, and its methods return a RoleError.
+func OptRole(r Role) Option {
+	return func(opt DeviceOption) error {
+		opt.SetRole(r)
+		return nil
+	}
+}
+
+// OptRemoteInfo reads the version and the features of the remote controller
+// of each connection, on connect, which are returned by Client.RemoteInfo.
+func OptRemoteInfo(enable bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetRemoteInfo(enable)
+		return nil
+	}
+}
+
+// OptConnMonitor sets the ConnMonitor, which receives the telemetry of the
+// connections. Their RSSI and channel map are polled every poll, unless it's 0.
+func OptConnMonitor(m ConnMonitor, poll time.Duration) Option {
+	return func(opt DeviceOption) error {
+		opt.SetConnMonitor(m, poll)
+		return nil
+	}
+}
+
+// OptIntervalTuning tunes the parameters of each connection to its traffic,
+// between the fast and the slow ones of t.
+func OptIntervalTuning(t IntervalTuning) Option {
+	return func(opt DeviceOption) error {
+		opt.SetIntervalTuning(t)
+		return nil
+	}
+}
+
+// OptDedupCache configures host-side suppression of duplicate advertisements.
+// Reports with the same key are delivered at most once per ttl while
+// duplicates are filtered. A zero ttl disables the host-side cache.
+func OptDedupCache(key DedupKey, ttl time.Duration) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetDedupCache(key, ttl)
+	}
+}
+
+// WithFilterDuplicates enables or disables duplicate filtering regardless of
+// the allowDup argument of Scan. It toggles the controller's filter as well as
+// the host-side cache, which defaults to DedupByAddress with a 10s TTL.
+func WithFilterDuplicates(enable bool) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetFilterDuplicates(enable)
+	}
+}
+
+// OptBondStore sets the BondStore that keeps the keys of bonded peers, such
+// as the CSRKs used for signed writes.
+func OptBondStore(s BondStore) Option {
+	return func(opt DeviceOption) error {
+		opt.SetBondStore(s)
+		return nil
+	}
+}
+
+// OptIndicationPolicy configures the queueing, confirmation timeout and retries
+// of indications sent by the GATT server.
+func OptIndicationPolicy(p IndicationPolicy) Option {
+	return func(opt DeviceOption) error {
+		opt.SetIndicationPolicy(p)
+		return nil
+	}
+}
+
+// OptGATTCache enables caching the discovered GATT profiles of peers in the
+// BondStore, so that reconnecting to a peer whose database hasn't changed
+// skips discovery. It has no effect without OptBondStore.
+func OptGATTCache(enable bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetGATTCache(enable)
+		return nil
+	}
+}
+
+// OptScanBuffer sets the number of advertising reports queued for the
+// AdvHandler. Reports received while the queue is full are dropped, and
+// onOverflow, if set, is called with the number of dropped reports before
+// the next report is delivered.
+func OptScanBuffer(size int, onOverflow func(dropped int)) Option {
+	return func(opt DeviceOption) error {
+		opt.SetScanBuffer(size, onOverflow)
+		return nil
+	}
+}
+
+// WithHCICapture captures the HCI traffic of the device to w, in the btsnoop
+// format, for debugging with tools such as Wireshark. This is linux specific.
+func WithHCICapture(w io.Writer) Option {
+	return func(opt DeviceOption) error {
+		opt.SetHCICapture(w)
+		return nil
+	}
+}
+
+// OptTransport makes the device talk HCI over t, instead of the HCI socket of
+// the device ID. Each read and write of t carries one H4 packet. This is
+// linux specific, and meant for controllers behind other transports, and for
+// replays of captures, see monitor.ReplaySocket.
+func OptTransport(t io.ReadWriteCloser) Option {
+	return func(opt DeviceOption) error {
+		opt.SetTransport(t)
+		return nil
+	}
+}
+
+// OptLogger sets the Logger which receives the log events of the device and
+// its connections. Use FilterLogger to select the levels and subsystems.
+func OptLogger(l Logger) Option {
+	return func(opt DeviceOption) error {
+		opt.SetLogger(l)
+		return nil
+	}
+}
+
+// OptMetrics sets the MetricsCollector which receives the metrics of the
+// device and its connections, such as a Metrics served to Prometheus.
+func OptMetrics(m MetricsCollector) Option {
+	return func(opt DeviceOption) error {
+		opt.SetMetrics(m)
+		return nil
+	}
+}
+
+// OptMaxConnections limits the number of concurrent connections of the
+// device, in both roles. Dialing fails beyond the limit, and incoming
+// connections are disconnected. 0, the default, leaves the limit to the
+// controller. This is linux specific.
+func OptMaxConnections(n int) Option {
+	return func(opt DeviceOption) error {
+		opt.SetMaxConnections(n)
+		return nil
+	}
+}
+
+// OptScanFilters restricts the advertisements delivered by Scan to those
+// matching any of the filters. The filters are offloaded to the controller
+// where it supports it, to cut the host wakeups, and applied by the host
+// otherwise. This is linux specific.
+func OptScanFilters(fs ...ScanFilter) Option {
+	return func(opt DeviceOption) error {
+		opt.SetScanFilters(fs)
+		return nil
+	}
+}
+
+// OptScanOffload selects the filtering which Scan may push to the controller,
+// DefaultScanOffload unless set. The plan of each scan is logged, at the
+// debug level of LogHCI, and returned by the ScanPlan method of the HCI.
+// This is linux specific.
+func OptScanOffload(o ScanOffload) Option {
+	return func(opt DeviceOption) error {
+		opt.SetScanOffload(o)
+		return nil
+	}
+}
+
+// OptRandomAddress makes the device advertise, scan and dial with the random
+// static address a, e.g. to give each device of a fleet an identity of the
+// deployment. A nil address is generated, once per device. This is linux
+// specific.
+func OptRandomAddress(a Addr) Option {
+	return func(opt DeviceOption) error {
+		opt.SetRandomAddress(a)
+		return nil
+	}
+}
+
+// OptPublicAddressOverride replaces the public address of the controller with
+// a, with the vendor command of its manufacturer, where known: Intel, Texas
+// Instruments, Broadcom, Cypress, and Zephyr. The device fails to initialize
+// otherwise. This is linux specific.
+func OptPublicAddressOverride(a Addr) Option {
+	return func(opt DeviceOption) error {
+		opt.SetPublicAddress(a)
+		return nil
+	}
+}
+
+// OptSecurityPolicy sets the minimum security of the links. Pairing which
+// can't meet it is refused, and links encrypted below it are disconnected.
+// This is linux specific.
+func OptSecurityPolicy(p SecurityPolicy) Option {
+	return func(opt DeviceOption) error {
+		opt.SetSecurityPolicy(p)
+		return nil
+	}
+}
+
+// OptStateHandler sets the handler of the state changes of the adapter, such
+// as Bluetooth being turned off. On darwin, the device is then created
+// whatever the state, rather than failing with an AdapterStateError, and is
+// usable once the handler is called with AdapterPoweredOn.
+func OptStateHandler(f func(AdapterState)) Option {
+	return func(opt DeviceOption) error {
+		opt.SetStateHandler(f)
+		return nil
+	}
+}
+
+// OptStateRestoration sets the restore identifier of the device, under which
+// CoreBluetooth preserves its connections, scans and services across
+// relaunches of the process, and the handler, which is called with what was
+// restored, e.g. to dial the peripherals again. This is darwin specific.
+func OptStateRestoration(id string, f func(RestoredState)) Option {
+	return func(opt DeviceOption) error {
+		opt.SetStateRestoration(id, f)
+		return nil
+	}
+}
+
+// OptClock sets the Clock, which times the device and its connections, such
+// as the ATT transaction timeouts and the dialing and listening timeouts, in
+// place of SystemClock, so that tests of the timeouts can run on a
+// FakeClock. This is linux specific.
+func OptClock(c Clock) Option {
+	return func(opt DeviceOption) error {
+		opt.SetClock(c)
+		return nil
+	}
+}
+
+// OptAdvTxPower includes the Tx Power Level of the advertising, as read from
+// the controller, in the advertising data, or else the scan response, of the
+// Advertise methods, so that scanners can estimate their distance by the path
+// loss. This is linux specific.
+func OptAdvTxPower(enable bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAdvTxPower(enable)
+		return nil
+	}
+}
+
+// OptAuthorizer sets the Authorizer of the characteristics, which require
+// PermAuthorize, of the GATT server. Without one, their accesses are denied.
+// This is linux specific.
+func OptAuthorizer(a Authorizer) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAuthorizer(a)
+		return nil
+	}
+}
+
+// OptExtendedScan scans with the extended commands, if the controller
+// supports extended advertising, so that the advertisements of extended
+// advertising PDUs are received too, reassembled from their chains.
+// This is linux specific.
+func OptExtendedScan(enable bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetExtendedScan(enable)
+		return nil
+	}
+}
+
+// OptPowerProfile sets the scan interval and window, and the advertising
+// interval, to those of the power profile, overriding the ones of the
+// OptScanParams and OptAdvParams before it. The profile may be switched at
+// runtime with the SetPowerProfile of the device. This is linux specific.
+func OptPowerProfile(p PowerProfile) Option {
+	return func(opt DeviceOption) error {
+		opt.SetPowerProfile(p)
+		return nil
+	}
+}
+
+// OptAuditHook sets the hook, which is called for each attribute operation of
+// the clients of the GATT server, with its outcome. This is linux specific.
+func OptAuditHook(f AuditHook) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAuditHook(f)
+		return nil
+	}
+}
+
+// OptRadioHandler sets the handler, which is called as the device starts and
+// stops scanning and advertising. This is linux specific.
+func OptRadioHandler(f RadioHandler) Option {
+	return func(opt DeviceOption) error {
+		opt.SetRadioHandler(f)
+		return nil
+	}
+}
+
+// OptDropMalformed sets whether the malformed HCI events, ACL packets, L2CAP
+// PDUs and ATT PDUs received are logged, counted as MetricMalformedDropped,
+// and dropped. Otherwise they fail the connection, if not the device, as they
+// are parsed. This is linux specific.
+func OptDropMalformed(enable bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetDropMalformed(enable)
+		return nil
+	}
+}
+
+// OptFallbackTCP makes the device talk HCI over TCP to addr, as a stream of
+// H4 packets, such as to an HCI bridge of the host, if the platform has no
+// Bluetooth support, as in the containers not sharing the network of the
+// host. This is linux specific.
+func OptFallbackTCP(addr string) Option {
+	return func(opt DeviceOption) error {
+		opt.SetFallbackTCP(addr)
+		return nil
+	}
+}
+
+// OptAdapterRemovedHandler sets the handler, which is called once the adapter
+// is removed, such as a USB dongle unplugged, after the device has turned
+// AdapterPoweredOff, and its connections have been dropped. If reopen is set,
+// the device is initialized again once an adapter of the same address is
+// back, and turns AdapterPoweredOn. This is linux specific.
+func OptAdapterRemovedHandler(f func(), reopen bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAdapterRemovedHandler(f, reopen)
+		return nil
+	}
+}
+
+// OptServerLimits sets the limits of what each client of the GATT server may
+// do, disconnecting the clients which send requests faster than allowed.
+// This is linux specific.
+func OptServerLimits(l ServerLimits) Option {
+	return func(opt DeviceOption) error {
+		opt.SetServerLimits(l)
+		return nil
+	}
+}
+
+// OptAdvRestartOnDisconnect sets whether advertising is restarted, once a
+// central disconnects, which it is by default. This is linux specific.
+func OptAdvRestartOnDisconnect(on bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAdvRestartOnDisconnect(on)
+		return nil
+	}
+}
+
+// OptAdvWhileConnected sets whether advertising goes on while a central is
+// connected, if the controller supports it, which it does by default. If
+// not, advertising is restarted once the last central disconnects. This is
+// linux specific.
+func OptAdvWhileConnected(on bool) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAdvWhileConnected(on)
+		return nil
+	}
+}
+
+// OptPacketHooks adds hooks to the chain of PacketHooks, which inspect,
+// mutate, or drop the HCI packets passing between the host and the
+// controller, such as to work around vendor quirks, or to experiment. This
+// is linux specific.
+func OptPacketHooks(hooks ...PacketHook) Option {
+	return func(opt DeviceOption) error {
+		opt.SetPacketHooks(hooks...)
+		return nil
+	}
+}
+
+// OptMemoryLimits caps the memory the device allocates internally, such as
+// OptMemoryLimits(ble.BoundedMemory) on constrained devices. This is linux
+// specific.
+func OptMemoryLimits(l MemoryLimits) Option {
+	return func(opt DeviceOption) error {
+		opt.SetMemoryLimits(l)
+		return nil
+	}
+}
+
+// WithAutoSecurity makes the GATT operations of the clients, which the server
+// refuses with ErrInsuffEnc, ErrAuthentication or ErrInsuffEncrKeySize,
+// elevate the security of the link to at least l, and retry once. The link
+// is encrypted with the LTK bonded with the peer, see BondLTK, as pairing
+// isn't implemented. This is linux specific.
+func WithAutoSecurity(l SecurityLevel) Option {
+	return func(opt DeviceOption) error {
+		opt.SetAutoSecurity(l)
+		return nil
+	}
+}
+
+// OptPreferredConnParams sets the connection parameters the device prefers
+// as a peripheral. They're told in the Peripheral Preferred Connection
+// Parameters characteristic, and requested of the centrals, which connect
+// with others, a few seconds after connecting.
+func OptPreferredConnParams(p PreferredConnParams) Option {
+	return func(opt DeviceOption) error {
+		opt.SetPreferredConnParams(p)
+		return nil
+	}
+}
+
+// OptPreferredPHY sets the PHYs the device prefers to transmit and receive
+// on, as the default of the connections, which the peripheral requests
+// after connecting as well. The PHYs the controller doesn't support are left
+// out.
+func OptPreferredPHY(p PreferredPHY) Option {
+	return func(opt DeviceOption) error {
+		opt.SetPreferredPHY(p)
+		return nil
+	}
+}
+
+// OptRFKillUnblock soft-unblocks the adapter, as blocked by rfkill, before
+// it's brought up, which otherwise fails with "operation not possible due to
+// RF-kill". The adapters blocked with a hardware switch remain so. This is
+// linux specific.
+func OptRFKillUnblock() Option {
+	return func(opt DeviceOption) error {
+		opt.SetRFKillUnblock(true)
+		return nil
+	}
+}
+
+// OptShortenName makes the Advertise methods advertise the Shortened Local
+// Name, of as many of the leading characters of the name as fit, if the
+// complete name fits neither in the advertising data, nor in the scan
+// response, rather than fail with the adv.LengthError of the scan response.
+// This is linux specific.
+func OptShortenName() Option {
+	return func(opt DeviceOption) error {
+		opt.SetShortenName(true)
+		return nil
+	}
+}
+
+// OptHostChannelClassification classifies the data channels, which m
+// doesn't have, as bad, so that the controller leaves them out of the
+// channel maps of the connections, such as those overlapping a co-located
+// Wi-Fi access point, as of ChannelMap.WithoutWiFi. m must leave at least
+// MinChannels channels. The classification may be changed at runtime with
+// the SetHostChannelClassification of the device. This is linux specific.
+func OptHostChannelClassification(m ChannelMap) Option {
+	return func(opt DeviceOption) error {
+		return opt.SetHostChannelClassification(m)
+	}
+}
@@ -0,0 +1,27 @@
+package ble
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// unsupportedOption fails to set the dedup options, as darwin does.
+type unsupportedOption struct {
+	DeviceOption
+}
+
+var errUnsupported = errors.New("Not supported")
+
+func (unsupportedOption) SetDedupCache(k DedupKey, ttl time.Duration) error { return errUnsupported }
+func (unsupportedOption) SetFilterDuplicates(enable bool) error             { return errUnsupported }
+
+func TestDedupOptions(t *testing.T) {
+	// The options fail with the errors of the setters.
+	if err := OptDedupCache(DedupByAddress, time.Second)(unsupportedOption{}); err != errUnsupported {
+		t.Errorf("OptDedupCache() = %v, want %v", err, errUnsupported)
+	}
+	if err := WithFilterDuplicates(true)(unsupportedOption{}); err != errUnsupported {
+		t.Errorf("WithFilterDuplicates() = %v, want %v", err, errUnsupported)
+	}
+}
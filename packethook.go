@@ -0,0 +1,22 @@
+package ble
+
+// PacketDir is the direction of a packet, relative to the host.
+type PacketDir int
+
+// Directions of the packets.
+const (
+	PacketSent PacketDir = iota
+	PacketReceived
+)
+
+// A PacketHook intercepts the H4 packets passing between the host and the
+// controller: the commands and data sent, and the events and data received,
+// each starting with its H4 packet type. It returns the packet to pass on,
+// which is p, mutated in place or not, or another packet, or nil to drop it.
+//
+// The hooks set with OptPacketHooks are called in order, each with the packet
+// returned by the one before, until one drops it. They are called from the
+// goroutines sending and receiving, possibly at the same time, and shouldn't
+// block. A command dropped is never completed, and times out; ACL data
+// dropped keeps its buffer of the controller taken.
+type PacketHook func(dir PacketDir, p []byte) []byte
@@ -0,0 +1,34 @@
+package ble
+
+// Permission is the security, which the reads or the writes of a
+// characteristic require of the link. [Vol 3, Part F, 3.2.5]
+type Permission int
+
+// Permissions.
+const (
+	PermEncrypt      Permission = 1 << iota // The link is encrypted.
+	PermAuthenticate                        // The link is encrypted, with authenticated pairing.
+	PermAuthorize                           // The Authorizer of the server authorizes the peer.
+)
+
+// An Authorizer reports whether the peer of conn, as told by its address and
+// the security of the link, may read, or write if write is set, c, which
+// requires PermAuthorize.
+type Authorizer func(conn Conn, c *Characteristic, write bool) bool
+
+// CheckPermission returns nil if a link of the security s meets p, or else
+// the ATT error to respond with, so that the client can pair, or encrypt the
+// link, and try again. authorize is called only if p requires PermAuthorize,
+// and the link meets the rest; a nil one denies.
+func CheckPermission(p Permission, s Security, authorize func() bool) error {
+	if p&PermAuthenticate != 0 && s.Level < SecurityAuthenticated {
+		return ErrAuthentication
+	}
+	if p&PermEncrypt != 0 && !s.Encrypted() {
+		return ErrInsuffEnc
+	}
+	if p&PermAuthorize != 0 && (authorize == nil || !authorize()) {
+		return ErrAuthorization
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package ble
+
+// A PowerProfile sets the duty cycles of scanning and advertising together,
+// trading the latency of discovery for the power drawn by the radio.
+type PowerProfile int
+
+// Power profiles of a device.
+const (
+	PowerAggressive PowerProfile = iota + 1 // Scanning all the time, and advertising every 100 ms.
+	PowerBalanced                           // Scanning a quarter of the time, and advertising every 250 ms.
+	PowerLowPower                           // Scanning a tenth of the time, and advertising every second.
+)
+
+func (p PowerProfile) String() string {
+	switch p {
+	case PowerAggressive:
+		return "aggressive"
+	case PowerBalanced:
+		return "balanced"
+	case PowerLowPower:
+		return "low power"
+	}
+	return "unknown"
+}
@@ -0,0 +1,66 @@
+package ble
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// PreferredConnParams are the connection parameters, which a peripheral
+// prefers, set with OptPreferredConnParams. They're told in the Peripheral
+// Preferred Connection Parameters characteristic of the GAP service, and
+// requested of the centrals, which connect with others. [Vol 3, Part C, 12.3]
+type PreferredConnParams struct {
+	IntervalMin        time.Duration // Shortest interval; 7.5ms to 4s, in 1.25ms steps.
+	IntervalMax        time.Duration // Longest interval; IntervalMin to 4s, in 1.25ms steps.
+	Latency            int           // Connection events the peripheral may skip; 0 to 499.
+	SupervisionTimeout time.Duration // Time without a packet until the link is lost; 100ms to 32s, in 10ms steps.
+}
+
+// Validate checks p against the limits of the specification, with either of
+// its intervals.
+func (p PreferredConnParams) Validate() error {
+	if p.IntervalMin > p.IntervalMax {
+		return errors.New("minimum connection interval longer than the maximum")
+	}
+	for _, d := range []time.Duration{p.IntervalMin, p.IntervalMax} {
+		if err := (ConnParams{Interval: d, Latency: p.Latency, SupervisionTimeout: p.SupervisionTimeout}).Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the parameters c of a connection are as preferred.
+func (p PreferredConnParams) Contains(c ConnParams) bool {
+	return c.Interval >= p.IntervalMin && c.Interval <= p.IntervalMax &&
+		c.Latency == p.Latency && c.SupervisionTimeout == p.SupervisionTimeout
+}
+
+// Marshal returns the value of the Peripheral Preferred Connection Parameters
+// characteristic of p. [Vol 3, Part C, 12.3]
+func (p PreferredConnParams) Marshal() []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint16(b[0:], uint16(p.IntervalMin/connIntervalUnit))
+	binary.LittleEndian.PutUint16(b[2:], uint16(p.IntervalMax/connIntervalUnit))
+	binary.LittleEndian.PutUint16(b[4:], uint16(p.Latency))
+	binary.LittleEndian.PutUint16(b[6:], uint16(p.SupervisionTimeout/supervisionTimeoutUnit))
+	return b
+}
+
+// PHY is a mask of the LE PHYs. [Vol 2, Part E, 7.8.48]
+type PHY uint8
+
+// PHYs.
+const (
+	PHY1M    PHY = 0x01 // LE 1M.
+	PHY2M    PHY = 0x02 // LE 2M, of twice the throughput.
+	PHYCoded PHY = 0x04 // LE Coded, of the long range.
+)
+
+// PreferredPHY are the PHYs, which a device prefers to transmit and receive
+// on, set with OptPreferredPHY. A zero mask has no preference.
+type PreferredPHY struct {
+	Tx PHY
+	Rx PHY
+}
@@ -0,0 +1,190 @@
+package ble
+
+import (
+	"context"
+	"crypto/aes"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An Interest selects the devices a PresenceScanner tracks, by one of its
+// fields: the address of a device, the IRK resolving the private addresses of
+// a device, or a service UUID, which any number of devices advertise.
+type Interest struct {
+	Name    string    // Name of the interest, to tell the events apart; optional.
+	Addr    Addr      // Address of the device.
+	IRK     *[16]byte // IRK of the device, most significant octet first.
+	Service UUID      // Service the devices advertise.
+}
+
+// PresenceEvent tells that a device of an Interest appeared, or disappeared.
+type PresenceEvent struct {
+	Interest Interest
+	Addr     Addr          // Address the device last advertised with.
+	Present  bool          // The device appeared, or else disappeared.
+	LastSeen time.Time     // Time the device was last heard.
+	Adv      Advertisement // Last advertisement of the device.
+}
+
+// DefaultAbsenceTimeout is the AbsenceTimeout of a PresenceConfig, if 0.
+const DefaultAbsenceTimeout = 30 * time.Second
+
+// PresenceConfig configures a PresenceScanner. The callbacks are called one
+// at a time, as the presence of the devices changes, and mustn't block long.
+type PresenceConfig struct {
+	Interests      []Interest
+	AbsenceTimeout time.Duration // Time a device isn't heard, before it disappears; DefaultAbsenceTimeout if 0.
+
+	OnAppear    func(e PresenceEvent)
+	OnDisappear func(e PresenceEvent)
+}
+
+// A PresenceScanner scans in the background, tracking the presence of the
+// devices of a list of Interests, such as for presence-detection gateways.
+// It calls back only as a device appears, or hasn't been heard for the
+// AbsenceTimeout. The device is best set up with OptPresenceScanning, so the
+// radio scans passively, a tenth of the time.
+type PresenceScanner struct {
+	cfg PresenceConfig
+
+	mu      sync.Mutex
+	present map[string]*PresenceEvent // By the key of the Interest, and the address of a service.
+}
+
+// NewPresenceScanner returns a PresenceScanner, as configured by cfg.
+func NewPresenceScanner(cfg PresenceConfig) *PresenceScanner {
+	if cfg.AbsenceTimeout <= 0 {
+		cfg.AbsenceTimeout = DefaultAbsenceTimeout
+	}
+	return &PresenceScanner{cfg: cfg, present: make(map[string]*PresenceEvent)}
+}
+
+// OptPresenceScanning sets up a device for a PresenceScanner: scanning
+// passively, with the duty cycle of PowerLowPower.
+func OptPresenceScanning() Option {
+	return func(opt DeviceOption) error {
+		opt.SetActiveScan(false)
+		opt.SetPowerProfile(PowerLowPower)
+		return nil
+	}
+}
+
+// Scan scans on d, or the default device if d is nil, until ctx is done, or
+// the scan fails. The devices present remain so, until scanned again.
+func (s *PresenceScanner) Scan(ctx context.Context, d Device) error {
+	if d == nil {
+		d = defaultDevice
+	}
+	if d == nil {
+		return ErrDefaultDevice
+	}
+	clock := ClockFromContext(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// The absences are noticed within a quarter of the timeout.
+		t := clock.NewTicker(s.cfg.AbsenceTimeout / 4)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C():
+				s.sweep(now)
+			}
+		}
+	}()
+	// The duplicates are the heartbeats of the devices present.
+	return d.Scan(ctx, true, func(a Advertisement) { s.handle(a, clock.Now()) })
+}
+
+// Present returns the devices present, as they appeared, along with when they
+// were last heard.
+func (s *PresenceScanner) Present() []PresenceEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var es []PresenceEvent
+	for _, e := range s.present {
+		es = append(es, *e)
+	}
+	return es
+}
+
+// handle tracks the advertisement a, received at now.
+func (s *PresenceScanner) handle(a Advertisement, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, in := range s.cfg.Interests {
+		key, ok := in.match(i, a)
+		if !ok {
+			continue
+		}
+		if e, ok := s.present[key]; ok {
+			e.Addr, e.LastSeen, e.Adv = a.Addr(), now, a
+			continue
+		}
+		e := &PresenceEvent{Interest: in, Addr: a.Addr(), Present: true, LastSeen: now, Adv: a}
+		s.present[key] = e
+		if s.cfg.OnAppear != nil {
+			s.cfg.OnAppear(*e)
+		}
+	}
+}
+
+// sweep drops the devices, which haven't been heard for the AbsenceTimeout
+// at now.
+func (s *PresenceScanner) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, e := range s.present {
+		if now.Sub(e.LastSeen) < s.cfg.AbsenceTimeout {
+			continue
+		}
+		delete(s.present, key)
+		if s.cfg.OnDisappear != nil {
+			gone := *e
+			gone.Present = false
+			s.cfg.OnDisappear(gone)
+		}
+	}
+}
+
+// match reports whether the advertisement a matches the i-th Interest in,
+// and returns the key of the device it's tracked by.
+func (in Interest) match(i int, a Advertisement) (string, bool) {
+	key := strconv.Itoa(i)
+	switch {
+	case in.Addr != nil:
+		return key, SameAddr(in.Addr, a.Addr())
+	case in.IRK != nil:
+		return key, ResolveRPA(*in.IRK, a.Addr())
+	case in.Service != nil:
+		for _, u := range a.Services() {
+			if u.Equal(in.Service) {
+				return key + "/" + a.Addr().String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// ResolveRPA reports whether a is a resolvable private address, generated
+// with irk, most significant octet first. [Vol 6, Part B, 1.3.2.3]
+func ResolveRPA(irk [16]byte, a Addr) bool {
+	if AddrTypeOf(a) == AddrTypePublic {
+		return false
+	}
+	b, err := net.ParseMAC(a.String())
+	if err != nil || len(b) != 6 || b[0]>>6 != 0x01 {
+		return false
+	}
+	// hash = ah(IRK, prand), the 24 least significant bits of the AES-128
+	// encryption of prand, padded. [Vol 3, Part H, 2.2.2]
+	c, _ := aes.NewCipher(irk[:])
+	var r [16]byte
+	copy(r[13:], b[:3])
+	c.Encrypt(r[:], r[:])
+	return r[13] == b[3] && r[14] == b[4] && r[15] == b[5]
+}
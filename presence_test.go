@@ -0,0 +1,49 @@
+package ble
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResolveRPA(t *testing.T) {
+	// The sample data of ah. [Vol 3, Part H, D.7]
+	irk := [16]byte{0xec, 0x02, 0x34, 0xa3, 0x57, 0xc8, 0xad, 0x05, 0x34, 0x10, 0x10, 0xa6, 0x0a, 0x39, 0x7d, 0x9b}
+	if !ResolveRPA(irk, NewAddr("70:81:94:0d:fb:aa")) {
+		t.Error("RPA not resolved")
+	}
+	if ResolveRPA(irk, NewAddr("70:81:94:0d:fb:ab")) {
+		t.Error("RPA of another IRK resolved")
+	}
+}
+
+func TestPresenceScanner(t *testing.T) {
+	var events []PresenceEvent
+	record := func(e PresenceEvent) { events = append(events, e) }
+	s := NewPresenceScanner(PresenceConfig{
+		Interests: []Interest{
+			{Name: "tag", Addr: NewAddr("11:22:33:44:55:66")},
+			{Name: "heart rate", Service: UUID16(0x180D)},
+		},
+		AbsenceTimeout: 10 * time.Second,
+		OnAppear:       record,
+		OnDisappear:    record,
+	})
+	at := func(s int64) time.Time { return time.Unix(s, 0) }
+
+	tag := &testAdv{addr: NewAddr("11:22:33:44:55:66")}
+	hr := &testAdv{addr: NewAddr("aa:bb:cc:dd:ee:ff"), services: []UUID{UUID16(0x180D)}}
+	other := &testAdv{addr: NewAddr("66:55:44:33:22:11")}
+	s.handle(tag, at(0))
+	s.handle(other, at(1))
+	s.handle(hr, at(2))
+	s.handle(tag, at(8))
+	s.sweep(at(15))
+	if len(events) != 3 || !events[0].Present || events[0].Interest.Name != "tag" ||
+		!events[1].Present || events[1].Interest.Name != "heart rate" ||
+		events[2].Present || events[2].Interest.Name != "heart rate" {
+		t.Fatalf("events %+v, want the tag and the heart rate appearing, and the heart rate disappearing", events)
+	}
+	if p := s.Present(); len(p) != 1 || !p[0].LastSeen.Equal(at(8)) {
+		t.Errorf("Present() = %+v, want the tag, last seen at 8", p)
+	}
+}
@@ -1,5 +1,10 @@
 package ble
 
+import (
+	"fmt"
+	"sort"
+)
+
 // NewService creates and initialize a new Service using u as it's UUID.
 func NewService(u UUID) *Service {
 	return &Service{UUID: u}
@@ -35,6 +40,29 @@ type Profile struct {
 	Services []*Service
 }
 
+// DiscoverPartialProfile discovers only the specified services of a server,
+// along with their characteristics and descriptors. Neither backend discovers
+// anything on connect, so this is cheaper than DiscoverProfile on servers with
+// large GATT tables.
+func DiscoverPartialProfile(cln Client, ss []UUID) (*Profile, error) {
+	svcs, err := cln.DiscoverServices(ss)
+	if err != nil {
+		return nil, fmt.Errorf("can't discover services: %s", err)
+	}
+	for _, s := range svcs {
+		cs, err := cln.DiscoverCharacteristics(nil, s)
+		if err != nil {
+			return nil, fmt.Errorf("can't discover characteristics: %s", err)
+		}
+		for _, c := range cs {
+			if _, err := cln.DiscoverDescriptors(nil, c); err != nil {
+				return nil, fmt.Errorf("can't discover descriptors: %s", err)
+			}
+		}
+	}
+	return &Profile{Services: svcs}, nil
+}
+
 // Find searches discovered profile for the specified target's type and UUID.
 // The target must has the type of *Service, *Characteristic, or *Descriptor.
 func (p *Profile) Find(target interface{}) interface{} {
@@ -72,6 +100,22 @@ func (p *Profile) FindCharacteristic(char *Characteristic) *Characteristic {
 	return nil
 }
 
+// FindCharacteristics returns all the characteristics of the discovered profile
+// with UUID u, in handle order. Unlike FindCharacteristic, it addresses every
+// instance of a characteristic which a server exposes more than once.
+func (p *Profile) FindCharacteristics(u UUID) []*Characteristic {
+	var cs []*Characteristic
+	for _, s := range p.Services {
+		for _, c := range s.Characteristics {
+			if c.UUID.Equal(u) {
+				cs = append(cs, c)
+			}
+		}
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Handle < cs[j].Handle })
+	return cs
+}
+
 // FindDescriptor searches discoverd profile for the specified descriptor and UUID
 func (p *Profile) FindDescriptor(desc *Descriptor) *Descriptor {
 	for _, s := range p.Services {
@@ -123,6 +167,12 @@ type Characteristic struct {
 
 	Value []byte
 
+	// The security, which the reads and the writes of the value require.
+	// The servers respond with ErrInsuffEnc, ErrAuthentication or
+	// ErrAuthorization to the accesses of the links, which don't meet it.
+	ReadPermission  Permission
+	WritePermission Permission
+
 	ReadHandler     ReadHandler
 	WriteHandler    WriteHandler
 	NotifyHandler   NotifyHandler
@@ -131,6 +181,8 @@ type Characteristic struct {
 	Handle      uint16
 	ValueHandle uint16
 	EndHandle   uint16
+
+	fanout *fanout // Set by HandleFanout.
 }
 
 // AddDescriptor adds a descriptor to a characteristic.
@@ -0,0 +1,129 @@
+package profiles
+
+import (
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// BatteryLevelUUID is the Battery Level characteristic.
+var BatteryLevelUUID = ble.UUID16(0x2A19)
+
+// Battery is a client of the Battery Service of a peer. [BAS]
+type Battery struct {
+	cln   ble.Client
+	level *ble.Characteristic
+}
+
+// NewBattery returns the client of the Battery Service of cln.
+func NewBattery(cln ble.Client) (*Battery, error) {
+	s, err := service(cln, ble.BatteryUUID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := required(s, BatteryLevelUUID)
+	if err != nil {
+		return nil, err
+	}
+	return &Battery{cln: cln, level: c}, nil
+}
+
+// Level reads the level of the battery, in percent. [BAS, 3.1]
+func (b *Battery) Level() (int, error) {
+	v, err := b.cln.ReadCharacteristic(b.level)
+	if err != nil {
+		return 0, err
+	}
+	return parseBatteryLevel(v)
+}
+
+// SubscribeLevel calls h with the level of the battery, as the peer notifies
+// it. The notifications are optional, and the subscription fails without.
+func (b *Battery) SubscribeLevel(h func(level int)) error {
+	return b.cln.Subscribe(b.level, false, func(v []byte) {
+		if l, err := parseBatteryLevel(v); err == nil {
+			h(l)
+		}
+	})
+}
+
+// UnsubscribeLevel ends the subscription of SubscribeLevel.
+func (b *Battery) UnsubscribeLevel() error {
+	return b.cln.Unsubscribe(b.level, false)
+}
+
+func parseBatteryLevel(v []byte) (int, error) {
+	if len(v) != 1 || v[0] > 100 {
+		return 0, malformed("battery level", v)
+	}
+	return int(v[0]), nil
+}
+
+// BatteryService is the Battery Service of a GATT server, whose level is
+// read from a func. [BAS]
+type BatteryService struct {
+	Service *ble.Service
+
+	level func() int
+	mu    sync.Mutex
+	subs  map[chan struct{}]bool
+}
+
+// NewBatteryService returns the Battery Service, whose level, in percent, is
+// read from level, then clamped to 0 to 100. The subscribed clients are
+// notified of it on Changed.
+func NewBatteryService(level func() int) *BatteryService {
+	b := &BatteryService{Service: ble.NewService(ble.BatteryUUID), level: level, subs: map[chan struct{}]bool{}}
+	b.Service.BuildCharacteristic(BatteryLevelUUID).
+		Properties(ble.CharRead | ble.CharNotify).
+		OnRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+			rsp.Write(b.value())
+		})).
+		OnNotify(ble.NotifyHandlerFunc(b.notify))
+	return b
+}
+
+// Changed notifies the subscribed clients of the level, as it has changed.
+func (b *BatteryService) Changed() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *BatteryService) notify(req ble.Request, n ble.Notifier) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subs[ch] = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-n.Context().Done():
+			return
+		case <-ch:
+			if _, err := n.Write(b.value()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (b *BatteryService) value() []byte {
+	l := b.level()
+	switch {
+	case l < 0:
+		l = 0
+	case l > 100:
+		l = 100
+	}
+	return []byte{byte(l)}
+}
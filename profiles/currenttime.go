@@ -0,0 +1,106 @@
+package profiles
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// CurrentTimeCharUUID is the Current Time characteristic.
+var CurrentTimeCharUUID = ble.UUID16(0x2A2B)
+
+// Adjust Reasons of a CurrentTime. [CTS, 3.1.2.1]
+const (
+	AdjustManual       = 0x01 // Manual time update.
+	AdjustReference    = 0x02 // External reference time update.
+	AdjustTimeZone     = 0x04 // Change of time zone.
+	AdjustDaylightTime = 0x08 // Change of DST.
+)
+
+// CurrentTime is the value of the Current Time characteristic, the local
+// time of the peer.
+type CurrentTime struct {
+	Time         time.Time // In the location of the time.Local, as the peer doesn't tell its own.
+	AdjustReason uint8
+}
+
+// Clock is a client of the Current Time Service of a peer. [CTS]
+type Clock struct {
+	cln ble.Client
+	cur *ble.Characteristic
+}
+
+// NewClock returns the client of the Current Time Service of cln.
+func NewClock(cln ble.Client) (*Clock, error) {
+	s, err := service(cln, ble.CurrentTimeUUID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := required(s, CurrentTimeCharUUID)
+	if err != nil {
+		return nil, err
+	}
+	return &Clock{cln: cln, cur: c}, nil
+}
+
+// Time reads the current time of the peer.
+func (c *Clock) Time() (CurrentTime, error) {
+	v, err := c.cln.ReadCharacteristic(c.cur)
+	if err != nil {
+		return CurrentTime{}, err
+	}
+	return ParseCurrentTime(v)
+}
+
+// SetTime writes t to the peer, which may not allow it.
+func (c *Clock) SetTime(t CurrentTime) error {
+	return c.cln.WriteCharacteristic(c.cur, t.Bytes(), false)
+}
+
+// Subscribe calls h with the current time, as the peer notifies the changes
+// of it.
+func (c *Clock) Subscribe(h func(CurrentTime)) error {
+	return c.cln.Subscribe(c.cur, false, func(v []byte) {
+		if t, err := ParseCurrentTime(v); err == nil {
+			h(t)
+		}
+	})
+}
+
+// Unsubscribe ends the subscription of Subscribe.
+func (c *Clock) Unsubscribe() error {
+	return c.cln.Unsubscribe(c.cur, false)
+}
+
+// ParseCurrentTime parses the value of the Current Time characteristic.
+// [CTS, 3.1]
+func ParseCurrentTime(v []byte) (CurrentTime, error) {
+	if len(v) != 10 {
+		return CurrentTime{}, malformed("current time", v)
+	}
+	year := int(binary.LittleEndian.Uint16(v))
+	if year == 0 || v[2] == 0 || v[3] == 0 {
+		// The date is unknown.
+		return CurrentTime{}, malformed("current time", v)
+	}
+	frac := time.Duration(v[8]) * time.Second / 256
+	t := time.Date(year, time.Month(v[2]), int(v[3]), int(v[4]), int(v[5]), int(v[6]), int(frac), time.Local)
+	return CurrentTime{Time: t, AdjustReason: v[9]}, nil
+}
+
+// Bytes returns the value of the Current Time characteristic of t.
+func (t CurrentTime) Bytes() []byte {
+	v := make([]byte, 10)
+	binary.LittleEndian.PutUint16(v, uint16(t.Time.Year()))
+	v[2] = byte(t.Time.Month())
+	v[3] = byte(t.Time.Day())
+	v[4] = byte(t.Time.Hour())
+	v[5] = byte(t.Time.Minute())
+	v[6] = byte(t.Time.Second())
+	// Monday is 1, and Sunday 7.
+	v[7] = byte((int(t.Time.Weekday())+6)%7 + 1)
+	v[8] = byte(time.Duration(t.Time.Nanosecond()) * 256 / time.Second)
+	v[9] = t.AdjustReason
+	return v
+}
@@ -0,0 +1,147 @@
+package profiles
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/kirbo/ble"
+)
+
+// Characteristics of the Device Information Service. [DIS, 3]
+var (
+	SystemIDUUID         = ble.UUID16(0x2A23)
+	ModelNumberUUID      = ble.UUID16(0x2A24)
+	SerialNumberUUID     = ble.UUID16(0x2A25)
+	FirmwareRevisionUUID = ble.UUID16(0x2A26)
+	HardwareRevisionUUID = ble.UUID16(0x2A27)
+	SoftwareRevisionUUID = ble.UUID16(0x2A28)
+	ManufacturerNameUUID = ble.UUID16(0x2A29)
+	PnPIDUUID            = ble.UUID16(0x2A50)
+)
+
+// DeviceInfo is the Device Information Service of a peer. The fields of the
+// characteristics it lacks are left zero. [DIS]
+type DeviceInfo struct {
+	ManufacturerName string
+	ModelNumber      string
+	SerialNumber     string
+	HardwareRevision string
+	FirmwareRevision string
+	SoftwareRevision string
+	SystemID         uint64 // Manufacturer Identifier, in the 40 low bits, and Organizationally Unique Identifier.
+	PnPID            *PnPID
+}
+
+// PnPID is the Plug and Play identity of a device. [DIS, 3.9]
+type PnPID struct {
+	VendorIDSource uint8 // 1 for a Bluetooth SIG company identifier, 2 for a USB vendor ID.
+	VendorID       uint16
+	ProductID      uint16
+	ProductVersion uint16
+}
+
+// ReadDeviceInfo reads the characteristics of the Device Information Service
+// of cln, which it has.
+func ReadDeviceInfo(cln ble.Client) (*DeviceInfo, error) {
+	s, err := service(cln, ble.DeviceInfoUUID)
+	if err != nil {
+		return nil, err
+	}
+	d := &DeviceInfo{}
+	for _, f := range []struct {
+		u ble.UUID
+		s *string
+	}{
+		{ManufacturerNameUUID, &d.ManufacturerName},
+		{ModelNumberUUID, &d.ModelNumber},
+		{SerialNumberUUID, &d.SerialNumber},
+		{HardwareRevisionUUID, &d.HardwareRevision},
+		{FirmwareRevisionUUID, &d.FirmwareRevision},
+		{SoftwareRevisionUUID, &d.SoftwareRevision},
+	} {
+		v, err := read(cln, s, f.u)
+		if err != nil {
+			return nil, err
+		}
+		*f.s = string(v)
+	}
+	if v, err := read(cln, s, SystemIDUUID); err != nil {
+		return nil, err
+	} else if v != nil {
+		if len(v) != 8 {
+			return nil, malformed("system ID", v)
+		}
+		d.SystemID = binary.LittleEndian.Uint64(v)
+	}
+	if v, err := read(cln, s, PnPIDUUID); err != nil {
+		return nil, err
+	} else if v != nil {
+		if len(v) != 7 {
+			return nil, malformed("PnP ID", v)
+		}
+		d.PnPID = &PnPID{
+			VendorIDSource: v[0],
+			VendorID:       binary.LittleEndian.Uint16(v[1:]),
+			ProductID:      binary.LittleEndian.Uint16(v[3:]),
+			ProductVersion: binary.LittleEndian.Uint16(v[5:]),
+		}
+	}
+	return d, nil
+}
+
+// NewDeviceInformation returns the Device Information Service of d, with the
+// characteristics of its fields, which aren't zero. [DIS]
+func NewDeviceInformation(d DeviceInfo) *ble.Service {
+	s := ble.NewService(ble.DeviceInfoUUID)
+	add := func(u ble.UUID, v []byte) {
+		s.BuildCharacteristic(u).Properties(ble.CharRead).Value(v)
+	}
+	for _, f := range []struct {
+		u ble.UUID
+		s string
+	}{
+		{ManufacturerNameUUID, d.ManufacturerName},
+		{ModelNumberUUID, d.ModelNumber},
+		{SerialNumberUUID, d.SerialNumber},
+		{HardwareRevisionUUID, d.HardwareRevision},
+		{FirmwareRevisionUUID, d.FirmwareRevision},
+		{SoftwareRevisionUUID, d.SoftwareRevision},
+	} {
+		if f.s != "" {
+			add(f.u, []byte(f.s))
+		}
+	}
+	if d.SystemID != 0 {
+		v := make([]byte, 8)
+		binary.LittleEndian.PutUint64(v, d.SystemID)
+		add(SystemIDUUID, v)
+	}
+	if p := d.PnPID; p != nil {
+		v := []byte{p.VendorIDSource, 0, 0, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint16(v[1:], p.VendorID)
+		binary.LittleEndian.PutUint16(v[3:], p.ProductID)
+		binary.LittleEndian.PutUint16(v[5:], p.ProductVersion)
+		add(PnPIDUUID, v)
+	}
+	return s
+}
+
+// read reads the value of the characteristic u of s, as long as it needs,
+// or returns nil if s lacks it.
+func read(cln ble.Client, s *ble.Service, u ble.UUID) ([]byte, error) {
+	c := characteristic(s, u)
+	if c == nil {
+		return nil, nil
+	}
+	v, err := cln.ReadLongCharacteristic(c)
+	if errors.Is(err, ble.ErrAttrNotLong) || errors.Is(err, ble.ErrInvalidOffset) {
+		v, err = cln.ReadCharacteristic(c)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	return v, nil
+}
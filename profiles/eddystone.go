@@ -0,0 +1,129 @@
+package profiles
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// EddystoneUUID is the service, whose service data the Eddystone frames are.
+// A frame is advertised with AdvertiseServiceData16(EddystoneID, frame).
+// [Eddystone]
+var EddystoneUUID = ble.UUID16(EddystoneID)
+
+// EddystoneID is the 16-bit UUID of EddystoneUUID.
+const EddystoneID = 0xFEAA
+
+// The types of the Eddystone frames, their first byte.
+const (
+	EddystoneUID byte = 0x00
+	EddystoneURL byte = 0x10
+	EddystoneTLM byte = 0x20
+	EddystoneEID byte = 0x30
+)
+
+// EddystoneUIDFrame returns the UID frame of the namespace ns, and the
+// instance inst, whose power received at 0 m is txPower, in dBm.
+// [Eddystone-UID]
+func EddystoneUIDFrame(ns [10]byte, inst [6]byte, txPower int8) []byte {
+	b := make([]byte, 20) // With the 2 bytes reserved.
+	b[0], b[1] = EddystoneUID, byte(txPower)
+	copy(b[2:], ns[:])
+	copy(b[12:], inst[:])
+	return b
+}
+
+// The schemes, and the expansions of the Eddystone URLs. [Eddystone-URL]
+var (
+	eddystoneSchemes    = []string{"http://www.", "https://www.", "http://", "https://"}
+	eddystoneExpansions = []string{".com/", ".org/", ".edu/", ".net/", ".info/", ".biz/", ".gov/",
+		".com", ".org", ".edu", ".net", ".info", ".biz", ".gov"}
+)
+
+// maxEddystoneURL is the length of the longest URL encoded, short of its
+// scheme.
+const maxEddystoneURL = 17
+
+// EddystoneURLFrame returns the URL frame of url, whose power received at 0 m
+// is txPower, in dBm, or an error if the url doesn't encode in 17 bytes, as
+// its scheme, and the common domains, are compressed. [Eddystone-URL]
+func EddystoneURLFrame(url string, txPower int8) ([]byte, error) {
+	b, err := encodeEddystoneURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{EddystoneURL, byte(txPower)}, b...), nil
+}
+
+// encodeEddystoneURL returns url encoded, starting with its scheme.
+func encodeEddystoneURL(url string) ([]byte, error) {
+	b := []byte{}
+	for i, s := range eddystoneSchemes {
+		if strings.HasPrefix(url, s) {
+			b, url = append(b, byte(i)), url[len(s):]
+			break
+		}
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("URL %q has no scheme of Eddystone-URL", url)
+	}
+next:
+	for len(url) > 0 {
+		for i, e := range eddystoneExpansions {
+			if strings.HasPrefix(url, e) {
+				b, url = append(b, byte(i)), url[len(e):]
+				continue next
+			}
+		}
+		if url[0] <= 0x20 || url[0] >= 0x7F {
+			return nil, fmt.Errorf("URL has a character 0x%02X, which Eddystone-URL doesn't encode", url[0])
+		}
+		b, url = append(b, url[0]), url[1:]
+	}
+	if len(b) > 1+maxEddystoneURL {
+		return nil, fmt.Errorf("URL encodes in %d bytes, over the %d of Eddystone-URL", len(b)-1, maxEddystoneURL)
+	}
+	return b, nil
+}
+
+// DecodeEddystoneURL returns the URL of a URL frame.
+func DecodeEddystoneURL(frame []byte) (string, error) {
+	if len(frame) < 3 || frame[0] != EddystoneURL || int(frame[2]) >= len(eddystoneSchemes) {
+		return "", malformed("Eddystone-URL frame", frame)
+	}
+	s := eddystoneSchemes[frame[2]]
+	for _, c := range frame[3:] {
+		switch {
+		case int(c) < len(eddystoneExpansions):
+			s += eddystoneExpansions[c]
+		case c > 0x20 && c < 0x7F:
+			s += string(rune(c))
+		default:
+			return "", malformed("Eddystone-URL frame", frame)
+		}
+	}
+	return s, nil
+}
+
+// EddystoneTLMInfo is the telemetry of a beacon, which its TLM frame tells.
+// [Eddystone-TLM]
+type EddystoneTLMInfo struct {
+	Battery     int           // Voltage of the battery, in mV, or 0 if not measured.
+	Temperature float64       // Temperature of the beacon, in °C, or -128 if not measured.
+	AdvCount    uint32        // Frames advertised since the power up, or reboot.
+	Uptime      time.Duration // Time since the power up, or reboot, with a resolution of 0.1 s.
+}
+
+// Frame returns the unencrypted TLM frame of t.
+func (t EddystoneTLMInfo) Frame() []byte {
+	b := make([]byte, 14)
+	b[0], b[1] = EddystoneTLM, 0x00 // Version.
+	binary.BigEndian.PutUint16(b[2:], uint16(t.Battery))
+	binary.BigEndian.PutUint16(b[4:], uint16(int16(t.Temperature*256))) // Signed 8.8 fixed point.
+	binary.BigEndian.PutUint32(b[6:], t.AdvCount)
+	binary.BigEndian.PutUint32(b[10:], uint32(t.Uptime/(100*time.Millisecond)))
+	return b
+}
@@ -0,0 +1,480 @@
+package profiles
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// The Eddystone Configuration Service, and its characteristics, which the
+// beacon-config apps provision the beacons with. [Eddystone-GATT]
+var (
+	EddystoneConfigUUID       = ble.MustParse("a3c87500-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneCapabilitiesUUID = ble.MustParse("a3c87501-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneActiveSlotUUID   = ble.MustParse("a3c87502-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneIntervalUUID     = ble.MustParse("a3c87503-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneRadioTxUUID      = ble.MustParse("a3c87504-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneAdvTxUUID        = ble.MustParse("a3c87505-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneLockStateUUID    = ble.MustParse("a3c87506-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneUnlockUUID       = ble.MustParse("a3c87507-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneECDHKeyUUID      = ble.MustParse("a3c87508-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneEIDKeyUUID       = ble.MustParse("a3c87509-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneSlotDataUUID     = ble.MustParse("a3c8750a-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneResetUUID        = ble.MustParse("a3c8750b-8ed3-4bdf-8a39-a01bebede295")
+	EddystoneConnectableUUID  = ble.MustParse("a3c8750c-8ed3-4bdf-8a39-a01bebede295")
+)
+
+// The lock states of a beacon.
+const (
+	eddystoneLocked         = 0x00
+	eddystoneUnlocked       = 0x01
+	eddystoneUnlockedNoLock = 0x02 // Unlocked, and not relocked on disconnection.
+)
+
+// EddystoneSlot is an advertising slot of a beacon.
+type EddystoneSlot struct {
+	Frame        []byte        // Frame advertised, starting with its type, or nil if the slot is empty.
+	Interval     time.Duration // Advertising interval.
+	RadioTx      int8          // Radio tx power, in dBm.
+	AdvertisedTx int8          // Power received at 0 m, in dBm, which the UID and URL frames tell.
+}
+
+// EddystoneBeacon is what an Eddystone beacon supports, and its factory
+// settings, which EddystoneConfigService serves.
+type EddystoneBeacon struct {
+	Slots    int    // Advertising slots, 1 if 0.
+	TxPowers []int8 // Radio tx powers supported, in dBm, ascending; 0 dBm if none.
+
+	// Defaults are the factory settings of the slots, up to Slots. The
+	// slots without are empty, advertising every second at the highest
+	// tx power.
+	Defaults []EddystoneSlot
+
+	// Key is the lock key, which unlocks the beacon, and Unlocked tells
+	// whether it starts unlocked.
+	Key      [16]byte
+	Unlocked bool
+
+	// TLM returns the telemetry, which the TLM frames tell. The TLM frames
+	// are supported, if set.
+	TLM func() EddystoneTLMInfo
+
+	// OnChange is called, as a client changes the slots, or whether the
+	// beacon remains connectable, so that the application advertises them.
+	OnChange func()
+}
+
+// EddystoneConfigService is the Eddystone Configuration Service of a GATT
+// server, which lets the beacon-config apps provision the slots of a beacon:
+// their frames, advertising intervals and tx powers, and lock it. The
+// ephemeral identifiers (EID) aren't supported. The application advertises
+// the slots, as Slots tells, and advertises the service, connectable, while
+// the beacon is to be configured:
+//
+//	d.AdvertiseNameAndServices(ctx, "Beacon", profiles.EddystoneConfigUUID)
+//
+// The beacon relocks, as the client, which unlocked it, disconnects.
+// [Eddystone-GATT]
+type EddystoneConfigService struct {
+	Service *ble.Service
+
+	b           EddystoneBeacon
+	mu          sync.Mutex
+	slots       []EddystoneSlot
+	active      int
+	lock        byte
+	key         [16]byte
+	challenge   []byte
+	connectable bool
+}
+
+// NewEddystoneConfigService returns the Eddystone Configuration Service of
+// the beacon b.
+func NewEddystoneConfigService(b EddystoneBeacon) *EddystoneConfigService {
+	if b.Slots <= 0 {
+		b.Slots = 1
+	}
+	if len(b.TxPowers) == 0 {
+		b.TxPowers = []int8{0}
+	}
+	s := &EddystoneConfigService{Service: ble.NewService(EddystoneConfigUUID), b: b, key: b.Key, connectable: true}
+	s.reset()
+	if b.Unlocked {
+		s.lock = eddystoneUnlocked
+	}
+
+	rw := ble.CharRead | ble.CharWrite
+	s.add(EddystoneCapabilitiesUUID, ble.CharRead, false, s.capabilities, nil)
+	s.add(EddystoneActiveSlotUUID, rw, true, s.readActive, s.writeActive)
+	s.add(EddystoneIntervalUUID, rw, true, s.readInterval, s.writeInterval)
+	s.add(EddystoneRadioTxUUID, rw, true, s.readRadioTx, s.writeRadioTx)
+	s.add(EddystoneAdvTxUUID, rw, true, s.readAdvTx, s.writeAdvTx)
+	s.add(EddystoneLockStateUUID, rw, false, s.readLock, s.writeLock)
+	s.add(EddystoneUnlockUUID, rw, false, s.readChallenge, s.unlock)
+	s.add(EddystoneECDHKeyUUID, ble.CharRead, true, noEID, nil)
+	s.add(EddystoneEIDKeyUUID, ble.CharRead, true, noEID, nil)
+	s.add(EddystoneSlotDataUUID, rw, true, s.readSlot, s.writeSlot)
+	s.add(EddystoneResetUUID, ble.CharWrite, true, nil, s.factoryReset)
+	s.add(EddystoneConnectableUUID, rw, true, s.readConnectable, s.writeConnectable)
+	return s
+}
+
+// Slots returns the slots, as configured.
+func (s *EddystoneConfigService) Slots() []EddystoneSlot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ss := make([]EddystoneSlot, len(s.slots))
+	for i, sl := range s.slots {
+		ss[i] = sl
+		ss[i].Frame = append([]byte(nil), sl.Frame...)
+		if sl.Frame != nil && sl.Frame[0] == EddystoneTLM {
+			ss[i].Frame = s.tlm()
+		}
+	}
+	return ss
+}
+
+// RemainConnectable tells whether the beacon is to remain connectable, as it
+// advertises the slots, rather than only while it's to be configured.
+func (s *EddystoneConfigService) RemainConnectable() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connectable
+}
+
+// add adds the characteristic u, whose reads are served by r, and writes by
+// w, which are called with s.mu held; those of locked, only while the beacon
+// is unlocked.
+func (s *EddystoneConfigService) add(u ble.UUID, p ble.Property, locked bool, r func(ble.Request) ([]byte, error), w func(ble.Request) error) {
+	b := s.Service.BuildCharacteristic(u).Properties(p)
+	if r != nil {
+		b.OnRead(ble.ReadErrorHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) error {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if locked && s.lock == eddystoneLocked {
+				return ble.ErrAuthorization
+			}
+			v, err := r(req)
+			if err != nil {
+				return err
+			}
+			if req.Offset() > len(v) {
+				return ble.ErrInvalidOffset
+			}
+			_, err = rsp.Write(v[req.Offset():])
+			return err
+		}))
+	}
+	if w != nil {
+		b.OnWrite(ble.WriteErrorHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) error {
+			s.mu.Lock()
+			if locked && s.lock == eddystoneLocked {
+				s.mu.Unlock()
+				return ble.ErrAuthorization
+			}
+			err := w(req)
+			s.mu.Unlock()
+			if err == nil && locked && s.b.OnChange != nil {
+				s.b.OnChange()
+			}
+			return err
+		}))
+	}
+}
+
+// reset sets the slots to their factory settings.
+func (s *EddystoneConfigService) reset() {
+	s.slots = make([]EddystoneSlot, s.b.Slots)
+	for i := range s.slots {
+		if i < len(s.b.Defaults) {
+			s.slots[i] = s.b.Defaults[i]
+			s.slots[i].Frame = append([]byte(nil), s.b.Defaults[i].Frame...)
+			continue
+		}
+		s.slots[i] = EddystoneSlot{Interval: time.Second, RadioTx: s.b.TxPowers[len(s.b.TxPowers)-1]}
+	}
+	s.active = 0
+	s.connectable = true
+}
+
+// tlm returns the TLM frame, as it's read, or advertised.
+func (s *EddystoneConfigService) tlm() []byte {
+	if s.b.TLM == nil {
+		return []byte{EddystoneTLM}
+	}
+	return s.b.TLM().Frame()
+}
+
+// The capabilities and the frame types supported. [Eddystone-GATT, Capabilities]
+const (
+	eddystoneVariableInterval = 0x01
+	eddystoneVariableTx       = 0x02
+
+	eddystoneSupportsUID = 0x0001
+	eddystoneSupportsURL = 0x0002
+	eddystoneSupportsTLM = 0x0004
+)
+
+func (s *EddystoneConfigService) capabilities(req ble.Request) ([]byte, error) {
+	frames := uint16(eddystoneSupportsUID | eddystoneSupportsURL)
+	if s.b.TLM != nil {
+		frames |= eddystoneSupportsTLM
+	}
+	// Version, slots, EID slots, capabilities, frame types, tx powers.
+	v := []byte{0x00, byte(s.b.Slots), 0, eddystoneVariableInterval | eddystoneVariableTx, byte(frames >> 8), byte(frames)}
+	for _, p := range s.b.TxPowers {
+		v = append(v, byte(p))
+	}
+	return v, nil
+}
+
+func (s *EddystoneConfigService) readActive(req ble.Request) ([]byte, error) {
+	return []byte{byte(s.active)}, nil
+}
+
+func (s *EddystoneConfigService) writeActive(req ble.Request) error {
+	v := req.Data()
+	if len(v) != 1 {
+		return ble.ErrInvalAttrValueLen
+	}
+	if int(v[0]) >= len(s.slots) {
+		return ble.ErrValueNotAllowed
+	}
+	s.active = int(v[0])
+	return nil
+}
+
+func (s *EddystoneConfigService) readInterval(req ble.Request) ([]byte, error) {
+	v := make([]byte, 2)
+	binary.BigEndian.PutUint16(v, uint16(s.slots[s.active].Interval/time.Millisecond))
+	return v, nil
+}
+
+// The advertising intervals of the non-connectable advertising. [Vol 6, Part
+// B, 4.4.2.2]
+const (
+	minEddystoneInterval = 100 * time.Millisecond
+	maxEddystoneInterval = 10240 * time.Millisecond
+)
+
+// writeInterval sets the interval of the active slot, the nearest supported.
+func (s *EddystoneConfigService) writeInterval(req ble.Request) error {
+	v := req.Data()
+	if len(v) != 2 {
+		return ble.ErrInvalAttrValueLen
+	}
+	d := time.Duration(binary.BigEndian.Uint16(v)) * time.Millisecond
+	switch {
+	case d < minEddystoneInterval:
+		d = minEddystoneInterval
+	case d > maxEddystoneInterval:
+		d = maxEddystoneInterval
+	}
+	s.slots[s.active].Interval = d
+	return nil
+}
+
+func (s *EddystoneConfigService) readRadioTx(req ble.Request) ([]byte, error) {
+	return []byte{byte(s.slots[s.active].RadioTx)}, nil
+}
+
+// writeRadioTx sets the tx power of the active slot, the highest supported,
+// which doesn't exceed it, or the lowest.
+func (s *EddystoneConfigService) writeRadioTx(req ble.Request) error {
+	v := req.Data()
+	if len(v) != 1 {
+		return ble.ErrInvalAttrValueLen
+	}
+	p := s.b.TxPowers[0]
+	for _, q := range s.b.TxPowers {
+		if q <= int8(v[0]) {
+			p = q
+		}
+	}
+	s.slots[s.active].RadioTx = p
+	return nil
+}
+
+func (s *EddystoneConfigService) readAdvTx(req ble.Request) ([]byte, error) {
+	return []byte{byte(s.slots[s.active].AdvertisedTx)}, nil
+}
+
+// writeAdvTx sets the power at 0 m of the active slot, which its UID, or URL,
+// frame tells.
+func (s *EddystoneConfigService) writeAdvTx(req ble.Request) error {
+	v := req.Data()
+	if len(v) != 1 {
+		return ble.ErrInvalAttrValueLen
+	}
+	sl := &s.slots[s.active]
+	sl.AdvertisedTx = int8(v[0])
+	if len(sl.Frame) > 1 && (sl.Frame[0] == EddystoneUID || sl.Frame[0] == EddystoneURL) {
+		sl.Frame[1] = v[0]
+	}
+	return nil
+}
+
+func (s *EddystoneConfigService) readLock(req ble.Request) ([]byte, error) {
+	return []byte{s.lock}, nil
+}
+
+// writeLock locks the beacon, with a new key, if told, encrypted with the
+// key, or disables its relocking.
+func (s *EddystoneConfigService) writeLock(req ble.Request) error {
+	v := req.Data()
+	if s.lock == eddystoneLocked {
+		return ble.ErrAuthorization
+	}
+	switch {
+	case len(v) == 1 && v[0] == eddystoneLocked:
+		s.lock = eddystoneLocked
+	case len(v) == 1 && v[0] == eddystoneUnlockedNoLock:
+		s.lock = eddystoneUnlockedNoLock
+	case len(v) == 17 && v[0] == eddystoneLocked:
+		c, _ := aes.NewCipher(s.key[:])
+		c.Decrypt(s.key[:], v[1:])
+		s.lock = eddystoneLocked
+	case len(v) == 1 || len(v) == 17:
+		return ble.ErrValueNotAllowed
+	default:
+		return ble.ErrInvalAttrValueLen
+	}
+	return nil
+}
+
+// readChallenge returns a new challenge, which the client encrypts with the
+// key to unlock the beacon.
+func (s *EddystoneConfigService) readChallenge(req ble.Request) ([]byte, error) {
+	if s.lock != eddystoneLocked {
+		return nil, ble.ErrReadNotPerm
+	}
+	if s.challenge == nil {
+		s.challenge = make([]byte, 16)
+		if _, err := rand.Read(s.challenge); err != nil {
+			s.challenge = nil
+			return nil, err
+		}
+	}
+	return s.challenge, nil
+}
+
+// unlock unlocks the beacon, if the challenge, encrypted with the key, is
+// written, until the client disconnects. A challenge is used once.
+func (s *EddystoneConfigService) unlock(req ble.Request) error {
+	v := req.Data()
+	if len(v) != 16 {
+		return ble.ErrInvalAttrValueLen
+	}
+	if s.lock != eddystoneLocked {
+		return nil
+	}
+	challenge := s.challenge
+	s.challenge = nil
+	if challenge == nil {
+		return ble.ErrAuthorization
+	}
+	want := make([]byte, 16)
+	c, _ := aes.NewCipher(s.key[:])
+	c.Encrypt(want, challenge)
+	if subtle.ConstantTimeCompare(want, v) != 1 {
+		return ble.ErrAuthorization
+	}
+	s.lock = eddystoneUnlocked
+	go func() {
+		<-req.Conn().Disconnected()
+		s.mu.Lock()
+		if s.lock == eddystoneUnlocked {
+			s.lock = eddystoneLocked
+		}
+		s.mu.Unlock()
+	}()
+	return nil
+}
+
+func noEID(req ble.Request) ([]byte, error) {
+	return nil, ble.ErrReadNotPerm
+}
+
+// readSlot returns the frame of the active slot, as advertised.
+func (s *EddystoneConfigService) readSlot(req ble.Request) ([]byte, error) {
+	f := s.slots[s.active].Frame
+	switch {
+	case f == nil:
+		return []byte{}, nil
+	case f[0] == EddystoneTLM:
+		return s.tlm(), nil
+	case f[0] == EddystoneUID:
+		return f[:18], nil // Without the bytes reserved.
+	}
+	return f, nil
+}
+
+// writeSlot sets the frame of the active slot, or empties it, if no frame is
+// written.
+func (s *EddystoneConfigService) writeSlot(req ble.Request) error {
+	v := req.Data()
+	sl := &s.slots[s.active]
+	if len(v) == 0 || len(v) == 1 && v[0] == EddystoneUID {
+		sl.Frame = nil
+		return nil
+	}
+	switch v[0] {
+	case EddystoneUID:
+		if len(v) != 17 {
+			return ble.ErrInvalAttrValueLen
+		}
+		var ns [10]byte
+		var inst [6]byte
+		copy(ns[:], v[1:11])
+		copy(inst[:], v[11:])
+		sl.Frame = EddystoneUIDFrame(ns, inst, sl.AdvertisedTx)
+	case EddystoneURL:
+		if len(v) < 2 || len(v) > 2+maxEddystoneURL {
+			return ble.ErrInvalAttrValueLen
+		}
+		f := append([]byte{EddystoneURL, byte(sl.AdvertisedTx)}, v[1:]...)
+		if _, err := DecodeEddystoneURL(f); err != nil {
+			return ble.ErrValueNotAllowed
+		}
+		sl.Frame = f
+	case EddystoneTLM:
+		if s.b.TLM == nil {
+			return ble.ErrValueNotAllowed
+		}
+		sl.Frame = []byte{EddystoneTLM}
+	default:
+		return ble.ErrValueNotAllowed
+	}
+	return nil
+}
+
+// factoryReset resets the slots to their factory settings, as 0x0B is
+// written. The key remains.
+func (s *EddystoneConfigService) factoryReset(req ble.Request) error {
+	if v := req.Data(); len(v) != 1 || v[0] != 0x0B {
+		return ble.ErrValueNotAllowed
+	}
+	s.reset()
+	return nil
+}
+
+func (s *EddystoneConfigService) readConnectable(req ble.Request) ([]byte, error) {
+	if s.connectable {
+		return []byte{1}, nil
+	}
+	return []byte{0}, nil
+}
+
+func (s *EddystoneConfigService) writeConnectable(req ble.Request) error {
+	v := req.Data()
+	if len(v) != 1 {
+		return ble.ErrInvalAttrValueLen
+	}
+	s.connectable = v[0] != 0
+	return nil
+}
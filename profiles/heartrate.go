@@ -0,0 +1,153 @@
+package profiles
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/kirbo/ble"
+)
+
+// Characteristics of the Heart Rate Service. [HRS, 3]
+var (
+	HeartRateMeasurementUUID  = ble.UUID16(0x2A37)
+	BodySensorLocationUUID    = ble.UUID16(0x2A38)
+	HeartRateControlPointUUID = ble.UUID16(0x2A39)
+)
+
+// Flags of the Heart Rate Measurement. [HRS, 3.1.1.1]
+const (
+	hrmFlagUint16    = 0x01
+	hrmFlagContact   = 0x02
+	hrmFlagSupported = 0x04
+	hrmFlagEnergy    = 0x08
+	hrmFlagRR        = 0x10
+)
+
+// HeartRateMeasurement is a notification of the Heart Rate Measurement.
+type HeartRateMeasurement struct {
+	BPM int // Heart rate, in beats per minute.
+
+	ContactSupported bool // The sensor tells whether it's in contact with the skin.
+	Contact          bool // The sensor is in contact, if ContactSupported.
+
+	EnergyPresent  bool
+	EnergyExpended int // Energy expended since the last reset, in kJ, if EnergyPresent.
+
+	RRIntervals []time.Duration // Times between the beats since the last measurement, in 1/1024 s steps.
+}
+
+// ParseHeartRateMeasurement parses the value of the Heart Rate Measurement
+// characteristic. [HRS, 3.1.1]
+func ParseHeartRateMeasurement(v []byte) (HeartRateMeasurement, error) {
+	var m HeartRateMeasurement
+	if len(v) < 2 {
+		return m, malformed("heart rate measurement", v)
+	}
+	flags, b := v[0], v[1:]
+	if flags&hrmFlagUint16 != 0 {
+		if len(b) < 2 {
+			return m, malformed("heart rate measurement", v)
+		}
+		m.BPM, b = int(binary.LittleEndian.Uint16(b)), b[2:]
+	} else {
+		m.BPM, b = int(b[0]), b[1:]
+	}
+	m.ContactSupported = flags&hrmFlagSupported != 0
+	m.Contact = m.ContactSupported && flags&hrmFlagContact != 0
+	if flags&hrmFlagEnergy != 0 {
+		if len(b) < 2 {
+			return m, malformed("heart rate measurement", v)
+		}
+		m.EnergyPresent = true
+		m.EnergyExpended, b = int(binary.LittleEndian.Uint16(b)), b[2:]
+	}
+	if flags&hrmFlagRR != 0 {
+		if len(b)%2 != 0 {
+			return m, malformed("heart rate measurement", v)
+		}
+		for ; len(b) != 0; b = b[2:] {
+			rr := time.Duration(binary.LittleEndian.Uint16(b)) * time.Second / 1024
+			m.RRIntervals = append(m.RRIntervals, rr)
+		}
+	}
+	return m, nil
+}
+
+// BodySensorLocation is the location of a heart rate sensor. [HRS, 3.2]
+type BodySensorLocation uint8
+
+// Body sensor locations.
+const (
+	SensorOther BodySensorLocation = iota
+	SensorChest
+	SensorWrist
+	SensorFinger
+	SensorHand
+	SensorEarLobe
+	SensorFoot
+)
+
+// HeartRate is a client of the Heart Rate Service of a peer. [HRS]
+type HeartRate struct {
+	cln ble.Client
+	hrm *ble.Characteristic
+	bsl *ble.Characteristic
+	cp  *ble.Characteristic
+}
+
+// NewHeartRate returns the client of the Heart Rate Service of cln.
+func NewHeartRate(cln ble.Client) (*HeartRate, error) {
+	s, err := service(cln, ble.HeartRateUUID)
+	if err != nil {
+		return nil, err
+	}
+	c, err := required(s, HeartRateMeasurementUUID)
+	if err != nil {
+		return nil, err
+	}
+	return &HeartRate{
+		cln: cln,
+		hrm: c,
+		bsl: characteristic(s, BodySensorLocationUUID),
+		cp:  characteristic(s, HeartRateControlPointUUID),
+	}, nil
+}
+
+// Subscribe calls h with the measurements, as the peer notifies them. The
+// malformed ones are dropped.
+func (hr *HeartRate) Subscribe(h func(HeartRateMeasurement)) error {
+	return hr.cln.Subscribe(hr.hrm, false, func(v []byte) {
+		if m, err := ParseHeartRateMeasurement(v); err == nil {
+			h(m)
+		}
+	})
+}
+
+// Unsubscribe ends the subscription of Subscribe.
+func (hr *HeartRate) Unsubscribe() error {
+	return hr.cln.Unsubscribe(hr.hrm, false)
+}
+
+// BodySensorLocation reads the location of the sensor, which is optional.
+func (hr *HeartRate) BodySensorLocation() (BodySensorLocation, error) {
+	if hr.bsl == nil {
+		return 0, ErrNotFound
+	}
+	v, err := hr.cln.ReadCharacteristic(hr.bsl)
+	if err != nil {
+		return 0, err
+	}
+	if len(v) != 1 {
+		return 0, malformed("body sensor location", v)
+	}
+	return BodySensorLocation(v[0]), nil
+}
+
+// ResetEnergyExpended resets the energy expended of the measurements, which
+// only the sensors that tell it support. [HRS, 3.3.1]
+func (hr *HeartRate) ResetEnergyExpended() error {
+	if hr.cp == nil {
+		return ErrNotFound
+	}
+	return hr.cln.WriteCharacteristic(hr.cp, []byte{0x01}, false)
+}
@@ -0,0 +1,194 @@
+package profiles
+
+import (
+	"encoding/binary"
+
+	"github.com/kirbo/ble"
+)
+
+// Characteristics and descriptors of the HID Service. [HIDS, 2]
+var (
+	HIDInformationUUID  = ble.UUID16(0x2A4A)
+	ReportMapUUID       = ble.UUID16(0x2A4B)
+	HIDControlPointUUID = ble.UUID16(0x2A4C)
+	ReportUUID          = ble.UUID16(0x2A4D)
+	ProtocolModeUUID    = ble.UUID16(0x2A4E)
+	ReportReferenceUUID = ble.UUID16(0x2908)
+)
+
+// ReportType is the type of a Report. [HIDS, 3.6.4.2]
+type ReportType uint8
+
+// Report types.
+const (
+	ReportInput   ReportType = 0x01
+	ReportOutput  ReportType = 0x02
+	ReportFeature ReportType = 0x03
+)
+
+// ProtocolMode is the protocol mode of a HID device. [HIDS, 2.2]
+type ProtocolMode uint8
+
+// Protocol modes.
+const (
+	ProtocolBoot   ProtocolMode = 0x00
+	ProtocolReport ProtocolMode = 0x01
+)
+
+// HIDInfo is the HID Information of a HID device. [HIDS, 2.10]
+type HIDInfo struct {
+	BCDHID              uint16 // Version of the HID specification, in binary-coded decimal.
+	CountryCode         uint8
+	RemoteWake          bool // The device may wake the host up.
+	NormallyConnectable bool // The device advertises when bonded, but not connected.
+}
+
+// A Report is a report characteristic of a HID device, as its Report
+// Reference tells.
+type Report struct {
+	ID   uint8
+	Type ReportType
+	c    *ble.Characteristic
+}
+
+// HID is a client of the HID Service of a peer, the HID over GATT Profile.
+// It uses the first instance of the service. [HOGP]
+type HID struct {
+	cln       ble.Client
+	info      *ble.Characteristic
+	reportMap *ble.Characteristic
+	control   *ble.Characteristic
+	protocol  *ble.Characteristic
+	reports   []Report
+}
+
+// NewHID returns the client of the HID Service of cln. It reads the Report
+// References of the reports.
+func NewHID(cln ble.Client) (*HID, error) {
+	s, err := service(cln, ble.HIDUUID)
+	if err != nil {
+		return nil, err
+	}
+	h := &HID{
+		cln:      cln,
+		control:  characteristic(s, HIDControlPointUUID),
+		protocol: characteristic(s, ProtocolModeUUID),
+	}
+	if h.info, err = required(s, HIDInformationUUID); err != nil {
+		return nil, err
+	}
+	if h.reportMap, err = required(s, ReportMapUUID); err != nil {
+		return nil, err
+	}
+	for _, c := range s.Characteristics {
+		if !c.UUID.Equal(ReportUUID) {
+			continue
+		}
+		r := Report{c: c}
+		for _, d := range c.Descriptors {
+			if !d.UUID.Equal(ReportReferenceUUID) {
+				continue
+			}
+			v, err := cln.ReadDescriptor(d)
+			if err != nil {
+				return nil, err
+			}
+			if len(v) != 2 {
+				return nil, malformed("report reference", v)
+			}
+			r.ID, r.Type = v[0], ReportType(v[1])
+		}
+		h.reports = append(h.reports, r)
+	}
+	return h, nil
+}
+
+// Info reads the HID Information of the device.
+func (h *HID) Info() (HIDInfo, error) {
+	v, err := h.cln.ReadCharacteristic(h.info)
+	if err != nil {
+		return HIDInfo{}, err
+	}
+	if len(v) != 4 {
+		return HIDInfo{}, malformed("HID information", v)
+	}
+	return HIDInfo{
+		BCDHID:              binary.LittleEndian.Uint16(v),
+		CountryCode:         v[2],
+		RemoteWake:          v[3]&0x01 != 0,
+		NormallyConnectable: v[3]&0x02 != 0,
+	}, nil
+}
+
+// ReportMap reads the Report Map, the HID report descriptor of the device,
+// which describes the format of the reports. [HIDS, 2.6]
+func (h *HID) ReportMap() ([]byte, error) {
+	return h.cln.ReadLongCharacteristic(h.reportMap)
+}
+
+// Reports returns the reports of the device, in handle order.
+func (h *HID) Reports() []Report {
+	return h.reports
+}
+
+// SubscribeInput calls f with the input reports, as the device notifies
+// them.
+func (h *HID) SubscribeInput(f func(id uint8, data []byte)) error {
+	for _, r := range h.reports {
+		if r.Type != ReportInput {
+			continue
+		}
+		id := r.ID
+		if err := h.cln.Subscribe(r.c, false, func(v []byte) { f(id, v) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnsubscribeInput ends the subscriptions of SubscribeInput.
+func (h *HID) UnsubscribeInput() error {
+	for _, r := range h.reports {
+		if r.Type != ReportInput {
+			continue
+		}
+		if err := h.cln.Unsubscribe(r.c, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadReport reads the report r, such as a feature report.
+func (h *HID) ReadReport(r Report) ([]byte, error) {
+	return h.cln.ReadCharacteristic(r.c)
+}
+
+// WriteReport writes data to the report r, without response if it's an
+// output report, which allows it.
+func (h *HID) WriteReport(r Report, data []byte) error {
+	noRsp := r.Type == ReportOutput && r.c.Property&ble.CharWriteNR != 0
+	return h.cln.WriteCharacteristic(r.c, data, noRsp)
+}
+
+// SetProtocolMode sets the protocol mode of the device, which only those
+// supporting the boot protocol allow.
+func (h *HID) SetProtocolMode(m ProtocolMode) error {
+	if h.protocol == nil {
+		return ErrNotFound
+	}
+	return h.cln.WriteCharacteristic(h.protocol, []byte{byte(m)}, true)
+}
+
+// Suspend tells the device the host enters the suspend state, if suspend is
+// set, or exits it. [HIDS, 2.11]
+func (h *HID) Suspend(suspend bool) error {
+	if h.control == nil {
+		return ErrNotFound
+	}
+	v := byte(0x01) // Exit Suspend.
+	if suspend {
+		v = 0x00
+	}
+	return h.cln.WriteCharacteristic(h.control, []byte{v}, true)
+}
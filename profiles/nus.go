@@ -0,0 +1,143 @@
+package profiles
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// The Nordic UART Service, and its characteristics. The clients write the RX
+// characteristic, and the server notifies the TX characteristic.
+var (
+	NUSUUID   = ble.MustParse("6E400001-B5A3-F393-E0A9-E50E24DCCA9E")
+	NUSRXUUID = ble.MustParse("6E400002-B5A3-F393-E0A9-E50E24DCCA9E")
+	NUSTXUUID = ble.MustParse("6E400003-B5A3-F393-E0A9-E50E24DCCA9E")
+)
+
+// ErrNoSubscriber is returned by the writes of a UARTService, which no client
+// is subscribed to.
+var ErrNoSubscriber = errors.New("profiles: no subscriber")
+
+// NewUART returns the stream of the Nordic UART Service of cln, unframed.
+func NewUART(cln ble.Client) (*ble.CharStream, error) {
+	s, err := service(cln, NUSUUID)
+	if err != nil {
+		return nil, err
+	}
+	rx, err := required(s, NUSRXUUID)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := required(s, NUSTXUUID)
+	if err != nil {
+		return nil, err
+	}
+	return ble.NewCharStream(cln, rx, tx)
+}
+
+// UARTService is the Nordic UART Service of a GATT server. It reads the
+// bytes the clients write, and writes to the clients subscribed, as
+// notifications of up to their ATT_MTU-3 bytes.
+type UARTService struct {
+	Service *ble.Service
+
+	wmu sync.Mutex // Serializes the writes, so that their chunks don't interleave.
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    []byte // Written bytes, not read yet.
+	subs   map[ble.Notifier]bool
+	closed bool
+}
+
+// NewUARTService returns the Nordic UART Service.
+func NewUARTService() *UARTService {
+	u := &UARTService{Service: ble.NewService(NUSUUID), subs: map[ble.Notifier]bool{}}
+	u.cond = sync.NewCond(&u.mu)
+	u.Service.BuildCharacteristic(NUSRXUUID).
+		Properties(ble.CharWrite | ble.CharWriteNR).
+		OnWrite(ble.WriteHandlerFunc(u.written))
+	u.Service.BuildCharacteristic(NUSTXUUID).
+		Properties(ble.CharNotify).
+		OnNotify(ble.NotifyHandlerFunc(u.notify))
+	return u
+}
+
+func (u *UARTService) written(req ble.Request, rsp ble.ResponseWriter) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.closed {
+		u.buf = append(u.buf, req.Data()...)
+		u.cond.Broadcast()
+	}
+}
+
+func (u *UARTService) notify(req ble.Request, n ble.Notifier) {
+	u.mu.Lock()
+	u.subs[n] = true
+	u.mu.Unlock()
+	<-n.Context().Done()
+	u.mu.Lock()
+	delete(u.subs, n)
+	u.mu.Unlock()
+}
+
+// Read reads the bytes written by the clients, blocking until there are
+// some. It returns io.EOF once u is closed.
+func (u *UARTService) Read(p []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for len(u.buf) == 0 && !u.closed {
+		u.cond.Wait()
+	}
+	if len(u.buf) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, u.buf)
+	u.buf = u.buf[n:]
+	return n, nil
+}
+
+// Write writes p to each client subscribed, or returns ErrNoSubscriber if
+// none is.
+func (u *UARTService) Write(p []byte) (int, error) {
+	u.wmu.Lock()
+	defer u.wmu.Unlock()
+	u.mu.Lock()
+	if u.closed {
+		u.mu.Unlock()
+		return 0, io.ErrClosedPipe
+	}
+	var ns []ble.Notifier
+	for n := range u.subs {
+		ns = append(ns, n)
+	}
+	u.mu.Unlock()
+	if len(ns) == 0 {
+		return 0, ErrNoSubscriber
+	}
+	for _, n := range ns {
+		for b := p; len(b) > 0; {
+			c := n.Cap()
+			if c <= 0 || c > len(b) {
+				c = len(b)
+			}
+			if _, err := n.Write(b[:c]); err != nil {
+				return 0, err
+			}
+			b = b[c:]
+		}
+	}
+	return len(p), nil
+}
+
+// Close ends the reads, once the bytes written are read, and the writes.
+func (u *UARTService) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.closed = true
+	u.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package profiles provides typed clients of common SIG services, such as the
+// Battery Service and the Heart Rate Service, built on ble.Client. They
+// discover the service they wrap, unless the profile of the client has it
+// already, parse the values read, and decode the notifications.
+//
+// It provides the servers of some, as the services to add to a device, too:
+// the Device Information Service, the Battery Service, the Nordic UART
+// Service, the de facto serial port over GATT, and the Eddystone
+// Configuration Service, which provisions the Eddystone frames of a beacon.
+package profiles
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kirbo/ble"
+)
+
+// ErrNotFound is the error, as told by errors.Is, of the peers, which lack
+// the service of a client, or a characteristic it requires.
+var ErrNotFound = errors.New("profiles: not found")
+
+// ErrMalformed is the error, as told by errors.Is, of the values, which
+// don't parse.
+var ErrMalformed = errors.New("profiles: malformed value")
+
+// service returns the service u of cln, as discovered with its
+// characteristics and their descriptors.
+func service(cln ble.Client, u ble.UUID) (*ble.Service, error) {
+	if p := cln.Profile(); p != nil {
+		if s := p.FindService(ble.NewService(u)); s != nil && len(s.Characteristics) != 0 {
+			return s, nil
+		}
+	}
+	p, err := ble.DiscoverPartialProfile(cln, []ble.UUID{u})
+	if err != nil {
+		return nil, err
+	}
+	if s := p.FindService(ble.NewService(u)); s != nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("%w: service %s", ErrNotFound, u)
+}
+
+// characteristic returns the characteristic u of s, or nil if none.
+func characteristic(s *ble.Service, u ble.UUID) *ble.Characteristic {
+	for _, c := range s.Characteristics {
+		if c.UUID.Equal(u) {
+			return c
+		}
+	}
+	return nil
+}
+
+// required returns the characteristic u of s, or ErrNotFound if none.
+func required(s *ble.Service, u ble.UUID) (*ble.Characteristic, error) {
+	if c := characteristic(s, u); c != nil {
+		return c, nil
+	}
+	return nil, fmt.Errorf("%w: characteristic %s", ErrNotFound, u)
+}
+
+func malformed(what string, b []byte) error {
+	return fmt.Errorf("%w: %s [% X]", ErrMalformed, what, b)
+}
@@ -0,0 +1,345 @@
+package profiles
+
+import (
+	"context"
+	"crypto/aes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/mock"
+)
+
+func TestParseHeartRateMeasurement(t *testing.T) {
+	for _, tt := range []struct {
+		v    []byte
+		want HeartRateMeasurement
+	}{
+		{[]byte{0x00, 72}, HeartRateMeasurement{BPM: 72}},
+		{[]byte{0x06, 60}, HeartRateMeasurement{BPM: 60, ContactSupported: true, Contact: true}},
+		{[]byte{0x19, 0x2C, 0x01, 0x10, 0x00, 0x00, 0x04, 0x00, 0x02}, HeartRateMeasurement{
+			BPM: 300, EnergyPresent: true, EnergyExpended: 16,
+			RRIntervals: []time.Duration{time.Second, time.Second / 2},
+		}},
+	} {
+		m, err := ParseHeartRateMeasurement(tt.v)
+		if err != nil || !reflect.DeepEqual(m, tt.want) {
+			t.Errorf("[% X]: got %+v, %v, want %+v", tt.v, m, err, tt.want)
+		}
+	}
+	for _, v := range [][]byte{{0x00}, {0x01, 0x2C}, {0x10, 60, 0x00}} {
+		if _, err := ParseHeartRateMeasurement(v); !errors.Is(err, ErrMalformed) {
+			t.Errorf("[% X]: got %v, want ErrMalformed", v, err)
+		}
+	}
+}
+
+func TestCurrentTime(t *testing.T) {
+	want := CurrentTime{Time: time.Date(2024, 2, 29, 13, 45, 30, int(time.Second/2), time.Local), AdjustReason: AdjustManual}
+	v := want.Bytes()
+	if v[7] != 4 {
+		t.Errorf("day of week %d, want 4 for a Thursday", v[7])
+	}
+	got, err := ParseCurrentTime(v)
+	if err != nil || !got.Time.Equal(want.Time) || got.AdjustReason != want.AdjustReason {
+		t.Errorf("got %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestBatteryAndDeviceInfo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Central")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	level := 80
+	bas := NewBatteryService(func() int { return level })
+	dis := NewDeviceInformation(DeviceInfo{ManufacturerName: "Acme", PnPID: &PnPID{2, 0x1234, 0x5678, 1}})
+	if err := p.SetServices([]*ble.Service{bas.Service, dis}); err != nil {
+		t.Fatal(err)
+	}
+	advCtx, stopAdv := context.WithCancel(ctx)
+	defer stopAdv()
+	go p.AdvertiseNameAndServices(advCtx, "Peripheral")
+
+	cln, err := c.Dial(ctx, p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+
+	b, err := NewBattery(cln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, err := b.Level(); err != nil || l != 80 {
+		t.Fatalf("battery level %d, %v, want 80", l, err)
+	}
+	got := make(chan int, 1)
+	if err := b.SubscribeLevel(func(l int) { got <- l }); err != nil {
+		t.Fatal(err)
+	}
+	level = 120
+	// Notified once the server has started the subscription.
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+	for l := 0; l == 0; {
+		select {
+		case l = <-got:
+			if l != 100 {
+				t.Fatalf("notified battery level %d, want 100", l)
+			}
+		case <-tick.C:
+			bas.Changed()
+		case <-ctx.Done():
+			t.Fatal("battery level not notified")
+		}
+	}
+
+	d, err := ReadDeviceInfo(cln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ManufacturerName != "Acme" || d.ModelNumber != "" || d.PnPID == nil || *d.PnPID != (PnPID{2, 0x1234, 0x5678, 1}) {
+		t.Fatalf("unexpected device information %+v, PnP ID %+v", d, d.PnPID)
+	}
+
+	if _, err := NewHeartRate(cln); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound of the heart rate service", err)
+	}
+}
+
+func TestUART(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Central")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	srv := NewUARTService()
+	defer srv.Close()
+	if err := p.AddService(srv.Service); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.Write([]byte("early")); err != ErrNoSubscriber {
+		t.Fatalf("write returned %v, want ErrNoSubscriber", err)
+	}
+	advCtx, stopAdv := context.WithCancel(ctx)
+	defer stopAdv()
+	go p.AdvertiseNameAndServices(advCtx, "Peripheral")
+
+	cln, err := c.Dial(ctx, p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+	s, err := NewUART(cln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := s.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4)
+	if _, err := io.ReadFull(srv, b); err != nil || string(b) != "ping" {
+		t.Fatalf("server read %q, %v, want %q", b, err, "ping")
+	}
+	// Written once the server has started the subscription.
+	for {
+		if _, err = srv.Write([]byte("pong")); err != ErrNoSubscriber {
+			break
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("client not subscribed")
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(s, b); err != nil || string(b) != "pong" {
+		t.Fatalf("client read %q, %v, want %q", b, err, "pong")
+	}
+}
+
+func TestEddystoneURL(t *testing.T) {
+	f, err := EddystoneURLFrame("https://www.example.com/x", -20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{EddystoneURL, 0xEC, 0x01, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x00, 'x'}; !reflect.DeepEqual(f, want) {
+		t.Fatalf("frame [% X], want [% X]", f, want)
+	}
+	if u, err := DecodeEddystoneURL(f); err != nil || u != "https://www.example.com/x" {
+		t.Fatalf("decoded %q, %v", u, err)
+	}
+	if _, err := EddystoneURLFrame("https://a-much-too-long-domain.example/", 0); err == nil {
+		t.Fatal("URL too long encoded")
+	}
+	if _, err := EddystoneURLFrame("ftp://example.com", 0); err == nil {
+		t.Fatal("URL of an unknown scheme encoded")
+	}
+}
+
+func TestEddystoneConfig(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Central")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	key := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	changed := make(chan struct{}, 10)
+	srv := NewEddystoneConfigService(EddystoneBeacon{
+		Slots:    2,
+		TxPowers: []int8{-20, -8, 0, 4},
+		Key:      key,
+		OnChange: func() { changed <- struct{}{} },
+	})
+	if err := p.AddService(srv.Service); err != nil {
+		t.Fatal(err)
+	}
+	advCtx, stopAdv := context.WithCancel(ctx)
+	defer stopAdv()
+	go p.AdvertiseNameAndServices(advCtx, "Beacon", EddystoneConfigUUID)
+
+	cln, err := c.Dial(ctx, p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+	s, err := service(cln, EddystoneConfigUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	char := func(u ble.UUID) *ble.Characteristic {
+		ch, err := required(s, u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ch
+	}
+
+	v, err := cln.ReadCharacteristic(char(EddystoneCapabilitiesUUID))
+	if want := []byte{0, 2, 0, 3, 0, 3, 0xEC, 0xF8, 0, 4}; err != nil || !reflect.DeepEqual(v, want) {
+		t.Fatalf("capabilities [% X], %v, want [% X]", v, err, want)
+	}
+	uid := append([]byte{EddystoneUID}, []byte("namespace!inst01")...)
+	if err := cln.WriteCharacteristic(char(EddystoneSlotDataUUID), uid, false); !errors.Is(err, ble.ErrAuthorization) {
+		t.Fatalf("write while locked: %v, want ErrAuthorization", err)
+	}
+
+	// Unlock with the challenge encrypted with the key.
+	challenge, err := cln.ReadCharacteristic(char(EddystoneUnlockUUID))
+	if err != nil || len(challenge) != 16 {
+		t.Fatalf("challenge [% X], %v", challenge, err)
+	}
+	b, _ := aes.NewCipher(key[:])
+	token := make([]byte, 16)
+	b.Encrypt(token, challenge)
+	if err := cln.WriteCharacteristic(char(EddystoneUnlockUUID), token, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := cln.ReadCharacteristic(char(EddystoneLockStateUUID)); err != nil || !reflect.DeepEqual(v, []byte{eddystoneUnlocked}) {
+		t.Fatalf("lock state [% X], %v, want unlocked", v, err)
+	}
+
+	if err := cln.WriteCharacteristic(char(EddystoneAdvTxUUID), []byte{0xEE}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cln.WriteCharacteristic(char(EddystoneRadioTxUUID), []byte{2}, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cln.WriteCharacteristic(char(EddystoneSlotDataUUID), uid, false); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := cln.ReadCharacteristic(char(EddystoneSlotDataUUID)); err != nil || !reflect.DeepEqual(v, append([]byte{EddystoneUID, 0xEE}, uid[1:]...)) {
+		t.Fatalf("slot data [% X], %v", v, err)
+	}
+	if err := cln.WriteCharacteristic(char(EddystoneActiveSlotUUID), []byte{1}, false); err != nil {
+		t.Fatal(err)
+	}
+	url := []byte{EddystoneURL, 0x03, 'g', 'o', 'o', '.', 'g', 'l'}
+	if err := cln.WriteCharacteristic(char(EddystoneSlotDataUUID), url, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cln.WriteCharacteristic(char(EddystoneSlotDataUUID), []byte{EddystoneTLM}, false); !errors.Is(err, ble.ErrValueNotAllowed) {
+		t.Fatalf("TLM written: %v, want ErrValueNotAllowed", err)
+	}
+	if err := cln.WriteCharacteristic(char(EddystoneIntervalUUID), []byte{0, 10}, false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		<-changed
+	}
+
+	ss := srv.Slots()
+	if ss[0].RadioTx != 0 || ss[0].Frame[1] != 0xEE || len(ss[0].Frame) != 20 {
+		t.Errorf("slot 0 %+v", ss[0])
+	}
+	if u, err := DecodeEddystoneURL(ss[1].Frame); err != nil || u != "https://goo.gl" || ss[1].Interval != 100*time.Millisecond {
+		t.Errorf("slot 1 %+v, URL %q, %v", ss[1], u, err)
+	}
+
+	// Locked with a new key, encrypted with the key.
+	key2 := [16]byte{0xAA}
+	enc := make([]byte, 16)
+	b.Encrypt(enc, key2[:])
+	if err := cln.WriteCharacteristic(char(EddystoneLockStateUUID), append([]byte{eddystoneLocked}, enc...), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cln.ReadCharacteristic(char(EddystoneSlotDataUUID)); !errors.Is(err, ble.ErrAuthorization) {
+		t.Fatalf("read while locked: %v, want ErrAuthorization", err)
+	}
+	challenge, _ = cln.ReadCharacteristic(char(EddystoneUnlockUUID))
+	b.Encrypt(token, challenge)
+	if err := cln.WriteCharacteristic(char(EddystoneUnlockUUID), token, false); !errors.Is(err, ble.ErrAuthorization) {
+		t.Fatalf("unlocked with the old key: %v", err)
+	}
+	challenge, _ = cln.ReadCharacteristic(char(EddystoneUnlockUUID))
+	b2, _ := aes.NewCipher(key2[:])
+	b2.Encrypt(token, challenge)
+	if err := cln.WriteCharacteristic(char(EddystoneUnlockUUID), token, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := cln.WriteCharacteristic(char(EddystoneResetUUID), []byte{0x0B}, false); err != nil {
+		t.Fatal(err)
+	}
+	if ss := srv.Slots(); ss[0].Frame != nil || ss[1].Frame != nil {
+		t.Errorf("slots not reset: %+v", ss)
+	}
+}
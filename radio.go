@@ -0,0 +1,78 @@
+package ble
+
+import "time"
+
+// RadioActivity is the scanning or the advertising of a RadioEvent.
+type RadioActivity int
+
+// RadioActivities.
+const (
+	RadioScanning RadioActivity = iota
+	RadioAdvertising
+)
+
+func (a RadioActivity) String() string {
+	if a == RadioAdvertising {
+		return "advertising"
+	}
+	return "scanning"
+}
+
+// StopReason tells why the radio stopped scanning or advertising.
+type StopReason int
+
+// StopReasons.
+const (
+	StopRequested  StopReason = iota // Stopped by the application.
+	StopPaused                       // Paused while dialing, and started again after.
+	StopConnected                    // Advertising ended by a central connecting.
+	StopTimeout                      // Directed advertising timed out.
+	StopPoweredOff                   // The device is closed, or the adapter is gone.
+	StopFailed                       // The controller failed to start.
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopPaused:
+		return "paused"
+	case StopConnected:
+		return "connected"
+	case StopTimeout:
+		return "timeout"
+	case StopPoweredOff:
+		return "powered off"
+	case StopFailed:
+		return "failed"
+	}
+	return "requested"
+}
+
+// A RadioEvent is a transition of the scanning, or the advertising, as the
+// controller is asked for it. It carries the parameters in use. A start the
+// controller fails is followed by a stop of StopFailed.
+type RadioEvent struct {
+	Time     time.Time
+	Activity RadioActivity
+	Started  bool
+	Reason   StopReason // Why it stopped, unless Started.
+	Extended bool       // Extended scanning or advertising.
+
+	// Of scanning.
+	Interval  time.Duration
+	Window    time.Duration
+	Active    bool // Scan requests are sent.
+	FilterDup bool // The controller filters duplicates.
+
+	// Of advertising.
+	IntervalMin time.Duration
+	IntervalMax time.Duration
+	Connectable bool
+	Directed    bool
+	Channels    AdvChannel
+}
+
+// A RadioHandler is called with the RadioEvents of a device, set with
+// OptRadioHandler, so that supervisory code may tell what the radio does. The
+// events are passed in order, on a goroutine of its own; a handler which
+// blocks delays the later events.
+type RadioHandler func(e RadioEvent)
@@ -0,0 +1,282 @@
+package ble
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ConnState is the state of the connection kept by a Connector.
+type ConnState int
+
+// States of a Connector.
+const (
+	StateConnecting   ConnState = iota // Discovering and connecting to the peer.
+	StateConnected                     // Connected, set up, and subscribed.
+	StateDisconnected                  // Disconnected, or an attempt failed; waiting to reconnect.
+	StateStopped                       // Run returned.
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// ReconnectPolicy configures how a Connector reconnects. The zero value
+// retries forever, starting after a second, and backing off to a minute.
+type ReconnectPolicy struct {
+	InitialBackoff time.Duration // Delay after the first failed attempt; 1s if 0.
+	MaxBackoff     time.Duration // Limit of the delay, which doubles on every failed attempt; 1min if 0.
+	Jitter         float64       // Fraction of the delay which is randomized, from 0 to 1, to spread the attempts of many Connectors.
+	MaxAttempts    int           // Consecutive failed attempts before Run gives up; 0 for no limit.
+	AttemptTimeout time.Duration // Limit of each attempt to discover and connect; 0 for no limit.
+}
+
+// Default delays of a ReconnectPolicy.
+const (
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+)
+
+// Backoff returns the delay before the next attempt, after the given number
+// of consecutive failed attempts. The first attempt after a disconnection
+// isn't delayed.
+func (p ReconnectPolicy) Backoff(failures int) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	d, max := p.InitialBackoff, p.MaxBackoff
+	if d <= 0 {
+		d = DefaultInitialBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+	for i := 1; i < failures && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(j * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// A Dialer establishes a connection for a Connector.
+type Dialer func(ctx context.Context) (Client, error)
+
+// DialFilter returns a Dialer which scans for, and connects to, the first
+// peripheral matching f, with the default device. The peer is re-discovered
+// on every attempt, so a peer which changes its address is found again, as
+// long as f doesn't depend on the address.
+func DialFilter(f AdvFilter) Dialer {
+	return func(ctx context.Context) (Client, error) { return Connect(ctx, f) }
+}
+
+// DialAddr returns a Dialer which connects to a, with the default device.
+func DialAddr(a Addr) Dialer {
+	return func(ctx context.Context) (Client, error) { return Dial(ctx, a) }
+}
+
+// A Connector keeps a connection to a peer: whenever it's lost, the peer is
+// connected again, with backoff, and the subscriptions are restored, so that
+// long running collectors survive flaky links.
+type Connector struct {
+	// Setup, if set, is called on every new connection before the
+	// subscriptions are restored, such as to re-establish security with a
+	// bonded peer, or to discover the profile. An error drops the
+	// connection, and counts as a failed attempt.
+	Setup func(cln Client) error
+
+	// OnStateChange, if set, is called on every change of the state. err is
+	// the reason of a failed attempt, if any.
+	OnStateChange func(s ConnState, err error)
+
+	dial   Dialer
+	policy ReconnectPolicy
+
+	mu   sync.Mutex
+	cln  Client
+	subs []subscription
+
+	// changed is closed, and replaced, whenever cln changes.
+	changed chan struct{}
+}
+
+type subscription struct {
+	u   UUID
+	ind bool
+	h   NotificationHandler
+}
+
+// NewConnector returns a Connector, which connects with dial according to p.
+func NewConnector(dial Dialer, p ReconnectPolicy) *Connector {
+	return &Connector{dial: dial, policy: p, changed: make(chan struct{})}
+}
+
+// Client returns the current client, or nil while disconnected.
+func (c *Connector) Client() Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cln
+}
+
+// Subscribe subscribes to the notifications, or indications if ind is set,
+// of the first characteristic with the UUID u. The subscription is made now
+// if connected, and again on every reconnection.
+func (c *Connector) Subscribe(u UUID, ind bool, h NotificationHandler) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subs = append(c.subs, subscription{u: u, ind: ind, h: h})
+	if c.cln == nil {
+		return nil
+	}
+	return subscribe(c.cln, c.subs[len(c.subs)-1])
+}
+
+// Unsubscribe removes the subscription made by Subscribe.
+func (c *Connector) Unsubscribe(u UUID, ind bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, s := range c.subs {
+		if s.u.Equal(u) && s.ind == ind {
+			c.subs = append(c.subs[:i], c.subs[i+1:]...)
+			break
+		}
+	}
+	if c.cln == nil {
+		return nil
+	}
+	ch := c.cln.Characteristics(u)
+	if len(ch) == 0 {
+		return nil
+	}
+	return c.cln.Unsubscribe(ch[0], ind)
+}
+
+// Run connects, and reconnects whenever the connection is lost, until ctx
+// is done, or MaxAttempts consecutive attempts failed. The connection is
+// closed when Run returns. The backoffs are timed by the Clock of ctx.
+func (c *Connector) Run(ctx context.Context) error {
+	defer c.setState(StateStopped, nil)
+	clock := ClockFromContext(ctx)
+	failures := 0
+	for {
+		c.setState(StateConnecting, nil)
+		cln, err := c.connect(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failures++
+			c.setState(StateDisconnected, err)
+			if c.policy.MaxAttempts > 0 && failures >= c.policy.MaxAttempts {
+				return wrap(err, fmt.Sprintf("can't reconnect after %d attempts", failures))
+			}
+		} else {
+			failures = 0
+			c.setState(StateConnected, nil)
+			select {
+			case <-cln.Disconnected():
+				c.setClient(nil)
+				c.setState(StateDisconnected, nil)
+			case <-ctx.Done():
+				c.setClient(nil)
+				cln.CancelConnection()
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-clock.After(c.policy.Backoff(failures)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// connect makes an attempt to connect, set up, and subscribe.
+func (c *Connector) connect(ctx context.Context) (Client, error) {
+	actx := ctx
+	if c.policy.AttemptTimeout > 0 {
+		var cancel func()
+		actx, cancel = context.WithTimeout(ctx, c.policy.AttemptTimeout)
+		defer cancel()
+	}
+	cln, err := c.dial(actx)
+	if err != nil {
+		return nil, err
+	}
+	if c.Setup != nil {
+		if err := c.Setup(cln); err != nil {
+			cln.CancelConnection()
+			return nil, wrap(err, "can't set up connection")
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.subs {
+		if err := subscribe(cln, s); err != nil {
+			cln.CancelConnection()
+			return nil, err
+		}
+	}
+	c.changeClient(cln)
+	return cln, nil
+}
+
+// subscribe subscribes to s on cln, and discovers the profile first, if the
+// characteristic isn't discovered yet.
+func subscribe(cln Client, s subscription) error {
+	ch := cln.Characteristics(s.u)
+	if len(ch) == 0 {
+		if _, err := cln.DiscoverProfile(false); err != nil {
+			return errors.Wrap(err, "can't discover profile")
+		}
+		ch = cln.Characteristics(s.u)
+	}
+	if len(ch) == 0 {
+		return errors.Errorf("can't find characteristic %s", s.u)
+	}
+	return errors.Wrapf(cln.Subscribe(ch[0], s.ind, s.h), "can't subscribe to %s", s.u)
+}
+
+func (c *Connector) setClient(cln Client) {
+	c.mu.Lock()
+	c.changeClient(cln)
+	c.mu.Unlock()
+}
+
+// changeClient sets the client, and tells Do of the change. The caller holds
+// c.mu.
+func (c *Connector) changeClient(cln Client) {
+	c.cln = cln
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *Connector) setState(s ConnState, err error) {
+	if c.OnStateChange != nil {
+		c.OnStateChange(s, err)
+	}
+}
@@ -0,0 +1,139 @@
+package ble
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	p := ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for _, c := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	} {
+		if got := p.Backoff(c.failures); got != c.want {
+			t.Errorf("Backoff(%d) = %s, want %s", c.failures, got, c.want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.Backoff(1); d <= 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("Backoff(1) with jitter = %s, want (50ms, 100ms]", d)
+		}
+	}
+}
+
+// fakeClient is connected until disconnect is called.
+type fakeClient struct {
+	Client
+	chars  []*Characteristic
+	subs   chan UUID
+	chDone chan struct{}
+}
+
+func (c *fakeClient) Characteristics(u UUID) []*Characteristic {
+	for _, ch := range c.chars {
+		if ch.UUID.Equal(u) {
+			return []*Characteristic{ch}
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) Subscribe(ch *Characteristic, ind bool, h NotificationHandler) error {
+	c.subs <- ch.UUID
+	return nil
+}
+
+func (c *fakeClient) Disconnected() <-chan struct{} { return c.chDone }
+func (c *fakeClient) CancelConnection() error       { return nil }
+
+func TestConnectorResubscribes(t *testing.T) {
+	u := UUID16(0x2A19)
+	subs := make(chan UUID, 4)
+	clients := make(chan *fakeClient, 4)
+	attempts := 0
+	dial := func(ctx context.Context) (Client, error) {
+		attempts++
+		if attempts == 2 {
+			return nil, errors.New("peer out of range")
+		}
+		c := &fakeClient{chars: []*Characteristic{NewCharacteristic(u)}, subs: subs, chDone: make(chan struct{})}
+		clients <- c
+		return c, nil
+	}
+
+	var states []ConnState
+	c := NewConnector(dial, ReconnectPolicy{InitialBackoff: time.Millisecond})
+	c.OnStateChange = func(s ConnState, err error) { states = append(states, s) }
+	if err := c.Subscribe(u, false, func([]byte) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- c.Run(ctx) }()
+
+	// The subscription is made on the first connection, and again after the
+	// failed attempt which follows the disconnection.
+	close((<-clients).chDone)
+	<-subs
+	<-clients
+	<-subs
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Run() = %v, want context.Canceled", err)
+	}
+	want := []ConnState{StateConnecting, StateConnected, StateDisconnected, StateConnecting,
+		StateDisconnected, StateConnecting, StateConnected, StateStopped}
+	if len(states) != len(want) {
+		t.Fatalf("states = %v, want %v", states, want)
+	}
+	for i := range want {
+		if states[i] != want[i] {
+			t.Fatalf("states = %v, want %v", states, want)
+		}
+	}
+}
+
+func TestConnectorGivesUp(t *testing.T) {
+	dial := func(ctx context.Context) (Client, error) { return nil, errors.New("peer out of range") }
+	c := NewConnector(dial, ReconnectPolicy{InitialBackoff: time.Millisecond, MaxAttempts: 3})
+	if err := c.Run(context.Background()); err == nil {
+		t.Fatal("Run() = nil, want an error after 3 attempts")
+	}
+}
+
+func TestConnectorDo(t *testing.T) {
+	dial := func(ctx context.Context) (Client, error) {
+		return &fakeClient{chDone: make(chan struct{})}, nil
+	}
+	c := NewConnector(dial, ReconnectPolicy{InitialBackoff: time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Run(ctx)
+
+	// The connection is lost during the first call.
+	var clns []Client
+	err := c.Do(ctx, func(cln Client) error {
+		clns = append(clns, cln)
+		if len(clns) == 1 {
+			close(cln.(*fakeClient).chDone)
+			return wrap(ErrDisconnected, "read failed")
+		}
+		return nil
+	})
+	if err != nil || len(clns) != 2 || clns[0] == clns[1] {
+		t.Errorf("Do() = %v after %d calls, want nil after a call on each of 2 connections", err, len(clns))
+	}
+}
@@ -0,0 +1,14 @@
+package ble
+
+// RemoteInfo is the version and the LE features of the controller of a peer,
+// as read on connect with OptRemoteInfo, for interop workarounds keyed on
+// peer stacks.
+type RemoteInfo struct {
+	VersionKnown bool   // The version was read.
+	Version      uint8  // Version of the Core Specification, such as 0x09 for 5.0.
+	Manufacturer uint16 // Company identifier of the manufacturer of the controller.
+	Subversion   uint16 // Revision of the controller, specific to the manufacturer.
+
+	FeaturesKnown bool   // The features were read.
+	Features      uint64 // LE features of the link, as the bits of the LE Features. [Vol 6, Part B, 4.6]
+}
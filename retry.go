@@ -0,0 +1,71 @@
+package ble
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// RetryPolicy configures how the ATT requests of a Client are retried, when
+// they fail. Only the idempotent requests are, i.e. the reads, the
+// discoveries, and the writes of the CCCDs, unless Writes is set. The zero
+// value doesn't retry.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts of each request, including the first; 1 if 0.
+	Backoff     time.Duration // Delay before each retry, which doubles on every one.
+	Writes      bool          // Retry the writes of the characteristics too, which the server may have performed already.
+
+	// Retryable reports whether a request is retried after failing with err,
+	// as DefaultRetryable does if nil. A Client, which is disconnected, can't
+	// be retried; a Connector dials again.
+	Retryable func(err error) bool
+}
+
+// Retry reports whether a request, which failed with err on the given
+// attempt, counting from 1, is attempted again, and after what delay.
+func (p RetryPolicy) Retry(err error, attempt int) (time.Duration, bool) {
+	if err == nil || attempt >= p.MaxAttempts || errors.Is(err, ErrDisconnected) {
+		return 0, false
+	}
+	f := p.Retryable
+	if f == nil {
+		f = DefaultRetryable
+	}
+	if !f(err) {
+		return 0, false
+	}
+	return p.Backoff << uint(attempt-1), true
+}
+
+// DefaultRetryable retries the requests, which the server lacked the
+// resources for at the time, or failed unexpectedly.
+func DefaultRetryable(err error) bool {
+	return errors.Is(err, ErrInsuffResources) || errors.Is(err, ErrUnlikely)
+}
+
+// Do calls f with the client of the connection, and calls it again, with the
+// client of the next one, if it fails as the connection is lost meanwhile, so
+// that the operations of f survive short disconnections. While disconnected,
+// Do waits for the connection. It returns once f succeeds, or fails
+// otherwise, or ctx is done. f may be called more than once, so it's to be
+// idempotent, such as reads and the writes of CCCDs.
+func (c *Connector) Do(ctx context.Context, f func(cln Client) error) error {
+	var lost Client
+	for {
+		c.mu.Lock()
+		cln, changed := c.cln, c.changed
+		c.mu.Unlock()
+		if cln == nil || cln == lost {
+			select {
+			case <-changed:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err := f(cln); !errors.Is(err, ErrDisconnected) {
+			return err
+		}
+		lost = cln
+	}
+}
@@ -0,0 +1,46 @@
+package ble
+
+import "fmt"
+
+// A Role is the GAP role, or roles, a device is set up for. [Vol 3, Part C, 2.2.2]
+type Role int
+
+// Roles of a device.
+const (
+	RoleBoth       Role = iota // Central and peripheral, as far as the controller supports it.
+	RoleCentral                // Scanning and dialing only.
+	RolePeripheral             // Advertising and accepting connections only.
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleBoth:
+		return "both"
+	case RoleCentral:
+		return "central"
+	case RolePeripheral:
+		return "peripheral"
+	}
+	return "unknown"
+}
+
+// Central reports whether r includes the central role.
+func (r Role) Central() bool {
+	return r == RoleBoth || r == RoleCentral
+}
+
+// Peripheral reports whether r includes the peripheral role.
+func (r Role) Peripheral() bool {
+	return r == RoleBoth || r == RolePeripheral
+}
+
+// A RoleError is returned by the methods of a role, which the device isn't
+// set up for.
+type RoleError struct {
+	Op   string // Method which was called.
+	Role Role   // Role of the device.
+}
+
+func (e *RoleError) Error() string {
+	return fmt.Sprintf("%s isn't available to a %s-only device", e.Op, e.Role)
+}
@@ -0,0 +1,201 @@
+package ble
+
+import (
+	"math"
+	"strings"
+	"sync"
+)
+
+// An RSSIFilter smooths the RSSI of a device, as it's measured.
+type RSSIFilter interface {
+	// Update adds a measured RSSI, in dBm, and returns the smoothed one.
+	Update(rssi float64) float64
+}
+
+// NewEWMAFilter returns an RSSIFilter of the exponentially weighted moving
+// average, which weighs each measurement by alpha, from 0 to 1. The lower
+// alpha, the smoother, and the slower to follow a moving device.
+func NewEWMAFilter(alpha float64) RSSIFilter {
+	return &ewmaFilter{alpha: alpha}
+}
+
+type ewmaFilter struct {
+	alpha float64
+	v     float64
+	init  bool
+}
+
+func (f *ewmaFilter) Update(rssi float64) float64 {
+	if !f.init {
+		f.v, f.init = rssi, true
+		return f.v
+	}
+	f.v += f.alpha * (rssi - f.v)
+	return f.v
+}
+
+// NewKalmanFilter returns an RSSIFilter of a one-dimensional Kalman filter,
+// of the variance q the RSSI changes by between the measurements, as the
+// device moves, and the variance r of the noise of a measurement. Typical
+// values are 0.01 and 4 of a device at rest, and larger q as it moves.
+func NewKalmanFilter(q, r float64) RSSIFilter {
+	return &kalmanFilter{q: q, r: r}
+}
+
+type kalmanFilter struct {
+	q, r float64
+	x, p float64 // Estimate, and its variance.
+	init bool
+}
+
+func (f *kalmanFilter) Update(rssi float64) float64 {
+	if !f.init {
+		f.x, f.p, f.init = rssi, f.r, true
+		return f.x
+	}
+	f.p += f.q
+	k := f.p / (f.p + f.r)
+	f.x += k * (rssi - f.x)
+	f.p *= 1 - k
+	return f.x
+}
+
+// A DistanceEstimator estimates the distance of a device, in meters, from its
+// RSSI.
+type DistanceEstimator interface {
+	Distance(rssi float64) float64
+}
+
+// PathLoss is the DistanceEstimator of the log-distance path loss model,
+// calibrated with the RSSI measured at 1 m from the device.
+type PathLoss struct {
+	MeasuredPower float64 // RSSI at 1 m, in dBm, such as the Measured Power of iBeacons.
+	Exponent      float64 // Path loss exponent; 2 in free space, up to 4 indoors; 2 if 0.
+}
+
+// DefaultPathLossExponent is the Exponent of a PathLoss, if 0.
+const DefaultPathLossExponent = 2
+
+// Distance returns the distance, at which the RSSI is rssi.
+func (p PathLoss) Distance(rssi float64) float64 {
+	n := p.Exponent
+	if n <= 0 {
+		n = DefaultPathLossExponent
+	}
+	return math.Pow(10, (p.MeasuredPower-rssi)/(10*n))
+}
+
+// PathLossFromTxPower returns the PathLoss of a device, which advertises its
+// TX Power Level, the power at 0 m, in dBm, assuming the free space loss of
+// 41 dB at 1 m of the 2.4 GHz band.
+func PathLossFromTxPower(txPower int) PathLoss {
+	return PathLoss{MeasuredPower: float64(txPower) - 41}
+}
+
+// CalibrateMeasuredPower returns the MeasuredPower of a PathLoss, from the
+// RSSIs measured at 1 m from a device: their mean, once the furthest tenth of
+// the outliers are dropped.
+func CalibrateMeasuredPower(rssis []int) float64 {
+	if len(rssis) == 0 {
+		return 0
+	}
+	var mean float64
+	for _, v := range rssis {
+		mean += float64(v)
+	}
+	mean /= float64(len(rssis))
+	vs := append([]int(nil), rssis...)
+	for drop := len(vs) / 10; drop > 0; drop-- {
+		far := 0
+		for i, v := range vs {
+			if math.Abs(float64(v)-mean) > math.Abs(float64(vs[far])-mean) {
+				far = i
+			}
+		}
+		vs = append(vs[:far], vs[far+1:]...)
+	}
+	var sum float64
+	for _, v := range vs {
+		sum += float64(v)
+	}
+	return sum / float64(len(vs))
+}
+
+// An RSSIReading is an advertisement, along with the smoothed RSSI of the
+// advertiser, and its estimated distance.
+type RSSIReading struct {
+	Advertisement
+	Smoothed float64 // Smoothed RSSI, in dBm.
+	Distance float64 // Estimated distance, in meters, or 0 without a DistanceEstimator.
+}
+
+// An RSSITracker smooths the RSSI of each advertiser of a scan, and estimates
+// its distance. It is safe for concurrent use.
+type RSSITracker struct {
+	newFilter func() RSSIFilter
+	est       DistanceEstimator
+
+	mu    sync.Mutex
+	peers map[string]*rssiPeer
+}
+
+type rssiPeer struct {
+	f    RSSIFilter
+	last RSSIReading
+}
+
+// NewRSSITracker returns an RSSITracker, which smooths the RSSI of each
+// advertiser with a filter of newFilter, and estimates the distance with est,
+// unless it's nil.
+func NewRSSITracker(newFilter func() RSSIFilter, est DistanceEstimator) *RSSITracker {
+	return &RSSITracker{newFilter: newFilter, est: est, peers: make(map[string]*rssiPeer)}
+}
+
+// Add adds the RSSI of the advertisement a, and returns the reading of the
+// advertiser.
+func (t *RSSITracker) Add(a Advertisement) RSSIReading {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := strings.ToLower(a.Addr().String())
+	p, ok := t.peers[k]
+	if !ok {
+		p = &rssiPeer{f: t.newFilter()}
+		t.peers[k] = p
+	}
+	r := RSSIReading{Advertisement: a, Smoothed: p.f.Update(float64(a.RSSI()))}
+	if t.est != nil {
+		r.Distance = t.est.Distance(r.Smoothed)
+	}
+	p.last = r
+	return r
+}
+
+// Handler returns an AdvHandler, which adds the advertisements, and then
+// passes their readings to next, unless it is nil.
+func (t *RSSITracker) Handler(next func(r RSSIReading)) AdvHandler {
+	return func(a Advertisement) {
+		r := t.Add(a)
+		if next != nil {
+			next(r)
+		}
+	}
+}
+
+// Last returns the last reading of the advertiser a, if any.
+func (t *RSSITracker) Last(a Addr) (RSSIReading, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.peers[strings.ToLower(a.String())]
+	if !ok {
+		return RSSIReading{}, false
+	}
+	return p.last, true
+}
+
+// Forget drops the filter of the advertiser a, so its RSSI is smoothed from
+// scratch, once it's heard again.
+func (t *RSSITracker) Forget(a Addr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.peers, strings.ToLower(a.String()))
+}
@@ -0,0 +1,64 @@
+package ble
+
+import (
+	"math"
+	"testing"
+)
+
+type rssiAdv struct {
+	testAdv
+	rssi int
+}
+
+func (a *rssiAdv) RSSI() int { return a.rssi }
+
+func TestRSSIFilters(t *testing.T) {
+	for name, f := range map[string]RSSIFilter{
+		"ewma":   NewEWMAFilter(0.2),
+		"kalman": NewKalmanFilter(0.01, 4),
+	} {
+		// A device at rest, measured around -60 dBm, and once at -80.
+		var v float64
+		for i, rssi := range []float64{-60, -62, -58, -80, -61, -59, -60} {
+			v = f.Update(rssi)
+			if i == 0 && v != -60 {
+				t.Errorf("%s: first Update() = %v, want the measurement", name, v)
+			}
+		}
+		if v < -66 || v > -60 {
+			t.Errorf("%s: smoothed %v, want near -60", name, v)
+		}
+	}
+}
+
+func TestPathLoss(t *testing.T) {
+	p := PathLoss{MeasuredPower: -59}
+	if d := p.Distance(-59); d != 1 {
+		t.Errorf("Distance(-59) = %v, want 1", d)
+	}
+	if d := p.Distance(-79); math.Abs(d-10) > 1e-9 {
+		t.Errorf("Distance(-79) = %v, want 10", d)
+	}
+	if m := CalibrateMeasuredPower([]int{-59, -60, -58, -59, -60, -58, -59, -60, -58, -89}); m != -59 {
+		t.Errorf("CalibrateMeasuredPower() = %v, want -59 without the outlier", m)
+	}
+}
+
+func TestRSSITracker(t *testing.T) {
+	tr := NewRSSITracker(func() RSSIFilter { return NewEWMAFilter(0.5) }, PathLoss{MeasuredPower: -59})
+	a := NewAddr("11:22:33:44:55:66")
+	var last RSSIReading
+	h := tr.Handler(func(r RSSIReading) { last = r })
+	h(&rssiAdv{testAdv{addr: a}, -59})
+	h(&rssiAdv{testAdv{addr: a}, -79})
+	if last.Smoothed != -69 {
+		t.Errorf("smoothed %v, want -69", last.Smoothed)
+	}
+	if r, ok := tr.Last(a); !ok || r.Distance != (PathLoss{MeasuredPower: -59}).Distance(-69) {
+		t.Errorf("Last() = %+v, %v", r, ok)
+	}
+	tr.Forget(a)
+	if _, ok := tr.Last(a); ok {
+		t.Error("Last() after Forget")
+	}
+}
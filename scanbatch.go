@@ -0,0 +1,225 @@
+package ble
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultScanBatchInterval is the cadence, at which ScanBatches delivers the
+// batches.
+const DefaultScanBatchInterval = 100 * time.Millisecond
+
+// DefaultScanBatchSize is the most advertisements of a batch of ScanBatches.
+const DefaultScanBatchSize = 256
+
+// A BatchHandler handles a batch of advertisements. The batch is reused once
+// it returns, so it mustn't be retained, though its advertisements may.
+type BatchHandler func(ads []Advertisement)
+
+// ScanBatchConfig configures a scan of ScanBatches. The zero value scans with
+// filtering of duplicates, delivering batches of up to DefaultScanBatchSize
+// advertisements every DefaultScanBatchInterval to a single worker.
+type ScanBatchConfig struct {
+	Interval time.Duration // Cadence of the batches; DefaultScanBatchInterval if 0.
+	Size     int           // Most advertisements of a batch, which is delivered early once full; DefaultScanBatchSize if 0.
+	Workers  int           // Goroutines handling the batches; 1 if 0.
+	Queue    int           // Batches waiting for a worker, beyond which they're dropped; Workers if 0.
+	AllowDup bool          // Reports the duplicate advertisements.
+
+	// ErrHandler, if set, is called with the error of the scan, which ends
+	// other than by ctx, before the workers are done.
+	ErrHandler func(err error)
+}
+
+// ScanBatchStats are the counters of a scan of ScanBatches.
+type ScanBatchStats struct {
+	Received  uint64 // Advertisements passing the filter.
+	Delivered uint64 // Advertisements handed to the workers.
+	Dropped   uint64 // Advertisements dropped, as the batch, or the queue, was full.
+	Batches   uint64 // Batches handed to the workers.
+}
+
+// A BatchScan is a scan of ScanBatches.
+type BatchScan struct {
+	cfg     ScanBatchConfig
+	h       BatchHandler
+	metrics MetricsCollector
+
+	mu   sync.Mutex
+	cur  []Advertisement // Batch collecting the advertisements.
+	full chan struct{}   // Tells the flusher that cur is full.
+
+	queue chan []Advertisement
+	free  chan []Advertisement // Batches handled, to reuse.
+	done  chan struct{}
+
+	received, delivered, dropped, batches uint64 // Guarded by atomic.
+}
+
+// ScanBatches scans on d, or the default device if d is nil, for the dense
+// environments of hundreds of advertisers a second: the advertisements
+// passing f are collected, without a goroutine, or a call of a handler, each,
+// and handed in batches to a pool of workers calling h, as configured by cfg.
+// The batches are reused, so the scan allocates little once started. The
+// batches, which the queue has no room for, and the advertisements, which a
+// full batch has no room for until it's handed over, are dropped, and
+// counted by Stats, and as MetricAdvDropped, by the collector of ctx. The
+// scan ends once ctx is done, with the batch collected delivered.
+func ScanBatches(ctx context.Context, d Device, f AdvFilter, cfg ScanBatchConfig, h BatchHandler) (*BatchScan, error) {
+	if d == nil {
+		d = defaultDevice
+	}
+	if d == nil {
+		return nil, ErrDefaultDevice
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultScanBatchInterval
+	}
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultScanBatchSize
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.Queue <= 0 {
+		cfg.Queue = cfg.Workers
+	}
+	s := &BatchScan{
+		cfg:     cfg,
+		h:       h,
+		metrics: MetricsFromContext(ctx),
+		cur:     make([]Advertisement, 0, cfg.Size),
+		full:    make(chan struct{}, 1),
+		queue:   make(chan []Advertisement, cfg.Queue),
+		free:    make(chan []Advertisement, cfg.Queue+cfg.Workers+1),
+		done:    make(chan struct{}),
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work()
+		}()
+	}
+	adv := s.add
+	if f != nil {
+		adv = func(a Advertisement) {
+			if f(a) {
+				s.add(a)
+			}
+		}
+	}
+	scanned := make(chan struct{})
+	go func() {
+		err := d.Scan(ctx, cfg.AllowDup, adv)
+		if err != nil && ctx.Err() == nil && cfg.ErrHandler != nil {
+			cfg.ErrHandler(err)
+		}
+		close(scanned)
+	}()
+	go func() {
+		t := time.NewTicker(cfg.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+			case <-s.full:
+			case <-scanned:
+				s.flush()
+				close(s.queue)
+				wg.Wait()
+				close(s.done)
+				return
+			}
+			s.flush()
+		}
+	}()
+	return s, nil
+}
+
+// Done returns a channel, which is closed once the scan has ended, and the
+// workers are done.
+func (s *BatchScan) Done() <-chan struct{} { return s.done }
+
+// Stats returns the counters of the scan.
+func (s *BatchScan) Stats() ScanBatchStats {
+	return ScanBatchStats{
+		Received:  atomic.LoadUint64(&s.received),
+		Delivered: atomic.LoadUint64(&s.delivered),
+		Dropped:   atomic.LoadUint64(&s.dropped),
+		Batches:   atomic.LoadUint64(&s.batches),
+	}
+}
+
+// add collects a, and tells the flusher once the batch is full. The
+// advertisements beyond it, which the flusher hasn't made room for yet, are
+// dropped.
+func (s *BatchScan) add(a Advertisement) {
+	atomic.AddUint64(&s.received, 1)
+	s.mu.Lock()
+	if len(s.cur) == cap(s.cur) {
+		s.mu.Unlock()
+		atomic.AddUint64(&s.dropped, 1)
+		s.metrics.Add(MetricAdvDropped, 1)
+		return
+	}
+	s.cur = append(s.cur, a)
+	full := len(s.cur) == cap(s.cur)
+	s.mu.Unlock()
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flush hands the batch collected to the workers, or drops it, if the queue
+// is full.
+func (s *BatchScan) flush() {
+	var next []Advertisement
+	select {
+	case next = <-s.free:
+	default:
+		next = make([]Advertisement, 0, s.cfg.Size)
+	}
+	s.mu.Lock()
+	b := s.cur
+	if len(b) == 0 {
+		s.mu.Unlock()
+		s.recycle(next)
+		return
+	}
+	s.cur = next
+	s.mu.Unlock()
+	select {
+	case s.queue <- b:
+		atomic.AddUint64(&s.delivered, uint64(len(b)))
+		atomic.AddUint64(&s.batches, 1)
+	default:
+		atomic.AddUint64(&s.dropped, uint64(len(b)))
+		s.metrics.Add(MetricAdvDropped, int64(len(b)))
+		s.recycle(b)
+	}
+}
+
+func (s *BatchScan) work() {
+	for b := range s.queue {
+		s.h(b)
+		s.recycle(b)
+	}
+}
+
+// recycle keeps b to be reused, with its advertisements released.
+func (s *BatchScan) recycle(b []Advertisement) {
+	for i := range b {
+		b[i] = nil
+	}
+	select {
+	case s.free <- b[:0]:
+	default:
+	}
+}
@@ -0,0 +1,75 @@
+package ble
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// burstDevice reports n advertisements at once, as it scans.
+type burstDevice struct {
+	Device
+	n int
+}
+
+type burstAdv struct {
+	Advertisement
+	i int
+}
+
+func (d *burstDevice) Scan(ctx context.Context, allowDup bool, h AdvHandler) error {
+	for i := 0; i < d.n; i++ {
+		h(&burstAdv{i: i})
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestScanBatches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	var got, batches int
+	even := func(a Advertisement) bool { return a.(*burstAdv).i%2 == 0 }
+	s, err := ScanBatches(ctx, &burstDevice{n: 1000}, even, ScanBatchConfig{Size: 100, Workers: 4, Queue: 10}, func(ads []Advertisement) {
+		mu.Lock()
+		defer mu.Unlock()
+		got += len(ads)
+		batches++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-s.Done()
+	st := s.Stats()
+	if st.Received != 500 || st.Delivered+st.Dropped != 500 || uint64(got) != st.Delivered || uint64(batches) != st.Batches {
+		t.Errorf("stats %+v, handled %d advertisements in %d batches", st, got, batches)
+	}
+	if st.Delivered == 0 {
+		t.Error("no advertisement delivered")
+	}
+
+	// A stalled worker drops the batches beyond the queue.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	var handled int64
+	s, err = ScanBatches(ctx, &burstDevice{n: 50}, nil, ScanBatchConfig{Size: 10, Interval: time.Millisecond}, func(ads []Advertisement) {
+		<-release
+		atomic.AddInt64(&handled, int64(len(ads)))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	cancel()
+	<-s.Done()
+	st = s.Stats()
+	if st.Received != 50 || st.Dropped == 0 || st.Delivered+st.Dropped != 50 || uint64(handled) != st.Delivered {
+		t.Errorf("stats %+v, handled %d", st, handled)
+	}
+}
@@ -0,0 +1,137 @@
+package ble
+
+import (
+	"context"
+	"sync"
+)
+
+// ScanDropPolicy is what a scan of ScanChan does with the advertisements,
+// which the channel has no room for.
+type ScanDropPolicy int
+
+// ScanDropPolicies.
+const (
+	// DropOldest drops the oldest advertisement in the channel, so that it
+	// holds the latest ones.
+	DropOldest ScanDropPolicy = iota
+
+	// DropNewest drops the advertisement, which the channel has no room for.
+	DropNewest
+
+	// DropNone blocks the handler of the scan until the advertisement is
+	// received. The reports of the controller back up meanwhile.
+	DropNone
+)
+
+// DefaultScanChanSize is the capacity of the channels of ScanChan.
+const DefaultScanChanSize = 64
+
+// ScanChanConfig configures a scan of ScanChan. The zero value scans with
+// filtering of duplicates, into a channel of DefaultScanChanSize, which drops
+// the oldest advertisements when full.
+type ScanChanConfig struct {
+	Size     int            // Capacity of the channel; DefaultScanChanSize if 0.
+	Drop     ScanDropPolicy // What to do when the channel is full.
+	AllowDup bool           // Reports the duplicate advertisements.
+
+	// ErrHandler, if set, is called with the error of the scan, which ends
+	// other than by ctx, before the channel is closed.
+	ErrHandler func(err error)
+}
+
+// ScanChan scans on the default device, and returns a channel, which
+// receives the advertisements passing f, with the zero ScanChanConfig. The
+// channel is closed once ctx is done.
+func ScanChan(ctx context.Context, f AdvFilter) (<-chan Advertisement, error) {
+	return ScanChanWithConfig(ctx, nil, f, ScanChanConfig{})
+}
+
+// ScanChanWithConfig scans on d, or the default device if d is nil, and
+// returns a channel, which receives the advertisements passing f, as
+// configured by cfg. The channel is closed once the scan ends. The
+// advertisements dropped are counted as MetricAdvDropped, by the collector of
+// ctx.
+func ScanChanWithConfig(ctx context.Context, d Device, f AdvFilter, cfg ScanChanConfig) (<-chan Advertisement, error) {
+	if d == nil {
+		d = defaultDevice
+	}
+	if d == nil {
+		return nil, ErrDefaultDevice
+	}
+	n := cfg.Size
+	if n <= 0 {
+		n = DefaultScanChanSize
+	}
+	s := &scanChan{
+		ch:      make(chan Advertisement, n),
+		stop:    make(chan struct{}),
+		drop:    cfg.Drop,
+		metrics: MetricsFromContext(ctx),
+	}
+	h := s.send
+	if f != nil {
+		h = func(a Advertisement) {
+			if f(a) {
+				s.send(a)
+			}
+		}
+	}
+	go func() {
+		err := d.Scan(ctx, cfg.AllowDup, h)
+		if err != nil && ctx.Err() == nil && cfg.ErrHandler != nil {
+			cfg.ErrHandler(err)
+		}
+		s.close()
+	}()
+	return s.ch, nil
+}
+
+// scanChan passes the advertisements of a scan to a channel.
+type scanChan struct {
+	mu      sync.Mutex
+	ch      chan Advertisement
+	closed  bool
+	stop    chan struct{} // Closed once the scan ends, to release a blocked send.
+	drop    ScanDropPolicy
+	metrics MetricsCollector
+}
+
+func (s *scanChan) send(a Advertisement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- a:
+		return
+	default:
+	}
+	switch s.drop {
+	case DropNone:
+		select {
+		case s.ch <- a:
+		case <-s.stop:
+		}
+		return
+	case DropOldest:
+		// The receivers may only make room, so a single retry does.
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- a:
+		default:
+		}
+	}
+	s.metrics.Add(MetricAdvDropped, 1)
+}
+
+func (s *scanChan) close() {
+	close(s.stop)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.ch)
+}
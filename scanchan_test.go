@@ -0,0 +1,42 @@
+package ble_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestScanChan(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	f := gatttest.New(ctx, t, 10*time.Millisecond)
+	defer f.Stop()
+
+	m := ble.NewMetrics()
+	scanCtx, stopScan := context.WithCancel(context.WithValue(ctx, ble.ContextKeyMetrics, m))
+	defer stopScan()
+	filter := func(a ble.Advertisement) bool { return a.LocalName() == gatttest.PeripheralName }
+	ch, err := ble.ScanChanWithConfig(scanCtx, f.Central, filter, ble.ScanChanConfig{Size: 1, Drop: ble.DropNewest, AllowDup: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The channel of one fills up while it isn't read.
+	for m.Value(ble.MetricAdvDropped) == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("no advertisement dropped")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if a := <-ch; a.LocalName() != gatttest.PeripheralName {
+		t.Errorf("LocalName() = %q, want %s", a.LocalName(), gatttest.PeripheralName)
+	}
+	stopScan()
+	for range ch {
+	}
+}
@@ -0,0 +1,64 @@
+package ble
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScanOffload selects the filtering of a scan which may be pushed to the
+// controller, to cut the host wakeups. The host does the rest.
+type ScanOffload int
+
+// ScanOffloads.
+const (
+	OffloadDuplicates    ScanOffload = 1 << iota // The duplicate filter of the controller.
+	OffloadVendorFilters                         // The vendor filters (APCF), for the ScanFilters.
+	OffloadAcceptList                            // The accept list, for ScanFilters of addresses only.
+
+	// DefaultScanOffload leaves the accept list, which the controller shares
+	// with the dialing and the advertising, alone.
+	DefaultScanOffload = OffloadDuplicates | OffloadVendorFilters
+)
+
+// A ScanPlan tells how a scan filters the advertisements: what is pushed to
+// the controller, and what is left to the host. The host matches the
+// ScanFilters either way, so an offload only saves it the wakeups.
+type ScanPlan struct {
+	ControllerDuplicates bool          // The controller filters duplicates.
+	DedupKey             DedupKey      // Key of the host's duplicate filter.
+	DedupTTL             time.Duration // TTL of the host's duplicate filter, or 0 if the host doesn't filter duplicates.
+	Filters              int           // Number of the ScanFilters.
+	VendorFilters        int           // Number of the ScanFilters offloaded to the vendor filters.
+	AcceptList           []Addr        // Addresses in the accept list, to which the controller limits the reports.
+}
+
+func (p ScanPlan) String() string {
+	var c, h []string
+	if p.ControllerDuplicates {
+		c = append(c, "duplicates")
+	}
+	if p.VendorFilters > 0 {
+		c = append(c, fmt.Sprintf("%d vendor filters", p.VendorFilters))
+	}
+	if len(p.AcceptList) > 0 {
+		c = append(c, fmt.Sprintf("accept list of %d", len(p.AcceptList)))
+	}
+	if p.DedupTTL > 0 {
+		k := "address"
+		if p.DedupKey == DedupByAddressAndData {
+			k = "address and data"
+		}
+		h = append(h, fmt.Sprintf("duplicates by %s for %s", k, p.DedupTTL))
+	}
+	if p.Filters > 0 {
+		h = append(h, fmt.Sprintf("%d filters", p.Filters))
+	}
+	if len(c) == 0 {
+		c = append(c, "nothing")
+	}
+	if len(h) == 0 {
+		h = append(h, "nothing")
+	}
+	return "controller: " + strings.Join(c, ", ") + "; host: " + strings.Join(h, ", ")
+}
@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/kirbo/ble"
+)
+
+// A Client reads, writes, and subscribes to the characteristics of the bound
+// fields of a struct type, on the service of a peer, and decodes them into
+// the fields.
+type Client struct {
+	cln    ble.Client
+	t      reflect.Type
+	fields []*field
+	chars  map[*field]*ble.Characteristic
+}
+
+// NewClient returns the Client of the service u of cln, whose
+// characteristics are the bound fields of the struct type of v, a struct or
+// a pointer to one. The service is discovered, unless the profile of cln has
+// it already. The characteristics, which the service lacks, fail the
+// accesses to their fields only.
+func NewClient(cln ble.Client, u ble.UUID, v interface{}) (*Client, error) {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fs, err := fields(t)
+	if err != nil {
+		return nil, err
+	}
+	var s *ble.Service
+	if p := cln.Profile(); p != nil {
+		if s = p.FindService(ble.NewService(u)); s != nil && len(s.Characteristics) == 0 {
+			s = nil
+		}
+	}
+	if s == nil {
+		p, err := ble.DiscoverPartialProfile(cln, []ble.UUID{u})
+		if err != nil {
+			return nil, err
+		}
+		if s = p.FindService(ble.NewService(u)); s == nil {
+			return nil, fmt.Errorf("service %s not found", u)
+		}
+	}
+	c := &Client{cln: cln, t: t, fields: fs, chars: map[*field]*ble.Characteristic{}}
+	for _, f := range fs {
+		for _, ch := range s.Characteristics {
+			if ch.UUID.Equal(f.uuid) {
+				c.chars[f] = ch
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *Client) char(name string) (*field, *ble.Characteristic, error) {
+	f, err := lookup(c.fields, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch, ok := c.chars[f]
+	if !ok {
+		return nil, nil, fmt.Errorf("characteristic %s of %s not found", f.uuid, name)
+	}
+	return f, ch, nil
+}
+
+func (c *Client) target(v interface{}) (reflect.Value, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return rv, err
+	}
+	if rv.Type() != c.t {
+		return rv, fmt.Errorf("%w: %s isn't %s", ErrInvalid, rv.Type(), c.t)
+	}
+	return rv, nil
+}
+
+// Read reads the readable fields, the peer has the characteristics of, into
+// the struct, which v points at.
+func (c *Client) Read(v interface{}) error {
+	for _, f := range c.fields {
+		if _, ok := c.chars[f]; !ok || f.prop&ble.CharRead == 0 {
+			continue
+		}
+		if err := c.ReadField(v, f.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadField reads the field name into the struct, which v points at.
+func (c *Client) ReadField(v interface{}, name string) error {
+	rv, err := c.target(v)
+	if err != nil {
+		return err
+	}
+	f, ch, err := c.char(name)
+	if err != nil {
+		return err
+	}
+	b, err := c.cln.ReadLongCharacteristic(ch)
+	if err != nil {
+		return err
+	}
+	x, err := f.decode(rv.Field(f.index).Type(), b)
+	if err != nil {
+		return err
+	}
+	rv.Field(f.index).Set(x)
+	return nil
+}
+
+// WriteField writes the field name of the struct, which v points at, without
+// response if the field is "writenr" only.
+func (c *Client) WriteField(v interface{}, name string) error {
+	rv, err := c.target(v)
+	if err != nil {
+		return err
+	}
+	f, ch, err := c.char(name)
+	if err != nil {
+		return err
+	}
+	b, err := f.encode(rv.Field(f.index))
+	if err != nil {
+		return err
+	}
+	return c.cln.WriteCharacteristic(ch, b, f.prop&ble.CharWrite == 0)
+}
+
+// Subscribe calls h with the values of the field name, of its type, as the
+// peer notifies, or indicates, them. The values, which don't decode, are
+// dropped.
+func (c *Client) Subscribe(name string, h func(value interface{})) error {
+	f, ch, err := c.char(name)
+	if err != nil {
+		return err
+	}
+	t := c.t.Field(f.index).Type
+	return c.cln.Subscribe(ch, f.prop&ble.CharNotify == 0, func(b []byte) {
+		if x, err := f.decode(t, b); err == nil {
+			h(x.Interface())
+		}
+	})
+}
+
+// Unsubscribe ends the subscription of Subscribe.
+func (c *Client) Unsubscribe(name string) error {
+	f, ch, err := c.char(name)
+	if err != nil {
+		return err
+	}
+	return c.cln.Unsubscribe(ch, f.prop&ble.CharNotify == 0)
+}
@@ -0,0 +1,240 @@
+// Package schema binds the fields of Go structs to the characteristics of a
+// GATT service by their struct tags, so that a service is declared once, and
+// then served by a Server, with the read, write and notify handlers of its
+// fields, or read by a Client, which decodes the values into the fields.
+//
+// The tag of a field names the UUID of its characteristic, and then the
+// options of it:
+//
+//	type Thermometer struct {
+//		Temperature int16   `gatt:"2a6e,read,notify"`
+//		Interval    uint16  `gatt:"2a21,read,write"`
+//		Name        string  `gatt:"2a00,read"`
+//		Config      Config  `gatt:"5e0a0002-0000-1000-8000-00805f9b34fb,read,write,json"`
+//	}
+//
+// The options are the properties, "read", "write", "writenr", "notify" and
+// "indicate", the byte order, "le", the default, or "be", and the format,
+// "json", which encodes the value as JSON. A field without properties is
+// read only. The fields without tags, or tagged "-", aren't bound.
+//
+// Without the JSON format, the fields are encoded as the SIG formats are:
+// the integers and the floats in their size, the bools in a byte, the
+// strings in UTF-8, the byte slices as they are, and the arrays and structs
+// of those, but strings and slices, field after field. The int and uint
+// types, whose size varies, aren't bound.
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kirbo/ble"
+)
+
+// ErrInvalid is the error, as told by errors.Is, of the structs, whose tags
+// or fields don't bind.
+var ErrInvalid = errors.New("schema: invalid struct")
+
+// ErrMalformed is the error, as told by errors.Is, of the values, which
+// don't decode.
+var ErrMalformed = errors.New("schema: malformed value")
+
+// A field is a field of a struct, bound to a characteristic.
+type field struct {
+	name  string
+	index int
+	uuid  ble.UUID
+	prop  ble.Property
+	order binary.ByteOrder
+	json  bool
+}
+
+// fields returns the bound fields of the struct type t.
+func fields(t reflect.Type) ([]*field, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s isn't a struct", ErrInvalid, t)
+	}
+	var fs []*field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("gatt")
+		if !ok || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("%w: field %s isn't exported", ErrInvalid, sf.Name)
+		}
+		f, err := parseTag(sf.Name, tag)
+		if err != nil {
+			return nil, err
+		}
+		f.index = i
+		if !f.json {
+			if err := check(sf.Type); err != nil {
+				return nil, fmt.Errorf("%w: field %s: %v", ErrInvalid, sf.Name, err)
+			}
+		}
+		for _, g := range fs {
+			if g.uuid.Equal(f.uuid) {
+				return nil, fmt.Errorf("%w: fields %s and %s have the UUID %s", ErrInvalid, g.name, f.name, f.uuid)
+			}
+		}
+		fs = append(fs, f)
+	}
+	if len(fs) == 0 {
+		return nil, fmt.Errorf("%w: %s has no gatt fields", ErrInvalid, t)
+	}
+	return fs, nil
+}
+
+func parseTag(name, tag string) (*field, error) {
+	opts := strings.Split(tag, ",")
+	u, err := ble.Parse(opts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: field %s: invalid UUID %q", ErrInvalid, name, opts[0])
+	}
+	f := &field{name: name, uuid: u, order: binary.LittleEndian}
+	for _, o := range opts[1:] {
+		switch strings.TrimSpace(o) {
+		case "read":
+			f.prop |= ble.CharRead
+		case "write":
+			f.prop |= ble.CharWrite
+		case "writenr":
+			f.prop |= ble.CharWriteNR
+		case "notify":
+			f.prop |= ble.CharNotify
+		case "indicate":
+			f.prop |= ble.CharIndicate
+		case "le":
+			f.order = binary.LittleEndian
+		case "be":
+			f.order = binary.BigEndian
+		case "json":
+			f.json = true
+		default:
+			return nil, fmt.Errorf("%w: field %s: unknown option %q", ErrInvalid, name, o)
+		}
+	}
+	if f.prop == 0 {
+		f.prop = ble.CharRead
+	}
+	return f, nil
+}
+
+// check tells whether the values of t are encoded without JSON.
+func check(t reflect.Type) error {
+	if t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return nil
+	}
+	if binary.Size(reflect.Zero(t).Interface()) < 0 {
+		return fmt.Errorf("%s has no fixed size", t)
+	}
+	return nil
+}
+
+// encode returns the value of the characteristic of f, of the field v.
+func (f *field) encode(v reflect.Value) ([]byte, error) {
+	if f.json {
+		return json.Marshal(v.Interface())
+	}
+	switch {
+	case v.Kind() == reflect.String:
+		return []byte(v.String()), nil
+	case v.Kind() == reflect.Slice:
+		return append([]byte{}, v.Bytes()...), nil
+	}
+	var b bytes.Buffer
+	if err := binary.Write(&b, f.order, v.Interface()); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// decode returns the field, of the type t, of the value b of the
+// characteristic of f.
+func (f *field) decode(t reflect.Type, b []byte) (reflect.Value, error) {
+	v := reflect.New(t)
+	if f.json {
+		if err := json.Unmarshal(b, v.Interface()); err != nil {
+			return v, fmt.Errorf("%w: %s: %v", ErrMalformed, f.name, err)
+		}
+		return v.Elem(), nil
+	}
+	switch {
+	case t.Kind() == reflect.String:
+		v.Elem().SetString(string(b))
+	case t.Kind() == reflect.Slice:
+		v.Elem().SetBytes(append([]byte{}, b...))
+	default:
+		if n := binary.Size(v.Elem().Interface()); len(b) != n {
+			return v, fmt.Errorf("%w: %s: %d bytes, want %d", ErrMalformed, f.name, len(b), n)
+		}
+		if err := binary.Read(bytes.NewReader(b), f.order, v.Interface()); err != nil {
+			return v, fmt.Errorf("%w: %s: %v", ErrMalformed, f.name, err)
+		}
+	}
+	return v.Elem(), nil
+}
+
+// structValue returns the struct, which the pointer v points at.
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%w: %T isn't a pointer to a struct", ErrInvalid, v)
+	}
+	return rv.Elem(), nil
+}
+
+// Marshal returns the value of the characteristic of the field name of the
+// struct v, or of the struct which v points at.
+func Marshal(v interface{}, name string) ([]byte, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	fs, err := fields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	f, err := lookup(fs, name)
+	if err != nil {
+		return nil, err
+	}
+	return f.encode(rv.Field(f.index))
+}
+
+// Unmarshal decodes the value b of the characteristic of the field name into
+// the field of the struct, which v points at.
+func Unmarshal(b []byte, v interface{}, name string) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	fs, err := fields(rv.Type())
+	if err != nil {
+		return err
+	}
+	f, err := lookup(fs, name)
+	if err != nil {
+		return err
+	}
+	x, err := f.decode(rv.Field(f.index).Type(), b)
+	if err != nil {
+		return err
+	}
+	rv.Field(f.index).Set(x)
+	return nil
+}
+
+func lookup(fs []*field, name string) (*field, error) {
+	for _, f := range fs {
+		if f.name == name {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no gatt field %s", ErrInvalid, name)
+}
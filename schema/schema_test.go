@@ -0,0 +1,154 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/mock"
+)
+
+type config struct {
+	Mode string `json:"mode"`
+}
+
+type sensor struct {
+	Temperature int16  `gatt:"2a6e,read,notify"`
+	Interval    uint16 `gatt:"2a21,be,read,write"`
+	Name        string `gatt:"2a00"`
+	Config      config `gatt:"5e0a0002-0000-1000-8000-00805f9b34fb,read,write,json"`
+	Ignored     int
+}
+
+var sensorUUID = ble.MustParse("5e0a0001-0000-1000-8000-00805f9b34fb")
+
+func TestCodec(t *testing.T) {
+	s := sensor{Temperature: -2, Interval: 0x0102, Name: "probe", Config: config{Mode: "fast"}}
+	for _, c := range []struct {
+		name string
+		want []byte
+	}{
+		{"Temperature", []byte{0xFE, 0xFF}},
+		{"Interval", []byte{0x01, 0x02}},
+		{"Name", []byte("probe")},
+		{"Config", []byte(`{"mode":"fast"}`)},
+	} {
+		b, err := Marshal(s, c.name)
+		if err != nil || !bytes.Equal(b, c.want) {
+			t.Errorf("Marshal(%s) = %x, %v, want %x", c.name, b, err, c.want)
+		}
+		var got sensor
+		if err := Unmarshal(b, &got, c.name); err != nil {
+			t.Errorf("Unmarshal(%s): %v", c.name, err)
+		}
+	}
+	var got sensor
+	if err := Unmarshal([]byte{1}, &got, "Interval"); !errors.Is(err, ErrMalformed) {
+		t.Errorf("short value: %v, want ErrMalformed", err)
+	}
+
+	for _, v := range []interface{}{
+		&struct{ A int }{},
+		&struct {
+			A int `gatt:"2a00"`
+		}{},
+		&struct {
+			A uint8 `gatt:"nope"`
+		}{},
+		&struct {
+			A uint8 `gatt:"2a00,bogus"`
+		}{},
+		&struct {
+			A uint8 `gatt:"2a00"`
+			B uint8 `gatt:"2a00"`
+		}{},
+	} {
+		if _, err := NewServer(sensorUUID, v); !errors.Is(err, ErrInvalid) {
+			t.Errorf("NewServer(%T): %v, want ErrInvalid", v, err)
+		}
+	}
+}
+
+func TestServerAndClient(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n := mock.NewNetwork(10 * time.Millisecond)
+	p, err := n.NewDevice("11:22:33:44:55:66", "Peripheral")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c, err := n.NewDevice("66:55:44:33:22:11", "Central")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+
+	v := &sensor{Temperature: 21, Interval: 10, Name: "probe"}
+	srv, err := NewServer(sensorUUID, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan string, 1)
+	srv.OnWrite = func(name string) { written <- name }
+	if err := p.AddService(srv.Service); err != nil {
+		t.Fatal(err)
+	}
+	go p.AdvertiseNameAndServices(ctx, "Peripheral", sensorUUID)
+
+	cln, err := c.Dial(ctx, p.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cln.CancelConnection()
+	sc, err := NewClient(cln, sensorUUID, sensor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got sensor
+	if err := sc.Read(&got); err != nil {
+		t.Fatal(err)
+	}
+	if want := *v; got != want {
+		t.Fatalf("read %+v, want %+v", got, want)
+	}
+
+	got.Interval, got.Config.Mode = 60, "slow"
+	for _, name := range []string{"Interval", "Config"} {
+		if err := sc.WriteField(&got, name); err != nil {
+			t.Fatal(err)
+		}
+		if w := <-written; w != name {
+			t.Errorf("written %s, want %s", w, name)
+		}
+	}
+	srv.Update(func() {
+		if v.Interval != 60 || v.Config.Mode != "slow" {
+			t.Errorf("served %+v after the writes", *v)
+		}
+	})
+
+	temps := make(chan int16, 1)
+	if err := sc.Subscribe("Temperature", func(x interface{}) { temps <- x.(int16) }); err != nil {
+		t.Fatal(err)
+	}
+	// Retried, as the subscription is served once the CCCD is written.
+	for i := int16(22); ; i++ {
+		srv.Update(func() { v.Temperature = i })
+		select {
+		case tmp := <-temps:
+			if tmp < 22 || tmp > i {
+				t.Fatalf("notified %d, want 22 to %d", tmp, i)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("notification not received")
+		}
+	}
+}
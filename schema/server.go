@@ -0,0 +1,156 @@
+package schema
+
+import (
+	"bytes"
+	"reflect"
+	"sync"
+
+	"github.com/kirbo/ble"
+)
+
+// A Server serves the bound fields of a struct as the characteristics of a
+// service. The reads return the fields, and the writes set them; the
+// subscribed clients are notified of the fields, which Update changes. It is
+// safe for concurrent use.
+type Server struct {
+	Service *ble.Service
+
+	// OnWrite, if set, is called with the name of each field a client
+	// writes, after the write.
+	OnWrite func(name string)
+
+	mu     sync.Mutex // Guards the struct.
+	v      reflect.Value
+	fields []*field
+	subs   map[*field]map[chan struct{}]bool
+}
+
+// NewServer returns the Server of the service u, whose characteristics are
+// the bound fields of the struct, which v points at. The struct is served as
+// it is, and must be changed within Update only.
+func NewServer(u ble.UUID, v interface{}) (*Server, error) {
+	rv, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := fields(rv.Type())
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Service: ble.NewService(u),
+		v:       rv,
+		fields:  fs,
+		subs:    map[*field]map[chan struct{}]bool{},
+	}
+	for _, f := range fs {
+		f := f
+		b := s.Service.BuildCharacteristic(f.uuid).Properties(f.prop)
+		if f.prop&ble.CharRead != 0 {
+			b.OnRead(ble.ReadHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+				s.read(f, req, rsp)
+			}))
+		}
+		if f.prop&(ble.CharWrite|ble.CharWriteNR) != 0 {
+			b.OnWrite(ble.WriteHandlerFunc(func(req ble.Request, rsp ble.ResponseWriter) {
+				s.write(f, req, rsp)
+			}))
+		}
+		if f.prop&(ble.CharNotify|ble.CharIndicate) != 0 {
+			s.subs[f] = map[chan struct{}]bool{}
+			h := ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+				s.notify(f, n)
+			})
+			if f.prop&ble.CharNotify != 0 {
+				b.OnNotify(h)
+			}
+			if f.prop&ble.CharIndicate != 0 {
+				b.OnIndicate(h)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Update calls f, which changes the struct, and then notifies the subscribed
+// clients of the fields, whose values have changed. The Server is locked
+// while f runs, so f must not call it.
+func (s *Server) Update(f func()) {
+	s.mu.Lock()
+	before := map[*field][]byte{}
+	for fd := range s.subs {
+		before[fd], _ = s.value(fd)
+	}
+	f()
+	for fd, chs := range s.subs {
+		if after, _ := s.value(fd); bytes.Equal(before[fd], after) {
+			continue
+		}
+		for ch := range chs {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+	s.mu.Unlock()
+}
+
+// value returns the value of the field f. Must be called with s.mu held.
+func (s *Server) value(f *field) ([]byte, error) {
+	return f.encode(s.v.Field(f.index))
+}
+
+func (s *Server) read(f *field, req ble.Request, rsp ble.ResponseWriter) {
+	s.mu.Lock()
+	b, err := s.value(f)
+	s.mu.Unlock()
+	switch {
+	case err != nil:
+		rsp.SetStatus(ble.ErrUnlikely)
+	case req.Offset() > len(b):
+		rsp.SetStatus(ble.ErrInvalidOffset)
+	default:
+		rsp.Write(b[req.Offset():])
+	}
+}
+
+func (s *Server) write(f *field, req ble.Request, rsp ble.ResponseWriter) {
+	x, err := f.decode(s.v.Field(f.index).Type(), req.Data())
+	if err != nil {
+		rsp.SetStatus(ble.ErrInvalAttrValueLen)
+		return
+	}
+	s.Update(func() { s.v.Field(f.index).Set(x) })
+	if s.OnWrite != nil {
+		s.OnWrite(f.name)
+	}
+}
+
+func (s *Server) notify(f *field, n ble.Notifier) {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.subs[f][ch] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs[f], ch)
+		s.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-n.Context().Done():
+			return
+		case <-ch:
+			s.mu.Lock()
+			b, err := s.value(f)
+			s.mu.Unlock()
+			if err != nil {
+				continue
+			}
+			if _, err := n.Write(b); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package ble
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SecurityLevel is the security level of an LE link, in LE Security Mode 1.
+// [Vol 3, Part C, 10.2.1]
+type SecurityLevel int
+
+// SecurityLevels.
+const (
+	SecurityUnknown         SecurityLevel = iota // The platform doesn't tell.
+	SecurityNone                                 // No authentication, no encryption.
+	SecurityEncrypted                            // Unauthenticated pairing, with encryption.
+	SecurityAuthenticated                        // Authenticated pairing, with encryption.
+	SecurityAuthenticatedSC                      // Authenticated LE Secure Connections pairing, with a 128-bit key.
+)
+
+func (l SecurityLevel) String() string {
+	switch l {
+	case SecurityNone:
+		return "none"
+	case SecurityEncrypted:
+		return "encrypted"
+	case SecurityAuthenticated:
+		return "authenticated"
+	case SecurityAuthenticatedSC:
+		return "authenticated secure connections"
+	}
+	return "unknown"
+}
+
+// Range of the encryption key size. Keys shorter than 16 bytes are
+// negotiated down by the pairing, and are open to brute force, as in the
+// KNOB attack. [Vol 3, Part H, 2.3.4]
+const (
+	MinEncKeySize = 7
+	MaxEncKeySize = 16
+)
+
+// Security is the security of a link.
+type Security struct {
+	Level             SecurityLevel
+	KeySize           int  // Size of the encryption key, in bytes, or 0 if not encrypted.
+	SecureConnections bool // LE Secure Connections pairing, rather than legacy pairing.
+}
+
+// Encrypted reports whether the link is encrypted.
+func (s Security) Encrypted() bool {
+	return s.KeySize > 0
+}
+
+// RequireKeySize returns ErrInsuffEnc if the link isn't encrypted, or
+// ErrInsuffEncrKeySize if its key is shorter than n bytes, so that
+// applications can refuse links of weakened keys.
+func (s Security) RequireKeySize(n int) error {
+	if !s.Encrypted() {
+		return ErrInsuffEnc
+	}
+	if s.KeySize < n {
+		return ErrInsuffEncrKeySize
+	}
+	return nil
+}
+
+func (s Security) String() string {
+	if !s.Encrypted() {
+		return s.Level.String()
+	}
+	return fmt.Sprintf("%s, %d byte key", s.Level, s.KeySize)
+}
+
+// ErrSCRequired is returned by SecurityPolicy.Check for links which weren't
+// paired with LE Secure Connections, when the policy requires it.
+var ErrSCRequired = errors.New("LE Secure Connections required")
+
+// A SecurityPolicy is the minimum security of the links. Zero fields
+// require nothing.
+type SecurityPolicy struct {
+	MinKeySize int  // Minimum size of the encryption key, in bytes.
+	SCOnly     bool // Only LE Secure Connections pairing; legacy pairing is refused.
+}
+
+// Check returns nil if s meets the policy, or the error of the requirement
+// it fails. Unencrypted links fail any policy but the zero one.
+func (p SecurityPolicy) Check(s Security) error {
+	if p.MinKeySize > 0 {
+		if err := s.RequireKeySize(p.MinKeySize); err != nil {
+			return err
+		}
+	}
+	if p.SCOnly && !s.SecureConnections {
+		return ErrSCRequired
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package ble
+
+import "fmt"
+
+// AdapterState is the state of the Bluetooth adapter, as CBManagerState.
+type AdapterState int
+
+// AdapterStates.
+const (
+	AdapterUnknown      AdapterState = iota // The state isn't known yet.
+	AdapterResetting                        // The adapter is resetting, and is about to be updated.
+	AdapterUnsupported                      // The platform doesn't support Bluetooth LE.
+	AdapterUnauthorized                     // The application isn't authorized to use Bluetooth LE.
+	AdapterPoweredOff                       // Bluetooth is off, or the adapter is gone.
+	AdapterPoweredOn                        // The adapter is ready.
+)
+
+func (s AdapterState) String() string {
+	switch s {
+	case AdapterResetting:
+		return "resetting"
+	case AdapterUnsupported:
+		return "unsupported"
+	case AdapterUnauthorized:
+		return "unauthorized"
+	case AdapterPoweredOff:
+		return "powered off"
+	case AdapterPoweredOn:
+		return "powered on"
+	}
+	return "unknown"
+}
+
+// An AdapterStateError is returned by the initialization of a device, whose
+// adapter isn't powered on, unless a state handler is set by OptStateHandler.
+type AdapterStateError struct {
+	State AdapterState
+}
+
+func (e *AdapterStateError) Error() string {
+	return fmt.Sprintf("adapter %s", e.State)
+}
+
+// Is tells an unauthorized adapter as ErrPermissionDenied.
+func (e *AdapterStateError) Is(target error) bool {
+	return target == ErrPermissionDenied && e.State == AdapterUnauthorized
+}
+
+// RestoredState is the state, which the platform preserved for the device
+// of a restore identifier, across relaunches of the process.
+type RestoredState struct {
+	Peripherals  []Addr // Peripherals which were connected, or being dialed, to dial again.
+	ScanServices []UUID // Services which were scanned for.
+	Services     []UUID // Services which were published, in the peripheral role.
+	Advertised   bool   // The device was advertising.
+}
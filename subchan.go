@@ -0,0 +1,188 @@
+package ble
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultSubscribeChanSize is the capacity of the channels of SubscribeChan.
+const DefaultSubscribeChanSize = 16
+
+// SubscribeChanConfig configures a subscription of SubscribeChanWithConfig.
+// The zero value passes each value notified, as it is, to a channel of
+// DefaultSubscribeChanSize, which drops the oldest values when full.
+//
+// Many devices stream the values longer than the MTU over several
+// notifications; Framer, or Gap, reassembles them, so that the channel
+// receives the values whole.
+type SubscribeChanConfig struct {
+	Size int            // Capacity of the channel; DefaultSubscribeChanSize if 0.
+	Drop ScanDropPolicy // What to do when the channel is full, as of ScanChan.
+
+	// Framer, if set, reassembles the values of the frames it decodes, such
+	// as the length prefixed ones of LengthFramer.
+	Framer Framer
+
+	// Gap, if set, and Framer isn't, joins the notifications following
+	// each other within Gap into a value, which is passed once Gap elapses
+	// without another, or once it's MaxLen long, if MaxLen > 0.
+	Gap    time.Duration
+	MaxLen int
+}
+
+// SubscribeChan subscribes to the notifications, or the indications, if ind,
+// of c, and returns a channel, which receives their values, with the zero
+// SubscribeChanConfig, and a function, which unsubscribes, and closes the
+// channel.
+func SubscribeChan(cln Client, c *Characteristic, ind bool) (<-chan []byte, func(), error) {
+	return SubscribeChanWithConfig(cln, c, ind, SubscribeChanConfig{})
+}
+
+// SubscribeChanWithConfig subscribes to the notifications, or the
+// indications, if ind, of c, and returns a channel, which receives their
+// values, reassembled as configured by cfg, and a function, which
+// unsubscribes, and closes the channel. The channel is closed too once the
+// client disconnects. The value being reassembled by Gap is passed before
+// the channel is closed; the partial frames of Framer are dropped.
+func SubscribeChanWithConfig(cln Client, c *Characteristic, ind bool, cfg SubscribeChanConfig) (<-chan []byte, func(), error) {
+	n := cfg.Size
+	if n <= 0 {
+		n = DefaultSubscribeChanSize
+	}
+	s := &subChan{
+		ch:   make(chan []byte, n),
+		stop: make(chan struct{}),
+		cfg:  cfg,
+	}
+	if err := cln.Subscribe(c, ind, s.receive); err != nil {
+		return nil, nil, err
+	}
+	go func() {
+		select {
+		case <-cln.Disconnected():
+			s.close()
+		case <-s.stop:
+		}
+	}()
+	cancel := func() {
+		if s.close() {
+			select {
+			case <-cln.Disconnected():
+			default:
+				cln.Unsubscribe(c, ind)
+			}
+		}
+	}
+	return s.ch, cancel, nil
+}
+
+// subChan passes the values of a subscription to a channel.
+type subChan struct {
+	mu     sync.Mutex
+	ch     chan []byte
+	closed bool
+	stop   chan struct{} // Closed once the subscription ends, to release a blocked send.
+	once   sync.Once
+	cfg    SubscribeChanConfig
+
+	// The value being reassembled by Gap.
+	buf   []byte
+	last  time.Time   // When the last notification was received.
+	timer *time.Timer // Passes buf, once Gap elapses; nil if not armed.
+}
+
+func (s *subChan) receive(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	switch {
+	case s.cfg.Framer != nil:
+		for _, v := range s.cfg.Framer.Decode(b) {
+			s.send(v)
+		}
+	case s.cfg.Gap > 0:
+		s.buf, s.last = append(s.buf, b...), time.Now()
+		if s.cfg.MaxLen > 0 && len(s.buf) >= s.cfg.MaxLen {
+			s.flush()
+			return
+		}
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.cfg.Gap, s.expire)
+		}
+	default:
+		s.send(append([]byte(nil), b...))
+	}
+}
+
+// expire passes the value being reassembled, once Gap has elapsed since its
+// last notification, or waits for the rest of it.
+func (s *subChan) expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.timer == nil {
+		return
+	}
+	if d := s.cfg.Gap - time.Since(s.last); d > 0 {
+		s.timer.Reset(d)
+		return
+	}
+	s.flush()
+}
+
+// flush passes the value being reassembled, with s.mu held.
+func (s *subChan) flush() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if len(s.buf) == 0 {
+		return
+	}
+	v := s.buf
+	s.buf = nil
+	s.send(v)
+}
+
+// send passes v to the channel, with s.mu held.
+func (s *subChan) send(v []byte) {
+	select {
+	case s.ch <- v:
+		return
+	default:
+	}
+	switch s.cfg.Drop {
+	case DropNone:
+		select {
+		case s.ch <- v:
+		case <-s.stop:
+		}
+	case DropOldest:
+		// The receivers may only make room, so a single retry does.
+		select {
+		case <-s.ch:
+		default:
+		}
+		select {
+		case s.ch <- v:
+		default:
+		}
+	}
+}
+
+// close passes the value being reassembled, if it may, and closes the
+// channel. It reports whether it did, the first time.
+func (s *subChan) close() bool {
+	closed := false
+	s.once.Do(func() {
+		close(s.stop)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.flush()
+		s.closed = true
+		close(s.ch)
+		closed = true
+	})
+	return closed
+}
@@ -0,0 +1,115 @@
+package ble_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestSubscribeChan(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	ch := svc.NewCharacteristic(gatttest.NotifyCharUUID)
+	ch.HandleFanout(ble.FanoutPolicy{})
+	f := gatttest.New(ctx, t, 0, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	nc := f.Characteristic(cln, gatttest.NotifyCharUUID)
+
+	// waitFor notifies v until the subscriber, served in the background,
+	// receives it.
+	waitFor := func(vs <-chan []byte, v []byte) {
+		for {
+			if err := ch.Notify(v); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case <-vs:
+				return
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				t.Fatal("notification not received")
+			}
+		}
+	}
+	// notifyLong notifies b, cut into the values of the MTU.
+	notifyLong := func(b []byte) {
+		for len(b) > 0 {
+			n := 20
+			if n > len(b) {
+				n = len(b)
+			}
+			if err := ch.Notify(b[:n]); err != nil {
+				t.Fatal(err)
+			}
+			b = b[n:]
+		}
+	}
+	long := make([]byte, 100)
+	for i := range long {
+		long[i] = byte(i)
+	}
+
+	// Length prefixed.
+	vs, unsub, err := ble.SubscribeChanWithConfig(cln, nc, false, ble.SubscribeChanConfig{Framer: ble.LengthFramer(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(vs, []byte{0x01, 0x00, 'x'})
+	frame, _ := ble.LengthFramer(2).Encode(long)
+	notifyLong(frame)
+	select {
+	case v := <-vs:
+		if !bytes.Equal(v, long) {
+			t.Fatalf("received % X, want % X", v, long)
+		}
+	case <-ctx.Done():
+		t.Fatal("value not received")
+	}
+	unsub()
+	if _, ok := <-vs; ok {
+		t.Fatal("channel not closed")
+	}
+
+	// Timeout based.
+	vs, unsub, err = ble.SubscribeChanWithConfig(cln, nc, false, ble.SubscribeChanConfig{Gap: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsub()
+	waitFor(vs, []byte("x"))
+	// Drain the values of waitFor, passed once the gap elapsed.
+	for drained := false; !drained; {
+		select {
+		case <-vs:
+		case <-time.After(100 * time.Millisecond):
+			drained = true
+		}
+	}
+	notifyLong(long)
+	select {
+	case v := <-vs:
+		if !bytes.Equal(v, long) {
+			t.Fatalf("received % X, want % X", v, long)
+		}
+	case <-ctx.Done():
+		t.Fatal("value not received")
+	}
+
+	cln.CancelConnection()
+	select {
+	case _, ok := <-vs:
+		if ok {
+			t.Fatal("value received after the disconnection")
+		}
+	case <-ctx.Done():
+		t.Fatal("channel not closed on disconnection")
+	}
+}
@@ -0,0 +1,55 @@
+package ble
+
+import "fmt"
+
+// A ProfileHandler handles the notifications and indications of the
+// characteristics of a profile.
+type ProfileHandler func(c *Characteristic, req []byte)
+
+// A ProfileSubscription is the subscription of SubscribeProfile to the
+// characteristics of a profile.
+type ProfileSubscription struct {
+	cln   Client
+	chars []*Characteristic
+	inds  []bool
+}
+
+// SubscribeProfile subscribes to every characteristic of p, which notifies or
+// indicates, with notifications where it supports both, and passes them to h.
+// If any subscription fails, those made are undone.
+func SubscribeProfile(cln Client, p *Profile, h ProfileHandler) (*ProfileSubscription, error) {
+	s := &ProfileSubscription{cln: cln}
+	for _, svc := range p.Services {
+		for _, c := range svc.Characteristics {
+			if c.Property&(CharNotify|CharIndicate) == 0 {
+				continue
+			}
+			c := c
+			ind := c.Property&CharNotify == 0
+			if err := cln.Subscribe(c, ind, func(req []byte) { h(c, req) }); err != nil {
+				s.Close()
+				return nil, wrap(err, fmt.Sprintf("can't subscribe to %s", c.UUID))
+			}
+			s.chars = append(s.chars, c)
+			s.inds = append(s.inds, ind)
+		}
+	}
+	return s, nil
+}
+
+// Characteristics returns the characteristics subscribed to.
+func (s *ProfileSubscription) Characteristics() []*Characteristic {
+	return s.chars
+}
+
+// Close unsubscribes from the characteristics, and returns the first error.
+func (s *ProfileSubscription) Close() error {
+	var err error
+	for i, c := range s.chars {
+		if e := s.cln.Unsubscribe(c, s.inds[i]); e != nil && err == nil {
+			err = wrap(e, fmt.Sprintf("can't unsubscribe from %s", c.UUID))
+		}
+	}
+	s.chars, s.inds = nil, nil
+	return err
+}
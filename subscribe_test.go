@@ -0,0 +1,57 @@
+package ble_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kirbo/ble"
+	"github.com/kirbo/ble/internal/gatttest"
+)
+
+func TestSubscribeProfile(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	svc := ble.NewService(gatttest.ServiceUUID)
+	svc.NewCharacteristic(gatttest.ReadCharUUID).SetValue([]byte("hello"))
+	svc.NewCharacteristic(gatttest.NotifyCharUUID).HandleNotify(ble.NotifyHandlerFunc(func(req ble.Request, n ble.Notifier) {
+		n.Write([]byte("tick"))
+		<-n.Context().Done()
+	}))
+	f := gatttest.New(ctx, t, 10*time.Millisecond, svc)
+	defer f.Stop()
+
+	cln := f.Connect(ctx)
+	got := make(chan *ble.Characteristic, 1)
+	s, err := ble.SubscribeProfile(cln, cln.Profile(), func(c *ble.Characteristic, req []byte) {
+		if string(req) == "tick" {
+			got <- c
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The Service Changed characteristic of the GATT service indicates too.
+	subscribed := false
+	for _, c := range s.Characteristics() {
+		subscribed = subscribed || c.UUID.Equal(gatttest.NotifyCharUUID)
+		if c.UUID.Equal(gatttest.ReadCharUUID) {
+			t.Errorf("subscribed to %s, which doesn't notify", c.UUID)
+		}
+	}
+	if !subscribed {
+		t.Errorf("not subscribed to %s", gatttest.NotifyCharUUID)
+	}
+	select {
+	case c := <-got:
+		if !c.UUID.Equal(gatttest.NotifyCharUUID) {
+			t.Errorf("notification of %s, want %s", c.UUID, gatttest.NotifyCharUUID)
+		}
+	case <-ctx.Done():
+		t.Fatal("notification not received")
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,180 @@
+package ble
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ConnParams are the timing parameters of an LE connection. [Vol 6, Part B, 4.5.1 & 4.5.2]
+type ConnParams struct {
+	Interval           time.Duration // Time between connection events; 7.5ms to 4s, in 1.25ms steps.
+	Latency            int           // Connection events the peripheral may skip; 0 to 499.
+	SupervisionTimeout time.Duration // Time without a packet until the link is lost; 100ms to 32s, in 10ms steps.
+}
+
+// Limits of the connection parameters. [Vol 4, Part E, 7.8.12]
+const (
+	MinConnInterval       = 7500 * time.Microsecond
+	MaxConnInterval       = 4 * time.Second
+	MaxConnLatency        = 499
+	MinSupervisionTimeout = 100 * time.Millisecond
+	MaxSupervisionTimeout = 32 * time.Second
+)
+
+const (
+	connIntervalUnit       = 1250 * time.Microsecond
+	supervisionTimeoutUnit = 10 * time.Millisecond
+
+	// defaultMissedEvents is the number of lost connection events that
+	// RecommendConnParams tolerates by default.
+	defaultMissedEvents = 6
+)
+
+// Validate checks p against the limits of the specification. The supervision
+// timeout must be longer than twice the effective interval, which accounts
+// for the latency. [Vol 6, Part B, 4.5.2]
+func (p ConnParams) Validate() error {
+	switch {
+	case p.Interval < MinConnInterval || p.Interval > MaxConnInterval:
+		return fmt.Errorf("connection interval %s out of range", p.Interval)
+	case p.Latency < 0 || p.Latency > MaxConnLatency:
+		return fmt.Errorf("connection latency %d out of range", p.Latency)
+	case p.SupervisionTimeout < MinSupervisionTimeout || p.SupervisionTimeout > MaxSupervisionTimeout:
+		return fmt.Errorf("supervision timeout %s out of range", p.SupervisionTimeout)
+	case p.SupervisionTimeout <= 2*p.effectiveInterval():
+		return fmt.Errorf("supervision timeout %s must exceed twice the effective interval %s", p.SupervisionTimeout, p.effectiveInterval())
+	}
+	return nil
+}
+
+// effectiveInterval is the longest time between the connection events the
+// peripheral listens to.
+func (p ConnParams) effectiveInterval() time.Duration {
+	return time.Duration(1+p.Latency) * p.Interval
+}
+
+// MissedEvents returns how many consecutive connection events, which the
+// peripheral listens to, may be lost to interference before the link is.
+func (p ConnParams) MissedEvents() int {
+	return int(p.SupervisionTimeout / p.effectiveInterval())
+}
+
+// RecommendConnParams returns the parameters of a link with the given interval
+// and latency, which survives losing as many as missed consecutive connection
+// events to interference, but detects a lost radio as soon as possible. The interval and timeout are rounded to their units,
+// and clamped to the limits of the specification. If missed is 0, a default
+// of 6 is used.
+func RecommendConnParams(interval time.Duration, latency, missed int) ConnParams {
+	if missed <= 0 {
+		missed = defaultMissedEvents
+	}
+	if latency < 0 {
+		latency = 0
+	} else if latency > MaxConnLatency {
+		latency = MaxConnLatency
+	}
+	interval = clampDuration(roundUp(interval, connIntervalUnit), MinConnInterval, MaxConnInterval)
+	p := ConnParams{Interval: interval, Latency: latency}
+	for {
+		// The link survives the missed events if the next one gets through.
+		tmo := time.Duration(missed+1) * p.effectiveInterval()
+		if min := 2*p.effectiveInterval() + supervisionTimeoutUnit; tmo < min {
+			tmo = min
+		}
+		p.SupervisionTimeout = clampDuration(roundUp(tmo, supervisionTimeoutUnit), MinSupervisionTimeout, MaxSupervisionTimeout)
+
+		// Reduce the latency if the timeout can't cover the effective interval.
+		if p.Latency == 0 || p.Validate() == nil {
+			return p
+		}
+		p.Latency--
+	}
+}
+
+func roundUp(d, unit time.Duration) time.Duration {
+	return (d + unit - 1) / unit * unit
+}
+
+func clampDuration(d, min, max time.Duration) time.Duration {
+	if d < min {
+		return min
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// HeartbeatResult reports how a connection supervised by Heartbeat ended.
+type HeartbeatResult struct {
+	Beats    int       // Successful heartbeats.
+	Failures int       // Failed heartbeats.
+	LastSeen time.Time // Time of the last successful heartbeat.
+	LostAt   time.Time // Time the disconnection was reported; zero if ctx was done first.
+
+	// Detection is the time between the last successful heartbeat and the
+	// disconnection. The link was lost within a heartbeat period before the
+	// last heartbeat failed to return, so Detection exceeds the actual
+	// supervision timeout by at most one period.
+	Detection time.Duration
+}
+
+// Heartbeat calls beat on cln every period, until cln disconnects or ctx is
+// done, to measure how long it takes the stack to report the loss of the
+// radio link, such as when the peer is powered off or shielded. beat should
+// make a round trip to the peer, such as reading a characteristic. The beats
+// are timed by the Clock of ctx.
+func Heartbeat(ctx context.Context, cln Client, period time.Duration, beat func(Client) error) (HeartbeatResult, error) {
+	var r HeartbeatResult
+	clock := ClockFromContext(ctx)
+	r.LastSeen = clock.Now()
+	t := clock.NewTicker(period)
+	defer t.Stop()
+
+	// The beats run on their own goroutine, since a request to a lost peer
+	// blocks until the disconnection or a transaction timeout.
+	done := make(chan struct{})
+	defer close(done)
+	beats := make(chan time.Time)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C():
+			}
+			if err := beat(cln); err != nil {
+				select {
+				case beats <- time.Time{}:
+				case <-done:
+					return
+				}
+				continue
+			}
+			select {
+			case beats <- clock.Now():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case at := <-beats:
+			if at.IsZero() {
+				r.Failures++
+				continue
+			}
+			r.Beats++
+			r.LastSeen = at
+		case <-cln.Disconnected():
+			r.LostAt = clock.Now()
+			r.Detection = r.LostAt.Sub(r.LastSeen)
+			return r, nil
+		case <-ctx.Done():
+			return r, ctx.Err()
+		}
+	}
+}
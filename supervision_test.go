@@ -0,0 +1,46 @@
+package ble
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRecommendConnParams(t *testing.T) {
+	for _, tt := range []struct {
+		interval time.Duration
+		latency  int
+		missed   int
+		want     ConnParams
+	}{
+		{30 * time.Millisecond, 0, 6, ConnParams{30 * time.Millisecond, 0, 210 * time.Millisecond}},
+		{7 * time.Millisecond, 0, 6, ConnParams{7500 * time.Microsecond, 0, 100 * time.Millisecond}},
+		{50 * time.Millisecond, 4, 3, ConnParams{50 * time.Millisecond, 4, 1 * time.Second}},
+		{4 * time.Second, 10, 6, ConnParams{4 * time.Second, 2, 32 * time.Second}},
+	} {
+		got := RecommendConnParams(tt.interval, tt.latency, tt.missed)
+		if got != tt.want {
+			t.Errorf("RecommendConnParams(%s, %d, %d): got %+v, want %+v", tt.interval, tt.latency, tt.missed, got, tt.want)
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("RecommendConnParams(%s, %d, %d): %s", tt.interval, tt.latency, tt.missed, err)
+		}
+	}
+}
+
+func TestPreferredConnParams(t *testing.T) {
+	p := PreferredConnParams{IntervalMin: 30 * time.Millisecond, IntervalMax: 50 * time.Millisecond, Latency: 0, SupervisionTimeout: 4 * time.Second}
+	if err := p.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if b, want := p.Marshal(), []byte{0x18, 0x00, 0x28, 0x00, 0x00, 0x00, 0x90, 0x01}; !bytes.Equal(b, want) {
+		t.Errorf("Marshal() = % x, want % x", b, want)
+	}
+	if !p.Contains(ConnParams{40 * time.Millisecond, 0, 4 * time.Second}) || p.Contains(ConnParams{15 * time.Millisecond, 0, 4 * time.Second}) {
+		t.Error("Contains() doesn't bound the interval")
+	}
+	p.IntervalMin = time.Second
+	if err := p.Validate(); err == nil {
+		t.Error("Validate() = nil, with the minimum interval longer than the maximum")
+	}
+}
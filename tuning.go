@@ -0,0 +1,51 @@
+package ble
+
+import (
+	"errors"
+	"time"
+)
+
+// IntervalTuning tunes the parameters of each connection to its traffic,
+// set with OptIntervalTuning, trading latency for power without managing
+// them by hand. A connection is switched to Fast as soon as it carries Burst
+// PDUs within a Window, and back to Slow once it hasn't for Idle.
+// RecommendConnParams gives the parameters of the intervals to tune between.
+type IntervalTuning struct {
+	Fast ConnParams // Parameters during bursts.
+	Slow ConnParams // Parameters when idle.
+
+	Window time.Duration // Period the traffic is counted over; 1s if 0.
+	Burst  int           // PDUs sent and received within a Window, which make a burst; 4 if 0.
+	Idle   time.Duration // Time without a burst, before slowing down; 5s if 0.
+}
+
+// Defaults of IntervalTuning.
+const (
+	defaultTuningWindow = time.Second
+	defaultTuningBurst  = 4
+	defaultTuningIdle   = 5 * time.Second
+)
+
+// WithDefaults returns t, with the defaults of the fields it leaves zero, or
+// an error if its parameters are invalid, or Fast is slower than Slow.
+func (t IntervalTuning) WithDefaults() (IntervalTuning, error) {
+	if err := t.Fast.Validate(); err != nil {
+		return t, err
+	}
+	if err := t.Slow.Validate(); err != nil {
+		return t, err
+	}
+	if t.Fast.Interval > t.Slow.Interval {
+		return t, errors.New("fast connection interval longer than the slow one")
+	}
+	if t.Window <= 0 {
+		t.Window = defaultTuningWindow
+	}
+	if t.Burst <= 0 {
+		t.Burst = defaultTuningBurst
+	}
+	if t.Idle <= 0 {
+		t.Idle = defaultTuningIdle
+	}
+	return t, nil
+}
@@ -121,6 +121,7 @@ var knownUUID = map[string]struct{ Name, Type string }{
 	"1813": {Name: "Scan Parameters", Type: "org.bluetooth.service.scan_parameters"},
 	"1814": {Name: "Running Speed and Cadence", Type: "org.bluetooth.service.running_speed_and_cadence"},
 	"1815": {Name: "Cycling Speed and Cadence", Type: "org.bluetooth.service.cycling_speed_and_cadence"},
+	"181e": {Name: "Bond Management", Type: "org.bluetooth.service.bond_management"},
 
 	// A dictionary of known descriptor names and type (keyed by attribute uuid)
 	"2800": {Name: "Primary Service", Type: "org.bluetooth.attribute.gatt.primary_service_declaration"},
@@ -212,4 +213,6 @@ var knownUUID = map[string]struct{ Name, Type string }{
 	"2a5b": {Name: "CSC Measurement", Type: "org.bluetooth.characteristic.csc_measurement"},
 	"2a5c": {Name: "CSC Feature", Type: "org.bluetooth.characteristic.csc_feature"},
 	"2a5d": {Name: "Sensor Location", Type: "org.bluetooth.characteristic.sensor_location"},
+	"2aa4": {Name: "Bond Management Control Point", Type: "org.bluetooth.characteristic.bond_management_control_point"},
+	"2aa5": {Name: "Bond Management Feature", Type: "org.bluetooth.characteristic.bond_management_feature"},
 }
@@ -0,0 +1,117 @@
+//go:build ignore
+// +build ignore
+
+// gen writes uuids_gen.go, from the assigned numbers of the YAML files in
+// the format of the Bluetooth SIG.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// files are the assigned numbers read, with the suffix of their names.
+var files = []struct {
+	name   string
+	suffix string
+	doc    string
+}{
+	{"service_uuids.yaml", "Service", "Services."},
+	{"declarations.yaml", "Decl", "Attribute types of the declarations."},
+	{"descriptors.yaml", "Desc", "Descriptors."},
+	{"characteristic_uuids.yaml", "Char", "Characteristics."},
+}
+
+type entry struct {
+	uuid uint16
+	name string
+}
+
+func main() {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen.go; DO NOT EDIT.\n\npackage uuids\n\nimport \"github.com/kirbo/ble\"\n")
+	var all []entry
+	seen := map[string]bool{}
+	for _, f := range files {
+		es, err := read(f.name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintf(&b, "\n// %s\nvar (\n", f.doc)
+		for _, e := range es {
+			id := ident(e.name, f.suffix)
+			if seen[id] {
+				log.Fatalf("%s: duplicate %s", f.name, id)
+			}
+			seen[id] = true
+			fmt.Fprintf(&b, "%s = ble.UUID16(0x%04X) // %s\n", id, e.uuid, e.name)
+		}
+		fmt.Fprintf(&b, ")\n")
+		all = append(all, es...)
+	}
+	fmt.Fprintf(&b, "\nvar names = map[uint16]string{\n")
+	for _, e := range all {
+		fmt.Fprintf(&b, "0x%04X: %q,\n", e.uuid, e.name)
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("uuids_gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// read returns the entries of the uuids list of the file name, of which only
+// the uuid and name keys are used.
+func read(name string) ([]entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var es []entry
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		l := strings.TrimSpace(s.Text())
+		switch {
+		case strings.HasPrefix(l, "- uuid:"):
+			u, err := strconv.ParseUint(strings.TrimSpace(l[len("- uuid:"):]), 0, 16)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", name, n, err)
+			}
+			es = append(es, entry{uuid: uint16(u)})
+		case strings.HasPrefix(l, "name:"):
+			if len(es) == 0 {
+				return nil, fmt.Errorf("%s:%d: name without uuid", name, n)
+			}
+			es[len(es)-1].name = strings.TrimSpace(l[len("name:"):])
+		}
+	}
+	return es, s.Err()
+}
+
+// ident returns the Go name of an assigned name, such as HeartRateService of
+// "Heart Rate", or MagneticFluxDensity2DChar of "Magnetic Flux Density - 2D".
+func ident(name, suffix string) string {
+	name = strings.TrimSuffix(name, " "+suffix)
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	return b.String() + suffix
+}
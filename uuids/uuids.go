@@ -0,0 +1,25 @@
+// Package uuids names the UUIDs of the Bluetooth SIG assigned numbers: the
+// services, as HeartRateService, the characteristics, as BatteryLevelChar,
+// the descriptors, as ClientCharacteristicConfigurationDesc, and the
+// attribute types of the declarations, as PrimaryServiceDecl.
+//
+// The names are generated from the YAML files of the assigned numbers, kept
+// next to the package; to add to them, edit the files and run go generate.
+package uuids
+
+//go:generate go run gen.go
+
+import (
+	"encoding/binary"
+
+	"github.com/kirbo/ble"
+)
+
+// Name returns the assigned name of the 16-bit UUID u, or "" if u isn't one
+// of the package.
+func Name(u ble.UUID) string {
+	if len(u) != 2 {
+		return ""
+	}
+	return names[binary.LittleEndian.Uint16(u)]
+}
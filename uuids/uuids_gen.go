@@ -0,0 +1,604 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package uuids
+
+import "github.com/kirbo/ble"
+
+// Services.
+var (
+	GAPService                          = ble.UUID16(0x1800) // GAP
+	GATTService                         = ble.UUID16(0x1801) // GATT
+	ImmediateAlertService               = ble.UUID16(0x1802) // Immediate Alert
+	LinkLossService                     = ble.UUID16(0x1803) // Link Loss
+	TxPowerService                      = ble.UUID16(0x1804) // Tx Power
+	CurrentTimeService                  = ble.UUID16(0x1805) // Current Time
+	ReferenceTimeUpdateService          = ble.UUID16(0x1806) // Reference Time Update
+	NextDSTChangeService                = ble.UUID16(0x1807) // Next DST Change
+	GlucoseService                      = ble.UUID16(0x1808) // Glucose
+	HealthThermometerService            = ble.UUID16(0x1809) // Health Thermometer
+	DeviceInformationService            = ble.UUID16(0x180A) // Device Information
+	HeartRateService                    = ble.UUID16(0x180D) // Heart Rate
+	PhoneAlertStatusService             = ble.UUID16(0x180E) // Phone Alert Status
+	BatteryService                      = ble.UUID16(0x180F) // Battery
+	BloodPressureService                = ble.UUID16(0x1810) // Blood Pressure
+	AlertNotificationService            = ble.UUID16(0x1811) // Alert Notification
+	HumanInterfaceDeviceService         = ble.UUID16(0x1812) // Human Interface Device
+	ScanParametersService               = ble.UUID16(0x1813) // Scan Parameters
+	RunningSpeedAndCadenceService       = ble.UUID16(0x1814) // Running Speed and Cadence
+	AutomationIOService                 = ble.UUID16(0x1815) // Automation IO
+	CyclingSpeedAndCadenceService       = ble.UUID16(0x1816) // Cycling Speed and Cadence
+	CyclingPowerService                 = ble.UUID16(0x1818) // Cycling Power
+	LocationAndNavigationService        = ble.UUID16(0x1819) // Location and Navigation
+	EnvironmentalSensingService         = ble.UUID16(0x181A) // Environmental Sensing
+	BodyCompositionService              = ble.UUID16(0x181B) // Body Composition
+	UserDataService                     = ble.UUID16(0x181C) // User Data
+	WeightScaleService                  = ble.UUID16(0x181D) // Weight Scale
+	BondManagementService               = ble.UUID16(0x181E) // Bond Management
+	ContinuousGlucoseMonitoringService  = ble.UUID16(0x181F) // Continuous Glucose Monitoring
+	InternetProtocolSupportService      = ble.UUID16(0x1820) // Internet Protocol Support
+	IndoorPositioningService            = ble.UUID16(0x1821) // Indoor Positioning
+	PulseOximeterService                = ble.UUID16(0x1822) // Pulse Oximeter
+	HTTPProxyService                    = ble.UUID16(0x1823) // HTTP Proxy
+	TransportDiscoveryService           = ble.UUID16(0x1824) // Transport Discovery
+	ObjectTransferService               = ble.UUID16(0x1825) // Object Transfer
+	FitnessMachineService               = ble.UUID16(0x1826) // Fitness Machine
+	MeshProvisioningService             = ble.UUID16(0x1827) // Mesh Provisioning
+	MeshProxyService                    = ble.UUID16(0x1828) // Mesh Proxy
+	ReconnectionConfigurationService    = ble.UUID16(0x1829) // Reconnection Configuration
+	InsulinDeliveryService              = ble.UUID16(0x183A) // Insulin Delivery
+	BinarySensorService                 = ble.UUID16(0x183B) // Binary Sensor
+	EmergencyConfigurationService       = ble.UUID16(0x183C) // Emergency Configuration
+	AuthorizationControlService         = ble.UUID16(0x183D) // Authorization Control
+	PhysicalActivityMonitorService      = ble.UUID16(0x183E) // Physical Activity Monitor
+	ElapsedTimeService                  = ble.UUID16(0x183F) // Elapsed Time
+	GenericHealthSensorService          = ble.UUID16(0x1840) // Generic Health Sensor
+	AudioInputControlService            = ble.UUID16(0x1843) // Audio Input Control
+	VolumeControlService                = ble.UUID16(0x1844) // Volume Control
+	VolumeOffsetControlService          = ble.UUID16(0x1845) // Volume Offset Control
+	CoordinatedSetIdentificationService = ble.UUID16(0x1846) // Coordinated Set Identification
+	DeviceTimeService                   = ble.UUID16(0x1847) // Device Time
+	MediaControlService                 = ble.UUID16(0x1848) // Media Control
+	GenericMediaControlService          = ble.UUID16(0x1849) // Generic Media Control
+	ConstantToneExtensionService        = ble.UUID16(0x184A) // Constant Tone Extension
+	TelephoneBearerService              = ble.UUID16(0x184B) // Telephone Bearer
+	GenericTelephoneBearerService       = ble.UUID16(0x184C) // Generic Telephone Bearer
+	MicrophoneControlService            = ble.UUID16(0x184D) // Microphone Control
+	AudioStreamControlService           = ble.UUID16(0x184E) // Audio Stream Control
+	BroadcastAudioScanService           = ble.UUID16(0x184F) // Broadcast Audio Scan
+	PublishedAudioCapabilitiesService   = ble.UUID16(0x1850) // Published Audio Capabilities
+	BasicAudioAnnouncementService       = ble.UUID16(0x1851) // Basic Audio Announcement
+	BroadcastAudioAnnouncementService   = ble.UUID16(0x1852) // Broadcast Audio Announcement
+	CommonAudioService                  = ble.UUID16(0x1853) // Common Audio
+	HearingAccessService                = ble.UUID16(0x1854) // Hearing Access
+	TelephonyAndMediaAudioService       = ble.UUID16(0x1855) // Telephony and Media Audio
+	PublicBroadcastAnnouncementService  = ble.UUID16(0x1856) // Public Broadcast Announcement
+	ElectronicShelfLabelService         = ble.UUID16(0x1857) // Electronic Shelf Label
+	GamingAudioService                  = ble.UUID16(0x1858) // Gaming Audio
+	MeshProxySolicitationService        = ble.UUID16(0x1859) // Mesh Proxy Solicitation
+)
+
+// Attribute types of the declarations.
+var (
+	PrimaryServiceDecl   = ble.UUID16(0x2800) // Primary Service
+	SecondaryServiceDecl = ble.UUID16(0x2801) // Secondary Service
+	IncludeDecl          = ble.UUID16(0x2802) // Include
+	CharacteristicDecl   = ble.UUID16(0x2803) // Characteristic
+)
+
+// Descriptors.
+var (
+	CharacteristicExtendedPropertiesDesc   = ble.UUID16(0x2900) // Characteristic Extended Properties
+	CharacteristicUserDescriptionDesc      = ble.UUID16(0x2901) // Characteristic User Description
+	ClientCharacteristicConfigurationDesc  = ble.UUID16(0x2902) // Client Characteristic Configuration
+	ServerCharacteristicConfigurationDesc  = ble.UUID16(0x2903) // Server Characteristic Configuration
+	CharacteristicPresentationFormatDesc   = ble.UUID16(0x2904) // Characteristic Presentation Format
+	CharacteristicAggregateFormatDesc      = ble.UUID16(0x2905) // Characteristic Aggregate Format
+	ValidRangeDesc                         = ble.UUID16(0x2906) // Valid Range
+	ExternalReportReferenceDesc            = ble.UUID16(0x2907) // External Report Reference
+	ReportReferenceDesc                    = ble.UUID16(0x2908) // Report Reference
+	NumberOfDigitalsDesc                   = ble.UUID16(0x2909) // Number of Digitals
+	ValueTriggerSettingDesc                = ble.UUID16(0x290A) // Value Trigger Setting
+	EnvironmentalSensingConfigurationDesc  = ble.UUID16(0x290B) // Environmental Sensing Configuration
+	EnvironmentalSensingMeasurementDesc    = ble.UUID16(0x290C) // Environmental Sensing Measurement
+	EnvironmentalSensingTriggerSettingDesc = ble.UUID16(0x290D) // Environmental Sensing Trigger Setting
+	TimeTriggerSettingDesc                 = ble.UUID16(0x290E) // Time Trigger Setting
+	CompleteBREDRTransportBlockDataDesc    = ble.UUID16(0x290F) // Complete BR-EDR Transport Block Data
+	ObservationScheduleDesc                = ble.UUID16(0x2910) // Observation Schedule
+	ValidRangeAndAccuracyDesc              = ble.UUID16(0x2911) // Valid Range and Accuracy
+)
+
+// Characteristics.
+var (
+	DeviceNameChar                                    = ble.UUID16(0x2A00) // Device Name
+	AppearanceChar                                    = ble.UUID16(0x2A01) // Appearance
+	PeripheralPrivacyFlagChar                         = ble.UUID16(0x2A02) // Peripheral Privacy Flag
+	ReconnectionAddressChar                           = ble.UUID16(0x2A03) // Reconnection Address
+	PeripheralPreferredConnectionParametersChar       = ble.UUID16(0x2A04) // Peripheral Preferred Connection Parameters
+	ServiceChangedChar                                = ble.UUID16(0x2A05) // Service Changed
+	AlertLevelChar                                    = ble.UUID16(0x2A06) // Alert Level
+	TxPowerLevelChar                                  = ble.UUID16(0x2A07) // Tx Power Level
+	DateTimeChar                                      = ble.UUID16(0x2A08) // Date Time
+	DayOfWeekChar                                     = ble.UUID16(0x2A09) // Day of Week
+	DayDateTimeChar                                   = ble.UUID16(0x2A0A) // Day Date Time
+	ExactTime256Char                                  = ble.UUID16(0x2A0C) // Exact Time 256
+	DSTOffsetChar                                     = ble.UUID16(0x2A0D) // DST Offset
+	TimeZoneChar                                      = ble.UUID16(0x2A0E) // Time Zone
+	LocalTimeInformationChar                          = ble.UUID16(0x2A0F) // Local Time Information
+	TimeWithDSTChar                                   = ble.UUID16(0x2A11) // Time with DST
+	TimeAccuracyChar                                  = ble.UUID16(0x2A12) // Time Accuracy
+	TimeSourceChar                                    = ble.UUID16(0x2A13) // Time Source
+	ReferenceTimeInformationChar                      = ble.UUID16(0x2A14) // Reference Time Information
+	TimeUpdateControlPointChar                        = ble.UUID16(0x2A16) // Time Update Control Point
+	TimeUpdateStateChar                               = ble.UUID16(0x2A17) // Time Update State
+	GlucoseMeasurementChar                            = ble.UUID16(0x2A18) // Glucose Measurement
+	BatteryLevelChar                                  = ble.UUID16(0x2A19) // Battery Level
+	TemperatureMeasurementChar                        = ble.UUID16(0x2A1C) // Temperature Measurement
+	TemperatureTypeChar                               = ble.UUID16(0x2A1D) // Temperature Type
+	IntermediateTemperatureChar                       = ble.UUID16(0x2A1E) // Intermediate Temperature
+	MeasurementIntervalChar                           = ble.UUID16(0x2A21) // Measurement Interval
+	BootKeyboardInputReportChar                       = ble.UUID16(0x2A22) // Boot Keyboard Input Report
+	SystemIDChar                                      = ble.UUID16(0x2A23) // System ID
+	ModelNumberStringChar                             = ble.UUID16(0x2A24) // Model Number String
+	SerialNumberStringChar                            = ble.UUID16(0x2A25) // Serial Number String
+	FirmwareRevisionStringChar                        = ble.UUID16(0x2A26) // Firmware Revision String
+	HardwareRevisionStringChar                        = ble.UUID16(0x2A27) // Hardware Revision String
+	SoftwareRevisionStringChar                        = ble.UUID16(0x2A28) // Software Revision String
+	ManufacturerNameStringChar                        = ble.UUID16(0x2A29) // Manufacturer Name String
+	IEEE1107320601RegulatoryCertificationDataListChar = ble.UUID16(0x2A2A) // IEEE 11073-20601 Regulatory Certification Data List
+	CurrentTimeChar                                   = ble.UUID16(0x2A2B) // Current Time
+	MagneticDeclinationChar                           = ble.UUID16(0x2A2C) // Magnetic Declination
+	ScanRefreshChar                                   = ble.UUID16(0x2A31) // Scan Refresh
+	BootKeyboardOutputReportChar                      = ble.UUID16(0x2A32) // Boot Keyboard Output Report
+	BootMouseInputReportChar                          = ble.UUID16(0x2A33) // Boot Mouse Input Report
+	GlucoseMeasurementContextChar                     = ble.UUID16(0x2A34) // Glucose Measurement Context
+	BloodPressureMeasurementChar                      = ble.UUID16(0x2A35) // Blood Pressure Measurement
+	IntermediateCuffPressureChar                      = ble.UUID16(0x2A36) // Intermediate Cuff Pressure
+	HeartRateMeasurementChar                          = ble.UUID16(0x2A37) // Heart Rate Measurement
+	BodySensorLocationChar                            = ble.UUID16(0x2A38) // Body Sensor Location
+	HeartRateControlPointChar                         = ble.UUID16(0x2A39) // Heart Rate Control Point
+	AlertStatusChar                                   = ble.UUID16(0x2A3F) // Alert Status
+	RingerControlPointChar                            = ble.UUID16(0x2A40) // Ringer Control Point
+	RingerSettingChar                                 = ble.UUID16(0x2A41) // Ringer Setting
+	AlertCategoryIDBitMaskChar                        = ble.UUID16(0x2A42) // Alert Category ID Bit Mask
+	AlertCategoryIDChar                               = ble.UUID16(0x2A43) // Alert Category ID
+	AlertNotificationControlPointChar                 = ble.UUID16(0x2A44) // Alert Notification Control Point
+	UnreadAlertStatusChar                             = ble.UUID16(0x2A45) // Unread Alert Status
+	NewAlertChar                                      = ble.UUID16(0x2A46) // New Alert
+	SupportedNewAlertCategoryChar                     = ble.UUID16(0x2A47) // Supported New Alert Category
+	SupportedUnreadAlertCategoryChar                  = ble.UUID16(0x2A48) // Supported Unread Alert Category
+	BloodPressureFeatureChar                          = ble.UUID16(0x2A49) // Blood Pressure Feature
+	HIDInformationChar                                = ble.UUID16(0x2A4A) // HID Information
+	ReportMapChar                                     = ble.UUID16(0x2A4B) // Report Map
+	HIDControlPointChar                               = ble.UUID16(0x2A4C) // HID Control Point
+	ReportChar                                        = ble.UUID16(0x2A4D) // Report
+	ProtocolModeChar                                  = ble.UUID16(0x2A4E) // Protocol Mode
+	ScanIntervalWindowChar                            = ble.UUID16(0x2A4F) // Scan Interval Window
+	PnPIDChar                                         = ble.UUID16(0x2A50) // PnP ID
+	GlucoseFeatureChar                                = ble.UUID16(0x2A51) // Glucose Feature
+	RecordAccessControlPointChar                      = ble.UUID16(0x2A52) // Record Access Control Point
+	RSCMeasurementChar                                = ble.UUID16(0x2A53) // RSC Measurement
+	RSCFeatureChar                                    = ble.UUID16(0x2A54) // RSC Feature
+	SCControlPointChar                                = ble.UUID16(0x2A55) // SC Control Point
+	DigitalChar                                       = ble.UUID16(0x2A56) // Digital
+	AnalogChar                                        = ble.UUID16(0x2A58) // Analog
+	AggregateChar                                     = ble.UUID16(0x2A5A) // Aggregate
+	CSCMeasurementChar                                = ble.UUID16(0x2A5B) // CSC Measurement
+	CSCFeatureChar                                    = ble.UUID16(0x2A5C) // CSC Feature
+	SensorLocationChar                                = ble.UUID16(0x2A5D) // Sensor Location
+	PLXSpotCheckMeasurementChar                       = ble.UUID16(0x2A5E) // PLX Spot-Check Measurement
+	PLXContinuousMeasurementChar                      = ble.UUID16(0x2A5F) // PLX Continuous Measurement
+	PLXFeaturesChar                                   = ble.UUID16(0x2A60) // PLX Features
+	CyclingPowerMeasurementChar                       = ble.UUID16(0x2A63) // Cycling Power Measurement
+	CyclingPowerVectorChar                            = ble.UUID16(0x2A64) // Cycling Power Vector
+	CyclingPowerFeatureChar                           = ble.UUID16(0x2A65) // Cycling Power Feature
+	CyclingPowerControlPointChar                      = ble.UUID16(0x2A66) // Cycling Power Control Point
+	LocationAndSpeedChar                              = ble.UUID16(0x2A67) // Location and Speed
+	NavigationChar                                    = ble.UUID16(0x2A68) // Navigation
+	PositionQualityChar                               = ble.UUID16(0x2A69) // Position Quality
+	LNFeatureChar                                     = ble.UUID16(0x2A6A) // LN Feature
+	LNControlPointChar                                = ble.UUID16(0x2A6B) // LN Control Point
+	ElevationChar                                     = ble.UUID16(0x2A6C) // Elevation
+	PressureChar                                      = ble.UUID16(0x2A6D) // Pressure
+	TemperatureChar                                   = ble.UUID16(0x2A6E) // Temperature
+	HumidityChar                                      = ble.UUID16(0x2A6F) // Humidity
+	TrueWindSpeedChar                                 = ble.UUID16(0x2A70) // True Wind Speed
+	TrueWindDirectionChar                             = ble.UUID16(0x2A71) // True Wind Direction
+	ApparentWindSpeedChar                             = ble.UUID16(0x2A72) // Apparent Wind Speed
+	ApparentWindDirectionChar                         = ble.UUID16(0x2A73) // Apparent Wind Direction
+	GustFactorChar                                    = ble.UUID16(0x2A74) // Gust Factor
+	PollenConcentrationChar                           = ble.UUID16(0x2A75) // Pollen Concentration
+	UVIndexChar                                       = ble.UUID16(0x2A76) // UV Index
+	IrradianceChar                                    = ble.UUID16(0x2A77) // Irradiance
+	RainfallChar                                      = ble.UUID16(0x2A78) // Rainfall
+	WindChillChar                                     = ble.UUID16(0x2A79) // Wind Chill
+	HeatIndexChar                                     = ble.UUID16(0x2A7A) // Heat Index
+	DewPointChar                                      = ble.UUID16(0x2A7B) // Dew Point
+	DescriptorValueChangedChar                        = ble.UUID16(0x2A7D) // Descriptor Value Changed
+	AerobicHeartRateLowerLimitChar                    = ble.UUID16(0x2A7E) // Aerobic Heart Rate Lower Limit
+	AerobicThresholdChar                              = ble.UUID16(0x2A7F) // Aerobic Threshold
+	AgeChar                                           = ble.UUID16(0x2A80) // Age
+	AnaerobicHeartRateLowerLimitChar                  = ble.UUID16(0x2A81) // Anaerobic Heart Rate Lower Limit
+	AnaerobicHeartRateUpperLimitChar                  = ble.UUID16(0x2A82) // Anaerobic Heart Rate Upper Limit
+	AnaerobicThresholdChar                            = ble.UUID16(0x2A83) // Anaerobic Threshold
+	AerobicHeartRateUpperLimitChar                    = ble.UUID16(0x2A84) // Aerobic Heart Rate Upper Limit
+	DateOfBirthChar                                   = ble.UUID16(0x2A85) // Date of Birth
+	DateOfThresholdAssessmentChar                     = ble.UUID16(0x2A86) // Date of Threshold Assessment
+	EmailAddressChar                                  = ble.UUID16(0x2A87) // Email Address
+	FatBurnHeartRateLowerLimitChar                    = ble.UUID16(0x2A88) // Fat Burn Heart Rate Lower Limit
+	FatBurnHeartRateUpperLimitChar                    = ble.UUID16(0x2A89) // Fat Burn Heart Rate Upper Limit
+	FirstNameChar                                     = ble.UUID16(0x2A8A) // First Name
+	FiveZoneHeartRateLimitsChar                       = ble.UUID16(0x2A8B) // Five Zone Heart Rate Limits
+	GenderChar                                        = ble.UUID16(0x2A8C) // Gender
+	HeartRateMaxChar                                  = ble.UUID16(0x2A8D) // Heart Rate Max
+	HeightChar                                        = ble.UUID16(0x2A8E) // Height
+	HipCircumferenceChar                              = ble.UUID16(0x2A8F) // Hip Circumference
+	LastNameChar                                      = ble.UUID16(0x2A90) // Last Name
+	MaximumRecommendedHeartRateChar                   = ble.UUID16(0x2A91) // Maximum Recommended Heart Rate
+	RestingHeartRateChar                              = ble.UUID16(0x2A92) // Resting Heart Rate
+	SportTypeForAerobicAndAnaerobicThresholdsChar     = ble.UUID16(0x2A93) // Sport Type for Aerobic and Anaerobic Thresholds
+	ThreeZoneHeartRateLimitsChar                      = ble.UUID16(0x2A94) // Three Zone Heart Rate Limits
+	TwoZoneHeartRateLimitsChar                        = ble.UUID16(0x2A95) // Two Zone Heart Rate Limits
+	VO2MaxChar                                        = ble.UUID16(0x2A96) // VO2 Max
+	WaistCircumferenceChar                            = ble.UUID16(0x2A97) // Waist Circumference
+	WeightChar                                        = ble.UUID16(0x2A98) // Weight
+	DatabaseChangeIncrementChar                       = ble.UUID16(0x2A99) // Database Change Increment
+	UserIndexChar                                     = ble.UUID16(0x2A9A) // User Index
+	BodyCompositionFeatureChar                        = ble.UUID16(0x2A9B) // Body Composition Feature
+	BodyCompositionMeasurementChar                    = ble.UUID16(0x2A9C) // Body Composition Measurement
+	WeightMeasurementChar                             = ble.UUID16(0x2A9D) // Weight Measurement
+	WeightScaleFeatureChar                            = ble.UUID16(0x2A9E) // Weight Scale Feature
+	UserControlPointChar                              = ble.UUID16(0x2A9F) // User Control Point
+	MagneticFluxDensity2DChar                         = ble.UUID16(0x2AA0) // Magnetic Flux Density - 2D
+	MagneticFluxDensity3DChar                         = ble.UUID16(0x2AA1) // Magnetic Flux Density - 3D
+	LanguageChar                                      = ble.UUID16(0x2AA2) // Language
+	BarometricPressureTrendChar                       = ble.UUID16(0x2AA3) // Barometric Pressure Trend
+	BondManagementControlPointChar                    = ble.UUID16(0x2AA4) // Bond Management Control Point
+	BondManagementFeatureChar                         = ble.UUID16(0x2AA5) // Bond Management Feature
+	CentralAddressResolutionChar                      = ble.UUID16(0x2AA6) // Central Address Resolution
+	CGMMeasurementChar                                = ble.UUID16(0x2AA7) // CGM Measurement
+	CGMFeatureChar                                    = ble.UUID16(0x2AA8) // CGM Feature
+	CGMStatusChar                                     = ble.UUID16(0x2AA9) // CGM Status
+	CGMSessionStartTimeChar                           = ble.UUID16(0x2AAA) // CGM Session Start Time
+	CGMSessionRunTimeChar                             = ble.UUID16(0x2AAB) // CGM Session Run Time
+	CGMSpecificOpsControlPointChar                    = ble.UUID16(0x2AAC) // CGM Specific Ops Control Point
+	IndoorPositioningConfigurationChar                = ble.UUID16(0x2AAD) // Indoor Positioning Configuration
+	LatitudeChar                                      = ble.UUID16(0x2AAE) // Latitude
+	LongitudeChar                                     = ble.UUID16(0x2AAF) // Longitude
+	LocalNorthCoordinateChar                          = ble.UUID16(0x2AB0) // Local North Coordinate
+	LocalEastCoordinateChar                           = ble.UUID16(0x2AB1) // Local East Coordinate
+	FloorNumberChar                                   = ble.UUID16(0x2AB2) // Floor Number
+	AltitudeChar                                      = ble.UUID16(0x2AB3) // Altitude
+	UncertaintyChar                                   = ble.UUID16(0x2AB4) // Uncertainty
+	LocationNameChar                                  = ble.UUID16(0x2AB5) // Location Name
+	URIChar                                           = ble.UUID16(0x2AB6) // URI
+	HTTPHeadersChar                                   = ble.UUID16(0x2AB7) // HTTP Headers
+	HTTPStatusCodeChar                                = ble.UUID16(0x2AB8) // HTTP Status Code
+	HTTPEntityBodyChar                                = ble.UUID16(0x2AB9) // HTTP Entity Body
+	HTTPControlPointChar                              = ble.UUID16(0x2ABA) // HTTP Control Point
+	HTTPSSecurityChar                                 = ble.UUID16(0x2ABB) // HTTPS Security
+	TDSControlPointChar                               = ble.UUID16(0x2ABC) // TDS Control Point
+	OTSFeatureChar                                    = ble.UUID16(0x2ABD) // OTS Feature
+	ObjectNameChar                                    = ble.UUID16(0x2ABE) // Object Name
+	ObjectTypeChar                                    = ble.UUID16(0x2ABF) // Object Type
+	ObjectSizeChar                                    = ble.UUID16(0x2AC0) // Object Size
+	ObjectFirstCreatedChar                            = ble.UUID16(0x2AC1) // Object First-Created
+	ObjectLastModifiedChar                            = ble.UUID16(0x2AC2) // Object Last-Modified
+	ObjectIDChar                                      = ble.UUID16(0x2AC3) // Object ID
+	ObjectPropertiesChar                              = ble.UUID16(0x2AC4) // Object Properties
+	ObjectActionControlPointChar                      = ble.UUID16(0x2AC5) // Object Action Control Point
+	ObjectListControlPointChar                        = ble.UUID16(0x2AC6) // Object List Control Point
+	ObjectListFilterChar                              = ble.UUID16(0x2AC7) // Object List Filter
+	ObjectChangedChar                                 = ble.UUID16(0x2AC8) // Object Changed
+	ResolvablePrivateAddressOnlyChar                  = ble.UUID16(0x2AC9) // Resolvable Private Address Only
+	FitnessMachineFeatureChar                         = ble.UUID16(0x2ACC) // Fitness Machine Feature
+	TreadmillDataChar                                 = ble.UUID16(0x2ACD) // Treadmill Data
+	CrossTrainerDataChar                              = ble.UUID16(0x2ACE) // Cross Trainer Data
+	StepClimberDataChar                               = ble.UUID16(0x2ACF) // Step Climber Data
+	StairClimberDataChar                              = ble.UUID16(0x2AD0) // Stair Climber Data
+	RowerDataChar                                     = ble.UUID16(0x2AD1) // Rower Data
+	IndoorBikeDataChar                                = ble.UUID16(0x2AD2) // Indoor Bike Data
+	TrainingStatusChar                                = ble.UUID16(0x2AD3) // Training Status
+	SupportedSpeedRangeChar                           = ble.UUID16(0x2AD4) // Supported Speed Range
+	SupportedInclinationRangeChar                     = ble.UUID16(0x2AD5) // Supported Inclination Range
+	SupportedResistanceLevelRangeChar                 = ble.UUID16(0x2AD6) // Supported Resistance Level Range
+	SupportedHeartRateRangeChar                       = ble.UUID16(0x2AD7) // Supported Heart Rate Range
+	SupportedPowerRangeChar                           = ble.UUID16(0x2AD8) // Supported Power Range
+	FitnessMachineControlPointChar                    = ble.UUID16(0x2AD9) // Fitness Machine Control Point
+	FitnessMachineStatusChar                          = ble.UUID16(0x2ADA) // Fitness Machine Status
+	ClientSupportedFeaturesChar                       = ble.UUID16(0x2B29) // Client Supported Features
+	DatabaseHashChar                                  = ble.UUID16(0x2B2A) // Database Hash
+	ServerSupportedFeaturesChar                       = ble.UUID16(0x2B3A) // Server Supported Features
+)
+
+var names = map[uint16]string{
+	0x1800: "GAP",
+	0x1801: "GATT",
+	0x1802: "Immediate Alert",
+	0x1803: "Link Loss",
+	0x1804: "Tx Power",
+	0x1805: "Current Time",
+	0x1806: "Reference Time Update",
+	0x1807: "Next DST Change",
+	0x1808: "Glucose",
+	0x1809: "Health Thermometer",
+	0x180A: "Device Information",
+	0x180D: "Heart Rate",
+	0x180E: "Phone Alert Status",
+	0x180F: "Battery",
+	0x1810: "Blood Pressure",
+	0x1811: "Alert Notification",
+	0x1812: "Human Interface Device",
+	0x1813: "Scan Parameters",
+	0x1814: "Running Speed and Cadence",
+	0x1815: "Automation IO",
+	0x1816: "Cycling Speed and Cadence",
+	0x1818: "Cycling Power",
+	0x1819: "Location and Navigation",
+	0x181A: "Environmental Sensing",
+	0x181B: "Body Composition",
+	0x181C: "User Data",
+	0x181D: "Weight Scale",
+	0x181E: "Bond Management",
+	0x181F: "Continuous Glucose Monitoring",
+	0x1820: "Internet Protocol Support",
+	0x1821: "Indoor Positioning",
+	0x1822: "Pulse Oximeter",
+	0x1823: "HTTP Proxy",
+	0x1824: "Transport Discovery",
+	0x1825: "Object Transfer",
+	0x1826: "Fitness Machine",
+	0x1827: "Mesh Provisioning",
+	0x1828: "Mesh Proxy",
+	0x1829: "Reconnection Configuration",
+	0x183A: "Insulin Delivery",
+	0x183B: "Binary Sensor",
+	0x183C: "Emergency Configuration",
+	0x183D: "Authorization Control",
+	0x183E: "Physical Activity Monitor",
+	0x183F: "Elapsed Time",
+	0x1840: "Generic Health Sensor",
+	0x1843: "Audio Input Control",
+	0x1844: "Volume Control",
+	0x1845: "Volume Offset Control",
+	0x1846: "Coordinated Set Identification",
+	0x1847: "Device Time",
+	0x1848: "Media Control",
+	0x1849: "Generic Media Control",
+	0x184A: "Constant Tone Extension",
+	0x184B: "Telephone Bearer",
+	0x184C: "Generic Telephone Bearer",
+	0x184D: "Microphone Control",
+	0x184E: "Audio Stream Control",
+	0x184F: "Broadcast Audio Scan",
+	0x1850: "Published Audio Capabilities",
+	0x1851: "Basic Audio Announcement",
+	0x1852: "Broadcast Audio Announcement",
+	0x1853: "Common Audio",
+	0x1854: "Hearing Access",
+	0x1855: "Telephony and Media Audio",
+	0x1856: "Public Broadcast Announcement",
+	0x1857: "Electronic Shelf Label",
+	0x1858: "Gaming Audio",
+	0x1859: "Mesh Proxy Solicitation",
+	0x2800: "Primary Service",
+	0x2801: "Secondary Service",
+	0x2802: "Include",
+	0x2803: "Characteristic",
+	0x2900: "Characteristic Extended Properties",
+	0x2901: "Characteristic User Description",
+	0x2902: "Client Characteristic Configuration",
+	0x2903: "Server Characteristic Configuration",
+	0x2904: "Characteristic Presentation Format",
+	0x2905: "Characteristic Aggregate Format",
+	0x2906: "Valid Range",
+	0x2907: "External Report Reference",
+	0x2908: "Report Reference",
+	0x2909: "Number of Digitals",
+	0x290A: "Value Trigger Setting",
+	0x290B: "Environmental Sensing Configuration",
+	0x290C: "Environmental Sensing Measurement",
+	0x290D: "Environmental Sensing Trigger Setting",
+	0x290E: "Time Trigger Setting",
+	0x290F: "Complete BR-EDR Transport Block Data",
+	0x2910: "Observation Schedule",
+	0x2911: "Valid Range and Accuracy",
+	0x2A00: "Device Name",
+	0x2A01: "Appearance",
+	0x2A02: "Peripheral Privacy Flag",
+	0x2A03: "Reconnection Address",
+	0x2A04: "Peripheral Preferred Connection Parameters",
+	0x2A05: "Service Changed",
+	0x2A06: "Alert Level",
+	0x2A07: "Tx Power Level",
+	0x2A08: "Date Time",
+	0x2A09: "Day of Week",
+	0x2A0A: "Day Date Time",
+	0x2A0C: "Exact Time 256",
+	0x2A0D: "DST Offset",
+	0x2A0E: "Time Zone",
+	0x2A0F: "Local Time Information",
+	0x2A11: "Time with DST",
+	0x2A12: "Time Accuracy",
+	0x2A13: "Time Source",
+	0x2A14: "Reference Time Information",
+	0x2A16: "Time Update Control Point",
+	0x2A17: "Time Update State",
+	0x2A18: "Glucose Measurement",
+	0x2A19: "Battery Level",
+	0x2A1C: "Temperature Measurement",
+	0x2A1D: "Temperature Type",
+	0x2A1E: "Intermediate Temperature",
+	0x2A21: "Measurement Interval",
+	0x2A22: "Boot Keyboard Input Report",
+	0x2A23: "System ID",
+	0x2A24: "Model Number String",
+	0x2A25: "Serial Number String",
+	0x2A26: "Firmware Revision String",
+	0x2A27: "Hardware Revision String",
+	0x2A28: "Software Revision String",
+	0x2A29: "Manufacturer Name String",
+	0x2A2A: "IEEE 11073-20601 Regulatory Certification Data List",
+	0x2A2B: "Current Time",
+	0x2A2C: "Magnetic Declination",
+	0x2A31: "Scan Refresh",
+	0x2A32: "Boot Keyboard Output Report",
+	0x2A33: "Boot Mouse Input Report",
+	0x2A34: "Glucose Measurement Context",
+	0x2A35: "Blood Pressure Measurement",
+	0x2A36: "Intermediate Cuff Pressure",
+	0x2A37: "Heart Rate Measurement",
+	0x2A38: "Body Sensor Location",
+	0x2A39: "Heart Rate Control Point",
+	0x2A3F: "Alert Status",
+	0x2A40: "Ringer Control Point",
+	0x2A41: "Ringer Setting",
+	0x2A42: "Alert Category ID Bit Mask",
+	0x2A43: "Alert Category ID",
+	0x2A44: "Alert Notification Control Point",
+	0x2A45: "Unread Alert Status",
+	0x2A46: "New Alert",
+	0x2A47: "Supported New Alert Category",
+	0x2A48: "Supported Unread Alert Category",
+	0x2A49: "Blood Pressure Feature",
+	0x2A4A: "HID Information",
+	0x2A4B: "Report Map",
+	0x2A4C: "HID Control Point",
+	0x2A4D: "Report",
+	0x2A4E: "Protocol Mode",
+	0x2A4F: "Scan Interval Window",
+	0x2A50: "PnP ID",
+	0x2A51: "Glucose Feature",
+	0x2A52: "Record Access Control Point",
+	0x2A53: "RSC Measurement",
+	0x2A54: "RSC Feature",
+	0x2A55: "SC Control Point",
+	0x2A56: "Digital",
+	0x2A58: "Analog",
+	0x2A5A: "Aggregate",
+	0x2A5B: "CSC Measurement",
+	0x2A5C: "CSC Feature",
+	0x2A5D: "Sensor Location",
+	0x2A5E: "PLX Spot-Check Measurement",
+	0x2A5F: "PLX Continuous Measurement",
+	0x2A60: "PLX Features",
+	0x2A63: "Cycling Power Measurement",
+	0x2A64: "Cycling Power Vector",
+	0x2A65: "Cycling Power Feature",
+	0x2A66: "Cycling Power Control Point",
+	0x2A67: "Location and Speed",
+	0x2A68: "Navigation",
+	0x2A69: "Position Quality",
+	0x2A6A: "LN Feature",
+	0x2A6B: "LN Control Point",
+	0x2A6C: "Elevation",
+	0x2A6D: "Pressure",
+	0x2A6E: "Temperature",
+	0x2A6F: "Humidity",
+	0x2A70: "True Wind Speed",
+	0x2A71: "True Wind Direction",
+	0x2A72: "Apparent Wind Speed",
+	0x2A73: "Apparent Wind Direction",
+	0x2A74: "Gust Factor",
+	0x2A75: "Pollen Concentration",
+	0x2A76: "UV Index",
+	0x2A77: "Irradiance",
+	0x2A78: "Rainfall",
+	0x2A79: "Wind Chill",
+	0x2A7A: "Heat Index",
+	0x2A7B: "Dew Point",
+	0x2A7D: "Descriptor Value Changed",
+	0x2A7E: "Aerobic Heart Rate Lower Limit",
+	0x2A7F: "Aerobic Threshold",
+	0x2A80: "Age",
+	0x2A81: "Anaerobic Heart Rate Lower Limit",
+	0x2A82: "Anaerobic Heart Rate Upper Limit",
+	0x2A83: "Anaerobic Threshold",
+	0x2A84: "Aerobic Heart Rate Upper Limit",
+	0x2A85: "Date of Birth",
+	0x2A86: "Date of Threshold Assessment",
+	0x2A87: "Email Address",
+	0x2A88: "Fat Burn Heart Rate Lower Limit",
+	0x2A89: "Fat Burn Heart Rate Upper Limit",
+	0x2A8A: "First Name",
+	0x2A8B: "Five Zone Heart Rate Limits",
+	0x2A8C: "Gender",
+	0x2A8D: "Heart Rate Max",
+	0x2A8E: "Height",
+	0x2A8F: "Hip Circumference",
+	0x2A90: "Last Name",
+	0x2A91: "Maximum Recommended Heart Rate",
+	0x2A92: "Resting Heart Rate",
+	0x2A93: "Sport Type for Aerobic and Anaerobic Thresholds",
+	0x2A94: "Three Zone Heart Rate Limits",
+	0x2A95: "Two Zone Heart Rate Limits",
+	0x2A96: "VO2 Max",
+	0x2A97: "Waist Circumference",
+	0x2A98: "Weight",
+	0x2A99: "Database Change Increment",
+	0x2A9A: "User Index",
+	0x2A9B: "Body Composition Feature",
+	0x2A9C: "Body Composition Measurement",
+	0x2A9D: "Weight Measurement",
+	0x2A9E: "Weight Scale Feature",
+	0x2A9F: "User Control Point",
+	0x2AA0: "Magnetic Flux Density - 2D",
+	0x2AA1: "Magnetic Flux Density - 3D",
+	0x2AA2: "Language",
+	0x2AA3: "Barometric Pressure Trend",
+	0x2AA4: "Bond Management Control Point",
+	0x2AA5: "Bond Management Feature",
+	0x2AA6: "Central Address Resolution",
+	0x2AA7: "CGM Measurement",
+	0x2AA8: "CGM Feature",
+	0x2AA9: "CGM Status",
+	0x2AAA: "CGM Session Start Time",
+	0x2AAB: "CGM Session Run Time",
+	0x2AAC: "CGM Specific Ops Control Point",
+	0x2AAD: "Indoor Positioning Configuration",
+	0x2AAE: "Latitude",
+	0x2AAF: "Longitude",
+	0x2AB0: "Local North Coordinate",
+	0x2AB1: "Local East Coordinate",
+	0x2AB2: "Floor Number",
+	0x2AB3: "Altitude",
+	0x2AB4: "Uncertainty",
+	0x2AB5: "Location Name",
+	0x2AB6: "URI",
+	0x2AB7: "HTTP Headers",
+	0x2AB8: "HTTP Status Code",
+	0x2AB9: "HTTP Entity Body",
+	0x2ABA: "HTTP Control Point",
+	0x2ABB: "HTTPS Security",
+	0x2ABC: "TDS Control Point",
+	0x2ABD: "OTS Feature",
+	0x2ABE: "Object Name",
+	0x2ABF: "Object Type",
+	0x2AC0: "Object Size",
+	0x2AC1: "Object First-Created",
+	0x2AC2: "Object Last-Modified",
+	0x2AC3: "Object ID",
+	0x2AC4: "Object Properties",
+	0x2AC5: "Object Action Control Point",
+	0x2AC6: "Object List Control Point",
+	0x2AC7: "Object List Filter",
+	0x2AC8: "Object Changed",
+	0x2AC9: "Resolvable Private Address Only",
+	0x2ACC: "Fitness Machine Feature",
+	0x2ACD: "Treadmill Data",
+	0x2ACE: "Cross Trainer Data",
+	0x2ACF: "Step Climber Data",
+	0x2AD0: "Stair Climber Data",
+	0x2AD1: "Rower Data",
+	0x2AD2: "Indoor Bike Data",
+	0x2AD3: "Training Status",
+	0x2AD4: "Supported Speed Range",
+	0x2AD5: "Supported Inclination Range",
+	0x2AD6: "Supported Resistance Level Range",
+	0x2AD7: "Supported Heart Rate Range",
+	0x2AD8: "Supported Power Range",
+	0x2AD9: "Fitness Machine Control Point",
+	0x2ADA: "Fitness Machine Status",
+	0x2B29: "Client Supported Features",
+	0x2B2A: "Database Hash",
+	0x2B3A: "Server Supported Features",
+}
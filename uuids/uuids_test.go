@@ -0,0 +1,31 @@
+package uuids
+
+import (
+	"testing"
+
+	"github.com/kirbo/ble"
+)
+
+func TestUUIDs(t *testing.T) {
+	for _, tc := range []struct {
+		u    ble.UUID
+		want ble.UUID
+		name string
+	}{
+		{HeartRateService, ble.HeartRateUUID, "Heart Rate"},
+		{BatteryService, ble.BatteryUUID, "Battery"},
+		{BatteryLevelChar, ble.UUID16(0x2A19), "Battery Level"},
+		{ClientCharacteristicConfigurationDesc, ble.ClientCharacteristicConfigUUID, "Client Characteristic Configuration"},
+		{PrimaryServiceDecl, ble.PrimaryServiceUUID, "Primary Service"},
+	} {
+		if !tc.u.Equal(tc.want) {
+			t.Errorf("%s: got %s, want %s", tc.name, tc.u, tc.want)
+		}
+		if got := Name(tc.u); got != tc.name {
+			t.Errorf("Name(%s) = %q, want %q", tc.u, got, tc.name)
+		}
+	}
+	if got := Name(ble.MustParse("6E400001-B5A3-F393-E0A9-E50E24DCCA9E")); got != "" {
+		t.Errorf("Name of a vendor UUID = %q, want \"\"", got)
+	}
+}